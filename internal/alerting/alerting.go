@@ -0,0 +1,360 @@
+// Package alerting evaluates metric threshold rules and notifies a webhook when they fire.
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// MetricErrorRate is the percentage of failed requests in the rule window.
+	MetricErrorRate = "error_rate"
+	// MetricP95Latency is the 95th percentile request latency in milliseconds.
+	MetricP95Latency = "p95_latency"
+
+	// StatusFiring marks a notification for a newly breached rule.
+	StatusFiring = "firing"
+	// StatusResolved marks a notification for a rule that recovered.
+	StatusResolved = "resolved"
+
+	defaultWindow             = time.Minute
+	defaultEvaluationInterval = 15 * time.Second
+	webhookTimeout            = 10 * time.Second
+)
+
+// Notification is the JSON payload posted to the webhook.
+type Notification struct {
+	Alert         string    `json:"alert"`
+	Status        string    `json:"status"`
+	Metric        string    `json:"metric"`
+	Operator      string    `json:"operator"`
+	Threshold     float64   `json:"threshold"`
+	Value         float64   `json:"value"`
+	Requests      int       `json:"requests"`
+	WindowSeconds int       `json:"window_seconds"`
+	StartsAt      time.Time `json:"starts_at"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// rule is a validated AlertRule plus its evaluation state.
+type rule struct {
+	cfg    config.AlertRule
+	window time.Duration
+	hold   time.Duration
+
+	pendingSince time.Time
+	firing       bool
+}
+
+// latencyBoundsMs are the upper bounds of the latency histogram kept per slot; latencies
+// above the last bound fall into an overflow bucket.
+var latencyBoundsMs = [...]float64{50, 100, 250, 500, 1000, 2500, 5000, 10000, 20000, 30000, 60000, 120000, 300000}
+
+// slot aggregates the request outcomes observed during one slot of the manager's slot width.
+// Each latency bucket also keeps its largest latency, so a percentile falling in it is
+// reported as a latency that was actually observed.
+type slot struct {
+	start      time.Time
+	requests   int
+	failures   int
+	latency    [len(latencyBoundsMs) + 1]int
+	maxLatency [len(latencyBoundsMs) + 1]float64
+}
+
+// Manager records request outcomes and evaluates alert rules against them. Outcomes are
+// counted in fixed-width slots, so memory is bounded by the longest rule window rather
+// than by traffic.
+type Manager struct {
+	mu        sync.Mutex
+	rules     []*rule
+	slots     []slot
+	slotWidth time.Duration
+	maxWindow time.Duration
+	interval  time.Duration
+
+	webhookURL string
+	client     *http.Client
+	now        func() time.Time
+}
+
+// NewManager validates the configured rules and returns a manager.
+func NewManager(cfg config.AlertingConfig) (*Manager, error) {
+	m := &Manager{
+		webhookURL: strings.TrimSpace(cfg.WebhookURL),
+		client:     &http.Client{Timeout: webhookTimeout},
+		interval:   defaultEvaluationInterval,
+		now:        time.Now,
+	}
+	if cfg.EvaluationIntervalSeconds > 0 {
+		m.interval = time.Duration(cfg.EvaluationIntervalSeconds) * time.Second
+	}
+	for i, rc := range cfg.Rules {
+		rc.Metric = strings.ToLower(strings.TrimSpace(rc.Metric))
+		if rc.Metric != MetricErrorRate && rc.Metric != MetricP95Latency {
+			return nil, fmt.Errorf("alerting: rule %d: unsupported metric %q", i, rc.Metric)
+		}
+		rc.Operator = strings.TrimSpace(rc.Operator)
+		if rc.Operator == "" {
+			rc.Operator = ">"
+		}
+		switch rc.Operator {
+		case ">", ">=", "<", "<=":
+		default:
+			return nil, fmt.Errorf("alerting: rule %d: unsupported operator %q", i, rc.Operator)
+		}
+		if strings.TrimSpace(rc.Name) == "" {
+			rc.Name = fmt.Sprintf("%s %s %g", rc.Metric, rc.Operator, rc.Threshold)
+		}
+		r := &rule{cfg: rc, window: defaultWindow, hold: time.Duration(rc.ForSeconds) * time.Second}
+		if rc.WindowSeconds > 0 {
+			r.window = time.Duration(rc.WindowSeconds) * time.Second
+		}
+		if r.window > m.maxWindow {
+			m.maxWindow = r.window
+		}
+		m.rules = append(m.rules, r)
+	}
+	// Slots are one evaluation interval wide, but never wider than the shortest window.
+	m.slotWidth = m.interval
+	for _, r := range m.rules {
+		if r.window < m.slotWidth {
+			m.slotWidth = r.window
+		}
+	}
+	return m, nil
+}
+
+// Observe records the outcome of one completed request.
+func (m *Manager) Observe(latencyMs float64, success bool) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.rules) == 0 {
+		return
+	}
+	now := m.now()
+	start := now.Truncate(m.slotWidth)
+	if n := len(m.slots); n == 0 || !m.slots[n-1].start.Equal(start) {
+		m.slots = append(m.slots, slot{start: start})
+	}
+	s := &m.slots[len(m.slots)-1]
+	s.requests++
+	if !success {
+		s.failures++
+	}
+	idx := sort.SearchFloat64s(latencyBoundsMs[:], latencyMs)
+	s.latency[idx]++
+	if latencyMs > s.maxLatency[idx] {
+		s.maxLatency[idx] = latencyMs
+	}
+	m.pruneLocked(now)
+}
+
+// pruneLocked drops slots that lie entirely outside the longest rule window.
+func (m *Manager) pruneLocked(now time.Time) {
+	cutoff := now.Add(-m.maxWindow)
+	drop := 0
+	for drop < len(m.slots) && !m.slots[drop].start.Add(m.slotWidth).After(cutoff) {
+		drop++
+	}
+	if drop > 0 {
+		m.slots = append(m.slots[:0], m.slots[drop:]...)
+	}
+}
+
+// Evaluate checks every rule and sends notifications for state transitions.
+// A rule fires once after its condition has held for ForSeconds; repeated
+// evaluations while it stays breached are deduplicated until it resolves.
+func (m *Manager) Evaluate(ctx context.Context) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	now := m.now()
+	m.pruneLocked(now)
+	var pending []Notification
+	for _, r := range m.rules {
+		value, requests, ok := m.measureLocked(r, now)
+		breached := ok && compare(value, r.cfg.Operator, r.cfg.Threshold)
+		switch {
+		case breached:
+			if r.pendingSince.IsZero() {
+				r.pendingSince = now
+			}
+			if !r.firing && now.Sub(r.pendingSince) >= r.hold {
+				r.firing = true
+				pending = append(pending, r.notification(StatusFiring, value, requests, now))
+			}
+		case r.firing:
+			pending = append(pending, r.notification(StatusResolved, value, requests, now))
+			r.firing = false
+			r.pendingSince = time.Time{}
+		default:
+			r.pendingSince = time.Time{}
+		}
+	}
+	m.mu.Unlock()
+
+	for _, n := range pending {
+		if err := m.send(ctx, n); err != nil {
+			log.Warnf("alerting: failed to deliver %s notification for %q: %v", n.Status, n.Alert, err)
+		}
+	}
+}
+
+// measureLocked computes the rule metric over its window. A slot counts while any part of
+// it lies inside the window. The p95 latency is the largest latency seen in the histogram
+// bucket holding the 95th percentile. It reports false when the window holds fewer
+// requests than MinRequests or none at all.
+func (m *Manager) measureLocked(r *rule, now time.Time) (float64, int, bool) {
+	cutoff := now.Add(-r.window)
+	var total slot
+	for i := range m.slots {
+		s := &m.slots[i]
+		if !s.start.Add(m.slotWidth).After(cutoff) {
+			continue
+		}
+		total.requests += s.requests
+		total.failures += s.failures
+		for b, n := range s.latency {
+			total.latency[b] += n
+			if s.maxLatency[b] > total.maxLatency[b] {
+				total.maxLatency[b] = s.maxLatency[b]
+			}
+		}
+	}
+	requests := total.requests
+	if requests == 0 || requests < r.cfg.MinRequests {
+		return 0, requests, false
+	}
+	switch r.cfg.Metric {
+	case MetricErrorRate:
+		return float64(total.failures) / float64(requests) * 100, requests, true
+	default:
+		rank := requests * 95 / 100
+		seen := 0
+		for b, n := range total.latency {
+			seen += n
+			if seen > rank {
+				return total.maxLatency[b], requests, true
+			}
+		}
+		return total.maxLatency[len(total.maxLatency)-1], requests, true
+	}
+}
+
+func (r *rule) notification(status string, value float64, requests int, now time.Time) Notification {
+	return Notification{
+		Alert:         r.cfg.Name,
+		Status:        status,
+		Metric:        r.cfg.Metric,
+		Operator:      r.cfg.Operator,
+		Threshold:     r.cfg.Threshold,
+		Value:         value,
+		Requests:      requests,
+		WindowSeconds: int(r.window / time.Second),
+		StartsAt:      r.pendingSince,
+		Timestamp:     now,
+	}
+}
+
+func compare(value float64, op string, threshold float64) bool {
+	switch op {
+	case ">=":
+		return value >= threshold
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	default:
+		return value > threshold
+	}
+}
+
+// send posts a notification to the configured webhook.
+func (m *Manager) send(ctx context.Context, n Notification) error {
+	if m.webhookURL == "" {
+		log.Warnf("alerting: %s %q (value %.2f, threshold %g) but no webhook-url is configured", n.Status, n.Alert, n.Value, n.Threshold)
+		return nil
+	}
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Run evaluates rules on the configured interval until ctx is cancelled.
+func (m *Manager) Run(ctx context.Context) {
+	if m == nil || len(m.rules) == 0 {
+		return
+	}
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Evaluate(ctx)
+		}
+	}
+}
+
+var (
+	globalMu      sync.RWMutex
+	globalManager *Manager
+)
+
+// Init builds the global manager from cfg. A disabled config clears it.
+func Init(cfg config.AlertingConfig) (*Manager, error) {
+	if !cfg.Enabled {
+		SetManager(nil)
+		return nil, nil
+	}
+	m, err := NewManager(cfg)
+	if err != nil {
+		return nil, err
+	}
+	SetManager(m)
+	return m, nil
+}
+
+// SetManager replaces the global manager.
+func SetManager(m *Manager) {
+	globalMu.Lock()
+	globalManager = m
+	globalMu.Unlock()
+}
+
+// GetManager returns the global manager, or nil when alerting is disabled.
+func GetManager() *Manager {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return globalManager
+}
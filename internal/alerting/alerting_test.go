@@ -0,0 +1,183 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+type webhookRecorder struct {
+	mu       sync.Mutex
+	received []Notification
+}
+
+func (w *webhookRecorder) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	var n Notification
+	if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.mu.Lock()
+	w.received = append(w.received, n)
+	w.mu.Unlock()
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (w *webhookRecorder) notifications() []Notification {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]Notification(nil), w.received...)
+}
+
+func newTestManager(t *testing.T, rules ...config.AlertRule) (*Manager, *webhookRecorder, *time.Time) {
+	t.Helper()
+	recorder := &webhookRecorder{}
+	srv := httptest.NewServer(recorder)
+	t.Cleanup(srv.Close)
+
+	m, err := NewManager(config.AlertingConfig{Enabled: true, WebhookURL: srv.URL, Rules: rules})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	clock := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return clock }
+	return m, recorder, &clock
+}
+
+func TestErrorRateAlertFiresOnceAfterHoldAndResolves(t *testing.T) {
+	m, recorder, clock := newTestManager(t, config.AlertRule{
+		Name:          "high-error-rate",
+		Metric:        MetricErrorRate,
+		Threshold:     10,
+		WindowSeconds: 600,
+		ForSeconds:    300,
+	})
+	ctx := context.Background()
+
+	// 3 of 10 requests fail: 30% error rate.
+	for i := 0; i < 10; i++ {
+		m.Observe(100, i >= 3)
+	}
+	m.Evaluate(ctx)
+	if got := recorder.notifications(); len(got) != 0 {
+		t.Fatalf("alert fired before the hold period elapsed: %+v", got)
+	}
+
+	*clock = clock.Add(5 * time.Minute)
+	m.Evaluate(ctx)
+	*clock = clock.Add(time.Minute)
+	m.Evaluate(ctx)
+
+	got := recorder.notifications()
+	if len(got) != 1 {
+		t.Fatalf("expected exactly one firing notification, got %d: %+v", len(got), got)
+	}
+	n := got[0]
+	if n.Alert != "high-error-rate" || n.Status != StatusFiring || n.Metric != MetricErrorRate {
+		t.Fatalf("unexpected notification: %+v", n)
+	}
+	if n.Value != 30 || n.Threshold != 10 || n.Operator != ">" || n.Requests != 10 || n.WindowSeconds != 600 {
+		t.Fatalf("unexpected notification values: %+v", n)
+	}
+
+	// Healthy traffic pushes the rate under the threshold: 3 of 40 fail.
+	for i := 0; i < 30; i++ {
+		m.Observe(100, true)
+	}
+	m.Evaluate(ctx)
+	got = recorder.notifications()
+	if len(got) != 2 || got[1].Status != StatusResolved {
+		t.Fatalf("expected a resolved notification, got %+v", got)
+	}
+}
+
+func TestP95LatencyAlertFiresImmediately(t *testing.T) {
+	m, recorder, _ := newTestManager(t, config.AlertRule{
+		Metric:    MetricP95Latency,
+		Threshold: 5000,
+	})
+
+	for i := 0; i < 90; i++ {
+		m.Observe(200, true)
+	}
+	m.Evaluate(context.Background())
+	if got := recorder.notifications(); len(got) != 0 {
+		t.Fatalf("alert fired under threshold: %+v", got)
+	}
+
+	for i := 0; i < 10; i++ {
+		m.Observe(8000, true)
+	}
+	m.Evaluate(context.Background())
+	got := recorder.notifications()
+	if len(got) != 1 || got[0].Status != StatusFiring || got[0].Value != 8000 {
+		t.Fatalf("unexpected notifications: %+v", got)
+	}
+	if got[0].Alert != "p95_latency > 5000" {
+		t.Fatalf("default alert name = %q", got[0].Alert)
+	}
+}
+
+func TestMinRequestsAndWindowPruning(t *testing.T) {
+	m, recorder, clock := newTestManager(t, config.AlertRule{
+		Name:          "errors",
+		Metric:        MetricErrorRate,
+		Threshold:     50,
+		WindowSeconds: 60,
+		MinRequests:   5,
+	})
+
+	m.Observe(10, false)
+	m.Observe(10, false)
+	m.Evaluate(context.Background())
+	if got := recorder.notifications(); len(got) != 0 {
+		t.Fatalf("alert fired below min-requests: %+v", got)
+	}
+
+	*clock = clock.Add(2 * time.Minute)
+	for i := 0; i < 5; i++ {
+		m.Observe(10, true)
+	}
+	m.Evaluate(context.Background())
+	if got := recorder.notifications(); len(got) != 0 {
+		t.Fatalf("stale failures outside the window triggered an alert: %+v", got)
+	}
+}
+
+func TestNewManagerRejectsInvalidRules(t *testing.T) {
+	if _, err := NewManager(config.AlertingConfig{Rules: []config.AlertRule{{Metric: "cpu"}}}); err == nil {
+		t.Fatalf("expected error for unsupported metric")
+	}
+	if _, err := NewManager(config.AlertingConfig{Rules: []config.AlertRule{{Metric: MetricErrorRate, Operator: "!="}}}); err == nil {
+		t.Fatalf("expected error for unsupported operator")
+	}
+}
+
+func TestObserveKeepsMemoryBoundedByWindow(t *testing.T) {
+	m, _, clock := newTestManager(t, config.AlertRule{
+		Metric:        MetricErrorRate,
+		Threshold:     50,
+		WindowSeconds: 60,
+	})
+
+	// An hour of steady traffic, ten requests a second.
+	for i := 0; i < 3600; i++ {
+		for j := 0; j < 10; j++ {
+			m.Observe(100, true)
+		}
+		*clock = clock.Add(time.Second)
+	}
+	if max := int(m.maxWindow/m.slotWidth) + 1; len(m.slots) > max {
+		t.Fatalf("kept %d slots, want at most %d for a 60s window", len(m.slots), max)
+	}
+	value, requests, ok := m.measureLocked(m.rules[0], m.now())
+	if !ok || value != 0 || requests < 600 || requests > 600+10*int(m.slotWidth/time.Second) {
+		t.Fatalf("measure = %v over %d requests (ok %v), want a clean rate over about a minute of traffic", value, requests, ok)
+	}
+}
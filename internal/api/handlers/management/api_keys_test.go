@@ -0,0 +1,76 @@
+package management
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/access"
+	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+)
+
+func TestRotateAPIKeys_AppliesToAuthenticationAndPersists(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	configaccess.Register()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("api-keys:\n  - old-key\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{}
+	cfg.APIKeys = []string{"old-key"}
+	manager := sdkaccess.NewManager()
+	if _, err := access.ApplyAccessProviders(manager, nil, cfg); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(cfg, path, nil)
+	h.SetAccessManager(manager)
+
+	authenticates := func(key string) bool {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		result, err := manager.Authenticate(req.Context(), req)
+		return err == nil && result != nil
+	}
+	rotate := func(body string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v0/management/api-keys/rotate", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		h.RotateAPIKeys(c)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("rotate %s: status %d, body %s", body, rec.Code, rec.Body.String())
+		}
+	}
+
+	if authenticates("new-key") {
+		t.Fatal("new-key must not authenticate before it is added")
+	}
+	rotate(`{"add":["new-key"]}`)
+	if !authenticates("new-key") || !authenticates("old-key") {
+		t.Fatal("expected both keys to authenticate after adding new-key")
+	}
+
+	rotate(`{"remove":["new-key"]}`)
+	if authenticates("new-key") {
+		t.Fatal("removed key still authenticates")
+	}
+	if !authenticates("old-key") {
+		t.Fatal("old-key should still authenticate")
+	}
+
+	saved, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("reload config: %v", err)
+	}
+	if len(saved.APIKeys) != 1 || saved.APIKeys[0] != "old-key" {
+		t.Fatalf("persisted api-keys = %v, want [old-key]", saved.APIKeys)
+	}
+}
@@ -0,0 +1,53 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+)
+
+// WarmupCache preloads the response cache. The body either lists entries to store:
+//
+//	{"entries": [{"model": "...", "prompt": "...", "response": "..."}]}
+//
+// Each response is stored under the key of a /v1/chat/completions request sending prompt
+// as its only user message, so that request is then answered from the cache.
+//
+// or asks to copy the most recently used Redis keys into the local cache:
+//
+//	{"rehydrate_top_n": 500}
+//
+// Rehydration needs Redis with redis.access-index-size set. The response reports how many
+// entries were loaded, skipped and failed.
+func (h *Handler) WarmupCache(c *gin.Context) {
+	var body struct {
+		Entries       []cache.WarmupEntry `json:"entries"`
+		RehydrateTopN int                 `json:"rehydrate_top_n"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || (len(body.Entries) == 0) == (body.RehydrateTopN <= 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "provide either entries or rehydrate_top_n"})
+		return
+	}
+
+	cs := cache.GetCacheSystem()
+	if len(body.Entries) > 0 {
+		keyFor := func(model string, request []byte) string {
+			if h.cfg == nil {
+				return ""
+			}
+			return handlers.ResponseCacheKeyFor(&h.cfg.SDKConfig, constant.OpenAI, model, request, "")
+		}
+		c.JSON(http.StatusOK, gin.H{"mode": "preload", "report": cs.Preload(body.Entries, keyFor)})
+		return
+	}
+
+	report, err := cs.RehydrateFromRedis(body.RehydrateTopN)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mode": "rehydrate", "report": report})
+}
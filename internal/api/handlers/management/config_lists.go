@@ -6,7 +6,9 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/access"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
 )
 
 // Generic helpers for list[string]
@@ -110,13 +112,65 @@ func (h *Handler) PutAPIKeys(c *gin.Context) {
 	h.putStringList(c, func(v []string) {
 		h.cfg.APIKeys = append([]string(nil), v...)
 		h.cfg.Access.Providers = nil
-	}, nil)
+	}, h.applyAccessKeys)
 }
 func (h *Handler) PatchAPIKeys(c *gin.Context) {
-	h.patchStringList(c, &h.cfg.APIKeys, func() { h.cfg.Access.Providers = nil })
+	h.patchStringList(c, &h.cfg.APIKeys, h.applyAccessKeys)
 }
 func (h *Handler) DeleteAPIKeys(c *gin.Context) {
-	h.deleteFromStringList(c, &h.cfg.APIKeys, func() { h.cfg.Access.Providers = nil })
+	h.deleteFromStringList(c, &h.cfg.APIKeys, h.applyAccessKeys)
+}
+
+// RotateAPIKeys adds and removes client API keys in one step, e.g. to introduce a new key
+// and retire the old one. Inline access-provider keys are merged into api-keys when the
+// config loads, so this covers both. Body: {"add": ["new-key"], "remove": ["old-key"]}.
+func (h *Handler) RotateAPIKeys(c *gin.Context) {
+	var body struct {
+		Add    []string `json:"add"`
+		Remove []string `json:"remove"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || len(body.Add)+len(body.Remove) == 0 {
+		c.JSON(400, gin.H{"error": "invalid body"})
+		return
+	}
+	removed := make(map[string]struct{}, len(body.Remove))
+	for _, key := range body.Remove {
+		if key = strings.TrimSpace(key); key != "" {
+			removed[key] = struct{}{}
+		}
+	}
+	keys := make([]string, 0, len(h.cfg.APIKeys)+len(body.Add))
+	seen := make(map[string]struct{}, cap(keys))
+	for _, key := range append(append([]string(nil), h.cfg.APIKeys...), body.Add...) {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if _, drop := removed[key]; drop {
+			continue
+		}
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		seen[key] = struct{}{}
+		keys = append(keys, key)
+	}
+	h.cfg.APIKeys = keys
+	h.applyAccessKeys()
+	h.persist(c)
+}
+
+// applyAccessKeys drops the inline access providers, which config loading folds into
+// api-keys, and swaps the request authentication providers for ones built from the
+// current api-keys, so key changes take effect before the config file is reloaded.
+func (h *Handler) applyAccessKeys() {
+	h.cfg.Access.Providers = nil
+	if h.accessManager == nil {
+		return
+	}
+	if _, err := access.ApplyAccessProviders(h.accessManager, h.cfg, h.cfg); err != nil {
+		log.Errorf("management: failed to apply api-keys: %v", err)
+	}
 }
 
 // gemini-api-key: []GeminiKey
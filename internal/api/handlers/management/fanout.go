@@ -0,0 +1,28 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+)
+
+// GetFanoutStreams reports every shared stream tracked by the stream fan-out, with its
+// subscriber count, buffered events, age and completion status. Completed streams remain
+// listed until the dedup window expires and cleanup removes them.
+func (h *Handler) GetFanoutStreams(c *gin.Context) {
+	fanout := executor.GetStreamFanout()
+	streams := fanout.Streams()
+
+	totalSubscribers := 0
+	for _, stream := range streams {
+		totalSubscribers += stream.Subscribers
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":           fanout.IsEnabled(),
+		"active_streams":    len(streams),
+		"total_subscribers": totalSubscribers,
+		"streams":           streams,
+	})
+}
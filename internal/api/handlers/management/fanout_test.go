@@ -0,0 +1,58 @@
+package management
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	"github.com/tidwall/gjson"
+)
+
+func TestGetFanoutStreams_ReportsSharedStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	fanout := executor.GetStreamFanout()
+	if !fanout.IsEnabled() {
+		t.Skip("stream fan-out disabled")
+	}
+	const key = "debug-fanout-test"
+	stream, isNew, _ := fanout.GetOrCreateStream(key)
+	if !isNew {
+		t.Fatal("expected a new stream")
+	}
+	t.Cleanup(func() {
+		stream.Complete()
+		fanout.RemoveStream(key)
+	})
+	_, _, second := fanout.GetOrCreateStream(key)
+	if second == nil {
+		t.Fatal("expected a second subscriber")
+	}
+	stream.PublishBytes([]byte(`data: {"choices":[]}`))
+	stream.PublishBytes([]byte(`data: [DONE]`))
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v0/management/debug/fanout", nil)
+	(&Handler{}).GetFanoutStreams(c)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	body := gjson.Parse(rec.Body.String())
+	if body.Get("total_subscribers").Int() < 2 {
+		t.Errorf("total_subscribers = %d, want at least 2", body.Get("total_subscribers").Int())
+	}
+	entry := body.Get(`streams.#(key=="` + key + `")`)
+	if !entry.Exists() {
+		t.Fatalf("stream %s missing from %s", key, rec.Body.String())
+	}
+	if entry.Get("subscribers").Int() != 2 || entry.Get("buffered_events").Int() != 2 {
+		t.Errorf("stream entry = %s, want 2 subscribers and 2 buffered events", entry.Raw)
+	}
+	if entry.Get("completed").Bool() || entry.Get("age_seconds").Float() < 0 {
+		t.Errorf("stream entry = %s, want an active stream with a non-negative age", entry.Raw)
+	}
+}
@@ -3,6 +3,7 @@ package management
 import (
 	"context"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -100,6 +101,20 @@ func (h *Handler) GetTPSMetrics(c *gin.Context) {
 		}
 	}
 
+	// Then the metrics file
+	if mf := usage.GetMetricsFile(); mf.IsEnabled() {
+		data, currentTPS, err := mf.GetTPSData(60)
+		if err == nil {
+			c.JSON(http.StatusOK, gin.H{
+				"current_tps": currentTPS,
+				"granularity": granularity,
+				"data":        data,
+				"source":      "file",
+			})
+			return
+		}
+	}
+
 	// Fallback to in-memory
 	hm := usage.GetHistoricalMetrics()
 	if hm == nil {
@@ -153,6 +168,20 @@ func (h *Handler) GetTPMMetrics(c *gin.Context) {
 		}
 	}
 
+	// Then the metrics file
+	if mf := usage.GetMetricsFile(); mf.IsEnabled() {
+		data, currentTPM, err := mf.GetTPMData(60)
+		if err == nil {
+			c.JSON(http.StatusOK, gin.H{
+				"current_tpm": currentTPM,
+				"granularity": granularity,
+				"data":        data,
+				"source":      "file",
+			})
+			return
+		}
+	}
+
 	// Fallback to in-memory
 	hm := usage.GetHistoricalMetrics()
 	if hm == nil {
@@ -206,6 +235,20 @@ func (h *Handler) GetTPHMetrics(c *gin.Context) {
 		}
 	}
 
+	// Then the metrics file
+	if mf := usage.GetMetricsFile(); mf.IsEnabled() {
+		data, currentTPH, err := mf.GetTPHData(24)
+		if err == nil {
+			c.JSON(http.StatusOK, gin.H{
+				"current_tph": currentTPH,
+				"range":       rangeParam,
+				"data":        data,
+				"source":      "file",
+			})
+			return
+		}
+	}
+
 	// Fallback to in-memory
 	hm := usage.GetHistoricalMetrics()
 	if hm == nil {
@@ -252,6 +295,25 @@ func (h *Handler) GetTPDMetrics(c *gin.Context) {
 		}
 	}
 
+	// Then the metrics file
+	if mf := usage.GetMetricsFile(); mf.IsEnabled() {
+		limit := 30
+		if rangeParam == "7d" {
+			limit = 7
+		}
+
+		data, currentTPD, err := mf.GetTPDData(limit)
+		if err == nil {
+			c.JSON(http.StatusOK, gin.H{
+				"current_tpd": currentTPD,
+				"range":       rangeParam,
+				"data":        data,
+				"source":      "file",
+			})
+			return
+		}
+	}
+
 	// Fallback to in-memory
 	hm := usage.GetHistoricalMetrics()
 	if hm == nil {
@@ -328,3 +390,86 @@ func calculateSummaryFromBuckets(buckets []usage.MetricBucket) HistoricalSummary
 
 	return summary
 }
+
+// TopModelsResponse is the API response for the top models endpoint.
+type TopModelsResponse struct {
+	Range  string            `json:"range"`
+	By     string            `json:"by"`
+	Source string            `json:"source"`
+	Models []usage.ModelRank `json:"models"`
+}
+
+// GetTopModels returns the top-N models ranked over a time range. The 7d and 30d ranges
+// use MetricsDB or the metrics file when enabled, falling back to in-memory history.
+// Query params:
+//   - by: requests, tokens, errors (default: requests)
+//   - n: number of models to return (default: 10)
+//   - range: 1m, 1h, 24h, 7d, 30d (default: 1h)
+func (h *Handler) GetTopModels(c *gin.Context) {
+	by := usage.RankBy(c.DefaultQuery("by", string(usage.RankByRequests)))
+	switch by {
+	case usage.RankByRequests, usage.RankByTokens, usage.RankByErrors:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "by must be one of requests, tokens, errors"})
+		return
+	}
+
+	n, err := strconv.Atoi(c.DefaultQuery("n", "10"))
+	if err != nil || n <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "n must be a positive integer"})
+		return
+	}
+
+	rangeParam := c.DefaultQuery("range", "1h")
+	resp := TopModelsResponse{Range: rangeParam, By: string(by), Source: "memory", Models: []usage.ModelRank{}}
+
+	hm := usage.GetHistoricalMetrics()
+	if hm == nil {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	var buckets []usage.MetricBucket
+	switch rangeParam {
+	case "1m":
+		buckets = hm.Snapshot(true, false, false, false).Seconds
+	case "1h":
+		buckets = hm.Snapshot(false, true, false, false).Minutes
+	case "24h":
+		buckets = hm.Snapshot(false, false, true, false).Hours
+	case "7d":
+		buckets, resp.Source = usage.TopModelDailyBuckets(c.Request.Context(), 7)
+	case "30d":
+		buckets, resp.Source = usage.TopModelDailyBuckets(c.Request.Context(), 30)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "range must be one of 1m, 1h, 24h, 7d, 30d"})
+		return
+	}
+
+	resp.Models = usage.TopModels(buckets, by, n)
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetSLO reports the configured availability SLO against daily aggregates, including the
+// remaining error budget and its burn rate. Daily data comes from MetricsDB when enabled,
+// falling back to in-memory history.
+func (h *Handler) GetSLO(c *gin.Context) {
+	target := usage.DefaultSLOTarget
+	windowDays := usage.DefaultSLOWindowDays
+	if h != nil && h.cfg != nil {
+		if h.cfg.SLO.AvailabilityTarget > 0 {
+			target = h.cfg.SLO.AvailabilityTarget / 100
+		}
+		if h.cfg.SLO.WindowDays > 0 {
+			windowDays = h.cfg.SLO.WindowDays
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	buckets, source := usage.SLODailyBuckets(ctx, windowDays)
+	report := usage.ComputeSLO(buckets, target, windowDays, time.Now())
+	report.Source = source
+	c.JSON(http.StatusOK, report)
+}
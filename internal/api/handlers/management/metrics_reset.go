@@ -0,0 +1,31 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/scheduler"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+// ResetMetrics zeroes in-memory metrics without restarting the server.
+// It resets the custom MetricsCollector, historical TPS/TPM/TPH/TPD buckets,
+// scheduler metrics, and cache hit/miss statistics.
+//
+// Counters registered with the official Prometheus client (use-official-client)
+// are monotonic by design and cannot be reset; they are left untouched, as are
+// persisted MetricsDB rows and usage statistics.
+func (h *Handler) ResetMetrics(c *gin.Context) {
+	observability.GetMetrics().Reset()
+	usage.GetHistoricalMetrics().Reset()
+	scheduler.GetScheduler().ResetMetrics()
+	cache.ResetGlobalStats()
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"reset":  []string{"metrics_collector", "historical_metrics", "scheduler_metrics", "cache_stats"},
+		"note":   "official Prometheus client counters cannot be reset",
+	})
+}
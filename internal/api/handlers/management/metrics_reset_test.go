@@ -0,0 +1,77 @@
+package management
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/scheduler"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+func TestResetMetrics_ZeroesInMemoryStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	metrics := observability.GetMetrics()
+	metrics.RecordRequest("reset-model", "success", 120, 42)
+	metrics.RecordProviderRequest("reset-provider", 120, true)
+	metrics.RecordCacheAccess(true, 1)
+	metrics.RecordTruncatedStream()
+
+	hm := usage.GetHistoricalMetrics()
+	hm.Record("reset-model", 10, 20, 50, true)
+
+	// Without workers the request is enqueued and then abandoned once the context expires.
+	sched := scheduler.GetScheduler()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	_ = sched.Schedule(ctx, "reset-key", 1, func() error { return nil })
+	cancel()
+	if snap := sched.Stats().Metrics; snap.TotalEnqueued == 0 {
+		t.Fatalf("expected scheduler to record the enqueue before reset")
+	}
+
+	streaming := cache.GetStreamingCache()
+	streaming.Get("missing-key")
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v0/management/metrics/reset", nil)
+	(&Handler{}).ResetMetrics(c)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	export := metrics.Export()
+	if strings.Contains(export, `model="reset-model"`) {
+		t.Errorf("request series survived reset:\n%s", export)
+	}
+	if strings.Contains(export, `provider="reset-provider"`) {
+		t.Errorf("provider series survived reset:\n%s", export)
+	}
+	if !strings.Contains(export, "_cache_hits_total 0") || !strings.Contains(export, "_truncated_streams_total 0") {
+		t.Errorf("counters not zeroed:\n%s", export)
+	}
+
+	// Historical accumulator should be empty after the next rollover.
+	time.Sleep(1100 * time.Millisecond)
+	snapshot := hm.Snapshot(true, false, false, false)
+	for _, bucket := range snapshot.Seconds {
+		if bucket.Requests != 0 {
+			t.Fatalf("historical bucket still has %d requests after reset", bucket.Requests)
+		}
+	}
+
+	if snap := sched.Stats().Metrics; snap.TotalEnqueued != 0 || snap.TotalExecuted != 0 {
+		t.Errorf("scheduler metrics not reset: %+v", snap)
+	}
+	if stats := streaming.Stats(); stats.Hits != 0 || stats.Misses != 0 {
+		t.Errorf("streaming cache stats not reset: %+v", stats)
+	}
+}
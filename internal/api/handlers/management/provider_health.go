@@ -268,6 +268,7 @@ type SchedulerStatsResponse struct {
 		TotalExecuted   int64 `json:"total_executed"`
 		TotalRejected   int64 `json:"total_rejected"`
 		TotalCancelled  int64 `json:"total_cancelled"`
+		TotalTimedOut   int64 `json:"total_timed_out"`
 		TotalSuccessful int64 `json:"total_successful"`
 		TotalFailed     int64 `json:"total_failed"`
 	} `json:"metrics"`
@@ -0,0 +1,61 @@
+package management
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+func TestComputeSLO_BurnRateForKnownFailures(t *testing.T) {
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	day := func(ago int) time.Time { return now.Truncate(24*time.Hour).AddDate(0, 0, -ago) }
+
+	buckets := []usage.MetricBucket{
+		// Outside the 30-day window; must not count.
+		{Timestamp: day(45), Requests: 1000, SuccessCount: 0, FailureCount: 1000},
+		{Timestamp: day(20), Requests: 4000, SuccessCount: 3998, FailureCount: 2},
+		{Timestamp: day(5), Requests: 4000, SuccessCount: 3997, FailureCount: 3},
+		{Timestamp: day(0), Requests: 2000, SuccessCount: 1995, FailureCount: 5},
+	}
+
+	report := usage.ComputeSLO(buckets, 0.999, 30, now)
+	if report.TotalRequests != 10000 || report.FailedRequests != 10 {
+		t.Fatalf("totals = %d/%d, want 10000/10", report.TotalRequests, report.FailedRequests)
+	}
+	assertClose(t, "success rate", report.SuccessRate, 0.999)
+	assertClose(t, "error budget", report.ErrorBudget, 10)
+	assertClose(t, "burn rate", report.BurnRate, 1)
+	assertClose(t, "budget remaining", report.ErrorBudgetRemaining, 0)
+
+	report = usage.ComputeSLO(buckets, 0.995, 30, now)
+	assertClose(t, "burn rate at 99.5%", report.BurnRate, 0.2)
+	assertClose(t, "budget remaining at 99.5%", report.ErrorBudgetRemaining, 0.8)
+	if !report.Met {
+		t.Fatal("99.5% target should be met")
+	}
+
+	report = usage.ComputeSLO(buckets, 0.9999, 30, now)
+	assertClose(t, "burn rate at 99.99%", report.BurnRate, 10)
+	if report.Met || report.ErrorBudgetRemaining >= 0 {
+		t.Fatalf("99.99%% target should be violated, got %+v", report)
+	}
+}
+
+func TestComputeSLO_NoTraffic(t *testing.T) {
+	report := usage.ComputeSLO(nil, 0, 0, time.Now())
+	if report.Target != usage.DefaultSLOTarget || report.WindowDays != usage.DefaultSLOWindowDays {
+		t.Fatalf("defaults not applied: %+v", report)
+	}
+	if report.SuccessRate != 1 || report.BurnRate != 0 || report.ErrorBudgetRemaining != 1 || !report.Met {
+		t.Fatalf("idle service should have a full budget: %+v", report)
+	}
+}
+
+func assertClose(t *testing.T, name string, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 1e-9 {
+		t.Fatalf("%s = %v, want %v", name, got, want)
+	}
+}
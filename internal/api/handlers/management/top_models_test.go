@@ -0,0 +1,87 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+func topModelsDataset() []usage.MetricBucket {
+	return []usage.MetricBucket{
+		{ByModel: map[string]usage.ModelBucket{
+			"leader":  {Requests: 80, Tokens: 1000, FailureCount: 1},
+			"bulky":   {Requests: 10, Tokens: 90000},
+			"flaky":   {Requests: 10, Tokens: 500, FailureCount: 6},
+			"unknown": {},
+		}},
+		{ByModel: map[string]usage.ModelBucket{
+			"leader": {Requests: 40, Tokens: 800},
+			"flaky":  {Requests: 2, Tokens: 100, FailureCount: 2},
+		}},
+	}
+}
+
+func TestTopModels_RanksClearLeader(t *testing.T) {
+	buckets := topModelsDataset()
+
+	cases := []struct {
+		by   usage.RankBy
+		want []string
+	}{
+		{usage.RankByRequests, []string{"leader", "flaky", "bulky"}},
+		{usage.RankByTokens, []string{"bulky", "leader", "flaky"}},
+		{usage.RankByErrors, []string{"flaky", "leader", "bulky"}},
+	}
+	for _, tc := range cases {
+		got := usage.TopModels(buckets, tc.by, 0)
+		if len(got) != len(tc.want) {
+			t.Fatalf("by=%s: got %d models, want %d", tc.by, len(got), len(tc.want))
+		}
+		for i, model := range tc.want {
+			if got[i].Model != model {
+				t.Errorf("by=%s: rank %d = %q, want %q", tc.by, i, got[i].Model, model)
+			}
+		}
+	}
+
+	top := usage.TopModels(buckets, usage.RankByRequests, 1)
+	if len(top) != 1 || top[0].Model != "leader" || top[0].Requests != 120 || top[0].Tokens != 1800 {
+		t.Fatalf("unexpected top entry: %+v", top)
+	}
+	if flaky := usage.TopModels(buckets, usage.RankByErrors, 1)[0]; flaky.Errors != 8 || flaky.ErrorRate < 66 || flaky.ErrorRate > 67 {
+		t.Fatalf("unexpected error stats for flaky: %+v", flaky)
+	}
+}
+
+func TestGetTopModels_ValidatesQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, query := range []string{"by=latency", "n=0", "n=abc", "range=2h"} {
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest(http.MethodGet, "/v0/management/metrics/top?"+query, nil)
+		(&Handler{}).GetTopModels(c)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v0/management/metrics/top?by=tokens&n=3&range=24h", nil)
+	(&Handler{}).GetTopModels(c)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var resp TopModelsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.By != "tokens" || resp.Range != "24h" || len(resp.Models) > 3 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
@@ -0,0 +1,81 @@
+// Package middleware provides HTTP middleware components for the API server.
+// This file emits one access-log line per API request tracing how it was served.
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// accessLogLogger receives the access-log lines; replaced in tests.
+var accessLogLogger = log.StandardLogger()
+
+// AccessLogMiddleware logs a single structured line per request while enabled reports true,
+// combining the request, how it was routed, every upstream call with its retries and
+// failovers, the cache status, the upstream latency and the final status, keyed by the
+// request id. The upstream trace and routing details are read from the audit_* context
+// values set by the handlers, so the middleware must run outside AuditMiddleware.
+func AccessLogMiddleware(enabled func() bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if enabled == nil || !enabled() {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		path := c.Request.URL.Path
+		if query := util.MaskSensitiveQuery(c.Request.URL.RawQuery); query != "" {
+			path += "?" + query
+		}
+		cacheStatus := c.Writer.Header().Get("X-Cache")
+		if cacheStatus == "" {
+			cacheStatus = "BYPASS"
+		}
+		attempts := c.GetStringSlice("audit_upstream_attempts")
+		fields := log.Fields{
+			"request_id": logging.GetGinRequestID(c),
+			"method":     c.Request.Method,
+			"path":       path,
+			"model":      getStringFromContext(c, "audit_model"),
+			"provider":   getStringFromContext(c, "audit_provider"),
+			"cache":      cacheStatus,
+			"attempts":   len(attempts),
+			"retries":    c.GetInt("audit_retries"),
+			"upstream":   strings.Join(attempts, " > "),
+			"status":     c.Writer.Status(),
+			"latency_ms": time.Since(start).Milliseconds(),
+		}
+		if latency, ok := c.Get("audit_upstream_latency_ms"); ok {
+			fields["upstream_latency_ms"] = latency
+		}
+		meta := c.GetStringMapString("audit_metadata")
+		for field, key := range map[string]string{
+			"requested_model":    "original_model",
+			"fallback_model":     "fallback_model",
+			"default_provider":   "default_provider",
+			"experiment_variant": "experiment_variant",
+		} {
+			if value := meta[key]; value != "" {
+				fields[field] = value
+			}
+		}
+		if len(c.Errors) > 0 {
+			fields["error"] = RedactSecrets(c.Errors.Last().Error())
+		}
+
+		entry := accessLogLogger.WithFields(fields)
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			entry.Warn("access")
+			return
+		}
+		entry.Info("access")
+	}
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+// rateLimitedErr is a 429 asking the caller to retry shortly.
+type rateLimitedErr struct{ retryAfter time.Duration }
+
+func (e rateLimitedErr) Error() string              { return "rate limited" }
+func (e rateLimitedErr) StatusCode() int            { return http.StatusTooManyRequests }
+func (e rateLimitedErr) RetryAfter() *time.Duration { return &e.retryAfter }
+
+// flakyExecutor is rate limited on its first call and succeeds afterwards.
+type flakyExecutor struct {
+	calls atomic.Int32
+}
+
+func (e *flakyExecutor) Identifier() string { return "codex" }
+
+func (e *flakyExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	if e.calls.Add(1) == 1 {
+		return coreexecutor.Response{}, rateLimitedErr{retryAfter: 10 * time.Millisecond}
+	}
+	return coreexecutor.Response{Payload: []byte(`{"ok":true}`)}, nil
+}
+
+func (e *flakyExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (e *flakyExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *flakyExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (e *flakyExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func TestAccessLog_TracesRetryInOneLine(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger, hook := test.NewNullLogger()
+	prevLogger := accessLogLogger
+	accessLogLogger = logger
+	t.Cleanup(func() { accessLogLogger = prevLogger })
+
+	const model = "access-log-test-model"
+	executor := &flakyExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	manager.SetRetryConfig(1, time.Second)
+	auth := &coreauth.Auth{ID: "access-log-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: model}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+	handler := handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager)
+
+	engine := gin.New()
+	engine.Use(AccessLogMiddleware(func() bool { return true }))
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), "gin", c)
+		resp, errMsg := handler.ExecuteWithAuthManager(ctx, "openai", model, []byte(`{"model":"`+model+`"}`), "")
+		if errMsg != nil {
+			c.JSON(errMsg.StatusCode, gin.H{"error": errMsg.Error.Error()})
+			return
+		}
+		c.Data(http.StatusOK, "application/json", resp)
+	})
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	entries := hook.AllEntries()
+	if len(entries) != 1 {
+		t.Fatalf("expected one access log line, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.Level != logrus.InfoLevel || entry.Message != "access" {
+		t.Fatalf("entry = %s %q, want info access", entry.Level, entry.Message)
+	}
+	for field, want := range map[string]any{
+		"method":   http.MethodPost,
+		"path":     "/v1/chat/completions",
+		"model":    model,
+		"provider": "codex",
+		"cache":    "BYPASS",
+		"attempts": 2,
+		"retries":  1,
+		"status":   http.StatusOK,
+	} {
+		if got := entry.Data[field]; got != want {
+			t.Errorf("field %s = %v, want %v", field, got, want)
+		}
+	}
+	upstream, _ := entry.Data["upstream"].(string)
+	hops := strings.Split(upstream, " > ")
+	if len(hops) != 2 || !strings.HasPrefix(hops[0], "codex/access-log-auth 429 ") || !strings.HasPrefix(hops[1], "codex/access-log-auth ok ") {
+		t.Errorf("upstream = %q, want a 429 followed by a success", upstream)
+	}
+	for _, field := range []string{"request_id", "latency_ms", "upstream_latency_ms"} {
+		if _, ok := entry.Data[field]; !ok {
+			t.Errorf("missing field %s", field)
+		}
+	}
+}
+
+func TestAccessLog_Disabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger, hook := test.NewNullLogger()
+	prevLogger := accessLogLogger
+	accessLogLogger = logger
+	t.Cleanup(func() { accessLogLogger = prevLogger })
+
+	engine := gin.New()
+	engine.Use(AccessLogMiddleware(func() bool { return false }))
+	engine.GET("/v1/models", func(c *gin.Context) { c.Status(http.StatusOK) })
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	if n := len(hook.AllEntries()); n != 0 {
+		t.Fatalf("expected no access log lines while disabled, got %d", n)
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/audit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
 	"github.com/tidwall/gjson"
 )
 
@@ -26,7 +27,9 @@ func (w *responseBodyWriter) Write(b []byte) (int, error) {
 
 // AuditMiddleware creates a middleware that logs all API requests to the audit log.
 // It captures request/response metadata including latency, tokens, and errors.
-func AuditMiddleware() gin.HandlerFunc {
+// keyLabel, when set, resolves the client API key to its configured label, which is
+// recorded as the entry's auth label and on the per-key request metric.
+func AuditMiddleware(keyLabel func(apiKey string) string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Skip non-API paths
 		path := c.Request.URL.Path
@@ -88,9 +91,22 @@ func AuditMiddleware() gin.HandlerFunc {
 			}
 		}
 
+		// Publish the resolved values for outer middleware such as the structured request log
+		c.Set("audit_model", model)
+		c.Set("audit_input_tokens", inputTokens)
+		c.Set("audit_output_tokens", outputTokens)
+
 		// Get auth info from context
 		authID := getStringFromContext(c, "auth_id")
 		authLabel := getStringFromContext(c, "auth_label")
+		apiKey := getStringFromContext(c, "apiKey")
+		var keyLabelValue string
+		if keyLabel != nil && apiKey != "" {
+			keyLabelValue = keyLabel(apiKey)
+		}
+		if authLabel == "" {
+			authLabel = keyLabelValue
+		}
 
 		// Check if cached
 		cached := false
@@ -106,8 +122,23 @@ func AuditMiddleware() gin.HandlerFunc {
 			reqError = c.Errors.Last().Err
 		}
 
+		// Get handler-supplied metadata (e.g. experiment variant)
+		var metadata map[string]string
+		if ctxMeta, exists := c.Get("audit_metadata"); exists {
+			if m, ok := ctxMeta.(map[string]string); ok {
+				metadata = m
+			}
+		}
+
+		// Attribute the request to the client key without exposing it
+		keyStatus := "success"
+		if c.Writer.Status() >= 400 || reqError != nil {
+			keyStatus = "error"
+		}
+		observability.GetMetricsRecorder().RecordKeyRequest(apiKey, keyLabelValue, keyStatus)
+
 		// Log to audit
-		audit.GetAuditLogger().LogResponse(
+		audit.GetAuditLogger().LogResponseWithMetadata(
 			provider,
 			model,
 			authID,
@@ -121,6 +152,7 @@ func AuditMiddleware() gin.HandlerFunc {
 			streaming,
 			cached,
 			reqError,
+			metadata,
 		)
 	}
 }
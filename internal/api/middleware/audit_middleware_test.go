@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/audit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
+)
+
+func TestAuditMiddleware_RecordsKeyLabel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	audit.GetAuditLogger().Clear()
+	t.Cleanup(audit.GetAuditLogger().Clear)
+
+	const apiKey = "sk-labeled-0123456789abcdef"
+	labels := map[string]string{apiKey: "team-search"}
+
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		c.Set("apiKey", c.GetHeader("X-Test-Key"))
+		c.Next()
+	})
+	engine.Use(AuditMiddleware(func(key string) string { return labels[key] }))
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+
+	for _, key := range []string{apiKey, "sk-unlabeled-0123456789"} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-4o"}`))
+		req.Header.Set("X-Test-Key", key)
+		engine.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	entries := audit.GetAuditLogger().GetEntries(audit.AuditFilter{})
+	if len(entries) != 2 {
+		t.Fatalf("expected two audit entries, got %d", len(entries))
+	}
+	// Entries are returned newest first.
+	if got := entries[1].AuthLabel; got != "team-search" {
+		t.Fatalf("labeled key audit label = %q, want %q", got, "team-search")
+	}
+	if got := entries[0].AuthLabel; got != "" {
+		t.Fatalf("unlabeled key audit label = %q, want empty", got)
+	}
+
+	export := observability.GetMetrics().Export()
+	want := `api_key_requests_total{api_key="` + observability.HashAPIKey(apiKey) + `",key_label="team-search",status="success"}`
+	if !strings.Contains(export, want) {
+		t.Fatalf("metrics export missing %s", want)
+	}
+	if strings.Contains(export, apiKey) {
+		t.Fatal("metrics export leaks the raw API key")
+	}
+}
@@ -0,0 +1,130 @@
+// Package middleware provides HTTP middleware components for the API server.
+// This file enforces the configured fail-open/fail-closed policy for dependency outages.
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// DependencyRedis names the Redis cache dependency.
+	DependencyRedis = "redis"
+	// DependencyMetricsDB names the metrics database dependency.
+	DependencyMetricsDB = "metrics-db"
+
+	// dependencyProbeInterval is how long a health result is reused before probing again,
+	// so a burst of requests does not ping the dependency once per request.
+	dependencyProbeInterval = 2 * time.Second
+	dependencyProbeTimeout  = time.Second
+)
+
+// DependencyCheck reports whether a dependency is reachable. It should return nil when the
+// dependency is not enabled.
+type DependencyCheck func(ctx context.Context) error
+
+// DependencyGuard applies the DependencyPolicyConfig to API requests. Fail-closed
+// dependencies are probed and requests are rejected with 503 while they are down;
+// fail-open dependencies are never probed here and their features degrade silently.
+type DependencyGuard struct {
+	policy func() config.DependencyPolicyConfig
+	checks map[string]DependencyCheck
+
+	mu     sync.Mutex
+	probes map[string]dependencyProbe
+	// inflight lets concurrent requests share one probe instead of queueing behind it.
+	inflight singleflight.Group
+}
+
+type dependencyProbe struct {
+	err       error
+	checkedAt time.Time
+}
+
+// NewDependencyGuard returns a guard reading the current policy from policy on every request.
+func NewDependencyGuard(policy func() config.DependencyPolicyConfig, checks map[string]DependencyCheck) *DependencyGuard {
+	return &DependencyGuard{
+		policy: policy,
+		checks: checks,
+		probes: make(map[string]dependencyProbe, len(checks)),
+	}
+}
+
+// Middleware rejects the request with 503 when a fail-closed dependency is down.
+func (g *DependencyGuard) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if name, err := g.firstFailedClosed(); err != nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error": gin.H{
+					"message": "required dependency " + name + " is unavailable: " + err.Error(),
+					"type":    "server_error",
+					"code":    "dependency_unavailable",
+				},
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+func (g *DependencyGuard) firstFailedClosed() (string, error) {
+	policy := g.policy()
+	for _, dep := range []struct{ name, mode string }{
+		{DependencyRedis, policy.Redis},
+		{DependencyMetricsDB, policy.MetricsDB},
+	} {
+		if !strings.EqualFold(strings.TrimSpace(dep.mode), config.DependencyFailClosed) {
+			continue
+		}
+		if err := g.probe(dep.name); err != nil {
+			return dep.name, err
+		}
+	}
+	return "", nil
+}
+
+// probe returns the dependency's health, re-checking at most once per dependencyProbeInterval.
+// The check runs detached from any request, so a client disconnecting mid-probe is never
+// recorded as an outage, and outside the lock, so one slow probe does not block requests
+// that still have a fresh result.
+func (g *DependencyGuard) probe(name string) error {
+	check, ok := g.checks[name]
+	if !ok {
+		return nil
+	}
+
+	g.mu.Lock()
+	last, seen := g.probes[name]
+	g.mu.Unlock()
+	if seen && time.Since(last.checkedAt) < dependencyProbeInterval {
+		return last.err
+	}
+
+	_, err, _ := g.inflight.Do(name, func() (any, error) {
+		probeCtx, cancel := context.WithTimeout(context.Background(), dependencyProbeTimeout)
+		defer cancel()
+		err := check(probeCtx)
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		last, seen := g.probes[name]
+		if seen && (err == nil) != (last.err == nil) {
+			if err != nil {
+				log.Warnf("dependency %s is down, rejecting requests (fail-closed): %v", name, err)
+			} else {
+				log.Infof("dependency %s recovered", name)
+			}
+		}
+		g.probes[name] = dependencyProbe{err: err, checkedAt: time.Now()}
+		return nil, err
+	})
+	return err
+}
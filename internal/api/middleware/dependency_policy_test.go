@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// downRedisClient simulates a Redis server that refuses every connection.
+type downRedisClient struct{}
+
+var errRedisDown = errors.New("dial tcp 127.0.0.1:6379: connect: connection refused")
+
+func (downRedisClient) Get(context.Context, string) ([]byte, error) { return nil, errRedisDown }
+func (downRedisClient) Set(context.Context, string, []byte, time.Duration) error {
+	return errRedisDown
+}
+func (downRedisClient) Delete(context.Context, string) error         { return errRedisDown }
+func (downRedisClient) Exists(context.Context, string) (bool, error) { return false, errRedisDown }
+func (downRedisClient) TTL(context.Context, string) (time.Duration, error) {
+	return 0, errRedisDown
+}
+func (downRedisClient) Keys(context.Context, string) ([]string, error) { return nil, errRedisDown }
+func (downRedisClient) Scan(context.Context, string, int64, func([]string) error) error {
+	return errRedisDown
+}
+func (downRedisClient) Unlink(context.Context, ...string) error { return errRedisDown }
+func (downRedisClient) ZAdd(context.Context, string, float64, string) error {
+	return errRedisDown
+}
+func (downRedisClient) ZRevRange(context.Context, string, int64, int64) ([]string, error) {
+	return nil, errRedisDown
+}
+func (downRedisClient) ZRemRangeByRank(context.Context, string, int64, int64) error {
+	return errRedisDown
+}
+func (downRedisClient) Ping(context.Context) error { return errRedisDown }
+func (downRedisClient) Close() error               { return nil }
+
+func serveWithRedisPolicy(t *testing.T, policy string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	redis := cache.NewRedisCache(downRedisClient{}, cache.RedisCacheConfig{DialTimeoutMs: 100})
+	guard := NewDependencyGuard(
+		func() config.DependencyPolicyConfig { return config.DependencyPolicyConfig{Redis: policy} },
+		map[string]DependencyCheck{DependencyRedis: func(context.Context) error { return redis.Ping() }},
+	)
+
+	engine := gin.New()
+	engine.Use(guard.Middleware())
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		// A down Redis only makes cache lookups miss.
+		if _, hit := redis.Get("m", "k"); hit {
+			t.Error("unexpected cache hit from a down Redis")
+		}
+		c.String(http.StatusOK, "served")
+	})
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil))
+	return rec
+}
+
+func TestDependencyGuard_DownRedisFailOpenServes(t *testing.T) {
+	for _, policy := range []string{"", config.DependencyFailOpen} {
+		rec := serveWithRedisPolicy(t, policy)
+		if rec.Code != http.StatusOK || rec.Body.String() != "served" {
+			t.Fatalf("policy %q: status = %d body = %q, want the request served", policy, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestDependencyGuard_DownRedisFailClosedRejects(t *testing.T) {
+	rec := serveWithRedisPolicy(t, config.DependencyFailClosed)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "dependency_unavailable") || !strings.Contains(body, "redis") {
+		t.Fatalf("unexpected error body: %s", body)
+	}
+}
+
+func TestDependencyGuard_ReusesProbeWithinInterval(t *testing.T) {
+	calls := 0
+	guard := NewDependencyGuard(
+		func() config.DependencyPolicyConfig {
+			return config.DependencyPolicyConfig{MetricsDB: config.DependencyFailClosed}
+		},
+		map[string]DependencyCheck{DependencyMetricsDB: func(context.Context) error { calls++; return nil }},
+	)
+	for i := 0; i < 5; i++ {
+		if name, err := guard.firstFailedClosed(); err != nil {
+			t.Fatalf("%s reported down: %v", name, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("probed %d times, want 1", calls)
+	}
+}
+
+func TestDependencyGuard_ClientDisconnectIsNotAnOutage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	guard := NewDependencyGuard(
+		func() config.DependencyPolicyConfig {
+			return config.DependencyPolicyConfig{MetricsDB: config.DependencyFailClosed}
+		},
+		map[string]DependencyCheck{DependencyMetricsDB: func(ctx context.Context) error { return ctx.Err() }},
+	)
+	engine := gin.New()
+	engine.Use(guard.Middleware())
+	engine.POST("/v1/chat/completions", func(c *gin.Context) { c.String(http.StatusOK, "served") })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	gone := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil).WithContext(ctx)
+	engine.ServeHTTP(httptest.NewRecorder(), gone)
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status after a disconnected client = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Response headers describing how the proxy served a request.
+const (
+	ProxyProviderHeader  = "X-Proxy-Provider"
+	ProxyModelHeader     = "X-Proxy-Model"
+	ProxyCacheHeader     = "X-Proxy-Cache"
+	ProxyLatencyMsHeader = "X-Proxy-Latency-Ms"
+)
+
+// ProxyHeadersMiddleware adds the X-Proxy-* headers to responses while enabled reports true.
+// The provider and resolved model come from the audit_provider and audit_model context values
+// set by the handlers, the cache status mirrors X-Cache (BYPASS when the response cache was
+// not consulted), and the latency is measured until the headers are sent. Headers are added
+// when the response is committed, so streaming responses carry them before the first flush.
+func ProxyHeadersMiddleware(enabled func() bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if enabled == nil || !enabled() {
+			c.Next()
+			return
+		}
+		c.Writer = &proxyHeadersWriter{ResponseWriter: c.Writer, ctx: c, start: time.Now()}
+		c.Next()
+	}
+}
+
+// proxyHeadersWriter adds the X-Proxy-* headers just before the response is committed.
+type proxyHeadersWriter struct {
+	gin.ResponseWriter
+	ctx       *gin.Context
+	start     time.Time
+	committed bool
+}
+
+func (w *proxyHeadersWriter) commit() {
+	if w.committed || w.ResponseWriter.Written() {
+		return
+	}
+	w.committed = true
+
+	header := w.Header()
+	if provider := getStringFromContext(w.ctx, "audit_provider"); provider != "" {
+		header.Set(ProxyProviderHeader, provider)
+	}
+	if model := getStringFromContext(w.ctx, "audit_model"); model != "" {
+		header.Set(ProxyModelHeader, model)
+	}
+	cacheStatus := header.Get("X-Cache")
+	if cacheStatus == "" {
+		cacheStatus = "BYPASS"
+	}
+	header.Set(ProxyCacheHeader, cacheStatus)
+	header.Set(ProxyLatencyMsHeader, strconv.FormatInt(time.Since(w.start).Milliseconds(), 10))
+}
+
+func (w *proxyHeadersWriter) WriteHeaderNow() {
+	w.commit()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *proxyHeadersWriter) Write(data []byte) (int, error) {
+	w.commit()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *proxyHeadersWriter) WriteString(s string) (int, error) {
+	w.commit()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *proxyHeadersWriter) Flush() {
+	w.commit()
+	w.ResponseWriter.Flush()
+}
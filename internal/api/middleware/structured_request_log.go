@@ -0,0 +1,106 @@
+// Package middleware provides HTTP middleware components for the API server.
+// This file emits one structured, redacted log entry per sampled API request.
+package middleware
+
+import (
+	"math/rand"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// RequestLogSettings controls StructuredRequestLogMiddleware.
+type RequestLogSettings struct {
+	// Enabled turns structured request logging on.
+	Enabled bool
+	// SampleRate is the fraction (0-1] of requests logged; values outside the range log every request.
+	SampleRate float64
+}
+
+var (
+	// requestLogLogger receives the structured entries; replaced in tests.
+	requestLogLogger = log.StandardLogger()
+	// requestLogSample returns a value in [0, 1) compared against SampleRate; replaced in tests.
+	requestLogSample = rand.Float64
+
+	// secretPattern matches credentials that can leak into error messages: bearer tokens,
+	// provider key prefixes and key=value style assignments. Group 1 is kept, the rest redacted.
+	secretPattern = regexp.MustCompile(`(?i)(bearer\s+|sk-(?:ant-)?|aiza|(?:api[_-]?key|access[_-]?token|secret|password)["']?\s*[:=]\s*["']?)[a-z0-9._~+/\-]{6,}`)
+)
+
+// StructuredRequestLogMiddleware logs method, path, model, status, latency and token usage
+// as structured fields for each sampled request while settings reports it as enabled. The
+// query string, API key and error text are redacted. Model and token counts are read from
+// the audit_* context keys, so the middleware must run outside AuditMiddleware.
+func StructuredRequestLogMiddleware(settings func() RequestLogSettings) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		current := settings()
+		if !current.Enabled || !sampled(current.SampleRate) {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		inputTokens := getInt64FromContext(c, "audit_input_tokens")
+		outputTokens := getInt64FromContext(c, "audit_output_tokens")
+		path := c.Request.URL.Path
+		if query := util.MaskSensitiveQuery(c.Request.URL.RawQuery); query != "" {
+			path += "?" + query
+		}
+		fields := log.Fields{
+			"request_id":    logging.GetGinRequestID(c),
+			"method":        c.Request.Method,
+			"path":          path,
+			"model":         getStringFromContext(c, "audit_model"),
+			"status":        c.Writer.Status(),
+			"latency_ms":    time.Since(start).Milliseconds(),
+			"input_tokens":  inputTokens,
+			"output_tokens": outputTokens,
+			"total_tokens":  inputTokens + outputTokens,
+		}
+		if key := getStringFromContext(c, "apiKey"); key != "" {
+			fields["api_key"] = util.HideAPIKey(key)
+		}
+		if len(c.Errors) > 0 {
+			fields["error"] = RedactSecrets(c.Errors.Last().Error())
+		}
+
+		entry := requestLogLogger.WithFields(fields)
+		switch status := c.Writer.Status(); {
+		case status >= http.StatusInternalServerError:
+			entry.Error("api request")
+		case status >= http.StatusBadRequest:
+			entry.Warn("api request")
+		default:
+			entry.Info("api request")
+		}
+	}
+}
+
+// RedactSecrets replaces credential-looking substrings of s with [REDACTED].
+func RedactSecrets(s string) string {
+	return secretPattern.ReplaceAllString(s, "${1}[REDACTED]")
+}
+
+func sampled(rate float64) bool {
+	if rate <= 0 || rate >= 1 {
+		return true
+	}
+	return requestLogSample() < rate
+}
+
+func getInt64FromContext(c *gin.Context, key string) int64 {
+	if val, exists := c.Get(key); exists {
+		if n, ok := val.(int64); ok {
+			return n
+		}
+	}
+	return 0
+}
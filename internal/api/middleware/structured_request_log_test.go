@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func newRequestLogEngine(t *testing.T, settings RequestLogSettings) *test.Hook {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	logger, hook := test.NewNullLogger()
+	prevLogger := requestLogLogger
+	requestLogLogger = logger
+	t.Cleanup(func() { requestLogLogger = prevLogger })
+
+	engine := gin.New()
+	engine.Use(StructuredRequestLogMiddleware(func() RequestLogSettings { return settings }))
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		c.Set("apiKey", "sk-client-0123456789abcdef")
+		c.Set("audit_model", "gpt-4o")
+		c.Set("audit_input_tokens", int64(12))
+		c.Set("audit_output_tokens", int64(30))
+		_ = c.Error(errors.New("upstream rejected Authorization: Bearer abcdef1234567890 with api_key=supersecretvalue"))
+		c.JSON(http.StatusBadGateway, gin.H{"ok": false})
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions?key=AIzaSyQueryKey123456&debug=1", nil)
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+	return hook
+}
+
+func TestStructuredRequestLog_LogsRedactedFields(t *testing.T) {
+	hook := newRequestLogEngine(t, RequestLogSettings{Enabled: true})
+
+	entries := hook.AllEntries()
+	if len(entries) != 1 {
+		t.Fatalf("expected one log entry, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.Level != logrus.ErrorLevel {
+		t.Fatalf("expected error level for 502, got %s", entry.Level)
+	}
+	for field, want := range map[string]any{
+		"method":        http.MethodPost,
+		"model":         "gpt-4o",
+		"status":        http.StatusBadGateway,
+		"input_tokens":  int64(12),
+		"output_tokens": int64(30),
+		"total_tokens":  int64(42),
+	} {
+		if got := entry.Data[field]; got != want {
+			t.Errorf("field %s = %v, want %v", field, got, want)
+		}
+	}
+	if _, ok := entry.Data["latency_ms"]; !ok {
+		t.Error("expected latency_ms field")
+	}
+
+	logged := ""
+	for _, v := range entry.Data {
+		if s, ok := v.(string); ok {
+			logged += s + "\n"
+		}
+	}
+	for _, secret := range []string{"AIzaSyQueryKey123456", "sk-client-0123456789abcdef", "abcdef1234567890", "supersecretvalue"} {
+		if strings.Contains(logged, secret) {
+			t.Errorf("secret %q leaked into log fields:\n%s", secret, logged)
+		}
+	}
+	if path, _ := entry.Data["path"].(string); !strings.HasPrefix(path, "/v1/chat/completions?") || !strings.Contains(path, "debug=1") {
+		t.Errorf("unexpected path field %q", path)
+	}
+}
+
+func TestStructuredRequestLog_DisabledIsNoop(t *testing.T) {
+	hook := newRequestLogEngine(t, RequestLogSettings{Enabled: false, SampleRate: 1})
+	if n := len(hook.AllEntries()); n != 0 {
+		t.Fatalf("expected no log entries when disabled, got %d", n)
+	}
+}
+
+func TestStructuredRequestLog_Sampling(t *testing.T) {
+	prevSample := requestLogSample
+	t.Cleanup(func() { requestLogSample = prevSample })
+
+	requestLogSample = func() float64 { return 0.75 }
+	if n := len(newRequestLogEngine(t, RequestLogSettings{Enabled: true, SampleRate: 0.5}).AllEntries()); n != 0 {
+		t.Fatalf("expected request outside the sample to be skipped, got %d entries", n)
+	}
+
+	requestLogSample = func() float64 { return 0.25 }
+	if n := len(newRequestLogEngine(t, RequestLogSettings{Enabled: true, SampleRate: 0.5}).AllEntries()); n != 1 {
+		t.Fatalf("expected sampled request to be logged, got %d entries", n)
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	cases := map[string]string{
+		"Bearer abcdefghijkl":          "Bearer [REDACTED]",
+		"key sk-ant-api03-abcdefgh":    "key sk-ant-[REDACTED]",
+		`{"api_key": "0123456789ab"}`:  `{"api_key": "[REDACTED]"}`,
+		"plain message without tokens": "plain message without tokens",
+	}
+	for input, want := range cases {
+		if got := RedactSecrets(input); got != want {
+			t.Errorf("RedactSecrets(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
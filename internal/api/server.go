@@ -19,10 +19,12 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/access"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/alerting"
 	managementHandlers "github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/management"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/middleware"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules"
 	ampmodule "github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules/amp"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/managementasset"
@@ -30,6 +32,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/agent"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/claude"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/gemini"
@@ -37,6 +40,7 @@ import (
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	sdkusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 )
@@ -258,6 +262,7 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	auth.SetQuotaCooldownDisabled(cfg.DisableCooling)
 	// Initialize management handler
 	s.mgmt = managementHandlers.NewHandler(cfg, configFilePath, authManager)
+	s.mgmt.SetAccessManager(accessManager)
 	if optionState.localPassword != "" {
 		s.mgmt.SetLocalPassword(optionState.localPassword)
 	}
@@ -269,8 +274,9 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	s.localPassword = optionState.localPassword
 
 	// Register metrics hook for real-time TPS and latency tracking
-	// Feeds data to both RealTimeTracker (for dashboard) and PrometheusMetrics (for /metrics endpoint)
-	useOfficialPrometheus := cfg.Observability.Metrics.UseOfficialClient
+	// Feeds data to both RealTimeTracker (for dashboard) and the metrics recorder (for /metrics endpoint)
+	observability.InitMetricsRecorder(observability.MetricsConfig{UseOfficialClient: cfg.Observability.Metrics.UseOfficialClient})
+	setTranslationValidation(cfg.ValidateTranslations)
 	sdkusage.SetMetricsHook(func(model string, tokens int64, latencyMs int64, success bool) {
 		// Feed to RealTimeTracker for dashboard WebSocket/API
 		tracker := managementHandlers.GetRealTimeTracker()
@@ -278,20 +284,20 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 			tracker.Record(model, tokens, latencyMs, success)
 		}
 
-		// Feed to PrometheusMetrics for /metrics endpoint (if official client enabled)
-		if useOfficialPrometheus {
-			promMetrics := observability.GetPrometheusMetrics()
-			if promMetrics != nil {
-				status := "success"
-				if !success {
-					status = "error"
-				}
-				// Convert latency from ms to seconds for Prometheus histogram
-				promMetrics.RecordRequest(model, "proxy", status, float64(latencyMs)/1000.0, tokens)
-			}
+		// Feed alert rule evaluation (no-op when alerting is disabled)
+		alerting.GetManager().Observe(float64(latencyMs), success)
+
+		// Feed the collector serving the /metrics endpoint
+		status := "success"
+		if !success {
+			status = "error"
 		}
+		observability.GetMetricsRecorder().RecordRequest(model, "proxy", status, time.Duration(latencyMs)*time.Millisecond, tokens)
 	})
 
+	applyProviderHealthPolicy(cfg)
+	applyAgentToolConfig(cfg)
+
 	// Setup routes
 	s.setupRoutes()
 
@@ -345,15 +351,22 @@ func (s *Server) setupRoutes() {
 	// WebSocket endpoint for real-time metrics
 	s.engine.GET("/ws/metrics", s.serveMetricsWebSocket)
 
+	// Liveness probe. Unlike /health, which is only served with metrics enabled and reports
+	// 503 while providers are unhealthy, it answers whenever the server is up.
+	s.engine.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
 	// Prometheus metrics endpoint (if enabled in config)
 	if s.cfg.Observability.Metrics.Enabled {
 		obsCfg := observability.ObservabilityConfig{
 			Metrics: observability.MetricsConfig{
-				Enabled:          s.cfg.Observability.Metrics.Enabled,
-				Path:             s.cfg.Observability.Metrics.Path,
-				Namespace:        s.cfg.Observability.Metrics.Namespace,
-				Subsystem:        s.cfg.Observability.Metrics.Subsystem,
-				HistogramBuckets: s.cfg.Observability.Metrics.HistogramBuckets,
+				Enabled:           s.cfg.Observability.Metrics.Enabled,
+				Path:              s.cfg.Observability.Metrics.Path,
+				Namespace:         s.cfg.Observability.Metrics.Namespace,
+				Subsystem:         s.cfg.Observability.Metrics.Subsystem,
+				HistogramBuckets:  s.cfg.Observability.Metrics.HistogramBuckets,
+				UseOfficialClient: s.cfg.Observability.Metrics.UseOfficialClient,
 			},
 		}
 		useOfficial := s.cfg.Observability.Metrics.UseOfficialClient
@@ -371,14 +384,52 @@ func (s *Server) setupRoutes() {
 	claudeCodeHandlers := claude.NewClaudeCodeAPIHandler(s.handlers)
 	openaiResponsesHandlers := openai.NewOpenAIResponsesAPIHandler(s.handlers)
 
+	// API requests are rejected while a dependency configured as fail-closed is down.
+	dependencyGuard := middleware.NewDependencyGuard(
+		func() config.DependencyPolicyConfig { return s.cfg.DependencyPolicy },
+		map[string]middleware.DependencyCheck{
+			middleware.DependencyRedis: func(context.Context) error { return cache.GetCacheSystem().RedisHealth() },
+			middleware.DependencyMetricsDB: func(ctx context.Context) error {
+				if !s.cfg.MetricsDB.Enabled {
+					return nil
+				}
+				return usage.GetMetricsDB().Ping(ctx)
+			},
+		},
+	)
+
+	// Structured per-request log lines, sampled and redacted, while request-log is on.
+	requestLog := middleware.StructuredRequestLogMiddleware(func() middleware.RequestLogSettings {
+		return middleware.RequestLogSettings{
+			Enabled:    s.cfg.RequestLog,
+			SampleRate: s.cfg.RequestLogSamplePercent / 100,
+		}
+	})
+
+	// Audit entries and per-key metrics carry the client key's configured label.
+	keyLabel := func(apiKey string) string { return s.cfg.KeyLabel(apiKey) }
+
+	// X-Proxy-* response headers describe the serving path while proxy-headers is on.
+	proxyHeaders := middleware.ProxyHeadersMiddleware(func() bool { return s.cfg.ProxyHeaders })
+
+	// One line per request tracing how it was served while access-log is on.
+	accessLog := middleware.AccessLogMiddleware(func() bool { return s.cfg.AccessLog })
+
 	// OpenAI compatible API routes
 	v1 := s.engine.Group("/v1")
 	v1.Use(AuthMiddleware(s.accessManager))
-	v1.Use(middleware.AuditMiddleware())
+	v1.Use(dependencyGuard.Middleware())
+	v1.Use(requestLog)
+	v1.Use(accessLog)
+	v1.Use(middleware.AuditMiddleware(keyLabel))
+	v1.Use(proxyHeaders)
 	{
 		v1.GET("/models", s.unifiedModelsHandler(openaiHandlers, claudeCodeHandlers))
 		v1.POST("/chat/completions", openaiHandlers.ChatCompletions)
+		v1.POST("/batch", openaiHandlers.Batch)
 		v1.POST("/completions", openaiHandlers.Completions)
+		v1.POST("/embeddings", openaiHandlers.Embeddings)
+		v1.POST("/cache/prime", openaiHandlers.CachePrime)
 		v1.POST("/messages", claudeCodeHandlers.ClaudeMessages)
 		v1.POST("/messages/count_tokens", claudeCodeHandlers.ClaudeCountTokens)
 		v1.POST("/responses", openaiResponsesHandlers.Responses)
@@ -387,7 +438,11 @@ func (s *Server) setupRoutes() {
 	// Gemini compatible API routes
 	v1beta := s.engine.Group("/v1beta")
 	v1beta.Use(AuthMiddleware(s.accessManager))
-	v1beta.Use(middleware.AuditMiddleware())
+	v1beta.Use(dependencyGuard.Middleware())
+	v1beta.Use(requestLog)
+	v1beta.Use(accessLog)
+	v1beta.Use(middleware.AuditMiddleware(keyLabel))
+	v1beta.Use(proxyHeaders)
 	{
 		v1beta.GET("/models", geminiHandlers.GeminiModels)
 		v1beta.POST("/models/*action", geminiHandlers.GeminiHandler)
@@ -542,6 +597,10 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.GET("/metrics/tpm", s.mgmt.GetTPMMetrics)
 		mgmt.GET("/metrics/tph", s.mgmt.GetTPHMetrics)
 		mgmt.GET("/metrics/tpd", s.mgmt.GetTPDMetrics)
+		mgmt.GET("/slo", s.mgmt.GetSLO)
+		mgmt.GET("/metrics/top", s.mgmt.GetTopModels)
+		mgmt.POST("/metrics/reset", s.mgmt.ResetMetrics)
+		mgmt.POST("/cache/warmup", s.mgmt.WarmupCache)
 		mgmt.GET("/config", s.mgmt.GetConfig)
 		mgmt.GET("/config.yaml", s.mgmt.GetConfigYAML)
 		mgmt.PUT("/config.yaml", s.mgmt.PutConfigYAML)
@@ -550,6 +609,7 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.GET("/debug", s.mgmt.GetDebug)
 		mgmt.PUT("/debug", s.mgmt.PutDebug)
 		mgmt.PATCH("/debug", s.mgmt.PutDebug)
+		mgmt.GET("/debug/fanout", s.mgmt.GetFanoutStreams)
 
 		mgmt.GET("/logging-to-file", s.mgmt.GetLoggingToFile)
 		mgmt.PUT("/logging-to-file", s.mgmt.PutLoggingToFile)
@@ -582,6 +642,7 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.PUT("/api-keys", s.mgmt.PutAPIKeys)
 		mgmt.PATCH("/api-keys", s.mgmt.PatchAPIKeys)
 		mgmt.DELETE("/api-keys", s.mgmt.DeleteAPIKeys)
+		mgmt.POST("/api-keys/rotate", s.mgmt.RotateAPIKeys)
 
 		mgmt.GET("/gemini-api-key", s.mgmt.GetGeminiKeys)
 		mgmt.PUT("/gemini-api-key", s.mgmt.PutGeminiKeys)
@@ -700,6 +761,45 @@ func (s *Server) registerManagementRoutes() {
 	}
 }
 
+// applyProviderHealthPolicy configures provider health warmup and thresholds on the
+// global metrics collector. Unset values keep their defaults.
+func applyProviderHealthPolicy(cfg *config.Config) {
+	if cfg == nil {
+		return
+	}
+	health := cfg.Observability.Metrics.ProviderHealth
+	policy := observability.ProviderHealthPolicy{
+		TripErrorRate:    health.TripErrorRate / 100,
+		RecoverErrorRate: health.RecoverErrorRate / 100,
+		WindowSize:       health.WindowSize,
+		WindowSeconds:    health.WindowSeconds,
+	}
+	if health.MinSamples > 0 {
+		policy.MinSamples = uint64(health.MinSamples)
+	}
+	observability.GetMetrics().SetProviderHealthPolicy(policy)
+}
+
+// applyAgentToolConfig applies the server-wide agentic tool settings: the global tool
+// concurrency limit and the built-in http_request tool.
+func applyAgentToolConfig(cfg *config.Config) {
+	if cfg == nil {
+		return
+	}
+	agent.SetGlobalMaxConcurrency(cfg.Agent.MaxConcurrency)
+	toolCfg := cfg.Agent.HTTPTool
+	err := agent.ConfigureHTTPRequestTool(cfg.Agent.AutoExecuteTools && toolCfg.Enabled, agent.HTTPToolConfig{
+		AllowedHosts:     toolCfg.AllowedHosts,
+		AllowedSchemes:   toolCfg.AllowedSchemes,
+		AllowedCIDRs:     toolCfg.AllowedCIDRs,
+		MaxResponseBytes: toolCfg.MaxResponseBytes,
+		Timeout:          time.Duration(toolCfg.TimeoutMs) * time.Millisecond,
+	})
+	if err != nil {
+		log.Warnf("agentic: http_request tool disabled: %v", err)
+	}
+}
+
 func (s *Server) managementAvailabilityMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if !s.managementRoutesEnabled.Load() {
@@ -952,6 +1052,19 @@ func (s *Server) applyAccessConfig(oldCfg, newCfg *config.Config) {
 	}
 }
 
+// setTranslationValidation turns validation of translated responses on or off. Responses
+// that fail it are logged and counted in translation_errors_total but still served.
+func setTranslationValidation(enabled bool) {
+	if !enabled {
+		sdktranslator.SetInvalidResponseHandler(nil)
+		return
+	}
+	sdktranslator.SetInvalidResponseHandler(func(_ context.Context, from, to sdktranslator.Format, err error) {
+		log.Warnf("translated %s response from %s failed validation: %v", to, from, err)
+		observability.GetMetricsRecorder().RecordTranslationError(from.String(), to.String())
+	})
+}
+
 // UpdateClients updates the server's client list and configuration.
 // This method is called when the configuration or authentication tokens change.
 //
@@ -1017,6 +1130,14 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 			log.Debugf("disable_cooling toggled to %t", cfg.DisableCooling)
 		}
 	}
+	if oldCfg == nil || oldCfg.ValidateTranslations != cfg.ValidateTranslations {
+		setTranslationValidation(cfg.ValidateTranslations)
+		if oldCfg != nil {
+			log.Debugf("validate_translations updated from %t to %t", oldCfg.ValidateTranslations, cfg.ValidateTranslations)
+		} else {
+			log.Debugf("validate_translations toggled to %t", cfg.ValidateTranslations)
+		}
+	}
 	if s.handlers != nil && s.handlers.AuthManager != nil {
 		s.handlers.AuthManager.SetRetryConfig(cfg.RequestRetry, time.Duration(cfg.MaxRetryInterval)*time.Second)
 	}
@@ -1064,6 +1185,8 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 	}
 
 	s.applyAccessConfig(oldCfg, cfg)
+	applyProviderHealthPolicy(cfg)
+	applyAgentToolConfig(cfg)
 	s.cfg = cfg
 	s.wsAuthEnabled.Store(cfg.WebsocketAuth)
 	if oldCfg != nil && s.wsAuthChanged != nil && oldCfg.WebsocketAuth != cfg.WebsocketAuth {
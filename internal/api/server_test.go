@@ -1,15 +1,19 @@
 package api
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	gin "github.com/gin-gonic/gin"
 	proxyconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/scheduler"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
@@ -17,6 +21,12 @@ import (
 
 func newTestServer(t *testing.T) *Server {
 	t.Helper()
+	return newTestServerWith(t, nil)
+}
+
+// newTestServerWith builds a test server after applying configure to its config.
+func newTestServerWith(t *testing.T, configure func(*proxyconfig.Config)) *Server {
+	t.Helper()
 
 	gin.SetMode(gin.TestMode)
 
@@ -37,6 +47,10 @@ func newTestServer(t *testing.T) *Server {
 		UsageStatisticsEnabled: false,
 	}
 
+	if configure != nil {
+		configure(cfg)
+	}
+
 	authManager := auth.NewManager(nil, nil, nil)
 	accessManager := sdkaccess.NewManager()
 
@@ -109,3 +123,49 @@ func TestAmpProviderModelRoutes(t *testing.T) {
 		})
 	}
 }
+
+func TestOperationalEndpointsRespondWhileSaturated(t *testing.T) {
+	ac := scheduler.InitAdmissionController(scheduler.AdmissionConfig{MaxInFlight: 1})
+	t.Cleanup(func() { scheduler.InitAdmissionController(scheduler.AdmissionConfig{}) })
+	hold, err := ac.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	t.Cleanup(hold)
+	registry.GetGlobalRegistry().RegisterClient("saturated-auth", "codex", []*registry.ModelInfo{{ID: "saturated-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient("saturated-auth") })
+
+	server := newTestServerWith(t, func(cfg *proxyconfig.Config) {
+		cfg.Observability.Metrics.Enabled = true
+	})
+	serve := func(req *http.Request) int {
+		done := make(chan int, 1)
+		go func() {
+			rec := httptest.NewRecorder()
+			server.engine.ServeHTTP(rec, req)
+			done <- rec.Code
+		}()
+		select {
+		case code := <-done:
+			return code
+		case <-time.After(time.Second):
+			t.Fatalf("%s did not respond while the proxy was saturated", req.URL.Path)
+			return 0
+		}
+	}
+
+	// Model calls are shed at the admission limit.
+	chat := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"saturated-model","messages":[{"role":"user","content":"hi"}]}`))
+	chat.Header.Set("Authorization", "Bearer test-key")
+	chat.Header.Set("Content-Type", "application/json")
+	if code := serve(chat); code != http.StatusServiceUnavailable {
+		t.Fatalf("chat completion status = %d, want 503 while saturated", code)
+	}
+
+	// Operational endpoints never take an admission slot.
+	for _, path := range []string{"/healthz", "/health", "/metrics"} {
+		if code := serve(httptest.NewRequest(http.MethodGet, path, nil)); code != http.StatusOK {
+			t.Fatalf("%s status = %d, want 200", path, code)
+		}
+	}
+}
@@ -185,6 +185,16 @@ func (al *AuditLogger) LogResponse(
 	provider, model, authID, authLabel, endpoint, method string,
 	statusCode int, latency time.Duration, inputTokens, outputTokens int64,
 	streaming, cached bool, err error,
+) {
+	al.LogResponseWithMetadata(provider, model, authID, authLabel, endpoint, method,
+		statusCode, latency, inputTokens, outputTokens, streaming, cached, err, nil)
+}
+
+// LogResponseWithMetadata logs an API response with additional key/value metadata.
+func (al *AuditLogger) LogResponseWithMetadata(
+	provider, model, authID, authLabel, endpoint, method string,
+	statusCode int, latency time.Duration, inputTokens, outputTokens int64,
+	streaming, cached bool, err error, metadata map[string]string,
 ) {
 	if !al.IsEnabled() {
 		return
@@ -212,6 +222,12 @@ func (al *AuditLogger) LogResponse(
 		Streaming:    streaming,
 		Cached:       cached,
 	}
+	if len(metadata) > 0 {
+		entry.Metadata = make(map[string]string, len(metadata))
+		for k, v := range metadata {
+			entry.Metadata[k] = v
+		}
+	}
 
 	if err != nil {
 		entry.Error = err.Error()
@@ -4,6 +4,8 @@ package cache
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,6 +19,7 @@ type CacheSystem struct {
 	Streaming *StreamingCache
 	Redis     *RedisCache
 	Hybrid    *HybridCache
+	Negative  *NegativeCache
 
 	config    CacheSystemConfig
 	redisOK   bool
@@ -28,10 +31,18 @@ type CacheSystemConfig struct {
 	// LRU cache settings
 	LRUCapacity   int
 	LRUTTLSeconds int
+	// EvictionPolicy applies to the LRU, semantic and hybrid local caches
+	EvictionPolicy EvictionPolicy
+	// StaleGraceSeconds keeps expired LRU and hybrid local entries this long past their
+	// TTL for GetStale; 0 drops entries as soon as they expire
+	StaleGraceSeconds int
 
 	// Redis settings
 	RedisEnabled        bool
+	RedisMode           RedisMode
 	RedisAddress        string
+	RedisAddresses      []string
+	RedisMasterName     string
 	RedisPassword       string
 	RedisDatabase       int
 	RedisKeyPrefix      string
@@ -42,12 +53,20 @@ type CacheSystemConfig struct {
 	RedisWriteTimeoutMs int
 	RedisEnableTLS      bool
 	RedisMaxRetries     int
+	RedisScanCount      int
+	RedisCompressStreaming bool
+	RedisAccessIndexSize   int
+	// ModelConfigs sets per-model Redis TTLs; models may use * and ? wildcards
+	ModelConfigs []ModelCacheConfig
 
 	// Semantic cache settings
 	SemanticEnabled           bool
 	SemanticMaxEntries        int
 	SemanticTTLSeconds        int
 	SemanticSimilarityThreshold float64
+	SemanticSimilarityMethod    SimilarityMethod
+	SemanticTokenMode           TokenMode
+	SemanticSkipWhenDeterministic bool
 
 	// Streaming cache settings
 	StreamingEnabled        bool
@@ -57,6 +76,9 @@ type CacheSystemConfig struct {
 	StreamingMaxTotalSize   int64
 	StreamingPreserveTimings bool
 
+	// Negative cache settings; disabled when NegativeTTLSeconds is 0
+	NegativeTTLSeconds int
+
 	// Hybrid cache settings
 	HybridLocalCapacity   int
 	HybridLocalTTLSeconds int
@@ -64,11 +86,16 @@ type CacheSystemConfig struct {
 	HybridReadThrough     bool
 }
 
+// DefaultStaleGraceSeconds is the stale grace used when serving stale responses is enabled
+// without an explicit window.
+const DefaultStaleGraceSeconds = 300
+
 // DefaultCacheSystemConfig returns sensible defaults.
 func DefaultCacheSystemConfig() CacheSystemConfig {
 	return CacheSystemConfig{
-		LRUCapacity:   1000,
-		LRUTTLSeconds: 60,
+		LRUCapacity:    1000,
+		LRUTTLSeconds:  60,
+		EvictionPolicy: EvictionLRU,
 
 		RedisEnabled:        false,
 		RedisAddress:        "localhost:6379",
@@ -79,11 +106,13 @@ func DefaultCacheSystemConfig() CacheSystemConfig {
 		RedisReadTimeoutMs:  3000,
 		RedisWriteTimeoutMs: 3000,
 		RedisMaxRetries:     3,
+		RedisScanCount:      500,
 
 		SemanticEnabled:           false,
 		SemanticMaxEntries:        1000,
 		SemanticTTLSeconds:        60,
 		SemanticSimilarityThreshold: 0.85,
+		SemanticSkipWhenDeterministic: true,
 
 		StreamingEnabled:        true,
 		StreamingMaxEntries:     200,
@@ -126,8 +155,9 @@ func newCacheSystem(cfg CacheSystemConfig) *CacheSystem {
 	}
 
 	// Initialize LRU cache
-	cs.LRU = NewLRUCache(cfg.LRUCapacity, time.Duration(cfg.LRUTTLSeconds)*time.Second)
-	log.Infof("Cache: LRU cache initialized (capacity=%d, ttl=%ds)", cfg.LRUCapacity, cfg.LRUTTLSeconds)
+	cs.LRU = NewLRUCacheWithPolicy(cfg.LRUCapacity, time.Duration(cfg.LRUTTLSeconds)*time.Second, cfg.EvictionPolicy)
+	cs.LRU.SetStaleGrace(time.Duration(cfg.StaleGraceSeconds) * time.Second)
+	log.Infof("Cache: LRU cache initialized (capacity=%d, ttl=%ds, eviction=%s)", cfg.LRUCapacity, cfg.LRUTTLSeconds, cs.LRU.policy)
 
 	// Initialize Redis if enabled
 	if cfg.RedisEnabled {
@@ -143,9 +173,19 @@ func newCacheSystem(cfg CacheSystemConfig) *CacheSystem {
 			NGramSize:           3,
 			NormalizeCase:       true,
 			NormalizeWhitespace: true,
+			EvictionPolicy:      cfg.EvictionPolicy,
+			SimilarityMethod:    cfg.SemanticSimilarityMethod,
+			TokenMode:           cfg.SemanticTokenMode,
+			SkipWhenDeterministic: cfg.SemanticSkipWhenDeterministic,
 		})
-		log.Infof("Cache: Semantic cache initialized (max=%d, threshold=%.2f)", 
-			cfg.SemanticMaxEntries, cfg.SemanticSimilarityThreshold)
+		log.Infof("Cache: Semantic cache initialized (max=%d, threshold=%.2f, similarity=%s)", 
+			cfg.SemanticMaxEntries, cfg.SemanticSimilarityThreshold, cs.Semantic.config.SimilarityMethod)
+	}
+
+	// Initialize negative cache if enabled
+	if cfg.NegativeTTLSeconds > 0 {
+		cs.Negative = NewNegativeCache(cfg.LRUCapacity, time.Duration(cfg.NegativeTTLSeconds)*time.Second)
+		log.Infof("Cache: Negative cache initialized (ttl=%ds)", cfg.NegativeTTLSeconds)
 	}
 
 	// Initialize streaming cache if enabled
@@ -164,8 +204,16 @@ func newCacheSystem(cfg CacheSystemConfig) *CacheSystem {
 }
 
 func (cs *CacheSystem) initRedis(cfg CacheSystemConfig) {
+	if cfg.RedisMode == RedisModeSentinel && cfg.RedisMasterName == "" {
+		log.Warn("Cache: Redis sentinel mode requires master-name - running without Redis")
+		return
+	}
+
 	redisCfg := RedisCacheConfig{
+		Mode:              cfg.RedisMode,
 		Address:           cfg.RedisAddress,
+		Addresses:         cfg.RedisAddresses,
+		MasterName:        cfg.RedisMasterName,
 		Password:          cfg.RedisPassword,
 		Database:          cfg.RedisDatabase,
 		KeyPrefix:         cfg.RedisKeyPrefix,
@@ -176,6 +224,10 @@ func (cs *CacheSystem) initRedis(cfg CacheSystemConfig) {
 		ReadTimeoutMs:     cfg.RedisReadTimeoutMs,
 		WriteTimeoutMs:    cfg.RedisWriteTimeoutMs,
 		EnableTLS:         cfg.RedisEnableTLS,
+		ScanCount:         cfg.RedisScanCount,
+		CompressStreaming: cfg.RedisCompressStreaming,
+		MaxStreamingBytes: cfg.StreamingMaxTotalSize,
+		AccessIndexSize:   cfg.RedisAccessIndexSize,
 		Enabled:           true,
 	}
 
@@ -187,17 +239,18 @@ func (cs *CacheSystem) initRedis(cfg CacheSystemConfig) {
 	defer cancel()
 
 	if err := goRedisClient.Ping(ctx); err != nil {
-		log.Warnf("Cache: Redis connection failed (%s): %v - running without Redis", cfg.RedisAddress, err)
+		log.Warnf("Cache: Redis connection failed (%s): %v - running without Redis", redisEndpoint(cfg), err)
 		cs.redisOK = false
 		return
 	}
 
 	cs.Redis = NewRedisCache(goRedisClient, redisCfg)
+	cs.Redis.LoadModelTTLs(cfg.ModelConfigs)
 	cs.redisOK = true
 	SetGlobalRedisCache(cs.Redis)
 
 	log.Infof("Cache: Redis connected (%s, db=%d, prefix=%s)", 
-		cfg.RedisAddress, cfg.RedisDatabase, cfg.RedisKeyPrefix)
+		redisEndpoint(cfg), cfg.RedisDatabase, cfg.RedisKeyPrefix)
 
 	// Initialize hybrid cache if Redis is available
 	cs.Hybrid = NewHybridCache(cs.Redis, HybridCacheConfig{
@@ -205,10 +258,28 @@ func (cs *CacheSystem) initRedis(cfg CacheSystemConfig) {
 		LocalTTLSeconds: cfg.HybridLocalTTLSeconds,
 		WriteThrough:    cfg.HybridWriteThrough,
 		ReadThrough:     cfg.HybridReadThrough,
+		EvictionPolicy:  cfg.EvictionPolicy,
 	})
+	cs.Hybrid.local.SetStaleGrace(time.Duration(cfg.StaleGraceSeconds) * time.Second)
 	log.Info("Cache: Hybrid cache initialized (L1: LRU, L2: Redis)")
 }
 
+// redisEndpoint describes the configured Redis deployment for log messages.
+func redisEndpoint(cfg CacheSystemConfig) string {
+	addrs := cfg.RedisAddress
+	if len(cfg.RedisAddresses) > 0 {
+		addrs = strings.Join(cfg.RedisAddresses, ",")
+	}
+	switch cfg.RedisMode {
+	case RedisModeCluster:
+		return "cluster " + addrs
+	case RedisModeSentinel:
+		return "sentinel " + cfg.RedisMasterName + "@" + addrs
+	default:
+		return addrs
+	}
+}
+
 // IsRedisAvailable returns whether Redis is connected and available.
 func (cs *CacheSystem) IsRedisAvailable() bool {
 	cs.mu.RLock()
@@ -216,6 +287,24 @@ func (cs *CacheSystem) IsRedisAvailable() bool {
 	return cs.redisOK && cs.Redis != nil
 }
 
+// ErrRedisUnavailable is returned by RedisHealth when Redis is enabled but never connected.
+var ErrRedisUnavailable = errors.New("redis is not connected")
+
+// RedisHealth pings Redis and reports whether it is reachable. It returns nil when Redis is
+// not enabled, so callers only see errors for a configured dependency that is down.
+func (cs *CacheSystem) RedisHealth() error {
+	cs.mu.RLock()
+	enabled, redis := cs.config.RedisEnabled, cs.Redis
+	cs.mu.RUnlock()
+	if !enabled {
+		return nil
+	}
+	if redis == nil {
+		return ErrRedisUnavailable
+	}
+	return redis.Ping()
+}
+
 // Get retrieves from the best available cache.
 func (cs *CacheSystem) Get(model, key string) ([]byte, bool) {
 	// Try hybrid cache first if available
@@ -224,14 +313,27 @@ func (cs *CacheSystem) Get(model, key string) ([]byte, bool) {
 	}
 
 	// Fall back to LRU
+	start := time.Now()
 	cacheKey := HashKey(model, key)
 	if data := cs.LRU.Get(cacheKey); data != nil {
+		recordLookup(true, start)
 		return data, true
 	}
 
+	recordLookup(false, start)
 	return nil, false
 }
 
+// GetStale retrieves a value from the in-process cache even if it has expired, as long as
+// it is within the stale grace window. Redis is not consulted: it drops entries at their
+// TTL. stale reports whether the value is past its TTL.
+func (cs *CacheSystem) GetStale(model, key string) (value []byte, stale bool, ok bool) {
+	if cs.Hybrid != nil {
+		return cs.Hybrid.local.GetStale(HashKey(model, key))
+	}
+	return cs.LRU.GetStale(HashKey(model, key))
+}
+
 // Set stores in the best available cache.
 func (cs *CacheSystem) Set(model, key string, value []byte) {
 	// Use hybrid cache if available
@@ -245,6 +347,72 @@ func (cs *CacheSystem) Set(model, key string, value []byte) {
 	cs.LRU.Set(cacheKey, value)
 }
 
+// GetStreamingResponse retrieves the events of a cached streaming response, trying the
+// in-memory streaming cache before Redis. A Redis hit is copied into memory.
+func (cs *CacheSystem) GetStreamingResponse(key string) ([]StreamEvent, bool) {
+	if cs.Streaming == nil {
+		return nil, false
+	}
+	if events, ok := cs.Streaming.Get(key); ok {
+		return events, true
+	}
+	if !cs.IsRedisAvailable() {
+		return nil, false
+	}
+	events, ok := cs.Redis.GetStreamingResponse(key)
+	if !ok || len(events) == 0 {
+		return nil, false
+	}
+	var totalSize int64
+	for _, e := range events {
+		totalSize += int64(len(e.Data))
+	}
+	cs.Streaming.set(key, events, totalSize)
+	return events, true
+}
+
+// SetStreamingResponse caches the events of a streaming response in memory and, when
+// connected, in Redis with the model's TTL.
+func (cs *CacheSystem) SetStreamingResponse(model, key string, events []StreamEvent) {
+	if cs.Streaming == nil || len(events) == 0 {
+		return
+	}
+	var totalSize int64
+	for _, e := range events {
+		totalSize += int64(len(e.Data))
+	}
+	cs.Streaming.set(key, events, totalSize)
+	if cs.IsRedisAvailable() {
+		if err := cs.Redis.SetStreamingResponse(key, events, cs.Redis.GetTTL(model)); err != nil {
+			log.Debugf("Cache: streaming response not stored in Redis: %v", err)
+		}
+	}
+}
+
+// NewStreamRecorder returns a recorder, bound by the streaming cache size limits, whose
+// Commit stores the recorded stream with SetStreamingResponse. It returns nil while
+// streaming caching is disabled.
+func (cs *CacheSystem) NewStreamRecorder(model, key string) *StreamRecorder {
+	if cs.Streaming == nil {
+		return nil
+	}
+	cfg := cs.Streaming.config
+	return newStreamRecorder(key, cfg.MaxTotalSize, cfg.MaxEventSize, func(events []StreamEvent, _ int64) {
+		cs.SetStreamingResponse(model, key, events)
+	})
+}
+
+// ReplayStreamingResponse sends events returned by GetStreamingResponse through callback
+// in order, keeping their original spacing when PreserveTimings is configured. It stops
+// with the context error once ctx is done.
+func (cs *CacheSystem) ReplayStreamingResponse(ctx context.Context, events []StreamEvent, callback func(event StreamEvent) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	preserveTimings := cs.Streaming != nil && cs.Streaming.config.PreserveTimings
+	return replayEvents(ctx, events, preserveTimings, callback)
+}
+
 // Stats returns combined cache statistics.
 func (cs *CacheSystem) Stats() CacheSystemStats {
 	stats := CacheSystemStats{
@@ -272,9 +440,53 @@ func (cs *CacheSystem) Stats() CacheSystemStats {
 		stats.Hybrid = &hybridStats
 	}
 
+	if cs.Negative != nil {
+		negativeStats := cs.Negative.Stats()
+		stats.Negative = &negativeStats
+	}
+
 	return stats
 }
 
+// ResetStats resets hit/miss counters on every cache tier. Cached entries are kept.
+func (cs *CacheSystem) ResetStats() {
+	cs.LRU.ResetStats()
+	if cs.Redis != nil {
+		cs.Redis.ResetStats()
+	}
+	if cs.Semantic != nil {
+		cs.Semantic.ResetStats()
+	}
+	if cs.Streaming != nil {
+		cs.Streaming.ResetStats()
+	}
+	if cs.Hybrid != nil && cs.Hybrid.local != nil {
+		cs.Hybrid.local.ResetStats()
+	}
+	if cs.Negative != nil {
+		cs.Negative.ResetStats()
+	}
+}
+
+// ResetGlobalStats resets statistics on every global cache that has been initialized.
+func ResetGlobalStats() {
+	if globalCacheSystem != nil {
+		globalCacheSystem.ResetStats()
+	}
+	if globalSemanticCache != nil {
+		globalSemanticCache.ResetStats()
+	}
+	if globalStreamingCache != nil {
+		globalStreamingCache.ResetStats()
+	}
+	if globalResponseCache != nil {
+		globalResponseCache.ResetStats()
+	}
+	if redis := GetGlobalRedisCache(); redis != nil {
+		redis.ResetStats()
+	}
+}
+
 // Close closes all cache connections.
 func (cs *CacheSystem) Close() error {
 	if cs.Redis != nil {
@@ -291,4 +503,5 @@ type CacheSystemStats struct {
 	Semantic       *SemanticCacheStats `json:"semantic,omitempty"`
 	Streaming      *StreamingCacheStats `json:"streaming,omitempty"`
 	Hybrid         *HybridCacheStats   `json:"hybrid,omitempty"`
+	Negative       *CacheStats         `json:"negative,omitempty"`
 }
@@ -5,12 +5,60 @@ import (
 	"container/list"
 	"crypto/sha256"
 	"encoding/hex"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
-// LRUCache is a thread-safe LRU cache with TTL support and metrics.
+// EvictionPolicy selects which entry an LRUCache evicts when it is full.
+type EvictionPolicy string
+
+const (
+	// EvictionLRU evicts the least recently used entry. This is the default.
+	EvictionLRU EvictionPolicy = "lru"
+	// EvictionLFU evicts the least frequently used entry, breaking ties by recency.
+	EvictionLFU EvictionPolicy = "lfu"
+	// EvictionLFUAging is EvictionLFU with access counts halved every decay interval,
+	// so entries that were hot once but are no longer requested eventually evict.
+	EvictionLFUAging EvictionPolicy = "lfu-aging"
+
+	// defaultFrequencyDecayInterval is how often EvictionLFUAging halves access counts.
+	defaultFrequencyDecayInterval = time.Minute
+)
+
+// ParseEvictionPolicy maps a config value to an EvictionPolicy, defaulting to EvictionLRU.
+func ParseEvictionPolicy(value string) EvictionPolicy {
+	switch policy := EvictionPolicy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case EvictionLFU, EvictionLFUAging:
+		return policy
+	default:
+		return EvictionLRU
+	}
+}
+
+// Reasons passed to an EvictCallback.
+const (
+	// EvictReasonCapacity means the entry was evicted to make room for another.
+	EvictReasonCapacity = "capacity"
+	// EvictReasonTTL means the entry expired.
+	EvictReasonTTL = "ttl"
+	// EvictReasonManual means a caller deleted, cleared or replaced the entry.
+	EvictReasonManual = "manual"
+)
+
+// EvictCallback observes entries leaving a cache. It is called after the cache's lock is
+// released, so it may use the cache.
+type EvictCallback func(key, reason string)
+
+// evictionEvent is a removal held for the EvictCallback until the lock is released.
+type evictionEvent struct {
+	key    string
+	reason string
+}
+
+// LRUCache is a thread-safe cache with TTL support and metrics. Entries are evicted by
+// recency by default, or by access frequency when built with an LFU EvictionPolicy.
 type LRUCache struct {
 	mu       sync.RWMutex
 	capacity int
@@ -19,19 +67,49 @@ type LRUCache struct {
 	order    *list.List
 	stopCh   chan struct{}
 
+	// freqs holds the *freqBucket of the LFU policies in ascending frequency order, one
+	// bucket per distinct count, so the next victim is always at the front.
+	freqs         *list.List
+	policy        EvictionPolicy
+	decayInterval time.Duration
+	lastDecay     time.Time
+
+	// onEvict and the removals pending its call; both guarded by mu.
+	onEvict EvictCallback
+	pending []evictionEvent
+
+	// staleGrace keeps expired entries around this long for GetStale; guarded by mu.
+	staleGrace time.Duration
+
 	// Metrics
-	hits   uint64
-	misses uint64
+	hits      uint64
+	misses    uint64
+	evictions uint64
 }
 
 type lruEntry struct {
 	key       string
 	value     []byte
 	expiresAt time.Time
+	// bucket is the entry's element in LRUCache.freqs and bucketElem its element in that
+	// bucket's entries; both are nil under EvictionLRU.
+	bucket     *list.Element
+	bucketElem *list.Element
+}
+
+// freqBucket groups the entries accessed freq times, most recently used at the front.
+type freqBucket struct {
+	freq    uint64
+	entries *list.List
 }
 
 // NewLRUCache creates a new LRU cache with the specified capacity and TTL.
 func NewLRUCache(capacity int, ttl time.Duration) *LRUCache {
+	return NewLRUCacheWithPolicy(capacity, ttl, EvictionLRU)
+}
+
+// NewLRUCacheWithPolicy creates a cache with the specified capacity, TTL and eviction policy.
+func NewLRUCacheWithPolicy(capacity int, ttl time.Duration, policy EvictionPolicy) *LRUCache {
 	if capacity <= 0 {
 		capacity = 1000
 	}
@@ -39,11 +117,15 @@ func NewLRUCache(capacity int, ttl time.Duration) *LRUCache {
 		ttl = 60 * time.Second
 	}
 	c := &LRUCache{
-		capacity: capacity,
-		ttl:      ttl,
-		items:    make(map[string]*list.Element),
-		order:    list.New(),
-		stopCh:   make(chan struct{}),
+		capacity:      capacity,
+		ttl:           ttl,
+		items:         make(map[string]*list.Element),
+		order:         list.New(),
+		stopCh:        make(chan struct{}),
+		freqs:         list.New(),
+		policy:        ParseEvictionPolicy(string(policy)),
+		decayInterval: defaultFrequencyDecayInterval,
+		lastDecay:     time.Now(),
 	}
 	go c.startCleanup()
 	return c
@@ -53,7 +135,7 @@ func NewLRUCache(capacity int, ttl time.Duration) *LRUCache {
 // Returns nil if not found or expired.
 func (c *LRUCache) Get(key string) []byte {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlockAndNotify()
 
 	elem, ok := c.items[key]
 	if !ok {
@@ -62,22 +144,56 @@ func (c *LRUCache) Get(key string) []byte {
 	}
 
 	entry := elem.Value.(*lruEntry)
-	if time.Now().After(entry.expiresAt) {
-		c.removeElement(elem)
+	if now := time.Now(); now.After(entry.expiresAt) {
+		if now.After(entry.expiresAt.Add(c.staleGrace)) {
+			c.removeElement(elem, EvictReasonTTL)
+		}
 		atomic.AddUint64(&c.misses, 1)
 		return nil
 	}
 
 	// Move to front (most recently used)
 	c.order.MoveToFront(elem)
+	c.touch(entry)
 	atomic.AddUint64(&c.hits, 1)
 	return entry.value
 }
 
+// GetStale retrieves a value even if it has expired, as long as it expired less than the
+// stale grace ago. stale reports whether the value is past its TTL. It does not count as a
+// hit or miss, nor refresh the entry's recency.
+func (c *LRUCache) GetStale(key string) (value []byte, stale bool, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	elem, found := c.items[key]
+	if !found {
+		return nil, false, false
+	}
+	entry := elem.Value.(*lruEntry)
+	now := time.Now()
+	if now.After(entry.expiresAt.Add(c.staleGrace)) {
+		return nil, false, false
+	}
+	return entry.value, now.After(entry.expiresAt), true
+}
+
+// SetStaleGrace keeps expired entries for grace past their TTL so GetStale can still
+// return them. Get never returns an expired entry. Stale entries keep their capacity slot
+// until the grace ends or they are evicted.
+func (c *LRUCache) SetStaleGrace(grace time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if grace < 0 {
+		grace = 0
+	}
+	c.staleGrace = grace
+}
+
 // Set stores a value in the cache.
 func (c *LRUCache) Set(key string, value []byte) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlockAndNotify()
 
 	// Update existing entry
 	if elem, ok := c.items[key]; ok {
@@ -85,12 +201,13 @@ func (c *LRUCache) Set(key string, value []byte) {
 		entry.value = value
 		entry.expiresAt = time.Now().Add(c.ttl)
 		c.order.MoveToFront(elem)
+		c.touch(entry)
 		return
 	}
 
-	// Evict oldest if at capacity
+	// Evict according to the policy if at capacity
 	for c.order.Len() >= c.capacity {
-		c.removeOldest()
+		c.evict()
 	}
 
 	// Add new entry
@@ -101,25 +218,58 @@ func (c *LRUCache) Set(key string, value []byte) {
 	}
 	elem := c.order.PushFront(entry)
 	c.items[key] = elem
+	if c.policy != EvictionLRU {
+		// Aging may have left a bucket of zero counts ahead of the one for new entries.
+		var after *list.Element
+		if front := c.freqs.Front(); front != nil && front.Value.(*freqBucket).freq == 0 {
+			after = front
+		}
+		c.placeInBucket(entry, 1, after)
+	}
 }
 
 // Delete removes a key from the cache.
 func (c *LRUCache) Delete(key string) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlockAndNotify()
 
 	if elem, ok := c.items[key]; ok {
-		c.removeElement(elem)
+		c.removeElement(elem, EvictReasonManual)
 	}
 }
 
 // Clear removes all entries from the cache.
 func (c *LRUCache) Clear() {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlockAndNotify()
 
+	if c.onEvict != nil {
+		for elem := c.order.Back(); elem != nil; elem = elem.Prev() {
+			c.pending = append(c.pending, evictionEvent{key: elem.Value.(*lruEntry).key, reason: EvictReasonManual})
+		}
+	}
 	c.items = make(map[string]*list.Element)
 	c.order.Init()
+	c.freqs.Init()
+}
+
+// SetOnEvict registers fn to be called for every entry that is evicted, expires, or is
+// deleted or cleared. Replacing an entry's value with Set does not call it. Pass nil to
+// stop observing.
+func (c *LRUCache) SetOnEvict(fn EvictCallback) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onEvict = fn
+}
+
+// unlockAndNotify releases c.mu and then reports the removals made while it was held.
+func (c *LRUCache) unlockAndNotify() {
+	fn, events := c.onEvict, c.pending
+	c.pending = nil
+	c.mu.Unlock()
+	for _, event := range events {
+		fn(event.key, event.reason)
+	}
 }
 
 // Len returns the number of items in the cache.
@@ -139,30 +289,136 @@ func (c *LRUCache) Stats() CacheStats {
 		hitRate = float64(hits) / float64(total) * 100
 	}
 	return CacheStats{
-		Hits:    hits,
-		Misses:  misses,
-		Size:    c.Len(),
-		HitRate: hitRate,
+		Hits:           hits,
+		Misses:         misses,
+		Size:           c.Len(),
+		HitRate:        hitRate,
+		EvictionPolicy: string(c.policy),
+		Evictions:      atomic.LoadUint64(&c.evictions),
 	}
 }
 
-// ResetStats resets the hit/miss counters.
+// ResetStats resets the hit/miss and eviction counters.
 func (c *LRUCache) ResetStats() {
 	atomic.StoreUint64(&c.hits, 0)
 	atomic.StoreUint64(&c.misses, 0)
+	atomic.StoreUint64(&c.evictions, 0)
 }
 
-func (c *LRUCache) removeElement(elem *list.Element) {
+// removeElement drops elem and queues it for the EvictCallback. Callers must hold c.mu.
+func (c *LRUCache) removeElement(elem *list.Element, reason string) {
 	entry := elem.Value.(*lruEntry)
 	delete(c.items, entry.key)
 	c.order.Remove(elem)
+	if entry.bucket != nil {
+		c.unlinkFromBucket(entry)
+	}
+	if c.onEvict != nil {
+		c.pending = append(c.pending, evictionEvent{key: entry.key, reason: reason})
+	}
+}
+
+// touch records an access for the LFU policies by moving entry to the bucket one count
+// higher. Callers must hold c.mu.
+func (c *LRUCache) touch(entry *lruEntry) {
+	if c.policy == EvictionLRU {
+		return
+	}
+	c.maybeDecay()
+	current := entry.bucket
+	bucket := current.Value.(*freqBucket)
+	bucket.entries.Remove(entry.bucketElem)
+	c.placeInBucket(entry, bucket.freq+1, current)
+	if bucket.entries.Len() == 0 {
+		c.freqs.Remove(current)
+	}
+}
+
+// placeInBucket adds entry to the bucket for freq, which belongs right after the bucket
+// element after, or at the front when after is nil, creating it if needed. Callers must
+// hold c.mu.
+func (c *LRUCache) placeInBucket(entry *lruEntry, freq uint64, after *list.Element) {
+	next := c.freqs.Front()
+	if after != nil {
+		next = after.Next()
+	}
+	if next == nil || next.Value.(*freqBucket).freq != freq {
+		bucket := &freqBucket{freq: freq, entries: list.New()}
+		if after == nil {
+			next = c.freqs.PushFront(bucket)
+		} else {
+			next = c.freqs.InsertAfter(bucket, after)
+		}
+	}
+	entry.bucket = next
+	entry.bucketElem = next.Value.(*freqBucket).entries.PushFront(entry)
+}
+
+// unlinkFromBucket removes entry from its frequency bucket, dropping the bucket once it is
+// empty. Callers must hold c.mu.
+func (c *LRUCache) unlinkFromBucket(entry *lruEntry) {
+	bucket := entry.bucket.Value.(*freqBucket)
+	bucket.entries.Remove(entry.bucketElem)
+	if bucket.entries.Len() == 0 {
+		c.freqs.Remove(entry.bucket)
+	}
+	entry.bucket, entry.bucketElem = nil, nil
+}
+
+// maybeDecay halves every access count once per decay interval under EvictionLFUAging.
+// Halving keeps the buckets in order, so it walks the buckets rather than the entries and
+// only moves entries when two neighbouring counts become equal. Callers must hold c.mu.
+func (c *LRUCache) maybeDecay() {
+	if c.policy != EvictionLFUAging || time.Since(c.lastDecay) < c.decayInterval {
+		return
+	}
+	for elem := c.freqs.Front(); elem != nil; {
+		next := elem.Next()
+		elem.Value.(*freqBucket).freq /= 2
+		if prev := elem.Prev(); prev != nil && prev.Value.(*freqBucket).freq == elem.Value.(*freqBucket).freq {
+			c.mergeBuckets(prev, elem)
+		}
+		elem = next
+	}
+	c.lastDecay = time.Now()
 }
 
-func (c *LRUCache) removeOldest() {
-	elem := c.order.Back()
-	if elem != nil {
-		c.removeElement(elem)
+// mergeBuckets joins two buckets whose counts became equal, moving the smaller one's
+// entries. The entries of lower, which had the lower count, rank as less recently used.
+// Callers must hold c.mu.
+func (c *LRUCache) mergeBuckets(lower, upper *list.Element) {
+	lb, ub := lower.Value.(*freqBucket), upper.Value.(*freqBucket)
+	if lb.entries.Len() <= ub.entries.Len() {
+		for e := lb.entries.Front(); e != nil; e = e.Next() {
+			entry := e.Value.(*lruEntry)
+			entry.bucket, entry.bucketElem = upper, ub.entries.PushBack(entry)
+		}
+		c.freqs.Remove(lower)
+		return
+	}
+	for e := ub.entries.Back(); e != nil; e = e.Prev() {
+		entry := e.Value.(*lruEntry)
+		entry.bucket, entry.bucketElem = lower, lb.entries.PushFront(entry)
+	}
+	c.freqs.Remove(upper)
+}
+
+// evict removes one entry chosen by the eviction policy: the least recently used entry,
+// or under the LFU policies the least recently used entry of the lowest-count bucket.
+// Callers must hold c.mu.
+func (c *LRUCache) evict() {
+	victim := c.order.Back()
+	if victim == nil {
+		return
 	}
+	if c.policy != EvictionLRU {
+		c.maybeDecay()
+		if front := c.freqs.Front(); front != nil {
+			victim = c.items[front.Value.(*freqBucket).entries.Back().Value.(*lruEntry).key]
+		}
+	}
+	c.removeElement(victim, EvictReasonCapacity)
+	atomic.AddUint64(&c.evictions, 1)
 }
 
 func (c *LRUCache) startCleanup() {
@@ -186,13 +442,13 @@ func (c *LRUCache) Close() {
 func (c *LRUCache) purgeExpired() {
 	now := time.Now()
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlockAndNotify()
 
 	for elem := c.order.Back(); elem != nil; {
 		entry := elem.Value.(*lruEntry)
 		prev := elem.Prev()
-		if now.After(entry.expiresAt) {
-			c.removeElement(elem)
+		if now.After(entry.expiresAt.Add(c.staleGrace)) {
+			c.removeElement(elem, EvictReasonTTL)
 		}
 		elem = prev
 	}
@@ -204,6 +460,10 @@ type CacheStats struct {
 	Misses  uint64  `json:"misses"`
 	Size    int     `json:"size"`
 	HitRate float64 `json:"hit_rate_percent"`
+	// EvictionPolicy and Evictions report how capacity evictions were chosen and how many
+	// happened; expired entries are not counted.
+	EvictionPolicy string `json:"eviction_policy,omitempty"`
+	Evictions      uint64 `json:"evictions"`
 }
 
 // HashKey creates a cache key from multiple string inputs.
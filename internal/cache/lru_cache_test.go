@@ -1,6 +1,9 @@
 package cache
 
 import (
+	"fmt"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 )
@@ -61,6 +64,44 @@ func TestLRUCache_TTLExpiration(t *testing.T) {
 	}
 }
 
+func TestLRUCache_GetStaleWithinGrace(t *testing.T) {
+	c := NewLRUCache(10, 30*time.Millisecond)
+	defer c.Close()
+	c.SetStaleGrace(200 * time.Millisecond)
+	c.Set("key1", []byte("value1"))
+
+	if got, stale, ok := c.GetStale("key1"); !ok || stale || string(got) != "value1" {
+		t.Fatalf("fresh GetStale = %q, stale=%v, ok=%v", got, stale, ok)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if got := c.Get("key1"); got != nil {
+		t.Fatalf("Get returned expired value %q", got)
+	}
+	if got, stale, ok := c.GetStale("key1"); !ok || !stale || string(got) != "value1" {
+		t.Fatalf("GetStale within grace = %q, stale=%v, ok=%v", got, stale, ok)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	if _, _, ok := c.GetStale("key1"); ok {
+		t.Fatal("GetStale returned an entry past its grace window")
+	}
+	if n := c.Len(); n != 0 {
+		t.Fatalf("len = %d, want entries past their grace purged", n)
+	}
+}
+
+func TestLRUCache_GetStaleWithoutGrace(t *testing.T) {
+	c := NewLRUCache(10, 30*time.Millisecond)
+	defer c.Close()
+	c.Set("key1", []byte("value1"))
+
+	time.Sleep(60 * time.Millisecond)
+	if _, _, ok := c.GetStale("key1"); ok {
+		t.Fatal("GetStale returned an expired entry without a grace window")
+	}
+}
+
 func TestLRUCache_Stats(t *testing.T) {
 	c := NewLRUCache(10, 1*time.Minute)
 
@@ -130,3 +171,192 @@ func TestHashKey(t *testing.T) {
 		t.Errorf("expected key length 32, got %d", len(key1))
 	}
 }
+
+// hotKeyUnderChurn requests "hot" repeatedly and then floods the cache with one-off keys.
+func hotKeyUnderChurn(c *LRUCache) {
+	c.Set("hot", []byte("value"))
+	for i := 0; i < 5; i++ {
+		c.Get("hot")
+	}
+	for i := 0; i < 10; i++ {
+		c.Set(fmt.Sprintf("once-%d", i), []byte("value"))
+	}
+}
+
+func TestLRUCache_DefaultPolicyIsLRU(t *testing.T) {
+	c := NewLRUCache(3, time.Minute)
+	defer c.Close()
+	hotKeyUnderChurn(c)
+
+	if c.Get("hot") != nil {
+		t.Fatal("LRU should evict the hot key once enough newer keys arrive")
+	}
+	stats := c.Stats()
+	if stats.EvictionPolicy != string(EvictionLRU) || stats.Evictions != 8 {
+		t.Fatalf("stats = %+v, want lru with 8 evictions", stats)
+	}
+}
+
+func TestLRUCache_LFUKeepsFrequentEntries(t *testing.T) {
+	c := NewLRUCacheWithPolicy(3, time.Minute, EvictionLFU)
+	defer c.Close()
+	hotKeyUnderChurn(c)
+
+	if c.Get("hot") == nil {
+		t.Fatal("LFU should keep the frequently used key through one-off traffic")
+	}
+	// Ties between one-off keys evict the least recent, so the newest one-off survives.
+	if c.Get("once-9") == nil || c.Get("once-0") != nil {
+		t.Fatal("LFU should break frequency ties by recency")
+	}
+	stats := c.Stats()
+	if stats.EvictionPolicy != string(EvictionLFU) || stats.Evictions != 8 {
+		t.Fatalf("stats = %+v, want lfu with 8 evictions", stats)
+	}
+}
+
+func TestLRUCache_LFUAgingEvictsStaleHotEntries(t *testing.T) {
+	c := NewLRUCacheWithPolicy(3, time.Minute, EvictionLFUAging)
+	defer c.Close()
+	c.decayInterval = time.Hour
+	hotKeyUnderChurn(c)
+	if c.Get("hot") == nil {
+		t.Fatal("hot key should survive while its count is fresh")
+	}
+
+	// Let several decay intervals pass with no further traffic to the hot key.
+	for i := 0; i < 4; i++ {
+		c.mu.Lock()
+		c.lastDecay = time.Now().Add(-2 * time.Hour)
+		c.maybeDecay()
+		c.mu.Unlock()
+	}
+	for i := 0; i < 3; i++ {
+		key := fmt.Sprintf("fresh-%d", i)
+		c.Set(key, []byte("value"))
+		c.Get(key)
+	}
+	if c.Get("hot") != nil {
+		t.Fatal("a once-hot key should evict after its count decays")
+	}
+}
+
+func TestLRUCache_LFUAgingMergedCountsEvictLowerCountFirst(t *testing.T) {
+	c := NewLRUCacheWithPolicy(2, time.Minute, EvictionLFUAging)
+	defer c.Close()
+	c.decayInterval = time.Hour
+	c.Set("a", []byte("value"))
+	c.Get("a")
+	c.Get("a")
+	c.Set("b", []byte("value"))
+	c.Get("b")
+
+	// Counts 3 and 2 both halve to 1, landing in one bucket.
+	c.mu.Lock()
+	c.lastDecay = time.Now().Add(-2 * time.Hour)
+	c.maybeDecay()
+	buckets := c.freqs.Len()
+	c.mu.Unlock()
+	if buckets != 1 {
+		t.Fatalf("got %d frequency buckets after decay, want the two counts merged into 1", buckets)
+	}
+
+	c.Set("c", []byte("value"))
+	if c.Get("b") != nil || c.Get("a") == nil {
+		t.Fatal("the entry with the lower count before decay should evict first")
+	}
+}
+
+// evictionLog collects EvictCallback calls as "key:reason".
+type evictionLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *evictionLog) record(key, reason string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, key+":"+reason)
+}
+
+func (l *evictionLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return fmt.Sprint(l.events)
+}
+
+func TestLRUCache_OnEvictReasons(t *testing.T) {
+	c := NewLRUCache(2, time.Minute)
+	defer c.Close()
+	var log evictionLog
+	c.SetOnEvict(log.record)
+
+	c.Set("a", []byte("1"))
+	c.Set("b", []byte("2"))
+	c.Set("a", []byte("1b")) // replacing a value is not an eviction
+	c.Set("c", []byte("3"))  // evicts b, the least recently used
+	c.Delete("a")
+	c.Clear()
+
+	if got, want := log.String(), "[b:capacity a:manual c:manual]"; got != want {
+		t.Fatalf("events = %s, want %s", got, want)
+	}
+}
+
+func TestLRUCache_OnEvictTTL(t *testing.T) {
+	c := NewLRUCache(10, 20*time.Millisecond)
+	defer c.Close()
+	var log evictionLog
+	c.SetOnEvict(log.record)
+
+	c.Set("read", []byte("1"))
+	c.Set("purged", []byte("2"))
+	time.Sleep(30 * time.Millisecond)
+	// Either the read or the background cleanup may remove each entry first.
+	c.Get("read")
+	c.purgeExpired()
+
+	log.mu.Lock()
+	events := append([]string(nil), log.events...)
+	log.mu.Unlock()
+	sort.Strings(events)
+	if got, want := fmt.Sprint(events), "[purged:ttl read:ttl]"; got != want {
+		t.Fatalf("events = %s, want %s", got, want)
+	}
+}
+
+func TestLRUCache_OnEvictRunsOutsideLock(t *testing.T) {
+	c := NewLRUCache(1, time.Minute)
+	defer c.Close()
+	c.SetOnEvict(func(key, _ string) {
+		// Re-entering the cache would deadlock if the lock were still held.
+		c.Get(key)
+		c.Len()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		c.Set("a", []byte("1"))
+		c.Set("b", []byte("2"))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("callback deadlocked on the cache lock")
+	}
+}
+
+func TestParseEvictionPolicy(t *testing.T) {
+	cases := map[string]EvictionPolicy{
+		"":          EvictionLRU,
+		"LFU":       EvictionLFU,
+		"lfu-aging": EvictionLFUAging,
+		"random":    EvictionLRU,
+	}
+	for value, want := range cases {
+		if got := ParseEvictionPolicy(value); got != want {
+			t.Errorf("ParseEvictionPolicy(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+// Package cache provides caching utilities for the API proxy.
+// This file reports cache lookups to the metrics recorder.
+package cache
+
+import (
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
+)
+
+// recordLookup reports a cache lookup that started at start as a hit or miss.
+func recordLookup(hit bool, start time.Time) {
+	latency := time.Since(start)
+	if hit {
+		observability.GetMetricsRecorder().RecordCacheHit(latency)
+		return
+	}
+	observability.GetMetricsRecorder().RecordCacheMiss(latency)
+}
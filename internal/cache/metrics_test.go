@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
+)
+
+// cacheLookups returns the hit and miss observations of the official cache duration histogram.
+func cacheLookups(t *testing.T) (hits, misses uint64) {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "shinapi_proxy_cache_operation_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			switch metric.GetLabel()[0].GetValue() {
+			case "hit":
+				hits = metric.GetHistogram().GetSampleCount()
+			case "miss":
+				misses = metric.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	return hits, misses
+}
+
+func TestCacheLookups_ObservedOnHitAndMiss(t *testing.T) {
+	observability.InitMetricsRecorder(observability.MetricsConfig{UseOfficialClient: true})
+	t.Cleanup(func() { observability.InitMetricsRecorder(observability.DefaultMetricsConfig()) })
+
+	redis := NewRedisCache(newFakeRedisClient(), RedisCacheConfig{KeyPrefix: "shinapi:"})
+	hybrid := NewHybridCache(redis, DefaultHybridCacheConfig())
+	hits, misses := cacheLookups(t)
+
+	expect := func(step string, wantHits, wantMisses uint64) {
+		t.Helper()
+		gotHits, gotMisses := cacheLookups(t)
+		if gotHits-hits != wantHits || gotMisses-misses != wantMisses {
+			t.Fatalf("%s: observed %d hits and %d misses, want %d and %d", step, gotHits-hits, gotMisses-misses, wantHits, wantMisses)
+		}
+		hits, misses = gotHits, gotMisses
+	}
+
+	if _, ok := redis.Get("gpt-4o", "absent"); ok {
+		t.Fatal("unexpected redis hit")
+	}
+	expect("redis miss", 0, 1)
+
+	if err := redis.Set("gpt-4o", "present", []byte("value")); err != nil {
+		t.Fatalf("redis.Set: %v", err)
+	}
+	if _, ok := redis.Get("gpt-4o", "present"); !ok {
+		t.Fatal("expected redis hit")
+	}
+	expect("redis hit", 1, 0)
+
+	if _, ok := hybrid.Get("gpt-4o", "absent"); ok {
+		t.Fatal("unexpected hybrid hit")
+	}
+	expect("hybrid miss through redis", 0, 1)
+
+	if _, ok := hybrid.Get("gpt-4o", "present"); !ok {
+		t.Fatal("expected hybrid hit")
+	}
+	expect("hybrid read-through hit", 1, 0)
+
+	if _, ok := hybrid.Get("gpt-4o", "present"); !ok {
+		t.Fatal("expected local hit")
+	}
+	expect("hybrid local hit", 1, 0)
+
+	semantic := NewSemanticCache(DefaultSemanticCacheConfig())
+	t.Cleanup(semantic.Close)
+	if _, ok := semantic.Get("gpt-4o", "what is the capital of france"); ok {
+		t.Fatal("unexpected semantic hit")
+	}
+	expect("semantic miss", 0, 1)
+}
@@ -0,0 +1,88 @@
+// Package cache provides caching utilities for the API proxy.
+// This file implements a short-lived cache of deterministic provider errors.
+package cache
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	providererrors "github.com/router-for-me/CLIProxyAPI/v6/internal/errors"
+)
+
+// NegativeEntry is a provider error replayed in place of an upstream call.
+type NegativeEntry struct {
+	StatusCode int    `json:"status_code"`
+	Code       string `json:"code,omitempty"`
+	Body       string `json:"body"`
+}
+
+// NegativeCache remembers deterministic provider errors, such as a prompt exceeding the
+// context length, so identical requests bound to fail again are answered without calling
+// upstream. Entries live for a short TTL since the same request may succeed once the
+// provider or the proxy configuration changes.
+type NegativeCache struct {
+	cache *LRUCache
+}
+
+// NewNegativeCache creates a negative cache holding up to maxEntries errors for ttl.
+func NewNegativeCache(maxEntries int, ttl time.Duration) *NegativeCache {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &NegativeCache{cache: NewLRUCache(maxEntries, ttl)}
+}
+
+// NegativeCacheable reports whether perr may be cached: only non-retryable 4xx errors
+// caused by the request itself. Rate limits, server errors and errors that depend on the
+// credential used (401, 403) or on timing (408, 409) are never cached.
+func NegativeCacheable(perr *providererrors.ProviderError) bool {
+	if perr == nil || perr.Retryable || perr.ShouldFailover {
+		return false
+	}
+	switch perr.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout,
+		http.StatusConflict, http.StatusTooManyRequests:
+		return false
+	}
+	return perr.StatusCode >= 400 && perr.StatusCode < 500
+}
+
+// Get returns the cached error for key, if any.
+func (nc *NegativeCache) Get(key string) (NegativeEntry, bool) {
+	var entry NegativeEntry
+	data := nc.cache.Get(key)
+	if data == nil || json.Unmarshal(data, &entry) != nil {
+		return NegativeEntry{}, false
+	}
+	return entry, true
+}
+
+// Set caches body as the error response for key when perr is NegativeCacheable and
+// reports whether it was stored.
+func (nc *NegativeCache) Set(key string, perr *providererrors.ProviderError, body string) bool {
+	if key == "" || !NegativeCacheable(perr) {
+		return false
+	}
+	data, err := json.Marshal(NegativeEntry{StatusCode: perr.StatusCode, Code: perr.Code, Body: body})
+	if err != nil {
+		return false
+	}
+	nc.cache.Set(key, data)
+	return true
+}
+
+// Stats returns cache statistics.
+func (nc *NegativeCache) Stats() CacheStats {
+	return nc.cache.Stats()
+}
+
+// ResetStats resets the hit/miss counters.
+func (nc *NegativeCache) ResetStats() {
+	nc.cache.ResetStats()
+}
+
+// Clear removes all cached errors.
+func (nc *NegativeCache) Clear() {
+	nc.cache.Clear()
+}
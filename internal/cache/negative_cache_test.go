@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	providererrors "github.com/router-for-me/CLIProxyAPI/v6/internal/errors"
+)
+
+func TestNegativeCacheable(t *testing.T) {
+	cases := map[int]bool{
+		http.StatusBadRequest:          true,
+		http.StatusNotFound:            true,
+		http.StatusUnauthorized:        false,
+		http.StatusForbidden:           false,
+		http.StatusTooManyRequests:     false,
+		http.StatusInternalServerError: false,
+		http.StatusServiceUnavailable:  false,
+	}
+	for status, want := range cases {
+		perr := providererrors.ParseProviderError("openai", status, []byte(`{"error":{"message":"x"}}`))
+		if got := NegativeCacheable(perr); got != want {
+			t.Errorf("status %d: cacheable = %v, want %v", status, got, want)
+		}
+	}
+}
+
+func TestNegativeCache_SetGet(t *testing.T) {
+	nc := NewNegativeCache(10, time.Minute)
+	body := `{"error":{"message":"context length exceeded"}}`
+
+	if nc.Set("k", providererrors.ParseProviderError("openai", http.StatusTooManyRequests, []byte(body)), body) {
+		t.Fatal("429 must not be cached")
+	}
+	if _, ok := nc.Get("k"); ok {
+		t.Fatal("unexpected entry after rejected Set")
+	}
+
+	if !nc.Set("k", providererrors.ParseProviderError("openai", http.StatusBadRequest, []byte(body)), body) {
+		t.Fatal("400 should be cached")
+	}
+	entry, ok := nc.Get("k")
+	if !ok || entry.StatusCode != http.StatusBadRequest || entry.Body != body {
+		t.Fatalf("unexpected entry: %+v (ok=%v)", entry, ok)
+	}
+}
+
+func TestRequestCacheKey(t *testing.T) {
+	cfg := DefaultCacheKeyConfig()
+	base := []byte(`{"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}],"temperature":0.2,"user":"a"}`)
+	key := RequestCacheKey(cfg, "m", base)
+
+	if RequestCacheKey(cfg, "m", base) != key {
+		t.Fatal("key should be stable")
+	}
+	for name, payload := range map[string]string{
+		"prompt": `{"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"bye"}],"temperature":0.2,"user":"a"}`,
+		"system": `{"messages":[{"role":"system","content":"be verbose"},{"role":"user","content":"hi"}],"temperature":0.2,"user":"a"}`,
+	} {
+		if RequestCacheKey(cfg, "m", []byte(payload)) == key {
+			t.Errorf("changing the %s should change the key", name)
+		}
+	}
+	if RequestCacheKey(cfg, "other", base) == key {
+		t.Error("changing the model should change the key")
+	}
+
+	hotter := []byte(`{"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}],"temperature":0.9,"user":"b"}`)
+	if RequestCacheKey(cfg, "m", hotter) != key {
+		t.Error("temperature and excluded fields should not affect the default key")
+	}
+	cfg.IncludeTemperature = true
+	if RequestCacheKey(cfg, "m", hotter) == RequestCacheKey(cfg, "m", base) {
+		t.Error("temperature should affect the key when included")
+	}
+}
@@ -3,9 +3,14 @@
 package cache
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -20,14 +25,52 @@ type RedisClient interface {
 	Exists(ctx context.Context, key string) (bool, error)
 	TTL(ctx context.Context, key string) (time.Duration, error)
 	Keys(ctx context.Context, pattern string) ([]string, error)
+	// Scan iterates keys matching pattern with SCAN, passing batches of at most count keys to fn.
+	Scan(ctx context.Context, pattern string, count int64, fn func(keys []string) error) error
+	// Unlink removes keys, batching them into as few round trips as the client allows.
+	Unlink(ctx context.Context, keys ...string) error
+	// ZAdd adds member to the sorted set key with score, updating the score if present.
+	ZAdd(ctx context.Context, key string, score float64, member string) error
+	// ZRevRange returns the members ranked start..stop by descending score.
+	ZRevRange(ctx context.Context, key string, start, stop int64) ([]string, error)
+	// ZRemRangeByRank removes the members ranked start..stop by ascending score.
+	ZRemRangeByRank(ctx context.Context, key string, start, stop int64) error
 	Ping(ctx context.Context) error
 	Close() error
 }
 
+// RedisMode selects how the Redis client connects.
+type RedisMode string
+
+const (
+	// RedisModeStandalone connects to a single server. This is the default.
+	RedisModeStandalone RedisMode = "standalone"
+	// RedisModeCluster connects to a Redis Cluster through its seed nodes.
+	RedisModeCluster RedisMode = "cluster"
+	// RedisModeSentinel asks the sentinels for the current master and follows failovers.
+	RedisModeSentinel RedisMode = "sentinel"
+)
+
+// ParseRedisMode maps a config value to a RedisMode, defaulting to RedisModeStandalone.
+func ParseRedisMode(value string) RedisMode {
+	switch mode := RedisMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case RedisModeCluster, RedisModeSentinel:
+		return mode
+	default:
+		return RedisModeStandalone
+	}
+}
+
 // RedisCacheConfig configures the Redis cache.
 type RedisCacheConfig struct {
+	// Mode selects a standalone server, a Redis Cluster or a Sentinel-managed master
+	Mode RedisMode `yaml:"mode" json:"mode"`
 	// Address is the Redis server address (host:port)
 	Address string `yaml:"address" json:"address"`
+	// Addresses lists the cluster seed nodes or the sentinels; Address is used when empty
+	Addresses []string `yaml:"addresses" json:"addresses"`
+	// MasterName is the Sentinel master set name (sentinel mode only)
+	MasterName string `yaml:"master-name" json:"master_name"`
 	// Password is the Redis password (optional)
 	Password string `yaml:"password" json:"password"`
 	// Database is the Redis database number
@@ -48,6 +91,14 @@ type RedisCacheConfig struct {
 	WriteTimeoutMs int `yaml:"write-timeout-ms" json:"write_timeout_ms"`
 	// EnableTLS enables TLS for Redis connections
 	EnableTLS bool `yaml:"enable-tls" json:"enable_tls"`
+	// ScanCount is the number of keys Clear enumerates and deletes per batch
+	ScanCount int `yaml:"scan-count" json:"scan_count"`
+	// CompressStreaming gzips cached streaming responses before storing them
+	CompressStreaming bool `yaml:"compress-streaming" json:"compress_streaming"`
+	// MaxStreamingBytes caps the uncompressed event data of a cached streaming response (0 = no limit)
+	MaxStreamingBytes int64 `yaml:"max-streaming-bytes" json:"max_streaming_bytes"`
+	// AccessIndexSize is how many recently used keys are tracked for cache warmup (0 = disabled)
+	AccessIndexSize int `yaml:"access-index-size" json:"access_index_size"`
 	// Enabled controls whether Redis caching is active
 	Enabled bool `yaml:"enabled" json:"enabled"`
 }
@@ -65,6 +116,7 @@ func DefaultRedisCacheConfig() RedisCacheConfig {
 		ReadTimeoutMs:     3000,
 		WriteTimeoutMs:    3000,
 		EnableTLS:         false,
+		ScanCount:         500,
 		Enabled:           false,
 	}
 }
@@ -81,6 +133,18 @@ type RedisCache struct {
 	errors    uint64
 	latencyNs atomic.Int64
 
+	// Streaming payload sizes before and after compression
+	streamingRawBytes        atomic.Int64
+	streamingCompressedBytes atomic.Int64
+
+	// accessWrites counts access index updates to schedule trimming
+	accessWrites atomic.Uint64
+	// pendingAccess holds access index updates queued by hybrid local hits, by member, until
+	// the background flush writes them; accessFlushing is set while that flush runs.
+	accessMu       sync.Mutex
+	pendingAccess  map[string]float64
+	accessFlushing bool
+
 	mu     sync.RWMutex
 	closed bool
 }
@@ -93,6 +157,9 @@ func NewRedisCache(client RedisClient, cfg RedisCacheConfig) *RedisCache {
 	if cfg.KeyPrefix == "" {
 		cfg.KeyPrefix = "shinapi:"
 	}
+	if cfg.ScanCount <= 0 {
+		cfg.ScanCount = 500
+	}
 
 	return &RedisCache{
 		client:    client,
@@ -103,6 +170,16 @@ func NewRedisCache(client RedisClient, cfg RedisCacheConfig) *RedisCache {
 
 // Get retrieves a value from Redis.
 func (c *RedisCache) Get(model, key string) ([]byte, bool) {
+	start := time.Now()
+	data, found := c.get(model, key)
+	recordLookup(found, start)
+	if found {
+		c.recordAccess(model, key)
+	}
+	return data, found
+}
+
+func (c *RedisCache) get(model, key string) ([]byte, bool) {
 	c.mu.RLock()
 	if c.closed {
 		c.mu.RUnlock()
@@ -135,6 +212,16 @@ func (c *RedisCache) Set(model, key string, value []byte) error {
 
 // SetWithTTL stores a value in Redis with a custom TTL.
 func (c *RedisCache) SetWithTTL(model, key string, value []byte, ttl time.Duration) error {
+	if err := c.setWithTTL(model, key, value, ttl); err != nil {
+		return err
+	}
+	if len(value) > 0 {
+		c.recordAccess(model, key)
+	}
+	return nil
+}
+
+func (c *RedisCache) setWithTTL(model, key string, value []byte, ttl time.Duration) error {
 	c.mu.RLock()
 	if c.closed {
 		c.mu.RUnlock()
@@ -179,7 +266,11 @@ func (c *RedisCache) Delete(model, key string) error {
 	return c.client.Delete(ctx, fullKey)
 }
 
-// Clear removes all keys with the configured prefix.
+// Clear removes all keys with the configured prefix. Keys are enumerated with SCAN in
+// batches of ScanCount and each batch is unlinked in one round trip, so a flush never
+// blocks Redis the way KEYS does on large datasets. A failed batch is counted in the error
+// stats and skipped; the first error is returned once the scan completes, or at once if
+// the scan itself fails.
 func (c *RedisCache) Clear() error {
 	c.mu.RLock()
 	if c.closed {
@@ -191,21 +282,152 @@ func (c *RedisCache) Clear() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	pattern := c.config.KeyPrefix + "*"
-	keys, err := c.client.Keys(ctx, pattern)
+	var firstErr error
+	err := c.client.Scan(ctx, c.config.KeyPrefix+"*", int64(c.config.ScanCount), func(keys []string) error {
+		if err := c.client.Unlink(ctx, keys...); err != nil {
+			atomic.AddUint64(&c.errors, 1)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		return nil
+	})
 	if err != nil {
+		atomic.AddUint64(&c.errors, 1)
 		return err
 	}
+	return firstErr
+}
+
+// accessIndexTrimEvery is how many access index updates pass between trims to AccessIndexSize.
+const accessIndexTrimEvery = 64
+
+// CacheKeyRef identifies a cached value by the model and key it was stored under.
+type CacheKeyRef struct {
+	Model string `json:"model"`
+	Key   string `json:"key"`
+}
+
+// accessIndexKey returns the sorted set ranking keys by last access time.
+func (c *RedisCache) accessIndexKey() string {
+	return c.config.KeyPrefix + "access-index"
+}
+
+// recordAccess moves a key to the front of the access index when it is enabled, costing one
+// ZADD per Redis hit or write. The index is trimmed back to AccessIndexSize periodically
+// rather than on every access. Failures only make warmup less complete, so they are ignored.
+func (c *RedisCache) recordAccess(model, key string) {
+	if c.config.AccessIndexSize <= 0 {
+		return
+	}
+	if member, ok := accessMember(model, key); ok {
+		c.writeAccess(member, float64(time.Now().UnixMicro()))
+	}
+}
 
-	for _, key := range keys {
-		if err := c.client.Delete(ctx, key); err != nil {
-			atomic.AddUint64(&c.errors, 1)
+// queueAccess is recordAccess for hits served without a Redis round trip. The update is
+// queued and written by a background flush, so the hit never waits on Redis; repeated hits
+// on a key before the flush collapse into one write, and at most AccessIndexSize keys are
+// queued at a time.
+func (c *RedisCache) queueAccess(model, key string) {
+	if c.config.AccessIndexSize <= 0 {
+		return
+	}
+	member, ok := accessMember(model, key)
+	if !ok {
+		return
+	}
+
+	c.accessMu.Lock()
+	if c.pendingAccess == nil {
+		c.pendingAccess = make(map[string]float64)
+	}
+	if _, queued := c.pendingAccess[member]; queued || len(c.pendingAccess) < c.config.AccessIndexSize {
+		c.pendingAccess[member] = float64(time.Now().UnixMicro())
+	}
+	start := !c.accessFlushing
+	c.accessFlushing = true
+	c.accessMu.Unlock()
+
+	if start {
+		go c.flushAccess()
+	}
+}
+
+// flushAccess writes queued access index updates until the queue stays empty.
+func (c *RedisCache) flushAccess() {
+	for {
+		c.accessMu.Lock()
+		pending := c.pendingAccess
+		c.pendingAccess = nil
+		if len(pending) == 0 {
+			c.accessFlushing = false
+			c.accessMu.Unlock()
+			return
+		}
+		c.accessMu.Unlock()
+
+		for member, score := range pending {
+			c.writeAccess(member, score)
 		}
 	}
+}
 
-	return nil
+// writeAccess sets member's access index score, trimming the index every accessIndexTrimEvery writes.
+func (c *RedisCache) writeAccess(member string, score float64) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.config.WriteTimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	indexKey := c.accessIndexKey()
+	if err := c.client.ZAdd(ctx, indexKey, score, member); err != nil {
+		return
+	}
+	if c.accessWrites.Add(1)%accessIndexTrimEvery == 0 {
+		_ = c.client.ZRemRangeByRank(ctx, indexKey, 0, -int64(c.config.AccessIndexSize)-1)
+	}
+}
+
+// accessMember encodes a model and key as an access index member.
+func accessMember(model, key string) (string, bool) {
+	member, err := json.Marshal([2]string{model, key})
+	if err != nil {
+		return "", false
+	}
+	return string(member), true
+}
+
+// HotKeys returns up to n of the most recently used keys, most recent first. It requires
+// AccessIndexSize to be set.
+func (c *RedisCache) HotKeys(n int) ([]CacheKeyRef, error) {
+	if c.config.AccessIndexSize <= 0 {
+		return nil, ErrAccessIndexDisabled
+	}
+	if n <= 0 || n > c.config.AccessIndexSize {
+		n = c.config.AccessIndexSize
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.config.ReadTimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	members, err := c.client.ZRevRange(ctx, c.accessIndexKey(), 0, int64(n)-1)
+	if err != nil {
+		atomic.AddUint64(&c.errors, 1)
+		return nil, err
+	}
+	refs := make([]CacheKeyRef, 0, len(members))
+	for _, member := range members {
+		var pair [2]string
+		if err = json.Unmarshal([]byte(member), &pair); err != nil {
+			continue
+		}
+		refs = append(refs, CacheKeyRef{Model: pair[0], Key: pair[1]})
+	}
+	return refs, nil
 }
 
+// ErrAccessIndexDisabled is returned by HotKeys when AccessIndexSize is not set.
+var ErrAccessIndexDisabled = errors.New("redis access index is disabled")
+
 // SetModelTTL sets the TTL for a specific model.
 func (c *RedisCache) SetModelTTL(model string, ttl time.Duration) {
 	c.ttlConfig.SetModelTTL(model, ttl)
@@ -216,6 +438,22 @@ func (c *RedisCache) SetPatternTTL(pattern string, ttl time.Duration) {
 	c.ttlConfig.SetPatternTTL(pattern, ttl)
 }
 
+// LoadModelTTLs applies the TTLs of per-model cache configs. Models containing * or ?
+// are registered as patterns. Entries without a positive TTL keep the default.
+func (c *RedisCache) LoadModelTTLs(configs []ModelCacheConfig) {
+	for _, mc := range configs {
+		if mc.Model == "" || mc.TTLSeconds <= 0 {
+			continue
+		}
+		ttl := time.Duration(mc.TTLSeconds) * time.Second
+		if strings.ContainsAny(mc.Model, "*?") {
+			c.ttlConfig.SetPatternTTL(mc.Model, ttl)
+		} else {
+			c.ttlConfig.SetModelTTL(mc.Model, ttl)
+		}
+	}
+}
+
 // GetTTL returns the TTL for a specific model.
 func (c *RedisCache) GetTTL(model string) time.Duration {
 	return c.ttlConfig.GetTTL(model)
@@ -254,18 +492,33 @@ func (c *RedisCache) Stats() RedisCacheStats {
 		hitRate = float64(hits) / float64(total) * 100
 	}
 
+	var compressionRatio float64
+	if compressed := c.streamingCompressedBytes.Load(); compressed > 0 {
+		compressionRatio = float64(c.streamingRawBytes.Load()) / float64(compressed)
+	}
+
 	return RedisCacheStats{
-		Hits:            hits,
-		Misses:          misses,
-		Errors:          errors,
-		HitRate:         hitRate,
-		LastLatencyMs:   float64(latencyNs) / 1e6,
-		Connected:       c.Ping() == nil,
-		KeyPrefix:       c.config.KeyPrefix,
-		DefaultTTLSec:   c.config.DefaultTTLSeconds,
+		Hits:             hits,
+		Misses:           misses,
+		Errors:           errors,
+		HitRate:          hitRate,
+		LastLatencyMs:    float64(latencyNs) / 1e6,
+		Connected:        c.Ping() == nil,
+		KeyPrefix:        c.config.KeyPrefix,
+		DefaultTTLSec:    c.config.DefaultTTLSeconds,
+		CompressionRatio: compressionRatio,
 	}
 }
 
+// ResetStats resets the hit/miss/error counters.
+func (c *RedisCache) ResetStats() {
+	atomic.StoreUint64(&c.hits, 0)
+	atomic.StoreUint64(&c.misses, 0)
+	atomic.StoreUint64(&c.errors, 0)
+	c.streamingRawBytes.Store(0)
+	c.streamingCompressedBytes.Store(0)
+}
+
 // makeKey creates a full Redis key with prefix.
 func (c *RedisCache) makeKey(model, key string) string {
 	return c.config.KeyPrefix + model + ":" + HashKey(key)
@@ -281,6 +534,9 @@ type RedisCacheStats struct {
 	Connected     bool    `json:"connected"`
 	KeyPrefix     string  `json:"key_prefix"`
 	DefaultTTLSec int     `json:"default_ttl_seconds"`
+	// CompressionRatio is the uncompressed to stored size of compressed streaming
+	// responses written since the last reset; 0 when none were compressed.
+	CompressionRatio float64 `json:"compression_ratio"`
 }
 
 // CachedStreamingResponse stores a streaming response for Redis.
@@ -290,13 +546,35 @@ type CachedStreamingResponse struct {
 	CreatedAt time.Time     `json:"created_at"`
 }
 
+// streamingGzipHeader marks a gzip-compressed streaming response. Uncompressed entries are
+// plain JSON and start with '{', so entries written before compression was enabled, or
+// with it disabled, still decode.
+const streamingGzipHeader byte = 0x01
+
+// ErrStreamingResponseTooLarge is returned by SetStreamingResponse when the events exceed
+// MaxStreamingBytes.
+var ErrStreamingResponseTooLarge = errors.New("streaming response exceeds the cache size limit")
+
 // GetStreamingResponse retrieves a cached streaming response from Redis.
 func (c *RedisCache) GetStreamingResponse(key string) ([]StreamEvent, bool) {
-	data, found := c.Get("streaming", key)
+	data, found := c.get("streaming", key)
 	if !found {
 		return nil, false
 	}
 
+	if len(data) > 0 && data[0] == streamingGzipHeader {
+		reader, err := gzip.NewReader(bytes.NewReader(data[1:]))
+		if err != nil {
+			atomic.AddUint64(&c.errors, 1)
+			return nil, false
+		}
+		data, err = io.ReadAll(reader)
+		if err != nil {
+			atomic.AddUint64(&c.errors, 1)
+			return nil, false
+		}
+	}
+
 	var resp CachedStreamingResponse
 	if err := json.Unmarshal(data, &resp); err != nil {
 		atomic.AddUint64(&c.errors, 1)
@@ -306,12 +584,16 @@ func (c *RedisCache) GetStreamingResponse(key string) ([]StreamEvent, bool) {
 	return resp.Events, true
 }
 
-// SetStreamingResponse stores a streaming response in Redis.
+// SetStreamingResponse stores a streaming response in Redis, gzip-compressed when
+// CompressStreaming is set. MaxStreamingBytes applies to the uncompressed event data.
 func (c *RedisCache) SetStreamingResponse(key string, events []StreamEvent, ttl time.Duration) error {
 	var totalSize int64
 	for _, e := range events {
 		totalSize += int64(len(e.Data))
 	}
+	if c.config.MaxStreamingBytes > 0 && totalSize > c.config.MaxStreamingBytes {
+		return ErrStreamingResponseTooLarge
+	}
 
 	resp := CachedStreamingResponse{
 		Events:    events,
@@ -324,7 +606,22 @@ func (c *RedisCache) SetStreamingResponse(key string, events []StreamEvent, ttl
 		return err
 	}
 
-	return c.SetWithTTL("streaming", key, data, ttl)
+	if c.config.CompressStreaming {
+		var buf bytes.Buffer
+		buf.WriteByte(streamingGzipHeader)
+		zw := gzip.NewWriter(&buf)
+		if _, err = zw.Write(data); err != nil {
+			return err
+		}
+		if err = zw.Close(); err != nil {
+			return err
+		}
+		c.streamingRawBytes.Add(int64(len(data)))
+		c.streamingCompressedBytes.Add(int64(buf.Len()))
+		data = buf.Bytes()
+	}
+
+	return c.setWithTTL("streaming", key, data, ttl)
 }
 
 // HybridCache combines in-memory LRU cache with Redis for multi-tier caching.
@@ -344,6 +641,8 @@ type HybridCacheConfig struct {
 	WriteThrough bool `yaml:"write-through" json:"write_through"`
 	// ReadThrough reads from Redis on local cache miss and populates local.
 	ReadThrough bool `yaml:"read-through" json:"read_through"`
+	// EvictionPolicy selects how local entries are evicted at capacity (default: LRU).
+	EvictionPolicy EvictionPolicy `yaml:"eviction-policy" json:"eviction_policy"`
 }
 
 // DefaultHybridCacheConfig returns sensible defaults.
@@ -366,17 +665,23 @@ func NewHybridCache(redis *RedisCache, cfg HybridCacheConfig) *HybridCache {
 	}
 
 	return &HybridCache{
-		local:  NewLRUCache(cfg.LocalCapacity, time.Duration(cfg.LocalTTLSeconds)*time.Second),
+		local:  NewLRUCacheWithPolicy(cfg.LocalCapacity, time.Duration(cfg.LocalTTLSeconds)*time.Second, cfg.EvictionPolicy),
 		redis:  redis,
 		config: cfg,
 	}
 }
 
-// Get retrieves a value, checking local cache first, then Redis.
+// Get retrieves a value, checking local cache first, then Redis. A lookup that reaches
+// Redis is reported by RedisCache.Get.
 func (h *HybridCache) Get(model, key string) ([]byte, bool) {
+	start := time.Now()
 	// Check local cache first
 	cacheKey := HashKey(model, key)
 	if data := h.local.Get(cacheKey); data != nil {
+		if h.redis != nil {
+			h.redis.queueAccess(model, key)
+		}
+		recordLookup(true, start)
 		return data, true
 	}
 
@@ -387,8 +692,10 @@ func (h *HybridCache) Get(model, key string) ([]byte, bool) {
 			h.local.Set(cacheKey, data)
 			return data, true
 		}
+		return nil, false
 	}
 
+	recordLookup(false, start)
 	return nil, false
 }
 
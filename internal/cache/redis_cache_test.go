@@ -0,0 +1,321 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRedisClient is an in-memory RedisClient that records how Clear talks to it.
+type fakeRedisClient struct {
+	data map[string][]byte
+
+	scanPages   []int // keys handed out per SCAN page
+	unlinkCalls [][]string
+	keysCalls   int
+	unlinkErr   error
+	scanErr     error
+
+	zsets map[string]map[string]float64
+	ttls  map[string]time.Duration
+}
+
+func newFakeRedisClient() *fakeRedisClient {
+	return &fakeRedisClient{data: make(map[string][]byte)}
+}
+
+func (f *fakeRedisClient) Get(_ context.Context, key string) ([]byte, error) {
+	if v, ok := f.data[key]; ok {
+		return v, nil
+	}
+	return nil, errors.New("redis: nil")
+}
+
+func (f *fakeRedisClient) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	f.data[key] = value
+	if f.ttls == nil {
+		f.ttls = make(map[string]time.Duration)
+	}
+	f.ttls[key] = ttl
+	return nil
+}
+
+func (f *fakeRedisClient) Delete(_ context.Context, key string) error {
+	delete(f.data, key)
+	return nil
+}
+
+func (f *fakeRedisClient) Exists(_ context.Context, key string) (bool, error) {
+	_, ok := f.data[key]
+	return ok, nil
+}
+
+func (f *fakeRedisClient) TTL(context.Context, string) (time.Duration, error) { return 0, nil }
+
+func (f *fakeRedisClient) Keys(context.Context, string) ([]string, error) {
+	f.keysCalls++
+	return nil, errors.New("KEYS must not be used")
+}
+
+func (f *fakeRedisClient) Scan(_ context.Context, pattern string, count int64, fn func([]string) error) error {
+	if f.scanErr != nil {
+		return f.scanErr
+	}
+	prefix := strings.TrimSuffix(pattern, "*")
+	var matched []string
+	for key := range f.data {
+		if strings.HasPrefix(key, prefix) {
+			matched = append(matched, key)
+		}
+	}
+	sort.Strings(matched)
+	for len(matched) > 0 {
+		n := min(int64(len(matched)), count)
+		f.scanPages = append(f.scanPages, int(n))
+		if err := fn(matched[:n]); err != nil {
+			return err
+		}
+		matched = matched[n:]
+	}
+	return nil
+}
+
+func (f *fakeRedisClient) Unlink(_ context.Context, keys ...string) error {
+	f.unlinkCalls = append(f.unlinkCalls, append([]string(nil), keys...))
+	if f.unlinkErr != nil {
+		return f.unlinkErr
+	}
+	for _, key := range keys {
+		delete(f.data, key)
+	}
+	return nil
+}
+
+func (f *fakeRedisClient) ZAdd(_ context.Context, key string, score float64, member string) error {
+	if f.zsets == nil {
+		f.zsets = make(map[string]map[string]float64)
+	}
+	if f.zsets[key] == nil {
+		f.zsets[key] = make(map[string]float64)
+	}
+	f.zsets[key][member] = score
+	return nil
+}
+
+// zsetByScore returns the members of key in ascending score order.
+func (f *fakeRedisClient) zsetByScore(key string) []string {
+	members := make([]string, 0, len(f.zsets[key]))
+	for member := range f.zsets[key] {
+		members = append(members, member)
+	}
+	sort.Slice(members, func(i, j int) bool { return f.zsets[key][members[i]] < f.zsets[key][members[j]] })
+	return members
+}
+
+// zsetRange resolves Redis-style start/stop ranks (negative counts from the end) over n items.
+func zsetRange(n int, start, stop int64) (int, int) {
+	if start < 0 {
+		start += int64(n)
+	}
+	if stop < 0 {
+		stop += int64(n)
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= int64(n) {
+		stop = int64(n) - 1
+	}
+	if start > stop {
+		return 0, 0
+	}
+	return int(start), int(stop) + 1
+}
+
+func (f *fakeRedisClient) ZRevRange(_ context.Context, key string, start, stop int64) ([]string, error) {
+	members := f.zsetByScore(key)
+	for i, j := 0, len(members)-1; i < j; i, j = i+1, j-1 {
+		members[i], members[j] = members[j], members[i]
+	}
+	from, to := zsetRange(len(members), start, stop)
+	return members[from:to], nil
+}
+
+func (f *fakeRedisClient) ZRemRangeByRank(_ context.Context, key string, start, stop int64) error {
+	members := f.zsetByScore(key)
+	from, to := zsetRange(len(members), start, stop)
+	for _, member := range members[from:to] {
+		delete(f.zsets[key], member)
+	}
+	return nil
+}
+
+func (f *fakeRedisClient) Ping(context.Context) error { return nil }
+func (f *fakeRedisClient) Close() error               { return nil }
+
+func TestRedisCacheClear_ScansAndUnlinksInBatches(t *testing.T) {
+	client := newFakeRedisClient()
+	for i := 0; i < 23; i++ {
+		client.data["shinapi:m:"+strings.Repeat("k", i+1)] = []byte("v")
+	}
+	client.data["other:keep"] = []byte("v")
+
+	c := NewRedisCache(client, RedisCacheConfig{KeyPrefix: "shinapi:", ScanCount: 10})
+	if err := c.Clear(); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+
+	if client.keysCalls != 0 {
+		t.Fatalf("Clear used KEYS %d times", client.keysCalls)
+	}
+	if len(client.unlinkCalls) != 3 {
+		t.Fatalf("unlink batches = %d, want 3", len(client.unlinkCalls))
+	}
+	for i, batch := range client.unlinkCalls {
+		if len(batch) > 10 {
+			t.Errorf("batch %d unlinked %d keys, want at most 10", i, len(batch))
+		}
+	}
+	for i, n := range client.scanPages {
+		if n > 10 {
+			t.Errorf("scan page %d enumerated %d keys, want at most 10", i, n)
+		}
+	}
+	if len(client.data) != 1 || client.data["other:keep"] == nil {
+		t.Fatalf("expected only keys outside the prefix to remain, got %d keys", len(client.data))
+	}
+}
+
+func TestRedisCacheClear_CountsFailedBatchesAndReturnsFirstError(t *testing.T) {
+	client := newFakeRedisClient()
+	for i := 0; i < 5; i++ {
+		client.data["shinapi:m:"+strings.Repeat("k", i+1)] = []byte("v")
+	}
+	client.unlinkErr = errors.New("unlink failed")
+
+	c := NewRedisCache(client, RedisCacheConfig{KeyPrefix: "shinapi:", ScanCount: 2})
+	if err := c.Clear(); !errors.Is(err, client.unlinkErr) {
+		t.Fatalf("Clear error = %v, want %v", err, client.unlinkErr)
+	}
+	if len(client.unlinkCalls) != 3 {
+		t.Fatalf("a failed batch must not stop the flush: unlink batches = %d, want 3", len(client.unlinkCalls))
+	}
+	if got := c.Stats().Errors; got != 3 {
+		t.Fatalf("errors = %d, want 3", got)
+	}
+
+	client.unlinkErr = nil
+	client.scanErr = errors.New("scan failed")
+	if err := c.Clear(); !errors.Is(err, client.scanErr) {
+		t.Fatalf("Clear error = %v, want %v", err, client.scanErr)
+	}
+}
+
+func TestRedisCacheStreamingResponse_RoundTrip(t *testing.T) {
+	events := []StreamEvent{
+		{Data: []byte(`data: {"choices":[{"delta":{"content":"` + strings.Repeat("hello ", 200) + `"}}]}`), ID: "1"},
+		{Data: []byte("data: [DONE]"), EventType: "done", Delay: 5 * time.Millisecond},
+	}
+
+	for _, compress := range []bool{false, true} {
+		client := newFakeRedisClient()
+		c := NewRedisCache(client, RedisCacheConfig{KeyPrefix: "shinapi:", CompressStreaming: compress})
+		if err := c.SetStreamingResponse("k", events, time.Minute); err != nil {
+			t.Fatalf("compress=%v: SetStreamingResponse: %v", compress, err)
+		}
+
+		stored := client.data[c.makeKey("streaming", "k")]
+		if compressed := len(stored) > 0 && stored[0] == streamingGzipHeader; compressed != compress {
+			t.Fatalf("compress=%v: stored payload compressed = %v", compress, compressed)
+		}
+
+		got, ok := c.GetStreamingResponse("k")
+		if !ok {
+			t.Fatalf("compress=%v: GetStreamingResponse missed", compress)
+		}
+		if len(got) != len(events) {
+			t.Fatalf("compress=%v: got %d events, want %d", compress, len(got), len(events))
+		}
+		for i := range events {
+			if string(got[i].Data) != string(events[i].Data) || got[i].ID != events[i].ID ||
+				got[i].EventType != events[i].EventType || got[i].Delay != events[i].Delay {
+				t.Fatalf("compress=%v: event %d = %+v, want %+v", compress, i, got[i], events[i])
+			}
+		}
+
+		ratio := c.Stats().CompressionRatio
+		if compress && ratio <= 1 {
+			t.Fatalf("compression ratio = %v, want > 1", ratio)
+		}
+		if !compress && ratio != 0 {
+			t.Fatalf("compression ratio without compression = %v, want 0", ratio)
+		}
+	}
+}
+
+func TestRedisCacheStreamingResponse_ReadsUncompressedWithCompressionEnabled(t *testing.T) {
+	client := newFakeRedisClient()
+	events := []StreamEvent{{Data: []byte("data: hi")}}
+	plain := NewRedisCache(client, RedisCacheConfig{KeyPrefix: "shinapi:"})
+	if err := plain.SetStreamingResponse("k", events, time.Minute); err != nil {
+		t.Fatalf("SetStreamingResponse: %v", err)
+	}
+
+	c := NewRedisCache(client, RedisCacheConfig{KeyPrefix: "shinapi:", CompressStreaming: true})
+	got, ok := c.GetStreamingResponse("k")
+	if !ok || len(got) != 1 || string(got[0].Data) != "data: hi" {
+		t.Fatalf("GetStreamingResponse = %+v, %v", got, ok)
+	}
+}
+
+func TestRedisCacheStreamingResponse_SizeLimitUsesUncompressedSize(t *testing.T) {
+	client := newFakeRedisClient()
+	c := NewRedisCache(client, RedisCacheConfig{KeyPrefix: "shinapi:", CompressStreaming: true, MaxStreamingBytes: 100})
+
+	// Highly compressible data that only fits the limit after compression.
+	events := []StreamEvent{{Data: []byte(strings.Repeat("a", 101))}}
+	if err := c.SetStreamingResponse("k", events, time.Minute); !errors.Is(err, ErrStreamingResponseTooLarge) {
+		t.Fatalf("SetStreamingResponse error = %v, want %v", err, ErrStreamingResponseTooLarge)
+	}
+	if len(client.data) != 0 {
+		t.Fatalf("oversized response was stored")
+	}
+}
+
+func TestRedisCacheLoadModelTTLs(t *testing.T) {
+	client := newFakeRedisClient()
+	c := NewRedisCache(client, RedisCacheConfig{KeyPrefix: "shinapi:", DefaultTTLSeconds: 60})
+	c.LoadModelTTLs([]ModelCacheConfig{
+		{Model: "gpt-4o", TTLSeconds: 120},
+		{Model: "claude-*", TTLSeconds: 600},
+		{Model: "gemini-?.5-pro", TTLSeconds: 900},
+		{Model: "ignored", TTLSeconds: 0},
+	})
+
+	tests := []struct {
+		model string
+		want  time.Duration
+	}{
+		{"gpt-4o", 120 * time.Second},
+		{"gpt-4o-mini", 60 * time.Second},
+		{"claude-sonnet-4", 600 * time.Second},
+		{"gemini-2.5-pro", 900 * time.Second},
+		{"gemini-2.5-flash", 60 * time.Second},
+		{"ignored", 60 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := c.GetTTL(tt.model); got != tt.want {
+			t.Errorf("GetTTL(%q) = %v, want %v", tt.model, got, tt.want)
+		}
+	}
+
+	if err := c.Set("claude-sonnet-4", "k", []byte("v")); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if got := client.ttls[c.makeKey("claude-sonnet-4", "k")]; got != 600*time.Second {
+		t.Fatalf("Set stored TTL %v, want 10m", got)
+	}
+}
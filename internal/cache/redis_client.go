@@ -5,32 +5,42 @@ package cache
 import (
 	"context"
 	"crypto/tls"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
-// GoRedisClient implements RedisClient using go-redis.
+// GoRedisClient implements RedisClient using go-redis. It talks to a standalone server,
+// a Redis Cluster or a Sentinel-managed master depending on GoRedisConfig.Mode.
 type GoRedisClient struct {
-	client *redis.Client
+	client redis.UniversalClient
 }
 
 // GoRedisConfig holds configuration for the go-redis client.
 type GoRedisConfig struct {
-	Address       string
-	Password      string
-	Database      int
-	PoolSize      int
-	DialTimeout   time.Duration
-	ReadTimeout   time.Duration
-	WriteTimeout  time.Duration
-	EnableTLS     bool
-	MaxRetries    int
+	Mode RedisMode
+	// Address is the standalone server, also used as the only seed or sentinel when
+	// Addresses is empty.
+	Address string
+	// Addresses lists the cluster seed nodes or the sentinels.
+	Addresses []string
+	// MasterName is the Sentinel master set name.
+	MasterName   string
+	Password     string
+	Database     int
+	PoolSize     int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	EnableTLS    bool
+	MaxRetries   int
 }
 
 // DefaultGoRedisConfig returns default configuration for localhost:6379.
 func DefaultGoRedisConfig() GoRedisConfig {
 	return GoRedisConfig{
+		Mode:         RedisModeStandalone,
 		Address:      "localhost:6379",
 		Password:     "",
 		Database:     0,
@@ -45,32 +55,68 @@ func DefaultGoRedisConfig() GoRedisConfig {
 
 // NewGoRedisClient creates a new go-redis based client.
 func NewGoRedisClient(cfg GoRedisConfig) *GoRedisClient {
-	opts := &redis.Options{
-		Addr:         cfg.Address,
-		Password:     cfg.Password,
-		DB:           cfg.Database,
-		PoolSize:     cfg.PoolSize,
-		DialTimeout:  cfg.DialTimeout,
-		ReadTimeout:  cfg.ReadTimeout,
-		WriteTimeout: cfg.WriteTimeout,
-		MaxRetries:   cfg.MaxRetries,
-	}
-
+	var tlsConfig *tls.Config
 	if cfg.EnableTLS {
-		opts.TLSConfig = &tls.Config{
+		tlsConfig = &tls.Config{
 			MinVersion: tls.VersionTLS12,
 		}
 	}
 
+	addrs := cfg.Addresses
+	if len(addrs) == 0 && cfg.Address != "" {
+		addrs = []string{cfg.Address}
+	}
+
+	switch cfg.Mode {
+	case RedisModeCluster:
+		// Cluster nodes only have database 0, so Database is ignored.
+		return &GoRedisClient{client: redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        addrs,
+			Password:     cfg.Password,
+			PoolSize:     cfg.PoolSize,
+			DialTimeout:  cfg.DialTimeout,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			MaxRetries:   cfg.MaxRetries,
+			TLSConfig:    tlsConfig,
+		})}
+	case RedisModeSentinel:
+		return &GoRedisClient{client: redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    cfg.MasterName,
+			SentinelAddrs: addrs,
+			Password:      cfg.Password,
+			DB:            cfg.Database,
+			PoolSize:      cfg.PoolSize,
+			DialTimeout:   cfg.DialTimeout,
+			ReadTimeout:   cfg.ReadTimeout,
+			WriteTimeout:  cfg.WriteTimeout,
+			MaxRetries:    cfg.MaxRetries,
+			TLSConfig:     tlsConfig,
+		})}
+	}
+
 	return &GoRedisClient{
-		client: redis.NewClient(opts),
+		client: redis.NewClient(&redis.Options{
+			Addr:         cfg.Address,
+			Password:     cfg.Password,
+			DB:           cfg.Database,
+			PoolSize:     cfg.PoolSize,
+			DialTimeout:  cfg.DialTimeout,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			MaxRetries:   cfg.MaxRetries,
+			TLSConfig:    tlsConfig,
+		}),
 	}
 }
 
 // NewGoRedisClientFromRedisCacheConfig creates a client from RedisCacheConfig.
 func NewGoRedisClientFromRedisCacheConfig(cfg RedisCacheConfig) *GoRedisClient {
 	return NewGoRedisClient(GoRedisConfig{
+		Mode:         cfg.Mode,
 		Address:      cfg.Address,
+		Addresses:    cfg.Addresses,
+		MasterName:   cfg.MasterName,
 		Password:     cfg.Password,
 		Database:     cfg.Database,
 		PoolSize:     cfg.PoolSize,
@@ -108,13 +154,99 @@ func (c *GoRedisClient) TTL(ctx context.Context, key string) (time.Duration, err
 	return c.client.TTL(ctx, key).Result()
 }
 
-// Keys returns all keys matching a pattern.
+// Keys returns all keys matching a pattern. Keys are enumerated with SCAN rather than
+// KEYS; in cluster mode every master is scanned, since each holds only its own slots.
 func (c *GoRedisClient) Keys(ctx context.Context, pattern string) ([]string, error) {
-	return c.client.Keys(ctx, pattern).Result()
+	var keys []string
+	err := c.Scan(ctx, pattern, keysScanCount, func(batch []string) error {
+		keys = append(keys, batch...)
+		return nil
+	})
+	return keys, err
+}
+
+// keysScanCount is the COUNT hint passed to each SCAN call made by Keys.
+const keysScanCount = 1000
+
+// Scan iterates the keys matching pattern with SCAN, calling fn with batches of at most
+// count keys. In cluster mode every master is scanned; fn is never called concurrently.
+func (c *GoRedisClient) Scan(ctx context.Context, pattern string, count int64, fn func(keys []string) error) error {
+	if count <= 0 {
+		count = keysScanCount
+	}
+	cluster, ok := c.client.(*redis.ClusterClient)
+	if !ok {
+		return scanNode(ctx, c.client, pattern, count, fn)
+	}
+	var mu sync.Mutex
+	return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		return scanNode(ctx, node, pattern, count, func(keys []string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			return fn(keys)
+		})
+	})
 }
 
-// Ping checks Redis connectivity.
+// scanNode runs a full SCAN over one server. COUNT is only a hint to Redis, so pages are
+// split further to keep every batch within count keys.
+func scanNode(ctx context.Context, client redis.Cmdable, pattern string, count int64, fn func(keys []string) error) error {
+	var cursor uint64
+	for {
+		page, next, err := client.Scan(ctx, cursor, pattern, count).Result()
+		if err != nil {
+			return err
+		}
+		for len(page) > 0 {
+			n := min(int64(len(page)), count)
+			if err := fn(page[:n]); err != nil {
+				return err
+			}
+			page = page[n:]
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// Unlink removes keys in one pipelined round trip. UNLINK frees memory in the background,
+// and sending one command per key keeps it valid when keys span cluster slots.
+func (c *GoRedisClient) Unlink(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	pipe := c.client.Pipeline()
+	for _, key := range keys {
+		pipe.Unlink(ctx, key)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ZAdd adds member to the sorted set key with score.
+func (c *GoRedisClient) ZAdd(ctx context.Context, key string, score float64, member string) error {
+	return c.client.ZAdd(ctx, key, redis.Z{Score: score, Member: member}).Err()
+}
+
+// ZRevRange returns the members ranked start..stop by descending score.
+func (c *GoRedisClient) ZRevRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	return c.client.ZRevRange(ctx, key, start, stop).Result()
+}
+
+// ZRemRangeByRank removes the members ranked start..stop by ascending score.
+func (c *GoRedisClient) ZRemRangeByRank(ctx context.Context, key string, start, stop int64) error {
+	return c.client.ZRemRangeByRank(ctx, key, start, stop).Err()
+}
+
+// Ping checks Redis connectivity. In cluster mode every master and replica must answer.
 func (c *GoRedisClient) Ping(ctx context.Context) error {
+	if cluster, ok := c.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachShard(ctx, func(ctx context.Context, node *redis.Client) error {
+			return node.Ping(ctx).Err()
+		})
+	}
 	return c.client.Ping(ctx).Err()
 }
 
@@ -123,7 +255,8 @@ func (c *GoRedisClient) Close() error {
 	return c.client.Close()
 }
 
-// Client returns the underlying go-redis client for advanced operations.
-func (c *GoRedisClient) Client() *redis.Client {
+// Client returns the underlying go-redis client for advanced operations. Its concrete type
+// is *redis.Client, *redis.ClusterClient or the failover *redis.Client depending on the mode.
+func (c *GoRedisClient) Client() redis.UniversalClient {
 	return c.client
 }
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestParseRedisMode(t *testing.T) {
+	cases := map[string]RedisMode{
+		"":           RedisModeStandalone,
+		"standalone": RedisModeStandalone,
+		" Cluster ":  RedisModeCluster,
+		"sentinel":   RedisModeSentinel,
+		"bogus":      RedisModeStandalone,
+	}
+	for value, want := range cases {
+		if got := ParseRedisMode(value); got != want {
+			t.Errorf("ParseRedisMode(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestNewGoRedisClient_SelectsClientForMode(t *testing.T) {
+	standalone := NewGoRedisClient(GoRedisConfig{Address: "localhost:6379"})
+	defer standalone.Close()
+	if _, ok := standalone.Client().(*redis.Client); !ok {
+		t.Fatalf("standalone client = %T, want *redis.Client", standalone.Client())
+	}
+
+	cluster := NewGoRedisClient(GoRedisConfig{Mode: RedisModeCluster, Address: "node-1:6379"})
+	defer cluster.Close()
+	cc, ok := cluster.Client().(*redis.ClusterClient)
+	if !ok {
+		t.Fatalf("cluster client = %T, want *redis.ClusterClient", cluster.Client())
+	}
+	if addrs := cc.Options().Addrs; len(addrs) != 1 || addrs[0] != "node-1:6379" {
+		t.Fatalf("cluster seeds = %v, want Address as the only seed", addrs)
+	}
+
+	sentinel := NewGoRedisClient(GoRedisConfig{
+		Mode:       RedisModeSentinel,
+		Addresses:  []string{"sentinel-1:26379", "sentinel-2:26379"},
+		MasterName: "mymaster",
+	})
+	defer sentinel.Close()
+	if _, ok := sentinel.Client().(*redis.Client); !ok {
+		t.Fatalf("sentinel client = %T, want a failover *redis.Client", sentinel.Client())
+	}
+}
@@ -0,0 +1,92 @@
+// Package cache provides caching utilities for the API proxy.
+// This file derives GenerateCacheKey inputs from raw request bodies.
+package cache
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// requestKeyFields are extracted into dedicated GenerateCacheKey inputs and removed from
+// the remainder, so the CacheKeyConfig toggles decide whether they count.
+var requestKeyFields = []string{
+	"model",
+	"system", "instructions", "systemInstruction", "system_instruction",
+	"temperature", "generationConfig.temperature",
+	"max_tokens", "max_completion_tokens", "max_output_tokens", "generationConfig.maxOutputTokens",
+	"tools", "functions",
+	"reasoning_effort", "reasoning", "thinking", "generationConfig.thinkingConfig",
+}
+
+// reasoningKeyFields hold the reasoning settings of the supported request formats: OpenAI
+// chat reasoning_effort, the OpenAI Responses reasoning object, the Claude thinking object
+// and the Gemini thinkingConfig with its budget, level and include-thoughts flag.
+var reasoningKeyFields = []string{"reasoning_effort", "reasoning", "thinking", "generationConfig.thinkingConfig"}
+
+// RequestCacheKey derives the GenerateCacheKey for a raw OpenAI chat, OpenAI Responses,
+// Claude messages or Gemini request body. The system prompt, temperature, max tokens,
+// tools and reasoning settings are pulled out so cfg can include or ignore them; whatever remains of the body
+// after dropping cfg.ExcludeFields stands in for the user prompt, so any other difference
+// between two requests yields a different key.
+func RequestCacheKey(cfg CacheKeyConfig, model string, payload []byte) string {
+	root := gjson.ParseBytes(payload)
+
+	var system []string
+	for _, path := range []string{"system", "instructions", "systemInstruction", "system_instruction"} {
+		if v := root.Get(path); v.Exists() {
+			system = append(system, v.Raw)
+		}
+	}
+	remainder := append([]byte(nil), payload...)
+	if messages := root.Get("messages"); messages.IsArray() {
+		kept := make([]string, 0, len(messages.Array()))
+		for _, msg := range messages.Array() {
+			switch msg.Get("role").String() {
+			case "system", "developer":
+				system = append(system, msg.Get("content").Raw)
+			default:
+				kept = append(kept, msg.Raw)
+			}
+		}
+		if len(kept) != len(messages.Array()) {
+			if updated, err := sjson.SetRawBytes(remainder, "messages", []byte("["+strings.Join(kept, ",")+"]")); err == nil {
+				remainder = updated
+			}
+		}
+	}
+
+	temperature := root.Get("temperature")
+	if !temperature.Exists() {
+		temperature = root.Get("generationConfig.temperature")
+	}
+	var maxTokens int
+	for _, path := range []string{"max_tokens", "max_completion_tokens", "max_output_tokens", "generationConfig.maxOutputTokens"} {
+		if v := root.Get(path); v.Exists() {
+			maxTokens = int(v.Int())
+			break
+		}
+	}
+	var tools []string
+	for _, path := range []string{"tools", "functions"} {
+		for _, tool := range root.Get(path).Array() {
+			tools = append(tools, tool.Raw)
+		}
+	}
+
+	var reasoning []string
+	for _, path := range reasoningKeyFields {
+		if v := root.Get(path); v.Exists() {
+			reasoning = append(reasoning, path+"="+canonicalJSON(v.Raw))
+		}
+	}
+
+	for _, path := range append(append([]string(nil), requestKeyFields...), cfg.ExcludeFields...) {
+		if updated, err := sjson.DeleteBytes(remainder, path); err == nil {
+			remainder = updated
+		}
+	}
+
+	return GenerateCacheKey(cfg, model, strings.Join(system, "\n"), string(remainder), temperature.Float(), maxTokens, tools, strings.Join(reasoning, ","))
+}
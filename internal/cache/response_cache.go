@@ -2,6 +2,8 @@
 package cache
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"time"
 )
@@ -62,6 +64,11 @@ func (c *ResponseCache) Stats() CacheStats {
 	return c.cache.Stats()
 }
 
+// ResetStats resets the hit/miss counters.
+func (c *ResponseCache) ResetStats() {
+	c.cache.ResetStats()
+}
+
 // Clear removes all cached responses.
 func (c *ResponseCache) Clear() {
 	c.cache.Clear()
@@ -103,32 +110,55 @@ func NewRequestDeduplicator() *RequestDeduplicator {
 // Do executes the function, deduplicating identical concurrent requests.
 // If a request with the same key is already in-flight, waits for its result.
 func (d *RequestDeduplicator) Do(key string, fn func() ([]byte, error)) ([]byte, error) {
-	d.mu.Lock()
+	resp, err, _ := d.DoContext(context.Background(), key, fn)
+	return resp, err
+}
 
-	// Check if request is already in-flight
-	if req, ok := d.inflight[key]; ok {
+// DoContext is Do with a per-caller context: a caller waiting on another caller's request
+// returns ctx.Err() once ctx is done, without affecting the in-flight call. A waiter whose
+// context is still live does not inherit the in-flight caller's cancellation; it runs fn
+// itself instead. shared reports whether the result came from another caller's call, in
+// which case the response must not be modified.
+func (d *RequestDeduplicator) DoContext(ctx context.Context, key string, fn func() ([]byte, error)) (response []byte, err error, shared bool) {
+	for {
+		d.mu.Lock()
+
+		// Check if request is already in-flight
+		if req, ok := d.inflight[key]; ok {
+			d.mu.Unlock()
+			select {
+			case <-req.done:
+			case <-ctx.Done():
+				return nil, ctx.Err(), false
+			}
+			if isContextError(req.err) && ctx.Err() == nil {
+				continue
+			}
+			return req.response, req.err, true
+		}
+
+		// Create new in-flight request
+		req := &inflightRequest{
+			done: make(chan struct{}),
+		}
+		d.inflight[key] = req
 		d.mu.Unlock()
-		<-req.done
-		return req.response, req.err
-	}
 
-	// Create new in-flight request
-	req := &inflightRequest{
-		done: make(chan struct{}),
-	}
-	d.inflight[key] = req
-	d.mu.Unlock()
+		// Execute the function
+		req.response, req.err = fn()
 
-	// Execute the function
-	req.response, req.err = fn()
-	close(req.done)
+		// Clean up before waking waiters so a retrying waiter starts a fresh call
+		d.mu.Lock()
+		delete(d.inflight, key)
+		d.mu.Unlock()
+		close(req.done)
 
-	// Clean up
-	d.mu.Lock()
-	delete(d.inflight, key)
-	d.mu.Unlock()
+		return req.response, req.err, false
+	}
+}
 
-	return req.response, req.err
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
 }
 
 // Global request deduplicator
@@ -5,22 +5,78 @@ package cache
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"math"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 	"unicode"
+
+	"github.com/tidwall/gjson"
+)
+
+// SimilarityMethod selects how SemanticCache scores a prompt against indexed entries.
+type SimilarityMethod string
+
+const (
+	// SimilarityJaccard compares the sets of n-grams. This is the default.
+	SimilarityJaccard SimilarityMethod = "jaccard"
+	// SimilarityCosineTFIDF compares n-gram count vectors weighted by inverse document
+	// frequency across the index, so n-grams shared by many prompts (boilerplate system
+	// text, templates) count for less than the parts that distinguish them.
+	SimilarityCosineTFIDF SimilarityMethod = "cosine-tfidf"
 )
 
+// ParseSimilarityMethod maps a config value to a SimilarityMethod, defaulting to SimilarityJaccard.
+func ParseSimilarityMethod(value string) SimilarityMethod {
+	switch method := SimilarityMethod(strings.ToLower(strings.TrimSpace(value))); method {
+	case SimilarityCosineTFIDF:
+		return method
+	default:
+		return SimilarityJaccard
+	}
+}
+
+// TokenMode selects the unit SemanticCache builds n-grams from.
+type TokenMode string
+
+const (
+	// TokenModeChar builds n-grams from character windows. This is the default.
+	TokenModeChar TokenMode = "char"
+	// TokenModeWord builds n-grams from whitespace-separated words, so a single substituted
+	// word changes every n-gram that covers it instead of only a few characters.
+	TokenModeWord TokenMode = "word"
+)
+
+// ParseTokenMode maps a config value to a TokenMode, defaulting to TokenModeChar.
+func ParseTokenMode(value string) TokenMode {
+	switch mode := TokenMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case TokenModeWord:
+		return mode
+	default:
+		return TokenModeChar
+	}
+}
+
 // SemanticCache provides caching based on prompt similarity rather than exact match.
 // It uses normalized text hashing and n-gram similarity for cache lookups.
 type SemanticCache struct {
-	mu       sync.RWMutex
-	cache    *LRUCache
-	index    map[string][]semanticEntry // normalized hash -> list of similar entries
-	config   SemanticCacheConfig
-	stopCh   chan struct{}
+	mu     sync.RWMutex
+	cache  *LRUCache
+	index  map[string][]semanticEntry // normalized hash -> list of similar entries
+	config SemanticCacheConfig
+	stopCh chan struct{}
+
+	// docFreq counts the indexed entries containing each n-gram; docCount is the number
+	// of indexed entries. Both feed the IDF weights used by SimilarityCosineTFIDF.
+	docFreq  map[string]int
+	docCount int
+
+	// onEvict and the index removals pending its call; both guarded by mu.
+	onEvict EvictCallback
+	pending []evictionEvent
 
 	// Metrics
 	semanticHits   uint64
@@ -31,7 +87,7 @@ type SemanticCache struct {
 type semanticEntry struct {
 	key           string
 	normalizedKey string
-	ngrams        map[string]struct{}
+	ngrams        map[string]int // n-gram -> occurrences
 	expiresAt     time.Time
 }
 
@@ -45,12 +101,21 @@ type SemanticCacheConfig struct {
 	SimilarityThreshold float64
 	// NGramSize is the size of n-grams for similarity calculation (default: 3)
 	NGramSize int
+	// TokenMode builds n-grams from characters or words (default: char)
+	TokenMode TokenMode
 	// NormalizeCase lowercases text for comparison
 	NormalizeCase bool
 	// NormalizeWhitespace collapses whitespace for comparison
 	NormalizeWhitespace bool
 	// StripPunctuation removes punctuation for comparison
 	StripPunctuation bool
+	// EvictionPolicy selects how entries are evicted at capacity (default: LRU)
+	EvictionPolicy EvictionPolicy
+	// SimilarityMethod selects how candidates are scored (default: Jaccard)
+	SimilarityMethod SimilarityMethod
+	// SkipWhenDeterministic limits GetForRequest to exact matches for requests with a zero
+	// temperature or a fixed seed, whose callers expect the answer to that exact prompt
+	SkipWhenDeterministic bool
 }
 
 // DefaultSemanticCacheConfig returns sensible defaults.
@@ -61,8 +126,9 @@ func DefaultSemanticCacheConfig() SemanticCacheConfig {
 		SimilarityThreshold: 0.85, // 85% similarity required
 		NGramSize:           3,
 		NormalizeCase:       true,
-		NormalizeWhitespace: true,
-		StripPunctuation:    false,
+		NormalizeWhitespace:   true,
+		StripPunctuation:      false,
+		SkipWhenDeterministic: true,
 	}
 }
 
@@ -80,12 +146,15 @@ func NewSemanticCache(cfg SemanticCacheConfig) *SemanticCache {
 	if cfg.NGramSize <= 0 {
 		cfg.NGramSize = 3
 	}
+	cfg.SimilarityMethod = ParseSimilarityMethod(string(cfg.SimilarityMethod))
+	cfg.TokenMode = ParseTokenMode(string(cfg.TokenMode))
 
 	sc := &SemanticCache{
-		cache:  NewLRUCache(cfg.MaxEntries, time.Duration(cfg.TTLSeconds)*time.Second),
-		index:  make(map[string][]semanticEntry),
-		config: cfg,
-		stopCh: make(chan struct{}),
+		cache:   NewLRUCacheWithPolicy(cfg.MaxEntries, time.Duration(cfg.TTLSeconds)*time.Second, cfg.EvictionPolicy),
+		index:   make(map[string][]semanticEntry),
+		config:  cfg,
+		stopCh:  make(chan struct{}),
+		docFreq: make(map[string]int),
 	}
 	go sc.startCleanup()
 	return sc
@@ -94,6 +163,13 @@ func NewSemanticCache(cfg SemanticCacheConfig) *SemanticCache {
 // Get retrieves a cached response based on semantic similarity.
 // Returns the response and a boolean indicating if a match was found.
 func (sc *SemanticCache) Get(model, prompt string) ([]byte, bool) {
+	start := time.Now()
+	data, ok := sc.get(model, prompt)
+	recordLookup(ok, start)
+	return data, ok
+}
+
+func (sc *SemanticCache) get(model, prompt string) ([]byte, bool) {
 	sc.mu.RLock()
 	defer sc.mu.RUnlock()
 
@@ -116,7 +192,7 @@ func (sc *SemanticCache) Get(model, prompt string) ([]byte, bool) {
 		if now.After(entry.expiresAt) {
 			continue
 		}
-		similarity := sc.jaccardSimilarity(promptNgrams, entry.ngrams)
+		similarity := sc.similarity(promptNgrams, entry.ngrams)
 		if similarity >= sc.config.SimilarityThreshold && similarity > bestSimilarity {
 			bestSimilarity = similarity
 			bestMatch = entry
@@ -135,6 +211,43 @@ func (sc *SemanticCache) Get(model, prompt string) ([]byte, bool) {
 	return nil, false
 }
 
+// GetForRequest looks up the response for prompt on behalf of the request body payload.
+// With SkipWhenDeterministic set, a deterministic request (see IsDeterministicRequest) only
+// matches a response cached for exactly the same prompt; otherwise it behaves like Get.
+func (sc *SemanticCache) GetForRequest(model, prompt string, payload []byte) ([]byte, bool) {
+	if !sc.config.SkipWhenDeterministic || !IsDeterministicRequest(payload) {
+		return sc.Get(model, prompt)
+	}
+	start := time.Now()
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if data := sc.cache.Get(HashKey(model, prompt)); data != nil {
+		sc.semanticHits++
+		recordLookup(true, start)
+		return data, true
+	}
+	sc.semanticMisses++
+	recordLookup(false, start)
+	return nil, false
+}
+
+// IsDeterministicRequest reports whether a request body asks for reproducible output: a
+// temperature of 0 or a fixed seed, in OpenAI or Gemini form.
+func IsDeterministicRequest(payload []byte) bool {
+	root := gjson.ParseBytes(payload)
+	for _, path := range []string{"temperature", "generationConfig.temperature"} {
+		if t := root.Get(path); t.Exists() && t.Type == gjson.Number && t.Float() == 0 {
+			return true
+		}
+	}
+	for _, path := range []string{"seed", "generationConfig.seed"} {
+		if seed := root.Get(path); seed.Exists() && seed.Type != gjson.Null {
+			return true
+		}
+	}
+	return false
+}
+
 // Set stores a response in the semantic cache.
 func (sc *SemanticCache) Set(model, prompt string, response []byte) {
 	if len(response) == 0 {
@@ -142,7 +255,7 @@ func (sc *SemanticCache) Set(model, prompt string, response []byte) {
 	}
 
 	sc.mu.Lock()
-	defer sc.mu.Unlock()
+	defer sc.unlockAndNotify()
 
 	normalizedPrompt := sc.normalize(prompt)
 	bucket := sc.bucketKey(normalizedPrompt)
@@ -159,15 +272,7 @@ func (sc *SemanticCache) Set(model, prompt string, response []byte) {
 		expiresAt:     time.Now().Add(time.Duration(sc.config.TTLSeconds) * time.Second),
 	}
 
-	entries := sc.index[bucket]
-	// Check if we already have this exact entry
-	for i := range entries {
-		if entries[i].key == prompt {
-			entries[i] = entry
-			return
-		}
-	}
-	sc.index[bucket] = append(entries, entry)
+	sc.addEntry(bucket, entry)
 }
 
 // SetWithTTL stores a response with a custom TTL.
@@ -177,7 +282,7 @@ func (sc *SemanticCache) SetWithTTL(model, prompt string, response []byte, ttl t
 	}
 
 	sc.mu.Lock()
-	defer sc.mu.Unlock()
+	defer sc.unlockAndNotify()
 
 	normalizedPrompt := sc.normalize(prompt)
 	bucket := sc.bucketKey(normalizedPrompt)
@@ -195,9 +300,19 @@ func (sc *SemanticCache) SetWithTTL(model, prompt string, response []byte, ttl t
 		expiresAt:     time.Now().Add(ttl),
 	}
 
+	sc.addEntry(bucket, entry)
+}
+
+// addEntry indexes entry under bucket, replacing an existing entry for the same prompt.
+// Callers must hold sc.mu.
+func (sc *SemanticCache) addEntry(bucket string, entry semanticEntry) {
 	entries := sc.index[bucket]
+	sc.countDocument(entry.ngrams, 1)
+	// Check if we already have this exact entry
 	for i := range entries {
-		if entries[i].key == prompt {
+		if entries[i].key == entry.key {
+			sc.countDocument(entries[i].ngrams, -1)
+			sc.queueEviction(entries[i].key, EvictReasonManual)
 			entries[i] = entry
 			return
 		}
@@ -205,6 +320,19 @@ func (sc *SemanticCache) SetWithTTL(model, prompt string, response []byte, ttl t
 	sc.index[bucket] = append(entries, entry)
 }
 
+// countDocument adds (delta 1) or removes (delta -1) one entry's n-grams from the
+// document frequency table. Callers must hold sc.mu.
+func (sc *SemanticCache) countDocument(ngrams map[string]int, delta int) {
+	sc.docCount += delta
+	for gram := range ngrams {
+		if n := sc.docFreq[gram] + delta; n > 0 {
+			sc.docFreq[gram] = n
+		} else {
+			delete(sc.docFreq, gram)
+		}
+	}
+}
+
 // normalize applies normalization rules to a prompt.
 func (sc *SemanticCache) normalize(text string) string {
 	if sc.config.NormalizeCase {
@@ -226,22 +354,49 @@ func (sc *SemanticCache) bucketKey(normalizedText string) string {
 	return hex.EncodeToString(h[:])[:8]
 }
 
-// generateNgrams creates n-grams from normalized text.
-func (sc *SemanticCache) generateNgrams(text string) map[string]struct{} {
-	ngrams := make(map[string]struct{})
+// generateNgrams counts the n-grams in normalized text, tokenizing by the configured TokenMode.
+func (sc *SemanticCache) generateNgrams(text string) map[string]int {
+	if sc.config.TokenMode == TokenModeWord {
+		return sc.generateWordNgrams(text)
+	}
+	ngrams := make(map[string]int)
 	n := sc.config.NGramSize
 	if len(text) < n {
-		ngrams[text] = struct{}{}
+		ngrams[text] = 1
 		return ngrams
 	}
 	for i := 0; i <= len(text)-n; i++ {
-		ngrams[text[i:i+n]] = struct{}{}
+		ngrams[text[i:i+n]]++
+	}
+	return ngrams
+}
+
+// generateWordNgrams counts runs of NGramSize whitespace-separated words. Text with fewer
+// words than NGramSize yields a single n-gram of all its words.
+func (sc *SemanticCache) generateWordNgrams(text string) map[string]int {
+	ngrams := make(map[string]int)
+	words := strings.Fields(text)
+	n := sc.config.NGramSize
+	if len(words) < n {
+		ngrams[strings.Join(words, " ")] = 1
+		return ngrams
+	}
+	for i := 0; i <= len(words)-n; i++ {
+		ngrams[strings.Join(words[i:i+n], " ")]++
 	}
 	return ngrams
 }
 
+// similarity scores two n-gram sets with the configured method. Callers must hold sc.mu.
+func (sc *SemanticCache) similarity(a, b map[string]int) float64 {
+	if sc.config.SimilarityMethod == SimilarityCosineTFIDF {
+		return sc.cosineTFIDFSimilarity(a, b)
+	}
+	return sc.jaccardSimilarity(a, b)
+}
+
 // jaccardSimilarity calculates the Jaccard similarity between two n-gram sets.
-func (sc *SemanticCache) jaccardSimilarity(a, b map[string]struct{}) float64 {
+func (sc *SemanticCache) jaccardSimilarity(a, b map[string]int) float64 {
 	if len(a) == 0 && len(b) == 0 {
 		return 1.0
 	}
@@ -263,6 +418,40 @@ func (sc *SemanticCache) jaccardSimilarity(a, b map[string]struct{}) float64 {
 	return float64(intersection) / float64(union)
 }
 
+// cosineTFIDFSimilarity calculates the cosine similarity between two n-gram count vectors
+// weighted by smoothed IDF over the current index. Callers must hold sc.mu.
+func (sc *SemanticCache) cosineTFIDFSimilarity(a, b map[string]int) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1.0
+	}
+	if len(a) == 0 || len(b) == 0 {
+		return 0.0
+	}
+
+	var dot, normA, normB float64
+	for gram, count := range a {
+		w := float64(count) * sc.idf(gram)
+		normA += w * w
+		if other, exists := b[gram]; exists {
+			dot += w * float64(other) * sc.idf(gram)
+		}
+	}
+	for gram, count := range b {
+		w := float64(count) * sc.idf(gram)
+		normB += w * w
+	}
+	if normA == 0 || normB == 0 {
+		return 0.0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// idf returns the smoothed inverse document frequency of gram, ln((1+N)/(1+df)) + 1,
+// which stays positive so n-grams present in every entry still contribute a little.
+func (sc *SemanticCache) idf(gram string) float64 {
+	return math.Log(float64(1+sc.docCount)/float64(1+sc.docFreq[gram])) + 1
+}
+
 // Stats returns semantic cache statistics.
 func (sc *SemanticCache) Stats() SemanticCacheStats {
 	sc.mu.RLock()
@@ -281,21 +470,63 @@ func (sc *SemanticCache) Stats() SemanticCacheStats {
 	}
 
 	return SemanticCacheStats{
-		CacheStats:      lruStats,
-		SemanticHits:    sc.semanticHits,
-		SemanticMisses:  sc.semanticMisses,
-		SemanticHitRate: hitRate,
-		IndexSize:       indexSize,
-		BucketCount:     len(sc.index),
+		CacheStats:       lruStats,
+		SemanticHits:     sc.semanticHits,
+		SemanticMisses:   sc.semanticMisses,
+		SemanticHitRate:  hitRate,
+		IndexSize:        indexSize,
+		BucketCount:      len(sc.index),
+		SimilarityMethod: string(sc.config.SimilarityMethod),
 	}
 }
 
+// ResetStats resets the semantic and underlying LRU hit/miss counters.
+func (sc *SemanticCache) ResetStats() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.semanticHits = 0
+	sc.semanticMisses = 0
+	sc.cache.ResetStats()
+}
+
 // Clear removes all entries from the cache.
 func (sc *SemanticCache) Clear() {
 	sc.mu.Lock()
-	defer sc.mu.Unlock()
+	defer sc.unlockAndNotify()
+	for _, entries := range sc.index {
+		for _, entry := range entries {
+			sc.queueEviction(entry.key, EvictReasonManual)
+		}
+	}
 	sc.cache.Clear()
 	sc.index = make(map[string][]semanticEntry)
+	sc.docFreq = make(map[string]int)
+	sc.docCount = 0
+}
+
+// SetOnEvict registers fn to be called with the prompt of every index entry that expires,
+// is replaced by a Set for the same prompt, or is cleared. Pass nil to stop observing.
+func (sc *SemanticCache) SetOnEvict(fn EvictCallback) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.onEvict = fn
+}
+
+// queueEviction holds a removal for the EvictCallback. Callers must hold sc.mu.
+func (sc *SemanticCache) queueEviction(prompt, reason string) {
+	if sc.onEvict != nil {
+		sc.pending = append(sc.pending, evictionEvent{key: prompt, reason: reason})
+	}
+}
+
+// unlockAndNotify releases sc.mu and then reports the removals made while it was held.
+func (sc *SemanticCache) unlockAndNotify() {
+	fn, events := sc.onEvict, sc.pending
+	sc.pending = nil
+	sc.mu.Unlock()
+	for _, event := range events {
+		fn(event.key, event.reason)
+	}
 }
 
 func (sc *SemanticCache) startCleanup() {
@@ -320,13 +551,16 @@ func (sc *SemanticCache) Close() {
 func (sc *SemanticCache) purgeExpired() {
 	now := time.Now()
 	sc.mu.Lock()
-	defer sc.mu.Unlock()
+	defer sc.unlockAndNotify()
 
 	for bucket, entries := range sc.index {
 		var valid []semanticEntry
 		for _, entry := range entries {
 			if now.Before(entry.expiresAt) {
 				valid = append(valid, entry)
+			} else {
+				sc.countDocument(entry.ngrams, -1)
+				sc.queueEviction(entry.key, EvictReasonTTL)
 			}
 		}
 		if len(valid) == 0 {
@@ -339,12 +573,13 @@ func (sc *SemanticCache) purgeExpired() {
 
 // SemanticCacheStats holds statistics for semantic caching.
 type SemanticCacheStats struct {
-	CacheStats      CacheStats `json:"cache_stats"`
-	SemanticHits    uint64     `json:"semantic_hits"`
-	SemanticMisses  uint64     `json:"semantic_misses"`
-	SemanticHitRate float64    `json:"semantic_hit_rate_percent"`
-	IndexSize       int        `json:"index_size"`
-	BucketCount     int        `json:"bucket_count"`
+	CacheStats       CacheStats `json:"cache_stats"`
+	SemanticHits     uint64     `json:"semantic_hits"`
+	SemanticMisses   uint64     `json:"semantic_misses"`
+	SemanticHitRate  float64    `json:"semantic_hit_rate_percent"`
+	IndexSize        int        `json:"index_size"`
+	BucketCount      int        `json:"bucket_count"`
+	SimilarityMethod string     `json:"similarity_method"`
 }
 
 // normalizeWhitespace collapses multiple whitespace characters to single spaces.
@@ -408,8 +643,11 @@ type CacheKeyConfig struct {
 	IncludeTemperature bool `yaml:"include-temperature" json:"include_temperature"`
 	// IncludeMaxTokens includes max_tokens in cache key
 	IncludeMaxTokens bool `yaml:"include-max-tokens" json:"include_max_tokens"`
-	// IncludeTools includes tools/functions in cache key
+	// IncludeTools includes the tool/function definitions, schemas included, in cache key
 	IncludeTools bool `yaml:"include-tools" json:"include_tools"`
+	// IncludeReasoning includes the reasoning settings (effort, thinking budget or level,
+	// include-thoughts) in cache key
+	IncludeReasoning bool `yaml:"include-reasoning" json:"include_reasoning"`
 	// ExcludeFields lists field names to exclude from cache key
 	ExcludeFields []string `yaml:"exclude-fields" json:"exclude_fields"`
 }
@@ -422,12 +660,15 @@ func DefaultCacheKeyConfig() CacheKeyConfig {
 		IncludeTemperature:  false, // Usually don't cache different temps
 		IncludeMaxTokens:    false,
 		IncludeTools:        true,
+		IncludeReasoning:    true,
 		ExcludeFields:       []string{"stream", "user", "metadata"},
 	}
 }
 
-// GenerateCacheKey creates a cache key based on the configuration.
-func GenerateCacheKey(cfg CacheKeyConfig, model, systemPrompt, userPrompt string, temperature float64, maxTokens int, tools []string) string {
+// GenerateCacheKey creates a cache key based on the configuration. tools holds the raw
+// JSON definition of each tool; see canonicalTools. reasoning describes the request's
+// reasoning settings, as built by RequestCacheKey.
+func GenerateCacheKey(cfg CacheKeyConfig, model, systemPrompt, userPrompt string, temperature float64, maxTokens int, tools []string, reasoning string) string {
 	var parts []string
 
 	if cfg.IncludeModel && model != "" {
@@ -440,16 +681,47 @@ func GenerateCacheKey(cfg CacheKeyConfig, model, systemPrompt, userPrompt string
 		parts = append(parts, "user:"+userPrompt)
 	}
 	if cfg.IncludeTemperature {
-		parts = append(parts, "temp:"+strconv.FormatFloat(temperature, 'f', 3, 64))
+		parts = append(parts, "temp:"+strconv.FormatFloat(temperature, 'f', -1, 64))
 	}
 	if cfg.IncludeMaxTokens && maxTokens > 0 {
 		parts = append(parts, "max:"+strconv.Itoa(maxTokens))
 	}
 	if cfg.IncludeTools && len(tools) > 0 {
-		sort.Strings(tools)
-		parts = append(parts, "tools:"+strings.Join(tools, ","))
+		parts = append(parts, "tools:"+canonicalTools(tools))
+	}
+	if cfg.IncludeReasoning && reasoning != "" {
+		parts = append(parts, "reasoning:"+reasoning)
 	}
 
 	combined := strings.Join(parts, "|")
 	return HashKey(combined)
 }
+
+// canonicalTools returns the tool definitions in a canonical form, so tools that share a
+// name but differ in schema contribute differently to a key while formatting, object key
+// order and the order of the tools do not. A definition that is not JSON is used as is.
+func canonicalTools(tools []string) string {
+	canonical := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		canonical = append(canonical, canonicalJSON(tool))
+	}
+	sort.Strings(canonical)
+	return strings.Join(canonical, ",")
+}
+
+// canonicalJSON returns raw re-encoded with sorted object keys and no insignificant
+// whitespace. A value that is not JSON is returned as is.
+func canonicalJSON(raw string) string {
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return raw
+	}
+	// encoding/json writes object keys sorted and without insignificant whitespace.
+	out, err := json.Marshal(v)
+	if err != nil {
+		return raw
+	}
+	return string(out)
+}
@@ -0,0 +1,283 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+const semanticBoilerplate = "you are a helpful assistant. answer concisely and cite sources where possible. "
+
+func newTestSemanticCache(t *testing.T, method SimilarityMethod) *SemanticCache {
+	t.Helper()
+	cfg := DefaultSemanticCacheConfig()
+	cfg.SimilarityMethod = method
+	sc := NewSemanticCache(cfg)
+	t.Cleanup(sc.Close)
+	return sc
+}
+
+func TestSemanticCache_CosineTFIDFDiscountsSharedBoilerplate(t *testing.T) {
+	scores := make(map[SimilarityMethod]float64)
+	for _, method := range []SimilarityMethod{SimilarityJaccard, SimilarityCosineTFIDF} {
+		sc := newTestSemanticCache(t, method)
+		for i := 0; i < 20; i++ {
+			sc.Set("m", fmt.Sprintf("%squestion number %d about topic %d", semanticBoilerplate, i, i*7), []byte("ok"))
+		}
+		a := sc.generateNgrams(sc.normalize(semanticBoilerplate + "what is the capital of france?"))
+		b := sc.generateNgrams(sc.normalize(semanticBoilerplate + "how do i bake sourdough bread?"))
+		sc.mu.RLock()
+		scores[method] = sc.similarity(a, b)
+		sc.mu.RUnlock()
+	}
+
+	if scores[SimilarityJaccard] < 0.5 {
+		t.Fatalf("expected boilerplate to inflate jaccard similarity, got %.3f", scores[SimilarityJaccard])
+	}
+	if scores[SimilarityCosineTFIDF] >= scores[SimilarityJaccard]-0.1 {
+		t.Fatalf("expected cosine-tfidf (%.3f) well below jaccard (%.3f)", scores[SimilarityCosineTFIDF], scores[SimilarityJaccard])
+	}
+}
+
+func TestSemanticCache_CosineTFIDFMatchesIdenticalPrompt(t *testing.T) {
+	sc := newTestSemanticCache(t, SimilarityCosineTFIDF)
+	prompt := semanticBoilerplate + "summarize the release notes"
+	sc.Set("m", prompt, []byte("cached"))
+
+	data, ok := sc.Get("m", prompt)
+	if !ok || string(data) != "cached" {
+		t.Fatalf("expected hit for identical prompt, got %q, %v", data, ok)
+	}
+	if stats := sc.Stats(); stats.SimilarityMethod != string(SimilarityCosineTFIDF) {
+		t.Fatalf("expected stats to report cosine-tfidf, got %q", stats.SimilarityMethod)
+	}
+}
+
+func TestSemanticCache_DocumentFrequencyTracksIndex(t *testing.T) {
+	sc := newTestSemanticCache(t, SimilarityCosineTFIDF)
+	sc.Set("m", "alpha beta", []byte("1"))
+	sc.Set("m", "alpha beta", []byte("1")) // replacing must not double count
+	sc.SetWithTTL("m", "alpha gamma", []byte("2"), -time.Second)
+
+	if sc.docCount != 2 || sc.docFreq["alp"] != 2 || sc.docFreq["gam"] != 1 {
+		t.Fatalf("unexpected document frequencies after set: count=%d alp=%d gam=%d", sc.docCount, sc.docFreq["alp"], sc.docFreq["gam"])
+	}
+
+	sc.purgeExpired()
+	if sc.docCount != 1 || sc.docFreq["alp"] != 1 {
+		t.Fatalf("unexpected document frequencies after purge: count=%d alp=%d", sc.docCount, sc.docFreq["alp"])
+	}
+	if _, ok := sc.docFreq["gam"]; ok {
+		t.Fatal("expected purged n-grams to be removed from the IDF table")
+	}
+
+	sc.Clear()
+	if sc.docCount != 0 || len(sc.docFreq) != 0 {
+		t.Fatalf("expected Clear to reset the IDF table, got count=%d terms=%d", sc.docCount, len(sc.docFreq))
+	}
+}
+
+func TestSemanticCache_OnEvictReasons(t *testing.T) {
+	sc := newTestSemanticCache(t, SimilarityJaccard)
+	var log evictionLog
+	sc.SetOnEvict(log.record)
+
+	sc.Set("m", "alpha beta", []byte("1"))
+	sc.Set("m", "alpha beta", []byte("2")) // overwrites the indexed entry
+	sc.SetWithTTL("m", "alpha gamma", []byte("3"), -time.Second)
+	sc.purgeExpired()
+	sc.Clear()
+
+	if got, want := log.String(), "[alpha beta:manual alpha gamma:ttl alpha beta:manual]"; got != want {
+		t.Fatalf("events = %s, want %s", got, want)
+	}
+}
+
+func TestParseSimilarityMethod(t *testing.T) {
+	cases := map[string]SimilarityMethod{
+		"":              SimilarityJaccard,
+		"jaccard":       SimilarityJaccard,
+		" Cosine-TFIDF": SimilarityCosineTFIDF,
+		"unknown":       SimilarityJaccard,
+	}
+	for value, want := range cases {
+		if got := ParseSimilarityMethod(value); got != want {
+			t.Errorf("ParseSimilarityMethod(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestSemanticCache_WordModePenalizesWordSubstitution(t *testing.T) {
+	score := func(mode TokenMode, a, b string) float64 {
+		cfg := DefaultSemanticCacheConfig()
+		cfg.NGramSize = 2
+		cfg.TokenMode = mode
+		cfg.StripPunctuation = true
+		sc := NewSemanticCache(cfg)
+		t.Cleanup(sc.Close)
+		return sc.similarity(sc.generateNgrams(sc.normalize(a)), sc.generateNgrams(sc.normalize(b)))
+	}
+
+	a, b := "The  cat sat on the mat.", "the car sat on the mat"
+	charScore := score(TokenModeChar, a, b)
+	wordScore := score(TokenModeWord, a, b)
+	if wordScore >= charScore {
+		t.Fatalf("expected word mode (%.3f) below char mode (%.3f) for a single-word substitution", wordScore, charScore)
+	}
+	if same := score(TokenModeWord, "The  cat sat on the mat.", "the cat sat on the mat"); same != 1 {
+		t.Fatalf("expected normalization to run before word tokenization, got similarity %.3f", same)
+	}
+}
+
+func TestSemanticCache_WordNgrams(t *testing.T) {
+	cfg := DefaultSemanticCacheConfig()
+	cfg.TokenMode = TokenModeWord
+	sc := NewSemanticCache(cfg)
+	t.Cleanup(sc.Close)
+
+	grams := sc.generateNgrams("a b c a b c")
+	if len(grams) != 3 || grams["a b c"] != 2 || grams["b c a"] != 1 || grams["c a b"] != 1 {
+		t.Fatalf("unexpected word n-grams: %v", grams)
+	}
+	if short := sc.generateNgrams("hello world"); len(short) != 1 || short["hello world"] != 1 {
+		t.Fatalf("expected short text to form a single n-gram, got %v", short)
+	}
+}
+
+func TestSemanticCache_DeterministicRequestsOnlyMatchExactly(t *testing.T) {
+	sc := newTestSemanticCache(t, SimilarityJaccard)
+	cached := "what is the capital city of france? please answer briefly."
+	// Normalizes to the same text, so it is a semantic match above any threshold.
+	similar := "What is the   capital city of France? Please answer briefly."
+	sc.Set("m", cached, []byte("paris"))
+
+	if _, ok := sc.GetForRequest("m", similar, []byte(`{"temperature":0.7}`)); !ok {
+		t.Fatal("expected a fuzzy match for a non-deterministic request")
+	}
+	for _, payload := range []string{
+		`{"temperature":0}`,
+		`{"temperature":0.7,"seed":42}`,
+		`{"generationConfig":{"temperature":0.0}}`,
+	} {
+		if _, ok := sc.GetForRequest("m", similar, []byte(payload)); ok {
+			t.Errorf("deterministic request %s returned a fuzzy match", payload)
+		}
+		if data, ok := sc.GetForRequest("m", cached, []byte(payload)); !ok || string(data) != "paris" {
+			t.Errorf("deterministic request %s should still hit the exact prompt", payload)
+		}
+	}
+
+	cfg := DefaultSemanticCacheConfig()
+	cfg.SkipWhenDeterministic = false
+	lenient := NewSemanticCache(cfg)
+	t.Cleanup(lenient.Close)
+	lenient.Set("m", cached, []byte("paris"))
+	if _, ok := lenient.GetForRequest("m", similar, []byte(`{"temperature":0}`)); !ok {
+		t.Fatal("with skip-when-deterministic off, temperature 0 should match fuzzily")
+	}
+}
+
+func TestGenerateCacheKey_EncodesTemperatureAndMaxTokens(t *testing.T) {
+	cfg := DefaultCacheKeyConfig()
+	cfg.IncludeTemperature = true
+	cfg.IncludeMaxTokens = true
+
+	key := func(temperature float64, maxTokens int) string {
+		return GenerateCacheKey(cfg, "m", "", "hello", temperature, maxTokens, nil, "")
+	}
+	for _, pair := range [][2]float64{{0.001, 0.002}, {1.0, 0.5}, {0.0001, 0.0002}} {
+		if key(pair[0], 0) == key(pair[1], 0) {
+			t.Errorf("temperatures %v and %v share a cache key", pair[0], pair[1])
+		}
+	}
+	if key(0.7, 0) != key(0.7, 0) {
+		t.Error("the same temperature must yield a stable key")
+	}
+	// Values above the largest Unicode code point once broke the key encoding.
+	if key(0.7, 0x110000) == key(0.7, 0x110001) {
+		t.Error("large max_tokens values share a cache key")
+	}
+	if key(0.7, 1000) == key(0.7, 1001) {
+		t.Error("max_tokens 1000 and 1001 share a cache key")
+	}
+}
+
+func TestGenerateCacheKey_HashesToolDefinitions(t *testing.T) {
+	cfg := DefaultCacheKeyConfig()
+	key := func(tools ...string) string {
+		return GenerateCacheKey(cfg, "m", "", "hello", 0, 0, tools, "")
+	}
+
+	byCity := `{"type":"function","function":{"name":"get_weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}}`
+	byCoords := `{"type":"function","function":{"name":"get_weather","parameters":{"type":"object","properties":{"lat":{"type":"number"},"lon":{"type":"number"}}}}}`
+	if key(byCity) == key(byCoords) {
+		t.Fatal("same-named tools with different schemas share a cache key")
+	}
+
+	reformatted := `{ "function": {"parameters": {"properties": {"city": {"type": "string"}}, "type": "object"}, "name": "get_weather"}, "type": "function" }`
+	if key(byCity) != key(reformatted) {
+		t.Fatal("formatting and key order of a tool definition must not change the key")
+	}
+	if key(byCity, byCoords) != key(byCoords, byCity) {
+		t.Fatal("the order of the tools must not change the key")
+	}
+
+	cfg.IncludeTools = false
+	if key(byCity) != key(byCoords) {
+		t.Fatal("tools must not change the key while include-tools is off")
+	}
+}
+
+func TestRequestCacheKey_DistinguishesToolSchemas(t *testing.T) {
+	body := func(param string) []byte {
+		return []byte(`{"model":"m","messages":[{"role":"user","content":"hi"}],"tools":[{"type":"function","function":{"name":"lookup","parameters":{"type":"object","properties":{"` + param + `":{"type":"string"}}}}}]}`)
+	}
+	cfg := DefaultCacheKeyConfig()
+	if RequestCacheKey(cfg, "m", body("id")) == RequestCacheKey(cfg, "m", body("email")) {
+		t.Fatal("requests whose same-named tools differ in schema share a cache key")
+	}
+}
+
+func TestRequestCacheKey_DistinguishesThinkingLevels(t *testing.T) {
+	cfg := DefaultCacheKeyConfig()
+	for name, pair := range map[string][2]string{
+		"openai effort": {
+			`{"model":"m","reasoning_effort":"low","messages":[{"role":"user","content":"hi"}]}`,
+			`{"model":"m","reasoning_effort":"high","messages":[{"role":"user","content":"hi"}]}`,
+		},
+		"responses effort": {
+			`{"model":"m","reasoning":{"effort":"low"},"input":"hi"}`,
+			`{"model":"m","reasoning":{"effort":"high"},"input":"hi"}`,
+		},
+		"claude budget": {
+			`{"model":"m","thinking":{"type":"enabled","budget_tokens":1024},"messages":[{"role":"user","content":"hi"}]}`,
+			`{"model":"m","thinking":{"type":"enabled","budget_tokens":8192},"messages":[{"role":"user","content":"hi"}]}`,
+		},
+		"gemini level": {
+			`{"contents":[{"role":"user","parts":[{"text":"hi"}]}],"generationConfig":{"thinkingConfig":{"thinkingLevel":"low"}}}`,
+			`{"contents":[{"role":"user","parts":[{"text":"hi"}]}],"generationConfig":{"thinkingConfig":{"thinkingLevel":"high"}}}`,
+		},
+		"gemini thoughts": {
+			`{"contents":[{"role":"user","parts":[{"text":"hi"}]}],"generationConfig":{"thinkingConfig":{"includeThoughts":true}}}`,
+			`{"contents":[{"role":"user","parts":[{"text":"hi"}]}],"generationConfig":{"thinkingConfig":{"includeThoughts":false}}}`,
+		},
+	} {
+		if RequestCacheKey(cfg, "m", []byte(pair[0])) == RequestCacheKey(cfg, "m", []byte(pair[1])) {
+			t.Errorf("%s: requests differing only in reasoning settings share a cache key", name)
+		}
+	}
+
+	// Formatting of the reasoning settings does not matter.
+	a := `{"model":"m","thinking":{"type":"enabled","budget_tokens":1024},"messages":[]}`
+	b := `{"model":"m","thinking":{ "budget_tokens":1024, "type":"enabled" },"messages":[]}`
+	if RequestCacheKey(cfg, "m", []byte(a)) != RequestCacheKey(cfg, "m", []byte(b)) {
+		t.Error("equivalent thinking settings yield different cache keys")
+	}
+
+	cfg.IncludeReasoning = false
+	low := `{"model":"m","reasoning_effort":"low","messages":[{"role":"user","content":"hi"}]}`
+	high := `{"model":"m","reasoning_effort":"high","messages":[{"role":"user","content":"hi"}]}`
+	if RequestCacheKey(cfg, "m", []byte(low)) != RequestCacheKey(cfg, "m", []byte(high)) {
+		t.Error("reasoning settings must not change the key while include-reasoning is off")
+	}
+}
@@ -4,6 +4,7 @@ package cache
 
 import (
 	"bytes"
+	"context"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,6 +17,7 @@ type StreamingCache struct {
 	cache    map[string]*streamingEntry
 	capacity int
 	ttl      time.Duration
+	config   StreamingCacheConfig
 	stopCh   chan struct{}
 
 	// Metrics (use atomic operations for thread-safe access)
@@ -76,34 +78,50 @@ func NewStreamingCache(cfg StreamingCacheConfig) *StreamingCache {
 		cache:    make(map[string]*streamingEntry),
 		capacity: cfg.MaxEntries,
 		ttl:      time.Duration(cfg.TTLSeconds) * time.Second,
+		config:   cfg,
 		stopCh:   make(chan struct{}),
 	}
 	go sc.startCleanup()
 	return sc
 }
 
-// StreamRecorder records streaming events for caching.
+// StreamRecorder records streaming events for caching. A recording that outgrows its size
+// limits is abandoned, so only complete streams are ever committed.
 type StreamRecorder struct {
-	mu        sync.Mutex
-	key       string
-	events    []StreamEvent
-	lastEvent time.Time
-	totalSize int64
-	maxSize   int64
-	cache     *StreamingCache
-	started   bool
+	mu           sync.Mutex
+	key          string
+	events       []StreamEvent
+	lastEvent    time.Time
+	totalSize    int64
+	maxSize      int64
+	maxEventSize int64
+	overflowed   bool
+	commit       func(events []StreamEvent, totalSize int64)
+	started      bool
 }
 
-// NewStreamRecorder creates a recorder for a streaming response.
+// NewStreamRecorder creates a recorder for a streaming response. maxSize overrides the
+// configured MaxTotalSize when positive; events larger than MaxEventSize abandon the
+// recording.
 func (sc *StreamingCache) NewStreamRecorder(key string, maxSize int64) *StreamRecorder {
+	if maxSize <= 0 {
+		maxSize = sc.config.MaxTotalSize
+	}
+	return newStreamRecorder(key, maxSize, sc.config.MaxEventSize, func(events []StreamEvent, totalSize int64) {
+		sc.set(key, events, totalSize)
+	})
+}
+
+func newStreamRecorder(key string, maxSize, maxEventSize int64, commit func([]StreamEvent, int64)) *StreamRecorder {
 	if maxSize <= 0 {
 		maxSize = 10 * 1024 * 1024 // 10MB default
 	}
 	return &StreamRecorder{
-		key:       key,
-		events:    make([]StreamEvent, 0, 100),
-		maxSize:   maxSize,
-		cache:     sc,
+		key:          key,
+		events:       make([]StreamEvent, 0, 100),
+		maxSize:      maxSize,
+		maxEventSize: maxEventSize,
+		commit:       commit,
 	}
 }
 
@@ -111,6 +129,9 @@ func (sc *StreamingCache) NewStreamRecorder(key string, maxSize int64) *StreamRe
 func (r *StreamRecorder) RecordEvent(data []byte, eventType, id string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	if r.overflowed {
+		return
+	}
 
 	now := time.Now()
 	var delay time.Duration
@@ -121,8 +142,10 @@ func (r *StreamRecorder) RecordEvent(data []byte, eventType, id string) {
 	r.lastEvent = now
 
 	size := int64(len(data))
-	if r.totalSize+size > r.maxSize {
-		// Exceeded max size, stop recording
+	if r.totalSize+size > r.maxSize || (r.maxEventSize > 0 && size > r.maxEventSize) {
+		// A stream missing events must never be replayed, so stop recording for good
+		r.overflowed = true
+		r.events = nil
 		return
 	}
 
@@ -135,9 +158,21 @@ func (r *StreamRecorder) RecordEvent(data []byte, eventType, id string) {
 	r.totalSize += size
 }
 
-// Commit saves the recorded streaming response to cache.
+// Overflowed reports whether the recording was abandoned for exceeding its size limits.
+func (r *StreamRecorder) Overflowed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.overflowed
+}
+
+// Commit saves the recorded streaming response to cache. Nothing is saved when no events
+// were recorded or the recording overflowed.
 func (r *StreamRecorder) Commit() {
 	r.mu.Lock()
+	if r.overflowed {
+		r.mu.Unlock()
+		return
+	}
 	events := make([]StreamEvent, len(r.events))
 	copy(events, r.events)
 	totalSize := r.totalSize
@@ -147,7 +182,7 @@ func (r *StreamRecorder) Commit() {
 		return
 	}
 
-	r.cache.set(r.key, events, totalSize)
+	r.commit(events, totalSize)
 }
 
 // set stores a streaming response in the cache.
@@ -195,10 +230,24 @@ func (sc *StreamingCache) Replay(key string, preserveTimings bool, callback func
 	if !exists {
 		return nil
 	}
+	return replayEvents(context.Background(), events, preserveTimings, callback)
+}
 
+// replayEvents sends events through callback in order, waiting out each recorded delay
+// when preserveTimings is set. It stops early with the context error once ctx is done.
+func replayEvents(ctx context.Context, events []StreamEvent, preserveTimings bool, callback func(event StreamEvent) error) error {
 	for _, event := range events {
 		if preserveTimings && event.Delay > 0 {
-			time.Sleep(event.Delay)
+			timer := time.NewTimer(event.Delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 		if err := callback(event); err != nil {
 			return err
@@ -251,6 +300,12 @@ func (sc *StreamingCache) Stats() StreamingCacheStats {
 	}
 }
 
+// ResetStats resets the hit/miss counters.
+func (sc *StreamingCache) ResetStats() {
+	atomic.StoreUint64(&sc.hits, 0)
+	atomic.StoreUint64(&sc.misses, 0)
+}
+
 func (sc *StreamingCache) evictOldest() {
 	var oldestKey string
 	var oldestTime time.Time
@@ -0,0 +1,33 @@
+package cache
+
+import "testing"
+
+func TestStreamRecorder_AbandonsRecordingOverLimits(t *testing.T) {
+	sc := NewStreamingCache(StreamingCacheConfig{MaxEventSize: 8, MaxTotalSize: 12})
+	defer sc.Close()
+
+	complete := sc.NewStreamRecorder("complete", 0)
+	complete.RecordEvent([]byte("first"), "", "")
+	complete.RecordEvent([]byte("second"), "", "")
+	complete.Commit()
+	if events, ok := sc.Get("complete"); !ok || len(events) != 2 || string(events[1].Data) != "second" {
+		t.Fatalf("Get(complete) = %v, %v, want both recorded events", events, ok)
+	}
+
+	for name, chunks := range map[string][]string{
+		"oversized event": {"a", "larger than eight"},
+		"oversized total": {"eight ch", "eight ch"},
+	} {
+		r := sc.NewStreamRecorder(name, 0)
+		for _, chunk := range chunks {
+			r.RecordEvent([]byte(chunk), "", "")
+		}
+		r.Commit()
+		if !r.Overflowed() {
+			t.Fatalf("%s: recorder did not report overflow", name)
+		}
+		if _, ok := sc.Get(name); ok {
+			t.Fatalf("%s: a truncated stream was cached", name)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Package cache provides caching utilities for the API proxy.
+// This file implements cache warmup from supplied entries or from Redis.
+package cache
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tidwall/sjson"
+)
+
+// maxWarmupErrors caps how many error messages a WarmupReport keeps.
+const maxWarmupErrors = 20
+
+// WarmupEntry is a response to preload into the cache.
+type WarmupEntry struct {
+	Model string `json:"model"`
+	// Prompt is the user message of the chat completion request the response answers.
+	Prompt string `json:"prompt"`
+	// Response is the chat completion body served for that request.
+	Response string `json:"response"`
+}
+
+// Request returns the chat completion request the entry answers: a single user message
+// carrying Prompt, in the compact form clients send.
+func (e WarmupEntry) Request() ([]byte, error) {
+	message, err := sjson.SetBytes([]byte(`{"role":"user"}`), "content", e.Prompt)
+	if err != nil {
+		return nil, err
+	}
+	body, err := sjson.SetBytes([]byte(`{}`), "model", e.Model)
+	if err != nil {
+		return nil, err
+	}
+	return sjson.SetRawBytes(body, "messages", append(append([]byte("["), message...), ']'))
+}
+
+// WarmupKeyFunc returns the key the response to a chat completion request body is cached
+// under for model, or "" when responses are not cached.
+type WarmupKeyFunc func(model string, request []byte) string
+
+// WarmupReport describes the outcome of a warmup.
+type WarmupReport struct {
+	Requested int      `json:"requested"`
+	Loaded    int      `json:"loaded"`
+	Skipped   int      `json:"skipped"`
+	Failed    int      `json:"failed"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+func (r *WarmupReport) fail(format string, args ...any) {
+	r.Failed++
+	if len(r.Errors) < maxWarmupErrors {
+		r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+	}
+}
+
+// ErrHybridUnavailable is returned when a warmup needs the Redis-backed hybrid cache.
+var ErrHybridUnavailable = errors.New("hybrid cache is not available")
+
+// Preload stores each entry in the best available cache, under the key keyFor derives from
+// the entry's request, so the preloaded response answers that request as a live lookup
+// would. Entries missing a model, prompt or response are counted as failed.
+func (cs *CacheSystem) Preload(entries []WarmupEntry, keyFor WarmupKeyFunc) WarmupReport {
+	report := WarmupReport{Requested: len(entries)}
+	for i, entry := range entries {
+		if entry.Model == "" || entry.Prompt == "" || entry.Response == "" {
+			report.fail("entry %d: model, prompt and response are required", i)
+			continue
+		}
+		request, err := entry.Request()
+		if err != nil {
+			report.fail("entry %d: %v", i, err)
+			continue
+		}
+		key := keyFor(entry.Model, request)
+		if key == "" {
+			report.fail("entry %d: response caching is disabled", i)
+			continue
+		}
+		if cs.Hybrid != nil {
+			if err = cs.Hybrid.Set(entry.Model, key, []byte(entry.Response)); err != nil {
+				report.fail("entry %d: %v", i, err)
+				continue
+			}
+		} else {
+			cs.LRU.Set(HashKey(entry.Model, key), []byte(entry.Response))
+		}
+		report.Loaded++
+	}
+	return report
+}
+
+// RehydrateFromRedis copies up to topN of the most recently used keys from Redis into the
+// hybrid cache's local LRU. Keys that have since expired from Redis are counted as skipped.
+// It requires the hybrid cache and the Redis access index.
+func (cs *CacheSystem) RehydrateFromRedis(topN int) (WarmupReport, error) {
+	if cs.Hybrid == nil || cs.Hybrid.redis == nil {
+		return WarmupReport{}, ErrHybridUnavailable
+	}
+	return cs.Hybrid.Rehydrate(topN)
+}
+
+// Rehydrate copies up to topN of the most recently used Redis keys into the local LRU.
+func (h *HybridCache) Rehydrate(topN int) (WarmupReport, error) {
+	refs, err := h.redis.HotKeys(topN)
+	if err != nil {
+		return WarmupReport{}, err
+	}
+	report := WarmupReport{Requested: len(refs)}
+	for _, ref := range refs {
+		data, found := h.redis.get(ref.Model, ref.Key)
+		if !found {
+			report.Skipped++
+			continue
+		}
+		h.local.Set(HashKey(ref.Model, ref.Key), data)
+		report.Loaded++
+	}
+	return report, nil
+}
@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func newWarmupTestSystem(t *testing.T, accessIndexSize int) (*CacheSystem, *fakeRedisClient) {
+	t.Helper()
+	client := newFakeRedisClient()
+	redis := NewRedisCache(client, RedisCacheConfig{KeyPrefix: "shinapi:", AccessIndexSize: accessIndexSize})
+	cs := &CacheSystem{
+		LRU:    NewLRUCache(10, time.Minute),
+		Redis:  redis,
+		Hybrid: NewHybridCache(redis, DefaultHybridCacheConfig()),
+	}
+	return cs, client
+}
+
+func TestPreload_StoresEntriesAndReportsFailures(t *testing.T) {
+	cs, _ := newWarmupTestSystem(t, 0)
+	keyFor := func(model string, request []byte) string {
+		if model == "uncached" {
+			return ""
+		}
+		return RequestCacheKey(DefaultCacheKeyConfig(), model, request)
+	}
+
+	report := cs.Preload([]WarmupEntry{
+		{Model: "gpt-4o", Prompt: "hello", Response: `{"id":"1"}`},
+		{Model: "gpt-4o", Prompt: "", Response: `{"id":"2"}`},
+		{Model: "claude", Prompt: "bye", Response: `{"id":"3"}`},
+		{Model: "uncached", Prompt: "bye", Response: `{"id":"4"}`},
+	}, keyFor)
+
+	if report.Requested != 4 || report.Loaded != 2 || report.Failed != 2 || len(report.Errors) != 2 {
+		t.Fatalf("report = %+v, want 4 requested, 2 loaded, 2 failed", report)
+	}
+	request := []byte(`{"model":"claude","messages":[{"role":"user","content":"bye"}]}`)
+	if data, ok := cs.Get("claude", keyFor("claude", request)); !ok || string(data) != `{"id":"3"}` {
+		t.Fatalf("preloaded entry = %q, %v", data, ok)
+	}
+}
+
+func TestRehydrateFromRedis_LoadsMostRecentlyUsedKeys(t *testing.T) {
+	cs, client := newWarmupTestSystem(t, 2)
+
+	for _, key := range []string{"a", "b", "c"} {
+		if err := cs.Redis.Set("gpt-4o", key, []byte("resp-"+key)); err != nil {
+			t.Fatalf("Set(%s): %v", key, err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	// Reading "a" makes it the most recently used key.
+	if _, ok := cs.Redis.Get("gpt-4o", "a"); !ok {
+		t.Fatal("expected a Redis hit for a")
+	}
+
+	hot, err := cs.Redis.HotKeys(0)
+	if err != nil {
+		t.Fatalf("HotKeys: %v", err)
+	}
+	if len(hot) != 2 || hot[0].Key != "a" || hot[1].Key != "c" {
+		t.Fatalf("hot keys = %+v, want [a c]", hot)
+	}
+
+	// "c" expired from Redis since it was last used.
+	delete(client.data, cs.Redis.makeKey("gpt-4o", "c"))
+
+	report, err := cs.RehydrateFromRedis(10)
+	if err != nil {
+		t.Fatalf("RehydrateFromRedis: %v", err)
+	}
+	if report.Requested != 2 || report.Loaded != 1 || report.Skipped != 1 {
+		t.Fatalf("report = %+v, want 2 requested, 1 loaded, 1 skipped", report)
+	}
+	if data := cs.Hybrid.local.Get(HashKey("gpt-4o", "a")); string(data) != "resp-a" {
+		t.Fatalf("local entry for a = %q", data)
+	}
+}
+
+func TestRehydrateFromRedis_RequiresAccessIndex(t *testing.T) {
+	cs, _ := newWarmupTestSystem(t, 0)
+	if _, err := cs.RehydrateFromRedis(10); err != ErrAccessIndexDisabled {
+		t.Fatalf("err = %v, want ErrAccessIndexDisabled", err)
+	}
+
+	noRedis := &CacheSystem{LRU: NewLRUCache(10, time.Minute)}
+	if _, err := noRedis.RehydrateFromRedis(10); err != ErrHybridUnavailable {
+		t.Fatalf("err = %v, want ErrHybridUnavailable", err)
+	}
+}
+
+func TestHybridLocalHit_QueuesAccessIndexUpdate(t *testing.T) {
+	cs, _ := newWarmupTestSystem(t, 4)
+	cs.Hybrid.local.Set(HashKey("gpt-4o", "local"), []byte("resp-local"))
+
+	for i := 0; i < 3; i++ {
+		if _, ok := cs.Hybrid.Get("gpt-4o", "local"); !ok {
+			t.Fatal("expected a local hit")
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		cs.Redis.accessMu.Lock()
+		flushing := cs.Redis.accessFlushing
+		cs.Redis.accessMu.Unlock()
+		if !flushing {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("access index flush did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	hot, err := cs.Redis.HotKeys(0)
+	if err != nil {
+		t.Fatalf("HotKeys: %v", err)
+	}
+	if len(hot) != 1 || hot[0].Key != "local" {
+		t.Fatalf("hot keys = %+v, want [local]", hot)
+	}
+}
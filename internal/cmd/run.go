@@ -10,11 +10,14 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/alerting"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/scheduler"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy"
 	log "github.com/sirupsen/logrus"
@@ -31,6 +34,9 @@ func initCacheSystem(cfg *config.Config) *cache.CacheSystem {
 		if cacheConfig.RedisAddress == "" {
 			cacheConfig.RedisAddress = "localhost:6379"
 		}
+		cacheConfig.RedisMode = cache.ParseRedisMode(cfg.Redis.Mode)
+		cacheConfig.RedisAddresses = cfg.Redis.Addresses
+		cacheConfig.RedisMasterName = cfg.Redis.MasterName
 		cacheConfig.RedisPassword = cfg.Redis.Password
 		cacheConfig.RedisDatabase = cfg.Redis.Database
 		cacheConfig.RedisKeyPrefix = cfg.Redis.KeyPrefix
@@ -56,6 +62,17 @@ func initCacheSystem(cfg *config.Config) *cache.CacheSystem {
 		if cfg.Redis.MaxRetries > 0 {
 			cacheConfig.RedisMaxRetries = cfg.Redis.MaxRetries
 		}
+		if cfg.Redis.ScanCount > 0 {
+			cacheConfig.RedisScanCount = cfg.Redis.ScanCount
+		}
+		cacheConfig.RedisCompressStreaming = cfg.Redis.CompressStreaming
+		cacheConfig.RedisAccessIndexSize = cfg.Redis.AccessIndexSize
+		for _, mc := range cfg.Cache.ModelConfigs {
+			cacheConfig.ModelConfigs = append(cacheConfig.ModelConfigs, cache.ModelCacheConfig{
+				Model:      mc.Model,
+				TTLSeconds: mc.TTLSeconds,
+			})
+		}
 	}
 
 	// Apply cache config
@@ -66,6 +83,14 @@ func initCacheSystem(cfg *config.Config) *cache.CacheSystem {
 		if cfg.Cache.DefaultTTLSeconds > 0 {
 			cacheConfig.LRUTTLSeconds = cfg.Cache.DefaultTTLSeconds
 		}
+		cacheConfig.EvictionPolicy = cache.ParseEvictionPolicy(cfg.Cache.EvictionPolicy)
+		cacheConfig.NegativeTTLSeconds = cfg.Cache.NegativeTTLSeconds
+		if cfg.Cache.ServeStaleOnError {
+			cacheConfig.StaleGraceSeconds = cfg.Cache.StaleGraceSeconds
+			if cacheConfig.StaleGraceSeconds <= 0 {
+				cacheConfig.StaleGraceSeconds = cache.DefaultStaleGraceSeconds
+			}
+		}
 
 		// Semantic cache
 		if cfg.Cache.SemanticCache.Enabled {
@@ -73,6 +98,11 @@ func initCacheSystem(cfg *config.Config) *cache.CacheSystem {
 			if cfg.Cache.SemanticCache.SimilarityThreshold > 0 {
 				cacheConfig.SemanticSimilarityThreshold = cfg.Cache.SemanticCache.SimilarityThreshold
 			}
+			cacheConfig.SemanticSimilarityMethod = cache.ParseSimilarityMethod(cfg.Cache.SemanticCache.SimilarityMethod)
+			cacheConfig.SemanticTokenMode = cache.ParseTokenMode(cfg.Cache.SemanticCache.TokenMode)
+			if skip := cfg.Cache.SemanticCache.SkipWhenDeterministic; skip != nil {
+				cacheConfig.SemanticSkipWhenDeterministic = *skip
+			}
 		}
 
 		// Streaming cache
@@ -94,6 +124,130 @@ func initCacheSystem(cfg *config.Config) *cache.CacheSystem {
 	return cache.InitCacheSystem(cacheConfig)
 }
 
+// defaultSchedulerDrainTimeout bounds how long shutdown waits for queued requests.
+const defaultSchedulerDrainTimeout = 30 * time.Second
+
+// initScheduler configures the global fair scheduler and starts its workers. When ctx is
+// done the scheduler drains: queued requests still run, bounded by the drain timeout. The
+// returned channel is closed once the drain has finished.
+func initScheduler(ctx context.Context, cfg *config.Config) <-chan struct{} {
+	schedCfg := scheduler.DefaultSchedulerConfig()
+	if cfg.Scheduler.DefaultWeight > 0 {
+		schedCfg.DefaultWeight = cfg.Scheduler.DefaultWeight
+	}
+	if cfg.Scheduler.MaxQueueSize > 0 {
+		schedCfg.MaxQueueSize = cfg.Scheduler.MaxQueueSize
+	}
+	if cfg.Scheduler.MaxConcurrent > 0 {
+		schedCfg.MaxConcurrent = cfg.Scheduler.MaxConcurrent
+	}
+	if cfg.Scheduler.QueueTimeoutSeconds > 0 {
+		schedCfg.QueueTimeout = time.Duration(cfg.Scheduler.QueueTimeoutSeconds) * time.Second
+	}
+	schedCfg.ShortestJobFirst = cfg.Scheduler.ShortestJobFirst
+	schedCfg.Mode = scheduler.ParseSchedulingMode(cfg.Scheduler.Mode)
+	schedCfg.DRRQuantum = cfg.Scheduler.DRRQuantum
+	if cfg.Scheduler.MetricsIntervalSeconds > 0 {
+		schedCfg.MetricsInterval = time.Duration(cfg.Scheduler.MetricsIntervalSeconds) * time.Second
+	}
+	for _, rl := range cfg.Scheduler.RateLimits {
+		schedCfg.RateLimits = append(schedCfg.RateLimits, scheduler.KeyRateLimit{
+			APIKey:            rl.APIKey,
+			RequestsPerMinute: rl.RequestsPerMinute,
+			TokensPerMinute:   rl.TokensPerMinute,
+		})
+	}
+
+	sched := scheduler.InitScheduler(schedCfg)
+	for _, kw := range cfg.Scheduler.APIKeyWeights {
+		if kw.APIKey != "" {
+			sched.SetWeight(kw.APIKey, kw.Weight)
+		}
+	}
+	// Workers outlive ctx so queued requests can finish while draining.
+	sched.Start(context.Background(), schedCfg.MaxConcurrent)
+	log.Infof("Fair scheduler started (workers: %d, mode: %s)", schedCfg.MaxConcurrent, schedCfg.Mode)
+
+	drainTimeout := defaultSchedulerDrainTimeout
+	if cfg.Scheduler.DrainTimeoutSeconds > 0 {
+		drainTimeout = time.Duration(cfg.Scheduler.DrainTimeoutSeconds) * time.Second
+	}
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		<-ctx.Done()
+		drainCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+		defer cancel()
+		if err := sched.Drain(drainCtx); err != nil {
+			log.Warnf("Fair scheduler drain gave up after %s: %v", drainTimeout, err)
+			return
+		}
+		log.Info("Fair scheduler drained")
+	}()
+	return drained
+}
+
+// initAdmission configures the global admission controller bounding upstream calls in flight.
+func initAdmission(cfg *config.Config) {
+	if cfg.Scheduler.MaxGlobalInFlight <= 0 {
+		return
+	}
+	scheduler.InitAdmissionController(scheduler.AdmissionConfig{
+		MaxInFlight: cfg.Scheduler.MaxGlobalInFlight,
+		MaxWait:     time.Duration(cfg.Scheduler.AdmissionWaitMs) * time.Millisecond,
+	})
+	log.Infof("Global admission control enabled (max in flight: %d, wait: %dms)",
+		cfg.Scheduler.MaxGlobalInFlight, cfg.Scheduler.AdmissionWaitMs)
+}
+
+// initRetryBudget configures the global retry budget shared by all retry loops.
+func initRetryBudget(cfg *config.Config) {
+	if cfg.RetryBudget.MaxRetries <= 0 {
+		return
+	}
+	window := time.Minute
+	if cfg.RetryBudget.WindowSeconds > 0 {
+		window = time.Duration(cfg.RetryBudget.WindowSeconds) * time.Second
+	}
+	scheduler.InitRetryBudget(scheduler.RetryBudgetConfig{
+		MaxRetries: cfg.RetryBudget.MaxRetries,
+		Window:     window,
+	})
+	log.Infof("Retry budget enabled (max retries: %d per %s)", cfg.RetryBudget.MaxRetries, window)
+}
+
+// initTracing installs the global tracer described by the observability config. Unset
+// fields keep observability.DefaultTracerConfig values.
+func initTracing(cfg *config.Config) {
+	tc := cfg.Observability.Tracing
+	tracerCfg := observability.DefaultTracerConfig()
+	tracerCfg.Enabled = true
+	tracerCfg.ExporterType = tc.ExporterType
+	tracerCfg.Headers = tc.Headers
+	tracerCfg.Insecure = tc.Insecure
+	if tc.ServiceName != "" {
+		tracerCfg.ServiceName = tc.ServiceName
+	}
+	if tc.ServiceVersion != "" {
+		tracerCfg.ServiceVersion = tc.ServiceVersion
+	}
+	if tc.SamplingRate > 0 {
+		tracerCfg.SamplingRate = tc.SamplingRate
+	}
+	if tc.ExporterEndpoint != "" {
+		tracerCfg.ExporterEndpoint = tc.ExporterEndpoint
+	}
+	if tc.BatchTimeoutMs > 0 {
+		tracerCfg.BatchTimeoutMs = tc.BatchTimeoutMs
+	}
+	if tc.MaxExportBatchSize > 0 {
+		tracerCfg.MaxExportBatchSize = tc.MaxExportBatchSize
+	}
+	observability.InitTracer(tracerCfg)
+	log.Infof("Tracing enabled (exporter: %s, endpoint: %s, sampling rate: %.2f)",
+		tracerCfg.ExporterType, tracerCfg.ExporterEndpoint, tracerCfg.SamplingRate)
+}
+
 // initPerformanceSystem initializes HTTP connection pooling and stream fanout.
 func initPerformanceSystem(cfg *config.Config) {
 	// Configure HTTP connection pool
@@ -125,6 +279,11 @@ func initPerformanceSystem(cfg *config.Config) {
 	if cfg.Performance.StreamFanout.DedupWindowSeconds > 0 {
 		fanoutCfg.DedupWindowSeconds = cfg.Performance.StreamFanout.DedupWindowSeconds
 	}
+	if cfg.Performance.StreamFanout.ResumeWindowSeconds > 0 {
+		fanoutCfg.ResumeWindowSeconds = cfg.Performance.StreamFanout.ResumeWindowSeconds
+	}
+	fanoutCfg.AllowedEventTypes = cfg.Performance.StreamFanout.AllowedEventTypes
+	fanoutCfg.DeniedEventTypes = cfg.Performance.StreamFanout.DeniedEventTypes
 
 	executor.GetStreamFanout().Configure(fanoutCfg)
 	if fanoutCfg.Enabled {
@@ -173,6 +332,19 @@ func StartService(cfg *config.Config, configPath string, localPassword string) {
 		}
 	}
 
+	// Initialize file-based metrics persistence if configured
+	if cfg.MetricsFile.Enabled {
+		if err := usage.InitMetricsFile(cfg.MetricsFile); err != nil {
+			log.Warnf("failed to initialize metrics file: %v", err)
+		} else {
+			defer func() {
+				if mf := usage.GetMetricsFile(); mf != nil {
+					mf.Close()
+				}
+			}()
+		}
+	}
+
 	// Initialize performance optimizations (HTTP/2 pooling, stream fanout)
 	initPerformanceSystem(cfg)
 	defer executor.GetHTTPPool().CloseIdleConnections()
@@ -201,6 +373,41 @@ func StartService(cfg *config.Config, configPath string, localPassword string) {
 		return
 	}
 
+	initAdmission(cfg)
+	initRetryBudget(cfg)
+
+	// Start fair scheduler workers if configured
+	if cfg.Scheduler.Enabled {
+		schedCtx, stopScheduler := context.WithCancel(runCtx)
+		drained := initScheduler(schedCtx, cfg)
+		defer func() {
+			stopScheduler()
+			<-drained
+		}()
+	}
+
+	// Export traces if configured
+	if cfg.Observability.Tracing.Enabled {
+		initTracing(cfg)
+		defer func() {
+			ctxShutdown, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancelShutdown()
+			if errShutdown := observability.ShutdownTracer(ctxShutdown); errShutdown != nil {
+				log.Warnf("failed to flush traces: %v", errShutdown)
+			}
+		}()
+	}
+
+	// Start alert rule evaluation if configured
+	if cfg.Observability.Alerting.Enabled {
+		alerts, errAlerts := alerting.Init(cfg.Observability.Alerting)
+		if errAlerts != nil {
+			log.Warnf("failed to initialize alerting: %v", errAlerts)
+		} else {
+			go alerts.Run(runCtx)
+		}
+	}
+
 	err = service.Run(runCtx)
 	if err != nil && !errors.Is(err, context.Canceled) {
 		log.Errorf("proxy service exited with error: %v", err)
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/scheduler"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/openai"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+// okExecutor answers every chat request with a fixed completion.
+type okExecutor struct{}
+
+func (okExecutor) Identifier() string { return "codex" }
+
+func (okExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{Payload: []byte(`{"object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`)}, nil
+}
+
+func (okExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (okExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (okExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (okExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func TestInitScheduler_BatchRunsThroughStartedSchedulerAndDrainsOnShutdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{}
+	cfg.Scheduler.Enabled = true
+	cfg.Scheduler.MaxConcurrent = 2
+	cfg.Scheduler.DrainTimeoutSeconds = 5
+
+	ctx, shutdown := context.WithCancel(context.Background())
+	defer shutdown()
+	drained := initScheduler(ctx, cfg)
+	sched := scheduler.GetScheduler()
+	if !sched.Running() {
+		t.Fatal("scheduler workers are not running after initScheduler")
+	}
+	before := sched.Stats().Metrics.TotalEnqueued
+
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(okExecutor{})
+	auth := &coreauth.Auth{ID: "run-batch-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "run-batch-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+	h := openai.NewOpenAIAPIHandler(handlers.NewBaseAPIHandlers(&cfg.SDKConfig, manager))
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	body := `[{"model":"run-batch-model","messages":[{"role":"user","content":"hi"}]},{"model":"run-batch-model","messages":[{"role":"user","content":"there"}]}]`
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/batch", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	h.Batch(c)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	for i, item := range gjson.Get(rec.Body.String(), "data").Array() {
+		if item.Get("status").Int() != http.StatusOK {
+			t.Errorf("item %d = %s", i, item.Raw)
+		}
+	}
+	if got := sched.Stats().Metrics.TotalEnqueued - before; got != 2 {
+		t.Fatalf("scheduled %d batch items, want 2", got)
+	}
+
+	shutdown()
+	select {
+	case <-drained:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduler did not drain after shutdown")
+	}
+	if sched.Running() {
+		t.Fatal("scheduler workers still running after the drain")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"strings"
 	"syscall"
@@ -64,6 +65,8 @@ type Config struct {
 	RequestRetry int `yaml:"request-retry" json:"request-retry"`
 	// MaxRetryInterval defines the maximum wait time in seconds before retrying a cooled-down credential.
 	MaxRetryInterval int `yaml:"max-retry-interval" json:"max-retry-interval"`
+	// RetryBudget caps retries across all requests to avoid amplifying load on a failing upstream.
+	RetryBudget RetryBudgetConfig `yaml:"retry-budget,omitempty" json:"retry-budget,omitempty"`
 
 	// QuotaExceeded defines the behavior when a quota is exceeded.
 	QuotaExceeded QuotaExceeded `yaml:"quota-exceeded" json:"quota-exceeded"`
@@ -107,6 +110,17 @@ type Config struct {
 	// gemini-api-key, codex-api-key, claude-api-key, openai-compatibility, vertex-api-key, and ampcode.
 	OAuthModelMappings map[string][]ModelNameMapping `yaml:"oauth-model-mappings,omitempty" json:"oauth-model-mappings,omitempty"`
 
+	// ProviderBaseURLs overrides the built-in upstream endpoint per provider
+	// (claude, codex, gemini, gemini-cli, vertex, antigravity, qwen, iflow), e.g. to reach
+	// a regional endpoint or a compatible gateway. A credential's own base-url still wins.
+	ProviderBaseURLs map[string]string `yaml:"provider-base-urls,omitempty" json:"provider-base-urls,omitempty"`
+
+	// InboundHeaderPolicy controls which client request headers may reach upstream providers.
+	InboundHeaderPolicy InboundHeaderPolicy `yaml:"inbound-header-policy,omitempty" json:"inbound-header-policy,omitempty"`
+
+	// UpstreamHeaders are set on every upstream provider request, overriding executor defaults.
+	UpstreamHeaders map[string]string `yaml:"upstream-headers,omitempty" json:"upstream-headers,omitempty"`
+
 	// Payload defines default and override rules for provider payload parameters.
 	Payload PayloadConfig `yaml:"payload" json:"payload"`
 
@@ -146,6 +160,19 @@ type QuotaExceeded struct {
 	SwitchPreviewModel bool `yaml:"switch-preview-model" json:"switch-preview-model"`
 }
 
+// RetryBudgetConfig configures the process-wide retry budget. Every retry, whether of a
+// cooled-down credential, of another credential or fallback model after a failure, or of a
+// transient upstream error, spends one retry from the budget, which refills at MaxRetries
+// per window. Once it is spent, failing requests return their error at once instead of
+// retrying.
+type RetryBudgetConfig struct {
+	// MaxRetries is the number of retries allowed per window; 0 disables the budget.
+	MaxRetries int `yaml:"max-retries,omitempty" json:"max-retries,omitempty"`
+
+	// WindowSeconds is the period over which MaxRetries refills (default 60).
+	WindowSeconds int `yaml:"window-seconds,omitempty" json:"window-seconds,omitempty"`
+}
+
 // RoutingConfig configures how credentials are selected for requests.
 type RoutingConfig struct {
 	// Strategy selects the credential selection strategy.
@@ -572,6 +599,14 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	// Normalize global OAuth model name mappings.
 	cfg.SanitizeOAuthModelMappings()
 
+	// Validate per-provider upstream base-URL overrides.
+	if err = cfg.SanitizeProviderBaseURLs(); err != nil {
+		return nil, err
+	}
+
+	// Normalize inbound header policy patterns and fixed upstream headers.
+	cfg.SanitizeHeaderPolicy()
+
 	if cfg.legacyMigrationPending {
 		fmt.Println("Detected legacy configuration keys, attempting to persist the normalized config...")
 		if !optional && configFile != "" {
@@ -588,6 +623,71 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	return &cfg, nil
 }
 
+// SanitizeProviderBaseURLs normalizes provider keys to lower-case, trims trailing slashes,
+// and rejects overrides that are not absolute http(s) URLs.
+func (cfg *Config) SanitizeProviderBaseURLs() error {
+	if cfg == nil || len(cfg.ProviderBaseURLs) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(cfg.ProviderBaseURLs))
+	for rawProvider, rawURL := range cfg.ProviderBaseURLs {
+		provider := strings.ToLower(strings.TrimSpace(rawProvider))
+		base := strings.TrimRight(strings.TrimSpace(rawURL), "/")
+		if provider == "" || base == "" {
+			continue
+		}
+		parsed, errParse := url.Parse(base)
+		if errParse != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("invalid provider-base-urls entry for %q: %q is not an absolute http(s) URL", provider, rawURL)
+		}
+		out[provider] = base
+	}
+	cfg.ProviderBaseURLs = out
+	return nil
+}
+
+// ProviderBaseURL returns the configured base-URL override for a provider, or "" when none is set.
+func (cfg *Config) ProviderBaseURL(provider string) string {
+	if cfg == nil || len(cfg.ProviderBaseURLs) == 0 {
+		return ""
+	}
+	return cfg.ProviderBaseURLs[strings.ToLower(strings.TrimSpace(provider))]
+}
+
+// InboundHeaderPolicy filters the client headers that executors pass through upstream.
+// Patterns are case-insensitive header names; a trailing "*" matches a prefix.
+type InboundHeaderPolicy struct {
+	// Allow lists headers clients may pass through. When set, passed-through headers
+	// not on the list are stripped and listed headers are forwarded even if the
+	// executor would not copy them itself (e.g. X-Request-ID).
+	Allow []string `yaml:"allow,omitempty" json:"allow,omitempty"`
+
+	// Deny lists headers that are never passed through. Deny wins over Allow.
+	Deny []string `yaml:"deny,omitempty" json:"deny,omitempty"`
+}
+
+// SanitizeHeaderPolicy trims header policy patterns and fixed upstream headers, dropping blank entries.
+func (cfg *Config) SanitizeHeaderPolicy() {
+	if cfg == nil {
+		return
+	}
+	clean := func(patterns []string) []string {
+		out := patterns[:0]
+		for _, p := range patterns {
+			if p = strings.TrimSpace(p); p != "" {
+				out = append(out, p)
+			}
+		}
+		if len(out) == 0 {
+			return nil
+		}
+		return out
+	}
+	cfg.InboundHeaderPolicy.Allow = clean(cfg.InboundHeaderPolicy.Allow)
+	cfg.InboundHeaderPolicy.Deny = clean(cfg.InboundHeaderPolicy.Deny)
+	cfg.UpstreamHeaders = NormalizeHeaders(cfg.UpstreamHeaders)
+}
+
 // SanitizeOAuthModelMappings normalizes and deduplicates global OAuth model name mappings.
 // It trims whitespace, normalizes channel keys to lower-case, drops empty entries,
 // allows multiple aliases per upstream name, and ensures aliases are unique within each channel.
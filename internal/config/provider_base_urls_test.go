@@ -0,0 +1,43 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeProviderBaseURLs_NormalizesEntries(t *testing.T) {
+	cfg := &Config{ProviderBaseURLs: map[string]string{" Codex ": " https://eu.example.com/codex/ ", "qwen": ""}}
+
+	if err := cfg.SanitizeProviderBaseURLs(); err != nil {
+		t.Fatalf("SanitizeProviderBaseURLs: %v", err)
+	}
+	if got := cfg.ProviderBaseURL("CODEX"); got != "https://eu.example.com/codex" {
+		t.Fatalf("codex base = %q, want https://eu.example.com/codex", got)
+	}
+	if _, ok := cfg.ProviderBaseURLs["qwen"]; ok {
+		t.Fatalf("empty override should be dropped: %v", cfg.ProviderBaseURLs)
+	}
+}
+
+func TestSanitizeProviderBaseURLs_RejectsInvalidURL(t *testing.T) {
+	for _, raw := range []string{"eu.example.com", "ftp://example.com", "https://", "://bad"} {
+		cfg := &Config{ProviderBaseURLs: map[string]string{"claude": raw}}
+		if err := cfg.SanitizeProviderBaseURLs(); err == nil {
+			t.Errorf("expected %q to be rejected", raw)
+		}
+	}
+}
+
+func TestLoadConfig_RejectsInvalidProviderBaseURL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("provider-base-urls:\n  claude: not-a-url\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	_, err := LoadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "provider-base-urls") {
+		t.Fatalf("LoadConfig error = %v, want provider-base-urls validation error", err)
+	}
+}
@@ -17,9 +17,29 @@ type SDKConfig struct {
 	// RequestLog enables or disables detailed request logging functionality.
 	RequestLog bool `yaml:"request-log" json:"request-log"`
 
+	// RequestLogSamplePercent is the percentage (1-100) of API requests that emit a structured
+	// request log entry while RequestLog is enabled. 0 logs every request.
+	RequestLogSamplePercent float64 `yaml:"request-log-sample-percent,omitempty" json:"request-log-sample-percent,omitempty"`
+
+	// ProxyHeaders adds X-Proxy-Provider, X-Proxy-Model, X-Proxy-Cache and X-Proxy-Latency-Ms
+	// headers to API responses, describing the provider, resolved model and cache that served them.
+	ProxyHeaders bool `yaml:"proxy-headers,omitempty" json:"proxy-headers,omitempty"`
+
+	// AccessLog emits one structured log line per API request tracing its routing, upstream
+	// calls with their retries and failovers, cache status, upstream latency and final status.
+	AccessLog bool `yaml:"access-log,omitempty" json:"access-log,omitempty"`
+
+	// ValidateTranslations checks that each translated response has the shape its target
+	// format requires, logging and counting the ones that do not. Responses are never altered.
+	ValidateTranslations bool `yaml:"validate-translations,omitempty" json:"validate-translations,omitempty"`
+
 	// APIKeys is a list of keys for authenticating clients to this proxy server.
 	APIKeys []string `yaml:"api-keys" json:"api-keys"`
 
+	// KeyLabels maps a client API key to a human-readable label (team or app name) recorded
+	// in audit entries and per-key metrics instead of the key itself.
+	KeyLabels map[string]string `yaml:"key-labels,omitempty" json:"key-labels,omitempty"`
+
 	// Access holds request authentication provider configuration.
 	Access AccessConfig `yaml:"auth,omitempty" json:"auth,omitempty"`
 
@@ -48,6 +68,16 @@ type SDKConfig struct {
 	// MetricsDB configures PostgreSQL database for metrics persistence.
 	MetricsDB MetricsDBConfig `yaml:"metrics-db,omitempty" json:"metrics-db,omitempty"`
 
+	// MetricsFile configures NDJSON file persistence for metrics when no database is available.
+	MetricsFile MetricsFileConfig `yaml:"metrics-file,omitempty" json:"metrics-file,omitempty"`
+
+	// SLO configures the availability objective reported by the SLO endpoint.
+	SLO SLOConfig `yaml:"slo,omitempty" json:"slo,omitempty"`
+
+	// DependencyPolicy chooses whether API requests are served or rejected while Redis or
+	// the metrics database is unreachable.
+	DependencyPolicy DependencyPolicyConfig `yaml:"dependency-policy,omitempty" json:"dependency-policy,omitempty"`
+
 	// Tools configures tool calling format conversion.
 	Tools ToolsConfig `yaml:"tools,omitempty" json:"tools,omitempty"`
 
@@ -62,11 +92,32 @@ type SDKConfig struct {
 
 	// Retry configures retry behavior with exponential backoff.
 	Retry RetryConfig `yaml:"retry,omitempty" json:"retry,omitempty"`
+
+	// ModelRouting configures declarative model-to-provider routing rules.
+	ModelRouting ModelRoutingConfig `yaml:"model-routing,omitempty" json:"model-routing,omitempty"`
+
+	// Pricing configures per-model token prices used for cost estimates.
+	Pricing PricingConfig `yaml:"pricing,omitempty" json:"pricing,omitempty"`
+
+	// ModelMaxTokens caps the output tokens a request may ask for, keyed by model name.
+	// Larger client values are clamped and the cap is injected when the client sets none.
+	ModelMaxTokens map[string]int64 `yaml:"model-max-tokens,omitempty" json:"model-max-tokens,omitempty"`
+
+	// MaxMessages rejects requests carrying more messages than this with a 400 before they
+	// are translated or dispatched. 0 disables the limit.
+	MaxMessages int `yaml:"max-messages,omitempty" json:"max-messages,omitempty"`
+
+	// ModelMaxMessages overrides MaxMessages for the named models.
+	ModelMaxMessages map[string]int `yaml:"model-max-messages,omitempty" json:"model-max-messages,omitempty"`
+
+	// Guardrails configures regex-based screening of inbound prompts.
+	Guardrails GuardrailConfig `yaml:"guardrails,omitempty" json:"guardrails,omitempty"`
 }
 
 // CacheConfig holds response caching configuration.
 type CacheConfig struct {
-	// Enabled controls whether response caching is enabled.
+	// Enabled controls whether response caching is enabled. While enabled, concurrent
+	// identical non-streaming requests also share a single upstream call.
 	Enabled bool `yaml:"enabled" json:"enabled"`
 
 	// DefaultTTLSeconds is the default TTL for cached responses.
@@ -75,6 +126,24 @@ type CacheConfig struct {
 	// MaxEntries is the maximum number of cached responses.
 	MaxEntries int `yaml:"max-entries" json:"max_entries"`
 
+	// NegativeTTLSeconds caches deterministic provider errors (non-retryable 4xx such as
+	// context_length_exceeded) for this many seconds and replays them to identical requests
+	// with an "X-Cache: NEGATIVE" header. 0 disables negative caching.
+	NegativeTTLSeconds int `yaml:"negative-ttl-seconds,omitempty" json:"negative_ttl_seconds,omitempty"`
+
+	// ServeStaleOnError serves expired cached embeddings, marked "X-Cache: STALE" with a
+	// Warning header, instead of an upstream timeout, rate limit or server error. Chat
+	// completions are not covered.
+	ServeStaleOnError bool `yaml:"serve-stale-on-error,omitempty" json:"serve_stale_on_error,omitempty"`
+
+	// StaleGraceSeconds is how long past its TTL a cached embedding may still be served by
+	// ServeStaleOnError (default: 300).
+	StaleGraceSeconds int `yaml:"stale-grace-seconds,omitempty" json:"stale_grace_seconds,omitempty"`
+
+	// EvictionPolicy chooses which entry in-memory caches evict when full: "lru" (default),
+	// "lfu", or "lfu-aging" (LFU with access counts decayed over time).
+	EvictionPolicy string `yaml:"eviction-policy,omitempty" json:"eviction_policy,omitempty"`
+
 	// SemanticCache configures semantic (similarity-based) caching.
 	SemanticCache SemanticCacheConfig `yaml:"semantic,omitempty" json:"semantic,omitempty"`
 
@@ -104,6 +173,17 @@ type SemanticCacheConfig struct {
 
 	// NormalizeWhitespace collapses whitespace for comparison.
 	NormalizeWhitespace bool `yaml:"normalize-whitespace" json:"normalize_whitespace"`
+
+	// SimilarityMethod scores candidates with "jaccard" (default) or "cosine-tfidf",
+	// which discounts n-grams common to many cached prompts.
+	SimilarityMethod string `yaml:"similarity-method,omitempty" json:"similarity_method,omitempty"`
+
+	// TokenMode builds n-grams from "char" windows (default) or whitespace-separated "word"s.
+	TokenMode string `yaml:"token-mode,omitempty" json:"token_mode,omitempty"`
+
+	// SkipWhenDeterministic restricts requests with temperature 0 or a seed to exact cache
+	// matches, never a similar prompt's response. Defaults to true.
+	SkipWhenDeterministic *bool `yaml:"skip-when-deterministic,omitempty" json:"skip_when_deterministic,omitempty"`
 }
 
 // StreamingCacheConfig configures streaming response caching.
@@ -138,9 +218,14 @@ type CacheKeyConfig struct {
 	// IncludeMaxTokens includes max_tokens in cache key.
 	IncludeMaxTokens bool `yaml:"include-max-tokens" json:"include_max_tokens"`
 
-	// IncludeTools includes tools/functions in cache key.
+	// IncludeTools includes the tool/function definitions, schemas included, in cache key.
 	IncludeTools bool `yaml:"include-tools" json:"include_tools"`
 
+	// IncludeReasoning includes the reasoning settings (reasoning effort, thinking budget or
+	// level, include-thoughts) in cache key, so requests asking for different reasoning never
+	// share a cached response. Defaults to true.
+	IncludeReasoning *bool `yaml:"include-reasoning,omitempty" json:"include_reasoning,omitempty"`
+
 	// ExcludeFields lists field names to exclude from cache key.
 	ExcludeFields []string `yaml:"exclude-fields" json:"exclude_fields"`
 }
@@ -180,8 +265,41 @@ type SchedulerConfig struct {
 	// QueueTimeoutSeconds is the maximum time a request can wait in queue.
 	QueueTimeoutSeconds int `yaml:"queue-timeout-seconds" json:"queue_timeout_seconds"`
 
+	// ShortestJobFirst serves requests with smaller estimated token cost first
+	// within each API key's queue. Fairness across keys is unaffected.
+	ShortestJobFirst bool `yaml:"shortest-job-first,omitempty" json:"shortest_job_first,omitempty"`
+
+	// Mode selects how capacity is shared between API keys: "wfq" (default) orders requests
+	// by estimated tokens, "drr" (deficit round robin) charges keys the tokens their
+	// requests actually used.
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+
+	// DRRQuantum is the tokens credited per round to a key with the default weight in
+	// "drr" mode (default 1000).
+	DRRQuantum int64 `yaml:"drr-quantum,omitempty" json:"drr_quantum,omitempty"`
+
+	// MaxGlobalInFlight caps upstream calls in flight across the whole process, whatever
+	// their API key. It applies even when fair scheduling is disabled; 0 means unlimited.
+	MaxGlobalInFlight int `yaml:"max-global-inflight,omitempty" json:"max_global_inflight,omitempty"`
+
+	// AdmissionWaitMs is how long a call waits for a free global slot before it is shed
+	// with 503. 0 sheds immediately once MaxGlobalInFlight is reached.
+	AdmissionWaitMs int `yaml:"admission-wait-ms,omitempty" json:"admission_wait_ms,omitempty"`
+
+	// MetricsIntervalSeconds is how often queue depths and wait times are published to the
+	// Prometheus metrics (default 5).
+	MetricsIntervalSeconds int `yaml:"metrics-interval-seconds,omitempty" json:"metrics_interval_seconds,omitempty"`
+
+	// DrainTimeoutSeconds is how long shutdown keeps running queued requests before
+	// giving up on them (default 30).
+	DrainTimeoutSeconds int `yaml:"drain-timeout-seconds,omitempty" json:"drain_timeout_seconds,omitempty"`
+
 	// APIKeyWeights maps API keys to their scheduling weights.
 	APIKeyWeights []APIKeyWeight `yaml:"api-key-weights,omitempty" json:"api_key_weights,omitempty"`
+
+	// RateLimits sets hard per-key request and token rates. A key over its limit waits
+	// while other keys share the spare capacity.
+	RateLimits []APIKeyRateLimit `yaml:"rate-limits,omitempty" json:"rate_limits,omitempty"`
 }
 
 // RedisCacheConfig holds Redis cache configuration.
@@ -189,9 +307,19 @@ type RedisCacheConfig struct {
 	// Enabled controls whether Redis caching is active.
 	Enabled bool `yaml:"enabled" json:"enabled"`
 
+	// Mode is "standalone" (default), "cluster" or "sentinel".
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+
 	// Address is the Redis server address (host:port).
 	Address string `yaml:"address" json:"address"`
 
+	// Addresses lists the cluster seed nodes or the sentinel addresses. When empty,
+	// Address is used as the only one.
+	Addresses []string `yaml:"addresses,omitempty" json:"addresses,omitempty"`
+
+	// MasterName is the name of the master set monitored by the sentinels.
+	MasterName string `yaml:"master-name,omitempty" json:"master_name,omitempty"`
+
 	// Password is the Redis password (optional).
 	Password string `yaml:"password" json:"password"`
 
@@ -221,6 +349,18 @@ type RedisCacheConfig struct {
 
 	// EnableTLS enables TLS for Redis connections.
 	EnableTLS bool `yaml:"enable-tls" json:"enable_tls"`
+
+	// ScanCount is how many keys a cache flush enumerates with SCAN and unlinks per batch
+	// (default 500).
+	ScanCount int `yaml:"scan-count,omitempty" json:"scan_count,omitempty"`
+
+	// CompressStreaming gzips cached streaming responses stored in Redis. Entries written
+	// without compression remain readable.
+	CompressStreaming bool `yaml:"compress-streaming,omitempty" json:"compress_streaming,omitempty"`
+
+	// AccessIndexSize is how many recently used cache keys are tracked in a Redis sorted
+	// set so a cache warmup can rehydrate the local cache with them. 0 disables tracking.
+	AccessIndexSize int `yaml:"access-index-size,omitempty" json:"access_index_size,omitempty"`
 }
 
 // ObservabilityConfig holds observability configuration.
@@ -230,6 +370,48 @@ type ObservabilityConfig struct {
 
 	// Tracing configures OpenTelemetry tracing.
 	Tracing TracingConfig `yaml:"tracing" json:"tracing"`
+
+	// Alerting configures threshold rules that notify a webhook.
+	Alerting AlertingConfig `yaml:"alerting,omitempty" json:"alerting,omitempty"`
+}
+
+// AlertingConfig configures metric threshold alerts delivered to a webhook.
+type AlertingConfig struct {
+	// Enabled controls whether alert rules are evaluated.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// WebhookURL receives a JSON POST when an alert fires or resolves.
+	WebhookURL string `yaml:"webhook-url" json:"webhook_url"`
+
+	// EvaluationIntervalSeconds controls how often rules are evaluated. Default: 15.
+	EvaluationIntervalSeconds int `yaml:"evaluation-interval-seconds,omitempty" json:"evaluation_interval_seconds,omitempty"`
+
+	// Rules are the thresholds to evaluate.
+	Rules []AlertRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// AlertRule describes a single threshold, e.g. error_rate > 10 for 300 seconds.
+type AlertRule struct {
+	// Name identifies the alert in webhook payloads.
+	Name string `yaml:"name" json:"name"`
+
+	// Metric is the measured value: "error_rate" (percent) or "p95_latency" (milliseconds).
+	Metric string `yaml:"metric" json:"metric"`
+
+	// Operator compares the metric against Threshold: ">", ">=", "<" or "<=". Default: ">".
+	Operator string `yaml:"operator,omitempty" json:"operator,omitempty"`
+
+	// Threshold is the value the metric is compared against.
+	Threshold float64 `yaml:"threshold" json:"threshold"`
+
+	// WindowSeconds is the lookback window used to compute the metric. Default: 60.
+	WindowSeconds int `yaml:"window-seconds,omitempty" json:"window_seconds,omitempty"`
+
+	// ForSeconds is how long the condition must hold before the alert fires. 0 fires immediately.
+	ForSeconds int `yaml:"for-seconds,omitempty" json:"for_seconds,omitempty"`
+
+	// MinRequests is the minimum number of requests in the window before the rule is evaluated.
+	MinRequests int `yaml:"min-requests,omitempty" json:"min_requests,omitempty"`
 }
 
 // MetricsConfig configures Prometheus metrics.
@@ -253,6 +435,31 @@ type MetricsConfig struct {
 	// instead of the custom implementation. When enabled, the /metrics endpoint
 	// uses promhttp.Handler() for standard Prometheus scraping. Default: false.
 	UseOfficialClient bool `yaml:"use-official-client" json:"use_official_client"`
+
+	// ProviderHealth configures when providers are reported unhealthy.
+	ProviderHealth ProviderHealthConfig `yaml:"provider-health,omitempty" json:"provider_health,omitempty"`
+}
+
+// ProviderHealthConfig configures provider health warmup and hysteresis.
+type ProviderHealthConfig struct {
+	// MinSamples is the number of requests a provider must serve before it can be marked
+	// unhealthy. Default: 20.
+	MinSamples int `yaml:"min-samples,omitempty" json:"min_samples,omitempty"`
+
+	// TripErrorRate is the error percentage at which a healthy provider is marked unhealthy.
+	// Default: 50.
+	TripErrorRate float64 `yaml:"trip-error-rate,omitempty" json:"trip_error_rate,omitempty"`
+
+	// RecoverErrorRate is the error percentage an unhealthy provider must fall to before it
+	// is marked healthy again. Must not exceed TripErrorRate. Default: 25.
+	RecoverErrorRate float64 `yaml:"recover-error-rate,omitempty" json:"recover_error_rate,omitempty"`
+
+	// WindowSize is the number of most recent requests health error rates are computed over,
+	// so long healthy history cannot mask a current outage. Default: 100.
+	WindowSize int `yaml:"window-size,omitempty" json:"window_size,omitempty"`
+
+	// WindowSeconds additionally ignores requests older than this many seconds. Default: 0 (off).
+	WindowSeconds int `yaml:"window-seconds,omitempty" json:"window_seconds,omitempty"`
 }
 
 // TracingConfig configures OpenTelemetry tracing.
@@ -280,6 +487,12 @@ type TracingConfig struct {
 
 	// Insecure disables TLS for the exporter connection.
 	Insecure bool `yaml:"insecure" json:"insecure"`
+
+	// BatchTimeoutMs is the maximum delay before queued spans are exported. Default: 5000.
+	BatchTimeoutMs int `yaml:"batch-timeout-ms,omitempty" json:"batch_timeout_ms,omitempty"`
+
+	// MaxExportBatchSize is the maximum number of spans sent per export. Default: 512.
+	MaxExportBatchSize int `yaml:"max-export-batch-size,omitempty" json:"max_export_batch_size,omitempty"`
 }
 
 // APIKeyWeight defines the scheduling weight for an API key.
@@ -291,6 +504,18 @@ type APIKeyWeight struct {
 	Weight int `yaml:"weight" json:"weight"`
 }
 
+// APIKeyRateLimit is a hard rate limit for one API key in the fair scheduler.
+type APIKeyRateLimit struct {
+	// APIKey is the exact API key the limit applies to.
+	APIKey string `yaml:"api-key" json:"api_key"`
+
+	// RequestsPerMinute caps requests started per minute; 0 means unlimited.
+	RequestsPerMinute int `yaml:"requests-per-minute,omitempty" json:"requests_per_minute,omitempty"`
+
+	// TokensPerMinute caps tokens used per minute; 0 means unlimited.
+	TokensPerMinute int64 `yaml:"tokens-per-minute,omitempty" json:"tokens_per_minute,omitempty"`
+}
+
 // StreamingConfig holds server streaming behavior configuration.
 type StreamingConfig struct {
 	// KeepAliveSeconds controls how often the server emits SSE heartbeats (": keep-alive\n\n").
@@ -301,6 +526,19 @@ type StreamingConfig struct {
 	// to allow auth rotation / transient recovery.
 	// <= 0 disables bootstrap retries. Default is 0.
 	BootstrapRetries int `yaml:"bootstrap-retries,omitempty" json:"bootstrap-retries,omitempty"`
+
+	// EventIDs numbers every SSE event with an "id:" field so clients can report their
+	// position in Last-Event-ID. Events shared through stream fan-out are numbered anyway.
+	EventIDs bool `yaml:"event-ids,omitempty" json:"event-ids,omitempty"`
+
+	// RetryMillis is the reconnect delay advertised with an SSE "retry:" field at the start
+	// of each stream. <= 0 omits the field. Default is 0.
+	RetryMillis int `yaml:"retry-ms,omitempty" json:"retry-ms,omitempty"`
+
+	// MaxDurationSeconds caps how long a single stream may run, however steadily the
+	// upstream keeps sending. A stream past the cap ends with an error event.
+	// <= 0 disables the cap. Default is 0.
+	MaxDurationSeconds int `yaml:"max-duration-seconds,omitempty" json:"max-duration-seconds,omitempty"`
 }
 
 // AccessConfig groups request authentication providers.
@@ -351,6 +589,14 @@ func (c *SDKConfig) ConfigAPIKeyProvider() *AccessProvider {
 	return nil
 }
 
+// KeyLabel returns the configured label for a client API key, or "" when it has none.
+func (c *SDKConfig) KeyLabel(key string) string {
+	if c == nil || key == "" {
+		return ""
+	}
+	return c.KeyLabels[key]
+}
+
 // MakeInlineAPIKeyProvider constructs an inline API key provider configuration.
 // It returns nil when no keys are supplied.
 func MakeInlineAPIKeyProvider(keys []string) *AccessProvider {
@@ -402,6 +648,18 @@ type StreamFanoutConfig struct {
 
 	// DedupWindowSeconds is the time window for detecting duplicate requests.
 	DedupWindowSeconds int `yaml:"dedup-window-seconds" json:"dedup_window_seconds"`
+
+	// AllowedEventTypes, when set, limits the typed SSE events (the "event:" line or the
+	// JSON "type" field) broadcast to fan-out subscribers. Untyped data events always pass.
+	AllowedEventTypes []string `yaml:"allowed-event-types,omitempty" json:"allowed_event_types,omitempty"`
+
+	// DeniedEventTypes lists SSE event types never broadcast to fan-out subscribers, e.g. "ping".
+	DeniedEventTypes []string `yaml:"denied-event-types,omitempty" json:"denied_event_types,omitempty"`
+
+	// ResumeWindowSeconds is how long a shared stream keeps its upstream running after the
+	// client that started it disconnected and no other client is subscribed, so the client
+	// can reconnect with Last-Event-ID. Defaults to 30.
+	ResumeWindowSeconds int `yaml:"resume-window-seconds" json:"resume_window_seconds"`
 }
 
 // DefaultPerformanceConfig returns sensible defaults for performance settings.
@@ -415,13 +673,41 @@ func DefaultPerformanceConfig() PerformanceConfig {
 			ForceHTTP2:             true,
 		},
 		StreamFanout: StreamFanoutConfig{
-			Enabled:            true,
-			BufferSize:         50,
-			DedupWindowSeconds: 5,
+			Enabled:             true,
+			BufferSize:          50,
+			DedupWindowSeconds:  5,
+			ResumeWindowSeconds: 30,
 		},
 	}
 }
 
+const (
+	// DependencyFailOpen keeps serving while a dependency is down, skipping the features
+	// that need it (cache lookups miss, metrics are not persisted).
+	DependencyFailOpen = "fail-open"
+	// DependencyFailClosed rejects API requests with 503 while the dependency is down.
+	DependencyFailClosed = "fail-closed"
+)
+
+// DependencyPolicyConfig sets the outage policy of each optional dependency. Values are
+// "fail-open" (default) or "fail-closed"; a policy only applies when its dependency is enabled.
+type DependencyPolicyConfig struct {
+	// Redis applies to the Redis cache.
+	Redis string `yaml:"redis,omitempty" json:"redis,omitempty"`
+
+	// MetricsDB applies to the PostgreSQL metrics database.
+	MetricsDB string `yaml:"metrics-db,omitempty" json:"metrics-db,omitempty"`
+}
+
+// SLOConfig configures an availability service-level objective.
+type SLOConfig struct {
+	// AvailabilityTarget is the required success percentage, e.g. 99.9. Defaults to 99.9.
+	AvailabilityTarget float64 `yaml:"availability-target" json:"availability_target"`
+
+	// WindowDays is the rolling window the objective is measured over. Defaults to 30.
+	WindowDays int `yaml:"window-days" json:"window_days"`
+}
+
 // MetricsDBConfig configures PostgreSQL database for metrics persistence.
 type MetricsDBConfig struct {
 	// Enabled controls whether metrics are persisted to database.
@@ -442,6 +728,31 @@ type MetricsDBConfig struct {
 
 	// BatchSize is the number of metrics to batch before flushing.
 	BatchSize int `yaml:"batch-size" json:"batch_size"`
+
+	// SecondaryDSN is a warm-standby PostgreSQL database. Writes fail over to it while the
+	// primary fails its health checks and return to the primary once it recovers. Metrics
+	// written to the secondary during the outage are not copied back to the primary.
+	SecondaryDSN string `yaml:"secondary-dsn,omitempty" json:"secondary_dsn,omitempty"`
+
+	// HealthCheckIntervalSeconds is how often the primary is pinged when a secondary is
+	// configured. Defaults to 10.
+	HealthCheckIntervalSeconds int `yaml:"health-check-interval-seconds,omitempty" json:"health_check_interval_seconds,omitempty"`
+}
+
+// MetricsFileConfig configures NDJSON file persistence for metrics.
+type MetricsFileConfig struct {
+	// Enabled controls whether metrics are appended to the file.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Path is the active metrics file. Rotated files get a numeric suffix (.1 is newest).
+	// Defaults to "metrics/metrics.ndjson".
+	Path string `yaml:"path" json:"path"`
+
+	// MaxSizeMB rotates the file once it would grow beyond this size. Defaults to 50.
+	MaxSizeMB int `yaml:"max-size-mb" json:"max_size_mb"`
+
+	// MaxBackups is how many rotated files to keep. Defaults to 5.
+	MaxBackups int `yaml:"max-backups" json:"max_backups"`
 }
 
 // ToolsConfig configures tool calling format conversion.
@@ -468,6 +779,8 @@ type ReasoningConfig struct {
 	ExtractThinking bool `yaml:"extract-thinking" json:"extract_thinking"`
 
 	// ShowThinkingToClient controls whether thinking is returned to client.
+	// When reasoning is enabled and this is false, reasoning_content and native_finish_reason
+	// are stripped from OpenAI chat completion responses.
 	ShowThinkingToClient bool `yaml:"show-thinking-to-client" json:"show_thinking_to_client"`
 
 	// Claude holds Claude-specific reasoning settings.
@@ -524,10 +837,16 @@ type AgentConfig struct {
 	// MaxIterations is the maximum agent loop iterations.
 	MaxIterations int `yaml:"max-iterations" json:"max_iterations"`
 
+	// MaxTotalTokens stops an agentic loop once the tokens reported across its iterations
+	// reach this budget, even before the iteration cap. Requests may set a smaller budget but
+	// not a larger one. 0 disables the budget.
+	MaxTotalTokens int64 `yaml:"max-total-tokens,omitempty" json:"max_total_tokens,omitempty"`
+
 	// ParallelToolCalls enables parallel tool execution.
 	ParallelToolCalls bool `yaml:"parallel-tool-calls" json:"parallel_tool_calls"`
 
-	// MaxConcurrency limits concurrent tool executions.
+	// MaxConcurrency limits concurrent tool executions across all agentic loops on the
+	// server. 0 leaves only the per-request limit.
 	MaxConcurrency int `yaml:"max-concurrency" json:"max_concurrency"`
 
 	// ToolTimeoutMs is the timeout for tool execution in milliseconds.
@@ -535,6 +854,60 @@ type AgentConfig struct {
 
 	// AutoExecuteTools executes tools automatically on the server.
 	AutoExecuteTools bool `yaml:"auto-execute-tools" json:"auto_execute_tools"`
+
+	// HTTPTool configures the built-in http_request tool, registered when both it and
+	// AutoExecuteTools are enabled.
+	HTTPTool AgentHTTPToolConfig `yaml:"http-tool,omitempty" json:"http_tool,omitempty"`
+
+	// MaxStepsBehavior selects the response when the iteration cap or token budget is
+	// reached: "error" (default) returns 400, "partial" returns the last assistant message
+	// with 200.
+	MaxStepsBehavior string `yaml:"max-steps-behavior,omitempty" json:"max_steps_behavior,omitempty"`
+
+	// IncludeSummary appends the loop summary to agentic responses.
+	IncludeSummary bool `yaml:"include-summary" json:"include_summary"`
+
+	// MaxToolResultBytes caps the content of each tool result fed back to the model; longer
+	// content is cut and a truncation marker inserted. 0 disables the cap.
+	MaxToolResultBytes int `yaml:"max-tool-result-bytes,omitempty" json:"max_tool_result_bytes,omitempty"`
+
+	// MaxToolArgsBytes caps the arguments of each tool call accumulated from a streamed
+	// response; a call growing past it is answered with an error instead of being run.
+	// 0 uses the default of 1 MiB.
+	MaxToolArgsBytes int `yaml:"max-tool-args-bytes,omitempty" json:"max_tool_args_bytes,omitempty"`
+
+	// ToolResultKeepTail keeps the end of a truncated tool result as well as its start,
+	// splitting MaxToolResultBytes between the two.
+	ToolResultKeepTail bool `yaml:"tool-result-keep-tail,omitempty" json:"tool_result_keep_tail,omitempty"`
+
+	// CompactThresholdBytes summarizes older messages of an agentic request with the request's
+	// own model once the request body grows past this size. 0 disables compaction.
+	CompactThresholdBytes int `yaml:"compact-threshold-bytes,omitempty" json:"compact_threshold_bytes,omitempty"`
+
+	// CompactKeepRecent is how many of the most recent messages compaction keeps verbatim
+	// (default 4). The system prompt and the first user message are always kept.
+	CompactKeepRecent int `yaml:"compact-keep-recent,omitempty" json:"compact_keep_recent,omitempty"`
+}
+
+// AgentHTTPToolConfig configures the built-in http_request agent tool.
+type AgentHTTPToolConfig struct {
+	// Enabled registers the tool.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// AllowedHosts lists reachable hosts; "*.example.com" matches subdomains. Empty allows none.
+	AllowedHosts []string `yaml:"allowed-hosts,omitempty" json:"allowed_hosts,omitempty"`
+
+	// AllowedSchemes defaults to ["https"].
+	AllowedSchemes []string `yaml:"allowed-schemes,omitempty" json:"allowed_schemes,omitempty"`
+
+	// AllowedCIDRs exempts networks from the private/loopback/link-local block.
+	AllowedCIDRs []string `yaml:"allowed-cidrs,omitempty" json:"allowed_cidrs,omitempty"`
+
+	// MaxResponseBytes caps the response body returned to the model. Defaults to 1 MiB.
+	MaxResponseBytes int64 `yaml:"max-response-bytes,omitempty" json:"max_response_bytes,omitempty"`
+
+	// TimeoutMs bounds each request. Defaults to 10000.
+	TimeoutMs int `yaml:"timeout-ms,omitempty" json:"timeout_ms,omitempty"`
 }
 
 // ContextConfig configures context window management.
@@ -584,3 +957,117 @@ type RetryConfig struct {
 	// RetryableStatusCodes lists HTTP status codes to retry.
 	RetryableStatusCodes []int `yaml:"retryable-status-codes" json:"retryable_status_codes"`
 }
+
+// ModelRoutingConfig configures declarative model-to-provider routing.
+type ModelRoutingConfig struct {
+	// Rules are evaluated in order; the first matching rule wins.
+	Rules []ModelRoutingRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+
+	// DefaultProvider is used when no rule matches and no registered provider serves the model.
+	DefaultProvider string `yaml:"default-provider,omitempty" json:"default-provider,omitempty"`
+
+	// Experiments split traffic for a logical model across weighted variant models.
+	Experiments []ModelExperiment `yaml:"experiments,omitempty" json:"experiments,omitempty"`
+
+	// FallbackChains maps a model to ordered alternates tried when the primary fails
+	// with a retryable or failover-eligible error.
+	FallbackChains map[string][]string `yaml:"fallback-chains,omitempty" json:"fallback-chains,omitempty"`
+
+	// BlockedModels rejects matching models before dispatch with an operator-supplied explanation.
+	BlockedModels []BlockedModel `yaml:"blocked-models,omitempty" json:"blocked-models,omitempty"`
+}
+
+// BlockedModel disables models matching a pattern.
+type BlockedModel struct {
+	// Pattern is a case-insensitive model name; "*" matches any run of characters and "?" one character.
+	Pattern string `yaml:"pattern" json:"pattern"`
+
+	// Message explains why the model is unavailable.
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+
+	// Replacement optionally suggests a model to use instead.
+	Replacement string `yaml:"replacement,omitempty" json:"replacement,omitempty"`
+}
+
+// ModelExperiment defines weighted A/B routing for a logical model.
+type ModelExperiment struct {
+	// Model is the client-facing logical model name.
+	Model string `yaml:"model" json:"model"`
+
+	// Variants maps concrete model names to relative traffic weights.
+	Variants map[string]int `yaml:"variants" json:"variants"`
+}
+
+// ModelRoutingRule routes models matching a pattern to a target provider.
+type ModelRoutingRule struct {
+	// Match selects how Pattern is compared to the model name (exact, prefix, regex). Default: exact.
+	Match string `yaml:"match,omitempty" json:"match,omitempty"`
+
+	// Pattern is the model name, prefix, or regular expression to match.
+	Pattern string `yaml:"pattern" json:"pattern"`
+
+	// Provider is the target provider identifier (e.g., "gemini", "claude", "codex").
+	Provider string `yaml:"provider" json:"provider"`
+
+	// CredentialGroup optionally restricts routing to credentials registered with this model prefix.
+	CredentialGroup string `yaml:"credential-group,omitempty" json:"credential-group,omitempty"`
+}
+
+// PricingConfig holds per-model token prices.
+type PricingConfig struct {
+	// Models maps model names to their token prices.
+	Models map[string]ModelPrice `yaml:"models,omitempty" json:"models,omitempty"`
+}
+
+// ModelPrice is the price of a model's tokens in USD per million tokens.
+type ModelPrice struct {
+	// InputPerMillion is the price of one million prompt tokens.
+	InputPerMillion float64 `yaml:"input-per-million" json:"input_per_million"`
+
+	// OutputPerMillion is the price of one million completion tokens.
+	OutputPerMillion float64 `yaml:"output-per-million" json:"output_per_million"`
+}
+
+// GuardrailConfig configures the built-in regex guardrail applied to inbound prompts.
+type GuardrailConfig struct {
+	// Enabled controls whether prompts are screened before dispatch.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Rules are evaluated in order; the first blocking match rejects the request.
+	Rules []GuardrailRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+
+	// Output configures screening of generated responses.
+	Output OutputGuardrailConfig `yaml:"output,omitempty" json:"output,omitempty"`
+}
+
+// OutputGuardrailConfig configures the built-in regex guardrail applied to generated responses.
+type OutputGuardrailConfig struct {
+	// Enabled controls whether responses are screened before they reach the client.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// LookaheadBytes is how much generated text a stream holds back so that a match
+	// spanning chunks is caught before emission. It should cover the longest phrase
+	// matched by a rule. Defaults to 64.
+	LookaheadBytes int `yaml:"lookahead-bytes,omitempty" json:"lookahead-bytes,omitempty"`
+
+	// Replacement substitutes redacted text. Defaults to "[REDACTED]".
+	Replacement string `yaml:"replacement,omitempty" json:"replacement,omitempty"`
+
+	// Rules support the "block" (default), "redact", and "flag" actions.
+	Rules []GuardrailRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// GuardrailRule matches prompt text against a regular expression.
+type GuardrailRule struct {
+	// Name identifies the rule in error messages and audit metadata.
+	Name string `yaml:"name" json:"name"`
+
+	// Pattern is a Go regular expression matched against the prompt text.
+	Pattern string `yaml:"pattern" json:"pattern"`
+
+	// Action is "block" (default) to reject the request or "flag" to annotate it and continue.
+	Action string `yaml:"action,omitempty" json:"action,omitempty"`
+
+	// Message optionally explains the block to the client.
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+}
@@ -19,7 +19,8 @@ const (
 	// StrategyPriority keeps important messages based on priority rules.
 	StrategyPriority Strategy = "priority"
 
-	// StrategySummarize uses LLM to summarize old context (future feature).
+	// StrategySummarize replaces older messages with a summary produced by a Summarizer;
+	// see SummarizeMessages. Truncate, which has no Summarizer, falls back to the sliding window.
 	StrategySummarize Strategy = "summarize"
 )
 
@@ -0,0 +1,85 @@
+// Package context provides context window management for AI models.
+// This file implements the summarize strategy.
+package context
+
+import (
+	gocontext "context"
+	"errors"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// summaryPrefix introduces the message that replaces summarized history.
+const summaryPrefix = "Summary of the earlier conversation:\n"
+
+// Summarizer condenses a JSON array of chat messages into a short text.
+type Summarizer interface {
+	Summarize(ctx gocontext.Context, model string, messages []byte) (string, error)
+}
+
+// SummarizerFunc adapts a function to Summarizer.
+type SummarizerFunc func(ctx gocontext.Context, model string, messages []byte) (string, error)
+
+// Summarize calls f.
+func (f SummarizerFunc) Summarize(ctx gocontext.Context, model string, messages []byte) (string, error) {
+	return f(ctx, model, messages)
+}
+
+// ErrEmptySummary is returned when a Summarizer produces no text.
+var ErrEmptySummary = errors.New("summarizer returned an empty summary")
+
+// SummarizeMessages replaces the middle of an OpenAI-style messages array with a single
+// user message holding a summary of it. The leading messages up to and including the first
+// user message (the system prompt and the task) are kept, as are the keepRecent most recent
+// messages. The recent window is widened so tool results are never separated from the
+// assistant message that called them. When nothing lies between the two, messages is
+// returned unchanged.
+func SummarizeMessages(ctx gocontext.Context, messages []byte, model string, keepRecent int, s Summarizer) ([]byte, error) {
+	msgArray := gjson.ParseBytes(messages).Array()
+
+	head := 0
+	for head < len(msgArray) {
+		role := msgArray[head].Get("role").String()
+		head++
+		if role == "user" {
+			break
+		}
+	}
+
+	if keepRecent < 0 {
+		keepRecent = 0
+	}
+	tail := len(msgArray) - keepRecent
+	for tail > head && tail < len(msgArray) && msgArray[tail].Get("role").String() == "tool" {
+		tail--
+	}
+	if tail <= head {
+		return messages, nil
+	}
+
+	older := make([]string, 0, tail-head)
+	for _, msg := range msgArray[head:tail] {
+		older = append(older, msg.Raw)
+	}
+	summary, err := s.Summarize(ctx, model, []byte("["+strings.Join(older, ",")+"]"))
+	if err != nil {
+		return messages, err
+	}
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return messages, ErrEmptySummary
+	}
+
+	summaryMsg, _ := sjson.Set(`{"role":"user"}`, "content", summaryPrefix+summary)
+	kept := make([]string, 0, head+1+len(msgArray)-tail)
+	for _, msg := range msgArray[:head] {
+		kept = append(kept, msg.Raw)
+	}
+	kept = append(kept, summaryMsg)
+	for _, msg := range msgArray[tail:] {
+		kept = append(kept, msg.Raw)
+	}
+	return []byte("[" + strings.Join(kept, ",") + "]"), nil
+}
@@ -162,10 +162,27 @@ type TokenEstimator interface {
 	EstimateTokens(content []byte) int64
 }
 
+// RoughEstimator is a TokenEstimator that needs no tokenizer.
+type RoughEstimator struct{}
+
+// EstimateTokens implements TokenEstimator using the rough character heuristic.
+func (RoughEstimator) EstimateTokens(content []byte) int64 {
+	return estimateTokensRough(content)
+}
+
 // estimateTokensRough provides a rough token estimate without a tokenizer.
 // Approximately 4 characters per token for English text.
 func estimateTokensRough(content []byte) int64 {
-	return int64(len(content) / 4)
+	return EstimateTokensFromLength(int64(len(content)))
+}
+
+// EstimateTokensFromLength applies the rough estimate to content n bytes long, for callers
+// that only track how much text they have seen.
+func EstimateTokensFromLength(n int64) int64 {
+	if n <= 0 {
+		return 0
+	}
+	return n / 4
 }
 
 // TruncateToMessageCount truncates to keep at most N messages.
@@ -0,0 +1,51 @@
+package observability
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMetricsCollector_HistogramBucketBoundaries(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   [4]uint64 // cumulative counts for le=100, 500, 1000, +Inf
+	}{
+		{"below first bound", []float64{0, 99.9}, [4]uint64{2, 2, 2, 2}},
+		{"equal to a bound", []float64{100}, [4]uint64{1, 1, 1, 1}},
+		{"just above a bound", []float64{100.001}, [4]uint64{0, 1, 1, 1}},
+		{"equal to the last bound", []float64{1000}, [4]uint64{0, 0, 1, 1}},
+		{"just above the last bound", []float64{1000.001}, [4]uint64{0, 0, 0, 1}},
+		{"far above every bound", []float64{1e9}, [4]uint64{0, 0, 0, 1}},
+		{"spread across buckets", []float64{50, 100, 400, 500, 501, 1000, 5000}, [4]uint64{2, 4, 6, 7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Bounds are configured out of order and with a duplicate on purpose.
+			m := NewMetricsCollector(MetricsConfig{HistogramBuckets: []float64{1000, 100, 500, 100}})
+			for _, v := range tt.values {
+				m.RecordRequest("gpt-4o", "success", v, 0)
+			}
+			out := m.Export()
+			for i, le := range []string{"100", "500", "1000", "+Inf"} {
+				line := fmt.Sprintf(`shinapi_proxy_request_duration_milliseconds_bucket{model="gpt-4o",le="%s"} %d`+"\n", le, tt.want[i])
+				if !strings.Contains(out, line) {
+					t.Errorf("missing %q in export:\n%s", strings.TrimSpace(line), out)
+				}
+			}
+			count := fmt.Sprintf(`shinapi_proxy_request_duration_milliseconds_count{model="gpt-4o"} %d`+"\n", len(tt.values))
+			if !strings.Contains(out, count) {
+				t.Errorf("missing %q in export", strings.TrimSpace(count))
+			}
+		})
+	}
+}
+
+func TestNormalizeBounds(t *testing.T) {
+	got := normalizeBounds([]float64{2.5, 1, 2.5, 0.5})
+	if fmt.Sprint(got) != "[0.5 1 2.5]" {
+		t.Fatalf("normalizeBounds = %v, want [0.5 1 2.5]", got)
+	}
+}
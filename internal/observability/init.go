@@ -24,14 +24,15 @@ func DefaultObservabilityConfig() ObservabilityConfig {
 	}
 }
 
-// RegisterGinRoutes registers observability routes on a Gin engine.
-func RegisterGinRoutes(r *gin.Engine, cfg ObservabilityConfig) {
-	RegisterGinRoutesWithOptions(r, cfg, false)
+// RegisterGinRoutes registers observability routes on a Gin engine, serving /metrics from
+// the collector cfg.Metrics.UseOfficialClient selects.
+func RegisterGinRoutes(r gin.IRoutes, cfg ObservabilityConfig) {
+	RegisterGinRoutesWithOptions(r, cfg, cfg.Metrics.UseOfficialClient)
 }
 
 // RegisterGinRoutesWithOptions registers observability routes with optional official Prometheus client.
 // When useOfficialClient is true, uses promhttp.Handler() from prometheus/client_golang.
-func RegisterGinRoutesWithOptions(r *gin.Engine, cfg ObservabilityConfig, useOfficialClient bool) {
+func RegisterGinRoutesWithOptions(r gin.IRoutes, cfg ObservabilityConfig, useOfficialClient bool) {
 	if cfg.Metrics.Enabled {
 		path := cfg.Metrics.Path
 		if path == "" {
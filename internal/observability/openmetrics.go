@@ -0,0 +1,47 @@
+// Package observability provides metrics collection and tracing for the API proxy.
+// This file renders the custom collector's metrics in the OpenMetrics text format.
+package observability
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// openMetricsFormat is the content type served to scrapers that ask for OpenMetrics.
+var openMetricsFormat = expfmt.NewFormat(expfmt.TypeOpenMetrics)
+
+// ExportOpenMetrics exports metrics in the OpenMetrics 1.0 text format. Counter families are
+// named without their _total suffix, which stays on the samples, and the output ends with
+// the # EOF marker.
+func (m *MetricsCollector) ExportOpenMetrics() (string, error) {
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(strings.NewReader(m.Export()))
+	if err != nil {
+		return "", fmt.Errorf("parse metrics export: %w", err)
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		// OpenMetrics has no way to describe a family without samples.
+		if len(families[name].GetMetric()) == 0 {
+			continue
+		}
+		if _, err := expfmt.MetricFamilyToOpenMetrics(&sb, families[name]); err != nil {
+			return "", fmt.Errorf("encode %s: %w", name, err)
+		}
+	}
+	if _, err := expfmt.FinalizeOpenMetrics(&sb); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
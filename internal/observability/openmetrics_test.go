@@ -0,0 +1,90 @@
+package observability
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrapeMetrics(m *MetricsCollector, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMetricsHandler_ServesTextFormatByDefault(t *testing.T) {
+	m := NewMetricsCollector(DefaultMetricsConfig())
+	m.RecordRequest("gpt-4o", "success", 12, 5)
+
+	for _, accept := range []string{"", "text/plain", "*/*"} {
+		rec := scrapeMetrics(m, accept)
+		if got := rec.Header().Get("Content-Type"); got != "text/plain; version=0.0.4; charset=utf-8" {
+			t.Fatalf("Accept %q: Content-Type = %q", accept, got)
+		}
+		body := rec.Body.String()
+		if !strings.Contains(body, "# TYPE shinapi_proxy_requests_total counter\n") || strings.Contains(body, "# EOF") {
+			t.Fatalf("Accept %q: body is not in the text format:\n%s", accept, body)
+		}
+	}
+}
+
+func TestMetricsHandler_NegotiatesOpenMetrics(t *testing.T) {
+	m := NewMetricsCollector(DefaultMetricsConfig())
+	m.RecordRequest("gpt-4o", "success", 12, 5)
+
+	rec := scrapeMetrics(m, "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5,*/*;q=0.1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/openmetrics-text; version=1.0.0") {
+		t.Fatalf("Content-Type = %q, want OpenMetrics", got)
+	}
+
+	body := rec.Body.String()
+	if !strings.HasSuffix(body, "\n# EOF\n") || strings.Count(body, "# EOF") != 1 {
+		t.Fatalf("body does not end with a single # EOF marker:\n%s", body)
+	}
+	for _, want := range []string{
+		"# TYPE shinapi_proxy_requests counter\n",
+		`shinapi_proxy_requests_total{model="gpt-4o",status="success"} 1.0`,
+		"# TYPE shinapi_proxy_request_duration_milliseconds histogram\n",
+		`shinapi_proxy_request_duration_milliseconds_bucket{model="gpt-4o",le="+Inf"} 1`,
+		"# TYPE shinapi_proxy_uptime_seconds gauge\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("OpenMetrics output missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "_total counter") {
+		t.Error("counter families must be named without the _total suffix")
+	}
+}
+
+func TestMetricsHandler_EscapesLabelValues(t *testing.T) {
+	m := NewMetricsCollector(DefaultMetricsConfig())
+	m.RecordKeyRequest("sk-test", "team \"a\"\\b\nc", "success")
+	m.RecordRequest(`model"x`, "success", 12, 5)
+
+	body := scrapeMetrics(m, "").Body.String()
+	for _, want := range []string{
+		`key_label="team \"a\"\\b\nc"`,
+		`shinapi_proxy_requests_total{model="model\"x",status="success"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("text output missing %q:\n%s", want, body)
+		}
+	}
+
+	rec := scrapeMetrics(m, "application/openmetrics-text;version=1.0.0")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("OpenMetrics status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `key_label="team \"a\"\\b\nc"`) {
+		t.Errorf("OpenMetrics output lost the escaped key label:\n%s", rec.Body.String())
+	}
+}
@@ -0,0 +1,222 @@
+// Package observability provides metrics collection and tracing for the API proxy.
+// This file implements a Tracer backed by the OpenTelemetry SDK that exports over OTLP gRPC.
+package observability
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OTLPTracer creates OpenTelemetry spans and exports them in batches over OTLP gRPC.
+type OTLPTracer struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+}
+
+// NewOTLPTracer creates a tracer exporting to cfg.ExporterEndpoint, which is either host:port
+// or a URL. The connection is established lazily, so an unreachable collector does not fail
+// construction. A SamplingRate outside (0, 1) samples every trace; child spans follow the
+// sampling decision of their parent.
+func NewOTLPTracer(ctx context.Context, cfg TracerConfig) (*OTLPTracer, error) {
+	opts := []otlptracegrpc.Option{}
+	if strings.Contains(cfg.ExporterEndpoint, "://") {
+		opts = append(opts, otlptracegrpc.WithEndpointURL(cfg.ExporterEndpoint))
+	} else if cfg.ExporterEndpoint != "" {
+		opts = append(opts, otlptracegrpc.WithEndpoint(cfg.ExporterEndpoint))
+	}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlptracegrpc.WithHeaders(cfg.Headers))
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create otlp exporter: %w", err)
+	}
+
+	batchOpts := []sdktrace.BatchSpanProcessorOption{}
+	if cfg.BatchTimeoutMs > 0 {
+		batchOpts = append(batchOpts, sdktrace.WithBatchTimeout(time.Duration(cfg.BatchTimeoutMs)*time.Millisecond))
+	}
+	if cfg.MaxExportBatchSize > 0 {
+		batchOpts = append(batchOpts, sdktrace.WithMaxExportBatchSize(cfg.MaxExportBatchSize))
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if cfg.SamplingRate > 0 && cfg.SamplingRate < 1 {
+		sampler = sdktrace.TraceIDRatioBased(cfg.SamplingRate)
+	}
+
+	res := resource.NewSchemaless(
+		semconv.ServiceName(cfg.ServiceName),
+		semconv.ServiceVersion(cfg.ServiceVersion),
+	)
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter, batchOpts...),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(res),
+	)
+	return &OTLPTracer{
+		provider: provider,
+		tracer:   provider.Tracer("github.com/router-for-me/CLIProxyAPI/v6/internal/observability"),
+	}, nil
+}
+
+// Start starts a span as a child of the span in ctx, or of a remote parent extracted with
+// ExtractTraceContext.
+func (t *OTLPTracer) Start(ctx context.Context, name string, opts ...SpanOption) (context.Context, Span) {
+	cfg := &spanConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	startOpts := []trace.SpanStartOption{trace.WithSpanKind(otelSpanKind(cfg.kind))}
+	if len(cfg.attributes) > 0 {
+		startOpts = append(startOpts, trace.WithAttributes(otelAttributes(cfg.attributes)...))
+	}
+	for _, link := range cfg.links {
+		if sc, ok := otelSpanContext(link); ok {
+			startOpts = append(startOpts, trace.WithLinks(trace.Link{SpanContext: sc}))
+		}
+	}
+
+	ctx, span := t.tracer.Start(ctx, name, startOpts...)
+	return ctx, &otelSpan{span: span}
+}
+
+// ForceFlush exports all ended spans that have not been exported yet.
+func (t *OTLPTracer) ForceFlush(ctx context.Context) error {
+	return t.provider.ForceFlush(ctx)
+}
+
+// Shutdown flushes pending spans and closes the exporter connection.
+func (t *OTLPTracer) Shutdown(ctx context.Context) error {
+	return t.provider.Shutdown(ctx)
+}
+
+// otelSpan adapts an OpenTelemetry span to Span.
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s *otelSpan) End() { s.span.End() }
+
+func (s *otelSpan) SetStatus(code SpanStatusCode, description string) {
+	s.span.SetStatus(otelStatusCode(code), description)
+}
+
+func (s *otelSpan) SetAttribute(key string, value interface{}) {
+	s.span.SetAttributes(otelAttribute(key, value))
+}
+
+func (s *otelSpan) AddEvent(name string, attrs map[string]interface{}) {
+	s.span.AddEvent(name, trace.WithAttributes(otelAttributes(attrs)...))
+}
+
+func (s *otelSpan) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s *otelSpan) SpanContext() SpanContext {
+	sc := s.span.SpanContext()
+	return SpanContext{
+		TraceID:    sc.TraceID().String(),
+		SpanID:     sc.SpanID().String(),
+		TraceFlags: byte(sc.TraceFlags()),
+		TraceState: sc.TraceState().String(),
+		Remote:     sc.IsRemote(),
+	}
+}
+
+func otelSpanKind(kind SpanKind) trace.SpanKind {
+	switch kind {
+	case SpanKindServer:
+		return trace.SpanKindServer
+	case SpanKindClient:
+		return trace.SpanKindClient
+	case SpanKindProducer:
+		return trace.SpanKindProducer
+	case SpanKindConsumer:
+		return trace.SpanKindConsumer
+	default:
+		return trace.SpanKindInternal
+	}
+}
+
+func otelStatusCode(code SpanStatusCode) codes.Code {
+	switch code {
+	case SpanStatusOK:
+		return codes.Ok
+	case SpanStatusError:
+		return codes.Error
+	default:
+		return codes.Unset
+	}
+}
+
+// otelSpanContext parses a SpanContext produced by an OpenTelemetry span.
+func otelSpanContext(sc SpanContext) (trace.SpanContext, bool) {
+	traceID, err := trace.TraceIDFromHex(sc.TraceID)
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+	spanID, err := trace.SpanIDFromHex(sc.SpanID)
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.TraceFlags(sc.TraceFlags),
+		Remote:     sc.Remote,
+	}), true
+}
+
+func otelAttributes(attrs map[string]interface{}) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for key, value := range attrs {
+		kvs = append(kvs, otelAttribute(key, value))
+	}
+	return kvs
+}
+
+func otelAttribute(key string, value interface{}) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int32:
+		return attribute.Int64(key, int64(v))
+	case int64:
+		return attribute.Int64(key, v)
+	case float32:
+		return attribute.Float64(key, float64(v))
+	case float64:
+		return attribute.Float64(key, v)
+	case []string:
+		return attribute.StringSlice(key, v)
+	case fmt.Stringer:
+		return attribute.String(key, v.String())
+	default:
+		return attribute.String(key, fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,188 @@
+package observability
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// collectorStub is an in-process OTLP trace collector that records what it receives.
+type collectorStub struct {
+	collectortrace.UnimplementedTraceServiceServer
+
+	mu      sync.Mutex
+	spans   []*tracepb.Span
+	service string
+	headers metadata.MD
+}
+
+func (c *collectorStub) Export(ctx context.Context, req *collectortrace.ExportTraceServiceRequest) (*collectortrace.ExportTraceServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.headers, _ = metadata.FromIncomingContext(ctx)
+	for _, rs := range req.GetResourceSpans() {
+		for _, attr := range rs.GetResource().GetAttributes() {
+			if attr.GetKey() == "service.name" {
+				c.service = attr.GetValue().GetStringValue()
+			}
+		}
+		for _, ss := range rs.GetScopeSpans() {
+			c.spans = append(c.spans, ss.GetSpans()...)
+		}
+	}
+	return &collectortrace.ExportTraceServiceResponse{}, nil
+}
+
+func (c *collectorStub) span(name string) *tracepb.Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, span := range c.spans {
+		if span.GetName() == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func startCollectorStub(t *testing.T) (*collectorStub, string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	stub := &collectorStub{}
+	server := grpc.NewServer()
+	collectortrace.RegisterTraceServiceServer(server, stub)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+	return stub, lis.Addr().String()
+}
+
+func newTestOTLPTracer(t *testing.T, endpoint string) *OTLPTracer {
+	t.Helper()
+	cfg := DefaultTracerConfig()
+	cfg.Enabled = true
+	cfg.ExporterType = "otlp"
+	cfg.ExporterEndpoint = endpoint
+	cfg.Insecure = true
+	cfg.SamplingRate = 1
+	cfg.BatchTimeoutMs = 10
+	cfg.Headers = map[string]string{"x-collector-token": "secret"}
+	tracer, err := NewOTLPTracer(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("NewOTLPTracer: %v", err)
+	}
+	t.Cleanup(func() { _ = tracer.Shutdown(context.Background()) })
+	return tracer
+}
+
+func flushTracer(t *testing.T, tracer *OTLPTracer) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracer.ForceFlush(ctx); err != nil {
+		t.Fatalf("ForceFlush: %v", err)
+	}
+}
+
+func TestOTLPTracer_ExportsSpansToCollector(t *testing.T) {
+	stub, endpoint := startCollectorStub(t)
+	tracer := newTestOTLPTracer(t, endpoint)
+
+	_, span := tracer.Start(context.Background(), "provider.request",
+		WithSpanKind(SpanKindClient),
+		WithAttributes(map[string]interface{}{"provider": "codex", "attempt": 2}),
+	)
+	span.SetAttribute("model", "gpt-4o")
+	span.RecordError(errors.New("upstream timed out"))
+	span.End()
+
+	_, okSpan := tracer.Start(context.Background(), "cache.lookup")
+	okSpan.SetStatus(SpanStatusOK, "")
+	okSpan.End()
+
+	flushTracer(t, tracer)
+
+	got := stub.span("provider.request")
+	if got == nil {
+		t.Fatal("collector did not receive provider.request span")
+	}
+	if got.GetKind() != tracepb.Span_SPAN_KIND_CLIENT {
+		t.Fatalf("span kind = %v, want client", got.GetKind())
+	}
+	if got.GetStatus().GetCode() != tracepb.Status_STATUS_CODE_ERROR || got.GetStatus().GetMessage() != "upstream timed out" {
+		t.Fatalf("span status = %v, want error with message", got.GetStatus())
+	}
+	attrs := map[string]string{}
+	for _, attr := range got.GetAttributes() {
+		attrs[attr.GetKey()] = attr.GetValue().String()
+	}
+	for _, key := range []string{"provider", "attempt", "model"} {
+		if _, ok := attrs[key]; !ok {
+			t.Fatalf("span attributes %v missing %q", attrs, key)
+		}
+	}
+
+	cacheSpan := stub.span("cache.lookup")
+	if cacheSpan == nil {
+		t.Fatal("collector did not receive cache.lookup span")
+	}
+	if cacheSpan.GetKind() != tracepb.Span_SPAN_KIND_INTERNAL || cacheSpan.GetStatus().GetCode() != tracepb.Status_STATUS_CODE_OK {
+		t.Fatalf("cache span kind/status = %v/%v, want internal/ok", cacheSpan.GetKind(), cacheSpan.GetStatus())
+	}
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if stub.service != "shinapi" {
+		t.Fatalf("resource service.name = %q, want shinapi", stub.service)
+	}
+	if token := stub.headers.Get("x-collector-token"); len(token) != 1 || token[0] != "secret" {
+		t.Fatalf("collector headers = %v, want x-collector-token", stub.headers)
+	}
+}
+
+func TestOTLPTracer_PropagatesTraceparent(t *testing.T) {
+	stub, endpoint := startCollectorStub(t)
+	tracer := newTestOTLPTracer(t, endpoint)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const parentID = "00f067aa0ba902b7"
+	incoming := http.Header{}
+	incoming.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+
+	ctx := ExtractTraceContext(context.Background(), incoming)
+	ctx, span := tracer.Start(ctx, "http.request", WithSpanKind(SpanKindServer))
+	if sc := span.SpanContext(); sc.TraceID != traceID || sc.TraceFlags != 1 {
+		t.Fatalf("span context = %+v, want trace %s sampled", sc, traceID)
+	}
+
+	outgoing := http.Header{}
+	InjectTraceContext(ctx, outgoing)
+	want := "00-" + traceID + "-" + span.SpanContext().SpanID + "-01"
+	if got := outgoing.Get("traceparent"); got != want {
+		t.Fatalf("outgoing traceparent = %q, want %q", got, want)
+	}
+	span.End()
+	flushTracer(t, tracer)
+
+	got := stub.span("http.request")
+	if got == nil {
+		t.Fatal("collector did not receive http.request span")
+	}
+	if got.GetKind() != tracepb.Span_SPAN_KIND_SERVER {
+		t.Fatalf("span kind = %v, want server", got.GetKind())
+	}
+	if parent := hex.EncodeToString(got.GetParentSpanId()); parent != parentID {
+		t.Fatalf("exported parent span id = %q, want %q", parent, parentID)
+	}
+}
@@ -2,13 +2,18 @@
 package observability
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"math"
 	"net/http"
 	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/prometheus/common/expfmt"
 )
 
 // MetricsCollector collects and exposes Prometheus-compatible metrics.
@@ -20,9 +25,17 @@ type MetricsCollector struct {
 	requestDurations  map[string]*histogram // model -> latency histogram
 	tokensTotal       map[string]*uint64 // model:type -> count
 	activeRequests    int64
+	truncatedStreams  uint64
+	modelVariants     map[string]*uint64 // model:variant -> count
+	keyRequests       map[keySeries]*uint64
+	defaultRoutes     map[string]*uint64 // provider -> count
+	translationErrors map[string]*uint64 // from:to -> count
 
 	// Provider metrics
 	providerHealth    map[string]*providerMetrics
+	providerLatency   map[string]*histogram // provider -> request latency histogram
+	providerTTFB      map[string]*histogram // provider -> time to first byte histogram
+	providerDuration  map[string]*histogram // provider -> total stream duration histogram
 	
 	// Cache metrics
 	cacheHits         uint64
@@ -34,6 +47,11 @@ type MetricsCollector struct {
 	schedulerQueueSize    map[string]*int64
 	schedulerWaitTimeSum  uint64
 	schedulerWaitTimeCount uint64
+	globalInFlight         int64
+	admissionShed          uint64
+	retryBudgetAllowed     uint64
+	retryBudgetExhausted   uint64
+	retryBudgetAvailable   int64
 
 	// System metrics
 	startTime time.Time
@@ -41,6 +59,13 @@ type MetricsCollector struct {
 	config MetricsConfig
 }
 
+// keySeries identifies a per-API-key request counter. The key is stored hashed.
+type keySeries struct {
+	keyHash string
+	label   string
+	status  string
+}
+
 type providerMetrics struct {
 	requests     uint64
 	errors       uint64
@@ -48,14 +73,98 @@ type providerMetrics struct {
 	latencyCount uint64
 	lastError    time.Time
 	healthy      bool
+
+	// window is a ring of the most recent outcomes used for health decisions, while the
+	// counters above stay all-time for reporting. next is the slot the next outcome overwrites.
+	window []healthSample
+	next   int
+}
+
+type healthSample struct {
+	at     time.Time
+	failed bool
+}
+
+// record adds an outcome to the window, keeping at most size samples.
+func (pm *providerMetrics) record(sample healthSample, size int) {
+	if len(pm.window) > size {
+		// The window shrank; keep the newest samples in order.
+		ordered := append(pm.window[pm.next:len(pm.window):len(pm.window)], pm.window[:pm.next]...)
+		pm.window = append([]healthSample(nil), ordered[len(ordered)-size:]...)
+		pm.next = 0
+	}
+	if len(pm.window) < size {
+		pm.window = append(pm.window, sample)
+		pm.next = len(pm.window) % size
+		return
+	}
+	pm.window[pm.next] = sample
+	pm.next = (pm.next + 1) % size
 }
 
+// windowCounts returns the requests and errors in the window, ignoring samples older
+// than maxAge when maxAge is positive.
+func (pm *providerMetrics) windowCounts(now time.Time, maxAge time.Duration) (requests, errors uint64) {
+	for _, sample := range pm.window {
+		if maxAge > 0 && now.Sub(sample.at) > maxAge {
+			continue
+		}
+		requests++
+		if sample.failed {
+			errors++
+		}
+	}
+	return requests, errors
+}
+
+// histogram counts observations per bucket. buckets[i] holds the observations in
+// (bounds[i-1], bounds[i]] and the final bucket those above the last bound, so each
+// observation lands in exactly one bucket; cumulative derives the "le" series at export.
 type histogram struct {
-	buckets []uint64 // count per bucket
+	buckets []uint64 // non-cumulative count per bucket, len(bounds)+1
 	sum     uint64
 	count   uint64
 }
 
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{buckets: make([]uint64, len(bounds)+1)}
+}
+
+// observe adds value to the bucket of the first bound it does not exceed, or to the
+// overflow bucket past the last bound. bounds must be sorted ascending.
+func (h *histogram) observe(bounds []float64, value float64) {
+	h.sum += uint64(value)
+	h.count++
+	h.buckets[sort.SearchFloat64s(bounds, value)]++
+}
+
+// cumulative returns the count of observations at or below each bound followed by the
+// total for the +Inf bucket.
+func (h *histogram) cumulative() []uint64 {
+	out := make([]uint64, len(h.buckets))
+	var total uint64
+	for i, n := range h.buckets {
+		total += n
+		out[i] = total
+	}
+	return out
+}
+
+// normalizeBounds returns bounds sorted ascending without duplicates, as required by
+// histogram.observe and the Prometheus exposition format.
+func normalizeBounds(bounds []float64) []float64 {
+	sorted := append([]float64(nil), bounds...)
+	sort.Float64s(sorted)
+	out := sorted[:0]
+	for _, b := range sorted {
+		if math.IsNaN(b) || math.IsInf(b, 0) || (len(out) > 0 && b == out[len(out)-1]) {
+			continue
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
 // MetricsConfig configures the metrics collector.
 type MetricsConfig struct {
 	// Enabled controls whether metrics collection is active.
@@ -68,6 +177,65 @@ type MetricsConfig struct {
 	Subsystem string `yaml:"subsystem" json:"subsystem"`
 	// HistogramBuckets defines latency histogram buckets in milliseconds.
 	HistogramBuckets []float64 `yaml:"histogram-buckets" json:"histogram_buckets"`
+	// UseOfficialClient records to the official Prometheus client instead of the custom collector.
+	UseOfficialClient bool `yaml:"use-official-client" json:"use_official_client"`
+	// ProviderHealth controls when providers are reported unhealthy.
+	ProviderHealth ProviderHealthPolicy `yaml:"provider-health" json:"provider_health"`
+}
+
+// ProviderHealthPolicy decides when a provider is reported unhealthy. Separate trip and
+// recover thresholds give hysteresis, so a provider hovering around one rate does not flap.
+type ProviderHealthPolicy struct {
+	// MinSamples is the warmup: a provider stays healthy until it has served this many requests.
+	MinSamples uint64 `yaml:"min-samples" json:"min_samples"`
+	// TripErrorRate marks a healthy provider unhealthy once its error rate (0-1) reaches it.
+	TripErrorRate float64 `yaml:"trip-error-rate" json:"trip_error_rate"`
+	// RecoverErrorRate marks an unhealthy provider healthy once its error rate falls to it.
+	RecoverErrorRate float64 `yaml:"recover-error-rate" json:"recover_error_rate"`
+	// WindowSize is how many recent requests the error rate is computed over.
+	WindowSize int `yaml:"window-size" json:"window_size"`
+	// WindowSeconds, when positive, also drops requests older than this from the window.
+	WindowSeconds int `yaml:"window-seconds" json:"window_seconds"`
+}
+
+// DefaultProviderHealthPolicy returns the default warmup and thresholds.
+func DefaultProviderHealthPolicy() ProviderHealthPolicy {
+	return ProviderHealthPolicy{
+		MinSamples:       20,
+		TripErrorRate:    0.5,
+		RecoverErrorRate: 0.25,
+		WindowSize:       100,
+	}
+}
+
+// normalized fills unset or invalid fields from the defaults and keeps RecoverErrorRate
+// at or below TripErrorRate.
+func (p ProviderHealthPolicy) normalized() ProviderHealthPolicy {
+	def := DefaultProviderHealthPolicy()
+	if p.MinSamples == 0 {
+		p.MinSamples = def.MinSamples
+	}
+	if p.TripErrorRate <= 0 || p.TripErrorRate > 1 {
+		p.TripErrorRate = def.TripErrorRate
+	}
+	if p.RecoverErrorRate <= 0 || p.RecoverErrorRate > p.TripErrorRate {
+		p.RecoverErrorRate = p.TripErrorRate / 2
+	}
+	if p.WindowSize <= 0 {
+		p.WindowSize = def.WindowSize
+	}
+	if uint64(p.WindowSize) < p.MinSamples {
+		// A window smaller than the warmup could never leave it.
+		p.WindowSize = int(p.MinSamples)
+	}
+	if p.WindowSeconds < 0 {
+		p.WindowSeconds = 0
+	}
+	return p
+}
+
+func (p ProviderHealthPolicy) windowAge() time.Duration {
+	return time.Duration(p.WindowSeconds) * time.Second
 }
 
 // DefaultMetricsConfig returns sensible defaults.
@@ -80,6 +248,7 @@ func DefaultMetricsConfig() MetricsConfig {
 		HistogramBuckets: []float64{
 			5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000,
 		},
+		ProviderHealth: DefaultProviderHealthPolicy(),
 	}
 }
 
@@ -91,15 +260,24 @@ func NewMetricsCollector(cfg MetricsConfig) *MetricsCollector {
 	if cfg.Subsystem == "" {
 		cfg.Subsystem = "proxy"
 	}
+	cfg.HistogramBuckets = normalizeBounds(cfg.HistogramBuckets)
 	if len(cfg.HistogramBuckets) == 0 {
 		cfg.HistogramBuckets = DefaultMetricsConfig().HistogramBuckets
 	}
+	cfg.ProviderHealth = cfg.ProviderHealth.normalized()
 
 	return &MetricsCollector{
 		requestsTotal:      make(map[string]*uint64),
 		requestDurations:   make(map[string]*histogram),
 		tokensTotal:        make(map[string]*uint64),
+		modelVariants:      make(map[string]*uint64),
+		keyRequests:        make(map[keySeries]*uint64),
+		defaultRoutes:      make(map[string]*uint64),
+		translationErrors:  make(map[string]*uint64),
 		providerHealth:     make(map[string]*providerMetrics),
+		providerLatency:    make(map[string]*histogram),
+		providerTTFB:       make(map[string]*histogram),
+		providerDuration:   make(map[string]*histogram),
 		schedulerQueueSize: make(map[string]*int64),
 		startTime:          time.Now(),
 		config:             cfg,
@@ -121,24 +299,9 @@ func (m *MetricsCollector) RecordRequest(model, status string, durationMs float6
 
 	// Record duration histogram
 	if m.requestDurations[model] == nil {
-		m.requestDurations[model] = &histogram{
-			buckets: make([]uint64, len(m.config.HistogramBuckets)+1),
-		}
-	}
-	h := m.requestDurations[model]
-	h.sum += uint64(durationMs)
-	h.count++
-
-	// Find bucket
-	for i, bound := range m.config.HistogramBuckets {
-		if durationMs <= bound {
-			h.buckets[i]++
-			break
-		}
-		if i == len(m.config.HistogramBuckets)-1 {
-			h.buckets[i+1]++
-		}
+		m.requestDurations[model] = newHistogram(m.config.HistogramBuckets)
 	}
+	m.requestDurations[model].observe(m.config.HistogramBuckets, durationMs)
 
 	// Record tokens
 	if tokens > 0 {
@@ -151,6 +314,86 @@ func (m *MetricsCollector) RecordRequest(model, status string, durationMs float6
 	}
 }
 
+// RecordTokens records token usage by type, such as reasoning or completion.
+func (m *MetricsCollector) RecordTokens(model, tokenType string, count int64) {
+	if count <= 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := model + ":" + tokenType
+	if m.tokensTotal[key] == nil {
+		var v uint64
+		m.tokensTotal[key] = &v
+	}
+	atomic.AddUint64(m.tokensTotal[key], uint64(count))
+}
+
+// RecordModelVariant records which experiment variant served a logical model.
+func (m *MetricsCollector) RecordModelVariant(model, variant string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := model + ":" + variant
+	if m.modelVariants[key] == nil {
+		var v uint64
+		m.modelVariants[key] = &v
+	}
+	atomic.AddUint64(m.modelVariants[key], 1)
+}
+
+// RecordKeyRequest records a request authenticated with apiKey. The key is exported only as
+// HashAPIKey(apiKey), alongside its configured label.
+func (m *MetricsCollector) RecordKeyRequest(apiKey, label, status string) {
+	if apiKey == "" {
+		return
+	}
+	series := keySeries{keyHash: HashAPIKey(apiKey), label: label, status: status}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.keyRequests[series] == nil {
+		var v uint64
+		m.keyRequests[series] = &v
+	}
+	atomic.AddUint64(m.keyRequests[series], 1)
+}
+
+// HashAPIKey returns a short, stable identifier for an API key that is safe to expose.
+func HashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:6])
+}
+
+// RecordDefaultProviderRoute records a request routed to the default provider because
+// no routing rule or registered provider matched its model.
+func (m *MetricsCollector) RecordDefaultProviderRoute(provider string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.defaultRoutes[provider] == nil {
+		var v uint64
+		m.defaultRoutes[provider] = &v
+	}
+	atomic.AddUint64(m.defaultRoutes[provider], 1)
+}
+
+// RecordTranslationError counts a response translated from one format to another that did
+// not have the shape the target format requires.
+func (m *MetricsCollector) RecordTranslationError(from, to string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := from + ":" + to
+	if m.translationErrors[key] == nil {
+		var v uint64
+		m.translationErrors[key] = &v
+	}
+	atomic.AddUint64(m.translationErrors[key], 1)
+}
+
 // RecordProviderRequest records a provider request.
 func (m *MetricsCollector) RecordProviderRequest(provider string, durationMs float64, success bool) {
 	m.mu.Lock()
@@ -163,18 +406,59 @@ func (m *MetricsCollector) RecordProviderRequest(provider string, durationMs flo
 	pm.requests++
 	pm.latencySum += uint64(durationMs)
 	pm.latencyCount++
+	if m.providerLatency[provider] == nil {
+		m.providerLatency[provider] = newHistogram(m.config.HistogramBuckets)
+	}
+	m.providerLatency[provider].observe(m.config.HistogramBuckets, durationMs)
 
+	now := time.Now()
 	if !success {
 		pm.errors++
-		pm.lastError = time.Now()
-		// Mark unhealthy if error rate > 50% in last 10 requests
-		if pm.requests >= 10 {
-			errorRate := float64(pm.errors) / float64(pm.requests)
-			pm.healthy = errorRate < 0.5
-		}
-	} else {
-		pm.healthy = true
+		pm.lastError = now
+	}
+	pm.record(healthSample{at: now, failed: !success}, m.config.ProviderHealth.WindowSize)
+	pm.healthy = m.config.ProviderHealth.evaluate(pm, now)
+}
+
+// RecordProviderLatency records how long a provider took to send the first byte of a stream
+// and to finish it. Both are exported in seconds.
+func (m *MetricsCollector) RecordProviderLatency(provider string, ttfb, total time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bounds := m.config.HistogramBuckets
+	if m.providerTTFB[provider] == nil {
+		m.providerTTFB[provider] = newHistogram(bounds)
+	}
+	if m.providerDuration[provider] == nil {
+		m.providerDuration[provider] = newHistogram(bounds)
 	}
+	m.providerTTFB[provider].observe(bounds, float64(ttfb)/float64(time.Millisecond))
+	m.providerDuration[provider].observe(bounds, float64(total)/float64(time.Millisecond))
+}
+
+// SetProviderHealthPolicy replaces the provider health policy. Current health states are
+// kept and re-evaluated on each provider's next request.
+func (m *MetricsCollector) SetProviderHealthPolicy(policy ProviderHealthPolicy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config.ProviderHealth = policy.normalized()
+}
+
+// evaluate returns the provider's next health state from the error rate over its recent
+// window. While the window holds fewer than MinSamples requests the provider stays healthy;
+// otherwise a healthy provider trips at TripErrorRate and an unhealthy one only recovers
+// once the error rate falls to RecoverErrorRate.
+func (p ProviderHealthPolicy) evaluate(pm *providerMetrics, now time.Time) bool {
+	requests, errors := pm.windowCounts(now, p.windowAge())
+	if requests < p.MinSamples {
+		return true
+	}
+	errorRate := float64(errors) / float64(requests)
+	if pm.healthy {
+		return errorRate < p.TripErrorRate
+	}
+	return errorRate <= p.RecoverErrorRate
 }
 
 // RecordCacheAccess records a cache access.
@@ -188,7 +472,7 @@ func (m *MetricsCollector) RecordCacheAccess(hit bool, latencyMs float64) {
 	atomic.AddUint64(&m.cacheLatencyCount, 1)
 }
 
-// RecordSchedulerQueue records scheduler queue size.
+// RecordSchedulerQueue records scheduler queue size. The key is hashed on export.
 func (m *MetricsCollector) RecordSchedulerQueue(apiKey string, size int64) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -206,6 +490,62 @@ func (m *MetricsCollector) RecordSchedulerWait(durationMs float64) {
 	atomic.AddUint64(&m.schedulerWaitTimeCount, 1)
 }
 
+// SetGlobalInFlight records the number of upstream calls admitted process-wide.
+func (m *MetricsCollector) SetGlobalInFlight(n int64) {
+	atomic.StoreInt64(&m.globalInFlight, n)
+}
+
+// RecordAdmissionShed counts an upstream call shed by the admission controller.
+func (m *MetricsCollector) RecordAdmissionShed() {
+	atomic.AddUint64(&m.admissionShed, 1)
+}
+
+// RecordRetryBudget counts a retry allowed or denied by the global retry budget and records
+// the whole retries left in it; a negative available means the budget is unlimited.
+func (m *MetricsCollector) RecordRetryBudget(allowed bool, available int64) {
+	if allowed {
+		atomic.AddUint64(&m.retryBudgetAllowed, 1)
+	} else {
+		atomic.AddUint64(&m.retryBudgetExhausted, 1)
+	}
+	atomic.StoreInt64(&m.retryBudgetAvailable, available)
+}
+
+// RecordTruncatedStream counts a stream that ended without a terminal event.
+func (m *MetricsCollector) RecordTruncatedStream() {
+	atomic.AddUint64(&m.truncatedStreams, 1)
+}
+
+// Reset zeroes all collected series. Active requests and uptime are preserved since they
+// describe current state rather than accumulated counts.
+func (m *MetricsCollector) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requestsTotal = make(map[string]*uint64)
+	m.requestDurations = make(map[string]*histogram)
+	m.tokensTotal = make(map[string]*uint64)
+	m.modelVariants = make(map[string]*uint64)
+	m.keyRequests = make(map[keySeries]*uint64)
+	m.defaultRoutes = make(map[string]*uint64)
+	m.translationErrors = make(map[string]*uint64)
+	m.providerHealth = make(map[string]*providerMetrics)
+	m.providerLatency = make(map[string]*histogram)
+	m.providerTTFB = make(map[string]*histogram)
+	m.providerDuration = make(map[string]*histogram)
+	m.schedulerQueueSize = make(map[string]*int64)
+	atomic.StoreUint64(&m.truncatedStreams, 0)
+	atomic.StoreUint64(&m.cacheHits, 0)
+	atomic.StoreUint64(&m.cacheMisses, 0)
+	atomic.StoreUint64(&m.cacheLatencySum, 0)
+	atomic.StoreUint64(&m.cacheLatencyCount, 0)
+	atomic.StoreUint64(&m.schedulerWaitTimeSum, 0)
+	atomic.StoreUint64(&m.schedulerWaitTimeCount, 0)
+	atomic.StoreUint64(&m.admissionShed, 0)
+	atomic.StoreUint64(&m.retryBudgetAllowed, 0)
+	atomic.StoreUint64(&m.retryBudgetExhausted, 0)
+}
+
 // IncrementActiveRequests increments active request count.
 func (m *MetricsCollector) IncrementActiveRequests() {
 	atomic.AddInt64(&m.activeRequests, 1)
@@ -221,6 +561,8 @@ func (m *MetricsCollector) GetProviderHealth() map[string]ProviderHealthStatus {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	now := time.Now()
+	windowAge := m.config.ProviderHealth.windowAge()
 	result := make(map[string]ProviderHealthStatus)
 	for provider, pm := range m.providerHealth {
 		var avgLatency float64
@@ -232,13 +574,21 @@ func (m *MetricsCollector) GetProviderHealth() map[string]ProviderHealthStatus {
 			errorRate = float64(pm.errors) / float64(pm.requests) * 100
 		}
 
+		windowRequests, windowErrors := pm.windowCounts(now, windowAge)
+		var windowErrorRate float64
+		if windowRequests > 0 {
+			windowErrorRate = float64(windowErrors) / float64(windowRequests) * 100
+		}
+
 		result[provider] = ProviderHealthStatus{
-			Healthy:       pm.healthy,
-			Requests:      pm.requests,
-			Errors:        pm.errors,
-			ErrorRate:     errorRate,
-			AvgLatencyMs:  avgLatency,
-			LastErrorTime: pm.lastError,
+			Healthy:         pm.healthy,
+			Requests:        pm.requests,
+			Errors:          pm.errors,
+			ErrorRate:       errorRate,
+			WindowRequests:  windowRequests,
+			WindowErrorRate: windowErrorRate,
+			AvgLatencyMs:    avgLatency,
+			LastErrorTime:   pm.lastError,
 		}
 	}
 
@@ -246,18 +596,32 @@ func (m *MetricsCollector) GetProviderHealth() map[string]ProviderHealthStatus {
 }
 
 // ProviderHealthStatus represents provider health.
+// Requests, Errors and ErrorRate are all-time; health is decided on the window.
 type ProviderHealthStatus struct {
-	Healthy       bool      `json:"healthy"`
-	Requests      uint64    `json:"requests"`
-	Errors        uint64    `json:"errors"`
-	ErrorRate     float64   `json:"error_rate_percent"`
-	AvgLatencyMs  float64   `json:"avg_latency_ms"`
-	LastErrorTime time.Time `json:"last_error_time,omitempty"`
+	Healthy         bool      `json:"healthy"`
+	Requests        uint64    `json:"requests"`
+	Errors          uint64    `json:"errors"`
+	ErrorRate       float64   `json:"error_rate_percent"`
+	WindowRequests  uint64    `json:"window_requests"`
+	WindowErrorRate float64   `json:"window_error_rate_percent"`
+	AvgLatencyMs    float64   `json:"avg_latency_ms"`
+	LastErrorTime   time.Time `json:"last_error_time,omitempty"`
 }
 
-// Handler returns an HTTP handler for the metrics endpoint.
+// Handler returns an HTTP handler for the metrics endpoint. It serves the Prometheus text
+// format unless the Accept header asks for application/openmetrics-text.
 func (m *MetricsCollector) Handler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if expfmt.NegotiateIncludingOpenMetrics(r.Header).FormatType() == expfmt.TypeOpenMetrics {
+			out, err := m.ExportOpenMetrics()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", string(openMetricsFormat))
+			w.Write([]byte(out))
+			return
+		}
 		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
 		w.Write([]byte(m.Export()))
 	})
@@ -283,22 +647,21 @@ func (m *MetricsCollector) Export() string {
 			status = parts[1]
 		}
 		sb.WriteString(fmt.Sprintf("%s_requests_total{model=\"%s\",status=\"%s\"} %d\n",
-			prefix, model, status, atomic.LoadUint64(count)))
+			prefix, escapeLabelValue(model), escapeLabelValue(status), atomic.LoadUint64(count)))
 	}
 
 	// Request duration histograms
 	sb.WriteString(fmt.Sprintf("# HELP %s_request_duration_milliseconds Request duration histogram\n", prefix))
 	sb.WriteString(fmt.Sprintf("# TYPE %s_request_duration_milliseconds histogram\n", prefix))
-	for model, h := range m.requestDurations {
-		var cumulative uint64
+	for rawModel, h := range m.requestDurations {
+		model := escapeLabelValue(rawModel)
+		cumulative := h.cumulative()
 		for i, bucket := range m.config.HistogramBuckets {
-			cumulative += h.buckets[i]
-			sb.WriteString(fmt.Sprintf("%s_request_duration_milliseconds_bucket{model=\"%s\",le=\"%.0f\"} %d\n",
-				prefix, model, bucket, cumulative))
+			sb.WriteString(fmt.Sprintf("%s_request_duration_milliseconds_bucket{model=\"%s\",le=\"%g\"} %d\n",
+				prefix, model, bucket, cumulative[i]))
 		}
-		cumulative += h.buckets[len(m.config.HistogramBuckets)]
 		sb.WriteString(fmt.Sprintf("%s_request_duration_milliseconds_bucket{model=\"%s\",le=\"+Inf\"} %d\n",
-			prefix, model, cumulative))
+			prefix, model, cumulative[len(m.config.HistogramBuckets)]))
 		sb.WriteString(fmt.Sprintf("%s_request_duration_milliseconds_sum{model=\"%s\"} %d\n",
 			prefix, model, h.sum))
 		sb.WriteString(fmt.Sprintf("%s_request_duration_milliseconds_count{model=\"%s\"} %d\n",
@@ -309,12 +672,56 @@ func (m *MetricsCollector) Export() string {
 	sb.WriteString(fmt.Sprintf("# HELP %s_tokens_total Total tokens processed\n", prefix))
 	sb.WriteString(fmt.Sprintf("# TYPE %s_tokens_total counter\n", prefix))
 	for key, count := range m.tokensTotal {
+		// The type never contains a colon, unlike some model names.
+		sep := strings.LastIndex(key, ":")
+		model, tokenType := key[:sep], key[sep+1:]
+		sb.WriteString(fmt.Sprintf("%s_tokens_total{model=\"%s\",type=\"%s\"} %d\n",
+			prefix, escapeLabelValue(model), escapeLabelValue(tokenType), atomic.LoadUint64(count)))
+	}
+
+	// Experiment variant counters
+	sb.WriteString(fmt.Sprintf("# HELP %s_model_variant_requests_total Requests served per experiment variant\n", prefix))
+	sb.WriteString(fmt.Sprintf("# TYPE %s_model_variant_requests_total counter\n", prefix))
+	for key, count := range m.modelVariants {
 		parts := strings.SplitN(key, ":", 2)
-		model := parts[0]
-		sb.WriteString(fmt.Sprintf("%s_tokens_total{model=\"%s\"} %d\n",
-			prefix, model, atomic.LoadUint64(count)))
+		model, variant := parts[0], ""
+		if len(parts) > 1 {
+			variant = parts[1]
+		}
+		sb.WriteString(fmt.Sprintf("%s_model_variant_requests_total{model=\"%s\",variant=\"%s\"} %d\n",
+			prefix, escapeLabelValue(model), escapeLabelValue(variant), atomic.LoadUint64(count)))
 	}
 
+	// Per-key requests
+	sb.WriteString(fmt.Sprintf("# HELP %s_api_key_requests_total Requests per client API key\n", prefix))
+	sb.WriteString(fmt.Sprintf("# TYPE %s_api_key_requests_total counter\n", prefix))
+	for series, count := range m.keyRequests {
+		sb.WriteString(fmt.Sprintf("%s_api_key_requests_total{api_key=\"%s\",key_label=\"%s\",status=\"%s\"} %d\n",
+			prefix, series.keyHash, escapeLabelValue(series.label), escapeLabelValue(series.status), atomic.LoadUint64(count)))
+	}
+
+	// Default provider routes
+	sb.WriteString(fmt.Sprintf("# HELP %s_default_provider_requests_total Requests routed to the default provider for unknown models\n", prefix))
+	sb.WriteString(fmt.Sprintf("# TYPE %s_default_provider_requests_total counter\n", prefix))
+	for provider, count := range m.defaultRoutes {
+		sb.WriteString(fmt.Sprintf("%s_default_provider_requests_total{provider=\"%s\"} %d\n",
+			prefix, escapeLabelValue(provider), atomic.LoadUint64(count)))
+	}
+
+	// Translation errors
+	sb.WriteString(fmt.Sprintf("# HELP %s_translation_errors_total Translated responses that did not match the target format\n", prefix))
+	sb.WriteString(fmt.Sprintf("# TYPE %s_translation_errors_total counter\n", prefix))
+	for key, count := range m.translationErrors {
+		from, to, _ := strings.Cut(key, ":")
+		sb.WriteString(fmt.Sprintf("%s_translation_errors_total{from=\"%s\",to=\"%s\"} %d\n",
+			prefix, escapeLabelValue(from), escapeLabelValue(to), atomic.LoadUint64(count)))
+	}
+
+	// Truncated streams
+	sb.WriteString(fmt.Sprintf("# HELP %s_truncated_streams_total Streams closed upstream without a terminal event\n", prefix))
+	sb.WriteString(fmt.Sprintf("# TYPE %s_truncated_streams_total counter\n", prefix))
+	sb.WriteString(fmt.Sprintf("%s_truncated_streams_total %d\n", prefix, atomic.LoadUint64(&m.truncatedStreams)))
+
 	// Active requests gauge
 	sb.WriteString(fmt.Sprintf("# HELP %s_active_requests Current number of active requests\n", prefix))
 	sb.WriteString(fmt.Sprintf("# TYPE %s_active_requests gauge\n", prefix))
@@ -336,6 +743,7 @@ func (m *MetricsCollector) Export() string {
 	
 	for _, provider := range providers {
 		pm := m.providerHealth[provider]
+		provider := escapeLabelValue(provider)
 		healthy := 0
 		if pm.healthy {
 			healthy = 1
@@ -345,6 +753,11 @@ func (m *MetricsCollector) Export() string {
 		sb.WriteString(fmt.Sprintf("%s_provider_errors_total{provider=\"%s\"} %d\n", prefix, provider, pm.errors))
 	}
 
+	// Provider latency
+	m.writeProviderHistogram(&sb, prefix+"_provider_request_duration_milliseconds", "Provider request latency", m.providerLatency, 1)
+	m.writeProviderHistogram(&sb, prefix+"_provider_ttfb_seconds", "Time until a provider stream sent its first byte", m.providerTTFB, 1000)
+	m.writeProviderHistogram(&sb, prefix+"_provider_duration_seconds", "Total duration of a provider stream", m.providerDuration, 1000)
+
 	// Cache metrics
 	sb.WriteString(fmt.Sprintf("# HELP %s_cache_hits_total Cache hits\n", prefix))
 	sb.WriteString(fmt.Sprintf("# TYPE %s_cache_hits_total counter\n", prefix))
@@ -358,13 +771,31 @@ func (m *MetricsCollector) Export() string {
 	sb.WriteString(fmt.Sprintf("# TYPE %s_scheduler_queue_size gauge\n", prefix))
 	for apiKey, size := range m.schedulerQueueSize {
 		// Hash API key for privacy
-		keyHash := apiKey
-		if len(keyHash) > 8 {
-			keyHash = keyHash[:8] + "..."
-		}
 		sb.WriteString(fmt.Sprintf("%s_scheduler_queue_size{api_key=\"%s\"} %d\n",
-			prefix, keyHash, atomic.LoadInt64(size)))
+			prefix, HashAPIKey(apiKey), atomic.LoadInt64(size)))
 	}
+	sb.WriteString(fmt.Sprintf("# HELP %s_scheduler_wait_milliseconds Time requests waited in the scheduler queue\n", prefix))
+	sb.WriteString(fmt.Sprintf("# TYPE %s_scheduler_wait_milliseconds summary\n", prefix))
+	sb.WriteString(fmt.Sprintf("%s_scheduler_wait_milliseconds_sum %.3f\n",
+		prefix, float64(atomic.LoadUint64(&m.schedulerWaitTimeSum))/1000))
+	sb.WriteString(fmt.Sprintf("%s_scheduler_wait_milliseconds_count %d\n",
+		prefix, atomic.LoadUint64(&m.schedulerWaitTimeCount)))
+
+	sb.WriteString(fmt.Sprintf("# HELP %s_global_inflight Upstream calls currently admitted by the global admission controller\n", prefix))
+	sb.WriteString(fmt.Sprintf("# TYPE %s_global_inflight gauge\n", prefix))
+	sb.WriteString(fmt.Sprintf("%s_global_inflight %d\n", prefix, atomic.LoadInt64(&m.globalInFlight)))
+	sb.WriteString(fmt.Sprintf("# HELP %s_admission_shed_total Upstream calls rejected at the global concurrency limit\n", prefix))
+	sb.WriteString(fmt.Sprintf("# TYPE %s_admission_shed_total counter\n", prefix))
+	sb.WriteString(fmt.Sprintf("%s_admission_shed_total %d\n", prefix, atomic.LoadUint64(&m.admissionShed)))
+	sb.WriteString(fmt.Sprintf("# HELP %s_retry_budget_allowed_total Retries granted by the global retry budget\n", prefix))
+	sb.WriteString(fmt.Sprintf("# TYPE %s_retry_budget_allowed_total counter\n", prefix))
+	sb.WriteString(fmt.Sprintf("%s_retry_budget_allowed_total %d\n", prefix, atomic.LoadUint64(&m.retryBudgetAllowed)))
+	sb.WriteString(fmt.Sprintf("# HELP %s_retry_budget_exhausted_total Retries skipped because the global retry budget was exhausted\n", prefix))
+	sb.WriteString(fmt.Sprintf("# TYPE %s_retry_budget_exhausted_total counter\n", prefix))
+	sb.WriteString(fmt.Sprintf("%s_retry_budget_exhausted_total %d\n", prefix, atomic.LoadUint64(&m.retryBudgetExhausted)))
+	sb.WriteString(fmt.Sprintf("# HELP %s_retry_budget_available Retries left in the global retry budget (-1 when unlimited)\n", prefix))
+	sb.WriteString(fmt.Sprintf("# TYPE %s_retry_budget_available gauge\n", prefix))
+	sb.WriteString(fmt.Sprintf("%s_retry_budget_available %d\n", prefix, atomic.LoadInt64(&m.retryBudgetAvailable)))
 
 	// Uptime
 	sb.WriteString(fmt.Sprintf("# HELP %s_uptime_seconds Server uptime in seconds\n", prefix))
@@ -374,6 +805,40 @@ func (m *MetricsCollector) Export() string {
 	return sb.String()
 }
 
+// writeProviderHistogram writes per-provider histograms recorded in milliseconds, dividing
+// bounds and sums by unit: 1 exports milliseconds, 1000 seconds.
+func (m *MetricsCollector) writeProviderHistogram(sb *strings.Builder, name, help string, series map[string]*histogram, unit float64) {
+	sb.WriteString(fmt.Sprintf("# HELP %s %s\n", name, help))
+	sb.WriteString(fmt.Sprintf("# TYPE %s histogram\n", name))
+
+	providers := make([]string, 0, len(series))
+	for p := range series {
+		providers = append(providers, p)
+	}
+	sort.Strings(providers)
+
+	for _, provider := range providers {
+		h := series[provider]
+		provider := escapeLabelValue(provider)
+		cumulative := h.cumulative()
+		for i, bucket := range m.config.HistogramBuckets {
+			sb.WriteString(fmt.Sprintf("%s_bucket{provider=\"%s\",le=\"%g\"} %d\n", name, provider, bucket/unit, cumulative[i]))
+		}
+		sb.WriteString(fmt.Sprintf("%s_bucket{provider=\"%s\",le=\"+Inf\"} %d\n", name, provider, cumulative[len(m.config.HistogramBuckets)]))
+		sb.WriteString(fmt.Sprintf("%s_sum{provider=\"%s\"} %.3f\n", name, provider, float64(h.sum)/unit))
+		sb.WriteString(fmt.Sprintf("%s_count{provider=\"%s\"} %d\n", name, provider, h.count))
+	}
+}
+
+// labelValueEscaper escapes the characters the text exposition format does not allow
+// unescaped in a label value.
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabelValue escapes v for use between the quotes of a label value.
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
+
 // Global metrics collector
 var (
 	globalMetrics     *MetricsCollector
@@ -6,6 +6,7 @@ package observability
 import (
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -15,14 +16,28 @@ import (
 // PrometheusMetrics provides an official Prometheus client implementation.
 // This coexists with the existing MetricsCollector for gradual migration.
 type PrometheusMetrics struct {
-	requestsTotal   *prometheus.CounterVec
-	requestDuration *prometheus.HistogramVec
-	tokensTotal     *prometheus.CounterVec
-	activeRequests  prometheus.Gauge
-	providerHealth  *prometheus.GaugeVec
-	providerErrors  *prometheus.CounterVec
-	cacheHits       prometheus.Counter
-	cacheMisses     prometheus.Counter
+	requestsTotal    *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	tokensTotal      *prometheus.CounterVec
+	activeRequests   prometheus.Gauge
+	providerHealth   *prometheus.GaugeVec
+	providerErrors   *prometheus.CounterVec
+	providerTTFB     *prometheus.HistogramVec
+	providerDuration *prometheus.HistogramVec
+	cacheHits        prometheus.Counter
+	cacheMisses      prometheus.Counter
+	cacheDuration    *prometheus.HistogramVec
+	globalInFlight   prometheus.Gauge
+	admissionShed    prometheus.Counter
+	retryBudget      *prometheus.CounterVec
+	retryAvailable   prometheus.Gauge
+	translationErrs  *prometheus.CounterVec
+	schedulerQueue   *prometheus.GaugeVec
+	schedulerWait    prometheus.Histogram
+	keyRequests      *prometheus.CounterVec
+	modelVariants    *prometheus.CounterVec
+	defaultRoutes    *prometheus.CounterVec
+	truncatedStreams prometheus.Counter
 
 	// Agentic metrics
 	agentIterations    *prometheus.CounterVec
@@ -105,6 +120,22 @@ func NewPrometheusMetrics(cfg PrometheusConfig) *PrometheusMetrics {
 			Help:      "Total provider errors",
 		}, []string{"provider"}),
 
+		providerTTFB: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: cfg.Subsystem,
+			Name:      "provider_ttfb_seconds",
+			Help:      "Time until a provider stream sent its first byte, in seconds",
+			Buckets:   cfg.HistogramBuckets,
+		}, []string{"provider"}),
+
+		providerDuration: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: cfg.Subsystem,
+			Name:      "provider_duration_seconds",
+			Help:      "Total duration of a provider stream, in seconds",
+			Buckets:   cfg.HistogramBuckets,
+		}, []string{"provider"}),
+
 		cacheHits: promauto.NewCounter(prometheus.CounterOpts{
 			Namespace: cfg.Namespace,
 			Subsystem: cfg.Subsystem,
@@ -119,6 +150,92 @@ func NewPrometheusMetrics(cfg PrometheusConfig) *PrometheusMetrics {
 			Help:      "Total cache misses",
 		}),
 
+		cacheDuration: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: cfg.Subsystem,
+			Name:      "cache_operation_duration_seconds",
+			Help:      "Cache lookup duration in seconds by result (hit, miss)",
+			Buckets:   []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1},
+		}, []string{"result"}),
+
+		globalInFlight: promauto.NewGauge(prometheus.GaugeOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: cfg.Subsystem,
+			Name:      "global_inflight",
+			Help:      "Upstream calls currently admitted by the global admission controller",
+		}),
+
+		admissionShed: promauto.NewCounter(prometheus.CounterOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: cfg.Subsystem,
+			Name:      "admission_shed_total",
+			Help:      "Upstream calls rejected because the proxy was at its global concurrency limit",
+		}),
+
+		retryBudget: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: cfg.Subsystem,
+			Name:      "retry_budget_total",
+			Help:      "Retries consulted against the global retry budget by outcome (allowed, exhausted)",
+		}, []string{"outcome"}),
+
+		retryAvailable: promauto.NewGauge(prometheus.GaugeOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: cfg.Subsystem,
+			Name:      "retry_budget_available",
+			Help:      "Retries left in the global retry budget (-1 when unlimited)",
+		}),
+
+		translationErrs: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: cfg.Subsystem,
+			Name:      "translation_errors_total",
+			Help:      "Translated responses that did not match the target format, by source and target format",
+		}, []string{"from", "to"}),
+
+		schedulerQueue: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: cfg.Subsystem,
+			Name:      "scheduler_queue_size",
+			Help:      "Scheduler queue size per API key",
+		}, []string{"api_key"}),
+
+		schedulerWait: promauto.NewHistogram(prometheus.HistogramOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: cfg.Subsystem,
+			Name:      "scheduler_wait_seconds",
+			Help:      "Time requests waited in the scheduler queue, in seconds",
+			Buckets:   cfg.HistogramBuckets,
+		}),
+
+		keyRequests: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: cfg.Subsystem,
+			Name:      "api_key_requests_total",
+			Help:      "Requests per client API key",
+		}, []string{"api_key", "key_label", "status"}),
+
+		modelVariants: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: cfg.Subsystem,
+			Name:      "model_variant_requests_total",
+			Help:      "Requests served per experiment variant",
+		}, []string{"model", "variant"}),
+
+		defaultRoutes: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: cfg.Subsystem,
+			Name:      "default_provider_requests_total",
+			Help:      "Requests routed to the default provider for unknown models",
+		}, []string{"provider"}),
+
+		truncatedStreams: promauto.NewCounter(prometheus.CounterOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: cfg.Subsystem,
+			Name:      "truncated_streams_total",
+			Help:      "Streams closed upstream without a terminal event",
+		}),
+
 		// Agentic metrics
 		agentIterations: promauto.NewCounterVec(prometheus.CounterOpts{
 			Namespace: cfg.Namespace,
@@ -192,6 +309,66 @@ func (p *PrometheusMetrics) DecrementActiveRequests() {
 	p.activeRequests.Dec()
 }
 
+// SetGlobalInFlight sets the number of upstream calls admitted process-wide.
+func (p *PrometheusMetrics) SetGlobalInFlight(n int64) {
+	p.globalInFlight.Set(float64(n))
+}
+
+// RecordAdmissionShed counts an upstream call shed by the admission controller.
+func (p *PrometheusMetrics) RecordAdmissionShed() {
+	p.admissionShed.Inc()
+}
+
+// RecordRetryBudget counts a retry allowed or denied by the global retry budget and sets the
+// retries left in it.
+func (p *PrometheusMetrics) RecordRetryBudget(allowed bool, available int64) {
+	outcome := "exhausted"
+	if allowed {
+		outcome = "allowed"
+	}
+	p.retryBudget.WithLabelValues(outcome).Inc()
+	p.retryAvailable.Set(float64(available))
+}
+
+// RecordTranslationError counts a translated response that did not match its target format.
+func (p *PrometheusMetrics) RecordTranslationError(from, to string) {
+	p.translationErrs.WithLabelValues(from, to).Inc()
+}
+
+// RecordSchedulerQueue sets the scheduler queue size for an API key, exported hashed.
+func (p *PrometheusMetrics) RecordSchedulerQueue(apiKey string, size int64) {
+	p.schedulerQueue.WithLabelValues(HashAPIKey(apiKey)).Set(float64(size))
+}
+
+// RecordSchedulerWait observes how long a request waited in the scheduler queue.
+func (p *PrometheusMetrics) RecordSchedulerWait(seconds float64) {
+	p.schedulerWait.Observe(seconds)
+}
+
+// RecordKeyRequest records a request authenticated with apiKey, exported as HashAPIKey(apiKey)
+// alongside its configured label.
+func (p *PrometheusMetrics) RecordKeyRequest(apiKey, label, status string) {
+	if apiKey == "" {
+		return
+	}
+	p.keyRequests.WithLabelValues(HashAPIKey(apiKey), label, status).Inc()
+}
+
+// RecordModelVariant records which experiment variant served a logical model.
+func (p *PrometheusMetrics) RecordModelVariant(model, variant string) {
+	p.modelVariants.WithLabelValues(model, variant).Inc()
+}
+
+// RecordDefaultProviderRoute records a request routed to the default provider.
+func (p *PrometheusMetrics) RecordDefaultProviderRoute(provider string) {
+	p.defaultRoutes.WithLabelValues(provider).Inc()
+}
+
+// RecordTruncatedStream counts a stream that ended without a terminal event.
+func (p *PrometheusMetrics) RecordTruncatedStream() {
+	p.truncatedStreams.Inc()
+}
+
 // SetProviderHealth sets the health status for a provider.
 func (p *PrometheusMetrics) SetProviderHealth(provider string, healthy bool) {
 	val := 0.0
@@ -206,6 +383,12 @@ func (p *PrometheusMetrics) RecordProviderError(provider string) {
 	p.providerErrors.WithLabelValues(provider).Inc()
 }
 
+// RecordProviderLatency records a provider stream's time to first byte and total duration.
+func (p *PrometheusMetrics) RecordProviderLatency(provider string, ttfb, total time.Duration) {
+	p.providerTTFB.WithLabelValues(provider).Observe(ttfb.Seconds())
+	p.providerDuration.WithLabelValues(provider).Observe(total.Seconds())
+}
+
 // RecordCacheHit records a cache hit.
 func (p *PrometheusMetrics) RecordCacheHit() {
 	p.cacheHits.Inc()
@@ -216,6 +399,18 @@ func (p *PrometheusMetrics) RecordCacheMiss() {
 	p.cacheMisses.Inc()
 }
 
+// RecordCacheAccess counts a cache lookup as a hit or miss and observes how long it took.
+func (p *PrometheusMetrics) RecordCacheAccess(hit bool, seconds float64) {
+	result := "miss"
+	if hit {
+		p.cacheHits.Inc()
+		result = "hit"
+	} else {
+		p.cacheMisses.Inc()
+	}
+	p.cacheDuration.WithLabelValues(result).Observe(seconds)
+}
+
 // RecordAgentIteration records an agent loop iteration.
 func (p *PrometheusMetrics) RecordAgentIteration(model, outcome string) {
 	p.agentIterations.WithLabelValues(model, outcome).Inc()
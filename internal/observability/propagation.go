@@ -0,0 +1,108 @@
+// Package observability provides metrics collection and tracing for the API proxy.
+// This file implements W3C trace context propagation.
+package observability
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// W3C trace context headers.
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// traceContextPropagator reads and writes the W3C headers for OpenTelemetry spans.
+var traceContextPropagator = propagation.TraceContext{}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns ctx carrying sc as the parent of spans started from it.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context carried by ctx, if any.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok
+}
+
+// ParseTraceparent parses a W3C traceparent header value such as
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01". All-zero IDs, the reserved
+// version ff and, for version 00, trailing fields are rejected.
+func ParseTraceparent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return SpanContext{}, false
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+	if !isLowerHex(traceID, 32) || !isLowerHex(spanID, 16) || !isLowerHex(flags, 2) {
+		return SpanContext{}, false
+	}
+	if strings.Trim(traceID, "0") == "" || strings.Trim(spanID, "0") == "" {
+		return SpanContext{}, false
+	}
+	flagBytes, _ := hex.DecodeString(flags)
+	return SpanContext{TraceID: traceID, SpanID: spanID, TraceFlags: flagBytes[0]}, true
+}
+
+// FormatTraceparent returns the version 00 traceparent header value for sc.
+func FormatTraceparent(sc SpanContext) string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.TraceFlags)
+}
+
+func isLowerHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// ExtractTraceContext returns ctx carrying the caller's span described by the traceparent and
+// tracestate headers, marked Remote, so spans started from it join the caller's trace. ctx is
+// returned unchanged when header has no valid traceparent.
+func ExtractTraceContext(ctx context.Context, header http.Header) context.Context {
+	sc, ok := ParseTraceparent(header.Get(TraceparentHeader))
+	if !ok {
+		return ctx
+	}
+	sc.TraceState = header.Get(TracestateHeader)
+	sc.Remote = true
+	ctx = ContextWithSpanContext(ctx, sc)
+	return traceContextPropagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// InjectTraceContext writes the traceparent and tracestate headers for the span in ctx to
+// header. Nothing is written when ctx carries no span.
+func InjectTraceContext(ctx context.Context, header http.Header) {
+	// A remote OpenTelemetry context only mirrors the extracted caller; spans from other
+	// tracers are carried as a SpanContext.
+	if otelCtx := trace.SpanContextFromContext(ctx); otelCtx.IsValid() && !otelCtx.IsRemote() {
+		traceContextPropagator.Inject(ctx, propagation.HeaderCarrier(header))
+		return
+	}
+	sc, ok := SpanContextFromContext(ctx)
+	if !ok {
+		return
+	}
+	header.Set(TraceparentHeader, FormatTraceparent(sc))
+	if sc.TraceState != "" {
+		header.Set(TracestateHeader, sc.TraceState)
+	}
+}
@@ -0,0 +1,97 @@
+package observability
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const (
+	callerTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	callerSpanID  = "00f067aa0ba902b7"
+)
+
+func TestTracingMiddleware_ContinuesCallerTrace(t *testing.T) {
+	tracer := NewInMemoryTracer(10)
+	m := NewTracingMiddleware(tracer, DefaultTracerConfig())
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set(TraceparentHeader, "00-"+callerTraceID+"-"+callerSpanID+"-01")
+	req.Header.Set(TracestateHeader, "vendor=abc")
+
+	if remote, ok := SpanContextFromContext(ExtractTraceContext(req.Context(), req.Header)); !ok || !remote.Remote {
+		t.Fatalf("extracted span context = %+v, want a remote parent", remote)
+	}
+
+	ctx, server := m.StartIncomingRequestSpan(req, "gpt-4o")
+	serverCtx := server.SpanContext()
+	if serverCtx.TraceID != callerTraceID || serverCtx.Remote {
+		t.Fatalf("server span context = %+v, want local span in trace %s", serverCtx, callerTraceID)
+	}
+	if got := server.(*InMemorySpan).ParentSpanID(); got != callerSpanID {
+		t.Fatalf("server span parent = %q, want %q", got, callerSpanID)
+	}
+
+	outbound := http.Header{}
+	_, provider := m.StartProviderSpan(ctx, "codex", "gpt-4o", outbound)
+	providerCtx := provider.SpanContext()
+	if providerCtx.TraceID != callerTraceID || provider.(*InMemorySpan).ParentSpanID() != serverCtx.SpanID {
+		t.Fatalf("provider span context = %+v, want child of the server span", providerCtx)
+	}
+	if got, want := outbound.Get(TraceparentHeader), "00-"+callerTraceID+"-"+providerCtx.SpanID+"-01"; got != want {
+		t.Fatalf("outbound traceparent = %q, want %q", got, want)
+	}
+	if got := outbound.Get(TracestateHeader); got != "vendor=abc" {
+		t.Fatalf("outbound tracestate = %q, want vendor=abc", got)
+	}
+}
+
+func TestTracingMiddleware_StartsNewTraceWithoutTraceparent(t *testing.T) {
+	m := NewTracingMiddleware(NewInMemoryTracer(10), DefaultTracerConfig())
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set(TraceparentHeader, "00-"+callerTraceID+"-0000000000000000-01")
+	_, span := m.StartIncomingRequestSpan(req, "gpt-4o")
+	if sc := span.SpanContext(); sc.TraceID == callerTraceID || !isLowerHex(sc.TraceID, 32) || !isLowerHex(sc.SpanID, 16) {
+		t.Fatalf("span context = %+v, want a new trace for an invalid traceparent", sc)
+	}
+	if got := span.(*InMemorySpan).ParentSpanID(); got != "" {
+		t.Fatalf("root span parent = %q, want none", got)
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	valid := "00-" + callerTraceID + "-" + callerSpanID + "-01"
+	sc, ok := ParseTraceparent(valid)
+	if !ok || sc.TraceID != callerTraceID || sc.SpanID != callerSpanID || sc.TraceFlags != 1 {
+		t.Fatalf("ParseTraceparent(%q) = %+v, %v", valid, sc, ok)
+	}
+	if got := FormatTraceparent(sc); got != valid {
+		t.Fatalf("FormatTraceparent = %q, want %q", got, valid)
+	}
+
+	for _, value := range []string{
+		"",
+		"ff-" + callerTraceID + "-" + callerSpanID + "-01",
+		"00-" + callerTraceID + "-" + callerSpanID + "-01-extra",
+		"00-00000000000000000000000000000000-" + callerSpanID + "-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-" + callerSpanID + "-01",
+		"00-" + callerTraceID + "-" + callerSpanID,
+	} {
+		if _, ok := ParseTraceparent(value); ok {
+			t.Fatalf("ParseTraceparent(%q) accepted an invalid header", value)
+		}
+	}
+	if _, ok := ParseTraceparent("01-" + callerTraceID + "-" + callerSpanID + "-01-future"); !ok {
+		t.Fatal("ParseTraceparent rejected a future version with extra fields")
+	}
+}
+
+func TestInjectTraceContext_NoSpan(t *testing.T) {
+	header := http.Header{}
+	InjectTraceContext(context.Background(), header)
+	if got := header.Get(TraceparentHeader); got != "" {
+		t.Fatalf("traceparent = %q, want none without a span", got)
+	}
+}
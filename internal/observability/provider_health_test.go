@@ -0,0 +1,159 @@
+package observability
+
+import (
+	"testing"
+	"time"
+)
+
+func newHealthCollector(policy ProviderHealthPolicy) *MetricsCollector {
+	cfg := DefaultMetricsConfig()
+	cfg.ProviderHealth = policy
+	return NewMetricsCollector(cfg)
+}
+
+func providerHealthy(t *testing.T, m *MetricsCollector, provider string) bool {
+	t.Helper()
+	status, ok := m.GetProviderHealth()[provider]
+	if !ok {
+		t.Fatalf("provider %s not tracked", provider)
+	}
+	return status.Healthy
+}
+
+func TestProviderHealth_NoFlapDuringWarmup(t *testing.T) {
+	m := newHealthCollector(ProviderHealthPolicy{MinSamples: 10, TripErrorRate: 0.5, RecoverErrorRate: 0.2})
+
+	// Errors alternate with successes during warmup, which must not flip health.
+	for i := 0; i < 9; i++ {
+		m.RecordProviderRequest("warm", 100, i%2 == 1)
+		if !providerHealthy(t, m, "warm") {
+			t.Fatalf("provider marked unhealthy during warmup after %d requests", i+1)
+		}
+	}
+	// Every warmup request failing still does not trip before MinSamples.
+	for i := 0; i < 9; i++ {
+		m.RecordProviderRequest("cold-start", 100, false)
+	}
+	if !providerHealthy(t, m, "cold-start") {
+		t.Fatal("provider tripped before reaching MinSamples")
+	}
+	m.RecordProviderRequest("cold-start", 100, false)
+	if providerHealthy(t, m, "cold-start") {
+		t.Fatal("provider should trip once warmup completes with a 100% error rate")
+	}
+}
+
+func TestProviderHealth_TripAndRecoverWithHysteresis(t *testing.T) {
+	m := newHealthCollector(ProviderHealthPolicy{MinSamples: 10, TripErrorRate: 0.5, RecoverErrorRate: 0.2})
+
+	for i := 0; i < 10; i++ {
+		m.RecordProviderRequest("p", 100, true)
+	}
+	for i := 0; i < 9; i++ {
+		m.RecordProviderRequest("p", 100, false)
+	}
+	if !providerHealthy(t, m, "p") {
+		t.Fatal("9/19 errors is below the trip rate")
+	}
+	m.RecordProviderRequest("p", 100, false) // 10/20 = 50%
+	if providerHealthy(t, m, "p") {
+		t.Fatal("provider should trip at the 50% trip rate")
+	}
+
+	// A success alone no longer recovers: the rate must fall to 20% first.
+	m.RecordProviderRequest("p", 100, true) // 10/21
+	if providerHealthy(t, m, "p") {
+		t.Fatal("a single success must not recover a tripped provider")
+	}
+	for i := 0; i < 28; i++ { // 10/49, just above 20%
+		m.RecordProviderRequest("p", 100, true)
+	}
+	if providerHealthy(t, m, "p") {
+		t.Fatal("provider recovered above the recover rate")
+	}
+	m.RecordProviderRequest("p", 100, true) // 10/50 = 20%
+	if !providerHealthy(t, m, "p") {
+		t.Fatal("provider should recover at the 20% recover rate")
+	}
+
+	// Back above the recover rate but below the trip rate: stays healthy.
+	for i := 0; i < 5; i++ {
+		m.RecordProviderRequest("p", 100, false) // 15/55
+	}
+	if !providerHealthy(t, m, "p") {
+		t.Fatal("a healthy provider must not trip below the trip rate")
+	}
+}
+
+func TestProviderHealthPolicy_Defaults(t *testing.T) {
+	got := ProviderHealthPolicy{TripErrorRate: 0.6}.normalized()
+	if got.MinSamples != DefaultProviderHealthPolicy().MinSamples || got.TripErrorRate != 0.6 || got.RecoverErrorRate != 0.3 {
+		t.Fatalf("normalized = %+v", got)
+	}
+	if got := (ProviderHealthPolicy{TripErrorRate: 0.4, RecoverErrorRate: 0.9}).normalized(); got.RecoverErrorRate > got.TripErrorRate {
+		t.Fatalf("recover rate above trip rate: %+v", got)
+	}
+}
+
+func TestProviderHealth_WindowTripsDespiteLongHistory(t *testing.T) {
+	m := newHealthCollector(ProviderHealthPolicy{MinSamples: 10, TripErrorRate: 0.5, RecoverErrorRate: 0.2, WindowSize: 20})
+
+	for i := 0; i < 100000; i++ {
+		m.RecordProviderRequest("veteran", 100, true)
+	}
+	// An outage starts: half of the window is enough to trip, even though the all-time
+	// error rate stays around 0.01%.
+	for i := 0; i < 9; i++ {
+		m.RecordProviderRequest("veteran", 100, false)
+	}
+	if !providerHealthy(t, m, "veteran") {
+		t.Fatal("9/20 errors in the window is below the trip rate")
+	}
+	m.RecordProviderRequest("veteran", 100, false)
+
+	status := m.GetProviderHealth()["veteran"]
+	if status.Healthy {
+		t.Fatalf("provider should trip within the window: %+v", status)
+	}
+	if status.Requests != 100010 || status.Errors != 10 || status.ErrorRate > 0.1 {
+		t.Fatalf("all-time counters changed meaning: %+v", status)
+	}
+	if status.WindowRequests != 20 || status.WindowErrorRate != 50 {
+		t.Fatalf("window = %d requests at %.1f%%, want 20 at 50%%", status.WindowRequests, status.WindowErrorRate)
+	}
+}
+
+func TestProviderHealth_TimeWindowDropsOldSamples(t *testing.T) {
+	m := newHealthCollector(ProviderHealthPolicy{MinSamples: 5, TripErrorRate: 0.5, WindowSize: 50, WindowSeconds: 60})
+	for i := 0; i < 10; i++ {
+		m.RecordProviderRequest("p", 100, false)
+	}
+	if providerHealthy(t, m, "p") {
+		t.Fatal("provider should trip on 100% errors")
+	}
+
+	// Age every sample past the time window; the next requests start a fresh warmup.
+	pm := m.providerHealth["p"]
+	for i := range pm.window {
+		pm.window[i].at = pm.window[i].at.Add(-time.Hour)
+	}
+	m.RecordProviderRequest("p", 100, true)
+	if !providerHealthy(t, m, "p") {
+		t.Fatal("samples outside the time window must not keep the provider unhealthy")
+	}
+}
+
+func TestProviderMetrics_WindowShrinkKeepsNewest(t *testing.T) {
+	pm := &providerMetrics{}
+	base := time.Now()
+	for i := 0; i < 7; i++ {
+		pm.record(healthSample{at: base.Add(time.Duration(i) * time.Second), failed: i >= 5}, 5)
+	}
+	pm.record(healthSample{at: base.Add(7 * time.Second), failed: true}, 3)
+	if len(pm.window) != 3 {
+		t.Fatalf("window length = %d, want 3", len(pm.window))
+	}
+	if requests, errors := pm.windowCounts(base, 0); requests != 3 || errors != 3 {
+		t.Fatalf("window = %d requests, %d errors; want the newest 3 samples, all failed", requests, errors)
+	}
+}
@@ -0,0 +1,110 @@
+package observability
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+func TestRecordProviderLatency_MetricsCollector(t *testing.T) {
+	m := NewMetricsCollector(DefaultMetricsConfig())
+	m.RecordProviderLatency("slow-start", 40*time.Millisecond, 3*time.Second)
+
+	export := m.Export()
+	for _, want := range []string{
+		`shinapi_proxy_provider_ttfb_seconds_bucket{provider="slow-start",le="0.025"} 0`,
+		`shinapi_proxy_provider_ttfb_seconds_bucket{provider="slow-start",le="0.05"} 1`,
+		`shinapi_proxy_provider_ttfb_seconds_sum{provider="slow-start"} 0.040`,
+		`shinapi_proxy_provider_ttfb_seconds_count{provider="slow-start"} 1`,
+		`shinapi_proxy_provider_duration_seconds_bucket{provider="slow-start",le="2.5"} 0`,
+		`shinapi_proxy_provider_duration_seconds_bucket{provider="slow-start",le="5"} 1`,
+		`shinapi_proxy_provider_duration_seconds_sum{provider="slow-start"} 3.000`,
+		`shinapi_proxy_provider_duration_seconds_count{provider="slow-start"} 1`,
+	} {
+		if !strings.Contains(export, want) {
+			t.Fatalf("metrics export missing %s", want)
+		}
+	}
+}
+
+func TestRecordProviderLatency_PrometheusMetrics(t *testing.T) {
+	GetPrometheusMetrics().RecordProviderLatency("ttfb-test", 40*time.Millisecond, 3*time.Second)
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	want := map[string]float64{
+		"shinapi_proxy_provider_ttfb_seconds":     0.04,
+		"shinapi_proxy_provider_duration_seconds": 3,
+	}
+	for _, family := range families {
+		sum, ok := want[family.GetName()]
+		if !ok {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			if metric.GetLabel()[0].GetValue() != "ttfb-test" {
+				continue
+			}
+			h := metric.GetHistogram()
+			if h.GetSampleCount() != 1 || h.GetSampleSum() != sum {
+				t.Fatalf("%s count/sum = %d/%v, want 1/%v", family.GetName(), h.GetSampleCount(), h.GetSampleSum(), sum)
+			}
+			delete(want, family.GetName())
+		}
+	}
+	if len(want) > 0 {
+		t.Fatalf("histograms not recorded: %v", want)
+	}
+}
+
+func TestRecordProviderRequest_ExportsValidLatencyHistogram(t *testing.T) {
+	m := NewMetricsCollector(MetricsConfig{HistogramBuckets: []float64{100, 500, 1000}})
+	for _, latency := range []float64{40, 100, 450, 900, 2500} {
+		m.RecordProviderRequest("codex", latency, latency < 2000)
+	}
+	m.RecordProviderRequest("claude", 700, true)
+
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(strings.NewReader(m.Export()))
+	if err != nil {
+		t.Fatalf("export is not valid Prometheus text: %v", err)
+	}
+	family := families["shinapi_proxy_provider_request_duration_milliseconds"]
+	if family == nil || family.GetType() != dto.MetricType_HISTOGRAM {
+		t.Fatalf("provider latency family = %v, want a histogram", family)
+	}
+
+	var codex *dto.Histogram
+	for _, metric := range family.GetMetric() {
+		if metric.GetLabel()[0].GetValue() == "codex" {
+			codex = metric.GetHistogram()
+		}
+	}
+	if codex == nil {
+		t.Fatal("no latency histogram for provider codex")
+	}
+	if codex.GetSampleCount() != 5 || codex.GetSampleSum() != 3990 {
+		t.Fatalf("codex count/sum = %d/%v, want 5/3990", codex.GetSampleCount(), codex.GetSampleSum())
+	}
+	want := map[float64]uint64{100: 2, 500: 3, 1000: 4}
+	for _, bucket := range codex.GetBucket() {
+		if math.IsInf(bucket.GetUpperBound(), 1) {
+			continue
+		}
+		if got := bucket.GetCumulativeCount(); got != want[bucket.GetUpperBound()] {
+			t.Fatalf("codex bucket le=%g = %d, want %d", bucket.GetUpperBound(), got, want[bucket.GetUpperBound()])
+		}
+		delete(want, bucket.GetUpperBound())
+	}
+	if len(want) > 0 {
+		t.Fatalf("codex buckets missing: %v", want)
+	}
+}
@@ -0,0 +1,226 @@
+// Package observability provides metrics collection and tracing for the API proxy.
+// This file defines the MetricsRecorder interface shared by both metrics collectors.
+package observability
+
+import (
+	"sync"
+	"time"
+)
+
+// MetricsRecorder records the metrics the proxy publishes, whichever collector serves the
+// /metrics endpoint. Durations are passed as time.Duration; each implementation converts
+// them to the unit its series are exported in.
+type MetricsRecorder interface {
+	// RecordRequest records a completed request served through provider.
+	RecordRequest(model, provider, status string, duration time.Duration, tokens int64)
+	// RecordCacheHit records a cache lookup that found an entry.
+	RecordCacheHit(latency time.Duration)
+	// RecordCacheMiss records a cache lookup that found nothing.
+	RecordCacheMiss(latency time.Duration)
+	// IncrementActiveRequests marks a request as started.
+	IncrementActiveRequests()
+	// DecrementActiveRequests marks a request as finished.
+	DecrementActiveRequests()
+	// SetGlobalInFlight sets the number of upstream calls admitted process-wide.
+	SetGlobalInFlight(n int64)
+	// RecordAdmissionShed counts an upstream call shed by the admission controller.
+	RecordAdmissionShed()
+	// RecordRetryBudget counts a retry allowed or denied by the global retry budget and sets
+	// the retries left in it; a negative available means the budget is unlimited.
+	RecordRetryBudget(allowed bool, available int64)
+	// RecordProviderLatency records a provider stream's time to first byte and total duration.
+	RecordProviderLatency(provider string, ttfb, total time.Duration)
+	// RecordTranslationError counts a response translated from one format to another that
+	// did not have the shape the target format requires.
+	RecordTranslationError(from, to string)
+	// RecordReasoningTokens records the reasoning and visible completion tokens of a response.
+	RecordReasoningTokens(model string, reasoning, completion int64)
+	// RecordSchedulerQueue sets the number of requests queued in the scheduler for apiKey.
+	RecordSchedulerQueue(apiKey string, size int64)
+	// RecordSchedulerWait records how long a request waited in the scheduler queue.
+	RecordSchedulerWait(wait time.Duration)
+	// RecordKeyRequest records a request authenticated with apiKey, which is only ever
+	// exported hashed, alongside its configured label.
+	RecordKeyRequest(apiKey, label, status string)
+	// RecordModelVariant records which experiment variant served a logical model.
+	RecordModelVariant(model, variant string)
+	// RecordDefaultProviderRoute records a request routed to the default provider because no
+	// routing rule or registered provider matched its model.
+	RecordDefaultProviderRoute(provider string)
+	// RecordTruncatedStream counts a stream that ended without a terminal event.
+	RecordTruncatedStream()
+}
+
+// collectorRecorder adapts the custom MetricsCollector, which works in milliseconds.
+type collectorRecorder struct {
+	m *MetricsCollector
+}
+
+func (r collectorRecorder) RecordRequest(model, _, status string, duration time.Duration, tokens int64) {
+	r.m.RecordRequest(model, status, milliseconds(duration), tokens)
+}
+
+func (r collectorRecorder) RecordCacheHit(latency time.Duration) {
+	r.m.RecordCacheAccess(true, milliseconds(latency))
+}
+
+func (r collectorRecorder) RecordCacheMiss(latency time.Duration) {
+	r.m.RecordCacheAccess(false, milliseconds(latency))
+}
+
+func (r collectorRecorder) IncrementActiveRequests() { r.m.IncrementActiveRequests() }
+
+func (r collectorRecorder) DecrementActiveRequests() { r.m.DecrementActiveRequests() }
+
+func (r collectorRecorder) SetGlobalInFlight(n int64) { r.m.SetGlobalInFlight(n) }
+
+func (r collectorRecorder) RecordAdmissionShed() { r.m.RecordAdmissionShed() }
+
+func (r collectorRecorder) RecordRetryBudget(allowed bool, available int64) {
+	r.m.RecordRetryBudget(allowed, available)
+}
+
+func (r collectorRecorder) RecordProviderLatency(provider string, ttfb, total time.Duration) {
+	r.m.RecordProviderLatency(provider, ttfb, total)
+}
+
+func (r collectorRecorder) RecordTranslationError(from, to string) {
+	r.m.RecordTranslationError(from, to)
+}
+
+func (r collectorRecorder) RecordReasoningTokens(model string, reasoning, completion int64) {
+	r.m.RecordTokens(model, "reasoning", reasoning)
+	r.m.RecordTokens(model, "completion", completion)
+}
+
+func (r collectorRecorder) RecordSchedulerQueue(apiKey string, size int64) {
+	r.m.RecordSchedulerQueue(apiKey, size)
+}
+
+func (r collectorRecorder) RecordSchedulerWait(wait time.Duration) {
+	r.m.RecordSchedulerWait(milliseconds(wait))
+}
+
+func (r collectorRecorder) RecordKeyRequest(apiKey, label, status string) {
+	r.m.RecordKeyRequest(apiKey, label, status)
+}
+
+func (r collectorRecorder) RecordModelVariant(model, variant string) {
+	r.m.RecordModelVariant(model, variant)
+}
+
+func (r collectorRecorder) RecordDefaultProviderRoute(provider string) {
+	r.m.RecordDefaultProviderRoute(provider)
+}
+
+func (r collectorRecorder) RecordTruncatedStream() { r.m.RecordTruncatedStream() }
+
+// prometheusRecorder adapts the official PrometheusMetrics, which works in seconds.
+type prometheusRecorder struct {
+	p *PrometheusMetrics
+}
+
+func (r prometheusRecorder) RecordRequest(model, provider, status string, duration time.Duration, tokens int64) {
+	r.p.RecordRequest(model, provider, status, duration.Seconds(), tokens)
+}
+
+func (r prometheusRecorder) RecordCacheHit(latency time.Duration) {
+	r.p.RecordCacheAccess(true, latency.Seconds())
+}
+
+func (r prometheusRecorder) RecordCacheMiss(latency time.Duration) {
+	r.p.RecordCacheAccess(false, latency.Seconds())
+}
+
+func (r prometheusRecorder) IncrementActiveRequests() { r.p.IncrementActiveRequests() }
+
+func (r prometheusRecorder) DecrementActiveRequests() { r.p.DecrementActiveRequests() }
+
+func (r prometheusRecorder) SetGlobalInFlight(n int64) { r.p.SetGlobalInFlight(n) }
+
+func (r prometheusRecorder) RecordAdmissionShed() { r.p.RecordAdmissionShed() }
+
+func (r prometheusRecorder) RecordRetryBudget(allowed bool, available int64) {
+	r.p.RecordRetryBudget(allowed, available)
+}
+
+func (r prometheusRecorder) RecordProviderLatency(provider string, ttfb, total time.Duration) {
+	r.p.RecordProviderLatency(provider, ttfb, total)
+}
+
+func (r prometheusRecorder) RecordTranslationError(from, to string) {
+	r.p.RecordTranslationError(from, to)
+}
+
+func (r prometheusRecorder) RecordReasoningTokens(model string, reasoning, completion int64) {
+	r.p.RecordTokens(model, "reasoning", reasoning)
+	r.p.RecordTokens(model, "completion", completion)
+	r.p.RecordAgentThinkingTokens(model, reasoning)
+}
+
+func (r prometheusRecorder) RecordSchedulerQueue(apiKey string, size int64) {
+	r.p.RecordSchedulerQueue(apiKey, size)
+}
+
+func (r prometheusRecorder) RecordSchedulerWait(wait time.Duration) {
+	r.p.RecordSchedulerWait(wait.Seconds())
+}
+
+func (r prometheusRecorder) RecordKeyRequest(apiKey, label, status string) {
+	r.p.RecordKeyRequest(apiKey, label, status)
+}
+
+func (r prometheusRecorder) RecordModelVariant(model, variant string) {
+	r.p.RecordModelVariant(model, variant)
+}
+
+func (r prometheusRecorder) RecordDefaultProviderRoute(provider string) {
+	r.p.RecordDefaultProviderRoute(provider)
+}
+
+func (r prometheusRecorder) RecordTruncatedStream() { r.p.RecordTruncatedStream() }
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Recorder returns a MetricsRecorder recording to m.
+func (m *MetricsCollector) Recorder() MetricsRecorder {
+	return collectorRecorder{m: m}
+}
+
+// NewMetricsRecorder returns a recorder backed by the global official Prometheus collector
+// when cfg.UseOfficialClient is set, and by the global custom collector otherwise.
+func NewMetricsRecorder(cfg MetricsConfig) MetricsRecorder {
+	if cfg.UseOfficialClient {
+		return prometheusRecorder{p: GetPrometheusMetrics()}
+	}
+	return collectorRecorder{m: GetMetrics()}
+}
+
+// Global metrics recorder instance
+var (
+	globalRecorder   MetricsRecorder
+	globalRecorderMu sync.RWMutex
+)
+
+// GetMetricsRecorder returns the global metrics recorder. It records to the custom collector
+// until InitMetricsRecorder selects one from configuration.
+func GetMetricsRecorder() MetricsRecorder {
+	globalRecorderMu.RLock()
+	r := globalRecorder
+	globalRecorderMu.RUnlock()
+	if r == nil {
+		return collectorRecorder{m: GetMetrics()}
+	}
+	return r
+}
+
+// InitMetricsRecorder replaces the global metrics recorder with the one cfg selects.
+func InitMetricsRecorder(cfg MetricsConfig) MetricsRecorder {
+	r := NewMetricsRecorder(cfg)
+	globalRecorderMu.Lock()
+	defer globalRecorderMu.Unlock()
+	globalRecorder = r
+	return r
+}
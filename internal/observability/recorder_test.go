@@ -0,0 +1,132 @@
+package observability
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+var (
+	_ MetricsRecorder = collectorRecorder{}
+	_ MetricsRecorder = prometheusRecorder{}
+)
+
+func TestNewMetricsRecorder_SelectsCollector(t *testing.T) {
+	if _, ok := NewMetricsRecorder(MetricsConfig{}).(collectorRecorder); !ok {
+		t.Fatal("default recorder is not backed by the custom collector")
+	}
+	if _, ok := NewMetricsRecorder(MetricsConfig{UseOfficialClient: true}).(prometheusRecorder); !ok {
+		t.Fatal("use-official-client recorder is not backed by the official client")
+	}
+}
+
+// recordSample feeds the same sequence of observations to r.
+func recordSample(r MetricsRecorder, modelName string) {
+	r.RecordRequest(modelName, "proxy", "success", 1500*time.Millisecond, 42)
+	r.RecordRequest(modelName, "proxy", "success", 500*time.Millisecond, 8)
+	r.RecordRequest(modelName, "proxy", "error", 250*time.Millisecond, 0)
+}
+
+func TestMetricsRecorders_RecordEquivalently(t *testing.T) {
+	const modelName = "recorder-equivalence-model"
+
+	custom := NewMetricsCollector(DefaultMetricsConfig())
+	recordSample(collectorRecorder{m: custom}, modelName)
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	customFamilies, err := parser.TextToMetricFamilies(strings.NewReader(custom.Export()))
+	if err != nil {
+		t.Fatalf("parse custom export: %v", err)
+	}
+
+	recordSample(prometheusRecorder{p: GetPrometheusMetrics()}, modelName)
+	gathered, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	officialFamilies := make(map[string]*dto.MetricFamily, len(gathered))
+	for _, family := range gathered {
+		officialFamilies[family.GetName()] = family
+	}
+
+	for _, status := range []string{"success", "error"} {
+		got := findMetric(customFamilies["shinapi_proxy_requests_total"], modelName, status).GetCounter().GetValue()
+		want := findMetric(officialFamilies["shinapi_proxy_requests_total"], modelName, status).GetCounter().GetValue()
+		if got != want || got == 0 {
+			t.Errorf("%s requests: custom %v, official %v", status, got, want)
+		}
+	}
+
+	customDuration := findMetric(customFamilies["shinapi_proxy_request_duration_milliseconds"], modelName, "").GetHistogram()
+	officialDuration := findMetric(officialFamilies["shinapi_proxy_request_duration_seconds"], modelName, "").GetHistogram()
+	if customDuration.GetSampleCount() != officialDuration.GetSampleCount() || customDuration.GetSampleCount() != 3 {
+		t.Errorf("duration count: custom %d, official %d", customDuration.GetSampleCount(), officialDuration.GetSampleCount())
+	}
+	if got, want := customDuration.GetSampleSum()/1000, officialDuration.GetSampleSum(); got != want {
+		t.Errorf("duration sum: custom %vs, official %vs", got, want)
+	}
+
+	got := findMetric(customFamilies["shinapi_proxy_tokens_total"], modelName, "").GetCounter().GetValue()
+	want := findMetric(officialFamilies["shinapi_proxy_tokens_total"], modelName, "").GetCounter().GetValue()
+	if got != want || got != 50 {
+		t.Errorf("tokens: custom %v, official %v, want 50", got, want)
+	}
+}
+
+// findMetric returns the series in family labelled with modelName and, when set, status.
+func findMetric(family *dto.MetricFamily, modelName, status string) *dto.Metric {
+	for _, metric := range family.GetMetric() {
+		labels := map[string]string{}
+		for _, label := range metric.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		if labels["model"] == modelName && (status == "" || labels["status"] == status) {
+			return metric
+		}
+	}
+	return nil
+}
+
+func TestPrometheusMetrics_RecordCacheAccessObservesHitsAndMisses(t *testing.T) {
+	p := GetPrometheusMetrics()
+	before := map[string]uint64{"hit": cacheDurationCount(t, p, "hit"), "miss": cacheDurationCount(t, p, "miss")}
+
+	r := prometheusRecorder{p: p}
+	r.RecordCacheHit(2 * time.Millisecond)
+	r.RecordCacheMiss(5 * time.Millisecond)
+	r.RecordCacheMiss(time.Millisecond)
+
+	for result, want := range map[string]uint64{"hit": 1, "miss": 2} {
+		if got := cacheDurationCount(t, p, result) - before[result]; got != want {
+			t.Errorf("%s observations = %d, want %d", result, got, want)
+		}
+	}
+}
+
+func cacheDurationCount(t *testing.T, p *PrometheusMetrics, result string) uint64 {
+	t.Helper()
+	var metric dto.Metric
+	if err := p.cacheDuration.WithLabelValues(result).(prometheus.Metric).Write(&metric); err != nil {
+		t.Fatalf("write %s histogram: %v", result, err)
+	}
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func TestCollectorRecorder_ReasoningTokensKeepModelNamesWithColons(t *testing.T) {
+	custom := NewMetricsCollector(DefaultMetricsConfig())
+	custom.Recorder().RecordReasoningTokens("org/model:free", 4, 2)
+
+	export := custom.Export()
+	for _, want := range []string{
+		`shinapi_proxy_tokens_total{model="org/model:free",type="reasoning"} 4`,
+		`shinapi_proxy_tokens_total{model="org/model:free",type="completion"} 2`,
+	} {
+		if !strings.Contains(export, want) {
+			t.Errorf("export missing %s:\n%s", want, export)
+		}
+	}
+}
@@ -4,8 +4,13 @@ package observability
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
 	"sync"
 	"time"
+
+	log "github.com/sirupsen/logrus"
 )
 
 // Span represents a trace span.
@@ -27,11 +32,13 @@ const (
 	SpanStatusError
 )
 
-// SpanContext contains identifying trace information.
+// SpanContext contains identifying trace information. IDs are lowercase hex as in the W3C
+// traceparent header; Remote is set for contexts extracted from an incoming request.
 type SpanContext struct {
 	TraceID    string
 	SpanID     string
 	TraceFlags byte
+	TraceState string
 	Remote     bool
 }
 
@@ -145,6 +152,7 @@ type InMemorySpan struct {
 	events      []spanEvent
 	errors      []error
 	ctx         SpanContext
+	parentID    string
 	ended       bool
 }
 
@@ -202,6 +210,37 @@ func (s *InMemorySpan) SpanContext() SpanContext {
 	return s.ctx
 }
 
+// Name returns the span name.
+func (s *InMemorySpan) Name() string {
+	return s.name
+}
+
+// Status returns the span status and its description.
+func (s *InMemorySpan) Status() (SpanStatusCode, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status, s.statusDesc
+}
+
+// Attribute returns the value of the span attribute key, or nil when it is not set.
+func (s *InMemorySpan) Attribute(key string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attributes[key]
+}
+
+// ParentSpanID returns the span ID of the span's parent, or "" for a root span.
+func (s *InMemorySpan) ParentSpanID() string {
+	return s.parentID
+}
+
+// Ended reports whether End has been called on the span.
+func (s *InMemorySpan) Ended() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ended
+}
+
 // Duration returns the span duration.
 func (s *InMemorySpan) Duration() time.Duration {
 	s.mu.Lock()
@@ -217,7 +256,6 @@ type InMemoryTracer struct {
 	mu        sync.Mutex
 	spans     []*InMemorySpan
 	maxSpans  int
-	idCounter uint64
 }
 
 // NewInMemoryTracer creates a new in-memory tracer.
@@ -237,21 +275,26 @@ func (t *InMemoryTracer) Start(ctx context.Context, name string, opts ...SpanOpt
 		opt(cfg)
 	}
 
-	t.mu.Lock()
-	t.idCounter++
-	spanID := t.idCounter
-	
+	// Child spans join the trace of the span, or remote caller, in ctx.
+	sc := SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), TraceFlags: 1}
+	var parentID string
+	if parent, ok := SpanContextFromContext(ctx); ok {
+		sc.TraceID = parent.TraceID
+		sc.TraceFlags = parent.TraceFlags
+		sc.TraceState = parent.TraceState
+		parentID = parent.SpanID
+	}
+
 	span := &InMemorySpan{
 		name:       name,
 		kind:       cfg.kind,
 		startTime:  time.Now(),
 		attributes: cfg.attributes,
-		ctx: SpanContext{
-			TraceID: generateTraceID(),
-			SpanID:  generateSpanID(spanID),
-		},
+		ctx:        sc,
+		parentID:   parentID,
 	}
-	
+
+	t.mu.Lock()
 	// Evict old spans if at capacity
 	if len(t.spans) >= t.maxSpans {
 		t.spans = t.spans[1:]
@@ -259,7 +302,7 @@ func (t *InMemoryTracer) Start(ctx context.Context, name string, opts ...SpanOpt
 	t.spans = append(t.spans, span)
 	t.mu.Unlock()
 
-	return ctx, span
+	return ContextWithSpanContext(ctx, sc), span
 }
 
 // Spans returns all recorded spans.
@@ -278,12 +321,18 @@ func (t *InMemoryTracer) Clear() {
 	t.spans = t.spans[:0]
 }
 
-func generateTraceID() string {
-	return time.Now().Format("20060102150405.000000")
+func newTraceID() string {
+	return randomHex(16)
+}
+
+func newSpanID() string {
+	return randomHex(8)
 }
 
-func generateSpanID(id uint64) string {
-	return time.Now().Format("150405") + "-" + string(rune('A'+id%26))
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
 }
 
 // TracingMiddleware provides request tracing.
@@ -300,6 +349,13 @@ func NewTracingMiddleware(tracer Tracer, cfg TracerConfig) *TracingMiddleware {
 	}
 }
 
+// StartIncomingRequestSpan starts a server span for r. When r carries a W3C traceparent
+// header the span continues the caller's trace instead of starting a new one.
+func (m *TracingMiddleware) StartIncomingRequestSpan(r *http.Request, model string) (context.Context, Span) {
+	ctx := ExtractTraceContext(r.Context(), r.Header)
+	return m.StartRequestSpan(ctx, r.Method, r.URL.Path, model)
+}
+
 // StartRequestSpan starts a span for an HTTP request.
 func (m *TracingMiddleware) StartRequestSpan(ctx context.Context, method, path, model string) (context.Context, Span) {
 	if m.tracer == nil {
@@ -316,19 +372,23 @@ func (m *TracingMiddleware) StartRequestSpan(ctx context.Context, method, path,
 	)
 }
 
-// StartProviderSpan starts a span for a provider call.
-func (m *TracingMiddleware) StartProviderSpan(ctx context.Context, provider, model string) (context.Context, Span) {
-	if m.tracer == nil {
-		return ctx, &NoopSpan{}
+// StartProviderSpan starts a span for a provider call and, when outbound is not nil, writes
+// the traceparent and tracestate headers for it so the provider joins the trace.
+func (m *TracingMiddleware) StartProviderSpan(ctx context.Context, provider, model string, outbound http.Header) (context.Context, Span) {
+	var span Span = &NoopSpan{}
+	if m.tracer != nil {
+		ctx, span = m.tracer.Start(ctx, "provider.request",
+			WithSpanKind(SpanKindClient),
+			WithAttributes(map[string]interface{}{
+				"provider": provider,
+				"model":    model,
+			}),
+		)
 	}
-
-	return m.tracer.Start(ctx, "provider.request",
-		WithSpanKind(SpanKindClient),
-		WithAttributes(map[string]interface{}{
-			"provider": provider,
-			"model":    model,
-		}),
-	)
+	if outbound != nil {
+		InjectTraceContext(ctx, outbound)
+	}
+	return ctx, span
 }
 
 // StartCacheSpan starts a span for a cache operation.
@@ -377,10 +437,28 @@ func InitTracer(cfg TracerConfig) Tracer {
 		switch cfg.ExporterType {
 		case "stdout", "memory":
 			globalTracer = NewInMemoryTracer(1000)
+		case "otlp":
+			tracer, err := NewOTLPTracer(context.Background(), cfg)
+			if err != nil {
+				log.Warnf("tracing: falling back to no-op tracer: %v", err)
+				globalTracer = &NoopTracer{}
+				return
+			}
+			globalTracer = tracer
 		default:
-			// For production, you would integrate with real OTEL SDK here
 			globalTracer = &NoopTracer{}
 		}
 	})
 	return globalTracer
 }
+
+// ShutdownTracer flushes and stops the global tracer if it exports spans.
+func ShutdownTracer(ctx context.Context) error {
+	globalTracerMu.RLock()
+	tracer := globalTracer
+	globalTracerMu.RUnlock()
+	if s, ok := tracer.(interface{ Shutdown(context.Context) error }); ok {
+		return s.Shutdown(ctx)
+	}
+	return nil
+}
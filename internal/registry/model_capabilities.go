@@ -0,0 +1,77 @@
+// Package registry provides model definitions and lookup helpers for various AI service providers.
+// This file exposes per-model capability flags so clients can feature-detect via the models endpoint.
+package registry
+
+import "strings"
+
+// ModelCapabilities describes the client-detectable features supported by a model.
+type ModelCapabilities struct {
+	// SupportsTools indicates whether the model accepts tool/function definitions.
+	SupportsTools bool `json:"supports_tools"`
+	// SupportsStreaming indicates whether the model can stream responses.
+	SupportsStreaming bool `json:"supports_streaming"`
+	// SupportsReasoning indicates whether the model exposes thinking/reasoning output.
+	SupportsReasoning bool `json:"supports_reasoning"`
+	// ContextWindow is the context window size in tokens (0 when unknown).
+	ContextWindow int64 `json:"context_window,omitempty"`
+}
+
+// capabilityEntry maps a model name prefix to its default capabilities.
+type capabilityEntry struct {
+	prefix       string
+	capabilities ModelCapabilities
+}
+
+// capabilityTable lists known model families ordered from most to least specific.
+// The first matching prefix wins.
+var capabilityTable = []capabilityEntry{
+	{prefix: "claude-3-5-haiku", capabilities: ModelCapabilities{SupportsTools: true, SupportsStreaming: true, ContextWindow: 200000}},
+	{prefix: "claude-haiku", capabilities: ModelCapabilities{SupportsTools: true, SupportsStreaming: true, ContextWindow: 200000}},
+	{prefix: "claude-", capabilities: ModelCapabilities{SupportsTools: true, SupportsStreaming: true, SupportsReasoning: true, ContextWindow: 200000}},
+	{prefix: "gemini-2.5-flash-image", capabilities: ModelCapabilities{SupportsStreaming: true, ContextWindow: 32768}},
+	{prefix: "gemini-3-pro-image", capabilities: ModelCapabilities{SupportsStreaming: true, ContextWindow: 65536}},
+	{prefix: "gemini-", capabilities: ModelCapabilities{SupportsTools: true, SupportsStreaming: true, SupportsReasoning: true, ContextWindow: 1048576}},
+	{prefix: "gpt-5", capabilities: ModelCapabilities{SupportsTools: true, SupportsStreaming: true, SupportsReasoning: true, ContextWindow: 400000}},
+	{prefix: "gpt-4", capabilities: ModelCapabilities{SupportsTools: true, SupportsStreaming: true, ContextWindow: 128000}},
+	{prefix: "o1", capabilities: ModelCapabilities{SupportsTools: true, SupportsStreaming: true, SupportsReasoning: true, ContextWindow: 200000}},
+	{prefix: "o3", capabilities: ModelCapabilities{SupportsTools: true, SupportsStreaming: true, SupportsReasoning: true, ContextWindow: 200000}},
+	{prefix: "o4", capabilities: ModelCapabilities{SupportsTools: true, SupportsStreaming: true, SupportsReasoning: true, ContextWindow: 200000}},
+	{prefix: "deepseek-r1", capabilities: ModelCapabilities{SupportsStreaming: true, SupportsReasoning: true, ContextWindow: 128000}},
+	{prefix: "deepseek-v3.2-reasoner", capabilities: ModelCapabilities{SupportsTools: true, SupportsStreaming: true, SupportsReasoning: true, ContextWindow: 128000}},
+	{prefix: "deepseek-", capabilities: ModelCapabilities{SupportsTools: true, SupportsStreaming: true, ContextWindow: 128000}},
+	{prefix: "kimi-k2-thinking", capabilities: ModelCapabilities{SupportsTools: true, SupportsStreaming: true, SupportsReasoning: true, ContextWindow: 262144}},
+	{prefix: "kimi-", capabilities: ModelCapabilities{SupportsTools: true, SupportsStreaming: true, ContextWindow: 262144}},
+	{prefix: "qwen3-", capabilities: ModelCapabilities{SupportsTools: true, SupportsStreaming: true, ContextWindow: 262144}},
+	{prefix: "glm-", capabilities: ModelCapabilities{SupportsTools: true, SupportsStreaming: true, SupportsReasoning: true, ContextWindow: 200000}},
+}
+
+// defaultCapabilities applies to models that match no table entry.
+var defaultCapabilities = ModelCapabilities{SupportsTools: true, SupportsStreaming: true}
+
+// LookupModelCapabilities returns the capabilities for a model ID.
+// Registry metadata (thinking support, context length) takes precedence over the
+// static capability table, which in turn takes precedence over the defaults.
+func LookupModelCapabilities(modelID string) ModelCapabilities {
+	caps := defaultCapabilities
+	normalized := strings.ToLower(strings.TrimSpace(modelID))
+	for _, entry := range capabilityTable {
+		if strings.HasPrefix(normalized, entry.prefix) {
+			caps = entry.capabilities
+			break
+		}
+	}
+
+	if info := GetGlobalRegistry().GetModelInfo(modelID); info != nil {
+		if info.Thinking != nil {
+			caps.SupportsReasoning = true
+		}
+		switch {
+		case info.ContextLength > 0:
+			caps.ContextWindow = int64(info.ContextLength)
+		case info.InputTokenLimit > 0:
+			caps.ContextWindow = int64(info.InputTokenLimit)
+		}
+	}
+
+	return caps
+}
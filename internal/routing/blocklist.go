@@ -0,0 +1,70 @@
+package routing
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// Block describes why a model was rejected.
+type Block struct {
+	// Pattern is the configured pattern that matched.
+	Pattern string
+
+	// Message is the operator-supplied explanation.
+	Message string
+
+	// Replacement is the suggested alternative model, if any.
+	Replacement string
+}
+
+type blockedModel struct {
+	re    *regexp.Regexp
+	block Block
+}
+
+// compileBlockedModels turns wildcard patterns into anchored, case-insensitive expressions.
+func compileBlockedModels(entries []config.BlockedModel) ([]blockedModel, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	out := make([]blockedModel, 0, len(entries))
+	for i, entry := range entries {
+		pattern := strings.TrimSpace(entry.Pattern)
+		if pattern == "" {
+			continue
+		}
+		expr := regexp.QuoteMeta(pattern)
+		expr = strings.ReplaceAll(expr, `\*`, ".*")
+		expr = strings.ReplaceAll(expr, `\?`, ".")
+		re, err := regexp.Compile("(?i)^" + expr + "$")
+		if err != nil {
+			return nil, fmt.Errorf("model-routing blocked model %d: invalid pattern %q: %w", i, pattern, err)
+		}
+		out = append(out, blockedModel{
+			re: re,
+			block: Block{
+				Pattern:     pattern,
+				Message:     strings.TrimSpace(entry.Message),
+				Replacement: strings.TrimSpace(entry.Replacement),
+			},
+		})
+	}
+	return out, nil
+}
+
+// Blocked reports whether model matches a blocked-models entry, returning the first match.
+func (r *Resolver) Blocked(model string) (Block, bool) {
+	if r == nil || len(r.blocked) == 0 {
+		return Block{}, false
+	}
+	model = strings.TrimSpace(model)
+	for _, entry := range r.blocked {
+		if entry.re.MatchString(model) {
+			return entry.block, true
+		}
+	}
+	return Block{}, false
+}
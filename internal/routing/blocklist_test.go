@@ -0,0 +1,40 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestResolverBlockedPatterns(t *testing.T) {
+	r, err := NewResolver(config.ModelRoutingConfig{
+		BlockedModels: []config.BlockedModel{
+			{Pattern: "gpt-4", Message: "retired", Replacement: "gpt-5"},
+			{Pattern: "claude-3-*"},
+			{Pattern: "gemini-1.?-pro"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+
+	block, ok := r.Blocked("GPT-4")
+	if !ok || block.Message != "retired" || block.Replacement != "gpt-5" {
+		t.Fatalf("exact block = %+v, %v", block, ok)
+	}
+	for _, model := range []string{"claude-3-opus", "Claude-3-haiku-20240307", "gemini-1.5-pro"} {
+		if _, ok := r.Blocked(model); !ok {
+			t.Errorf("%s: expected blocked", model)
+		}
+	}
+	for _, model := range []string{"gpt-4o", "claude-sonnet-4-5", "gemini-1.55-pro", "gemini-1x5-pro-latest"} {
+		if _, ok := r.Blocked(model); ok {
+			t.Errorf("%s: unexpectedly blocked", model)
+		}
+	}
+
+	var nilResolver *Resolver
+	if _, ok := nilResolver.Blocked("gpt-4"); ok {
+		t.Fatalf("nil resolver must not block")
+	}
+}
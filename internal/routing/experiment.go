@@ -0,0 +1,90 @@
+package routing
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"sort"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// experiment holds the compiled variants for one logical model.
+type experiment struct {
+	variants    []experimentVariant
+	totalWeight int
+}
+
+type experimentVariant struct {
+	model  string
+	weight int
+}
+
+// compileExperiments builds the experiment table keyed by lower-cased logical model.
+// Variants with non-positive weights are dropped; experiments without variants are skipped.
+func compileExperiments(entries []config.ModelExperiment) map[string]*experiment {
+	if len(entries) == 0 {
+		return nil
+	}
+	out := make(map[string]*experiment, len(entries))
+	for _, entry := range entries {
+		model := strings.ToLower(strings.TrimSpace(entry.Model))
+		if model == "" {
+			continue
+		}
+		exp := &experiment{}
+		for name, weight := range entry.Variants {
+			name = strings.TrimSpace(name)
+			if name == "" || weight <= 0 {
+				continue
+			}
+			exp.variants = append(exp.variants, experimentVariant{model: name, weight: weight})
+			exp.totalWeight += weight
+		}
+		if len(exp.variants) == 0 {
+			continue
+		}
+		// Sort so bucket boundaries are stable regardless of map iteration order.
+		sort.Slice(exp.variants, func(i, j int) bool { return exp.variants[i].model < exp.variants[j].model })
+		out[model] = exp
+	}
+	return out
+}
+
+// pick maps a bucket in [0, totalWeight) onto a variant.
+func (e *experiment) pick(bucket int) string {
+	for _, v := range e.variants {
+		if bucket < v.weight {
+			return v.model
+		}
+		bucket -= v.weight
+	}
+	return e.variants[len(e.variants)-1].model
+}
+
+// AssignVariant selects the variant model serving a logical model.
+// When sessionID is non-empty the assignment is sticky: the same session always
+// receives the same variant. Otherwise a variant is drawn at random by weight.
+// It reports false when the model has no experiment configured.
+func (r *Resolver) AssignVariant(model, sessionID string) (string, bool) {
+	if r == nil || len(r.experiments) == 0 {
+		return "", false
+	}
+	key := strings.ToLower(strings.TrimSpace(model))
+	exp, ok := r.experiments[key]
+	if !ok {
+		return "", false
+	}
+
+	var bucket int
+	if sessionID != "" {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(key))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(sessionID))
+		bucket = int(h.Sum64() % uint64(exp.totalWeight))
+	} else {
+		bucket = rand.IntN(exp.totalWeight)
+	}
+	return exp.pick(bucket), true
+}
@@ -0,0 +1,75 @@
+package routing
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func newExperimentResolver(t *testing.T) *Resolver {
+	t.Helper()
+	r, err := NewResolver(config.ModelRoutingConfig{
+		Experiments: []config.ModelExperiment{{
+			Model:    "chat-default",
+			Variants: map[string]int{"gpt-5": 80, "claude-sonnet-4-5": 20},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+	return r
+}
+
+func TestAssignVariantConvergesToConfiguredRatio(t *testing.T) {
+	r := newExperimentResolver(t)
+
+	const total = 20000
+	cases := map[string]func(i int) string{
+		"sessions":   func(i int) string { return fmt.Sprintf("session-%d", i) },
+		"no-session": func(int) string { return "" },
+	}
+	for name, sessionFor := range cases {
+		counts := map[string]int{}
+		for i := 0; i < total; i++ {
+			variant, ok := r.AssignVariant("chat-default", sessionFor(i))
+			if !ok {
+				t.Fatalf("%s: expected experiment to apply", name)
+			}
+			counts[variant]++
+		}
+		share := float64(counts["gpt-5"]) / total
+		if math.Abs(share-0.8) > 0.02 {
+			t.Errorf("%s: gpt-5 share = %.3f, want ~0.80 (counts %v)", name, share, counts)
+		}
+		if counts["gpt-5"]+counts["claude-sonnet-4-5"] != total {
+			t.Errorf("%s: unexpected variants assigned: %v", name, counts)
+		}
+	}
+}
+
+func TestAssignVariantStickyPerSession(t *testing.T) {
+	r := newExperimentResolver(t)
+
+	for i := 0; i < 50; i++ {
+		session := fmt.Sprintf("user-%d", i)
+		first, _ := r.AssignVariant("chat-default", session)
+		for j := 0; j < 20; j++ {
+			if got, _ := r.AssignVariant("Chat-Default", session); got != first {
+				t.Fatalf("session %s: variant changed from %q to %q", session, first, got)
+			}
+		}
+	}
+}
+
+func TestAssignVariantUnconfiguredModel(t *testing.T) {
+	r := newExperimentResolver(t)
+	if _, ok := r.AssignVariant("gemini-2.5-pro", "s"); ok {
+		t.Fatalf("expected no experiment for gemini-2.5-pro")
+	}
+	var nilResolver *Resolver
+	if _, ok := nilResolver.AssignVariant("chat-default", "s"); ok {
+		t.Fatalf("expected nil resolver to report no experiment")
+	}
+}
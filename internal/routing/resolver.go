@@ -0,0 +1,199 @@
+// Package routing resolves requested model names to target providers using
+// declarative, ordered rules. It centralizes the model → provider decision that
+// is otherwise implicit in the model registry.
+package routing
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// MatchType selects how a rule pattern is compared to a model name.
+type MatchType string
+
+const (
+	// MatchExact requires the model name to equal the pattern (case-insensitive).
+	MatchExact MatchType = "exact"
+
+	// MatchPrefix requires the model name to start with the pattern (case-insensitive).
+	MatchPrefix MatchType = "prefix"
+
+	// MatchRegex evaluates the pattern as a regular expression against the model name.
+	MatchRegex MatchType = "regex"
+)
+
+// Resolution describes the routing decision for a model.
+type Resolution struct {
+	// Provider is the target provider identifier.
+	Provider string
+
+	// CredentialGroup optionally restricts routing to prefixed credentials.
+	CredentialGroup string
+
+	// RuleIndex is the index of the matching rule, or -1 when the default applied.
+	RuleIndex int
+
+	// Default reports whether the default provider was used.
+	Default bool
+}
+
+// Model returns the model name to dispatch, applying the credential group prefix when set.
+func (r Resolution) Model(model string) string {
+	group := strings.TrimSpace(r.CredentialGroup)
+	if group == "" || strings.HasPrefix(model, group+"/") {
+		return model
+	}
+	return group + "/" + model
+}
+
+type compiledRule struct {
+	match           MatchType
+	pattern         string
+	re              *regexp.Regexp
+	provider        string
+	credentialGroup string
+}
+
+func (r *compiledRule) matches(model string) bool {
+	switch r.match {
+	case MatchPrefix:
+		return strings.HasPrefix(strings.ToLower(model), r.pattern)
+	case MatchRegex:
+		return r.re.MatchString(model)
+	default:
+		return strings.EqualFold(model, r.pattern)
+	}
+}
+
+// Resolver evaluates routing rules in order.
+type Resolver struct {
+	rules           []compiledRule
+	defaultProvider string
+	experiments     map[string]*experiment
+	fallbacks       map[string][]string
+	blocked         []blockedModel
+}
+
+// NewResolver compiles routing rules from configuration.
+// Rules with an empty pattern or provider are skipped; an invalid regular expression
+// or unknown match type returns an error.
+func NewResolver(cfg config.ModelRoutingConfig) (*Resolver, error) {
+	r := &Resolver{
+		rules:           make([]compiledRule, 0, len(cfg.Rules)),
+		defaultProvider: strings.ToLower(strings.TrimSpace(cfg.DefaultProvider)),
+		experiments:     compileExperiments(cfg.Experiments),
+		fallbacks:       compileFallbackChains(cfg.FallbackChains),
+	}
+	blocked, err := compileBlockedModels(cfg.BlockedModels)
+	if err != nil {
+		return nil, err
+	}
+	r.blocked = blocked
+	for i, rule := range cfg.Rules {
+		pattern := strings.TrimSpace(rule.Pattern)
+		provider := strings.ToLower(strings.TrimSpace(rule.Provider))
+		if pattern == "" || provider == "" {
+			continue
+		}
+		compiled := compiledRule{
+			match:           MatchType(strings.ToLower(strings.TrimSpace(rule.Match))),
+			provider:        provider,
+			credentialGroup: strings.Trim(strings.TrimSpace(rule.CredentialGroup), "/"),
+		}
+		switch compiled.match {
+		case "", MatchExact:
+			compiled.match = MatchExact
+			compiled.pattern = pattern
+		case MatchPrefix:
+			compiled.pattern = strings.ToLower(pattern)
+		case MatchRegex:
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("model-routing rule %d: invalid regex %q: %w", i, pattern, err)
+			}
+			compiled.pattern = pattern
+			compiled.re = re
+		default:
+			return nil, fmt.Errorf("model-routing rule %d: unknown match type %q", i, rule.Match)
+		}
+		r.rules = append(r.rules, compiled)
+	}
+	return r, nil
+}
+
+// Resolve returns the first matching rule for the model.
+// It reports false when no rule matches; use Default to apply the fallback provider.
+func (r *Resolver) Resolve(model string) (Resolution, bool) {
+	if r == nil || model == "" {
+		return Resolution{RuleIndex: -1}, false
+	}
+	for i := range r.rules {
+		rule := &r.rules[i]
+		if rule.matches(model) {
+			return Resolution{
+				Provider:        rule.provider,
+				CredentialGroup: rule.credentialGroup,
+				RuleIndex:       i,
+			}, true
+		}
+	}
+	return Resolution{RuleIndex: -1}, false
+}
+
+// Default returns the fallback resolution, reporting false when no default provider is configured.
+func (r *Resolver) Default() (Resolution, bool) {
+	if r == nil || r.defaultProvider == "" {
+		return Resolution{RuleIndex: -1}, false
+	}
+	return Resolution{Provider: r.defaultProvider, RuleIndex: -1, Default: true}, true
+}
+
+// FallbackChain returns the ordered alternate models for model, or nil when none are configured.
+func (r *Resolver) FallbackChain(model string) []string {
+	if r == nil || len(r.fallbacks) == 0 {
+		return nil
+	}
+	return r.fallbacks[strings.ToLower(strings.TrimSpace(model))]
+}
+
+// compileFallbackChains normalizes chain keys and drops blank, self-referencing, and duplicate entries.
+func compileFallbackChains(chains map[string][]string) map[string][]string {
+	if len(chains) == 0 {
+		return nil
+	}
+	out := make(map[string][]string, len(chains))
+	for model, chain := range chains {
+		key := strings.ToLower(strings.TrimSpace(model))
+		if key == "" {
+			continue
+		}
+		seen := map[string]struct{}{key: {}}
+		cleaned := make([]string, 0, len(chain))
+		for _, alt := range chain {
+			alt = strings.TrimSpace(alt)
+			if alt == "" {
+				continue
+			}
+			if _, dup := seen[strings.ToLower(alt)]; dup {
+				continue
+			}
+			seen[strings.ToLower(alt)] = struct{}{}
+			cleaned = append(cleaned, alt)
+		}
+		if len(cleaned) > 0 {
+			out[key] = cleaned
+		}
+	}
+	return out
+}
+
+// Len returns the number of active rules.
+func (r *Resolver) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.rules)
+}
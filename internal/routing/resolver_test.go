@@ -0,0 +1,120 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestResolverPrefixMatch(t *testing.T) {
+	r, err := NewResolver(config.ModelRoutingConfig{
+		Rules: []config.ModelRoutingRule{
+			{Match: "prefix", Pattern: "Claude-", Provider: "claude"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+
+	res, ok := r.Resolve("claude-sonnet-4-5")
+	if !ok {
+		t.Fatalf("expected prefix rule to match")
+	}
+	if res.Provider != "claude" || res.RuleIndex != 0 {
+		t.Fatalf("unexpected resolution: %+v", res)
+	}
+	if _, ok := r.Resolve("gemini-2.5-pro"); ok {
+		t.Fatalf("expected no match for gemini model")
+	}
+}
+
+func TestResolverRegexMatchWithCredentialGroup(t *testing.T) {
+	r, err := NewResolver(config.ModelRoutingConfig{
+		Rules: []config.ModelRoutingRule{
+			{Match: "regex", Pattern: `^gpt-5(\.\d+)?-codex`, Provider: "codex", CredentialGroup: "teamA"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+
+	res, ok := r.Resolve("gpt-5.1-codex-mini")
+	if !ok {
+		t.Fatalf("expected regex rule to match")
+	}
+	if res.Provider != "codex" {
+		t.Fatalf("provider = %q, want codex", res.Provider)
+	}
+	if got := res.Model("gpt-5.1-codex-mini"); got != "teamA/gpt-5.1-codex-mini" {
+		t.Fatalf("Model() = %q, want teamA/gpt-5.1-codex-mini", got)
+	}
+	if got := res.Model("teamA/gpt-5.1-codex-mini"); got != "teamA/gpt-5.1-codex-mini" {
+		t.Fatalf("Model() re-prefixed an already prefixed model: %q", got)
+	}
+	if _, ok := r.Resolve("gpt-5"); ok {
+		t.Fatalf("expected no match for gpt-5")
+	}
+}
+
+func TestResolverInvalidRegex(t *testing.T) {
+	_, err := NewResolver(config.ModelRoutingConfig{
+		Rules: []config.ModelRoutingRule{{Match: "regex", Pattern: "(", Provider: "codex"}},
+	})
+	if err == nil {
+		t.Fatalf("expected error for invalid regex")
+	}
+}
+
+func TestResolverDefaultFallback(t *testing.T) {
+	r, err := NewResolver(config.ModelRoutingConfig{
+		Rules:           []config.ModelRoutingRule{{Pattern: "gpt-5", Provider: "codex"}},
+		DefaultProvider: "OpenAI-Compat",
+	})
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+
+	if _, ok := r.Resolve("unknown-model"); ok {
+		t.Fatalf("expected no rule match for unknown model")
+	}
+	res, ok := r.Default()
+	if !ok {
+		t.Fatalf("expected default resolution")
+	}
+	if res.Provider != "openai-compat" || !res.Default || res.RuleIndex != -1 {
+		t.Fatalf("unexpected default resolution: %+v", res)
+	}
+
+	empty, _ := NewResolver(config.ModelRoutingConfig{})
+	if _, ok := empty.Default(); ok {
+		t.Fatalf("expected no default when unconfigured")
+	}
+}
+
+func TestResolverRuleOrderPrecedence(t *testing.T) {
+	r, err := NewResolver(config.ModelRoutingConfig{
+		Rules: []config.ModelRoutingRule{
+			{Match: "exact", Pattern: "gemini-2.5-pro", Provider: "vertex"},
+			{Match: "prefix", Pattern: "gemini-", Provider: "gemini"},
+			{Match: "regex", Pattern: ".*", Provider: "openai-compat"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+
+	cases := map[string]string{
+		"gemini-2.5-pro":   "vertex",
+		"gemini-2.5-flash": "gemini",
+		"kimi-k2":          "openai-compat",
+	}
+	for model, want := range cases {
+		res, ok := r.Resolve(model)
+		if !ok {
+			t.Fatalf("%s: expected match", model)
+		}
+		if res.Provider != want {
+			t.Errorf("%s: provider = %q, want %q", model, res.Provider, want)
+		}
+	}
+}
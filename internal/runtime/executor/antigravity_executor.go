@@ -137,7 +137,7 @@ func (e *AntigravityExecutor) Execute(ctx context.Context, auth *cliproxyauth.Au
 	translated = normalizeAntigravityThinking(req.Model, translated, isClaude)
 	translated = applyPayloadConfigWithRoot(e.cfg, req.Model, "antigravity", "request", translated, originalTranslated)
 
-	baseURLs := antigravityBaseURLFallbackOrder(auth)
+	baseURLs := antigravityBaseURLFallbackOrder(e.cfg, auth)
 	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
 	retryCfg := DefaultRetryConfig()
 
@@ -202,7 +202,7 @@ func (e *AntigravityExecutor) Execute(ctx context.Context, auth *cliproxyauth.Au
 				}
 
 				// No more fallback URLs, apply backoff and retry same URLs if attempts remain
-				if retryAttempt < retryCfg.MaxRetries {
+				if retryAttempt < retryCfg.MaxRetries && AllowRetry() {
 					delay := CalculateBackoff(retryCfg, retryAttempt, retryAfter)
 					log.Debugf("antigravity executor: rate limited, waiting %v before retry attempt %d", delay, retryAttempt+1)
 					if !SleepWithContext(ctx, delay) {
@@ -219,7 +219,7 @@ func (e *AntigravityExecutor) Execute(ctx context.Context, auth *cliproxyauth.Au
 			}
 
 			// For other retryable errors (500, 502, 503, 504), also apply backoff
-			if IsRetryableError(httpResp.StatusCode) && retryAttempt < retryCfg.MaxRetries {
+			if IsRetryableError(httpResp.StatusCode) && retryAttempt < retryCfg.MaxRetries && AllowRetry() {
 				delay := CalculateBackoff(retryCfg, retryAttempt, nil)
 				log.Debugf("antigravity executor: retryable error %d, waiting %v before retry attempt %d", httpResp.StatusCode, delay, retryAttempt+1)
 				if !SleepWithContext(ctx, delay) {
@@ -291,7 +291,7 @@ func (e *AntigravityExecutor) executeClaudeNonStream(ctx context.Context, auth *
 	translated = normalizeAntigravityThinking(req.Model, translated, true)
 	translated = applyPayloadConfigWithRoot(e.cfg, req.Model, "antigravity", "request", translated, originalTranslated)
 
-	baseURLs := antigravityBaseURLFallbackOrder(auth)
+	baseURLs := antigravityBaseURLFallbackOrder(e.cfg, auth)
 	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
 	retryCfg := DefaultRetryConfig()
 
@@ -366,7 +366,7 @@ func (e *AntigravityExecutor) executeClaudeNonStream(ctx context.Context, auth *
 					continue
 				}
 
-				if retryAttempt < retryCfg.MaxRetries {
+				if retryAttempt < retryCfg.MaxRetries && AllowRetry() {
 					delay := CalculateBackoff(retryCfg, retryAttempt, retryAfter)
 					log.Debugf("antigravity executor: claude rate limited, waiting %v before retry attempt %d", delay, retryAttempt+1)
 					if !SleepWithContext(ctx, delay) {
@@ -382,7 +382,7 @@ func (e *AntigravityExecutor) executeClaudeNonStream(ctx context.Context, auth *
 				return resp, err
 			}
 
-			if IsRetryableError(httpResp.StatusCode) && retryAttempt < retryCfg.MaxRetries {
+			if IsRetryableError(httpResp.StatusCode) && retryAttempt < retryCfg.MaxRetries && AllowRetry() {
 				delay := CalculateBackoff(retryCfg, retryAttempt, nil)
 				log.Debugf("antigravity executor: claude retryable error %d, waiting %v before retry attempt %d", httpResp.StatusCode, delay, retryAttempt+1)
 				if !SleepWithContext(ctx, delay) {
@@ -691,7 +691,7 @@ func (e *AntigravityExecutor) ExecuteStream(ctx context.Context, auth *cliproxya
 	translated = normalizeAntigravityThinking(req.Model, translated, isClaude)
 	translated = applyPayloadConfigWithRoot(e.cfg, req.Model, "antigravity", "request", translated, originalTranslated)
 
-	baseURLs := antigravityBaseURLFallbackOrder(auth)
+	baseURLs := antigravityBaseURLFallbackOrder(e.cfg, auth)
 	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
 	retryCfg := DefaultRetryConfig()
 
@@ -768,7 +768,7 @@ func (e *AntigravityExecutor) ExecuteStream(ctx context.Context, auth *cliproxya
 				}
 
 				// No more fallback URLs, apply backoff and retry same URLs if attempts remain
-				if retryAttempt < retryCfg.MaxRetries {
+				if retryAttempt < retryCfg.MaxRetries && AllowRetry() {
 					delay := CalculateBackoff(retryCfg, retryAttempt, retryAfter)
 					log.Debugf("antigravity executor: stream rate limited, waiting %v before retry attempt %d", delay, retryAttempt+1)
 					if !SleepWithContext(ctx, delay) {
@@ -785,7 +785,7 @@ func (e *AntigravityExecutor) ExecuteStream(ctx context.Context, auth *cliproxya
 			}
 
 			// For other retryable errors (500, 502, 503, 504), also apply backoff
-			if IsRetryableError(httpResp.StatusCode) && retryAttempt < retryCfg.MaxRetries {
+			if IsRetryableError(httpResp.StatusCode) && retryAttempt < retryCfg.MaxRetries && AllowRetry() {
 				delay := CalculateBackoff(retryCfg, retryAttempt, nil)
 				log.Debugf("antigravity executor: stream retryable error %d, waiting %v before retry attempt %d", httpResp.StatusCode, delay, retryAttempt+1)
 				if !SleepWithContext(ctx, delay) {
@@ -923,7 +923,7 @@ func (e *AntigravityExecutor) CountTokens(ctx context.Context, auth *cliproxyaut
 
 	isClaude := strings.Contains(strings.ToLower(req.Model), "claude")
 
-	baseURLs := antigravityBaseURLFallbackOrder(auth)
+	baseURLs := antigravityBaseURLFallbackOrder(e.cfg, auth)
 	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
 	retryCfg := DefaultRetryConfig()
 
@@ -950,7 +950,7 @@ func (e *AntigravityExecutor) CountTokens(ctx context.Context, auth *cliproxyaut
 
 		base := strings.TrimSuffix(baseURL, "/")
 		if base == "" {
-			base = buildBaseURL(auth)
+			base = buildBaseURL(e.cfg, auth)
 		}
 
 		var requestURL strings.Builder
@@ -1034,7 +1034,7 @@ func (e *AntigravityExecutor) CountTokens(ctx context.Context, auth *cliproxyaut
 				continue
 			}
 
-			if retryAttempt < retryCfg.MaxRetries {
+			if retryAttempt < retryCfg.MaxRetries && AllowRetry() {
 				delay := CalculateBackoff(retryCfg, retryAttempt, retryAfter)
 				log.Debugf("antigravity executor: token count rate limited, waiting %v before retry attempt %d", delay, retryAttempt+1)
 				if !SleepWithContext(ctx, delay) {
@@ -1049,7 +1049,7 @@ func (e *AntigravityExecutor) CountTokens(ctx context.Context, auth *cliproxyaut
 			return cliproxyexecutor.Response{}, sErr
 		}
 
-		if IsRetryableError(httpResp.StatusCode) && retryAttempt < retryCfg.MaxRetries {
+		if IsRetryableError(httpResp.StatusCode) && retryAttempt < retryCfg.MaxRetries && AllowRetry() {
 			delay := CalculateBackoff(retryCfg, retryAttempt, nil)
 			log.Debugf("antigravity executor: token count retryable error %d, waiting %v before retry attempt %d", httpResp.StatusCode, delay, retryAttempt+1)
 			if !SleepWithContext(ctx, delay) {
@@ -1104,7 +1104,7 @@ func FetchAntigravityModels(ctx context.Context, auth *cliproxyauth.Auth, cfg *c
 		auth = updatedAuth
 	}
 
-	baseURLs := antigravityBaseURLFallbackOrder(auth)
+	baseURLs := antigravityBaseURLFallbackOrder(cfg, auth)
 	httpClient := newProxyAwareHTTPClient(ctx, cfg, auth, 0)
 	retryCfg := DefaultRetryConfig()
 	var retryAttempt int
@@ -1153,7 +1153,7 @@ func FetchAntigravityModels(ctx context.Context, auth *cliproxyauth.Auth, cfg *c
 					continue
 				}
 
-				if retryAttempt < retryCfg.MaxRetries {
+				if retryAttempt < retryCfg.MaxRetries && AllowRetry() {
 					delay := CalculateBackoff(retryCfg, retryAttempt, nil)
 					log.Debugf("antigravity executor: models request rate limited, waiting %v before retry attempt %d", delay, retryAttempt+1)
 					if !SleepWithContext(ctx, delay) {
@@ -1165,7 +1165,7 @@ func FetchAntigravityModels(ctx context.Context, auth *cliproxyauth.Auth, cfg *c
 				}
 			}
 
-			if IsRetryableError(httpResp.StatusCode) && retryAttempt < retryCfg.MaxRetries {
+			if IsRetryableError(httpResp.StatusCode) && retryAttempt < retryCfg.MaxRetries && AllowRetry() {
 				delay := CalculateBackoff(retryCfg, retryAttempt, nil)
 				log.Debugf("antigravity executor: models request retryable error %d, waiting %v before retry attempt %d", httpResp.StatusCode, delay, retryAttempt+1)
 				if !SleepWithContext(ctx, delay) {
@@ -1334,7 +1334,7 @@ func (e *AntigravityExecutor) buildRequest(ctx context.Context, auth *cliproxyau
 
 	base := strings.TrimSuffix(baseURL, "/")
 	if base == "" {
-		base = buildBaseURL(auth)
+		base = buildBaseURL(e.cfg, auth)
 	}
 	path := antigravityGeneratePath
 	if stream {
@@ -1488,8 +1488,8 @@ func int64Value(value any) (int64, bool) {
 	return 0, false
 }
 
-func buildBaseURL(auth *cliproxyauth.Auth) string {
-	if baseURLs := antigravityBaseURLFallbackOrder(auth); len(baseURLs) > 0 {
+func buildBaseURL(cfg *config.Config, auth *cliproxyauth.Auth) string {
+	if baseURLs := antigravityBaseURLFallbackOrder(cfg, auth); len(baseURLs) > 0 {
 		return baseURLs[0]
 	}
 	return antigravityBaseURLDaily
@@ -1522,10 +1522,13 @@ func resolveUserAgent(auth *cliproxyauth.Auth) string {
 	return defaultAntigravityAgent
 }
 
-func antigravityBaseURLFallbackOrder(auth *cliproxyauth.Auth) []string {
+func antigravityBaseURLFallbackOrder(cfg *config.Config, auth *cliproxyauth.Auth) []string {
 	if base := resolveCustomAntigravityBaseURL(auth); base != "" {
 		return []string{base}
 	}
+	if base := cfg.ProviderBaseURL(antigravityAuthType); base != "" {
+		return []string{base}
+	}
 	return []string{
 		antigravitySandboxBaseURLDaily,
 		antigravityBaseURLDaily,
@@ -0,0 +1,17 @@
+// Package executor provides runtime execution capabilities for various AI service providers.
+// This file resolves upstream base URLs from per-provider configuration overrides.
+package executor
+
+import (
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// providerBaseURL returns the configured provider-base-urls override for provider,
+// falling back to the executor's built-in endpoint when none is configured.
+// Callers consult credential-level base_url attributes before this.
+func providerBaseURL(cfg *config.Config, provider, fallback string) string {
+	if override := cfg.ProviderBaseURL(provider); override != "" {
+		return override
+	}
+	return fallback
+}
@@ -0,0 +1,59 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+func TestClaudeExecutor_UsesProviderBaseURLOverride(t *testing.T) {
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{ProviderBaseURLs: map[string]string{"Claude": srv.URL + "/gateway/"}}
+	if err := cfg.SanitizeProviderBaseURLs(); err != nil {
+		t.Fatalf("SanitizeProviderBaseURLs: %v", err)
+	}
+	exec := NewClaudeExecutor(cfg)
+	auth := &cliproxyauth.Auth{ID: "claude-auth", Provider: "claude", Attributes: map[string]string{"api_key": "sk-test"}}
+
+	_, err := exec.Execute(context.Background(), auth, cliproxyexecutor.Request{
+		Model:   "claude-sonnet-4",
+		Payload: []byte(`{"model":"claude-sonnet-4","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`),
+	}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("claude")})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if gotPath != "/gateway/v1/messages" {
+		t.Fatalf("upstream path = %q, want /gateway/v1/messages", gotPath)
+	}
+}
+
+func TestProviderBaseURL_CredentialBaseURLWins(t *testing.T) {
+	cfg := &config.Config{ProviderBaseURLs: map[string]string{"gemini": "https://gemini.example.com"}}
+
+	if got := resolveGeminiBaseURL(cfg, &cliproxyauth.Auth{}); got != "https://gemini.example.com" {
+		t.Errorf("override base = %q, want https://gemini.example.com", got)
+	}
+	withCredential := &cliproxyauth.Auth{Attributes: map[string]string{"base_url": "https://key.example.com/"}}
+	if got := resolveGeminiBaseURL(cfg, withCredential); got != "https://key.example.com" {
+		t.Errorf("credential base = %q, want https://key.example.com", got)
+	}
+	if got := resolveGeminiBaseURL(nil, &cliproxyauth.Auth{}); got != glEndpoint {
+		t.Errorf("default base = %q, want %q", got, glEndpoint)
+	}
+	if got := antigravityBaseURLFallbackOrder(&config.Config{ProviderBaseURLs: map[string]string{"antigravity": "https://ag.example.com"}}, nil); len(got) != 1 || got[0] != "https://ag.example.com" {
+		t.Errorf("antigravity bases = %v, want [https://ag.example.com]", got)
+	}
+}
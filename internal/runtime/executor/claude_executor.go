@@ -114,7 +114,7 @@ func (e *ClaudeExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 	apiKey, baseURL := claudeCreds(auth)
 
 	if baseURL == "" {
-		baseURL = "https://api.anthropic.com"
+		baseURL = providerBaseURL(e.cfg, e.Identifier(), "https://api.anthropic.com")
 	}
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
 	defer reporter.trackFailure(ctx, &err)
@@ -268,7 +268,7 @@ func (e *ClaudeExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 	apiKey, baseURL := claudeCreds(auth)
 
 	if baseURL == "" {
-		baseURL = "https://api.anthropic.com"
+		baseURL = providerBaseURL(e.cfg, e.Identifier(), "https://api.anthropic.com")
 	}
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
 	defer reporter.trackFailure(ctx, &err)
@@ -435,7 +435,7 @@ func (e *ClaudeExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Aut
 	apiKey, baseURL := claudeCreds(auth)
 
 	if baseURL == "" {
-		baseURL = "https://api.anthropic.com"
+		baseURL = providerBaseURL(e.cfg, e.Identifier(), "https://api.anthropic.com")
 	}
 
 	from := opts.SourceFormat
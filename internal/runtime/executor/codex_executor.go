@@ -75,7 +75,7 @@ func (e *CodexExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, re
 	apiKey, baseURL := codexCreds(auth)
 
 	if baseURL == "" {
-		baseURL = "https://chatgpt.com/backend-api/codex"
+		baseURL = providerBaseURL(e.cfg, e.Identifier(), "https://chatgpt.com/backend-api/codex")
 	}
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
 	defer reporter.trackFailure(ctx, &err)
@@ -185,7 +185,7 @@ func (e *CodexExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Au
 	apiKey, baseURL := codexCreds(auth)
 
 	if baseURL == "" {
-		baseURL = "https://chatgpt.com/backend-api/codex"
+		baseURL = providerBaseURL(e.cfg, e.Identifier(), "https://chatgpt.com/backend-api/codex")
 	}
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
 	defer reporter.trackFailure(ctx, &err)
@@ -27,12 +27,17 @@ type StreamFanoutResult struct {
 // CheckStreamFanout checks if a stream fanout is available for the given request.
 // Returns a result indicating whether to create a new upstream or subscribe to existing.
 func CheckStreamFanout(model string, payload []byte) StreamFanoutResult {
+	return CheckStreamFanoutKey(generateStreamKey(model, payload))
+}
+
+// CheckStreamFanoutKey is CheckStreamFanout for a caller-supplied stream key,
+// such as one derived from IdempotentStreamKey.
+func CheckStreamFanoutKey(key string) StreamFanoutResult {
 	fanout := GetStreamFanout()
 	if !fanout.IsEnabled() {
 		return StreamFanoutResult{IsNew: true}
 	}
 
-	key := generateStreamKey(model, payload)
 	stream, isNew, sub := fanout.GetOrCreateStream(key)
 
 	return StreamFanoutResult{
@@ -43,6 +48,17 @@ func CheckStreamFanout(model string, payload []byte) StreamFanoutResult {
 	}
 }
 
+// ResumeStreamFanoutKey subscribes a reconnecting client to the stream under key from just
+// after lastEventID. ok is false when the stream cannot be resumed from that id, in which
+// case the caller handles the request as a fresh one.
+func ResumeStreamFanoutKey(key, lastEventID string) (StreamFanoutResult, bool) {
+	stream, sub, ok := GetStreamFanout().ResumeStream(key, lastEventID)
+	if !ok {
+		return StreamFanoutResult{}, false
+	}
+	return StreamFanoutResult{Stream: stream, Subscriber: sub, Key: key}, true
+}
+
 // generateStreamKey creates a unique key for request deduplication.
 func generateStreamKey(model string, payload []byte) string {
 	h := sha256.New()
@@ -51,6 +67,26 @@ func generateStreamKey(model string, payload []byte) string {
 	return hex.EncodeToString(h.Sum(nil))[:16]
 }
 
+// IdempotentStreamKey derives a stream key from a client Idempotency-Key so that
+// concurrent requests carrying the same key share one upstream stream. The handler
+// type and model are included so a reused key never mixes response formats, the
+// principal so one caller can never attach to another's stream, and the payload so a
+// key reused for a different request starts its own upstream instead of receiving the
+// first request's completion.
+func IdempotentStreamKey(handlerType, model, principal, idempotencyKey string, payload []byte) string {
+	h := sha256.New()
+	h.Write([]byte(handlerType))
+	h.Write([]byte{0})
+	h.Write([]byte(model))
+	h.Write([]byte{0})
+	h.Write([]byte(principal))
+	h.Write([]byte{0})
+	h.Write([]byte(idempotencyKey))
+	h.Write([]byte{0})
+	h.Write(NormalizePayloadForDedup(payload))
+	return "idem:" + hex.EncodeToString(h.Sum(nil))[:16]
+}
+
 // PublishToFanout publishes stream chunks to all subscribers.
 // Call this for each chunk received from the upstream.
 func PublishToFanout(stream *SharedStream, data []byte) {
@@ -135,7 +135,7 @@ func (e *GeminiExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 			action = "countTokens"
 		}
 	}
-	baseURL := resolveGeminiBaseURL(auth)
+	baseURL := resolveGeminiBaseURL(e.cfg, auth)
 	url := fmt.Sprintf("%s/%s/models/%s:%s", baseURL, glAPIVersion, model, action)
 	if opts.Alt != "" && action != "countTokens" {
 		url = url + fmt.Sprintf("?$alt=%s", opts.Alt)
@@ -232,7 +232,7 @@ func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 	body = applyPayloadConfigWithRoot(e.cfg, model, to.String(), "", body, originalTranslated)
 	body, _ = sjson.SetBytes(body, "model", model)
 
-	baseURL := resolveGeminiBaseURL(auth)
+	baseURL := resolveGeminiBaseURL(e.cfg, auth)
 	url := fmt.Sprintf("%s/%s/models/%s:%s", baseURL, glAPIVersion, model, "streamGenerateContent")
 	if opts.Alt == "" {
 		url = url + "?alt=sse"
@@ -350,7 +350,7 @@ func (e *GeminiExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Aut
 	translatedReq, _ = sjson.DeleteBytes(translatedReq, "safetySettings")
 	translatedReq, _ = sjson.SetBytes(translatedReq, "model", model)
 
-	baseURL := resolveGeminiBaseURL(auth)
+	baseURL := resolveGeminiBaseURL(e.cfg, auth)
 	url := fmt.Sprintf("%s/%s/models/%s:%s", baseURL, glAPIVersion, model, "countTokens")
 
 	requestBody := bytes.NewReader(translatedReq)
@@ -437,8 +437,8 @@ func geminiCreds(a *cliproxyauth.Auth) (apiKey, bearer string) {
 	return
 }
 
-func resolveGeminiBaseURL(auth *cliproxyauth.Auth) string {
-	base := glEndpoint
+func resolveGeminiBaseURL(cfg *config.Config, auth *cliproxyauth.Auth) string {
+	base := providerBaseURL(cfg, "gemini", glEndpoint)
 	if auth != nil && auth.Attributes != nil {
 		if custom := strings.TrimSpace(auth.Attributes["base_url"]); custom != "" {
 			base = strings.TrimRight(custom, "/")
@@ -0,0 +1,132 @@
+// Package executor provides runtime execution capabilities for various AI service providers.
+// This file applies the inbound header policy and fixed upstream headers to provider requests.
+package executor
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// protectedHeaders are managed by the executors and the HTTP stack. They are never
+// copied from the client and never stripped by the inbound header policy.
+var protectedHeaders = map[string]struct{}{
+	"Accept":              {},
+	"Accept-Encoding":     {},
+	"Authorization":       {},
+	"Connection":          {},
+	"Content-Length":      {},
+	"Content-Type":        {},
+	"Cookie":              {},
+	"Host":                {},
+	"Keep-Alive":          {},
+	"Proxy-Authorization": {},
+	"Te":                  {},
+	"Trailer":             {},
+	"Transfer-Encoding":   {},
+	"Upgrade":             {},
+	"X-Api-Key":           {},
+	"X-Goog-Api-Key":      {},
+}
+
+// headerPolicyTransport applies the header policy to every request sent through base.
+type headerPolicyTransport struct {
+	base     http.RoundTripper
+	policy   config.InboundHeaderPolicy
+	upstream map[string]string
+}
+
+// RoundTrip applies the header policy to a clone of req and forwards it.
+func (t *headerPolicyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var inbound http.Header
+	if ginCtx, ok := req.Context().Value("gin").(*gin.Context); ok && ginCtx != nil && ginCtx.Request != nil {
+		inbound = ginCtx.Request.Header
+	}
+	req = req.Clone(req.Context())
+	applyHeaderPolicy(req.Header, inbound, t.policy, t.upstream)
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// withHeaderPolicy wraps client so its requests honor inbound-header-policy and upstream-headers.
+// The client is returned unchanged when neither is configured.
+func withHeaderPolicy(client *http.Client, cfg *config.Config) *http.Client {
+	if client == nil || cfg == nil {
+		return client
+	}
+	policy := cfg.InboundHeaderPolicy
+	if len(policy.Allow) == 0 && len(policy.Deny) == 0 && len(cfg.UpstreamHeaders) == 0 {
+		return client
+	}
+	wrapped := *client
+	wrapped.Transport = &headerPolicyTransport{base: client.Transport, policy: policy, upstream: cfg.UpstreamHeaders}
+	return &wrapped
+}
+
+// applyHeaderPolicy rewrites an outgoing header set. A header counts as passed through
+// when the client sent it with the same value; such headers are stripped unless the policy
+// allows them. Allowed client headers the executor did not copy are added, and configured
+// upstream headers are set last.
+func applyHeaderPolicy(out, inbound http.Header, policy config.InboundHeaderPolicy, upstream map[string]string) {
+	for name, values := range out {
+		if _, protected := protectedHeaders[name]; protected || headerAllowed(name, policy) {
+			continue
+		}
+		if in := inbound.Values(name); len(in) > 0 && sameHeaderValues(in, values) {
+			out.Del(name)
+		}
+	}
+	if len(policy.Allow) > 0 {
+		for name, values := range inbound {
+			if _, protected := protectedHeaders[name]; protected || !headerAllowed(name, policy) {
+				continue
+			}
+			if len(out.Values(name)) == 0 {
+				out[name] = append([]string(nil), values...)
+			}
+		}
+	}
+	for name, value := range upstream {
+		out.Set(name, value)
+	}
+}
+
+// headerAllowed reports whether the policy lets a client header through.
+func headerAllowed(name string, policy config.InboundHeaderPolicy) bool {
+	if matchHeaderPattern(name, policy.Deny) {
+		return false
+	}
+	return len(policy.Allow) == 0 || matchHeaderPattern(name, policy.Allow)
+}
+
+func matchHeaderPattern(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if len(name) >= len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) {
+				return true
+			}
+			continue
+		}
+		if strings.EqualFold(name, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameHeaderValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
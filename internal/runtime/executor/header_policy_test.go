@@ -0,0 +1,108 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+// captureClaudeHeaders runs a Claude request carrying the given client headers and returns
+// the headers the upstream received.
+func captureClaudeHeaders(t *testing.T, cfg *config.Config, clientHeaders map[string]string) http.Header {
+	t.Helper()
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+	defer srv.Close()
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	for k, v := range clientHeaders {
+		c.Request.Header.Set(k, v)
+	}
+	ctx := context.WithValue(context.Background(), "gin", c)
+
+	cfg.SanitizeHeaderPolicy()
+	exec := NewClaudeExecutor(cfg)
+	auth := &cliproxyauth.Auth{ID: "claude-auth", Provider: "claude", Attributes: map[string]string{"api_key": "sk-upstream", "base_url": srv.URL}}
+	_, err := exec.Execute(ctx, auth, cliproxyexecutor.Request{
+		Model:   "claude-sonnet-4",
+		Payload: []byte(`{"model":"claude-sonnet-4","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`),
+	}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("claude")})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	return got
+}
+
+func TestHeaderPolicy_DeniedHeadersAreStrippedAndUpstreamHeadersAdded(t *testing.T) {
+	cfg := &config.Config{
+		InboundHeaderPolicy: config.InboundHeaderPolicy{Deny: []string{"x-stainless-*", " User-Agent "}},
+		UpstreamHeaders:     map[string]string{"X-Gateway-Tenant": "acme"},
+	}
+	got := captureClaudeHeaders(t, cfg, map[string]string{
+		"X-Stainless-Os":    "Linux",
+		"User-Agent":        "internal-tool/2.3",
+		"Anthropic-Version": "2023-06-01",
+	})
+
+	if v := got.Get("X-Stainless-Os"); v != "" {
+		t.Errorf("denied X-Stainless-Os forwarded as %q", v)
+	}
+	if v := got.Get("User-Agent"); v == "internal-tool/2.3" {
+		t.Errorf("denied User-Agent forwarded")
+	}
+	if v := got.Get("X-Stainless-Lang"); v != "js" {
+		t.Errorf("executor default X-Stainless-Lang = %q, want js", v)
+	}
+	if v := got.Get("Anthropic-Version"); v != "2023-06-01" {
+		t.Errorf("Anthropic-Version = %q, want passthrough", v)
+	}
+	if v := got.Get("X-Gateway-Tenant"); v != "acme" {
+		t.Errorf("X-Gateway-Tenant = %q, want acme", v)
+	}
+	if v := got.Get("Authorization"); v != "Bearer sk-upstream" {
+		t.Errorf("upstream credential = %q, want Bearer sk-upstream", v)
+	}
+}
+
+func TestHeaderPolicy_AllowlistForwardsOnlyTrustedHeaders(t *testing.T) {
+	cfg := &config.Config{
+		InboundHeaderPolicy: config.InboundHeaderPolicy{Allow: []string{"X-Request-ID", "Anthropic-*", "Authorization"}},
+		UpstreamHeaders:     map[string]string{"User-Agent": "cliproxy-gateway"},
+	}
+	got := captureClaudeHeaders(t, cfg, map[string]string{
+		"X-Request-ID":   "req-123",
+		"X-Stainless-Os": "Linux",
+		"User-Agent":     "internal-tool/2.3",
+		"Authorization":  "Bearer proxy-client-key",
+		"Cookie":         "session=secret",
+	})
+
+	if v := got.Get("X-Request-ID"); v != "req-123" {
+		t.Errorf("X-Request-ID = %q, want req-123", v)
+	}
+	if v := got.Get("X-Stainless-Os"); v != "" {
+		t.Errorf("unlisted X-Stainless-Os forwarded as %q", v)
+	}
+	if v := got.Get("User-Agent"); v != "cliproxy-gateway" {
+		t.Errorf("User-Agent = %q, want the configured upstream header", v)
+	}
+	if v := got.Get("Authorization"); v != "Bearer sk-upstream" {
+		t.Errorf("Authorization = %q, want the upstream credential rather than the client's", v)
+	}
+	if v := got.Get("Cookie"); v != "" {
+		t.Errorf("client Cookie leaked upstream as %q", v)
+	}
+}
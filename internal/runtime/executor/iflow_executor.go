@@ -73,7 +73,7 @@ func (e *IFlowExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, re
 		return resp, err
 	}
 	if baseURL == "" {
-		baseURL = iflowauth.DefaultAPIBaseURL
+		baseURL = providerBaseURL(e.cfg, e.Identifier(), iflowauth.DefaultAPIBaseURL)
 	}
 
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
@@ -167,7 +167,7 @@ func (e *IFlowExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Au
 		return nil, err
 	}
 	if baseURL == "" {
-		baseURL = iflowauth.DefaultAPIBaseURL
+		baseURL = providerBaseURL(e.cfg, e.Identifier(), iflowauth.DefaultAPIBaseURL)
 	}
 
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
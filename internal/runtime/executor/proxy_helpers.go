@@ -26,6 +26,8 @@ import (
 //   - auth: The authentication information
 //   - timeout: The client timeout (0 means no timeout)
 //
+// The returned client also applies inbound-header-policy and upstream-headers.
+//
 // Returns:
 //   - *http.Client: An HTTP client with configured proxy or transport
 func newProxyAwareHTTPClient(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, timeout time.Duration) *http.Client {
@@ -50,19 +52,19 @@ func newProxyAwareHTTPClient(ctx context.Context, cfg *config.Config, auth *clip
 
 	// If we have a proxy URL, use pooled proxy transport
 	if proxyURL != "" {
-		return pool.GetProxyClient(providerKey, proxyURL, timeout)
+		return withHeaderPolicy(pool.GetProxyClient(providerKey, proxyURL, timeout), cfg)
 	}
 
 	// Priority 3: Use RoundTripper from context if available
 	if rt, ok := ctx.Value("cliproxy.roundtripper").(http.RoundTripper); ok && rt != nil {
-		return &http.Client{
+		return withHeaderPolicy(&http.Client{
 			Transport: rt,
 			Timeout:   timeout,
-		}
+		}, cfg)
 	}
 
 	// Use pooled transport for the provider
-	return pool.GetClient(providerKey, timeout)
+	return withHeaderPolicy(pool.GetClient(providerKey, timeout), cfg)
 }
 
 // buildProxyTransport creates an HTTP transport configured for the given proxy URL.
@@ -68,7 +68,7 @@ func (e *QwenExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req
 	token, baseURL := qwenCreds(auth)
 
 	if baseURL == "" {
-		baseURL = "https://portal.qwen.ai/v1"
+		baseURL = providerBaseURL(e.cfg, e.Identifier(), "https://portal.qwen.ai/v1")
 	}
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
 	defer reporter.trackFailure(ctx, &err)
@@ -149,7 +149,7 @@ func (e *QwenExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Aut
 	token, baseURL := qwenCreds(auth)
 
 	if baseURL == "" {
-		baseURL = "https://portal.qwen.ai/v1"
+		baseURL = providerBaseURL(e.cfg, e.Identifier(), "https://portal.qwen.ai/v1")
 	}
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
 	defer reporter.trackFailure(ctx, &err)
@@ -8,6 +8,8 @@ import (
 	"math/rand"
 	"sync"
 	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/scheduler"
 )
 
 // RetryConfig configures exponential backoff retry behavior.
@@ -102,12 +104,20 @@ func IsRetryableError(statusCode int) bool {
 	}
 }
 
+// AllowRetry reports whether the global retry budget permits one more retry, spending it
+// if so. Callers check it last, once a retry is otherwise warranted, and return the error
+// they have when it reports false.
+func AllowRetry() bool {
+	return scheduler.GetRetryBudget().Allow()
+}
+
 // RetryableFunc is a function that can be retried.
 // It should return an error and optionally a status code for retry decisions.
 type RetryableFunc func() (statusCode int, retryAfter *time.Duration, err error)
 
 // ExecuteWithRetry executes a function with exponential backoff retry.
-// It retries the function if it returns a retryable error status code.
+// It retries the function if it returns a retryable error status code and the global
+// retry budget allows it.
 func ExecuteWithRetry(ctx context.Context, cfg RetryConfig, fn RetryableFunc) error {
 	var lastErr error
 
@@ -125,8 +135,8 @@ func ExecuteWithRetry(ctx context.Context, cfg RetryConfig, fn RetryableFunc) er
 			return err
 		}
 
-		// Don't retry if we've exhausted attempts
-		if attempt >= cfg.MaxRetries {
+		// Don't retry if we've exhausted attempts or the global retry budget
+		if attempt >= cfg.MaxRetries || !AllowRetry() {
 			return err
 		}
 
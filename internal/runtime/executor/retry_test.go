@@ -2,8 +2,14 @@ package executor
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/scheduler"
 )
 
 func TestCalculateBackoff_ExponentialGrowth(t *testing.T) {
@@ -152,3 +158,40 @@ func TestIsRetryableError(t *testing.T) {
 		}
 	}
 }
+
+func TestExecuteWithRetry_SkipsRetriesOnceBudgetIsExhausted(t *testing.T) {
+	scheduler.InitRetryBudget(scheduler.RetryBudgetConfig{MaxRetries: 2, Window: time.Hour})
+	t.Cleanup(func() { scheduler.InitRetryBudget(scheduler.RetryBudgetConfig{}) })
+
+	cfg := RetryConfig{InitialDelay: time.Millisecond, MaxDelay: time.Millisecond, Multiplier: 1, MaxRetries: 5}
+	upstreamErr := errors.New("upstream unavailable")
+	run := func() int {
+		calls := 0
+		err := ExecuteWithRetry(context.Background(), cfg, func() (int, *time.Duration, error) {
+			calls++
+			return http.StatusServiceUnavailable, nil, upstreamErr
+		})
+		if !errors.Is(err, upstreamErr) {
+			t.Fatalf("ExecuteWithRetry error = %v, want %v", err, upstreamErr)
+		}
+		return calls
+	}
+
+	// The first request spends the whole budget; later ones fail after a single attempt.
+	if calls := run(); calls != 3 {
+		t.Fatalf("first request made %d attempts, want 3 (1 + 2 budgeted retries)", calls)
+	}
+	for i := 0; i < 3; i++ {
+		if calls := run(); calls != 1 {
+			t.Fatalf("request %d made %d attempts after the budget was exhausted, want 1", i+2, calls)
+		}
+	}
+
+	stats := scheduler.GetRetryBudget().Stats()
+	if stats.Allowed != 2 || stats.Exhausted != 4 || stats.Available != 0 {
+		t.Fatalf("retry budget stats = %+v, want 2 allowed, 4 exhausted, 0 available", stats)
+	}
+	if !strings.Contains(observability.GetMetrics().Export(), "_retry_budget_available 0\n") {
+		t.Fatal("expected /metrics to report an exhausted retry budget")
+	}
+}
@@ -3,13 +3,18 @@
 package executor
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
 )
 
 // StreamFanout manages shared upstream connections for SSE streaming.
@@ -26,14 +31,26 @@ type StreamFanoutConfig struct {
 	Enabled            bool
 	BufferSize         int
 	DedupWindowSeconds int
+	// AllowedEventTypes, when non-empty, limits the typed events broadcast to subscribers
+	// (e.g. "content_block_delta"). Untyped data events always pass unless denied.
+	AllowedEventTypes []string
+	// DeniedEventTypes lists event types never broadcast, e.g. "ping".
+	DeniedEventTypes []string
+	// ResumeWindowSeconds is how long a stream whose originating client disconnected keeps
+	// its upstream running without any subscriber, waiting for the client to reconnect.
+	ResumeWindowSeconds int
 }
 
+// defaultEventType is the type of SSE events that carry no explicit type.
+const defaultEventType = "message"
+
 // DefaultStreamFanoutConfig returns sensible defaults.
 func DefaultStreamFanoutConfig() StreamFanoutConfig {
 	return StreamFanoutConfig{
-		Enabled:            true,
-		BufferSize:         50,
-		DedupWindowSeconds: 5,
+		Enabled:             true,
+		BufferSize:          50,
+		DedupWindowSeconds:  5,
+		ResumeWindowSeconds: 30,
 	}
 }
 
@@ -46,8 +63,13 @@ type SharedStream struct {
 	bufferSize  int
 	done        chan struct{}
 	completed   bool
+	aborted     bool
 	createdAt   time.Time
 	lastEventAt time.Time
+	filter      eventTypeFilter
+	// lastID is the id of the most recently published event. Ids increase by one per
+	// event so a reconnecting client's Last-Event-ID locates its position in the buffer.
+	lastID uint64
 }
 
 // StreamEvent represents a single SSE event in the stream.
@@ -95,6 +117,16 @@ func (sf *StreamFanout) IsEnabled() bool {
 	return sf.config.Enabled
 }
 
+// ResumeWindow returns how long an abandoned stream waits for its client to reconnect.
+func (sf *StreamFanout) ResumeWindow() time.Duration {
+	sf.mu.RLock()
+	defer sf.mu.RUnlock()
+	if sf.config.ResumeWindowSeconds <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(sf.config.ResumeWindowSeconds) * time.Second
+}
+
 // GenerateStreamKey creates a unique key for a request based on its content.
 func GenerateStreamKey(model string, messages []byte, params []byte) string {
 	h := sha256.New()
@@ -131,6 +163,7 @@ func (sf *StreamFanout) GetOrCreateStream(key string) (*SharedStream, bool, chan
 		done:        make(chan struct{}),
 		createdAt:   time.Now(),
 		lastEventAt: time.Now(),
+		filter:      newEventTypeFilter(sf.config.AllowedEventTypes, sf.config.DeniedEventTypes),
 	}
 	sf.streams[key] = stream
 
@@ -139,6 +172,25 @@ func (sf *StreamFanout) GetOrCreateStream(key string) (*SharedStream, bool, chan
 	return stream, true, sub
 }
 
+// ResumeStream subscribes to the stream under key from just after the event with id
+// lastEventID, as sent by a reconnecting client in its Last-Event-ID header. A completed
+// stream still awaiting cleanup can be resumed too; its channel closes after the replay.
+// ok is false when the stream is unknown or SubscribeAfter refuses lastEventID.
+func (sf *StreamFanout) ResumeStream(key, lastEventID string) (*SharedStream, chan StreamEvent, bool) {
+	sf.mu.RLock()
+	stream, exists := sf.streams[key]
+	enabled := sf.config.Enabled
+	sf.mu.RUnlock()
+	if !enabled || !exists {
+		return nil, nil, false
+	}
+	sub, ok := stream.SubscribeAfter(lastEventID)
+	if !ok {
+		return nil, nil, false
+	}
+	return stream, sub, true
+}
+
 // RemoveStream removes a stream from the manager.
 func (sf *StreamFanout) RemoveStream(key string) {
 	sf.mu.Lock()
@@ -215,6 +267,51 @@ func (sf *StreamFanout) GetStats() FanoutStats {
 	return stats
 }
 
+// StreamInfo describes one shared stream for debugging.
+type StreamInfo struct {
+	Key            string    `json:"key"`
+	Subscribers    int       `json:"subscribers"`
+	BufferedEvents int       `json:"buffered_events"`
+	CreatedAt      time.Time `json:"created_at"`
+	LastEventAt    time.Time `json:"last_event_at"`
+	AgeSeconds     float64   `json:"age_seconds"`
+	Completed      bool      `json:"completed"`
+}
+
+// Streams returns per-stream detail for every stream still tracked, including completed
+// streams awaiting cleanup, ordered by creation time.
+func (sf *StreamFanout) Streams() []StreamInfo {
+	sf.mu.RLock()
+	streams := make([]*SharedStream, 0, len(sf.streams))
+	for _, stream := range sf.streams {
+		streams = append(streams, stream)
+	}
+	sf.mu.RUnlock()
+
+	now := time.Now()
+	infos := make([]StreamInfo, 0, len(streams))
+	for _, stream := range streams {
+		stream.mu.RLock()
+		infos = append(infos, StreamInfo{
+			Key:            stream.key,
+			Subscribers:    len(stream.subscribers),
+			BufferedEvents: len(stream.events),
+			CreatedAt:      stream.createdAt,
+			LastEventAt:    stream.lastEventAt,
+			AgeSeconds:     now.Sub(stream.createdAt).Seconds(),
+			Completed:      stream.completed,
+		})
+		stream.mu.RUnlock()
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].CreatedAt.Equal(infos[j].CreatedAt) {
+			return infos[i].Key < infos[j].Key
+		}
+		return infos[i].CreatedAt.Before(infos[j].CreatedAt)
+	})
+	return infos
+}
+
 // Subscribe adds a new subscriber to the stream and returns a channel for events.
 func (s *SharedStream) Subscribe() chan StreamEvent {
 	s.mu.Lock()
@@ -224,15 +321,56 @@ func (s *SharedStream) Subscribe() chan StreamEvent {
 	s.subscribers[ch] = struct{}{}
 
 	// Replay buffered events to late joiner
-	for _, event := range s.events {
+	s.replay(ch, s.events)
+
+	return ch
+}
+
+// SubscribeAfter is Subscribe for a reconnecting client: only buffered events published
+// after the event with id lastEventID are replayed. On a completed stream the returned
+// channel holds the remaining events and is already closed. It reports false when
+// lastEventID was not issued by this stream, events following it are no longer buffered,
+// or the stream was aborted.
+func (s *SharedStream) SubscribeAfter(lastEventID string) (chan StreamEvent, bool) {
+	last, err := strconv.ParseUint(strings.TrimSpace(lastEventID), 10, 64)
+	if err != nil {
+		return nil, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.aborted || last > s.lastID {
+		return nil, false
+	}
+	// Buffered events carry the contiguous ids s.lastID-len(s.events)+1 through s.lastID.
+	first := s.lastID - uint64(len(s.events)) + 1
+	if last+1 < first {
+		return nil, false
+	}
+	missed := s.events[last+1-first:]
+
+	if s.completed {
+		ch := make(chan StreamEvent, len(missed))
+		s.replay(ch, missed)
+		close(ch)
+		return ch, true
+	}
+	ch := make(chan StreamEvent, 100)
+	s.subscribers[ch] = struct{}{}
+	s.replay(ch, missed)
+	return ch, true
+}
+
+// replay queues buffered events on a new subscriber channel. Callers hold s.mu.
+func (s *SharedStream) replay(ch chan StreamEvent, events []StreamEvent) {
+	for _, event := range events {
 		select {
 		case ch <- event:
 		default:
 			// Channel full, skip old events
 		}
 	}
-
-	return ch
 }
 
 // Unsubscribe removes a subscriber from the stream.
@@ -246,15 +384,22 @@ func (s *SharedStream) Unsubscribe(ch chan StreamEvent) {
 	}
 }
 
-// Publish sends an event to all subscribers and buffers it for late joiners.
-func (s *SharedStream) Publish(event StreamEvent) {
+// Publish sends an event to all subscribers and buffers it for late joiners, and returns
+// the id assigned to it. Events whose type is filtered out by the fan-out configuration
+// are dropped and get no id.
+func (s *SharedStream) Publish(event StreamEvent) string {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if s.completed {
-		return
+		return ""
+	}
+	if !s.filter.allows(event.EventType) {
+		return ""
 	}
 
+	s.lastID++
+	event.ID = strconv.FormatUint(s.lastID, 10)
 	event.Timestamp = time.Now()
 	s.lastEventAt = event.Timestamp
 
@@ -274,16 +419,81 @@ func (s *SharedStream) Publish(event StreamEvent) {
 			log.Debugf("stream fanout: dropping event for slow subscriber on stream %s", s.key)
 		}
 	}
+	return event.ID
 }
 
-// PublishBytes is a convenience method to publish raw bytes as a data event.
-func (s *SharedStream) PublishBytes(data []byte) {
-	s.Publish(StreamEvent{
+// PublishBytes is a convenience method to publish a raw SSE chunk, typed by SSEEventType.
+func (s *SharedStream) PublishBytes(data []byte) string {
+	return s.Publish(StreamEvent{
 		Data:      data,
-		EventType: "message",
+		EventType: SSEEventType(data),
 	})
 }
 
+// SSEEventType returns the type of a raw SSE chunk: the value of its "event:" line, else
+// the "type" field of its JSON data (as Claude and the Responses API send), else "message".
+func SSEEventType(data []byte) string {
+	var payload []byte
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if value, ok := bytes.CutPrefix(line, []byte("event:")); ok {
+			if eventType := strings.TrimSpace(string(value)); eventType != "" {
+				return eventType
+			}
+			continue
+		}
+		if value, ok := bytes.CutPrefix(line, []byte("data:")); ok && payload == nil {
+			payload = bytes.TrimSpace(value)
+		}
+	}
+	if payload == nil {
+		payload = bytes.TrimSpace(data)
+	}
+	if eventType := gjson.GetBytes(payload, "type"); eventType.Type == gjson.String && eventType.Str != "" {
+		return eventType.Str
+	}
+	return defaultEventType
+}
+
+// eventTypeFilter decides which event types a shared stream broadcasts.
+type eventTypeFilter struct {
+	allowed map[string]struct{}
+	denied  map[string]struct{}
+}
+
+func newEventTypeFilter(allowed, denied []string) eventTypeFilter {
+	return eventTypeFilter{allowed: eventTypeSet(allowed), denied: eventTypeSet(denied)}
+}
+
+func eventTypeSet(types []string) map[string]struct{} {
+	if len(types) == 0 {
+		return nil
+	}
+	set := make(map[string]struct{}, len(types))
+	for _, t := range types {
+		if t = strings.TrimSpace(t); t != "" {
+			set[t] = struct{}{}
+		}
+	}
+	return set
+}
+
+// allows reports whether eventType is broadcast. Denied types are always dropped; untyped
+// data events and, without an allowlist, every other type pass.
+func (f eventTypeFilter) allows(eventType string) bool {
+	if eventType == "" {
+		eventType = defaultEventType
+	}
+	if _, denied := f.denied[eventType]; denied {
+		return false
+	}
+	if eventType == defaultEventType || len(f.allowed) == 0 {
+		return true
+	}
+	_, ok := f.allowed[eventType]
+	return ok
+}
+
 // Complete marks the stream as completed and notifies all subscribers.
 func (s *SharedStream) Complete() {
 	s.mu.Lock()
@@ -305,6 +515,15 @@ func (s *SharedStream) Complete() {
 	log.Debugf("stream fanout: completed stream %s", s.key)
 }
 
+// Abort completes a stream whose upstream was cut off before it finished. An aborted
+// stream's buffer is not a whole response, so it can no longer be resumed.
+func (s *SharedStream) Abort() {
+	s.mu.Lock()
+	s.aborted = true
+	s.mu.Unlock()
+	s.Complete()
+}
+
 // IsCompleted returns whether the stream has finished.
 func (s *SharedStream) IsCompleted() bool {
 	s.mu.RLock()
@@ -0,0 +1,153 @@
+package executor
+
+import (
+	"testing"
+)
+
+func collectFanoutEvents(t *testing.T, cfg StreamFanoutConfig, chunks ...string) []StreamEvent {
+	t.Helper()
+	cfg.Enabled = true
+	cfg.BufferSize = 10
+	sf := &StreamFanout{streams: make(map[string]*SharedStream), config: cfg}
+
+	stream, isNew, sub := sf.GetOrCreateStream("filter-test")
+	if !isNew || stream == nil {
+		t.Fatal("expected a new stream")
+	}
+	for _, chunk := range chunks {
+		stream.PublishBytes([]byte(chunk))
+	}
+	stream.Complete()
+
+	var events []StreamEvent
+	for event := range sub {
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestSharedStream_DeniedEventTypesAreNotBroadcast(t *testing.T) {
+	events := collectFanoutEvents(t, StreamFanoutConfig{DeniedEventTypes: []string{"ping"}},
+		"event: ping\ndata: {\"type\": \"ping\"}\n\n",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"Hi\"}}\n\n",
+		`data: {"type":"ping"}`,
+		`data: {"choices":[{"delta":{"content":"there"}}]}`,
+	)
+
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2: %+v", len(events), events)
+	}
+	if events[0].EventType != "content_block_delta" || events[1].EventType != "message" {
+		t.Fatalf("unexpected event types %q, %q", events[0].EventType, events[1].EventType)
+	}
+}
+
+func TestSharedStream_AllowlistKeepsDataEvents(t *testing.T) {
+	events := collectFanoutEvents(t, StreamFanoutConfig{AllowedEventTypes: []string{"content_block_delta"}},
+		"event: ping\ndata: {\"type\": \"ping\"}\n\n",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\"}\n\n",
+		`data: {"choices":[{"delta":{"content":"hi"}}]}`,
+		"data: [DONE]\n\n",
+	)
+
+	var types []string
+	for _, event := range events {
+		types = append(types, event.EventType)
+	}
+	if len(types) != 3 || types[0] != "content_block_delta" || types[1] != "message" || types[2] != "message" {
+		t.Fatalf("broadcast types = %v, want content_block_delta and two data events", types)
+	}
+}
+
+func TestSSEEventType(t *testing.T) {
+	cases := map[string]string{
+		"event: ping\ndata: {}":                            "ping",
+		`data: {"type":"response.output_text.delta"}`:      "response.output_text.delta",
+		`{"type":"message_stop"}`:                          "message_stop",
+		`data: {"candidates":[{"finishReason":"SAFETY"}]}`: "message",
+		"data: [DONE]":                                     "message",
+	}
+	for chunk, want := range cases {
+		if got := SSEEventType([]byte(chunk)); got != want {
+			t.Errorf("SSEEventType(%q) = %q, want %q", chunk, got, want)
+		}
+	}
+}
+
+func TestSharedStream_SubscribeAfterReplaysOnlyLaterEvents(t *testing.T) {
+	sf := &StreamFanout{streams: make(map[string]*SharedStream), config: StreamFanoutConfig{Enabled: true, BufferSize: 10}}
+	stream, _, first := sf.GetOrCreateStream("resume-test")
+	for _, chunk := range []string{"a", "b", "c", "d"} {
+		stream.PublishBytes([]byte("data: " + chunk))
+	}
+
+	var firstIDs []string
+	for i := 0; i < 2; i++ {
+		firstIDs = append(firstIDs, (<-first).ID)
+	}
+	if firstIDs[0] != "1" || firstIDs[1] != "2" {
+		t.Fatalf("first event ids = %v, want [1 2]", firstIDs)
+	}
+
+	// The client drops after event 2 and reconnects with Last-Event-ID: 2.
+	_, resumed, ok := sf.ResumeStream("resume-test", "2")
+	if !ok {
+		t.Fatal("expected the stream to be resumable from event 2")
+	}
+	if id := stream.PublishBytes([]byte("data: e")); id != "5" {
+		t.Fatalf("live event id = %q, want 5", id)
+	}
+	stream.Complete()
+
+	var got []string
+	for event := range resumed {
+		got = append(got, event.ID+"="+string(event.Data))
+	}
+	want := []string{"3=data: c", "4=data: d", "5=data: e"}
+	if len(got) != len(want) {
+		t.Fatalf("resumed events = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("resumed events = %v, want %v", got, want)
+		}
+	}
+
+	// A completed stream still replays what follows the id, then closes.
+	_, done, ok := sf.ResumeStream("resume-test", "4")
+	if !ok {
+		t.Fatal("expected a completed stream to be resumable")
+	}
+	if event := <-done; event.ID != "5" {
+		t.Fatalf("replayed event id = %q, want 5", event.ID)
+	}
+	if _, open := <-done; open {
+		t.Fatal("expected the replay channel of a completed stream to be closed")
+	}
+}
+
+func TestSharedStream_SubscribeAfterRefusesUnresumableIDs(t *testing.T) {
+	sf := &StreamFanout{streams: make(map[string]*SharedStream), config: StreamFanoutConfig{Enabled: true, BufferSize: 3}}
+	stream, _, _ := sf.GetOrCreateStream("resume-gap")
+	for i := 0; i < 5; i++ {
+		stream.PublishBytes([]byte("data: x"))
+	}
+
+	// Events 3-5 are buffered: resuming after 1 would skip the dropped event 2.
+	for _, id := range []string{"1", "6", "not-a-number"} {
+		if _, _, ok := sf.ResumeStream("resume-gap", id); ok {
+			t.Errorf("ResumeStream(%q) succeeded, want refusal", id)
+		}
+	}
+	if _, _, ok := sf.ResumeStream("resume-gap", "2"); !ok {
+		t.Error("expected resuming right before the buffer to succeed")
+	}
+	if _, _, ok := sf.ResumeStream("unknown", "1"); ok {
+		t.Error("expected an unknown stream to be refused")
+	}
+
+	stream.Abort()
+	if _, _, ok := sf.ResumeStream("resume-gap", "4"); ok {
+		t.Error("expected an aborted stream to be refused")
+	}
+}
@@ -0,0 +1,156 @@
+// Package scheduler provides fair scheduling and weighted queuing for API requests.
+// This file implements the process-wide admission controller for upstream calls.
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
+)
+
+// ErrOverloaded is returned when the proxy is saturated and a call could not be admitted
+// within the configured wait.
+var ErrOverloaded = &SchedulerError{Message: "proxy is at its global concurrency limit"}
+
+// AdmissionConfig configures the global admission controller.
+type AdmissionConfig struct {
+	// MaxInFlight caps upstream calls running at once across all keys; <= 0 disables the cap.
+	MaxInFlight int
+	// MaxWait is how long a call queues for a free slot before being shed; 0 sheds immediately.
+	MaxWait time.Duration
+}
+
+// AdmissionController bounds the number of upstream calls in flight across the whole
+// process. It holds a bucket of MaxInFlight tokens: a call takes a token to start and
+// returns it when done, waiting up to MaxWait for one when the bucket is empty. Unlike
+// the per-key fair queues it protects the proxy itself, whatever mix of keys is calling.
+type AdmissionController struct {
+	tokens  chan struct{} // nil when unlimited
+	maxWait time.Duration
+
+	inFlight atomic.Int64
+	admitted atomic.Uint64
+	queued   atomic.Uint64
+	shed     atomic.Uint64
+}
+
+// NewAdmissionController creates an admission controller from cfg.
+func NewAdmissionController(cfg AdmissionConfig) *AdmissionController {
+	ac := &AdmissionController{maxWait: cfg.MaxWait}
+	if cfg.MaxInFlight > 0 {
+		ac.tokens = make(chan struct{}, cfg.MaxInFlight)
+	}
+	return ac
+}
+
+// Acquire admits one upstream call, waiting up to MaxWait for capacity. On success the
+// returned release must be called exactly once when the call finishes. It returns
+// ErrOverloaded when no slot freed up in time, or the context error if ctx ends first.
+func (ac *AdmissionController) Acquire(ctx context.Context) (release func(), err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if ac.tokens != nil {
+		select {
+		case ac.tokens <- struct{}{}:
+		default:
+			if err := ac.wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+	}
+	ac.admitted.Add(1)
+	ac.publish(ac.inFlight.Add(1))
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ac.publish(ac.inFlight.Add(-1))
+			if ac.tokens != nil {
+				<-ac.tokens
+			}
+		})
+	}, nil
+}
+
+// wait blocks for a token after the fast path found the bucket empty.
+func (ac *AdmissionController) wait(ctx context.Context) error {
+	if ac.maxWait <= 0 {
+		ac.recordShed()
+		return ErrOverloaded
+	}
+	ac.queued.Add(1)
+	timer := time.NewTimer(ac.maxWait)
+	defer timer.Stop()
+	select {
+	case ac.tokens <- struct{}{}:
+		return nil
+	case <-timer.C:
+		ac.recordShed()
+		return ErrOverloaded
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (ac *AdmissionController) recordShed() {
+	ac.shed.Add(1)
+	observability.GetMetricsRecorder().RecordAdmissionShed()
+}
+
+func (ac *AdmissionController) publish(inFlight int64) {
+	observability.GetMetricsRecorder().SetGlobalInFlight(inFlight)
+}
+
+// InFlight returns the number of admitted calls that have not been released.
+func (ac *AdmissionController) InFlight() int64 {
+	return ac.inFlight.Load()
+}
+
+// Stats returns admission statistics.
+func (ac *AdmissionController) Stats() AdmissionStats {
+	return AdmissionStats{
+		MaxInFlight: cap(ac.tokens),
+		InFlight:    ac.inFlight.Load(),
+		Admitted:    ac.admitted.Load(),
+		Queued:      ac.queued.Load(),
+		Shed:        ac.shed.Load(),
+	}
+}
+
+// AdmissionStats holds admission controller statistics. MaxInFlight is 0 when unlimited.
+type AdmissionStats struct {
+	MaxInFlight int    `json:"max_in_flight"`
+	InFlight    int64  `json:"in_flight"`
+	Admitted    uint64 `json:"admitted"`
+	Queued      uint64 `json:"queued"`
+	Shed        uint64 `json:"shed"`
+}
+
+// Global admission controller instance
+var (
+	globalAdmission   = NewAdmissionController(AdmissionConfig{})
+	globalAdmissionMu sync.RWMutex
+)
+
+// GetAdmissionController returns the global admission controller. It is unlimited until
+// InitAdmissionController installs a configured one.
+func GetAdmissionController() *AdmissionController {
+	globalAdmissionMu.RLock()
+	defer globalAdmissionMu.RUnlock()
+	return globalAdmission
+}
+
+// InitAdmissionController replaces the global admission controller. Calls admitted by the
+// previous controller release their slots there, so a replacement briefly allows up to
+// both limits combined while those calls drain.
+func InitAdmissionController(cfg AdmissionConfig) *AdmissionController {
+	ac := NewAdmissionController(cfg)
+	globalAdmissionMu.Lock()
+	defer globalAdmissionMu.Unlock()
+	globalAdmission = ac
+	return ac
+}
@@ -0,0 +1,117 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
+)
+
+func TestAdmissionController_ShedsWhenSaturatedAndRecovers(t *testing.T) {
+	ac := NewAdmissionController(AdmissionConfig{MaxInFlight: 2})
+
+	var releases []func()
+	for i := 0; i < 2; i++ {
+		release, err := ac.Acquire(context.Background())
+		if err != nil {
+			t.Fatalf("acquire %d: %v", i, err)
+		}
+		releases = append(releases, release)
+	}
+	if got := ac.InFlight(); got != 2 {
+		t.Fatalf("in flight = %d, want 2", got)
+	}
+	if !strings.Contains(observability.GetMetrics().Export(), "_global_inflight 2\n") {
+		t.Fatal("expected /metrics to report 2 global in-flight calls")
+	}
+
+	if _, err := ac.Acquire(context.Background()); !errors.Is(err, ErrOverloaded) {
+		t.Fatalf("expected ErrOverloaded at saturation, got %v", err)
+	}
+
+	releases[0]()
+	releases[0]() // a second release must not free another slot
+	release, err := ac.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("expected admission after a slot was released, got %v", err)
+	}
+	if _, err := ac.Acquire(context.Background()); !errors.Is(err, ErrOverloaded) {
+		t.Fatalf("expected double release to leave the controller saturated, got %v", err)
+	}
+	release()
+	releases[1]()
+
+	stats := ac.Stats()
+	if stats.InFlight != 0 || stats.MaxInFlight != 2 || stats.Admitted != 3 || stats.Shed != 2 {
+		t.Fatalf("unexpected stats after recovery: %+v", stats)
+	}
+}
+
+func TestAdmissionController_QueuesUntilSlotFrees(t *testing.T) {
+	ac := NewAdmissionController(AdmissionConfig{MaxInFlight: 1, MaxWait: time.Second})
+	release, err := ac.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admitted := make(chan error, 1)
+	go func() {
+		next, err := ac.Acquire(context.Background())
+		if err == nil {
+			next()
+		}
+		admitted <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	release()
+
+	select {
+	case err := <-admitted:
+		if err != nil {
+			t.Fatalf("queued call should be admitted once a slot frees, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queued call was never admitted")
+	}
+	if stats := ac.Stats(); stats.Queued != 1 || stats.Shed != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestAdmissionController_ShedsAfterMaxWait(t *testing.T) {
+	ac := NewAdmissionController(AdmissionConfig{MaxInFlight: 1, MaxWait: 20 * time.Millisecond})
+	release, err := ac.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	start := time.Now()
+	if _, err := ac.Acquire(context.Background()); !errors.Is(err, ErrOverloaded) {
+		t.Fatalf("expected ErrOverloaded after waiting, got %v", err)
+	}
+	if waited := time.Since(start); waited < 20*time.Millisecond {
+		t.Fatalf("expected the call to queue for MaxWait, waited %v", waited)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ac.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context error for a cancelled caller, got %v", err)
+	}
+}
+
+func TestAdmissionController_UnlimitedByDefault(t *testing.T) {
+	ac := NewAdmissionController(AdmissionConfig{})
+	for i := 0; i < 100; i++ {
+		if _, err := ac.Acquire(context.Background()); err != nil {
+			t.Fatalf("unlimited controller rejected call %d: %v", i, err)
+		}
+	}
+	if stats := ac.Stats(); stats.MaxInFlight != 0 || stats.InFlight != 100 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
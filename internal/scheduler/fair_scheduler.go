@@ -4,9 +4,15 @@ package scheduler
 import (
 	"container/heap"
 	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	contextmgr "github.com/router-for-me/CLIProxyAPI/v6/internal/context"
 )
 
 // FairScheduler implements weighted fair queuing for API requests.
@@ -19,13 +25,54 @@ type FairScheduler struct {
 	defaultWeight int
 	maxQueueSize  int
 	maxConcurrent int
+	queueTimeout  time.Duration
 	metrics       *SchedulerMetrics
 
+	// slots holds one token per executing callback, capping them at maxConcurrent. It is
+	// nil when concurrency is unlimited.
+	slots     chan struct{}
+	executing atomic.Int64
+
+	// estimator derives a request's token cost from its body when the caller gives none.
+	estimator contextmgr.TokenEstimator
+	// shortestJobFirst orders each key's queue by estimated tokens instead of arrival.
+	shortestJobFirst bool
+
+	// mode selects how NextRequest picks the next queue.
+	mode SchedulingMode
+
 	// Virtual time for fair scheduling
 	virtualTime atomic.Int64
 
-	stopCh chan struct{}
-	wg     sync.WaitGroup
+	// Deficit round robin state: the keys with pending requests in service order, the
+	// position of the queue whose turn it is, and whether that turn has been credited.
+	drrQuantum  int64
+	drrRound    []*requestQueue
+	drrCursor   int
+	drrCredited bool
+
+	// limits holds the per-key rate limits. recheckAt is when a worker is due to be woken
+	// because a rate-limited key's budget refills; zero when none is pending.
+	limits    map[string]*keyLimiter
+	recheckAt time.Time
+
+	// metricsInterval is how often the reporter started by Start publishes metrics.
+	metricsInterval time.Duration
+
+	// workCh wakes one idle worker when work may be waiting. It holds at most one
+	// pending signal, so a wakeup sent while every worker is busy is not lost.
+	workCh   chan struct{}
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	// workers counts the worker loops currently running.
+	workers atomic.Int32
+
+	// draining rejects new requests; drained is closed once the queues are empty while
+	// draining. Both are guarded by mu.
+	draining bool
+	drained  chan struct{}
 }
 
 // requestQueue holds pending requests for a single API key.
@@ -35,6 +82,11 @@ type requestQueue struct {
 	virtualTime int64
 	requests    []*scheduledRequest
 	totalTokens int64
+
+	// deficit is the token allowance left in deficit round robin mode; it goes negative
+	// when measured usage exceeds what the queue was credited.
+	deficit int64
+	inRound bool
 }
 
 // scheduledRequest represents a queued request.
@@ -43,10 +95,36 @@ type scheduledRequest struct {
 	priority   int
 	tokens     int64 // estimated tokens for this request
 	enqueuedAt time.Time
-	callback   func() error
+	callback   func() (int64, error) // returns measured tokens, or 0 if unknown
 	done       chan error
 }
 
+// SchedulingMode selects how the scheduler shares capacity between API keys.
+type SchedulingMode string
+
+const (
+	// ModeWFQ is weighted fair queuing on virtual finish times computed from each request's
+	// estimated tokens. This is the default.
+	ModeWFQ SchedulingMode = "wfq"
+	// ModeDRR is deficit round robin: each key is credited a quantum proportional to its
+	// weight per round and charged the tokens its requests actually used, so poor estimates
+	// only delay fairness rather than skew it.
+	ModeDRR SchedulingMode = "drr"
+
+	// defaultDRRQuantum is the per-round credit of a key with the default weight.
+	defaultDRRQuantum = 1000
+)
+
+// ParseSchedulingMode maps a config value to a SchedulingMode, defaulting to ModeWFQ.
+func ParseSchedulingMode(value string) SchedulingMode {
+	switch mode := SchedulingMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case ModeDRR:
+		return mode
+	default:
+		return ModeWFQ
+	}
+}
+
 // SchedulerConfig configures the fair scheduler.
 type SchedulerConfig struct {
 	// DefaultWeight is the default weight for API keys without explicit config
@@ -55,8 +133,22 @@ type SchedulerConfig struct {
 	MaxQueueSize int
 	// MaxConcurrent is the maximum number of concurrent requests
 	MaxConcurrent int
-	// QueueTimeout is the maximum time a request can wait in queue
+	// QueueTimeout is the maximum time a request can wait in queue (0 = no limit)
 	QueueTimeout time.Duration
+	// ShortestJobFirst serves smaller requests first within a single key's queue
+	ShortestJobFirst bool
+	// Estimator estimates request tokens from the body; defaults to a rough heuristic
+	Estimator contextmgr.TokenEstimator
+	// Mode selects weighted fair queuing or deficit round robin (default: WFQ)
+	Mode SchedulingMode
+	// DRRQuantum is the tokens credited per round to a key with DefaultWeight in DRR mode;
+	// other keys are credited in proportion to their weight (default: 1000)
+	DRRQuantum int64
+	// RateLimits are hard per-key request and token rates, applied on top of fair sharing
+	RateLimits []KeyRateLimit
+	// MetricsInterval is how often Start's reporter publishes queue depths and wait times
+	// to the metrics collector (default: 5s)
+	MetricsInterval time.Duration
 }
 
 // DefaultSchedulerConfig returns sensible defaults.
@@ -77,6 +169,15 @@ func NewFairScheduler(cfg SchedulerConfig) *FairScheduler {
 	if cfg.MaxQueueSize <= 0 {
 		cfg.MaxQueueSize = 1000
 	}
+	if cfg.Estimator == nil {
+		cfg.Estimator = contextmgr.RoughEstimator{}
+	}
+	if cfg.DRRQuantum <= 0 {
+		cfg.DRRQuantum = defaultDRRQuantum
+	}
+	if cfg.MetricsInterval <= 0 {
+		cfg.MetricsInterval = defaultMetricsInterval
+	}
 
 	fs := &FairScheduler{
 		queues:        make(map[string]*requestQueue),
@@ -84,8 +185,29 @@ func NewFairScheduler(cfg SchedulerConfig) *FairScheduler {
 		defaultWeight: cfg.DefaultWeight,
 		maxQueueSize:  cfg.MaxQueueSize,
 		maxConcurrent: cfg.MaxConcurrent,
+		queueTimeout:  cfg.QueueTimeout,
 		metrics:       NewSchedulerMetrics(),
+		workCh:        make(chan struct{}, 1),
 		stopCh:        make(chan struct{}),
+
+		estimator:        cfg.Estimator,
+		shortestJobFirst: cfg.ShortestJobFirst,
+		mode:             ParseSchedulingMode(string(cfg.Mode)),
+		drrQuantum:       cfg.DRRQuantum,
+		metricsInterval:  cfg.MetricsInterval,
+	}
+	if cfg.MaxConcurrent > 0 {
+		fs.slots = make(chan struct{}, cfg.MaxConcurrent)
+	}
+	now := time.Now()
+	for _, limit := range cfg.RateLimits {
+		if limit.APIKey == "" || (limit.RequestsPerMinute <= 0 && limit.TokensPerMinute <= 0) {
+			continue
+		}
+		if fs.limits == nil {
+			fs.limits = make(map[string]*keyLimiter)
+		}
+		fs.limits[limit.APIKey] = newKeyLimiter(limit, now)
 	}
 
 	return fs
@@ -121,8 +243,60 @@ func (fs *FairScheduler) GetWeight(apiKey string) int {
 // Schedule queues a request for execution with fair scheduling.
 // Returns an error if the queue is full or the context is cancelled.
 func (fs *FairScheduler) Schedule(ctx context.Context, apiKey string, estimatedTokens int64, callback func() error) error {
+	return fs.ScheduleMeasured(ctx, apiKey, estimatedTokens, func() (int64, error) {
+		return 0, callback()
+	})
+}
+
+// ScheduleMeasured queues a request like Schedule. callback reports the tokens the request
+// actually used, which in DRR mode replace the estimate charged to the key; 0 keeps the
+// estimate.
+//
+// A request that leaves the queue without running returns ErrClientCanceled when ctx is
+// canceled, ErrQueueTimeout when ctx's deadline or the scheduler's QueueTimeout passes, or
+// ErrSchedulerShutdown when the scheduler is stopped.
+func (fs *FairScheduler) ScheduleMeasured(ctx context.Context, apiKey string, estimatedTokens int64, callback func() (int64, error)) error {
+	return fs.ScheduleWithOptions(ctx, apiKey, ScheduleOptions{EstimatedTokens: estimatedTokens}, callback)
+}
+
+// Request priorities. Any int works; these name the common classes.
+const (
+	PriorityBatch       = -1
+	PriorityNormal      = 0
+	PriorityInteractive = 1
+)
+
+// ScheduleOptions describes a request passed to ScheduleWithOptions.
+type ScheduleOptions struct {
+	// EstimatedTokens is the request's expected token cost.
+	EstimatedTokens int64
+
+	// Priority orders requests within their API key's queue: higher runs first, and equal
+	// priorities keep arrival order (or shortest-job-first order when enabled). Priority
+	// does not affect fairness across keys. Virtual time and DRR deficits are charged per
+	// key for whichever request the key runs next, so a key's high-priority requests spend
+	// its own share rather than taking capacity from other keys.
+	Priority int
+}
+
+// ScheduleWithOptions queues a request like ScheduleMeasured, with its cost and priority
+// given by opts.
+func (fs *FairScheduler) ScheduleWithOptions(ctx context.Context, apiKey string, opts ScheduleOptions, callback func() (int64, error)) error {
+	estimatedTokens := opts.EstimatedTokens
 	fs.mu.Lock()
 
+	if fs.draining {
+		fs.mu.Unlock()
+		fs.metrics.RecordRejection(apiKey)
+		return ErrSchedulerDraining
+	}
+	select {
+	case <-fs.stopCh:
+		fs.mu.Unlock()
+		return ErrSchedulerShutdown
+	default:
+	}
+
 	q, exists := fs.queues[apiKey]
 	if !exists {
 		weight := fs.defaultWeight
@@ -145,54 +319,149 @@ func (fs *FairScheduler) Schedule(ctx context.Context, apiKey string, estimatedT
 
 	req := &scheduledRequest{
 		ctx:        ctx,
+		priority:   opts.Priority,
 		tokens:     estimatedTokens,
 		enqueuedAt: time.Now(),
 		callback:   callback,
 		done:       make(chan error, 1),
 	}
 
-	q.requests = append(q.requests, req)
+	fs.enqueue(q, req)
 	q.totalTokens += estimatedTokens
+	if fs.mode == ModeDRR && !q.inRound {
+		q.inRound = true
+		fs.drrRound = append(fs.drrRound, q)
+	}
 	fs.metrics.RecordEnqueue(apiKey)
 
 	fs.mu.Unlock()
+	fs.signalWork()
+
+	var timeout <-chan time.Time
+	if fs.queueTimeout > 0 {
+		timer := time.NewTimer(fs.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
 
 	// Wait for execution
+	var reason error
 	select {
 	case err := <-req.done:
 		return err
 	case <-ctx.Done():
-		fs.removeRequest(apiKey, req)
-		return ctx.Err()
+		reason = cancellationError(ctx.Err())
+	case <-timeout:
+		reason = ErrQueueTimeout
+	case <-fs.stopCh:
+		reason = ErrSchedulerShutdown
+	}
+	if !fs.removeRequest(apiKey, req, reason) {
+		// A worker dequeued the request first; its result is already on the way.
+		return <-req.done
+	}
+	return reason
+}
+
+// cancellationError maps a context error to the scheduler's error for it.
+func cancellationError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrQueueTimeout
 	}
+	return ErrClientCanceled
 }
 
-// removeRequest removes a cancelled request from the queue.
-func (fs *FairScheduler) removeRequest(apiKey string, req *scheduledRequest) {
+// ScheduleRequest queues a request like Schedule, estimating its token cost
+// from body when estimatedTokens is not positive.
+func (fs *FairScheduler) ScheduleRequest(ctx context.Context, apiKey string, body []byte, estimatedTokens int64, callback func() error) error {
+	if estimatedTokens <= 0 {
+		estimatedTokens = fs.EstimateTokens(body)
+	}
+	return fs.Schedule(ctx, apiKey, estimatedTokens, callback)
+}
+
+// ScheduleRequestMeasured is ScheduleRequest for a callback that reports measured tokens.
+func (fs *FairScheduler) ScheduleRequestMeasured(ctx context.Context, apiKey string, body []byte, estimatedTokens int64, callback func() (int64, error)) error {
+	if estimatedTokens <= 0 {
+		estimatedTokens = fs.EstimateTokens(body)
+	}
+	return fs.ScheduleMeasured(ctx, apiKey, estimatedTokens, callback)
+}
+
+// EstimateTokens returns the scheduler's token estimate for a request body.
+func (fs *FairScheduler) EstimateTokens(body []byte) int64 {
+	if len(body) == 0 {
+		return 0
+	}
+	return fs.estimator.EstimateTokens(body)
+}
+
+// enqueue adds req to q, keeping the queue ordered by priority and then, when
+// shortest-job-first is enabled, by estimated tokens. Otherwise equal requests keep
+// arrival order.
+func (fs *FairScheduler) enqueue(q *requestQueue, req *scheduledRequest) {
+	pos := sort.Search(len(q.requests), func(i int) bool {
+		r := q.requests[i]
+		if r.priority != req.priority {
+			return r.priority < req.priority
+		}
+		return fs.shortestJobFirst && r.tokens > req.tokens
+	})
+	q.requests = append(q.requests, nil)
+	copy(q.requests[pos+1:], q.requests[pos:])
+	q.requests[pos] = req
+}
+
+// removeRequest removes a request abandoned for reason from the queue. It reports false
+// when the request was no longer queued.
+func (fs *FairScheduler) removeRequest(apiKey string, req *scheduledRequest, reason error) bool {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
 	q, exists := fs.queues[apiKey]
 	if !exists {
-		return
+		return false
 	}
 
 	for i, r := range q.requests {
 		if r == req {
 			q.requests = append(q.requests[:i], q.requests[i+1:]...)
 			q.totalTokens -= req.tokens
-			fs.metrics.RecordCancellation(apiKey)
-			break
+			fs.recordAbandoned(apiKey, reason)
+			fs.checkDrained()
+			return true
 		}
 	}
+	return false
 }
 
 // NextRequest returns the next request to execute based on fair scheduling.
-// Uses weighted fair queuing where virtual time advances slower for higher-weight keys.
+// Uses weighted fair queuing where virtual time advances slower for higher-weight keys,
+// or deficit round robin in ModeDRR.
+//
+// Keys over their rate limit are skipped in favor of the next eligible queue, and a worker
+// is woken again once the earliest of their budgets refills. A skipped key is not owed
+// the time it spent limited: it resumes its fair share rather than catching up.
 func (fs *FairScheduler) NextRequest() (*scheduledRequest, string, bool) {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	now := time.Now()
+	var limitedFor time.Duration
+	defer func() {
+		if limitedFor > 0 {
+			fs.scheduleRecheck(now, limitedFor)
+		}
+	}()
+
+	if fs.mode == ModeDRR {
+		req, apiKey, ok := fs.nextDRR(now, &limitedFor)
+		if ok {
+			limitedFor = 0
+		}
+		return req, apiKey, ok
+	}
+
 	var bestQueue *requestQueue
 	var bestVirtualFinish int64 = -1
 
@@ -206,6 +475,12 @@ func (fs *FairScheduler) NextRequest() (*scheduledRequest, string, bool) {
 		// Calculate virtual finish time for the next request
 		// Lower weight = higher virtual time advancement = less priority
 		req := q.requests[0]
+		if wait := fs.rateLimitWait(q.apiKey, req.tokens, now); wait > 0 {
+			if limitedFor == 0 || wait < limitedFor {
+				limitedFor = wait
+			}
+			continue
+		}
 		virtualStart := max(q.virtualTime, globalVTime)
 		virtualFinish := virtualStart + (req.tokens * 1000 / int64(q.weight))
 
@@ -218,49 +493,197 @@ func (fs *FairScheduler) NextRequest() (*scheduledRequest, string, bool) {
 	if bestQueue == nil {
 		return nil, "", false
 	}
+	limitedFor = 0
 
 	// Pop the request
 	req := bestQueue.requests[0]
 	bestQueue.requests = bestQueue.requests[1:]
 	bestQueue.totalTokens -= req.tokens
 	bestQueue.virtualTime = bestVirtualFinish
+	fs.chargeRateLimit(bestQueue.apiKey, req.tokens)
+	fs.checkDrained()
 
 	// Update global virtual time
 	fs.virtualTime.Store(bestVirtualFinish)
 
-	fs.metrics.RecordDequeue(bestQueue.apiKey)
+	fs.metrics.RecordDequeue(bestQueue.apiKey, now.Sub(req.enqueuedAt))
 
 	return req, bestQueue.apiKey, true
 }
 
-// ExecuteNext executes the next scheduled request.
+// nextDRR serves the queue whose turn it is while its deficit is positive, crediting each
+// queue its weighted quantum once per turn. A request is charged its estimate when it is
+// dequeued; settleUsage later corrects the charge to the measured tokens. A rate-limited
+// queue's turn is passed over without a credit, and the shortest wait for one to become
+// eligible is stored in limitedFor. Callers must hold fs.mu.
+func (fs *FairScheduler) nextDRR(now time.Time, limitedFor *time.Duration) (*scheduledRequest, string, bool) {
+	limitedInARow := 0
+	for len(fs.drrRound) > 0 && limitedInARow < len(fs.drrRound) {
+		if fs.drrCursor >= len(fs.drrRound) {
+			fs.drrCursor = 0
+		}
+		q := fs.drrRound[fs.drrCursor]
+		if len(q.requests) == 0 {
+			fs.leaveRound(q)
+			continue
+		}
+		if wait := fs.rateLimitWait(q.apiKey, q.requests[0].tokens, now); wait > 0 {
+			if *limitedFor == 0 || wait < *limitedFor {
+				*limitedFor = wait
+			}
+			limitedInARow++
+			fs.drrCursor++
+			fs.drrCredited = false
+			continue
+		}
+		limitedInARow = 0
+		if !fs.drrCredited {
+			q.deficit += max(fs.drrQuantum*int64(q.weight)/int64(fs.defaultWeight), 1)
+			fs.drrCredited = true
+		}
+		if q.deficit > 0 {
+			req := q.requests[0]
+			q.requests = q.requests[1:]
+			q.totalTokens -= req.tokens
+			q.deficit -= req.tokens
+			fs.chargeRateLimit(q.apiKey, req.tokens)
+			fs.metrics.RecordDequeue(q.apiKey, now.Sub(req.enqueuedAt))
+			fs.checkDrained()
+			return req, q.apiKey, true
+		}
+		fs.drrCursor++
+		fs.drrCredited = false
+	}
+	return nil, "", false
+}
+
+// leaveRound drops the idle queue at the DRR cursor from the round. Unspent credit is
+// forfeited so idle keys cannot bank it, but debt is kept until repaid. Callers must hold
+// fs.mu.
+func (fs *FairScheduler) leaveRound(q *requestQueue) {
+	fs.drrRound = append(fs.drrRound[:fs.drrCursor], fs.drrRound[fs.drrCursor+1:]...)
+	fs.drrCredited = false
+	q.inRound = false
+	if q.deficit > 0 {
+		q.deficit = 0
+	}
+}
+
+// settleUsage replaces the estimate charged for a request with its measured tokens.
+func (fs *FairScheduler) settleUsage(apiKey string, estimated, measured int64) {
+	if measured <= 0 || (fs.mode != ModeDRR && fs.limits[apiKey] == nil) {
+		return
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if q, exists := fs.queues[apiKey]; exists && fs.mode == ModeDRR {
+		q.deficit -= measured - estimated
+	}
+	if l := fs.limits[apiKey]; l != nil {
+		l.settle(measured - estimated)
+	}
+}
+
+// rateLimitWait returns how long apiKey must wait before a request estimated at tokens
+// fits its rate limit; 0 when it may run now. Callers must hold fs.mu.
+func (fs *FairScheduler) rateLimitWait(apiKey string, tokens int64, now time.Time) time.Duration {
+	if l := fs.limits[apiKey]; l != nil {
+		return l.wait(tokens, now)
+	}
+	return 0
+}
+
+// chargeRateLimit charges a dequeued request to apiKey's rate limit. Callers must hold fs.mu.
+func (fs *FairScheduler) chargeRateLimit(apiKey string, tokens int64) {
+	if l := fs.limits[apiKey]; l != nil {
+		l.take(tokens)
+	}
+}
+
+// scheduleRecheck wakes a worker after d, when a rate-limited key's budget has refilled,
+// unless an earlier wakeup is already pending. Callers must hold fs.mu.
+func (fs *FairScheduler) scheduleRecheck(now time.Time, d time.Duration) {
+	at := now.Add(d)
+	if !fs.recheckAt.IsZero() && !fs.recheckAt.After(at) {
+		return
+	}
+	fs.recheckAt = at
+	time.AfterFunc(d, func() {
+		fs.mu.Lock()
+		if fs.recheckAt.Equal(at) {
+			fs.recheckAt = time.Time{}
+		}
+		fs.mu.Unlock()
+		fs.signalWork()
+	})
+}
+
+// signalWork wakes an idle worker, if any is waiting.
+func (fs *FairScheduler) signalWork() {
+	select {
+	case fs.workCh <- struct{}{}:
+	default:
+	}
+}
+
+// recordAbandoned counts a request that left its queue without running.
+func (fs *FairScheduler) recordAbandoned(apiKey string, reason error) {
+	if reason == ErrQueueTimeout {
+		fs.metrics.RecordTimeout(apiKey)
+		return
+	}
+	fs.metrics.RecordCancellation(apiKey)
+}
+
+// ExecuteNext executes the next scheduled request. While MaxConcurrent callbacks are
+// already running it blocks until one of them finishes.
 func (fs *FairScheduler) ExecuteNext() bool {
+	if fs.slots != nil {
+		fs.slots <- struct{}{}
+		defer func() { <-fs.slots }()
+	}
+
 	req, apiKey, ok := fs.NextRequest()
 	if !ok {
 		return false
 	}
+	// More requests may be queued behind this one; let another worker look while this
+	// one is busy.
+	fs.signalWork()
 
 	// Check if context is still valid
 	if req.ctx.Err() != nil {
-		req.done <- req.ctx.Err()
+		reason := cancellationError(req.ctx.Err())
+		fs.recordAbandoned(apiKey, reason)
+		req.done <- reason
 		return true
 	}
 
+	fs.executing.Add(1)
 	start := time.Now()
-	err := req.callback()
+	measured, err := req.callback()
 	duration := time.Since(start)
+	fs.executing.Add(-1)
 
 	fs.metrics.RecordExecution(apiKey, duration, err == nil)
+	fs.settleUsage(apiKey, req.tokens, measured)
 	req.done <- err
 
 	return true
 }
 
-// RunWorker starts a worker that processes requests continuously.
+// RunWorker starts a worker that processes requests continuously. When no requests are
+// queued it blocks until Schedule enqueues one.
 func (fs *FairScheduler) RunWorker(ctx context.Context) {
 	fs.wg.Add(1)
 	defer fs.wg.Done()
+	fs.workers.Add(1)
+	fs.runWorker(ctx)
+}
 
+// runWorker is the worker loop. Callers account for it in fs.wg and fs.workers.
+func (fs *FairScheduler) runWorker(ctx context.Context) {
+	defer fs.workers.Add(-1)
 	for {
 		select {
 		case <-ctx.Done():
@@ -268,15 +691,22 @@ func (fs *FairScheduler) RunWorker(ctx context.Context) {
 		case <-fs.stopCh:
 			return
 		default:
-			if !fs.ExecuteNext() {
-				// No requests, sleep briefly
-				time.Sleep(10 * time.Millisecond)
-			}
+		}
+		if fs.ExecuteNext() {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-fs.stopCh:
+			return
+		case <-fs.workCh:
 		}
 	}
 }
 
-// Start starts the scheduler with the specified number of workers.
+// Start starts the scheduler with the specified number of workers, and a reporter that
+// publishes queue depths and wait times to the metrics collector every MetricsInterval.
 func (fs *FairScheduler) Start(ctx context.Context, workers int) {
 	if workers <= 0 {
 		workers = 1
@@ -284,17 +714,78 @@ func (fs *FairScheduler) Start(ctx context.Context, workers int) {
 	if fs.maxConcurrent > 0 && workers > fs.maxConcurrent {
 		workers = fs.maxConcurrent
 	}
+	// Register the workers before starting them so a Stop right after Start waits for them.
+	fs.wg.Add(workers + 1)
+	fs.workers.Add(int32(workers))
 	for i := 0; i < workers; i++ {
-		go fs.RunWorker(ctx)
+		go func() {
+			defer fs.wg.Done()
+			fs.runWorker(ctx)
+		}()
 	}
+	go func() {
+		defer fs.wg.Done()
+		fs.reportMetrics(ctx)
+	}()
+}
+
+// Running reports whether any worker is processing the queues. Requests scheduled while
+// it is false wait until a worker is started.
+func (fs *FairScheduler) Running() bool {
+	return fs.workers.Load() > 0
 }
 
-// Stop stops all workers.
+// Stop stops all workers, waiting for the requests they are running. Requests still
+// queued fail with ErrSchedulerShutdown; use Drain to run them first. Stop may be called
+// more than once.
 func (fs *FairScheduler) Stop() {
-	close(fs.stopCh)
+	fs.stopOnce.Do(func() { close(fs.stopCh) })
 	fs.wg.Wait()
 }
 
+// Drain stops accepting requests, so Schedule returns ErrSchedulerDraining, and keeps
+// running the queued ones until the queues are empty or ctx is done. It then stops the
+// workers like Stop, which waits for requests already running. Drain returns ctx's error
+// if requests were still queued when it gave up; those fail with ErrSchedulerShutdown.
+func (fs *FairScheduler) Drain(ctx context.Context) error {
+	fs.mu.Lock()
+	fs.draining = true
+	if fs.drained == nil {
+		fs.drained = make(chan struct{})
+		fs.checkDrained()
+	}
+	drained := fs.drained
+	fs.mu.Unlock()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	fs.Stop()
+	return err
+}
+
+// checkDrained closes fs.drained once the queues are empty while draining. Callers must
+// hold fs.mu.
+func (fs *FairScheduler) checkDrained() {
+	if !fs.draining || fs.drained == nil {
+		return
+	}
+	select {
+	case <-fs.drained:
+		return
+	default:
+	}
+	for _, q := range fs.queues {
+		if len(q.requests) > 0 {
+			return
+		}
+	}
+	close(fs.drained)
+}
+
 // Stats returns scheduler statistics.
 func (fs *FairScheduler) Stats() SchedulerStats {
 	fs.mu.Lock()
@@ -302,29 +793,48 @@ func (fs *FairScheduler) Stats() SchedulerStats {
 
 	stats := SchedulerStats{
 		Queues:      make(map[string]QueueStats),
+		Mode:        string(fs.mode),
+		Executing:   fs.executing.Load(),
 		VirtualTime: fs.virtualTime.Load(),
 	}
 
+	now := time.Now()
 	for apiKey, q := range fs.queues {
-		stats.Queues[apiKey] = QueueStats{
+		qs := QueueStats{
 			PendingRequests: len(q.requests),
 			TotalTokens:     q.totalTokens,
 			Weight:          q.weight,
 			VirtualTime:     q.virtualTime,
+			Deficit:         q.deficit,
+		}
+		if l := fs.limits[apiKey]; l != nil {
+			qs.RateLimit = l.stats(now)
 		}
+		stats.Queues[apiKey] = qs
 		stats.TotalPending += len(q.requests)
 	}
 
 	stats.Metrics = fs.metrics.Snapshot()
+	stats.Admission = GetAdmissionController().Stats()
+	stats.RetryBudget = GetRetryBudget().Stats()
 	return stats
 }
 
+// ResetMetrics zeroes scheduler metrics without affecting queued requests.
+func (fs *FairScheduler) ResetMetrics() {
+	fs.metrics.Reset()
+}
+
 // SchedulerStats holds scheduler statistics.
 type SchedulerStats struct {
 	Queues       map[string]QueueStats `json:"queues"`
+	Mode         string                `json:"mode"`
 	TotalPending int                   `json:"total_pending"`
+	Executing    int64                 `json:"executing"`
 	VirtualTime  int64                 `json:"virtual_time"`
 	Metrics      MetricsSnapshot       `json:"metrics"`
+	Admission    AdmissionStats        `json:"admission"`
+	RetryBudget  RetryBudgetStats      `json:"retry_budget"`
 }
 
 // QueueStats holds statistics for a single queue.
@@ -333,20 +843,65 @@ type QueueStats struct {
 	TotalTokens     int64 `json:"total_tokens"`
 	Weight          int   `json:"weight"`
 	VirtualTime     int64 `json:"virtual_time"`
+	// Deficit is the token allowance left in DRR mode; negative while repaying usage.
+	Deficit int64 `json:"deficit,omitempty"`
+	// RateLimit is the budget left under the key's rate limit, if it has one.
+	RateLimit *RateLimitStats `json:"rate_limit,omitempty"`
 }
 
 // ErrQueueFull is returned when a queue is at capacity.
 var ErrQueueFull = &SchedulerError{Message: "queue is full"}
 
+// Errors for a request that left the queue without running. The first two unwrap to the
+// matching context error.
+var (
+	// ErrClientCanceled means the caller's context was canceled, usually a client disconnect.
+	ErrClientCanceled = &SchedulerError{Message: "request canceled while queued", Cause: context.Canceled}
+	// ErrQueueTimeout means the request waited longer than its deadline or QueueTimeout.
+	ErrQueueTimeout = &SchedulerError{Message: "request timed out in queue", Cause: context.DeadlineExceeded}
+	// ErrSchedulerShutdown means the scheduler stopped before the request ran.
+	ErrSchedulerShutdown = &SchedulerError{Message: "scheduler is shutting down"}
+)
+
+// ErrSchedulerDraining is returned by Schedule once Drain has begun.
+var ErrSchedulerDraining = &SchedulerError{Message: "scheduler is draining"}
+
+// StatusClientClosedRequest is the non-standard status for a client that went away.
+const StatusClientClosedRequest = 499
+
 // SchedulerError represents a scheduler error.
 type SchedulerError struct {
 	Message string
+	// Cause is the underlying error, if any.
+	Cause error
 }
 
 func (e *SchedulerError) Error() string {
 	return e.Message
 }
 
+// Unwrap returns the underlying error.
+func (e *SchedulerError) Unwrap() error {
+	return e.Cause
+}
+
+// HTTPStatus returns the response status for a scheduling error: 499 for a canceled
+// client, 504 for a queue timeout, 503 when the scheduler is full, overloaded, draining or
+// shutting down, and 0 for errors the scheduler did not produce.
+func HTTPStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrClientCanceled):
+		return StatusClientClosedRequest
+	case errors.Is(err, ErrQueueTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ErrSchedulerShutdown), errors.Is(err, ErrSchedulerDraining),
+		errors.Is(err, ErrQueueFull), errors.Is(err, ErrOverloaded):
+		return http.StatusServiceUnavailable
+	default:
+		return 0
+	}
+}
+
 // SchedulerMetrics tracks scheduler performance metrics.
 type SchedulerMetrics struct {
 	mu sync.RWMutex
@@ -356,12 +911,15 @@ type SchedulerMetrics struct {
 	totalExecuted   int64
 	totalRejected   int64
 	totalCancelled  int64
+	totalTimedOut   int64
 	totalSuccessful int64
 	totalFailed     int64
 
-	queueTimes    []time.Duration
-	executeTimes  []time.Duration
-	keyMetrics    map[string]*keyMetrics
+	// queueTimes holds recent enqueue-to-dequeue delays, executeTimes recent callback
+	// durations.
+	queueTimes   latencyWindow
+	executeTimes latencyWindow
+	keyMetrics   map[string]*keyMetrics
 }
 
 type keyMetrics struct {
@@ -370,6 +928,7 @@ type keyMetrics struct {
 	executed   int64
 	rejected   int64
 	cancelled  int64
+	timedOut   int64
 	successful int64
 	failed     int64
 }
@@ -377,9 +936,7 @@ type keyMetrics struct {
 // NewSchedulerMetrics creates a new metrics instance.
 func NewSchedulerMetrics() *SchedulerMetrics {
 	return &SchedulerMetrics{
-		queueTimes:   make([]time.Duration, 0, 1000),
-		executeTimes: make([]time.Duration, 0, 1000),
-		keyMetrics:   make(map[string]*keyMetrics),
+		keyMetrics: make(map[string]*keyMetrics),
 	}
 }
 
@@ -400,12 +957,13 @@ func (m *SchedulerMetrics) RecordEnqueue(apiKey string) {
 	m.getKeyMetrics(apiKey).enqueued++
 }
 
-// RecordDequeue records a request being dequeued.
-func (m *SchedulerMetrics) RecordDequeue(apiKey string) {
+// RecordDequeue records a request being dequeued after waiting in its queue for wait.
+func (m *SchedulerMetrics) RecordDequeue(apiKey string, wait time.Duration) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.totalDequeued++
 	m.getKeyMetrics(apiKey).dequeued++
+	m.queueTimes.add(wait)
 }
 
 // RecordRejection records a request being rejected.
@@ -424,6 +982,14 @@ func (m *SchedulerMetrics) RecordCancellation(apiKey string) {
 	m.getKeyMetrics(apiKey).cancelled++
 }
 
+// RecordTimeout records a request that waited in its queue past the queue timeout.
+func (m *SchedulerMetrics) RecordTimeout(apiKey string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.totalTimedOut++
+	m.getKeyMetrics(apiKey).timedOut++
+}
+
 // RecordExecution records a request execution.
 func (m *SchedulerMetrics) RecordExecution(apiKey string, duration time.Duration, success bool) {
 	m.mu.Lock()
@@ -441,11 +1007,33 @@ func (m *SchedulerMetrics) RecordExecution(apiKey string, duration time.Duration
 		km.failed++
 	}
 
-	// Keep last 1000 execution times
-	if len(m.executeTimes) >= 1000 {
-		m.executeTimes = m.executeTimes[1:]
-	}
-	m.executeTimes = append(m.executeTimes, duration)
+	m.executeTimes.add(duration)
+}
+
+// queueTimesSince returns the queue times recorded after the first seen ones, as far as
+// they are still kept, and how many have been recorded in total.
+func (m *SchedulerMetrics) queueTimesSince(seen int64) ([]time.Duration, int64) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.queueTimes.since(seen), m.queueTimes.recorded
+}
+
+// Reset zeroes all counters and recorded timings.
+func (m *SchedulerMetrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.totalEnqueued = 0
+	m.totalDequeued = 0
+	m.totalExecuted = 0
+	m.totalRejected = 0
+	m.totalCancelled = 0
+	m.totalTimedOut = 0
+	m.totalSuccessful = 0
+	m.totalFailed = 0
+	m.queueTimes.reset()
+	m.executeTimes.reset()
+	m.keyMetrics = make(map[string]*keyMetrics)
 }
 
 // Snapshot returns a copy of the current metrics.
@@ -459,8 +1047,11 @@ func (m *SchedulerMetrics) Snapshot() MetricsSnapshot {
 		TotalExecuted:   m.totalExecuted,
 		TotalRejected:   m.totalRejected,
 		TotalCancelled:  m.totalCancelled,
+		TotalTimedOut:   m.totalTimedOut,
 		TotalSuccessful: m.totalSuccessful,
 		TotalFailed:     m.totalFailed,
+		QueueWait:       m.queueTimes.percentiles(),
+		Execution:       m.executeTimes.percentiles(),
 	}
 }
 
@@ -471,8 +1062,13 @@ type MetricsSnapshot struct {
 	TotalExecuted   int64 `json:"total_executed"`
 	TotalRejected   int64 `json:"total_rejected"`
 	TotalCancelled  int64 `json:"total_cancelled"`
+	TotalTimedOut   int64 `json:"total_timed_out"`
 	TotalSuccessful int64 `json:"total_successful"`
 	TotalFailed     int64 `json:"total_failed"`
+	// QueueWait and Execution are percentiles of the last 1000 queue waits and callback
+	// durations.
+	QueueWait LatencyPercentiles `json:"queue_wait"`
+	Execution LatencyPercentiles `json:"execution"`
 }
 
 // PriorityQueue implements a priority queue for requests.
@@ -0,0 +1,600 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// enqueueAsync schedules a request without workers running and waits until it is queued.
+func enqueueAsync(t *testing.T, fs *FairScheduler, apiKey string, body []byte, tokens int64, callback func() error) <-chan error {
+	t.Helper()
+	before := fs.Stats().TotalPending
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- fs.ScheduleRequest(context.Background(), apiKey, body, tokens, callback)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for fs.Stats().TotalPending == before {
+		if time.Now().After(deadline) {
+			t.Fatal("request was not enqueued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return errCh
+}
+
+func TestScheduleRequest_EstimatesTokensFromBody(t *testing.T) {
+	fs := NewFairScheduler(DefaultSchedulerConfig())
+	body := []byte(`{"model":"m","messages":[{"role":"user","content":"` + strings.Repeat("a", 400) + `"}]}`)
+
+	errCh := enqueueAsync(t, fs, "key", body, 0, func() error { return nil })
+
+	want := fs.EstimateTokens(body)
+	if want <= 100 {
+		t.Fatalf("EstimateTokens = %d, want an estimate proportional to the body", want)
+	}
+	if got := fs.Stats().Queues["key"].TotalTokens; got != want {
+		t.Fatalf("queued tokens = %d, want %d", got, want)
+	}
+
+	fs.ExecuteNext()
+	if err := <-errCh; err != nil {
+		t.Fatalf("ScheduleRequest: %v", err)
+	}
+}
+
+func TestScheduleRequest_ExplicitTokensWin(t *testing.T) {
+	fs := NewFairScheduler(DefaultSchedulerConfig())
+
+	errCh := enqueueAsync(t, fs, "key", []byte(strings.Repeat("a", 4000)), 7, func() error { return nil })
+	if got := fs.Stats().Queues["key"].TotalTokens; got != 7 {
+		t.Fatalf("queued tokens = %d, want 7", got)
+	}
+
+	fs.ExecuteNext()
+	<-errCh
+}
+
+func runQueueOrder(t *testing.T, cfg SchedulerConfig) []string {
+	t.Helper()
+	fs := NewFairScheduler(cfg)
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) func() error {
+		return func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+			return nil
+		}
+	}
+
+	var waits []<-chan error
+	waits = append(waits, enqueueAsync(t, fs, "key", []byte(strings.Repeat("x", 8000)), 0, record("large")))
+	waits = append(waits, enqueueAsync(t, fs, "key", []byte(strings.Repeat("x", 2000)), 0, record("medium")))
+	waits = append(waits, enqueueAsync(t, fs, "key", []byte("small"), 0, record("small")))
+
+	for fs.ExecuteNext() {
+	}
+	for _, w := range waits {
+		<-w
+	}
+	return order
+}
+
+func TestShortestJobFirst_ServesSmallRequestsSooner(t *testing.T) {
+	cfg := DefaultSchedulerConfig()
+	cfg.ShortestJobFirst = true
+
+	got := strings.Join(runQueueOrder(t, cfg), ",")
+	if got != "small,medium,large" {
+		t.Fatalf("execution order = %s, want small,medium,large", got)
+	}
+}
+
+func TestShortestJobFirst_DisabledKeepsArrivalOrder(t *testing.T) {
+	got := strings.Join(runQueueOrder(t, DefaultSchedulerConfig()), ",")
+	if got != "large,medium,small" {
+		t.Fatalf("execution order = %s, want large,medium,small", got)
+	}
+}
+
+// servedTokenShares keeps two keys with 3:1 weights backlogged with requests that all
+// estimate 100 tokens but actually use small or large amounts, and returns each key's share
+// of the measured tokens served over many executions.
+func servedTokenShares(t *testing.T, mode SchedulingMode) map[string]float64 {
+	t.Helper()
+	cfg := DefaultSchedulerConfig()
+	cfg.Mode = mode
+	fs := NewFairScheduler(cfg)
+	fs.SetWeight("heavy", 300)
+	fs.SetWeight("light", 100)
+
+	rng := rand.New(rand.NewSource(1))
+	actual := map[string]func() int64{
+		// The higher-weight key sends small requests, the other large ones.
+		"heavy": func() int64 { return 20 + rng.Int63n(180) },
+		"light": func() int64 { return 500 + rng.Int63n(1500) },
+	}
+
+	const perKey = 400
+	var wg sync.WaitGroup
+	served := make(map[string]int64)
+	for _, key := range []string{"heavy", "light"} {
+		for i := 0; i < perKey; i++ {
+			wg.Add(1)
+			go func(key string) {
+				defer wg.Done()
+				_ = fs.ScheduleMeasured(context.Background(), key, 100, func() (int64, error) {
+					tokens := actual[key]()
+					served[key] += tokens
+					return tokens, nil
+				})
+			}(key)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for fs.Stats().TotalPending < 2*perKey {
+		if time.Now().After(deadline) {
+			t.Fatal("requests were not enqueued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Measure while both keys are still backlogged.
+	for i := 0; i < perKey/2; i++ {
+		fs.ExecuteNext()
+	}
+	total := float64(served["heavy"] + served["light"])
+	shares := map[string]float64{"heavy": float64(served["heavy"]) / total, "light": float64(served["light"]) / total}
+
+	for fs.ExecuteNext() {
+	}
+	wg.Wait()
+	return shares
+}
+
+func TestDRR_TokenSharesConvergeToWeights(t *testing.T) {
+	shares := servedTokenShares(t, ModeDRR)
+	if got := shares["heavy"]; got < 0.72 || got > 0.78 {
+		t.Fatalf("heavy key served %.3f of tokens, want about 0.75", got)
+	}
+}
+
+func TestStats_ReportsMode(t *testing.T) {
+	if got := NewFairScheduler(DefaultSchedulerConfig()).Stats().Mode; got != string(ModeWFQ) {
+		t.Fatalf("default mode = %q, want %q", got, ModeWFQ)
+	}
+	cfg := DefaultSchedulerConfig()
+	cfg.Mode = ParseSchedulingMode("DRR")
+	if got := NewFairScheduler(cfg).Stats().Mode; got != string(ModeDRR) {
+		t.Fatalf("mode = %q, want %q", got, ModeDRR)
+	}
+}
+
+func TestSchedule_CancellationReasons(t *testing.T) {
+	noop := func() error { return nil }
+	tests := []struct {
+		name       string
+		queueLimit time.Duration
+		run        func(fs *FairScheduler) error
+		want       error
+		wantStatus int
+	}{
+		{
+			name: "client canceled",
+			run: func(fs *FairScheduler) error {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(20*time.Millisecond, cancel)
+				return fs.Schedule(ctx, "key", 1, noop)
+			},
+			want:       ErrClientCanceled,
+			wantStatus: StatusClientClosedRequest,
+		},
+		{
+			name: "caller deadline",
+			run: func(fs *FairScheduler) error {
+				ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+				defer cancel()
+				return fs.Schedule(ctx, "key", 1, noop)
+			},
+			want:       ErrQueueTimeout,
+			wantStatus: http.StatusGatewayTimeout,
+		},
+		{
+			name:       "queue timeout",
+			queueLimit: 20 * time.Millisecond,
+			run: func(fs *FairScheduler) error {
+				return fs.Schedule(context.Background(), "key", 1, noop)
+			},
+			want:       ErrQueueTimeout,
+			wantStatus: http.StatusGatewayTimeout,
+		},
+		{
+			name: "shutdown",
+			run: func(fs *FairScheduler) error {
+				time.AfterFunc(20*time.Millisecond, fs.Stop)
+				return fs.Schedule(context.Background(), "key", 1, noop)
+			},
+			want:       ErrSchedulerShutdown,
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultSchedulerConfig()
+			cfg.QueueTimeout = tt.queueLimit
+			// No workers run, so every request stays queued until it is given up on.
+			fs := NewFairScheduler(cfg)
+
+			err := tt.run(fs)
+			if err != tt.want {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if got := HTTPStatus(err); got != tt.wantStatus {
+				t.Fatalf("HTTPStatus = %d, want %d", got, tt.wantStatus)
+			}
+			if pending := fs.Stats().TotalPending; pending != 0 {
+				t.Fatalf("pending = %d, want the request removed from its queue", pending)
+			}
+		})
+	}
+}
+
+func TestSchedule_CancellationErrorsUnwrapToContextErrors(t *testing.T) {
+	if !errors.Is(ErrClientCanceled, context.Canceled) || !errors.Is(ErrQueueTimeout, context.DeadlineExceeded) {
+		t.Fatal("cancellation errors must still match their context errors")
+	}
+	if errors.Is(ErrQueueTimeout, ErrClientCanceled) {
+		t.Fatal("queue timeout must be distinguishable from a canceled client")
+	}
+}
+
+func TestSchedule_RejectsAfterShutdown(t *testing.T) {
+	fs := NewFairScheduler(DefaultSchedulerConfig())
+	fs.Stop()
+	if err := fs.Schedule(context.Background(), "key", 1, func() error { return nil }); err != ErrSchedulerShutdown {
+		t.Fatalf("err = %v, want ErrSchedulerShutdown", err)
+	}
+}
+
+func TestRunWorker_WakesImmediatelyOnEnqueue(t *testing.T) {
+	fs := NewFairScheduler(DefaultSchedulerConfig())
+	fs.Start(context.Background(), 1)
+	defer fs.Stop()
+
+	const samples = 20
+	latencies := make([]time.Duration, 0, samples)
+	for i := 0; i < samples; i++ {
+		// Let the worker go idle before each request.
+		time.Sleep(15 * time.Millisecond)
+		enqueued := time.Now()
+		var started time.Time
+		if err := fs.Schedule(context.Background(), "key", 1, func() error {
+			started = time.Now()
+			return nil
+		}); err != nil {
+			t.Fatalf("Schedule: %v", err)
+		}
+		latencies = append(latencies, started.Sub(enqueued))
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	if median := latencies[samples/2]; median > 2*time.Millisecond {
+		t.Fatalf("median pickup latency = %v, want well under 10ms", median)
+	}
+}
+
+func TestRunWorker_WakesEveryIdleWorker(t *testing.T) {
+	fs := NewFairScheduler(DefaultSchedulerConfig())
+	fs.Start(context.Background(), 3)
+	defer fs.Stop()
+	time.Sleep(10 * time.Millisecond)
+
+	const requests = 3
+	var running sync.WaitGroup
+	running.Add(requests)
+	release := make(chan struct{})
+	errs := make(chan error, requests)
+	for i := 0; i < requests; i++ {
+		go func() {
+			errs <- fs.Schedule(context.Background(), "key", 1, func() error {
+				running.Done()
+				<-release
+				return nil
+			})
+		}()
+	}
+
+	allRunning := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(allRunning)
+	}()
+	select {
+	case <-allRunning:
+	case <-time.After(time.Second):
+		t.Fatal("queued requests did not run in parallel on idle workers")
+	}
+	close(release)
+	for i := 0; i < requests; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("Schedule: %v", err)
+		}
+	}
+}
+
+func TestStop_ReturnsPromptlyWithIdleWorkers(t *testing.T) {
+	fs := NewFairScheduler(DefaultSchedulerConfig())
+	fs.Start(context.Background(), 4)
+	time.Sleep(10 * time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		fs.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop did not return while workers were idle")
+	}
+}
+
+func TestQueueTimeout_RecordedSeparatelyFromCancellation(t *testing.T) {
+	cfg := DefaultSchedulerConfig()
+	cfg.QueueTimeout = 20 * time.Millisecond
+	fs := NewFairScheduler(cfg)
+
+	if err := fs.Schedule(context.Background(), "slow", 1, func() error { return nil }); err != ErrQueueTimeout {
+		t.Fatalf("err = %v, want ErrQueueTimeout", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := fs.Schedule(ctx, "slow", 1, func() error { return nil }); err != ErrClientCanceled {
+		t.Fatalf("err = %v, want ErrClientCanceled", err)
+	}
+
+	stats := fs.Stats()
+	if stats.TotalPending != 0 {
+		t.Fatalf("pending = %d, want timed out requests removed", stats.TotalPending)
+	}
+	if stats.Metrics.TotalTimedOut != 1 || stats.Metrics.TotalCancelled != 1 {
+		t.Fatalf("timed out = %d, cancelled = %d, want 1 and 1", stats.Metrics.TotalTimedOut, stats.Metrics.TotalCancelled)
+	}
+}
+
+func TestMaxConcurrent_CapsExecutingCallbacks(t *testing.T) {
+	cfg := DefaultSchedulerConfig()
+	cfg.MaxConcurrent = 2
+	fs := NewFairScheduler(cfg)
+	defer fs.Stop()
+	// Run more workers than the cap; the cap must hold regardless.
+	for i := 0; i < 6; i++ {
+		go fs.RunWorker(context.Background())
+	}
+
+	const requests = 12
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	wg.Add(requests)
+	for i := 0; i < requests; i++ {
+		go func() {
+			defer wg.Done()
+			err := fs.Schedule(context.Background(), "key", 1, func() error {
+				n := running.Add(1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				if executing := fs.Stats().Executing; executing > 2 {
+					t.Errorf("executing = %d, want at most 2", executing)
+				}
+				time.Sleep(5 * time.Millisecond)
+				running.Add(-1)
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Schedule: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got != 2 {
+		t.Fatalf("peak concurrency = %d, want 2", got)
+	}
+}
+
+// waitForPending waits until n requests are queued.
+func waitForPending(t *testing.T, fs *FairScheduler, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for fs.Stats().TotalPending != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("pending = %d, want %d", fs.Stats().TotalPending, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDrain_RunsQueuedRequestsThenStops(t *testing.T) {
+	fs := NewFairScheduler(DefaultSchedulerConfig())
+
+	const requests = 5
+	var ran atomic.Int32
+	errs := make(chan error, requests)
+	for i := 0; i < requests; i++ {
+		go func() {
+			errs <- fs.Schedule(context.Background(), "key", 1, func() error {
+				time.Sleep(5 * time.Millisecond)
+				ran.Add(1)
+				return nil
+			})
+		}()
+	}
+	waitForPending(t, fs, requests)
+	fs.Start(context.Background(), 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := fs.Drain(ctx); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if got := ran.Load(); got != requests {
+		t.Fatalf("ran = %d, want %d", got, requests)
+	}
+	for i := 0; i < requests; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("Schedule: %v", err)
+		}
+	}
+
+	err := fs.Schedule(context.Background(), "key", 1, func() error { return nil })
+	if err != ErrSchedulerDraining {
+		t.Fatalf("err after drain = %v, want ErrSchedulerDraining", err)
+	}
+	if got := HTTPStatus(err); got != http.StatusServiceUnavailable {
+		t.Fatalf("HTTPStatus = %d, want %d", got, http.StatusServiceUnavailable)
+	}
+}
+
+func TestDrain_GivesUpAtDeadline(t *testing.T) {
+	// No workers run, so the queued requests can never drain.
+	fs := NewFairScheduler(DefaultSchedulerConfig())
+
+	const requests = 3
+	errs := make(chan error, requests)
+	for i := 0; i < requests; i++ {
+		go func() {
+			errs <- fs.Schedule(context.Background(), "key", 1, func() error { return nil })
+		}()
+	}
+	waitForPending(t, fs, requests)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	drained := make(chan error, 1)
+	go func() { drained <- fs.Drain(ctx) }()
+
+	// Requests arriving while draining are turned away.
+	time.Sleep(5 * time.Millisecond)
+	if err := fs.Schedule(context.Background(), "other", 1, func() error { return nil }); err != ErrSchedulerDraining {
+		t.Fatalf("err during drain = %v, want ErrSchedulerDraining", err)
+	}
+
+	select {
+	case err := <-drained:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Drain = %v, want deadline exceeded", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Drain did not return at its deadline")
+	}
+	for i := 0; i < requests; i++ {
+		if err := <-errs; err != ErrSchedulerShutdown {
+			t.Fatalf("queued request err = %v, want ErrSchedulerShutdown", err)
+		}
+	}
+}
+
+func TestDrain_EmptySchedulerReturnsImmediately(t *testing.T) {
+	fs := NewFairScheduler(DefaultSchedulerConfig())
+	fs.Start(context.Background(), 2)
+	if err := fs.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	fs.Stop()
+}
+
+// priorityRequest is one request queued by runPriorityOrder.
+type priorityRequest struct {
+	key, name string
+	tokens    int64
+	priority  int
+}
+
+// runPriorityOrder queues reqs one at a time, then executes them all and returns the names
+// in the order they ran.
+func runPriorityOrder(t *testing.T, fs *FairScheduler, reqs []priorityRequest) []string {
+	t.Helper()
+	var mu sync.Mutex
+	var order []string
+	errs := make(chan error, len(reqs))
+	for i, r := range reqs {
+		go func() {
+			opts := ScheduleOptions{EstimatedTokens: r.tokens, Priority: r.priority}
+			errs <- fs.ScheduleWithOptions(context.Background(), r.key, opts, func() (int64, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				order = append(order, r.name)
+				return 0, nil
+			})
+		}()
+		waitForPending(t, fs, i+1)
+	}
+	for fs.ExecuteNext() {
+	}
+	for range reqs {
+		if err := <-errs; err != nil {
+			t.Fatalf("ScheduleWithOptions: %v", err)
+		}
+	}
+	return order
+}
+
+func TestPriority_OvertakesEarlierRequestInSameQueue(t *testing.T) {
+	for _, sjf := range []bool{false, true} {
+		cfg := DefaultSchedulerConfig()
+		cfg.ShortestJobFirst = sjf
+		got := strings.Join(runPriorityOrder(t, NewFairScheduler(cfg), []priorityRequest{
+			{"key", "batch", 100, PriorityBatch},
+			{"key", "normal", 100, PriorityNormal},
+			{"key", "interactive", 100, PriorityInteractive},
+			{"key", "batch-2", 100, PriorityBatch},
+		}), ",")
+		if got != "interactive,normal,batch,batch-2" {
+			t.Fatalf("shortest-job-first=%v: execution order = %s, want interactive,normal,batch,batch-2", sjf, got)
+		}
+	}
+}
+
+func TestPriority_DoesNotChangeOrderAcrossKeys(t *testing.T) {
+	// Distinct costs per key keep the fair schedule free of ties.
+	reqs := []priorityRequest{
+		{"a", "a", 100, PriorityBatch},
+		{"a", "a", 100, PriorityBatch},
+		{"a", "a", 100, PriorityBatch},
+		{"b", "b", 130, PriorityInteractive},
+		{"b", "b", 130, PriorityInteractive},
+		{"b", "b", 130, PriorityInteractive},
+	}
+	for _, mode := range []SchedulingMode{ModeWFQ, ModeDRR} {
+		cfg := DefaultSchedulerConfig()
+		cfg.Mode = mode
+		cfg.DRRQuantum = 100
+
+		withPriority := strings.Join(runPriorityOrder(t, NewFairScheduler(cfg), reqs), ",")
+		unprioritized := make([]priorityRequest, len(reqs))
+		for i, r := range reqs {
+			r.priority = PriorityNormal
+			unprioritized[i] = r
+		}
+		without := strings.Join(runPriorityOrder(t, NewFairScheduler(cfg), unprioritized), ",")
+		if withPriority != without {
+			t.Fatalf("mode %s: keys served %s with priorities, %s without", mode, withPriority, without)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Package scheduler provides fair scheduling and weighted queuing for API requests.
+// This file keeps bounded windows of recent durations and their percentiles.
+package scheduler
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// maxLatencySamples bounds how many recent durations a latencyWindow keeps.
+const maxLatencySamples = 1000
+
+// LatencyPercentiles summarizes recent durations, in milliseconds.
+type LatencyPercentiles struct {
+	P50 float64 `json:"p50_ms"`
+	P90 float64 `json:"p90_ms"`
+	P99 float64 `json:"p99_ms"`
+}
+
+// latencyWindow keeps the most recent durations in a fixed-size ring, so its memory stays
+// constant however many are recorded. It is not safe for concurrent use.
+type latencyWindow struct {
+	samples  []time.Duration
+	next     int   // where the next sample goes once the ring is full
+	recorded int64 // every sample recorded, including those overwritten
+}
+
+// add records d, overwriting the oldest sample once the window is full.
+func (w *latencyWindow) add(d time.Duration) {
+	w.recorded++
+	if len(w.samples) < maxLatencySamples {
+		w.samples = append(w.samples, d)
+		return
+	}
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % maxLatencySamples
+}
+
+// since returns, oldest first, the samples recorded after the first seen ones that are
+// still kept. A seen larger than the number recorded means the window was reset since.
+func (w *latencyWindow) since(seen int64) []time.Duration {
+	n := w.recorded - seen
+	if n < 0 {
+		n = w.recorded
+	}
+	n = min(n, int64(len(w.samples)))
+	out := make([]time.Duration, 0, n)
+	// The newest sample is just before next; walk back n samples from there.
+	start := w.next - int(n)
+	for i := range int(n) {
+		idx := (start + i) % len(w.samples)
+		if idx < 0 {
+			idx += len(w.samples)
+		}
+		out = append(out, w.samples[idx])
+	}
+	return out
+}
+
+// percentiles returns the nearest-rank percentiles of the kept samples.
+func (w *latencyWindow) percentiles() LatencyPercentiles {
+	if len(w.samples) == 0 {
+		return LatencyPercentiles{}
+	}
+	sorted := slices.Clone(w.samples)
+	slices.Sort(sorted)
+	at := func(p float64) float64 {
+		rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+		return float64(sorted[max(rank, 0)]) / float64(time.Millisecond)
+	}
+	return LatencyPercentiles{P50: at(0.50), P90: at(0.90), P99: at(0.99)}
+}
+
+// reset drops every sample.
+func (w *latencyWindow) reset() {
+	w.samples = w.samples[:0]
+	w.next = 0
+	w.recorded = 0
+}
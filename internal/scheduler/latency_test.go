@@ -0,0 +1,76 @@
+package scheduler
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestLatencyWindow_PercentilesOfKnownDistribution(t *testing.T) {
+	var w latencyWindow
+	// 1ms..1000ms in random order.
+	for _, i := range rand.New(rand.NewSource(1)).Perm(1000) {
+		w.add(time.Duration(i+1) * time.Millisecond)
+	}
+	got := w.percentiles()
+	want := LatencyPercentiles{P50: 500, P90: 900, P99: 990}
+	for name, pair := range map[string][2]float64{
+		"p50": {got.P50, want.P50},
+		"p90": {got.P90, want.P90},
+		"p99": {got.P99, want.P99},
+	} {
+		if math.Abs(pair[0]-pair[1]) > 1 {
+			t.Errorf("%s = %.1fms, want about %.0fms", name, pair[0], pair[1])
+		}
+	}
+}
+
+func TestLatencyWindow_KeepsOnlyRecentSamples(t *testing.T) {
+	var w latencyWindow
+	// A burst of slow samples followed by a full window of fast ones.
+	for i := 0; i < 500; i++ {
+		w.add(time.Second)
+	}
+	for i := 0; i < maxLatencySamples; i++ {
+		w.add(time.Millisecond)
+	}
+	if len(w.samples) != maxLatencySamples || cap(w.samples) > 2*maxLatencySamples {
+		t.Fatalf("window holds %d samples (cap %d), want %d", len(w.samples), cap(w.samples), maxLatencySamples)
+	}
+	if p99 := w.percentiles().P99; p99 != 1 {
+		t.Fatalf("p99 = %.1fms, want the old slow samples evicted", p99)
+	}
+
+	w.add(2 * time.Millisecond)
+	w.add(3 * time.Millisecond)
+	recent := w.since(w.recorded - 2)
+	if len(recent) != 2 || recent[0] != 2*time.Millisecond || recent[1] != 3*time.Millisecond {
+		t.Fatalf("since = %v, want [2ms 3ms]", recent)
+	}
+}
+
+func TestSnapshot_ReportsQueueWaitPercentiles(t *testing.T) {
+	fs := NewFairScheduler(DefaultSchedulerConfig())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- fs.Schedule(context.Background(), "key", 1, func() error { return nil })
+	}()
+	waitForPending(t, fs, 1)
+	time.Sleep(20 * time.Millisecond)
+	fs.ExecuteNext()
+	if err := <-errs; err != nil {
+		t.Fatalf("Schedule: %v", err)
+	}
+
+	snap := fs.Stats().Metrics
+	if snap.QueueWait.P50 < 20 || snap.QueueWait.P99 < snap.QueueWait.P50 {
+		t.Fatalf("queue wait = %+v, want at least 20ms", snap.QueueWait)
+	}
+
+	fs.ResetMetrics()
+	if snap := fs.Stats().Metrics; snap.QueueWait != (LatencyPercentiles{}) {
+		t.Fatalf("queue wait after reset = %+v, want zero", snap.QueueWait)
+	}
+}
@@ -0,0 +1,46 @@
+// Package scheduler provides fair scheduling and weighted queuing for API requests.
+// This file publishes scheduler state to the Prometheus metrics collector.
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
+)
+
+// defaultMetricsInterval is how often queue metrics are published unless configured.
+const defaultMetricsInterval = 5 * time.Second
+
+// reportMetrics publishes metrics to the configured recorder every metricsInterval until
+// ctx is done or the scheduler stops.
+func (fs *FairScheduler) reportMetrics(ctx context.Context) {
+	ticker := time.NewTicker(fs.metricsInterval)
+	defer ticker.Stop()
+
+	var seen int64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-fs.stopCh:
+			return
+		case <-ticker.C:
+			seen = fs.publishMetrics(observability.GetMetricsRecorder(), seen)
+		}
+	}
+}
+
+// publishMetrics records every key's current queue depth, and the queue waits recorded
+// after the first seen ones, in m. It returns the number of waits recorded so far, to be
+// passed as seen on the next call.
+func (fs *FairScheduler) publishMetrics(m observability.MetricsRecorder, seen int64) int64 {
+	for apiKey, q := range fs.Stats().Queues {
+		m.RecordSchedulerQueue(apiKey, int64(q.PendingRequests))
+	}
+	waits, recorded := fs.metrics.queueTimesSince(seen)
+	for _, d := range waits {
+		m.RecordSchedulerWait(d)
+	}
+	return recorded
+}
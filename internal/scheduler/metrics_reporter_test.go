@@ -0,0 +1,88 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
+)
+
+// waitForExport waits until the global metrics export contains want.
+func waitForExport(t *testing.T, want string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(observability.GetMetrics().Export(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("metrics export never contained %s", want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMetricsReporter_PublishesCurrentQueueDepth(t *testing.T) {
+	cfg := DefaultSchedulerConfig()
+	cfg.MaxConcurrent = 1
+	cfg.MetricsInterval = 10 * time.Millisecond
+	fs := NewFairScheduler(cfg)
+	defer fs.Stop()
+
+	apiKey := fmt.Sprintf("sk-reporter-%d", time.Now().UnixNano())
+	gauge := `scheduler_queue_size{api_key="` + observability.HashAPIKey(apiKey) + `"}`
+
+	// Hold the only worker so the requests behind it stay queued.
+	release := make(chan struct{})
+	started := make(chan struct{})
+	errs := make(chan error, 4)
+	go func() {
+		errs <- fs.Schedule(context.Background(), apiKey, 1, func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	fs.Start(context.Background(), 1)
+	<-started
+	for i := 0; i < 3; i++ {
+		go func() {
+			errs <- fs.Schedule(context.Background(), apiKey, 1, func() error { return nil })
+		}()
+	}
+	waitForPending(t, fs, 3)
+
+	waitForExport(t, gauge+" 3\n")
+	if strings.Contains(observability.GetMetrics().Export(), apiKey) {
+		t.Fatal("metrics export leaks the raw API key")
+	}
+
+	close(release)
+	for i := 0; i < 4; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("Schedule: %v", err)
+		}
+	}
+	waitForExport(t, gauge+" 0\n")
+}
+
+func TestPublishMetrics_RecordsEachQueueWaitOnce(t *testing.T) {
+	fs := NewFairScheduler(DefaultSchedulerConfig())
+	m := observability.NewMetricsCollector(observability.DefaultMetricsConfig())
+
+	for i := 0; i < 2; i++ {
+		go fs.Schedule(context.Background(), "key", 1, func() error { return nil })
+		waitForPending(t, fs, 1)
+		fs.ExecuteNext()
+	}
+	seen := fs.publishMetrics(m.Recorder(), 0)
+	if seen != 2 {
+		t.Fatalf("seen = %d, want 2", seen)
+	}
+	if got := fs.publishMetrics(m.Recorder(), seen); got != 2 {
+		t.Fatalf("seen after no new waits = %d, want 2", got)
+	}
+	if !strings.Contains(m.Export(), "scheduler_wait_milliseconds_count 2\n") {
+		t.Fatalf("wait count not 2 in export:\n%s", m.Export())
+	}
+}
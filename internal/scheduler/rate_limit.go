@@ -0,0 +1,124 @@
+// Package scheduler provides fair scheduling and weighted queuing for API requests.
+// This file implements the per-key rate limits consulted by the fair scheduler.
+package scheduler
+
+import (
+	"math"
+	"time"
+)
+
+// KeyRateLimit caps how fast one API key's requests leave the scheduler. Limits are hard:
+// a key over budget waits even when other keys leave capacity unused.
+type KeyRateLimit struct {
+	// APIKey is the exact key the limit applies to.
+	APIKey string
+	// RequestsPerMinute caps requests started per minute; <= 0 means unlimited.
+	RequestsPerMinute int
+	// TokensPerMinute caps tokens per minute, charged at the request's estimate and
+	// corrected to measured usage when the callback reports it; <= 0 means unlimited.
+	TokensPerMinute int64
+}
+
+// RateLimitStats reports the budget a rate-limited key has left. A remaining value is -1
+// when that dimension is unlimited, and negative otherwise when measured usage overran
+// the estimate charged.
+type RateLimitStats struct {
+	RequestsPerMinute int   `json:"requests_per_minute"`
+	RemainingRequests int64 `json:"remaining_requests"`
+	TokensPerMinute   int64 `json:"tokens_per_minute"`
+	RemainingTokens   int64 `json:"remaining_tokens"`
+}
+
+// tokenBucket holds up to capacity units and refills continuously at capacity per minute.
+type tokenBucket struct {
+	capacity float64
+	level    float64
+	last     time.Time
+}
+
+func newTokenBucket(perMinute float64, now time.Time) *tokenBucket {
+	return &tokenBucket{capacity: perMinute, level: perMinute, last: now}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.level = math.Min(b.capacity, b.level+b.capacity*elapsed.Minutes())
+		b.last = now
+	}
+}
+
+// wait returns how long until n units are available, 0 when they are now. A cost above
+// the capacity is admitted once the bucket is full so it cannot block the key forever.
+func (b *tokenBucket) wait(n float64, now time.Time) time.Duration {
+	b.refill(now)
+	need := math.Min(n, b.capacity)
+	if b.level >= need {
+		return 0
+	}
+	return time.Duration((need - b.level) / b.capacity * float64(time.Minute))
+}
+
+// keyLimiter enforces one KeyRateLimit. Its buckets are nil for unlimited dimensions.
+type keyLimiter struct {
+	requests *tokenBucket
+	tokens   *tokenBucket
+	limit    KeyRateLimit
+}
+
+func newKeyLimiter(limit KeyRateLimit, now time.Time) *keyLimiter {
+	l := &keyLimiter{limit: limit}
+	if limit.RequestsPerMinute > 0 {
+		l.requests = newTokenBucket(float64(limit.RequestsPerMinute), now)
+	}
+	if limit.TokensPerMinute > 0 {
+		l.tokens = newTokenBucket(float64(limit.TokensPerMinute), now)
+	}
+	return l
+}
+
+// wait returns how long until a request estimated at tokens fits the budget.
+func (l *keyLimiter) wait(tokens int64, now time.Time) time.Duration {
+	var d time.Duration
+	if l.requests != nil {
+		d = l.requests.wait(1, now)
+	}
+	if l.tokens != nil {
+		d = max(d, l.tokens.wait(float64(tokens), now))
+	}
+	return d
+}
+
+// take charges a dequeued request to the budget.
+func (l *keyLimiter) take(tokens int64) {
+	if l.requests != nil {
+		l.requests.level--
+	}
+	if l.tokens != nil {
+		l.tokens.level -= float64(tokens)
+	}
+}
+
+// settle charges the difference between a request's measured and estimated tokens.
+func (l *keyLimiter) settle(delta int64) {
+	if l.tokens != nil {
+		l.tokens.level -= float64(delta)
+	}
+}
+
+func (l *keyLimiter) stats(now time.Time) *RateLimitStats {
+	st := &RateLimitStats{
+		RequestsPerMinute: l.limit.RequestsPerMinute,
+		RemainingRequests: -1,
+		TokensPerMinute:   l.limit.TokensPerMinute,
+		RemainingTokens:   -1,
+	}
+	if l.requests != nil {
+		l.requests.refill(now)
+		st.RemainingRequests = int64(math.Floor(l.requests.level))
+	}
+	if l.tokens != nil {
+		l.tokens.refill(now)
+		st.RemainingTokens = int64(math.Floor(l.tokens.level))
+	}
+	return st
+}
@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimit_SkipsLimitedKeyAndSharesSpareCapacity(t *testing.T) {
+	for _, mode := range []SchedulingMode{ModeWFQ, ModeDRR} {
+		t.Run(string(mode), func(t *testing.T) {
+			cfg := DefaultSchedulerConfig()
+			cfg.Mode = mode
+			cfg.RateLimits = []KeyRateLimit{{APIKey: "limited", RequestsPerMinute: 2}}
+			fs := NewFairScheduler(cfg)
+
+			served := map[string]int{}
+			var results []<-chan error
+			for i := 0; i < 5; i++ {
+				for _, key := range []string{"limited", "free"} {
+					key := key
+					results = append(results, enqueueAsync(t, fs, key, nil, 10, func() error {
+						served[key]++
+						return nil
+					}))
+				}
+			}
+
+			for fs.ExecuteNext() {
+			}
+			if served["limited"] != 2 || served["free"] != 5 {
+				t.Fatalf("served = %v, want the limited key capped at 2 and the free key drained", served)
+			}
+
+			stats := fs.Stats()
+			if pending := stats.Queues["limited"].PendingRequests; pending != 3 {
+				t.Fatalf("limited pending = %d, want 3 waiting for budget", pending)
+			}
+			rl := stats.Queues["limited"].RateLimit
+			if rl == nil || rl.RemainingRequests != 0 || rl.RemainingTokens != -1 {
+				t.Fatalf("limited rate limit stats = %+v, want 0 requests left and unlimited tokens", rl)
+			}
+			if stats.Queues["free"].RateLimit != nil {
+				t.Fatal("a key without a limit reports rate limit stats")
+			}
+
+			// Stopping releases the requests still waiting for budget.
+			fs.Stop()
+			for _, errCh := range results {
+				<-errCh
+			}
+		})
+	}
+}
+
+func TestRateLimit_TokensPerMinute(t *testing.T) {
+	cfg := DefaultSchedulerConfig()
+	cfg.RateLimits = []KeyRateLimit{{APIKey: "key", TokensPerMinute: 100}}
+	fs := NewFairScheduler(cfg)
+	defer fs.Stop()
+
+	ran := 0
+	enqueueAsync(t, fs, "key", nil, 60, func() error { ran++; return nil })
+	enqueueAsync(t, fs, "key", nil, 60, func() error { ran++; return nil })
+	for fs.ExecuteNext() {
+	}
+	if ran != 1 {
+		t.Fatalf("ran %d requests, want 1 within a 100 token budget", ran)
+	}
+	if rl := fs.Stats().Queues["key"].RateLimit; rl == nil || rl.RemainingTokens != 40 {
+		t.Fatalf("rate limit stats = %+v, want 40 tokens left", rl)
+	}
+}
+
+func TestRateLimit_SettlesMeasuredTokens(t *testing.T) {
+	cfg := DefaultSchedulerConfig()
+	cfg.RateLimits = []KeyRateLimit{{APIKey: "key", TokensPerMinute: 1000}}
+	fs := NewFairScheduler(cfg)
+	fs.Start(context.Background(), 1)
+	defer fs.Stop()
+
+	if err := fs.ScheduleMeasured(context.Background(), "key", 100, func() (int64, error) { return 700, nil }); err != nil {
+		t.Fatalf("ScheduleMeasured: %v", err)
+	}
+	if rl := fs.Stats().Queues["key"].RateLimit; rl == nil || rl.RemainingTokens != 300 {
+		t.Fatalf("rate limit stats = %+v, want 300 tokens left after measured usage", rl)
+	}
+}
+
+func TestRateLimit_WorkerWakesWhenBudgetRefills(t *testing.T) {
+	cfg := DefaultSchedulerConfig()
+	// 6000 tokens per minute refill 100 tokens per second.
+	cfg.RateLimits = []KeyRateLimit{{APIKey: "key", TokensPerMinute: 6000}}
+	fs := NewFairScheduler(cfg)
+	fs.Start(context.Background(), 1)
+	defer fs.Stop()
+
+	if err := fs.Schedule(context.Background(), "key", 6000, func() error { return nil }); err != nil {
+		t.Fatalf("Schedule: %v", err)
+	}
+	start := time.Now()
+	if err := fs.Schedule(context.Background(), "key", 50, func() error { return nil }); err != nil {
+		t.Fatalf("Schedule: %v", err)
+	}
+	if waited := time.Since(start); waited < 300*time.Millisecond || waited > 2*time.Second {
+		t.Fatalf("second request waited %v, want about 500ms for 50 tokens to refill", waited)
+	}
+}
@@ -0,0 +1,147 @@
+// Package scheduler provides fair scheduling and weighted queuing for API requests.
+// This file implements the process-wide retry budget.
+package scheduler
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
+)
+
+// RetryBudgetConfig configures the global retry budget.
+type RetryBudgetConfig struct {
+	// MaxRetries is how many retries the whole process may make per Window; <= 0 disables the budget.
+	MaxRetries int
+	// Window is the period over which MaxRetries refills; <= 0 defaults to one minute.
+	Window time.Duration
+}
+
+// RetryBudget caps retries across all requests so that many requests failing at once cannot
+// multiply the load on a struggling upstream. It is a token bucket holding up to MaxRetries
+// tokens that refills continuously at MaxRetries per Window: every retry takes a token, and
+// once the bucket is empty callers fail fast with the error they already have instead of
+// retrying. First attempts never consult the budget.
+type RetryBudget struct {
+	mu         sync.Mutex
+	capacity   float64 // 0 when unlimited
+	refillRate float64 // tokens per nanosecond
+	tokens     float64
+	last       time.Time
+	now        func() time.Time
+
+	allowed   atomic.Uint64
+	exhausted atomic.Uint64
+}
+
+// NewRetryBudget creates a retry budget from cfg, starting full.
+func NewRetryBudget(cfg RetryBudgetConfig) *RetryBudget {
+	rb := &RetryBudget{now: time.Now}
+	if cfg.MaxRetries > 0 {
+		window := cfg.Window
+		if window <= 0 {
+			window = time.Minute
+		}
+		rb.capacity = float64(cfg.MaxRetries)
+		rb.refillRate = rb.capacity / float64(window)
+		rb.tokens = rb.capacity
+		rb.last = rb.now()
+	}
+	return rb
+}
+
+// Allow reports whether one more retry may be made, taking a token from the budget if so.
+// It always returns true when the budget is unlimited.
+func (rb *RetryBudget) Allow() bool {
+	if rb == nil {
+		return true
+	}
+	if rb.capacity == 0 {
+		rb.allowed.Add(1)
+		rb.publish(true, -1)
+		return true
+	}
+
+	rb.mu.Lock()
+	rb.refill()
+	ok := rb.tokens >= 1
+	if ok {
+		rb.tokens--
+	}
+	available := int64(rb.tokens)
+	rb.mu.Unlock()
+
+	if ok {
+		rb.allowed.Add(1)
+	} else {
+		rb.exhausted.Add(1)
+	}
+	rb.publish(ok, available)
+	return ok
+}
+
+// refill adds the tokens accrued since the last call. rb.mu must be held.
+func (rb *RetryBudget) refill() {
+	now := rb.now()
+	if elapsed := now.Sub(rb.last); elapsed > 0 {
+		rb.tokens += float64(elapsed) * rb.refillRate
+		if rb.tokens > rb.capacity {
+			rb.tokens = rb.capacity
+		}
+	}
+	rb.last = now
+}
+
+func (rb *RetryBudget) publish(allowed bool, available int64) {
+	observability.GetMetricsRecorder().RecordRetryBudget(allowed, available)
+}
+
+// Stats returns retry budget statistics.
+func (rb *RetryBudget) Stats() RetryBudgetStats {
+	stats := RetryBudgetStats{
+		Allowed:   rb.allowed.Load(),
+		Exhausted: rb.exhausted.Load(),
+		Available: -1,
+	}
+	if rb.capacity > 0 {
+		rb.mu.Lock()
+		rb.refill()
+		stats.Available = int64(rb.tokens)
+		rb.mu.Unlock()
+		stats.MaxRetries = int(rb.capacity)
+	}
+	return stats
+}
+
+// RetryBudgetStats holds retry budget statistics. MaxRetries is 0 and Available is -1 when
+// the budget is unlimited.
+type RetryBudgetStats struct {
+	MaxRetries int    `json:"max_retries"`
+	Available  int64  `json:"available"`
+	Allowed    uint64 `json:"allowed"`
+	Exhausted  uint64 `json:"exhausted"`
+}
+
+// Global retry budget instance
+var (
+	globalRetryBudget   = NewRetryBudget(RetryBudgetConfig{})
+	globalRetryBudgetMu sync.RWMutex
+)
+
+// GetRetryBudget returns the global retry budget. It is unlimited until InitRetryBudget
+// installs a configured one.
+func GetRetryBudget() *RetryBudget {
+	globalRetryBudgetMu.RLock()
+	defer globalRetryBudgetMu.RUnlock()
+	return globalRetryBudget
+}
+
+// InitRetryBudget replaces the global retry budget with a full one built from cfg.
+func InitRetryBudget(cfg RetryBudgetConfig) *RetryBudget {
+	rb := NewRetryBudget(cfg)
+	globalRetryBudgetMu.Lock()
+	defer globalRetryBudgetMu.Unlock()
+	globalRetryBudget = rb
+	return rb
+}
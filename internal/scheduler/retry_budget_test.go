@@ -0,0 +1,47 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryBudget_ExhaustsAndRefills(t *testing.T) {
+	now := time.Unix(0, 0)
+	rb := NewRetryBudget(RetryBudgetConfig{MaxRetries: 3, Window: 3 * time.Second})
+	rb.now = func() time.Time { return now }
+	rb.last = now
+
+	for i := 0; i < 3; i++ {
+		if !rb.Allow() {
+			t.Fatalf("retry %d denied by a fresh budget", i)
+		}
+	}
+	if rb.Allow() {
+		t.Fatal("expected the budget to deny a retry once exhausted")
+	}
+
+	now = now.Add(time.Second)
+	if !rb.Allow() {
+		t.Fatal("expected one retry after a third of the window refilled")
+	}
+	if rb.Allow() {
+		t.Fatal("expected the refilled retry to be spent")
+	}
+
+	now = now.Add(time.Hour)
+	if stats := rb.Stats(); stats.Available != 3 || stats.Allowed != 4 || stats.Exhausted != 2 {
+		t.Fatalf("stats = %+v, want a full budget of 3 after 4 allowed and 2 exhausted", stats)
+	}
+}
+
+func TestRetryBudget_UnlimitedByDefault(t *testing.T) {
+	rb := NewRetryBudget(RetryBudgetConfig{})
+	for i := 0; i < 1000; i++ {
+		if !rb.Allow() {
+			t.Fatalf("unlimited budget denied retry %d", i)
+		}
+	}
+	if stats := rb.Stats(); stats.Available != -1 || stats.MaxRetries != 0 {
+		t.Fatalf("stats = %+v, want an unlimited budget", stats)
+	}
+}
@@ -49,6 +49,9 @@ type ToolCall struct {
 	Name      string `json:"name"`
 	Arguments string `json:"arguments"`
 	Index     int    `json:"index,omitempty"`
+	// Malformed marks a streamed call whose arguments exceeded the accumulator limit;
+	// Arguments is empty and the call should not be executed.
+	Malformed bool `json:"malformed,omitempty"`
 }
 
 // ToolResult represents the result of executing a tool.
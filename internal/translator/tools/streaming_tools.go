@@ -2,6 +2,8 @@
 package tools
 
 import (
+	"bytes"
+	"sort"
 	"sync"
 
 	"github.com/tidwall/gjson"
@@ -17,8 +19,12 @@ type StreamingToolAccumulator struct {
 	provider     string
 	partialCalls map[int]*PartialToolCall
 	completeCalls []ToolCall
+	maxArgsBytes int
 }
 
+// DefaultMaxToolArgsBytes caps the accumulated arguments of a single streamed tool call.
+const DefaultMaxToolArgsBytes = 1 << 20
+
 // PartialToolCall represents an incomplete tool call being accumulated.
 type PartialToolCall struct {
 	ID            string
@@ -26,6 +32,9 @@ type PartialToolCall struct {
 	Name          string
 	ArgumentsJSON string
 	Complete      bool
+	// Malformed is set when the arguments exceeded the accumulator's size limit. The
+	// arguments collected so far are discarded and later fragments are ignored.
+	Malformed bool
 }
 
 // NewStreamingToolAccumulator creates a new accumulator for the given provider.
@@ -33,7 +42,36 @@ func NewStreamingToolAccumulator(provider string) *StreamingToolAccumulator {
 	return &StreamingToolAccumulator{
 		provider:     provider,
 		partialCalls: make(map[int]*PartialToolCall),
+		maxArgsBytes: DefaultMaxToolArgsBytes,
+	}
+}
+
+// SetMaxToolArgsBytes sets the per-call arguments limit; n <= 0 restores DefaultMaxToolArgsBytes.
+func (sta *StreamingToolAccumulator) SetMaxToolArgsBytes(n int) {
+	sta.mu.Lock()
+	defer sta.mu.Unlock()
+	if n <= 0 {
+		n = DefaultMaxToolArgsBytes
 	}
+	sta.maxArgsBytes = n
+}
+
+// appendArguments adds an arguments fragment to partial, marking it malformed instead of
+// growing past the size limit. Callers must hold sta.mu.
+func (sta *StreamingToolAccumulator) appendArguments(partial *PartialToolCall, fragment string) {
+	if partial.Malformed || fragment == "" {
+		return
+	}
+	limit := sta.maxArgsBytes
+	if limit <= 0 {
+		limit = DefaultMaxToolArgsBytes
+	}
+	if len(partial.ArgumentsJSON)+len(fragment) > limit {
+		partial.Malformed = true
+		partial.ArgumentsJSON = ""
+		return
+	}
+	partial.ArgumentsJSON += fragment
 }
 
 // ProcessChunk processes a streaming chunk and returns any newly completed tool calls.
@@ -80,42 +118,73 @@ func (sta *StreamingToolAccumulator) Reset() {
 	sta.completeCalls = nil
 }
 
-// Finalize marks all partial calls as complete and returns them.
+// Finalize marks all partial calls as complete and returns every completed call.
 func (sta *StreamingToolAccumulator) Finalize() []ToolCall {
 	sta.mu.Lock()
 	defer sta.mu.Unlock()
 
-	for _, pc := range sta.partialCalls {
-		if !pc.Complete {
-			pc.Complete = true
-			sta.completeCalls = append(sta.completeCalls, ToolCall{
-				ID:        pc.ID,
-				Name:      pc.Name,
-				Arguments: pc.ArgumentsJSON,
-				Index:     pc.Index,
-			})
+	sta.completePending()
+	return sta.completeCalls
+}
+
+// completePending completes every populated partial call in upstream index order and
+// returns the newly completed calls. Callers must hold sta.mu.
+func (sta *StreamingToolAccumulator) completePending() []ToolCall {
+	var completed []ToolCall
+	for _, pc := range sta.orderedPartials() {
+		if pc.Complete {
+			continue
+		}
+		pc.Complete = true
+		call := ToolCall{
+			ID:        pc.ID,
+			Name:      pc.Name,
+			Arguments: pc.ArgumentsJSON,
+			Index:     pc.Index,
+			Malformed: pc.Malformed,
 		}
+		completed = append(completed, call)
+		sta.completeCalls = append(sta.completeCalls, call)
 	}
+	return completed
+}
 
-	return sta.completeCalls
+// orderedPartials returns the partial calls sorted by upstream index. Indices that only
+// ever appeared without an id, name or arguments are placeholders for sparse or
+// out-of-order streams and are omitted. Callers must hold sta.mu.
+func (sta *StreamingToolAccumulator) orderedPartials() []*PartialToolCall {
+	partials := make([]*PartialToolCall, 0, len(sta.partialCalls))
+	for _, pc := range sta.partialCalls {
+		if pc.ID == "" && pc.Name == "" && pc.ArgumentsJSON == "" {
+			continue
+		}
+		partials = append(partials, pc)
+	}
+	sort.Slice(partials, func(i, j int) bool { return partials[i].Index < partials[j].Index })
+	return partials
 }
 
 // processOpenAIChunk processes OpenAI streaming delta format.
 // OpenAI sends: choices[].delta.tool_calls[].{index, id, function.name, function.arguments}
 // Arguments are sent character by character across multiple chunks.
 func (sta *StreamingToolAccumulator) processOpenAIChunk(chunk []byte) []ToolCall {
-	delta := gjson.GetBytes(chunk, "choices.0.delta")
-	if !delta.Exists() {
-		return nil
+	// The finish_reason chunk usually carries an empty delta, so tool_calls are optional here.
+	toolCalls := gjson.GetBytes(chunk, "choices.0.delta.tool_calls")
+	if toolCalls.IsArray() {
+		sta.mergeOpenAIDeltas(toolCalls)
 	}
 
-	toolCalls := delta.Get("tool_calls")
-	if !toolCalls.Exists() || !toolCalls.IsArray() {
-		return nil
+	// Check finish_reason to determine if tool calls are complete
+	finishReason := gjson.GetBytes(chunk, "choices.0.finish_reason")
+	if finishReason.Exists() && finishReason.String() == "tool_calls" {
+		return sta.completePending()
 	}
 
-	var completed []ToolCall
+	return nil
+}
 
+// mergeOpenAIDeltas folds one delta.tool_calls array into the partial calls.
+func (sta *StreamingToolAccumulator) mergeOpenAIDeltas(toolCalls gjson.Result) {
 	toolCalls.ForEach(func(_, tc gjson.Result) bool {
 		index := int(tc.Get("index").Int())
 
@@ -138,31 +207,11 @@ func (sta *StreamingToolAccumulator) processOpenAIChunk(chunk []byte) []ToolCall
 
 		// Append to arguments (incremental)
 		if args := tc.Get("function.arguments"); args.Exists() {
-			partial.ArgumentsJSON += args.String()
+			sta.appendArguments(partial, args.String())
 		}
 
 		return true
 	})
-
-	// Check finish_reason to determine if tool calls are complete
-	finishReason := gjson.GetBytes(chunk, "choices.0.finish_reason")
-	if finishReason.Exists() && finishReason.String() == "tool_calls" {
-		for _, pc := range sta.partialCalls {
-			if !pc.Complete {
-				pc.Complete = true
-				call := ToolCall{
-					ID:        pc.ID,
-					Name:      pc.Name,
-					Arguments: pc.ArgumentsJSON,
-					Index:     pc.Index,
-				}
-				completed = append(completed, call)
-				sta.completeCalls = append(sta.completeCalls, call)
-			}
-		}
-	}
-
-	return completed
 }
 
 // processClaudeChunk processes Claude streaming event format.
@@ -217,7 +266,7 @@ func (sta *StreamingToolAccumulator) processClaudeBlockDelta(chunk []byte) []Too
 		return nil
 	}
 
-	partial.ArgumentsJSON += delta.Get("partial_json").String()
+	sta.appendArguments(partial, delta.Get("partial_json").String())
 	return nil
 }
 
@@ -240,6 +289,7 @@ func (sta *StreamingToolAccumulator) processClaudeBlockStop(chunk []byte) []Tool
 		Name:      partial.Name,
 		Arguments: partial.ArgumentsJSON,
 		Index:     partial.Index,
+		Malformed: partial.Malformed,
 	}
 	sta.completeCalls = append(sta.completeCalls, call)
 
@@ -284,6 +334,55 @@ func (sta *StreamingToolAccumulator) processGeminiChunk(chunk []byte) []ToolCall
 	return completed
 }
 
+// DetectProvider guesses which provider's streaming format chunk uses from its shape:
+// OpenAI chunks carry "choices", Claude events a known "type", and Gemini chunks
+// "candidates" (optionally wrapped in "response" by the CLI API). SSE framing is ignored.
+// It returns "" when the shape is not recognized.
+func DetectProvider(chunk []byte) string {
+	payload := ssePayload(chunk)
+	if len(payload) == 0 || !gjson.ValidBytes(payload) {
+		return ""
+	}
+	root := gjson.ParseBytes(payload)
+	switch {
+	case root.Get("choices").IsArray():
+		return ProviderOpenAI
+	case root.Get("candidates").IsArray(), root.Get("response.candidates").IsArray():
+		return ProviderGemini
+	}
+	switch root.Get("type").String() {
+	case "message_start", "message_delta", "message_stop",
+		"content_block_start", "content_block_delta", "content_block_stop", "ping":
+		return ProviderClaude
+	}
+	return ""
+}
+
+// IsToolCallChunkAuto reports whether chunk carries tool call data in any known provider
+// format, for callers such as fan-out and normalization that do not know the provider.
+func IsToolCallChunkAuto(chunk []byte) bool {
+	provider := DetectProvider(chunk)
+	if provider == "" {
+		return false
+	}
+	payload := ssePayload(chunk)
+	if provider == ProviderGemini && !gjson.GetBytes(payload, "candidates").Exists() {
+		payload = []byte(gjson.GetBytes(payload, "response").Raw)
+	}
+	return IsStreamingToolCallChunk(payload, provider)
+}
+
+// ssePayload returns the first SSE data line of chunk, or chunk itself when it is not
+// SSE framed (e.g. "event: ...\ndata: {...}" yields the JSON after "data:").
+func ssePayload(chunk []byte) []byte {
+	for _, line := range bytes.Split(chunk, []byte("\n")) {
+		if rest, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:")); ok {
+			return bytes.TrimSpace(rest)
+		}
+	}
+	return bytes.TrimSpace(chunk)
+}
+
 // IsStreamingToolCallChunk checks if an SSE chunk contains tool call data.
 func IsStreamingToolCallChunk(chunk []byte, provider string) bool {
 	switch provider {
@@ -299,7 +398,7 @@ func IsStreamingToolCallChunk(chunk []byte, provider string) bool {
 		}
 		return false
 	case ProviderGemini:
-		return gjson.GetBytes(chunk, "candidates.0.content.parts.0.functionCall").Exists()
+		return len(gjson.GetBytes(chunk, "candidates.0.content.parts.#.functionCall").Array()) > 0
 	default:
 		return false
 	}
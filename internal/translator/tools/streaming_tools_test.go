@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestStreamingToolAccumulator_OpenAISparseIndices(t *testing.T) {
+	acc := NewStreamingToolAccumulator(ProviderOpenAI)
+	chunks := []string{
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":2,"id":"call_b","function":{"name":"second","arguments":"{}"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":4}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_a","function":{"name":"first","arguments":"{}"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+	}
+	var completed []ToolCall
+	for _, chunk := range chunks {
+		completed = append(completed, acc.ProcessChunk([]byte(chunk))...)
+	}
+
+	if len(completed) != 2 {
+		t.Fatalf("completed %d calls, want 2: %+v", len(completed), completed)
+	}
+	if completed[0].ID != "call_a" || completed[1].ID != "call_b" {
+		t.Fatalf("calls not in index order: %+v", completed)
+	}
+	if got := acc.Finalize(); len(got) != 2 {
+		t.Fatalf("Finalize returned %d calls, want 2: %+v", len(got), got)
+	}
+}
+
+func TestStreamingToolAccumulator_OversizedArgumentsMarkedMalformed(t *testing.T) {
+	acc := NewStreamingToolAccumulator(ProviderOpenAI)
+	acc.SetMaxToolArgsBytes(64)
+
+	acc.ProcessChunk([]byte(`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_big","function":{"name":"flood","arguments":""}},{"index":1,"id":"call_ok","function":{"name":"small","arguments":"{}"}}]}}]}`))
+	fragment := `{"chunk":"` + strings.Repeat("x", 20) + `"`
+	for i := 0; i < 100; i++ {
+		acc.ProcessChunk([]byte(`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":` + strconv.Quote(fragment) + `}}]}}]}`))
+		for _, pc := range acc.GetPartialCalls() {
+			if len(pc.ArgumentsJSON) > 64 {
+				t.Fatalf("call %s grew to %d bytes past the 64 byte limit", pc.ID, len(pc.ArgumentsJSON))
+			}
+		}
+	}
+
+	calls := acc.ProcessChunk([]byte(`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`))
+	if len(calls) != 2 {
+		t.Fatalf("completed %d calls, want 2: %+v", len(calls), calls)
+	}
+	if !calls[0].Malformed || calls[0].Arguments != "" || calls[0].ID != "call_big" {
+		t.Errorf("oversized call should be malformed with no arguments: %+v", calls[0])
+	}
+	if calls[1].Malformed || calls[1].Arguments != "{}" {
+		t.Errorf("small call should be untouched: %+v", calls[1])
+	}
+}
+
+func TestStreamingToolAccumulator_OversizedClaudeInput(t *testing.T) {
+	acc := NewStreamingToolAccumulator(ProviderClaude)
+	acc.SetMaxToolArgsBytes(16)
+
+	acc.ProcessChunk([]byte(`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"flood"}}`))
+	for i := 0; i < 10; i++ {
+		acc.ProcessChunk([]byte(`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"a\":\"bbbb"}}`))
+	}
+	calls := acc.ProcessChunk([]byte(`{"type":"content_block_stop","index":1}`))
+	if len(calls) != 1 || !calls[0].Malformed || calls[0].Arguments != "" {
+		t.Fatalf("expected one malformed call, got %+v", calls)
+	}
+}
+
+func TestStreamingToolAccumulator_FinalizeWithoutFinishReason(t *testing.T) {
+	acc := NewStreamingToolAccumulator(ProviderOpenAI)
+	acc.ProcessChunk([]byte(`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_a","function":{"name":"lookup","arguments":"{\"q\":"}}]}}]}`))
+	acc.ProcessChunk([]byte(`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"1}"}}]}}]}`))
+	if got := acc.GetCompleteCalls(); len(got) != 0 {
+		t.Fatalf("calls completed before the stream ended: %+v", got)
+	}
+
+	calls := acc.Finalize()
+	if len(calls) != 1 || calls[0].ID != "call_a" || calls[0].Arguments != `{"q":1}` {
+		t.Fatalf("Finalize did not complete the OpenAI partial: %+v", calls)
+	}
+	if again := acc.Finalize(); len(again) != 1 {
+		t.Fatalf("Finalize must be idempotent, got %+v", again)
+	}
+}
+
+func TestDetectProviderAndToolCallChunkAuto(t *testing.T) {
+	cases := []struct {
+		name     string
+		chunk    string
+		provider string
+		toolCall bool
+	}{
+		{"openai tool delta", `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"f","arguments":""}}]}}]}`, ProviderOpenAI, true},
+		{"openai text delta", `{"choices":[{"index":0,"delta":{"content":"hello"}}]}`, ProviderOpenAI, false},
+		{"claude tool_use start", "event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"tool_use\",\"id\":\"toolu_1\",\"name\":\"f\"}}\n\n", ProviderClaude, true},
+		{"claude input_json delta", `{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"a\""}}`, ProviderClaude, true},
+		{"claude text delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hi"}}`, ProviderClaude, false},
+		{"gemini function call", `{"candidates":[{"content":{"role":"model","parts":[{"text":"ok"},{"functionCall":{"name":"f","args":{}}}]}}]}`, ProviderGemini, true},
+		{"gemini cli wrapped", `data: {"response":{"candidates":[{"content":{"parts":[{"functionCall":{"name":"f","args":{}}}]}}]}}`, ProviderGemini, true},
+		{"gemini text", `{"candidates":[{"content":{"parts":[{"text":"hello"}]}}]}`, ProviderGemini, false},
+		{"done sentinel", `data: [DONE]`, "", false},
+		{"unrelated json", `{"object":"list","data":[]}`, "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := DetectProvider([]byte(tc.chunk)); got != tc.provider {
+				t.Errorf("DetectProvider = %q, want %q", got, tc.provider)
+			}
+			if got := IsToolCallChunkAuto([]byte(tc.chunk)); got != tc.toolCall {
+				t.Errorf("IsToolCallChunkAuto = %v, want %v", got, tc.toolCall)
+			}
+		})
+	}
+}
@@ -29,6 +29,7 @@ type ModelBucket struct {
 	InputTokens  int64   `json:"input_tokens"`
 	OutputTokens int64   `json:"output_tokens"`
 	AvgLatency   float64 `json:"avg_latency_ms"`
+	FailureCount int64   `json:"failure_count,omitempty"`
 }
 
 // HistoricalMetrics maintains time-series metrics data with multiple granularities.
@@ -71,6 +72,7 @@ type modelAccumulator struct {
 	outputTokens int64
 	latencySum   float64
 	latencyCount int64
+	failures     int64
 }
 
 var (
@@ -92,33 +94,51 @@ func NewHistoricalMetrics(persistPath string) *HistoricalMetrics {
 	hm := &HistoricalMetrics{
 		persistPath: persistPath,
 	}
+	hm.resetBuckets(time.Now())
+
+	// Try to load persisted data
+	if persistPath != "" {
+		hm.load()
+	}
+
+	return hm
+}
+
+// Reset zeroes all buckets and the in-progress accumulator.
+func (hm *HistoricalMetrics) Reset() {
+	if hm == nil {
+		return
+	}
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	hm.resetBuckets(time.Now())
+}
+
+// resetBuckets initializes all buckets with empty model maps. Callers must hold hm.mu
+// or have exclusive access.
+func (hm *HistoricalMetrics) resetBuckets(now time.Time) {
+	hm.currentSecond.requests = 0
+	hm.currentSecond.tokens = 0
+	hm.currentSecond.inputTokens = 0
+	hm.currentSecond.outputTokens = 0
+	hm.currentSecond.latencySum = 0
+	hm.currentSecond.latencyCount = 0
+	hm.currentSecond.successCount = 0
+	hm.currentSecond.failureCount = 0
 	hm.currentSecond.byModel = make(map[string]*modelAccumulator)
 
-	// Initialize all buckets with empty model maps
-	now := time.Now()
 	for i := range hm.SecondBuckets {
-		hm.SecondBuckets[i].ByModel = make(map[string]ModelBucket)
-		hm.SecondBuckets[i].Timestamp = now.Add(-time.Duration(60-i) * time.Second)
+		hm.SecondBuckets[i] = MetricBucket{Timestamp: now.Add(-time.Duration(60-i) * time.Second), ByModel: make(map[string]ModelBucket)}
 	}
 	for i := range hm.MinuteBuckets {
-		hm.MinuteBuckets[i].ByModel = make(map[string]ModelBucket)
-		hm.MinuteBuckets[i].Timestamp = now.Add(-time.Duration(60-i) * time.Minute)
+		hm.MinuteBuckets[i] = MetricBucket{Timestamp: now.Add(-time.Duration(60-i) * time.Minute), ByModel: make(map[string]ModelBucket)}
 	}
 	for i := range hm.HourBuckets {
-		hm.HourBuckets[i].ByModel = make(map[string]ModelBucket)
-		hm.HourBuckets[i].Timestamp = now.Add(-time.Duration(24-i) * time.Hour)
+		hm.HourBuckets[i] = MetricBucket{Timestamp: now.Add(-time.Duration(24-i) * time.Hour), ByModel: make(map[string]ModelBucket)}
 	}
 	for i := range hm.DayBuckets {
-		hm.DayBuckets[i].ByModel = make(map[string]ModelBucket)
-		hm.DayBuckets[i].Timestamp = now.Add(-time.Duration(30-i) * 24 * time.Hour)
+		hm.DayBuckets[i] = MetricBucket{Timestamp: now.Add(-time.Duration(30-i) * 24 * time.Hour), ByModel: make(map[string]ModelBucket)}
 	}
-
-	// Try to load persisted data
-	if persistPath != "" {
-		hm.load()
-	}
-
-	return hm
 }
 
 // Record records a request to the historical metrics.
@@ -161,6 +181,9 @@ func (hm *HistoricalMetrics) Record(model string, inputTokens, outputTokens int6
 		acc.outputTokens += outputTokens
 		acc.latencySum += latencyMs
 		acc.latencyCount++
+		if !success {
+			acc.failures++
+		}
 	}
 }
 
@@ -217,37 +240,14 @@ func (hm *HistoricalMetrics) tick() {
 			InputTokens:  acc.inputTokens,
 			OutputTokens: acc.outputTokens,
 			AvgLatency:   modelAvgLatency,
+			FailureCount: acc.failures,
 		}
 	}
 
 	hm.SecondBuckets[idx] = bucket
 
-	// Persist to database if available
-	if db := GetMetricsDB(); db != nil && db.IsEnabled() {
-		modelMetrics := make(map[string]ModelMetricRecord)
-		for model, mb := range bucket.ByModel {
-			modelMetrics[model] = ModelMetricRecord{
-				ModelName:    model,
-				Requests:     mb.Requests,
-				Tokens:       mb.Tokens,
-				InputTokens:  mb.InputTokens,
-				OutputTokens: mb.OutputTokens,
-				AvgLatencyMs: mb.AvgLatency,
-			}
-		}
-		db.Record(MetricRecord{
-			Timestamp:    bucket.Timestamp,
-			Granularity:  "second",
-			Requests:     bucket.Requests,
-			Tokens:       bucket.Tokens,
-			InputTokens:  bucket.InputTokens,
-			OutputTokens: bucket.OutputTokens,
-			AvgLatencyMs: bucket.AvgLatency,
-			SuccessCount: bucket.SuccessCount,
-			FailureCount: bucket.FailureCount,
-			ModelMetrics: modelMetrics,
-		})
-	}
+	// Hand the second bucket to the registered sinks
+	recordToSinks(bucket, "second")
 
 	// Reset current second accumulator
 	hm.currentSecond.requests = 0
@@ -292,32 +292,8 @@ func (hm *HistoricalMetrics) rollMinuteBucket(now time.Time, currentMinute int64
 	bucket.Timestamp = now
 	hm.MinuteBuckets[idx] = bucket
 
-	// Persist minute bucket to database
-	if db := GetMetricsDB(); db != nil && db.IsEnabled() {
-		modelMetrics := make(map[string]ModelMetricRecord)
-		for model, mb := range bucket.ByModel {
-			modelMetrics[model] = ModelMetricRecord{
-				ModelName:    model,
-				Requests:     mb.Requests,
-				Tokens:       mb.Tokens,
-				InputTokens:  mb.InputTokens,
-				OutputTokens: mb.OutputTokens,
-				AvgLatencyMs: mb.AvgLatency,
-			}
-		}
-		db.Record(MetricRecord{
-			Timestamp:    bucket.Timestamp,
-			Granularity:  "minute",
-			Requests:     bucket.Requests,
-			Tokens:       bucket.Tokens,
-			InputTokens:  bucket.InputTokens,
-			OutputTokens: bucket.OutputTokens,
-			AvgLatencyMs: bucket.AvgLatency,
-			SuccessCount: bucket.SuccessCount,
-			FailureCount: bucket.FailureCount,
-			ModelMetrics: modelMetrics,
-		})
-	}
+	// Hand the minute bucket to the registered sinks
+	recordToSinks(bucket, "minute")
 }
 
 func (hm *HistoricalMetrics) rollHourBucket(now time.Time, currentHour int64) {
@@ -326,32 +302,8 @@ func (hm *HistoricalMetrics) rollHourBucket(now time.Time, currentHour int64) {
 	bucket.Timestamp = now
 	hm.HourBuckets[idx] = bucket
 
-	// Persist hour bucket to database
-	if db := GetMetricsDB(); db != nil && db.IsEnabled() {
-		modelMetrics := make(map[string]ModelMetricRecord)
-		for model, mb := range bucket.ByModel {
-			modelMetrics[model] = ModelMetricRecord{
-				ModelName:    model,
-				Requests:     mb.Requests,
-				Tokens:       mb.Tokens,
-				InputTokens:  mb.InputTokens,
-				OutputTokens: mb.OutputTokens,
-				AvgLatencyMs: mb.AvgLatency,
-			}
-		}
-		db.Record(MetricRecord{
-			Timestamp:    bucket.Timestamp,
-			Granularity:  "hour",
-			Requests:     bucket.Requests,
-			Tokens:       bucket.Tokens,
-			InputTokens:  bucket.InputTokens,
-			OutputTokens: bucket.OutputTokens,
-			AvgLatencyMs: bucket.AvgLatency,
-			SuccessCount: bucket.SuccessCount,
-			FailureCount: bucket.FailureCount,
-			ModelMetrics: modelMetrics,
-		})
-	}
+	// Hand the hour bucket to the registered sinks
+	recordToSinks(bucket, "hour")
 }
 
 func (hm *HistoricalMetrics) rollDayBucket(now time.Time, currentDay int64) {
@@ -360,32 +312,8 @@ func (hm *HistoricalMetrics) rollDayBucket(now time.Time, currentDay int64) {
 	bucket.Timestamp = now
 	hm.DayBuckets[idx] = bucket
 
-	// Persist day bucket to database
-	if db := GetMetricsDB(); db != nil && db.IsEnabled() {
-		modelMetrics := make(map[string]ModelMetricRecord)
-		for model, mb := range bucket.ByModel {
-			modelMetrics[model] = ModelMetricRecord{
-				ModelName:    model,
-				Requests:     mb.Requests,
-				Tokens:       mb.Tokens,
-				InputTokens:  mb.InputTokens,
-				OutputTokens: mb.OutputTokens,
-				AvgLatencyMs: mb.AvgLatency,
-			}
-		}
-		db.Record(MetricRecord{
-			Timestamp:    bucket.Timestamp,
-			Granularity:  "day",
-			Requests:     bucket.Requests,
-			Tokens:       bucket.Tokens,
-			InputTokens:  bucket.InputTokens,
-			OutputTokens: bucket.OutputTokens,
-			AvgLatencyMs: bucket.AvgLatency,
-			SuccessCount: bucket.SuccessCount,
-			FailureCount: bucket.FailureCount,
-			ModelMetrics: modelMetrics,
-		})
-	}
+	// Hand the day bucket to the registered sinks
+	recordToSinks(bucket, "day")
 }
 
 func (hm *HistoricalMetrics) aggregateSeconds() MetricBucket {
@@ -413,6 +341,7 @@ func (hm *HistoricalMetrics) aggregateSeconds() MetricBucket {
 			existing.Tokens += mb.Tokens
 			existing.InputTokens += mb.InputTokens
 			existing.OutputTokens += mb.OutputTokens
+			existing.FailureCount += mb.FailureCount
 			if mb.Requests > 0 {
 				modelLatencySum[model] += mb.AvgLatency * float64(mb.Requests)
 				modelLatencyCount[model] += mb.Requests
@@ -459,6 +388,7 @@ func (hm *HistoricalMetrics) aggregateMinutes() MetricBucket {
 			existing.Tokens += mb.Tokens
 			existing.InputTokens += mb.InputTokens
 			existing.OutputTokens += mb.OutputTokens
+			existing.FailureCount += mb.FailureCount
 			if mb.Requests > 0 {
 				modelLatencySum[model] += mb.AvgLatency * float64(mb.Requests)
 				modelLatencyCount[model] += mb.Requests
@@ -505,6 +435,7 @@ func (hm *HistoricalMetrics) aggregateHours() MetricBucket {
 			existing.Tokens += mb.Tokens
 			existing.InputTokens += mb.InputTokens
 			existing.OutputTokens += mb.OutputTokens
+			existing.FailureCount += mb.FailureCount
 			if mb.Requests > 0 {
 				modelLatencySum[model] += mb.AvgLatency * float64(mb.Requests)
 				modelLatencyCount[model] += mb.Requests
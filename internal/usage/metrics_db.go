@@ -5,18 +5,36 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	log "github.com/sirupsen/logrus"
 )
 
-// MetricsDB provides PostgreSQL-backed metrics persistence.
+// maxBufferedRecords bounds the records kept for retry while no database accepts writes.
+const maxBufferedRecords = 10000
+
+// metricsPool is the subset of *pgxpool.Pool used by MetricsDB; replaced in tests.
+type metricsPool interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	Ping(ctx context.Context) error
+	Close()
+}
+
+// MetricsDB provides PostgreSQL-backed metrics persistence. With a secondary configured,
+// writes and reads move to it while the primary fails its health checks. Rows written to
+// the secondary stay there after failing back, so the outage is missing from the primary.
 type MetricsDB struct {
-	pool   *pgxpool.Pool
-	config config.MetricsDBConfig
+	pool       metricsPool // primary
+	secondary  metricsPool // warm standby, nil when not configured
+	failedOver atomic.Bool
+	config     config.MetricsDBConfig
 
 	// Buffer for batching writes
 	mu          sync.Mutex
@@ -31,35 +49,40 @@ type MetricsDB struct {
 
 // MetricRecord represents a single metrics record to be persisted.
 type MetricRecord struct {
-	Timestamp    time.Time
-	Granularity  string // "second", "minute", "hour", "day"
-	Requests     int64
-	Tokens       int64
-	InputTokens  int64
-	OutputTokens int64
-	AvgLatencyMs float64
-	SuccessCount int64
-	FailureCount int64
-	ModelMetrics map[string]ModelMetricRecord
+	Timestamp    time.Time                    `json:"timestamp"`
+	Granularity  string                       `json:"granularity"` // "second", "minute", "hour", "day"
+	Requests     int64                        `json:"requests"`
+	Tokens       int64                        `json:"tokens"`
+	InputTokens  int64                        `json:"input_tokens"`
+	OutputTokens int64                        `json:"output_tokens"`
+	AvgLatencyMs float64                      `json:"avg_latency_ms"`
+	SuccessCount int64                        `json:"success_count"`
+	FailureCount int64                        `json:"failure_count"`
+	ModelMetrics map[string]ModelMetricRecord `json:"model_metrics,omitempty"`
 }
 
 // ModelMetricRecord represents per-model metrics.
 type ModelMetricRecord struct {
-	ModelName    string
-	Requests     int64
-	Tokens       int64
-	InputTokens  int64
-	OutputTokens int64
-	AvgLatencyMs float64
+	ModelName    string  `json:"model_name"`
+	Requests     int64   `json:"requests"`
+	Tokens       int64   `json:"tokens"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	FailureCount int64   `json:"failure_count,omitempty"`
 }
 
 var (
 	globalMetricsDB     *MetricsDB
 	globalMetricsDBOnce sync.Once
 	globalMetricsDBMu   sync.RWMutex
+
+	// unregisterGlobalMetricsDB detaches the current global database from the metrics sinks.
+	unregisterGlobalMetricsDB func()
 )
 
-// InitMetricsDB initializes the global metrics database connection.
+// InitMetricsDB initializes the global metrics database connection and registers it as a
+// MetricsSink so HistoricalMetrics persists every bucket to it.
 func InitMetricsDB(cfg config.MetricsDBConfig) error {
 	if !cfg.Enabled || cfg.DSN == "" {
 		log.Info("Metrics database is disabled or DSN not configured")
@@ -74,7 +97,11 @@ func InitMetricsDB(cfg config.MetricsDBConfig) error {
 		return err
 	}
 
+	if unregisterGlobalMetricsDB != nil {
+		unregisterGlobalMetricsDB()
+	}
 	globalMetricsDB = db
+	unregisterGlobalMetricsDB = RegisterMetricsSink(db)
 	log.Info("Metrics database initialized successfully")
 	return nil
 }
@@ -92,13 +119,52 @@ func NewMetricsDB(cfg config.MetricsDBConfig) (*MetricsDB, error) {
 		return nil, fmt.Errorf("metrics database DSN is required")
 	}
 
-	poolConfig, err := pgxpool.ParseConfig(cfg.DSN)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	primary, err := openMetricsPool(ctx, cfg.DSN, cfg.MaxConnections)
+	if err != nil {
+		return nil, err
+	}
+	var secondary metricsPool
+	if cfg.SecondaryDSN != "" {
+		pool, errSecondary := openMetricsPool(ctx, cfg.SecondaryDSN, cfg.MaxConnections)
+		if errSecondary != nil {
+			primary.Close()
+			return nil, fmt.Errorf("secondary: %w", errSecondary)
+		}
+		secondary = pool
+	}
+	db := newMetricsDB(cfg, primary, secondary)
+
+	// Test connection, starting on the secondary when only it is reachable
+	if err := primary.Ping(ctx); err != nil {
+		if secondary == nil || secondary.Ping(ctx) != nil {
+			db.closePools()
+			return nil, fmt.Errorf("failed to connect to database: %w", err)
+		}
+		log.WithError(err).Warn("Metrics database primary is unreachable, starting on the secondary")
+		db.failedOver.Store(true)
+	}
+
+	// Initialize schema
+	if err := db.initSchema(ctx, db.active()); err != nil {
+		db.closePools()
+		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+	}
+
+	db.start()
+	return db, nil
+}
+
+// openMetricsPool creates a connection pool for dsn without connecting to it.
+func openMetricsPool(ctx context.Context, dsn string, maxConns int) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse DSN: %w", err)
 	}
 
 	// Set connection pool limits
-	maxConns := cfg.MaxConnections
 	if maxConns <= 0 {
 		maxConns = 10
 	}
@@ -107,37 +173,30 @@ func NewMetricsDB(cfg config.MetricsDBConfig) (*MetricsDB, error) {
 	poolConfig.MaxConnLifetime = 30 * time.Minute
 	poolConfig.MaxConnIdleTime = 5 * time.Minute
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
 	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create connection pool: %w", err)
 	}
+	return pool, nil
+}
 
-	// Test connection
-	if err := pool.Ping(ctx); err != nil {
-		pool.Close()
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
-	}
-
-	db := &MetricsDB{
-		pool:      pool,
+// newMetricsDB wraps the given pools without starting the background loops.
+func newMetricsDB(cfg config.MetricsDBConfig, primary, secondary metricsPool) *MetricsDB {
+	return &MetricsDB{
+		pool:      primary,
+		secondary: secondary,
 		config:    cfg,
 		buffer:    make([]MetricRecord, 0, cfg.BatchSize),
 		lastFlush: time.Now(),
 		flushCh:   make(chan struct{}, 1),
 		done:      make(chan struct{}),
 	}
+}
 
-	// Initialize schema
-	if err := db.initSchema(ctx); err != nil {
-		pool.Close()
-		return nil, fmt.Errorf("failed to initialize schema: %w", err)
-	}
-
+// start launches the flush, retention cleanup and, with a secondary, health check loops.
+func (db *MetricsDB) start() {
 	// Start background flush ticker
-	flushInterval := cfg.FlushIntervalSeconds
+	flushInterval := db.config.FlushIntervalSeconds
 	if flushInterval <= 0 {
 		flushInterval = 5
 	}
@@ -155,11 +214,80 @@ func NewMetricsDB(cfg config.MetricsDBConfig) (*MetricsDB, error) {
 		db.cleanupLoop()
 	}()
 
-	return db, nil
+	if db.secondary != nil {
+		db.wg.Add(1)
+		go func() {
+			defer db.wg.Done()
+			db.healthLoop()
+		}()
+	}
+}
+
+// active returns the pool that writes and reads currently go to.
+func (db *MetricsDB) active() metricsPool {
+	if db.secondary != nil && db.failedOver.Load() {
+		return db.secondary
+	}
+	return db.pool
 }
 
-// initSchema creates the database tables if they don't exist.
-func (db *MetricsDB) initSchema(ctx context.Context) error {
+// healthLoop periodically checks the primary while a secondary is configured.
+func (db *MetricsDB) healthLoop() {
+	interval := db.config.HealthCheckIntervalSeconds
+	if interval <= 0 {
+		interval = 10
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			db.checkHealth(ctx)
+			cancel()
+		case <-db.done:
+			return
+		}
+	}
+}
+
+// checkHealth pings the primary, failing over to the secondary while it is unreachable and
+// back once it answers again. Records buffered during the switch are flushed right away.
+// Failing back does not copy what the secondary received into the primary: snapshots and
+// hourly and daily aggregates from the outage stay on the secondary, and the GetT*Data
+// reads, which follow the active database, no longer include them.
+func (db *MetricsDB) checkHealth(ctx context.Context) {
+	if db.secondary == nil {
+		return
+	}
+	err := db.pool.Ping(ctx)
+	switch {
+	case err != nil && !db.failedOver.Load():
+		if errSchema := db.initSchema(ctx, db.secondary); errSchema != nil {
+			log.WithError(errSchema).Error("Metrics database primary is down and the secondary is unusable")
+			return
+		}
+		db.failedOver.Store(true)
+		log.WithError(err).Warn("Metrics database primary failed its health check, writing to the secondary")
+	case err == nil && db.failedOver.Load():
+		if errSchema := db.initSchema(ctx, db.pool); errSchema != nil {
+			log.WithError(errSchema).Error("Metrics database primary answers but its schema could not be initialized")
+			return
+		}
+		db.failedOver.Store(false)
+		log.Info("Metrics database primary recovered, writing to it again")
+	default:
+		return
+	}
+	select {
+	case db.flushCh <- struct{}{}:
+	default:
+	}
+}
+
+// initSchema creates the database tables in pool if they don't exist.
+func (db *MetricsDB) initSchema(ctx context.Context, pool metricsPool) error {
 	schema := `
 		CREATE TABLE IF NOT EXISTS metrics_snapshots (
 			id SERIAL PRIMARY KEY,
@@ -189,9 +317,13 @@ func (db *MetricsDB) initSchema(ctx context.Context) error {
 			tokens BIGINT NOT NULL DEFAULT 0,
 			input_tokens BIGINT NOT NULL DEFAULT 0,
 			output_tokens BIGINT NOT NULL DEFAULT 0,
-			avg_latency_ms DOUBLE PRECISION NOT NULL DEFAULT 0
+			avg_latency_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+			failure_count BIGINT NOT NULL DEFAULT 0
 		);
 
+		ALTER TABLE model_metrics
+			ADD COLUMN IF NOT EXISTS failure_count BIGINT NOT NULL DEFAULT 0;
+
 		CREATE INDEX IF NOT EXISTS idx_model_metrics_snapshot 
 			ON model_metrics(snapshot_id);
 
@@ -233,7 +365,7 @@ func (db *MetricsDB) initSchema(ctx context.Context) error {
 			ON daily_aggregates(date DESC);
 	`
 
-	_, err := db.pool.Exec(ctx, schema)
+	_, err := pool.Exec(ctx, schema)
 	return err
 }
 
@@ -291,6 +423,14 @@ func (db *MetricsDB) flush() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	pool := db.active()
+	var failed []MetricRecord
+	defer func() {
+		if len(failed) > 0 {
+			db.retryOrDrop(ctx, pool, failed)
+		}
+	}()
+
 	batch := &pgx.Batch{}
 	for _, record := range records {
 		batch.Queue(`
@@ -304,13 +444,14 @@ func (db *MetricsDB) flush() {
 			record.FailureCount, record.AvgLatencyMs)
 	}
 
-	results := db.pool.SendBatch(ctx, batch)
+	results := pool.SendBatch(ctx, batch)
 	defer results.Close()
 
 	for i, record := range records {
 		var snapshotID int64
 		if err := results.QueryRow().Scan(&snapshotID); err != nil {
 			log.WithError(err).Error("Failed to insert metrics snapshot")
+			failed = append(failed, record)
 			continue
 		}
 
@@ -321,32 +462,51 @@ func (db *MetricsDB) flush() {
 				modelBatch.Queue(`
 					INSERT INTO model_metrics (
 						snapshot_id, model_name, requests, tokens, input_tokens,
-						output_tokens, avg_latency_ms
-					) VALUES ($1, $2, $3, $4, $5, $6, $7)
+						output_tokens, avg_latency_ms, failure_count
+					) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 				`, snapshotID, model.ModelName, model.Requests, model.Tokens,
-					model.InputTokens, model.OutputTokens, model.AvgLatencyMs)
+					model.InputTokens, model.OutputTokens, model.AvgLatencyMs, model.FailureCount)
 			}
-			modelResults := db.pool.SendBatch(ctx, modelBatch)
+			modelResults := pool.SendBatch(ctx, modelBatch)
 			modelResults.Close()
 		}
 
 		// Update aggregates for minute/hour granularity
 		if record.Granularity == "minute" || record.Granularity == "hour" {
-			db.updateHourlyAggregate(ctx, record)
+			db.updateHourlyAggregate(ctx, pool, record)
 		}
 		if record.Granularity == "hour" || record.Granularity == "day" {
-			db.updateDailyAggregate(ctx, record)
+			db.updateDailyAggregate(ctx, pool, record)
 		}
 
 		_ = i // Suppress unused warning
 	}
 }
 
+// retryOrDrop puts records that failed to insert back at the front of the buffer when pool
+// is unreachable, so the next flush retries them, on the secondary once the health check
+// has failed over. Records rejected by a reachable database are dropped. The buffer keeps
+// at most maxBufferedRecords, dropping the oldest.
+func (db *MetricsDB) retryOrDrop(ctx context.Context, pool metricsPool, records []MetricRecord) {
+	if err := pool.Ping(ctx); err == nil {
+		log.Errorf("Dropping %d metrics records rejected by the database", len(records))
+		return
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.buffer = append(records, db.buffer...)
+	if over := len(db.buffer) - maxBufferedRecords; over > 0 {
+		log.Warnf("Metrics database unreachable, dropping %d buffered records", over)
+		db.buffer = db.buffer[over:]
+	}
+}
+
 // updateHourlyAggregate upserts hourly aggregate data.
-func (db *MetricsDB) updateHourlyAggregate(ctx context.Context, record MetricRecord) {
+func (db *MetricsDB) updateHourlyAggregate(ctx context.Context, pool metricsPool, record MetricRecord) {
 	hourStart := record.Timestamp.Truncate(time.Hour)
 
-	_, err := db.pool.Exec(ctx, `
+	_, err := pool.Exec(ctx, `
 		INSERT INTO hourly_aggregates (
 			hour_start, total_requests, total_tokens, total_input_tokens,
 			total_output_tokens, success_count, failure_count, avg_latency_ms
@@ -369,10 +529,10 @@ func (db *MetricsDB) updateHourlyAggregate(ctx context.Context, record MetricRec
 }
 
 // updateDailyAggregate upserts daily aggregate data.
-func (db *MetricsDB) updateDailyAggregate(ctx context.Context, record MetricRecord) {
+func (db *MetricsDB) updateDailyAggregate(ctx context.Context, pool metricsPool, record MetricRecord) {
 	date := record.Timestamp.Truncate(24 * time.Hour)
 
-	_, err := db.pool.Exec(ctx, `
+	_, err := pool.Exec(ctx, `
 		INSERT INTO daily_aggregates (
 			date, total_requests, total_tokens, total_input_tokens,
 			total_output_tokens, success_count, failure_count, avg_latency_ms
@@ -418,41 +578,42 @@ func (db *MetricsDB) cleanup() {
 	if retentionDays <= 0 {
 		retentionDays = 30
 	}
+	pool := db.active()
 
 	// Delete second-granularity data older than 1 hour
-	_, _ = db.pool.Exec(ctx, `
+	_, _ = pool.Exec(ctx, `
 		DELETE FROM metrics_snapshots 
 		WHERE granularity = 'second' 
 		AND timestamp < NOW() - INTERVAL '1 hour'
 	`)
 
 	// Delete minute-granularity data older than 24 hours
-	_, _ = db.pool.Exec(ctx, `
+	_, _ = pool.Exec(ctx, `
 		DELETE FROM metrics_snapshots 
 		WHERE granularity = 'minute' 
 		AND timestamp < NOW() - INTERVAL '24 hours'
 	`)
 
 	// Delete hour-granularity data older than 7 days
-	_, _ = db.pool.Exec(ctx, `
+	_, _ = pool.Exec(ctx, `
 		DELETE FROM metrics_snapshots 
 		WHERE granularity = 'hour' 
 		AND timestamp < NOW() - INTERVAL '7 days'
 	`)
 
 	// Delete daily data older than retention period
-	_, _ = db.pool.Exec(ctx, fmt.Sprintf(`
+	_, _ = pool.Exec(ctx, fmt.Sprintf(`
 		DELETE FROM metrics_snapshots 
 		WHERE granularity = 'day' 
 		AND timestamp < NOW() - INTERVAL '%d days'
 	`, retentionDays))
 
-	_, _ = db.pool.Exec(ctx, fmt.Sprintf(`
+	_, _ = pool.Exec(ctx, fmt.Sprintf(`
 		DELETE FROM hourly_aggregates 
 		WHERE hour_start < NOW() - INTERVAL '%d days'
 	`, retentionDays))
 
-	_, _ = db.pool.Exec(ctx, fmt.Sprintf(`
+	_, _ = pool.Exec(ctx, fmt.Sprintf(`
 		DELETE FROM daily_aggregates 
 		WHERE date < CURRENT_DATE - INTERVAL '%d days'
 	`, retentionDays))
@@ -466,7 +627,7 @@ func (db *MetricsDB) GetTPSData(ctx context.Context, limit int) ([]MetricBucket,
 		return nil, 0, fmt.Errorf("database not initialized")
 	}
 
-	rows, err := db.pool.Query(ctx, `
+	rows, err := db.active().Query(ctx, `
 		SELECT timestamp, requests, tokens, avg_latency_ms, success_count, failure_count
 		FROM metrics_snapshots
 		WHERE granularity = 'second'
@@ -517,7 +678,7 @@ func (db *MetricsDB) GetTPMData(ctx context.Context, limit int) ([]MetricBucket,
 		return nil, 0, fmt.Errorf("database not initialized")
 	}
 
-	rows, err := db.pool.Query(ctx, `
+	rows, err := db.active().Query(ctx, `
 		SELECT timestamp, requests, tokens, input_tokens, output_tokens, 
 			avg_latency_ms, success_count, failure_count
 		FROM metrics_snapshots
@@ -560,7 +721,7 @@ func (db *MetricsDB) GetTPHData(ctx context.Context, limit int) ([]MetricBucket,
 		return nil, 0, fmt.Errorf("database not initialized")
 	}
 
-	rows, err := db.pool.Query(ctx, `
+	rows, err := db.active().Query(ctx, `
 		SELECT hour_start, total_requests, total_tokens, total_input_tokens,
 			total_output_tokens, avg_latency_ms, success_count, failure_count
 		FROM hourly_aggregates
@@ -602,7 +763,7 @@ func (db *MetricsDB) GetTPDData(ctx context.Context, limit int) ([]MetricBucket,
 		return nil, 0, fmt.Errorf("database not initialized")
 	}
 
-	rows, err := db.pool.Query(ctx, `
+	rows, err := db.active().Query(ctx, `
 		SELECT date, total_requests, total_tokens, total_input_tokens,
 			total_output_tokens, avg_latency_ms, success_count, failure_count
 		FROM daily_aggregates
@@ -638,6 +799,49 @@ func (db *MetricsDB) GetTPDData(ctx context.Context, limit int) ([]MetricBucket,
 	return buckets, currentTPD, nil
 }
 
+// GetModelDailyData retrieves the per-model breakdown of the last limit day snapshots,
+// in chronological order. Days without model rows are omitted.
+func (db *MetricsDB) GetModelDailyData(ctx context.Context, limit int) ([]MetricBucket, error) {
+	if db == nil || db.pool == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	rows, err := db.active().Query(ctx, `
+		SELECT s.timestamp, m.model_name, m.requests, m.tokens, m.input_tokens,
+			m.output_tokens, m.avg_latency_ms, m.failure_count
+		FROM model_metrics m
+		JOIN (
+			SELECT id, timestamp
+			FROM metrics_snapshots
+			WHERE granularity = 'day'
+			ORDER BY timestamp DESC
+			LIMIT $1
+		) s ON s.id = m.snapshot_id
+		ORDER BY s.timestamp, s.id
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []MetricBucket
+	for rows.Next() {
+		var ts time.Time
+		var model string
+		var mb ModelBucket
+		if err := rows.Scan(&ts, &model, &mb.Requests, &mb.Tokens, &mb.InputTokens,
+			&mb.OutputTokens, &mb.AvgLatency, &mb.FailureCount); err != nil {
+			continue
+		}
+		if len(buckets) == 0 || !buckets[len(buckets)-1].Timestamp.Equal(ts) {
+			buckets = append(buckets, MetricBucket{Timestamp: ts, ByModel: make(map[string]ModelBucket)})
+		}
+		buckets[len(buckets)-1].ByModel[model] = mb
+	}
+
+	return buckets, rows.Err()
+}
+
 // Close shuts down the database connection.
 func (db *MetricsDB) Close() {
 	if db == nil {
@@ -650,12 +854,28 @@ func (db *MetricsDB) Close() {
 			db.flushTicker.Stop()
 		}
 		db.wg.Wait()
-		if db.pool != nil {
-			db.pool.Close()
-		}
+		db.closePools()
 	})
 }
 
+// closePools closes the primary and secondary pools.
+func (db *MetricsDB) closePools() {
+	if db.pool != nil {
+		db.pool.Close()
+	}
+	if db.secondary != nil {
+		db.secondary.Close()
+	}
+}
+
+// Ping checks that the database writes currently go to is reachable.
+func (db *MetricsDB) Ping(ctx context.Context) error {
+	if !db.IsEnabled() {
+		return fmt.Errorf("metrics database not initialized")
+	}
+	return db.active().Ping(ctx)
+}
+
 // IsEnabled returns true if the metrics database is initialized.
 func (db *MetricsDB) IsEnabled() bool {
 	return db != nil && db.pool != nil
@@ -0,0 +1,176 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+var errPoolDown = errors.New("connection refused")
+
+// fakeMetricsPool is an in-memory metricsPool that records the snapshots inserted into it
+// and fails every call while down.
+type fakeMetricsPool struct {
+	mu        sync.Mutex
+	down      bool
+	snapshots []int64 // Unix timestamps of inserted snapshots
+}
+
+func (p *fakeMetricsPool) setDown(down bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.down = down
+}
+
+func (p *fakeMetricsPool) err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.down {
+		return errPoolDown
+	}
+	return nil
+}
+
+func (p *fakeMetricsPool) inserted() []int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]int64(nil), p.snapshots...)
+}
+
+func (p *fakeMetricsPool) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, p.err()
+}
+
+func (p *fakeMetricsPool) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	return nil, errors.New("query not supported")
+}
+
+func (p *fakeMetricsPool) SendBatch(_ context.Context, b *pgx.Batch) pgx.BatchResults {
+	return &fakeBatchResults{pool: p, queued: b.QueuedQueries}
+}
+
+func (p *fakeMetricsPool) Ping(context.Context) error { return p.err() }
+
+func (p *fakeMetricsPool) Close() {}
+
+// fakeBatchResults answers each queued snapshot insert with an id, storing its timestamp.
+type fakeBatchResults struct {
+	pool   *fakeMetricsPool
+	queued []*pgx.QueuedQuery
+}
+
+func (r *fakeBatchResults) next() (*pgx.QueuedQuery, error) {
+	if len(r.queued) == 0 {
+		return nil, errors.New("no more queued queries")
+	}
+	q := r.queued[0]
+	r.queued = r.queued[1:]
+	return q, r.pool.err()
+}
+
+func (r *fakeBatchResults) Exec() (pgconn.CommandTag, error) {
+	_, err := r.next()
+	return pgconn.CommandTag{}, err
+}
+
+func (r *fakeBatchResults) Query() (pgx.Rows, error) {
+	return nil, errors.New("query not supported")
+}
+
+func (r *fakeBatchResults) QueryRow() pgx.Row {
+	q, err := r.next()
+	if err != nil {
+		return fakeRow{err: err}
+	}
+	r.pool.mu.Lock()
+	defer r.pool.mu.Unlock()
+	r.pool.snapshots = append(r.pool.snapshots, q.Arguments[0].(time.Time).Unix())
+	return fakeRow{id: int64(len(r.pool.snapshots))}
+}
+
+func (r *fakeBatchResults) Close() error { return nil }
+
+type fakeRow struct {
+	id  int64
+	err error
+}
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	*dest[0].(*int64) = r.id
+	return nil
+}
+
+func recordAt(db *MetricsDB, unix int64) {
+	db.Record(MetricRecord{Timestamp: time.Unix(unix, 0), Granularity: "second", Requests: 1})
+	db.flush()
+}
+
+func assertInserted(t *testing.T, name string, pool *fakeMetricsPool, want ...int64) {
+	t.Helper()
+	got := pool.inserted()
+	if len(got) != len(want) {
+		t.Fatalf("%s snapshots = %v, want %v", name, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("%s snapshots = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestMetricsDB_FailsOverToSecondaryAndBack(t *testing.T) {
+	primary, secondary := &fakeMetricsPool{}, &fakeMetricsPool{}
+	db := newMetricsDB(config.MetricsDBConfig{BatchSize: 10}, primary, secondary)
+	ctx := context.Background()
+
+	recordAt(db, 1)
+	assertInserted(t, "primary", primary, 1)
+
+	// The primary goes down before the health check notices: the write is buffered.
+	primary.setDown(true)
+	recordAt(db, 2)
+	assertInserted(t, "primary", primary, 1)
+	assertInserted(t, "secondary", secondary)
+
+	db.checkHealth(ctx)
+	if !db.failedOver.Load() {
+		t.Fatal("health check did not fail over to the secondary")
+	}
+	if err := db.Ping(ctx); err != nil {
+		t.Fatalf("Ping while failed over = %v, want the secondary's health", err)
+	}
+	db.flush()
+	recordAt(db, 3)
+	assertInserted(t, "secondary", secondary, 2, 3)
+
+	primary.setDown(false)
+	db.checkHealth(ctx)
+	if db.failedOver.Load() {
+		t.Fatal("health check did not return to the recovered primary")
+	}
+	recordAt(db, 4)
+	assertInserted(t, "primary", primary, 1, 4)
+	assertInserted(t, "secondary", secondary, 2, 3)
+}
+
+func TestMetricsDB_BuffersUntilPrimaryRecoversWithoutSecondary(t *testing.T) {
+	primary := &fakeMetricsPool{down: true}
+	db := newMetricsDB(config.MetricsDBConfig{BatchSize: 10}, primary, nil)
+
+	recordAt(db, 1)
+	recordAt(db, 2)
+	assertInserted(t, "primary", primary)
+
+	primary.setDown(false)
+	recordAt(db, 3)
+	assertInserted(t, "primary", primary, 1, 2, 3)
+}
@@ -0,0 +1,437 @@
+// Package usage provides usage tracking and metrics persistence for the CLI Proxy API server.
+// This file implements an NDJSON file MetricsSink for deployments without PostgreSQL.
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMetricsFilePath       = "metrics/metrics.ndjson"
+	defaultMetricsFileMaxSizeMB  = 50
+	defaultMetricsFileMaxBackups = 5
+
+	// maxMetricsLineBytes bounds a single NDJSON line when reading back.
+	maxMetricsLineBytes = 4 << 20
+
+	// metricsFileQueueSize is how many encoded records may wait for the writer before
+	// further records are dropped.
+	metricsFileQueueSize = 1024
+)
+
+// MetricsFile appends MetricRecords as NDJSON to a size-rotated file and reads them back
+// as MetricBuckets. Rotated files are named path.1 (newest) through path.N (oldest).
+// Records are written by a background goroutine so that Record, which is called while the
+// historical metrics are locked, never waits on the disk.
+type MetricsFile struct {
+	mu         sync.Mutex
+	path       string
+	maxBytes   int64
+	maxBackups int
+	file       *os.File
+	size       int64
+	closed     bool
+
+	pending   chan []byte
+	flushReq  chan chan struct{}
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+var (
+	globalMetricsFile   *MetricsFile
+	globalMetricsFileMu sync.RWMutex
+
+	// unregisterGlobalMetricsFile detaches the current global file from the metrics sinks.
+	unregisterGlobalMetricsFile func()
+)
+
+// InitMetricsFile opens the global metrics file and registers it as a MetricsSink.
+func InitMetricsFile(cfg config.MetricsFileConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	mf, err := NewMetricsFile(cfg)
+	if err != nil {
+		return err
+	}
+
+	globalMetricsFileMu.Lock()
+	defer globalMetricsFileMu.Unlock()
+	if unregisterGlobalMetricsFile != nil {
+		unregisterGlobalMetricsFile()
+	}
+	if globalMetricsFile != nil {
+		globalMetricsFile.Close()
+	}
+	globalMetricsFile = mf
+	unregisterGlobalMetricsFile = RegisterMetricsSink(mf)
+	log.Infof("Metrics file initialized at %s", mf.path)
+	return nil
+}
+
+// GetMetricsFile returns the global metrics file, or nil when file persistence is disabled.
+func GetMetricsFile() *MetricsFile {
+	globalMetricsFileMu.RLock()
+	defer globalMetricsFileMu.RUnlock()
+	return globalMetricsFile
+}
+
+// NewMetricsFile opens (or creates) the metrics file described by cfg, applying defaults.
+func NewMetricsFile(cfg config.MetricsFileConfig) (*MetricsFile, error) {
+	path := strings.TrimSpace(cfg.Path)
+	if path == "" {
+		path = defaultMetricsFilePath
+	}
+	maxSizeMB := cfg.MaxSizeMB
+	if maxSizeMB <= 0 {
+		maxSizeMB = defaultMetricsFileMaxSizeMB
+	}
+	maxBackups := cfg.MaxBackups
+	if maxBackups <= 0 {
+		maxBackups = defaultMetricsFileMaxBackups
+	}
+	return newMetricsFile(path, int64(maxSizeMB)<<20, maxBackups)
+}
+
+func newMetricsFile(path string, maxBytes int64, maxBackups int) (*MetricsFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create metrics directory: %w", err)
+	}
+	mf := &MetricsFile{
+		path:       path,
+		maxBytes:   maxBytes,
+		maxBackups: maxBackups,
+		pending:    make(chan []byte, metricsFileQueueSize),
+		flushReq:   make(chan chan struct{}),
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	if err := mf.open(); err != nil {
+		return nil, err
+	}
+	go mf.writeLoop()
+	return mf, nil
+}
+
+func (mf *MetricsFile) open() error {
+	f, err := os.OpenFile(mf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open metrics file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to stat metrics file: %w", err)
+	}
+	mf.file = f
+	mf.size = info.Size()
+	if mf.size > 0 && !endsWithNewline(mf.path) {
+		// Terminate a line torn by a crash so the next record starts cleanly.
+		n, _ := f.Write([]byte{'\n'})
+		mf.size += int64(n)
+	}
+	return nil
+}
+
+func endsWithNewline(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return true
+	}
+	defer func() { _ = f.Close() }()
+	last := make([]byte, 1)
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return true
+	}
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+		return true
+	}
+	return last[0] == '\n'
+}
+
+// Record implements MetricsSink by queueing record to be appended as one NDJSON line.
+// When the writer has fallen metricsFileQueueSize records behind, record is dropped.
+func (mf *MetricsFile) Record(record MetricRecord) {
+	if mf == nil {
+		return
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		log.WithError(err).Error("Failed to encode metrics record")
+		return
+	}
+	line = append(line, '\n')
+
+	select {
+	case mf.pending <- line:
+	default:
+		log.Warn("Metrics file writer is behind, dropping metrics record")
+	}
+}
+
+// Flush waits until every record queued before the call has been written.
+func (mf *MetricsFile) Flush() {
+	if mf == nil {
+		return
+	}
+	ack := make(chan struct{})
+	select {
+	case mf.flushReq <- ack:
+		<-ack
+	case <-mf.stopped:
+	}
+}
+
+// writeLoop writes queued records until Close, then writes whatever is still queued.
+func (mf *MetricsFile) writeLoop() {
+	defer close(mf.stopped)
+	for {
+		select {
+		case line := <-mf.pending:
+			mf.write(line)
+		case ack := <-mf.flushReq:
+			mf.drain()
+			close(ack)
+		case <-mf.done:
+			mf.drain()
+			return
+		}
+	}
+}
+
+func (mf *MetricsFile) drain() {
+	for {
+		select {
+		case line := <-mf.pending:
+			mf.write(line)
+		default:
+			return
+		}
+	}
+}
+
+// write appends line, rotating first when it would push the active file past the size
+// limit. A failed rotation, or an earlier failure that left no file open, does not stop
+// recording: the active file is reopened and rotation is retried with the next record.
+func (mf *MetricsFile) write(line []byte) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+	if mf.closed {
+		return
+	}
+	if mf.size > 0 && mf.size+int64(len(line)) > mf.maxBytes && mf.file != nil {
+		if err := mf.rotate(); err != nil {
+			log.WithError(err).Error("Failed to rotate metrics file")
+		}
+	}
+	if mf.file == nil {
+		if err := mf.open(); err != nil {
+			log.WithError(err).Error("Failed to reopen metrics file, dropping metrics record")
+			return
+		}
+	}
+	n, err := mf.file.Write(line)
+	mf.size += int64(n)
+	if err != nil {
+		log.WithError(err).Error("Failed to write metrics record")
+	}
+}
+
+// rotate shifts path.N-1 to path.N down to path to path.1, dropping the oldest backup,
+// and reopens an empty active file. Callers must hold mf.mu.
+func (mf *MetricsFile) rotate() error {
+	if err := mf.file.Close(); err != nil {
+		log.WithError(err).Warn("Failed to close metrics file before rotation")
+	}
+	mf.file = nil
+
+	_ = os.Remove(mf.backupPath(mf.maxBackups))
+	for i := mf.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(mf.backupPath(i), mf.backupPath(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(mf.path, mf.backupPath(1)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return mf.open()
+}
+
+func (mf *MetricsFile) backupPath(n int) string {
+	return fmt.Sprintf("%s.%d", mf.path, n)
+}
+
+// ReadBuckets reconstructs the most recent limit buckets of the given granularity, oldest
+// first, from the rotated and active files. Files are read newest first and reading stops
+// at the first file that completes limit buckets, so older backups are only scanned when
+// needed; limit <= 0 reads every file and returns every bucket. Lines that fail to parse
+// (e.g. a partial write before a crash) are skipped. Records queued before the call are
+// written first. Reading does not block writers, so a rotation racing with a read may
+// drop or repeat a file's worth of records.
+func (mf *MetricsFile) ReadBuckets(granularity string, limit int) ([]MetricBucket, error) {
+	if mf == nil {
+		return nil, fmt.Errorf("metrics file not initialized")
+	}
+	mf.Flush()
+
+	var buckets []MetricBucket
+	paths := make([]string, 0, mf.maxBackups+1)
+	paths = append(paths, mf.path)
+	for i := 1; i <= mf.maxBackups; i++ {
+		paths = append(paths, mf.backupPath(i))
+	}
+
+	for _, path := range paths {
+		if limit > 0 && len(buckets) >= limit {
+			break
+		}
+		fileBuckets, err := readFileBuckets(path, granularity, limit-len(buckets))
+		if err != nil {
+			return nil, err
+		}
+		buckets = append(fileBuckets, buckets...)
+	}
+	return buckets, nil
+}
+
+// readFileBuckets returns the last limit buckets of granularity in the file at path, or
+// all of them when limit <= 0. A missing file has none.
+func readFileBuckets(path, granularity string, limit int) ([]MetricBucket, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var buckets []MetricBucket
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMetricsLineBytes)
+	for scanner.Scan() {
+		var record MetricRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || record.Granularity != granularity {
+			continue
+		}
+		buckets = append(buckets, bucketFromRecord(record))
+		if limit > 0 && len(buckets) > limit {
+			buckets = buckets[1:]
+		}
+	}
+	return buckets, scanner.Err()
+}
+
+// GetTPSData mirrors MetricsDB.GetTPSData using the file's second records.
+func (mf *MetricsFile) GetTPSData(limit int) ([]MetricBucket, float64, error) {
+	buckets, err := mf.ReadBuckets("second", limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	var currentTPS float64
+	if len(buckets) > 0 {
+		count := 10
+		if len(buckets) < count {
+			count = len(buckets)
+		}
+		var total int64
+		for i := len(buckets) - count; i < len(buckets); i++ {
+			total += buckets[i].Requests
+		}
+		currentTPS = float64(total) / float64(count)
+	}
+	return buckets, currentTPS, nil
+}
+
+// GetTPMData mirrors MetricsDB.GetTPMData using the file's minute records.
+func (mf *MetricsFile) GetTPMData(limit int) ([]MetricBucket, int64, error) {
+	return mf.latestTokens("minute", limit)
+}
+
+// GetTPHData mirrors MetricsDB.GetTPHData using the file's hour records.
+func (mf *MetricsFile) GetTPHData(limit int) ([]MetricBucket, int64, error) {
+	return mf.latestTokens("hour", limit)
+}
+
+// GetTPDData mirrors MetricsDB.GetTPDData using the file's day records.
+func (mf *MetricsFile) GetTPDData(limit int) ([]MetricBucket, int64, error) {
+	return mf.latestTokens("day", limit)
+}
+
+func (mf *MetricsFile) latestTokens(granularity string, limit int) ([]MetricBucket, int64, error) {
+	buckets, err := mf.ReadBuckets(granularity, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	var current int64
+	if len(buckets) > 0 {
+		current = buckets[len(buckets)-1].Tokens
+	}
+	return buckets, current, nil
+}
+
+// Close writes the records still queued and closes the active file. Further records are
+// dropped.
+func (mf *MetricsFile) Close() {
+	if mf == nil {
+		return
+	}
+	mf.closeOnce.Do(func() { close(mf.done) })
+	<-mf.stopped
+
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+	mf.closed = true
+	if mf.file != nil {
+		_ = mf.file.Close()
+		mf.file = nil
+	}
+}
+
+// IsEnabled returns true until the metrics file is closed.
+func (mf *MetricsFile) IsEnabled() bool {
+	if mf == nil {
+		return false
+	}
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+	return !mf.closed
+}
+
+func bucketFromRecord(record MetricRecord) MetricBucket {
+	bucket := MetricBucket{
+		Timestamp:    record.Timestamp,
+		Requests:     record.Requests,
+		Tokens:       record.Tokens,
+		InputTokens:  record.InputTokens,
+		OutputTokens: record.OutputTokens,
+		AvgLatency:   record.AvgLatencyMs,
+		SuccessCount: record.SuccessCount,
+		FailureCount: record.FailureCount,
+		ByModel:      make(map[string]ModelBucket, len(record.ModelMetrics)),
+	}
+	for model, mm := range record.ModelMetrics {
+		bucket.ByModel[model] = ModelBucket{
+			Requests:     mm.Requests,
+			Tokens:       mm.Tokens,
+			InputTokens:  mm.InputTokens,
+			OutputTokens: mm.OutputTokens,
+			AvgLatency:   mm.AvgLatencyMs,
+			FailureCount: mm.FailureCount,
+		}
+	}
+	return bucket
+}
@@ -0,0 +1,197 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsFile_WritesAndReadsBackBuckets(t *testing.T) {
+	mf, err := newMetricsFile(filepath.Join(t.TempDir(), "metrics.ndjson"), 1<<20, 3)
+	if err != nil {
+		t.Fatalf("newMetricsFile: %v", err)
+	}
+	defer mf.Close()
+
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		mf.Record(MetricRecord{
+			Timestamp:    base.Add(time.Duration(i) * time.Minute),
+			Granularity:  "minute",
+			Requests:     int64(10 + i),
+			Tokens:       int64(100 * (i + 1)),
+			SuccessCount: int64(9 + i),
+			FailureCount: 1,
+			ModelMetrics: map[string]ModelMetricRecord{"model-a": {ModelName: "model-a", Requests: int64(10 + i), Tokens: int64(100 * (i + 1))}},
+		})
+	}
+	mf.Record(MetricRecord{Timestamp: base.Add(24 * time.Hour), Granularity: "day", Requests: 60, Tokens: 1500})
+
+	mf.Flush()
+
+	// A torn final line from a crash must not break reading.
+	f, err := os.OpenFile(mf.path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, _ = f.WriteString(`{"timestamp":"2026-03-01T00:09:00Z","granul`)
+	_ = f.Close()
+
+	// Reopening terminates the torn line, so later records are still readable.
+	mf.Close()
+	if mf, err = newMetricsFile(mf.path, 1<<20, 3); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	mf.Record(MetricRecord{Timestamp: base.Add(5 * time.Minute), Granularity: "minute", Requests: 15, Tokens: 600, SuccessCount: 14, FailureCount: 1,
+		ModelMetrics: map[string]ModelMetricRecord{"model-a": {ModelName: "model-a", Requests: 15, Tokens: 600}}})
+
+	buckets, current, err := mf.GetTPMData(3)
+	if err != nil {
+		t.Fatalf("GetTPMData: %v", err)
+	}
+	if len(buckets) != 3 || current != 600 {
+		t.Fatalf("got %d buckets, current %d; want 3 buckets, current 600", len(buckets), current)
+	}
+	for i, b := range buckets {
+		want := base.Add(time.Duration(i+3) * time.Minute)
+		if !b.Timestamp.Equal(want) || b.Requests != int64(13+i) || b.FailureCount != 1 {
+			t.Errorf("bucket %d = %+v, want timestamp %s", i, b, want)
+		}
+		if mb := b.ByModel["model-a"]; mb.Requests != b.Requests || mb.Tokens != b.Tokens {
+			t.Errorf("bucket %d model-a = %+v", i, mb)
+		}
+	}
+
+	days, currentTPD, err := mf.GetTPDData(30)
+	if err != nil || len(days) != 1 || currentTPD != 1500 || days[0].Requests != 60 {
+		t.Fatalf("GetTPDData = %+v, %d, %v", days, currentTPD, err)
+	}
+}
+
+func TestMetricsFile_RotatesAtSizeLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.ndjson")
+	const maxBytes = 400
+	mf, err := newMetricsFile(path, maxBytes, 2)
+	if err != nil {
+		t.Fatalf("newMetricsFile: %v", err)
+	}
+	defer mf.Close()
+
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	const total = 40
+	for i := 0; i < total; i++ {
+		mf.Record(MetricRecord{Timestamp: base.Add(time.Duration(i) * time.Second), Granularity: "second", Requests: int64(i)})
+	}
+	mf.Flush()
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("expected %s to exist: %v", name, err)
+		}
+		if info.Size() > maxBytes {
+			t.Errorf("%s is %d bytes, above the %d byte limit", name, info.Size(), maxBytes)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("backups beyond max-backups must be removed, stat err = %v", err)
+	}
+
+	buckets, err := mf.ReadBuckets("second", 0)
+	if err != nil {
+		t.Fatalf("ReadBuckets: %v", err)
+	}
+	if len(buckets) == 0 || len(buckets) >= total {
+		t.Fatalf("expected rotation to drop the oldest records, got %d of %d", len(buckets), total)
+	}
+	for i, b := range buckets {
+		if want := int64(total - len(buckets) + i); b.Requests != want {
+			t.Fatalf("bucket %d requests = %d, want %d (records must stay contiguous and ordered)", i, b.Requests, want)
+		}
+	}
+}
+
+func TestMetricsFile_ReceivesHistoricalMetricsAsSink(t *testing.T) {
+	mf, err := newMetricsFile(filepath.Join(t.TempDir(), "metrics.ndjson"), 1<<20, 1)
+	if err != nil {
+		t.Fatalf("newMetricsFile: %v", err)
+	}
+	defer mf.Close()
+	t.Cleanup(RegisterMetricsSink(mf))
+
+	hm := NewHistoricalMetrics("")
+	hm.Record("model-a", 40, 60, 120, true)
+	hm.tick()
+
+	for _, granularity := range []string{"second", "minute", "hour", "day"} {
+		buckets, err := mf.ReadBuckets(granularity, 0)
+		if err != nil || len(buckets) != 1 || buckets[0].Tokens != 100 {
+			t.Fatalf("%s buckets = %+v, err %v", granularity, buckets, err)
+		}
+	}
+}
+
+func TestMetricsFile_KeepsRecordingAfterFailedRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "metrics.ndjson")
+	mf, err := newMetricsFile(path, 200, 1)
+	if err != nil {
+		t.Fatalf("newMetricsFile: %v", err)
+	}
+	defer mf.Close()
+
+	// A directory where the backup should go makes every rotation fail.
+	if err := os.Mkdir(path+".1", 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(path+".1", "keep"), nil, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		mf.Record(MetricRecord{Timestamp: base.Add(time.Duration(i) * time.Second), Granularity: "second", Requests: int64(i)})
+	}
+	mf.Flush()
+	buckets, err := readFileBuckets(path, "second", 0)
+	if err != nil {
+		t.Fatalf("readFileBuckets: %v", err)
+	}
+	if len(buckets) != 10 || buckets[9].Requests != 9 {
+		t.Fatalf("got %d records in the active file, want all 10 despite the failed rotations", len(buckets))
+	}
+	if !mf.IsEnabled() {
+		t.Fatal("metrics file disabled after a failed rotation")
+	}
+}
+
+func TestMetricsFile_ReadBucketsStopsAtNewestFilesThatSuffice(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.ndjson")
+	mf, err := newMetricsFile(path, 1<<20, 2)
+	if err != nil {
+		t.Fatalf("newMetricsFile: %v", err)
+	}
+	defer mf.Close()
+
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		mf.Record(MetricRecord{Timestamp: base.Add(time.Duration(i) * time.Minute), Granularity: "minute", Requests: int64(i)})
+	}
+	// An unreadable oldest backup is only touched when the newer files are not enough.
+	if err := os.WriteFile(path+".2", []byte(strings.Repeat("x", maxMetricsLineBytes+1)), 0o644); err != nil {
+		t.Fatalf("write backup: %v", err)
+	}
+
+	buckets, err := mf.ReadBuckets("minute", 2)
+	if err != nil {
+		t.Fatalf("ReadBuckets: %v", err)
+	}
+	if len(buckets) != 2 || buckets[0].Requests != 1 || buckets[1].Requests != 2 {
+		t.Fatalf("buckets = %+v, want the last two minutes", buckets)
+	}
+	if _, err := mf.ReadBuckets("minute", 0); err == nil {
+		t.Fatal("reading every bucket must scan the oldest backup too")
+	}
+}
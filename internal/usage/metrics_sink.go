@@ -0,0 +1,88 @@
+// Package usage provides usage tracking and logging functionality for the CLI Proxy API server.
+// This file defines the pluggable persistence backends fed by HistoricalMetrics.
+package usage
+
+import "sync"
+
+// MetricsSink receives every bucket HistoricalMetrics completes, at each granularity
+// ("second", "minute", "hour", "day"). Record is called with the metrics lock held, so
+// implementations must be fast, must not modify the record, and must not call back into
+// HistoricalMetrics; backends doing I/O should buffer as MetricsDB does.
+type MetricsSink interface {
+	Record(record MetricRecord)
+}
+
+type registeredSink struct {
+	id   uint64
+	sink MetricsSink
+}
+
+var (
+	metricsSinksMu sync.RWMutex
+	metricsSinks   []registeredSink
+	metricsSinkSeq uint64
+)
+
+// RegisterMetricsSink adds sink to the set fanned out to on every bucket roll and returns
+// a function that removes it again. Sinks are normally registered once at startup.
+func RegisterMetricsSink(sink MetricsSink) (unregister func()) {
+	if sink == nil {
+		return func() {}
+	}
+	metricsSinksMu.Lock()
+	defer metricsSinksMu.Unlock()
+	metricsSinkSeq++
+	id := metricsSinkSeq
+	metricsSinks = append(metricsSinks, registeredSink{id: id, sink: sink})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			metricsSinksMu.Lock()
+			defer metricsSinksMu.Unlock()
+			for i, registered := range metricsSinks {
+				if registered.id == id {
+					metricsSinks = append(metricsSinks[:i:i], metricsSinks[i+1:]...)
+					return
+				}
+			}
+		})
+	}
+}
+
+// recordToSinks converts bucket into a MetricRecord and hands it to every registered sink.
+func recordToSinks(bucket MetricBucket, granularity string) {
+	metricsSinksMu.RLock()
+	defer metricsSinksMu.RUnlock()
+	if len(metricsSinks) == 0 {
+		return
+	}
+
+	modelMetrics := make(map[string]ModelMetricRecord, len(bucket.ByModel))
+	for model, mb := range bucket.ByModel {
+		modelMetrics[model] = ModelMetricRecord{
+			ModelName:    model,
+			Requests:     mb.Requests,
+			Tokens:       mb.Tokens,
+			InputTokens:  mb.InputTokens,
+			OutputTokens: mb.OutputTokens,
+			AvgLatencyMs: mb.AvgLatency,
+			FailureCount: mb.FailureCount,
+		}
+	}
+	record := MetricRecord{
+		Timestamp:    bucket.Timestamp,
+		Granularity:  granularity,
+		Requests:     bucket.Requests,
+		Tokens:       bucket.Tokens,
+		InputTokens:  bucket.InputTokens,
+		OutputTokens: bucket.OutputTokens,
+		AvgLatencyMs: bucket.AvgLatency,
+		SuccessCount: bucket.SuccessCount,
+		FailureCount: bucket.FailureCount,
+		ModelMetrics: modelMetrics,
+	}
+	for _, registered := range metricsSinks {
+		registered.sink.Record(record)
+	}
+}
@@ -0,0 +1,72 @@
+package usage
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type fakeMetricsSink struct {
+	mu      sync.Mutex
+	records []MetricRecord
+}
+
+func (s *fakeMetricsSink) Record(record MetricRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, record)
+}
+
+func (s *fakeMetricsSink) byGranularity() map[string]MetricRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]MetricRecord, len(s.records))
+	for _, r := range s.records {
+		out[r.Granularity] = r
+	}
+	return out
+}
+
+func TestMetricsSink_ReceivesEveryGranularity(t *testing.T) {
+	first, second := &fakeMetricsSink{}, &fakeMetricsSink{}
+	t.Cleanup(RegisterMetricsSink(first))
+	t.Cleanup(RegisterMetricsSink(second))
+
+	hm := NewHistoricalMetrics("")
+	hm.Record("model-a", 100, 50, 200, true)
+	hm.Record("model-a", 10, 5, 100, false)
+	hm.Record("model-b", 20, 30, 300, true)
+	// A fresh tracker has never rolled, so the first tick closes a bucket at every granularity.
+	hm.tick()
+
+	got := first.byGranularity()
+	for _, granularity := range []string{"second", "minute", "hour", "day"} {
+		record, ok := got[granularity]
+		if !ok {
+			t.Fatalf("no %s record delivered; got %+v", granularity, first.records)
+		}
+		if record.Requests != 3 || record.Tokens != 215 || record.SuccessCount != 2 || record.FailureCount != 1 {
+			t.Errorf("%s record totals = %+v", granularity, record)
+		}
+		if mm := record.ModelMetrics["model-a"]; mm.ModelName != "model-a" || mm.Requests != 2 || mm.Tokens != 165 {
+			t.Errorf("%s model-a metrics = %+v", granularity, mm)
+		}
+	}
+	if !reflect.DeepEqual(first.records, second.records) {
+		t.Fatalf("sinks received different records:\n%+v\n%+v", first.records, second.records)
+	}
+}
+
+func TestMetricsSink_Unregister(t *testing.T) {
+	sink := &fakeMetricsSink{}
+	unregister := RegisterMetricsSink(sink)
+	unregister()
+	unregister()
+
+	hm := NewHistoricalMetrics("")
+	hm.Record("model-a", 1, 1, 1, true)
+	hm.tick()
+	if len(sink.records) != 0 {
+		t.Fatalf("unregistered sink received %d records", len(sink.records))
+	}
+}
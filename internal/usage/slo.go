@@ -0,0 +1,113 @@
+// Package usage provides usage tracking and logging functionality for the CLI Proxy API server.
+// This file computes availability SLO compliance and error-budget burn from daily aggregates.
+package usage
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// DefaultSLOTarget is the availability objective used when none is configured (99.9%).
+	DefaultSLOTarget = 0.999
+	// DefaultSLOWindowDays is the rolling SLO window used when none is configured.
+	DefaultSLOWindowDays = 30
+)
+
+// SLOReport describes availability against an objective over a rolling window.
+type SLOReport struct {
+	// Target is the required success ratio, e.g. 0.999.
+	Target float64 `json:"target"`
+	// WindowDays is the length of the rolling window.
+	WindowDays int `json:"window_days"`
+	// TotalRequests and FailedRequests are summed over the window.
+	TotalRequests  int64 `json:"total_requests"`
+	FailedRequests int64 `json:"failed_requests"`
+	// SuccessRate is the observed success ratio; 1 when no requests were served.
+	SuccessRate float64 `json:"success_rate"`
+	// ErrorBudget is the number of failures the objective allows for the observed traffic.
+	ErrorBudget float64 `json:"error_budget"`
+	// ErrorBudgetRemaining is the unspent fraction of the budget; negative once the SLO is violated.
+	ErrorBudgetRemaining float64 `json:"error_budget_remaining"`
+	// BurnRate is the observed failure ratio divided by the allowed one; 1 spends the budget
+	// exactly over the window, higher values exhaust it early.
+	BurnRate float64 `json:"burn_rate"`
+	// Met reports whether the success rate is at or above the target.
+	Met bool `json:"met"`
+	// Source is "database", "file" or "memory".
+	Source string `json:"source"`
+}
+
+// ComputeSLO evaluates daily buckets whose timestamps fall within windowDays before now.
+// target is a ratio in (0, 1); out-of-range values fall back to DefaultSLOTarget.
+func ComputeSLO(buckets []MetricBucket, target float64, windowDays int, now time.Time) SLOReport {
+	if target <= 0 || target >= 1 {
+		target = DefaultSLOTarget
+	}
+	if windowDays <= 0 {
+		windowDays = DefaultSLOWindowDays
+	}
+	report := SLOReport{Target: target, WindowDays: windowDays, SuccessRate: 1}
+
+	since := now.AddDate(0, 0, -windowDays)
+	for _, b := range buckets {
+		if b.Timestamp.Before(since) || b.Timestamp.After(now) {
+			continue
+		}
+		total := b.Requests
+		if counted := b.SuccessCount + b.FailureCount; counted > total {
+			total = counted
+		}
+		report.TotalRequests += total
+		report.FailedRequests += b.FailureCount
+	}
+
+	allowedFailureRate := 1 - target
+	report.ErrorBudget = allowedFailureRate * float64(report.TotalRequests)
+	report.ErrorBudgetRemaining = 1
+	if report.TotalRequests > 0 {
+		failureRate := float64(report.FailedRequests) / float64(report.TotalRequests)
+		report.SuccessRate = 1 - failureRate
+		report.BurnRate = failureRate / allowedFailureRate
+		report.ErrorBudgetRemaining = 1 - report.BurnRate
+	}
+	report.Met = report.SuccessRate >= target
+	return report
+}
+
+// SLODailyBuckets returns the daily aggregates for the last windowDays, preferring MetricsDB,
+// then the metrics file, and falling back to in-memory history. The in-memory view also
+// counts hours completed since the most recent day roll, so a freshly started server is not
+// reported as idle.
+func SLODailyBuckets(ctx context.Context, windowDays int) ([]MetricBucket, string) {
+	if db := GetMetricsDB(); db != nil && db.IsEnabled() {
+		if buckets, _, err := db.GetTPDData(ctx, windowDays); err == nil {
+			return buckets, "database"
+		}
+	}
+	if mf := GetMetricsFile(); mf.IsEnabled() {
+		if buckets, _, err := mf.GetTPDData(windowDays); err == nil {
+			return buckets, "file"
+		}
+	}
+
+	hm := GetHistoricalMetrics()
+	if hm == nil {
+		return nil, "memory"
+	}
+	snapshot := hm.Snapshot(false, false, true, true)
+	var lastDay time.Time
+	buckets := make([]MetricBucket, 0, len(snapshot.Days)+len(snapshot.Hours))
+	for _, b := range snapshot.Days {
+		if b.Timestamp.After(lastDay) {
+			lastDay = b.Timestamp
+		}
+		buckets = append(buckets, b)
+	}
+	for _, b := range snapshot.Hours {
+		if b.Timestamp.After(lastDay) {
+			buckets = append(buckets, b)
+		}
+	}
+	return buckets, "memory"
+}
@@ -0,0 +1,110 @@
+package usage
+
+import (
+	"context"
+	"sort"
+)
+
+// RankBy selects the ordering used by TopModels.
+type RankBy string
+
+const (
+	// RankByRequests orders models by request count.
+	RankByRequests RankBy = "requests"
+	// RankByTokens orders models by total tokens.
+	RankByTokens RankBy = "tokens"
+	// RankByErrors orders models by error rate, then by failure count.
+	RankByErrors RankBy = "errors"
+)
+
+// ModelRank is a single entry in a top-N model ranking.
+type ModelRank struct {
+	Model      string  `json:"model"`
+	Requests   int64   `json:"requests"`
+	Tokens     int64   `json:"tokens"`
+	Errors     int64   `json:"errors"`
+	ErrorRate  float64 `json:"error_rate_percent"`
+	AvgLatency float64 `json:"avg_latency_ms"`
+}
+
+// TopModels aggregates per-model totals across buckets and returns the top n models.
+// Models without requests are skipped; n <= 0 returns every model.
+func TopModels(buckets []MetricBucket, by RankBy, n int) []ModelRank {
+	totals := make(map[string]*ModelRank)
+	latencySum := make(map[string]float64)
+	for _, bucket := range buckets {
+		for model, mb := range bucket.ByModel {
+			if mb.Requests == 0 {
+				continue
+			}
+			rank, ok := totals[model]
+			if !ok {
+				rank = &ModelRank{Model: model}
+				totals[model] = rank
+			}
+			rank.Requests += mb.Requests
+			rank.Tokens += mb.Tokens
+			rank.Errors += mb.FailureCount
+			latencySum[model] += mb.AvgLatency * float64(mb.Requests)
+		}
+	}
+
+	ranks := make([]ModelRank, 0, len(totals))
+	for model, rank := range totals {
+		rank.ErrorRate = float64(rank.Errors) / float64(rank.Requests) * 100
+		rank.AvgLatency = latencySum[model] / float64(rank.Requests)
+		ranks = append(ranks, *rank)
+	}
+
+	sort.Slice(ranks, func(i, j int) bool {
+		a, b := ranks[i], ranks[j]
+		switch by {
+		case RankByTokens:
+			if a.Tokens != b.Tokens {
+				return a.Tokens > b.Tokens
+			}
+		case RankByErrors:
+			if a.ErrorRate != b.ErrorRate {
+				return a.ErrorRate > b.ErrorRate
+			}
+			if a.Errors != b.Errors {
+				return a.Errors > b.Errors
+			}
+		}
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		return a.Model < b.Model
+	})
+
+	if n > 0 && len(ranks) > n {
+		ranks = ranks[:n]
+	}
+	return ranks
+}
+
+// TopModelDailyBuckets returns the per-model daily buckets for the last days, preferring
+// MetricsDB, then the metrics file, and falling back to in-memory history when neither
+// has any days, so rankings over 7d and 30d survive a restart.
+func TopModelDailyBuckets(ctx context.Context, days int) ([]MetricBucket, string) {
+	if db := GetMetricsDB(); db != nil && db.IsEnabled() {
+		if buckets, err := db.GetModelDailyData(ctx, days); err == nil && len(buckets) > 0 {
+			return buckets, "database"
+		}
+	}
+	if mf := GetMetricsFile(); mf.IsEnabled() {
+		if buckets, err := mf.ReadBuckets("day", days); err == nil && len(buckets) > 0 {
+			return buckets, "file"
+		}
+	}
+
+	hm := GetHistoricalMetrics()
+	if hm == nil {
+		return nil, "memory"
+	}
+	buckets := hm.Snapshot(false, false, false, true).Days
+	if len(buckets) > days {
+		buckets = buckets[len(buckets)-days:]
+	}
+	return buckets, "memory"
+}
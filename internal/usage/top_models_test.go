@@ -0,0 +1,60 @@
+package usage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTopModelDailyBuckets_ReadsPersistedDaysAfterRestart(t *testing.T) {
+	mf, err := newMetricsFile(filepath.Join(t.TempDir(), "metrics.ndjson"), 1<<20, 1)
+	if err != nil {
+		t.Fatalf("newMetricsFile: %v", err)
+	}
+	defer mf.Close()
+
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 9; i++ {
+		mf.Record(MetricRecord{
+			Timestamp:   base.AddDate(0, 0, i),
+			Granularity: "day",
+			Requests:    12,
+			ModelMetrics: map[string]ModelMetricRecord{
+				"steady": {ModelName: "steady", Requests: 10, Tokens: 1000},
+				"flaky":  {ModelName: "flaky", Requests: 2, Tokens: 50, FailureCount: 1},
+			},
+		})
+	}
+	mf.Record(MetricRecord{Timestamp: base.AddDate(0, 0, 9), Granularity: "hour", Requests: 99,
+		ModelMetrics: map[string]ModelMetricRecord{"hourly": {ModelName: "hourly", Requests: 99}}})
+
+	globalMetricsFileMu.Lock()
+	previous := globalMetricsFile
+	globalMetricsFile = mf
+	globalMetricsFileMu.Unlock()
+	t.Cleanup(func() {
+		globalMetricsFileMu.Lock()
+		globalMetricsFile = previous
+		globalMetricsFileMu.Unlock()
+	})
+
+	buckets, source := TopModelDailyBuckets(context.Background(), 7)
+	if source != "file" || len(buckets) != 7 {
+		t.Fatalf("got %d buckets from %q, want 7 from file", len(buckets), source)
+	}
+	if first := buckets[0].Timestamp; !first.Equal(base.AddDate(0, 0, 2)) {
+		t.Fatalf("first bucket at %s, want the last 7 days", first)
+	}
+
+	ranks := TopModels(buckets, RankByErrors, 0)
+	if len(ranks) != 2 {
+		t.Fatalf("got %d ranked models, want 2: %+v", len(ranks), ranks)
+	}
+	if ranks[0].Model != "flaky" || ranks[0].Requests != 14 || ranks[0].Errors != 7 {
+		t.Fatalf("unexpected leader: %+v", ranks[0])
+	}
+	if ranks[1].Model != "steady" || ranks[1].Tokens != 7000 {
+		t.Fatalf("unexpected runner-up: %+v", ranks[1])
+	}
+}
@@ -4,10 +4,13 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
 )
 
 // ToolCall describes a single tool invocation requested by a model.
@@ -16,13 +19,51 @@ type ToolCall struct {
 	Name       string
 	Arguments  json.RawMessage
 	RawPayload string
+	// Malformed marks a call whose arguments could not be received whole, e.g. because
+	// they exceeded the size limit; it is answered with an error instead of being run.
+	Malformed bool
 }
 
+// ToolStatus classifies how a tool call ended so the model can react appropriately.
+type ToolStatus string
+
+const (
+	// ToolStatusOK means the tool ran and Content holds its output.
+	ToolStatusOK ToolStatus = "ok"
+	// ToolStatusTimeout means the tool exceeded its timeout; retrying may succeed.
+	ToolStatusTimeout ToolStatus = "timeout"
+	// ToolStatusError means the tool failed, was not found, or panicked.
+	ToolStatusError ToolStatus = "error"
+	// ToolStatusDenied means the user declined to run the tool.
+	ToolStatusDenied ToolStatus = "denied"
+)
+
 // ToolResult is the output returned from a tool execution.
 type ToolResult struct {
 	ID      string
 	Name    string
 	Content string
+	// Status is how the call ended; empty is treated as ToolStatusOK.
+	Status ToolStatus
+	// Error describes why a non-ok call did not produce output.
+	Error string
+}
+
+// Failed reports whether the result carries a non-ok status.
+func (r ToolResult) Failed() bool {
+	return r.Status != "" && r.Status != ToolStatusOK
+}
+
+// DeniedResults returns a denied result for each call, for use when confirmation is refused.
+func DeniedResults(calls []ToolCall, reason string) []ToolResult {
+	if reason == "" {
+		reason = "tool execution was denied by the user"
+	}
+	results := make([]ToolResult, len(calls))
+	for i, call := range calls {
+		results[i] = ToolResult{ID: call.ID, Name: call.Name, Status: ToolStatusDenied, Error: reason}
+	}
+	return results
 }
 
 // ToolHandler executes a single tool call.
@@ -58,6 +99,16 @@ func (r *RegistryMap) Register(name string, handler ToolHandler) {
 	r.mu.Unlock()
 }
 
+// Unregister removes a tool handler from the registry.
+func (r *RegistryMap) Unregister(name string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	delete(r.tools, strings.TrimSpace(name))
+	r.mu.Unlock()
+}
+
 // Get returns a tool handler by name.
 func (r *RegistryMap) Get(name string) (ToolHandler, bool) {
 	if r == nil {
@@ -90,9 +141,14 @@ type ExecuteOptions struct {
 	Parallel       bool
 	MaxConcurrency int
 	Timeout        time.Duration
+
+	// Tracer, when set, opens an "agent.tool" span around each tool call.
+	Tracer observability.Tracer
 }
 
 // ExecuteToolCalls runs tool calls through the registry and returns ordered results.
+// results[i] always belongs to calls[i]: parallel execution writes each result into its
+// call's slot, so completion order never affects position.
 func ExecuteToolCalls(ctx context.Context, calls []ToolCall, opts ExecuteOptions, registry Registry) []ToolResult {
 	if registry == nil {
 		registry = defaultRegistry
@@ -132,16 +188,59 @@ func ExecuteToolCalls(ctx context.Context, calls []ToolCall, opts ExecuteOptions
 	return results
 }
 
+// executeTool runs call, inside an "agent.tool" span when opts carries a tracer.
 func executeTool(ctx context.Context, call ToolCall, opts ExecuteOptions, registry Registry) ToolResult {
+	if opts.Tracer == nil {
+		return runTool(ctx, call, opts, registry)
+	}
+	ctx, span := opts.Tracer.Start(ctx, "agent.tool",
+		observability.WithSpanKind(observability.SpanKindInternal),
+		observability.WithAttributes(map[string]interface{}{
+			"tool.name":    call.Name,
+			"tool.call_id": call.ID,
+		}),
+	)
+	defer span.End()
+
+	result := runTool(ctx, call, opts, registry)
+	span.SetAttribute("tool.status", string(result.Status))
+	if result.Failed() {
+		span.SetStatus(observability.SpanStatusError, result.Error)
+	}
+	return result
+}
+
+func runTool(ctx context.Context, call ToolCall, opts ExecuteOptions, registry Registry) ToolResult {
+	if call.Malformed {
+		return ToolResult{
+			ID:     call.ID,
+			Name:   call.Name,
+			Status: ToolStatusError,
+			Error:  "malformed_arguments: the tool call arguments were too large or incomplete",
+		}
+	}
 	handler, ok := registry.Get(call.Name)
 	if !ok {
 		return ToolResult{
-			ID:      call.ID,
-			Name:    call.Name,
-			Content: fmt.Sprintf(`{"error":"tool_not_found","tool":"%s"}`, call.Name),
+			ID:     call.ID,
+			Name:   call.Name,
+			Status: ToolStatusError,
+			Error:  fmt.Sprintf("tool_not_found: %s", call.Name),
 		}
 	}
 
+	// Wait for a server-wide slot before the per-tool timeout starts.
+	release, err := acquireGlobalSlot(ctx)
+	if err != nil {
+		return ToolResult{
+			ID:     call.ID,
+			Name:   call.Name,
+			Status: ToolStatusError,
+			Error:  fmt.Sprintf("tool execution cancelled while waiting for a slot: %v", err),
+		}
+	}
+	defer release()
+
 	ctxCall := ctx
 	var cancel context.CancelFunc
 	if opts.Timeout > 0 {
@@ -151,12 +250,23 @@ func executeTool(ctx context.Context, call ToolCall, opts ExecuteOptions, regist
 
 	result, err := safeInvoke(ctxCall, call, handler)
 	if err != nil {
+		status := ToolStatusError
+		message := err.Error()
+		// Only the per-tool deadline counts as a timeout; a cancelled request is an error.
+		if opts.Timeout > 0 && errors.Is(ctxCall.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			status = ToolStatusTimeout
+			message = fmt.Sprintf("tool timed out after %s", opts.Timeout)
+		}
 		return ToolResult{
-			ID:      call.ID,
-			Name:    call.Name,
-			Content: fmt.Sprintf(`{"error":%q}`, err.Error()),
+			ID:     call.ID,
+			Name:   call.Name,
+			Status: status,
+			Error:  message,
 		}
 	}
+	if result.Status == "" {
+		result.Status = ToolStatusOK
+	}
 	if result.ID == "" {
 		result.ID = call.ID
 	}
@@ -0,0 +1,326 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
+)
+
+func statusTestRegistry() *RegistryMap {
+	registry := NewRegistry()
+	registry.Register("echo", func(_ context.Context, call ToolCall) (ToolResult, error) {
+		return ToolResult{Content: string(call.Arguments)}, nil
+	})
+	registry.Register("slow", func(ctx context.Context, _ ToolCall) (ToolResult, error) {
+		<-ctx.Done()
+		return ToolResult{}, ctx.Err()
+	})
+	registry.Register("broken", func(context.Context, ToolCall) (ToolResult, error) {
+		return ToolResult{}, errors.New("disk full")
+	})
+	registry.Register("panics", func(context.Context, ToolCall) (ToolResult, error) {
+		panic("boom")
+	})
+	return registry
+}
+
+func TestExecuteToolCalls_Statuses(t *testing.T) {
+	calls := []ToolCall{
+		{ID: "1", Name: "echo", Arguments: []byte(`{"x":1}`)},
+		{ID: "2", Name: "slow"},
+		{ID: "3", Name: "broken"},
+		{ID: "4", Name: "panics"},
+		{ID: "5", Name: "missing"},
+	}
+	results := ExecuteToolCalls(context.Background(), calls, ExecuteOptions{Timeout: 20 * time.Millisecond}, statusTestRegistry())
+
+	want := []struct {
+		status ToolStatus
+		error  string
+	}{
+		{ToolStatusOK, ""},
+		{ToolStatusTimeout, "tool timed out after 20ms"},
+		{ToolStatusError, "disk full"},
+		{ToolStatusError, "tool panic: boom"},
+		{ToolStatusError, "tool_not_found: missing"},
+	}
+	for i, w := range want {
+		got := results[i]
+		if got.ID != calls[i].ID || got.Name != calls[i].Name {
+			t.Errorf("result %d identity = %s/%s", i, got.ID, got.Name)
+		}
+		if got.Status != w.status || got.Error != w.error {
+			t.Errorf("result %d (%s) = status %q error %q, want %q %q", i, calls[i].Name, got.Status, got.Error, w.status, w.error)
+		}
+		if got.Failed() != (w.status != ToolStatusOK) {
+			t.Errorf("result %d Failed() = %v", i, got.Failed())
+		}
+	}
+	if results[0].Content != `{"x":1}` {
+		t.Errorf("ok result content = %q", results[0].Content)
+	}
+}
+
+func TestExecuteToolCalls_CancelledRequestIsNotTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results := ExecuteToolCalls(ctx, []ToolCall{{ID: "1", Name: "slow"}}, ExecuteOptions{Timeout: time.Second}, statusTestRegistry())
+	if results[0].Status != ToolStatusError {
+		t.Fatalf("status = %q, want error", results[0].Status)
+	}
+}
+
+func TestLoopExecuteTools_DeniedConfirmation(t *testing.T) {
+	loop := NewLoop(LoopConfig{
+		MaxIterations:       2,
+		RequireConfirmation: true,
+		OnConfirmation:      func(Iteration, []ToolCall) bool { return false },
+	}, statusTestRegistry())
+	loop.StartIteration(context.Background())
+	loop.RecordModelResponse([]byte(`{}`), []ToolCall{{ID: "1", Name: "echo"}, {ID: "2", Name: "broken"}}, "", TokenUsage{})
+
+	results := loop.ExecuteTools(context.Background())
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	for _, r := range results {
+		if r.Status != ToolStatusDenied || r.Error == "" || r.Content != "" {
+			t.Errorf("result %s = %+v, want denied without output", r.ID, r)
+		}
+	}
+	if got := loop.Iterations()[0].ToolResults; len(got) != 2 {
+		t.Errorf("denied results not recorded on the iteration: %+v", got)
+	}
+	if loop.State() != StateComplete {
+		t.Errorf("state = %s, want complete", loop.State())
+	}
+}
+
+func TestLoopShouldContinue_StopsAtTokenBudget(t *testing.T) {
+	loop := NewLoop(LoopConfig{MaxIterations: 10, MaxTotalTokens: 1000}, statusTestRegistry())
+	calls := []ToolCall{{ID: "1", Name: "echo"}}
+
+	iterations := 0
+	for loop.ShouldContinue() {
+		loop.StartIteration(context.Background())
+		iterations++
+		loop.RecordModelResponse([]byte(`{}`), calls, "", TokenUsage{PromptTokens: 300, CompletionTokens: 100, TotalTokens: 400})
+		loop.ExecuteTools(context.Background())
+	}
+
+	// 400 + 400 leaves room for a third iteration, which takes the total past the budget.
+	if iterations != 3 {
+		t.Fatalf("iterations = %d, want 3", iterations)
+	}
+	if loop.State() != StateTokenBudgetExceeded {
+		t.Fatalf("state = %s, want %s", loop.State(), StateTokenBudgetExceeded)
+	}
+	if got := loop.TotalTokensUsed().TotalTokens; got != 1200 {
+		t.Fatalf("total tokens = %d, want 1200", got)
+	}
+}
+
+func TestLoopShouldContinue_CompletedLoopIgnoresBudget(t *testing.T) {
+	loop := NewLoop(LoopConfig{MaxTotalTokens: 100}, statusTestRegistry())
+	loop.StartIteration(context.Background())
+	loop.RecordModelResponse([]byte(`{}`), nil, "", TokenUsage{TotalTokens: 500})
+
+	if loop.ShouldContinue() {
+		t.Fatal("completed loop should not continue")
+	}
+	if loop.State() != StateComplete {
+		t.Fatalf("state = %s, want complete", loop.State())
+	}
+}
+
+func TestExecuteToolCalls_ParallelResultsKeepCallOrder(t *testing.T) {
+	registry := NewRegistry()
+	var finished []string
+	var mu sync.Mutex
+	registry.Register("sleep", func(_ context.Context, call ToolCall) (ToolResult, error) {
+		d, _ := time.ParseDuration(string(call.Arguments))
+		time.Sleep(d)
+		mu.Lock()
+		finished = append(finished, call.ID)
+		mu.Unlock()
+		return ToolResult{Content: call.ID}, nil
+	})
+
+	durations := []string{"60ms", "5ms", "40ms", "1ms", "20ms"}
+	calls := make([]ToolCall, len(durations))
+	for i, d := range durations {
+		calls[i] = ToolCall{ID: fmt.Sprintf("call_%d", i), Name: "sleep", Arguments: []byte(d)}
+	}
+
+	start := time.Now()
+	results := ExecuteToolCalls(context.Background(), calls, ExecuteOptions{Parallel: true}, registry)
+	elapsed := time.Since(start)
+
+	if len(results) != len(calls) {
+		t.Fatalf("got %d results, want %d", len(results), len(calls))
+	}
+	for i, r := range results {
+		if r.ID != calls[i].ID || r.Content != calls[i].ID {
+			t.Errorf("results[%d] = %s/%s, want %s", i, r.ID, r.Content, calls[i].ID)
+		}
+	}
+	if finished[0] == calls[0].ID {
+		t.Errorf("slowest call finished first; calls did not run concurrently: %v", finished)
+	}
+	if elapsed >= 120*time.Millisecond {
+		t.Errorf("parallel execution took %s, expected roughly the slowest call", elapsed)
+	}
+}
+
+func TestGlobalMaxConcurrency_BoundsAllLoops(t *testing.T) {
+	const limit = 3
+	SetGlobalMaxConcurrency(limit)
+	t.Cleanup(func() { SetGlobalMaxConcurrency(0) })
+
+	var active, peak atomic.Int32
+	registry := NewRegistry()
+	registry.Register("work", func(context.Context, ToolCall) (ToolResult, error) {
+		n := active.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		active.Add(-1)
+		return ToolResult{Content: "done"}, nil
+	})
+
+	const loops, callsPerLoop = 6, 4
+	var wg sync.WaitGroup
+	for l := 0; l < loops; l++ {
+		wg.Add(1)
+		go func(l int) {
+			defer wg.Done()
+			loop := NewLoop(LoopConfig{MaxIterations: 1, ParallelToolCalls: true, MaxConcurrency: callsPerLoop}, registry)
+			loop.StartIteration(context.Background())
+			calls := make([]ToolCall, callsPerLoop)
+			for i := range calls {
+				calls[i] = ToolCall{ID: fmt.Sprintf("loop%d_call%d", l, i), Name: "work"}
+			}
+			loop.RecordModelResponse([]byte(`{}`), calls, "", TokenUsage{})
+			for _, r := range loop.ExecuteTools(context.Background()) {
+				if r.Status != ToolStatusOK {
+					t.Errorf("%s: status %q (%s)", r.ID, r.Status, r.Error)
+				}
+			}
+		}(l)
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got > limit {
+		t.Fatalf("peak tool concurrency %d exceeded the global cap %d", got, limit)
+	}
+	if got := peak.Load(); got < 2 {
+		t.Fatalf("peak tool concurrency %d; tools did not run concurrently", got)
+	}
+}
+
+func TestGlobalMaxConcurrency_CancelledWhileWaiting(t *testing.T) {
+	SetGlobalMaxConcurrency(1)
+	t.Cleanup(func() { SetGlobalMaxConcurrency(0) })
+
+	release, err := acquireGlobalSlot(context.Background())
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	results := ExecuteToolCalls(ctx, []ToolCall{{ID: "1", Name: "echo"}}, ExecuteOptions{}, statusTestRegistry())
+	if results[0].Status != ToolStatusError {
+		t.Fatalf("status = %q, want error while the only slot is held", results[0].Status)
+	}
+}
+
+func TestLoop_TracesIterationsAndToolsUnderRequestSpan(t *testing.T) {
+	tracer := observability.NewInMemoryTracer(100)
+	ctx, request := tracer.Start(context.Background(), "http.request")
+	requestID := request.SpanContext().SpanID
+
+	loop := NewLoop(LoopConfig{MaxIterations: 5, Tracer: tracer}, statusTestRegistry())
+	loop.StartIteration(ctx)
+	loop.RecordModelResponse([]byte(`{}`), []ToolCall{{ID: "1", Name: "echo"}, {ID: "2", Name: "broken"}}, "", TokenUsage{})
+	loop.ExecuteTools(ctx)
+	loop.StartIteration(ctx)
+	loop.RecordModelResponse([]byte(`{}`), nil, "", TokenUsage{})
+	request.End()
+
+	var iterations []*observability.InMemorySpan
+	tools := map[string]*observability.InMemorySpan{}
+	for _, span := range tracer.Spans() {
+		switch span.Name() {
+		case "agent.iteration":
+			iterations = append(iterations, span)
+		case "agent.tool":
+			tools[span.Attribute("tool.name").(string)] = span
+		}
+	}
+	if len(iterations) != 2 || len(tools) != 2 {
+		t.Fatalf("got %d iteration and %d tool spans, want 2 and 2", len(iterations), len(tools))
+	}
+	for i, span := range iterations {
+		if span.ParentSpanID() != requestID {
+			t.Errorf("iteration %d parent = %q, want the request span %q", i+1, span.ParentSpanID(), requestID)
+		}
+		if got := span.Attribute("agent.iteration"); got != i+1 {
+			t.Errorf("iteration %d agent.iteration = %v", i+1, got)
+		}
+	}
+	firstID := iterations[0].SpanContext().SpanID
+	for name, span := range tools {
+		if span.ParentSpanID() != firstID {
+			t.Errorf("tool %s parent = %q, want the first iteration %q", name, span.ParentSpanID(), firstID)
+		}
+	}
+	if code, _ := tools["echo"].Status(); code != observability.SpanStatusUnset {
+		t.Errorf("echo span status = %v, want unset", code)
+	}
+	if code, desc := tools["broken"].Status(); code != observability.SpanStatusError || desc != "disk full" {
+		t.Errorf("broken span status = %v %q, want error disk full", code, desc)
+	}
+}
+
+func TestLoop_RecordErrorMarksIterationSpan(t *testing.T) {
+	tracer := observability.NewInMemoryTracer(10)
+	loop := NewLoop(LoopConfig{Tracer: tracer}, statusTestRegistry())
+	loop.StartIteration(context.Background())
+	loop.RecordError(errors.New("upstream failed"))
+
+	spans := tracer.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	if code, desc := spans[0].Status(); code != observability.SpanStatusError || desc != "upstream failed" {
+		t.Fatalf("iteration span status = %v %q, want error", code, desc)
+	}
+}
+
+func TestLoop_FinishEndsOpenIterationSpan(t *testing.T) {
+	tracer := observability.NewInMemoryTracer(10)
+	loop := NewLoop(LoopConfig{Tracer: tracer}, statusTestRegistry())
+	loop.StartIteration(context.Background())
+	loop.RecordModelResponse([]byte(`{}`), []ToolCall{{ID: "1", Name: "echo"}}, "", TokenUsage{})
+	spans := tracer.Spans()
+	if len(spans) != 1 || spans[0].Ended() {
+		t.Fatalf("want one open iteration span before Finish, got %d", len(spans))
+	}
+	loop.Finish()
+	loop.Finish()
+	if !spans[0].Ended() {
+		t.Fatal("iteration span still open after Finish")
+	}
+}
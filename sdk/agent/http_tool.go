@@ -0,0 +1,286 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// HTTPRequestToolName is the registry name of the built-in HTTP tool.
+const HTTPRequestToolName = "http_request"
+
+const (
+	defaultHTTPToolMaxResponseBytes = 1 << 20
+	defaultHTTPToolTimeout          = 10 * time.Second
+)
+
+// HTTPToolConfig configures the built-in http_request tool.
+type HTTPToolConfig struct {
+	// AllowedHosts lists the hosts the tool may call. Entries match exactly or, with a
+	// leading "*.", any subdomain. An empty list allows no hosts.
+	AllowedHosts []string
+	// AllowedSchemes defaults to https only.
+	AllowedSchemes []string
+	// AllowedCIDRs exempts networks from the private/link-local block, e.g. an internal API.
+	AllowedCIDRs []string
+	// MaxResponseBytes caps the returned body; defaults to 1 MiB.
+	MaxResponseBytes int64
+	// Timeout bounds the whole request; defaults to 10s.
+	Timeout time.Duration
+}
+
+// blockedNetworks are never dialed unless listed in AllowedCIDRs, on top of the
+// loopback, private, link-local, multicast and unspecified ranges net.IP reports.
+var blockedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",     // "this" network
+	"100.64.0.0/10", // carrier-grade NAT
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+	"240.0.0.0/4",   // reserved
+	"64:ff9b::/96",  // NAT64, can embed private IPv4
+)
+
+type httpToolArgs struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+type httpToolResult struct {
+	Status    int    `json:"status"`
+	Body      string `json:"body"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// NewHTTPRequestTool builds an http_request handler that only reaches allowlisted hosts
+// over allowlisted schemes and refuses to connect to private, loopback or link-local
+// addresses. The address check runs on the dialed IP, so DNS answers and redirects that
+// point inside the network are refused as well. Arguments are
+// {"url", "method", "headers", "body"}; the result is {"status", "body", "truncated"}.
+func NewHTTPRequestTool(cfg HTTPToolConfig) (ToolHandler, error) {
+	allowedNets, err := parseCIDRs(cfg.AllowedCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	schemes := cfg.AllowedSchemes
+	if len(schemes) == 0 {
+		schemes = []string{"https"}
+	}
+	maxBytes := cfg.MaxResponseBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultHTTPToolMaxResponseBytes
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultHTTPToolTimeout
+	}
+
+	checkURL := func(u *url.URL) error {
+		if !containsFold(schemes, u.Scheme) {
+			return fmt.Errorf("scheme %q is not allowed", u.Scheme)
+		}
+		if !hostAllowed(u.Hostname(), cfg.AllowedHosts) {
+			return fmt.Errorf("host %q is not allowed", u.Hostname())
+		}
+		return nil
+	}
+
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return fmt.Errorf("refusing to dial unresolved address %q", host)
+			}
+			if ipBlocked(ip, allowedNets) {
+				return fmt.Errorf("address %s is in a blocked network", ip)
+			}
+			return nil
+		},
+	}
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        16,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			return checkURL(req.URL)
+		},
+	}
+
+	return func(ctx context.Context, call ToolCall) (ToolResult, error) {
+		var args httpToolArgs
+		if err := json.Unmarshal(call.Arguments, &args); err != nil {
+			return ToolResult{}, fmt.Errorf("invalid arguments: %w", err)
+		}
+		target, err := url.Parse(strings.TrimSpace(args.URL))
+		if err != nil || target.Host == "" {
+			return ToolResult{}, fmt.Errorf("invalid url %q", args.URL)
+		}
+		if err := checkURL(target); err != nil {
+			return ToolResult{}, err
+		}
+		method := strings.ToUpper(strings.TrimSpace(args.Method))
+		if method == "" {
+			method = http.MethodGet
+		}
+
+		reqCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		var body io.Reader
+		if args.Body != "" {
+			body = strings.NewReader(args.Body)
+		}
+		req, err := http.NewRequestWithContext(reqCtx, method, target.String(), body)
+		if err != nil {
+			return ToolResult{}, err
+		}
+		for name, value := range args.Headers {
+			req.Header.Set(name, value)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return ToolResult{}, err
+		}
+		defer func() { _ = resp.Body.Close() }()
+
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+		if err != nil {
+			return ToolResult{}, err
+		}
+		out := httpToolResult{Status: resp.StatusCode}
+		if int64(len(data)) > maxBytes {
+			data = data[:maxBytes]
+			out.Truncated = true
+		}
+		out.Body = string(data)
+		encoded, err := json.Marshal(out)
+		if err != nil {
+			return ToolResult{}, err
+		}
+		return ToolResult{Content: string(encoded)}, nil
+	}, nil
+}
+
+var (
+	httpToolMu  sync.Mutex
+	httpToolCfg *HTTPToolConfig
+)
+
+// ConfigureHTTPRequestTool registers http_request in the default registry when enabled
+// and removes it otherwise. The handler is only rebuilt when cfg changes, so reapplying an
+// unchanged configuration keeps its connection pool. When cfg is invalid the tool is
+// removed, rather than left running with the previous configuration.
+func ConfigureHTTPRequestTool(enabled bool, cfg HTTPToolConfig) error {
+	httpToolMu.Lock()
+	defer httpToolMu.Unlock()
+	if !enabled {
+		if httpToolCfg != nil {
+			defaultRegistry.Unregister(HTTPRequestToolName)
+			httpToolCfg = nil
+		}
+		return nil
+	}
+	if httpToolCfg != nil && reflect.DeepEqual(*httpToolCfg, cfg) {
+		return nil
+	}
+	handler, err := NewHTTPRequestTool(cfg)
+	if err != nil {
+		if httpToolCfg != nil {
+			defaultRegistry.Unregister(HTTPRequestToolName)
+			httpToolCfg = nil
+		}
+		return err
+	}
+	defaultRegistry.Register(HTTPRequestToolName, handler)
+	stored := cfg
+	httpToolCfg = &stored
+	return nil
+}
+
+func hostAllowed(host string, allowed []string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, entry := range allowed {
+		entry = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(entry)), ".")
+		if suffix, ok := strings.CutPrefix(entry, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if host == entry {
+			return true
+		}
+	}
+	return false
+}
+
+func ipBlocked(ip net.IP, allowed []*net.IPNet) bool {
+	for _, n := range allowed {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return true
+	}
+	for _, n := range blockedNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, v string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(strings.TrimSpace(candidate), v) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCIDRs(values []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		_, n, err := net.ParseCIDR(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", value, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func mustParseCIDRs(values ...string) []*net.IPNet {
+	nets, err := parseCIDRs(values)
+	if err != nil {
+		panic(err)
+	}
+	return nets
+}
@@ -0,0 +1,166 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newLocalHTTPTool(t *testing.T, server *httptest.Server, cfg HTTPToolConfig) ToolHandler {
+	t.Helper()
+	u, _ := url.Parse(server.URL)
+	cfg.AllowedHosts = append(cfg.AllowedHosts, u.Hostname())
+	cfg.AllowedSchemes = []string{"http"}
+	cfg.AllowedCIDRs = append(cfg.AllowedCIDRs, "127.0.0.0/8")
+	handler, err := NewHTTPRequestTool(cfg)
+	if err != nil {
+		t.Fatalf("NewHTTPRequestTool: %v", err)
+	}
+	return handler
+}
+
+func httpToolCall(rawURL string) ToolCall {
+	args, _ := json.Marshal(map[string]string{"url": rawURL})
+	return ToolCall{ID: "call_http", Name: HTTPRequestToolName, Arguments: args}
+}
+
+func TestHTTPRequestTool_BlocksPrivateAddresses(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { hits++ }))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	// The host is allowlisted but resolves to loopback, which is blocked without AllowedCIDRs.
+	handler, err := NewHTTPRequestTool(HTTPToolConfig{
+		AllowedHosts:   []string{u.Hostname(), "169.254.169.254", "10.1.2.3"},
+		AllowedSchemes: []string{"http"},
+		Timeout:        time.Second,
+	})
+	if err != nil {
+		t.Fatalf("NewHTTPRequestTool: %v", err)
+	}
+	for _, target := range []string{server.URL, "http://169.254.169.254/latest/meta-data/", "http://10.1.2.3/"} {
+		_, err := handler(context.Background(), httpToolCall(target))
+		if err == nil || !strings.Contains(err.Error(), "blocked network") {
+			t.Errorf("%s: expected blocked network error, got %v", target, err)
+		}
+	}
+	if hits != 0 {
+		t.Fatalf("blocked request reached the server %d times", hits)
+	}
+}
+
+func TestHTTPRequestTool_AllowlistAndScheme(t *testing.T) {
+	handler, err := NewHTTPRequestTool(HTTPToolConfig{AllowedHosts: []string{"*.example.com"}})
+	if err != nil {
+		t.Fatalf("NewHTTPRequestTool: %v", err)
+	}
+	cases := map[string]string{
+		"https://evil.test/":          "host \"evil.test\" is not allowed",
+		"http://api.example.com/":     "scheme \"http\" is not allowed",
+		"https://example.com.evil.io": "is not allowed",
+		"file:///etc/passwd":          "invalid url",
+	}
+	for target, want := range cases {
+		if _, err := handler(context.Background(), httpToolCall(target)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error = %v, want it to contain %q", target, err, want)
+		}
+	}
+}
+
+func TestHTTPRequestTool_AllowedHostReturnsStatusAndBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("hello " + r.Method))
+	}))
+	defer server.Close()
+
+	result, err := newLocalHTTPTool(t, server, HTTPToolConfig{})(context.Background(), httpToolCall(server.URL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var out httpToolResult
+	if err := json.Unmarshal([]byte(result.Content), &out); err != nil {
+		t.Fatalf("content is not JSON: %q", result.Content)
+	}
+	if out.Status != http.StatusCreated || out.Body != "hello GET" || out.Truncated {
+		t.Fatalf("result = %+v", out)
+	}
+}
+
+func TestHTTPRequestTool_RedirectOutsideAllowlist(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://internal.test/admin", http.StatusFound)
+	}))
+	defer server.Close()
+
+	_, err := newLocalHTTPTool(t, server, HTTPToolConfig{})(context.Background(), httpToolCall(server.URL))
+	if err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Fatalf("redirect to a non-allowlisted host must fail, got %v", err)
+	}
+}
+
+func TestHTTPRequestTool_SizeAndTimeoutCaps(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-time.After(2 * time.Second):
+			case <-r.Context().Done():
+			}
+			return
+		}
+		_, _ = w.Write([]byte(strings.Repeat("a", 5000)))
+	}))
+	defer server.Close()
+
+	handler := newLocalHTTPTool(t, server, HTTPToolConfig{MaxResponseBytes: 100, Timeout: 100 * time.Millisecond})
+
+	result, err := handler(context.Background(), httpToolCall(server.URL+"/big"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var out httpToolResult
+	_ = json.Unmarshal([]byte(result.Content), &out)
+	if len(out.Body) != 100 || !out.Truncated {
+		t.Fatalf("body length %d truncated=%v, want 100 and true", len(out.Body), out.Truncated)
+	}
+
+	start := time.Now()
+	if _, err := handler(context.Background(), httpToolCall(server.URL+"/slow")); err == nil {
+		t.Fatal("slow request should time out")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("timeout not enforced, request took %s", elapsed)
+	}
+}
+
+func TestConfigureHTTPRequestTool_RegistersAndRemoves(t *testing.T) {
+	t.Cleanup(func() { _ = ConfigureHTTPRequestTool(false, HTTPToolConfig{}) })
+
+	if err := ConfigureHTTPRequestTool(true, HTTPToolConfig{AllowedHosts: []string{"api.example.com"}}); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	if _, ok := DefaultRegistry().Get(HTTPRequestToolName); !ok {
+		t.Fatal("http_request not registered")
+	}
+	if err := ConfigureHTTPRequestTool(true, HTTPToolConfig{AllowedCIDRs: []string{"not-a-cidr"}}); err == nil {
+		t.Fatal("invalid CIDR should be rejected")
+	}
+	if _, ok := DefaultRegistry().Get(HTTPRequestToolName); ok {
+		t.Fatal("http_request kept its previous configuration after an invalid update")
+	}
+	if err := ConfigureHTTPRequestTool(true, HTTPToolConfig{AllowedHosts: []string{"api.example.com"}}); err != nil {
+		t.Fatalf("reconfigure: %v", err)
+	}
+	if err := ConfigureHTTPRequestTool(false, HTTPToolConfig{}); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	if _, ok := DefaultRegistry().Get(HTTPRequestToolName); ok {
+		t.Fatal("http_request still registered after disabling")
+	}
+}
@@ -0,0 +1,55 @@
+package agent
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// toolLimiter is a process-wide semaphore for tool executions. It is replaced, never
+// mutated, when the limit changes so in-flight tools release into the semaphore they
+// acquired from.
+type toolLimiter struct {
+	limit int
+	sem   chan struct{}
+}
+
+var globalToolLimiter atomic.Pointer[toolLimiter]
+
+// SetGlobalMaxConcurrency bounds concurrent tool executions across every loop in the
+// process, on top of each loop's own MaxConcurrency. n <= 0 removes the bound. Changing
+// the limit does not interrupt tools already running.
+func SetGlobalMaxConcurrency(n int) {
+	if n < 0 {
+		n = 0
+	}
+	if current := globalToolLimiter.Load(); current != nil && current.limit == n {
+		return
+	}
+	if n == 0 {
+		globalToolLimiter.Store(nil)
+		return
+	}
+	globalToolLimiter.Store(&toolLimiter{limit: n, sem: make(chan struct{}, n)})
+}
+
+// GlobalMaxConcurrency returns the server-wide tool concurrency limit; 0 means unbounded.
+func GlobalMaxConcurrency() int {
+	if l := globalToolLimiter.Load(); l != nil {
+		return l.limit
+	}
+	return 0
+}
+
+// acquireGlobalSlot waits for a server-wide tool slot and returns its release function.
+func acquireGlobalSlot(ctx context.Context) (func(), error) {
+	l := globalToolLimiter.Load()
+	if l == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.sem <- struct{}{}:
+		return func() { <-l.sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
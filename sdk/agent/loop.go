@@ -7,6 +7,8 @@ import (
 	"encoding/json"
 	"sync"
 	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
 )
 
 // AgentState represents the current state of an agent loop.
@@ -33,6 +35,9 @@ const (
 
 	// StateMaxIterations means the agent reached max iterations.
 	StateMaxIterations AgentState = "max_iterations"
+
+	// StateTokenBudgetExceeded means the agent used up its token budget.
+	StateTokenBudgetExceeded AgentState = "token_budget_exceeded"
 )
 
 // Iteration represents a single iteration of the agent loop.
@@ -88,6 +93,10 @@ type LoopConfig struct {
 	// MaxIterations limits the number of loop iterations.
 	MaxIterations int
 
+	// MaxTotalTokens stops the loop once the tokens used across all iterations reach it,
+	// even before MaxIterations. 0 disables the budget.
+	MaxTotalTokens int64
+
 	// ParallelToolCalls enables parallel tool execution.
 	ParallelToolCalls bool
 
@@ -108,6 +117,10 @@ type LoopConfig struct {
 
 	// StopSequences are strings that trigger loop termination.
 	StopSequences []string
+
+	// Tracer records an "agent.iteration" span per iteration and an "agent.tool" span per
+	// tool call. Nil uses the global tracer.
+	Tracer observability.Tracer
 }
 
 // DefaultLoopConfig returns sensible defaults.
@@ -127,6 +140,9 @@ type Loop struct {
 	iterations []Iteration
 	state      AgentState
 	mu         sync.RWMutex
+
+	// iterationSpan is the open span of the current iteration, nil once it has ended.
+	iterationSpan observability.Span
 }
 
 // NewLoop creates a new agent loop with the given config.
@@ -192,21 +208,46 @@ func (l *Loop) TotalTokensUsed() TokenUsage {
 	return total
 }
 
-// StartIteration begins a new iteration.
-func (l *Loop) StartIteration() *Iteration {
+// tracer returns the configured tracer, or the global one.
+func (l *Loop) tracer() observability.Tracer {
+	if l.config.Tracer != nil {
+		return l.config.Tracer
+	}
+	return observability.GetTracer()
+}
+
+// StartIteration begins a new iteration and opens its "agent.iteration" span as a child of
+// the span in ctx, normally the HTTP request span.
+func (l *Loop) StartIteration(ctx context.Context) *Iteration {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	l.endIterationSpanLocked()
 	iter := Iteration{
 		Number:    len(l.iterations) + 1,
 		State:     StateThinking,
 		StartTime: time.Now(),
 	}
+	_, l.iterationSpan = l.tracer().Start(ctx, "agent.iteration",
+		observability.WithSpanKind(observability.SpanKindInternal),
+		observability.WithAttributes(map[string]interface{}{
+			"agent.iteration": iter.Number,
+		}),
+	)
 	l.iterations = append(l.iterations, iter)
 	l.state = StateThinking
 	return &iter
 }
 
+// endIterationSpanLocked ends the current iteration's span, if still open. l.mu must be held.
+func (l *Loop) endIterationSpanLocked() {
+	if l.iterationSpan == nil {
+		return
+	}
+	l.iterationSpan.End()
+	l.iterationSpan = nil
+}
+
 // RecordModelResponse records the model's response for the current iteration.
 func (l *Loop) RecordModelResponse(response []byte, toolCalls []ToolCall, thinking string, tokens TokenUsage) {
 	l.mu.Lock()
@@ -221,6 +262,10 @@ func (l *Loop) RecordModelResponse(response []byte, toolCalls []ToolCall, thinki
 	l.iterations[idx].ToolCalls = toolCalls
 	l.iterations[idx].ThinkingContent = thinking
 	l.iterations[idx].TokensUsed = tokens
+	if l.iterationSpan != nil {
+		l.iterationSpan.SetAttribute("agent.tool_calls", len(toolCalls))
+		l.iterationSpan.SetAttribute("agent.total_tokens", tokens.TotalTokens)
+	}
 
 	if len(toolCalls) > 0 {
 		l.iterations[idx].State = StateExecutingTools
@@ -229,7 +274,25 @@ func (l *Loop) RecordModelResponse(response []byte, toolCalls []ToolCall, thinki
 		l.iterations[idx].State = StateComplete
 		l.iterations[idx].EndTime = time.Now()
 		l.state = StateComplete
+		l.endIterationSpanLocked()
+	}
+}
+
+// RecordUsage adds tokens spent outside the model call, such as compacting the history, to
+// the current iteration so they count against MaxTotalTokens.
+func (l *Loop) RecordUsage(tokens TokenUsage) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.iterations) == 0 {
+		return
 	}
+
+	used := &l.iterations[len(l.iterations)-1].TokensUsed
+	used.PromptTokens += tokens.PromptTokens
+	used.CompletionTokens += tokens.CompletionTokens
+	used.ThinkingTokens += tokens.ThinkingTokens
+	used.TotalTokens += tokens.TotalTokens
 }
 
 // RecordToolResults records tool execution results.
@@ -244,6 +307,7 @@ func (l *Loop) RecordToolResults(results []ToolResult) {
 	idx := len(l.iterations) - 1
 	l.iterations[idx].ToolResults = results
 	l.iterations[idx].EndTime = time.Now()
+	l.endIterationSpanLocked()
 }
 
 // RecordError records an error in the current iteration.
@@ -260,6 +324,11 @@ func (l *Loop) RecordError(err error) {
 	l.iterations[idx].Error = err.Error()
 	l.iterations[idx].EndTime = time.Now()
 	l.state = StateError
+	if l.iterationSpan != nil {
+		l.iterationSpan.RecordError(err)
+		l.iterationSpan.SetStatus(observability.SpanStatusError, err.Error())
+		l.endIterationSpanLocked()
+	}
 }
 
 // ShouldContinue determines if the loop should continue.
@@ -272,10 +341,21 @@ func (l *Loop) ShouldContinue() bool {
 		return false
 	}
 
-	if l.state == StateError || l.state == StateComplete || l.state == StateMaxIterations {
+	if l.state == StateError || l.state == StateComplete || l.state == StateMaxIterations || l.state == StateTokenBudgetExceeded {
 		return false
 	}
 
+	if l.config.MaxTotalTokens > 0 {
+		var used int64
+		for _, iter := range l.iterations {
+			used += iter.TokensUsed.TotalTokens
+		}
+		if used >= l.config.MaxTotalTokens {
+			l.state = StateTokenBudgetExceeded
+			return false
+		}
+	}
+
 	// Check if last iteration had tool calls (need to continue)
 	if len(l.iterations) > 0 {
 		lastIter := l.iterations[len(l.iterations)-1]
@@ -285,7 +365,8 @@ func (l *Loop) ShouldContinue() bool {
 	return true
 }
 
-// ExecuteTools executes the tool calls from the current iteration.
+// ExecuteTools executes the tool calls from the current iteration. Their spans are children
+// of the iteration's span; ctx only bounds their execution.
 func (l *Loop) ExecuteTools(ctx context.Context) []ToolResult {
 	l.mu.RLock()
 	if len(l.iterations) == 0 {
@@ -294,6 +375,11 @@ func (l *Loop) ExecuteTools(ctx context.Context) []ToolResult {
 	}
 	idx := len(l.iterations) - 1
 	toolCalls := l.iterations[idx].ToolCalls
+	if l.iterationSpan != nil {
+		if sc := l.iterationSpan.SpanContext(); sc.SpanID != "" {
+			ctx = observability.ContextWithSpanContext(ctx, sc)
+		}
+	}
 	l.mu.RUnlock()
 
 	if len(toolCalls) == 0 {
@@ -308,12 +394,14 @@ func (l *Loop) ExecuteTools(ctx context.Context) []ToolResult {
 		l.mu.Unlock()
 
 		if !l.config.OnConfirmation(l.iterations[idx], toolCalls) {
+			results := DeniedResults(toolCalls, "")
+			l.RecordToolResults(results)
 			l.mu.Lock()
 			l.state = StateComplete
 			l.iterations[idx].State = StateComplete
 			l.iterations[idx].EndTime = time.Now()
 			l.mu.Unlock()
-			return nil
+			return results
 		}
 	}
 
@@ -326,6 +414,7 @@ func (l *Loop) ExecuteTools(ctx context.Context) []ToolResult {
 		Parallel:       l.config.ParallelToolCalls,
 		MaxConcurrency: l.config.MaxConcurrency,
 		Timeout:        l.config.ToolTimeout,
+		Tracer:         l.tracer(),
 	}, l.registry)
 
 	l.RecordToolResults(results)
@@ -346,12 +435,22 @@ func (l *Loop) MarkComplete() {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	l.state = StateComplete
+	l.endIterationSpanLocked()
+}
+
+// Finish ends the current iteration's span if the loop stopped without recording an
+// outcome for it. It is safe to call more than once.
+func (l *Loop) Finish() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.endIterationSpanLocked()
 }
 
 // Reset resets the loop for reuse.
 func (l *Loop) Reset() {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	l.endIterationSpanLocked()
 	l.iterations = nil
 	l.state = StateIdle
 }
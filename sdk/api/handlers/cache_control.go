@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CacheControlHeader lets a client bypass the response caches for one request:
+//
+//	X-Cache-Control: no-cache   skip the lookup; the fresh response is still stored
+//	X-Cache-Control: no-store   skip the lookup and do not store the response
+//
+// Directives are case-insensitive and may be comma-separated; unknown ones are ignored.
+// The header only changes whether a cache is consulted, never how its keys are derived.
+const CacheControlHeader = "X-Cache-Control"
+
+// CacheControl is the cache bypass a request asked for through CacheControlHeader.
+type CacheControl struct {
+	// SkipLookup forces a fresh upstream response.
+	SkipLookup bool
+	// SkipStore keeps the response out of the cache.
+	SkipStore bool
+}
+
+// ParseCacheControl parses a CacheControlHeader value.
+func ParseCacheControl(value string) CacheControl {
+	var cc CacheControl
+	for _, directive := range strings.Split(value, ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "no-cache":
+			cc.SkipLookup = true
+		case "no-store":
+			cc.SkipLookup = true
+			cc.SkipStore = true
+		}
+	}
+	return cc
+}
+
+// RequestCacheControl returns the cache bypass requested by c.
+func RequestCacheControl(c *gin.Context) CacheControl {
+	if c == nil || c.Request == nil {
+		return CacheControl{}
+	}
+	return ParseCacheControl(c.GetHeader(CacheControlHeader))
+}
+
+// cacheControlFromContext returns the cache bypass requested by the request behind ctx.
+func cacheControlFromContext(ctx context.Context) CacheControl {
+	if ctx == nil {
+		return CacheControl{}
+	}
+	ginCtx, _ := ctx.Value("gin").(*gin.Context)
+	return RequestCacheControl(ginCtx)
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseCacheControl(t *testing.T) {
+	cases := map[string]CacheControl{
+		"":                    {},
+		"no-cache":            {SkipLookup: true},
+		" No-Cache ":          {SkipLookup: true},
+		"no-store":            {SkipLookup: true, SkipStore: true},
+		"max-age=0, NO-STORE": {SkipLookup: true, SkipStore: true},
+		"private":             {},
+	}
+	for value, want := range cases {
+		if got := ParseCacheControl(value); got != want {
+			t.Errorf("ParseCacheControl(%q) = %+v, want %+v", value, got, want)
+		}
+	}
+}
+
+func cacheControlContext(value string) context.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	c.Request.Header.Set(CacheControlHeader, value)
+	return context.WithValue(context.Background(), "gin", c)
+}
+
+func TestExecuteWithAuthManager_NoCacheCallsUpstreamAndStores(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := `{"error":{"type":"invalid_request_error","code":"context_length_exceeded","message":"too long"}}`
+	handler, executor := newNegativeCacheTestHandler(t, http.StatusBadRequest, body)
+	payload := []byte(`{"model":"negative-model","messages":[{"role":"user","content":"hi"}]}`)
+
+	handler.ExecuteWithAuthManager(context.Background(), "openai", "negative-model", payload, "")
+	calls := executor.calls
+
+	_, errMsg := handler.ExecuteWithAuthManager(cacheControlContext("no-cache"), "openai", "negative-model", payload, "")
+	if errMsg == nil || errMsg.Addon.Get(CacheHeader) != "" {
+		t.Fatalf("no-cache must skip the cached error, got %+v", errMsg)
+	}
+	if executor.calls == calls {
+		t.Fatal("no-cache must call upstream")
+	}
+	if negativeCache().Stats().Size != 1 {
+		t.Fatal("no-cache must still store the fresh error")
+	}
+}
+
+func TestExecuteWithAuthManager_NoStoreSkipsReadAndWrite(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := `{"error":{"type":"invalid_request_error","code":"context_length_exceeded","message":"too long"}}`
+	handler, executor := newNegativeCacheTestHandler(t, http.StatusBadRequest, body)
+	payload := []byte(`{"model":"negative-model","messages":[{"role":"user","content":"hi"}]}`)
+
+	for i := 0; i < 2; i++ {
+		calls := executor.calls
+		_, errMsg := handler.ExecuteWithAuthManager(cacheControlContext("no-store"), "openai", "negative-model", payload, "")
+		if errMsg == nil || errMsg.Addon.Get(CacheHeader) != "" {
+			t.Fatalf("request %d: expected an upstream error, got %+v", i, errMsg)
+		}
+		if executor.calls == calls {
+			t.Fatalf("request %d: no-store must call upstream", i)
+		}
+	}
+	if negativeCache().Stats().Size != 0 {
+		t.Fatal("no-store must not store the error")
+	}
+}
@@ -76,6 +76,11 @@ func (h *ClaudeCodeAPIHandler) ClaudeMessages(c *gin.Context) {
 		return
 	}
 
+	if handlers.IsDryRun(c) {
+		c.JSON(http.StatusOK, h.DryRun(c.Request.Context(), rawJSON))
+		return
+	}
+
 	// Check if the client requested a streaming response.
 	streamResult := gjson.GetBytes(rawJSON, "stream")
 	if !streamResult.Exists() || streamResult.Type == gjson.False {
@@ -252,6 +257,7 @@ func (h *ClaudeCodeAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON [
 
 			// Write the first chunk
 			if len(chunk) > 0 {
+				handlers.WriteSSEEventFields(c, chunk)
 				_, _ = c.Writer.Write(chunk)
 				flusher.Flush()
 			}
@@ -269,6 +275,7 @@ func (h *ClaudeCodeAPIHandler) forwardClaudeStream(c *gin.Context, flusher http.
 			if len(chunk) == 0 {
 				return
 			}
+			handlers.WriteSSEEventFields(c, chunk)
 			_, _ = c.Writer.Write(chunk)
 		},
 		WriteTerminalError: func(errMsg *interfaces.ErrorMessage) {
@@ -284,6 +291,10 @@ func (h *ClaudeCodeAPIHandler) forwardClaudeStream(c *gin.Context, flusher http.
 			errorBytes, _ := json.Marshal(h.toClaudeError(errMsg))
 			_, _ = fmt.Fprintf(c.Writer, "event: error\ndata: %s\n\n", errorBytes)
 		},
+		WriteSyntheticFinish: func() {
+			_, _ = fmt.Fprint(c.Writer, "event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\",\"stop_sequence\":null},\"usage\":{\"output_tokens\":0}}\n\n")
+			_, _ = fmt.Fprint(c.Writer, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+		},
 	})
 }
 
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+)
+
+// requestCoalescer returns the in-flight map shared by identical non-streaming requests.
+// Replaced in tests.
+var requestCoalescer = func() *cache.RequestDeduplicator {
+	return cache.GetRequestDeduplicator()
+}
+
+// coalesceKey returns the key under which concurrent identical non-streaming requests share
+// one upstream call, or "" when they must not. Requests are only coalesced while response
+// caching is enabled, since a shared response is what a cache hit would have served, and
+// never when the client asked for a fresh response. A cache prime always runs on its own,
+// so the response it stores is its own and not that of a request differing in max tokens.
+func (h *BaseAPIHandler) coalesceKey(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) string {
+	if h.Cfg == nil || !h.Cfg.Cache.Enabled || cacheControlFromContext(ctx).SkipLookup || isCachePriming(ctx) {
+		return ""
+	}
+	return handlerType + ":" + alt + ":" + cache.RequestCacheKey(requestCacheKeyConfig(h.Cfg), modelName, rawJSON)
+}
+
+// coalescedError carries an ErrorMessage through the deduplicator's error result.
+type coalescedError struct {
+	msg *interfaces.ErrorMessage
+}
+
+func (e *coalescedError) Error() string { return e.msg.Error.Error() }
+
+func (e *coalescedError) Unwrap() error { return e.msg.Error }
+
+// executeCoalesced runs fn, or waits for an identical in-flight call under key and returns
+// its result. Each waiter stops waiting when its own ctx is done.
+func executeCoalesced(ctx context.Context, key string, fn func() ([]byte, *interfaces.ErrorMessage)) ([]byte, *interfaces.ErrorMessage) {
+	if key == "" {
+		return fn()
+	}
+	resp, err, shared := requestCoalescer().DoContext(ctx, key, func() ([]byte, error) {
+		resp, errMsg := fn()
+		if errMsg != nil {
+			return nil, &coalescedError{msg: errMsg}
+		}
+		return resp, nil
+	})
+	if err != nil {
+		var ce *coalescedError
+		if !errors.As(err, &ce) {
+			// This caller's context ended while it waited.
+			status := http.StatusRequestTimeout
+			if errors.Is(err, context.DeadlineExceeded) {
+				status = http.StatusGatewayTimeout
+			}
+			return nil, &interfaces.ErrorMessage{StatusCode: status, Error: err}
+		}
+		if !shared {
+			return nil, ce.msg
+		}
+		return nil, &interfaces.ErrorMessage{StatusCode: ce.msg.StatusCode, Error: ce.msg.Error, Addon: ce.msg.Addon.Clone()}
+	}
+	if shared {
+		return cloneBytes(resp), nil
+	}
+	return resp, nil
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// gatedExecutor counts calls and holds each one until release is closed.
+type gatedExecutor struct {
+	calls   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (e *gatedExecutor) Identifier() string { return "codex" }
+
+func (e *gatedExecutor) Execute(ctx context.Context, _ *coreauth.Auth, _ coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	if e.calls.Add(1) == 1 {
+		close(e.started)
+	}
+	select {
+	case <-e.release:
+		return coreexecutor.Response{Payload: []byte(`{"id":"shared"}`)}, nil
+	case <-ctx.Done():
+		return coreexecutor.Response{}, ctx.Err()
+	}
+}
+
+func (e *gatedExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (e *gatedExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *gatedExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (e *gatedExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func newCoalesceTestHandler(t *testing.T) (*BaseAPIHandler, *gatedExecutor) {
+	t.Helper()
+	dedup := cache.NewRequestDeduplicator()
+	previous := requestCoalescer
+	requestCoalescer = func() *cache.RequestDeduplicator { return dedup }
+	previousNegative := negativeCache
+	negativeCache = func() *cache.NegativeCache { return nil }
+	previousResponses := responseCache
+	responseCache = func() responseStore { return nil }
+	t.Cleanup(func() {
+		requestCoalescer = previous
+		negativeCache = previousNegative
+		responseCache = previousResponses
+	})
+
+	executor := &gatedExecutor{started: make(chan struct{}), release: make(chan struct{})}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "coalesce-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "coalesce-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Cache.Enabled = true
+	return NewBaseAPIHandlers(cfg, manager), executor
+}
+
+func TestExecuteWithAuthManager_CoalescesConcurrentIdenticalRequests(t *testing.T) {
+	handler, executor := newCoalesceTestHandler(t)
+	payload := []byte(`{"model":"coalesce-model","messages":[{"role":"user","content":"hi"}]}`)
+
+	const callers = 50
+	var ready, done sync.WaitGroup
+	ready.Add(callers)
+	done.Add(callers)
+	responses := make([]string, callers)
+	for i := 0; i < callers; i++ {
+		go func(i int) {
+			defer done.Done()
+			ready.Done()
+			resp, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "coalesce-model", payload, "")
+			if errMsg != nil {
+				t.Errorf("caller %d: unexpected error %v", i, errMsg.Error)
+				return
+			}
+			responses[i] = string(resp)
+		}(i)
+	}
+
+	ready.Wait()
+	<-executor.started
+	// Give every caller time to join the in-flight call before it completes.
+	time.Sleep(100 * time.Millisecond)
+	close(executor.release)
+	done.Wait()
+
+	if got := executor.calls.Load(); got != 1 {
+		t.Fatalf("upstream calls = %d, want 1", got)
+	}
+	for i, resp := range responses {
+		if resp != `{"id":"shared"}` {
+			t.Fatalf("caller %d response = %q", i, resp)
+		}
+	}
+}
+
+func TestExecuteWithAuthManager_CoalescedWaiterHonorsItsContext(t *testing.T) {
+	handler, executor := newCoalesceTestHandler(t)
+	payload := []byte(`{"model":"coalesce-model","messages":[{"role":"user","content":"hi"}]}`)
+
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		handler.ExecuteWithAuthManager(context.Background(), "openai", "coalesce-model", payload, "")
+	}()
+	<-executor.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, errMsg := handler.ExecuteWithAuthManager(ctx, "openai", "coalesce-model", payload, "")
+	if errMsg == nil || !errors.Is(errMsg.Error, context.DeadlineExceeded) {
+		t.Fatalf("waiter error = %+v, want deadline exceeded", errMsg)
+	}
+
+	close(executor.release)
+	<-leaderDone
+	if got := executor.calls.Load(); got != 1 {
+		t.Fatalf("upstream calls = %d, want 1", got)
+	}
+}
+
+func TestExecuteWithAuthManager_DoesNotCoalesceWithoutCache(t *testing.T) {
+	handler, _ := newCoalesceTestHandler(t)
+	handler.Cfg.Cache.Enabled = false
+	if key := handler.coalesceKey(context.Background(), "openai", "coalesce-model", []byte(`{}`), ""); key != "" {
+		t.Fatalf("coalesce key = %q, want empty while caching is disabled", key)
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DetachedContext returns a copy of c that can be used from another goroutine while c
+// is still in flight, e.g. for the items of a batch request. Keys are copied, audit
+// metadata starts empty, and anything written to the copy's response is discarded.
+func DetachedContext(c *gin.Context) *gin.Context {
+	cp := c.Copy()
+	cp.Writer = &detachedWriter{header: make(http.Header), status: http.StatusOK}
+	cp.Set("audit_metadata", make(map[string]string))
+	return cp
+}
+
+// detachedWriter is a gin.ResponseWriter that records status and headers but drops the body.
+type detachedWriter struct {
+	header  http.Header
+	status  int
+	size    int
+	written bool
+}
+
+func (w *detachedWriter) Header() http.Header { return w.header }
+
+func (w *detachedWriter) Write(data []byte) (int, error) {
+	w.written = true
+	w.size += len(data)
+	return len(data), nil
+}
+
+func (w *detachedWriter) WriteString(s string) (int, error) { return w.Write([]byte(s)) }
+
+func (w *detachedWriter) WriteHeader(code int) {
+	if !w.written {
+		w.status = code
+	}
+}
+
+func (w *detachedWriter) WriteHeaderNow() { w.written = true }
+
+func (w *detachedWriter) Status() int { return w.status }
+
+func (w *detachedWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.size
+}
+
+func (w *detachedWriter) Written() bool { return w.written }
+
+func (w *detachedWriter) Flush() {}
+
+func (w *detachedWriter) CloseNotify() <-chan bool { return make(chan bool) }
+
+func (w *detachedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("detached context does not support hijacking")
+}
+
+func (w *detachedWriter) Pusher() http.Pusher { return nil }
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	contextmgr "github.com/router-for-me/CLIProxyAPI/v6/internal/context"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/tidwall/gjson"
+	"golang.org/x/net/context"
+)
+
+// DryRunHeader requests validate-only handling when set to a true value.
+const DryRunHeader = "X-Dry-Run"
+
+// DryRunReport describes what a request would do without sending it upstream.
+type DryRunReport struct {
+	Object                string      `json:"object"`
+	Valid                 bool        `json:"valid"`
+	Model                 string      `json:"model"`
+	ResolvedModel         string      `json:"resolved_model,omitempty"`
+	Providers             []string    `json:"providers,omitempty"`
+	EstimatedPromptTokens int64       `json:"estimated_prompt_tokens"`
+	MaxOutputTokens       int64       `json:"max_output_tokens,omitempty"`
+	ContextWindow         int64       `json:"context_window,omitempty"`
+	EstimatedCost         *DryRunCost `json:"estimated_cost,omitempty"`
+	Errors                []string    `json:"errors,omitempty"`
+	Warnings              []string    `json:"warnings,omitempty"`
+}
+
+// DryRunCost is a cost estimate in USD. MaxCompletion assumes the full output budget is used.
+type DryRunCost struct {
+	Currency      string  `json:"currency"`
+	Prompt        float64 `json:"prompt"`
+	MaxCompletion float64 `json:"max_completion"`
+	Total         float64 `json:"total"`
+}
+
+// IsDryRun reports whether the client asked for validation only, via ?dry_run=true or the X-Dry-Run header.
+func IsDryRun(c *gin.Context) bool {
+	if c == nil || c.Request == nil {
+		return false
+	}
+	for _, raw := range []string{c.Query("dry_run"), c.GetHeader(DryRunHeader)} {
+		if enabled, err := strconv.ParseBool(strings.TrimSpace(raw)); err == nil && enabled {
+			return true
+		}
+	}
+	return false
+}
+
+// DryRun validates a chat-style request body (OpenAI chat completions or Claude messages),
+// resolves its model, and estimates token usage and cost. No provider is contacted.
+func (h *BaseAPIHandler) DryRun(ctx context.Context, rawJSON []byte) DryRunReport {
+	report := DryRunReport{Object: "dry_run"}
+	if !gjson.ValidBytes(rawJSON) {
+		report.Errors = append(report.Errors, "request body is not valid JSON")
+		return report
+	}
+	root := gjson.ParseBytes(rawJSON)
+
+	report.Model = strings.TrimSpace(root.Get("model").String())
+	if report.Model == "" {
+		report.Errors = append(report.Errors, "model is required")
+	} else {
+		providers, resolved, _, errMsg := h.resolveRequestDetails(ctx, report.Model, false)
+		if errMsg != nil {
+			report.Errors = append(report.Errors, errMsg.Error.Error())
+		} else {
+			report.Providers = providers
+			report.ResolvedModel = resolved
+		}
+	}
+
+	report.Errors = append(report.Errors, validateDryRunMessages(root.Get("messages"))...)
+	if violation := h.messageLimitViolation(report.Model, rawJSON); violation != "" {
+		report.Errors = append(report.Errors, violation)
+	}
+	report.Errors = append(report.Errors, validateDryRunTools(root.Get("tools"))...)
+
+	report.EstimatedPromptTokens = contextmgr.EstimateTokensFromLength(dryRunPromptChars(root))
+	for _, path := range []string{"max_completion_tokens", "max_tokens", "max_output_tokens"} {
+		if v := root.Get(path); v.Exists() {
+			if v.Int() <= 0 {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s must be a positive integer", path))
+			} else {
+				report.MaxOutputTokens = v.Int()
+			}
+			break
+		}
+	}
+
+	model := report.ResolvedModel
+	if model == "" {
+		model = report.Model
+	}
+	if model != "" {
+		caps := registry.LookupModelCapabilities(model)
+		if h.Cfg != nil {
+			if limit, ok := h.Cfg.Context.ModelLimits[model]; ok && limit > 0 {
+				caps.ContextWindow = limit
+			}
+		}
+		report.ContextWindow = caps.ContextWindow
+		if root.Get("tools").IsArray() && len(root.Get("tools").Array()) > 0 && !caps.SupportsTools {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("model %s does not support tools; tool definitions may be ignored", model))
+		}
+		if root.Get("stream").Bool() && !caps.SupportsStreaming {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("model %s does not support streaming", model))
+		}
+		report.Warnings = append(report.Warnings, dryRunContextWarnings(report)...)
+		report.EstimatedCost = h.dryRunCost(model, report)
+		if report.EstimatedCost == nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("no pricing configured for model %s; cost not estimated", model))
+		}
+	}
+
+	report.Valid = len(report.Errors) == 0
+	return report
+}
+
+// validateDryRunMessages checks that messages is a non-empty array of messages with roles.
+func validateDryRunMessages(messages gjson.Result) []string {
+	if !messages.Exists() {
+		return []string{"messages is required"}
+	}
+	if !messages.IsArray() || len(messages.Array()) == 0 {
+		return []string{"messages must be a non-empty array"}
+	}
+	var errs []string
+	for i, msg := range messages.Array() {
+		if strings.TrimSpace(msg.Get("role").String()) == "" {
+			errs = append(errs, fmt.Sprintf("messages[%d].role is required", i))
+		}
+	}
+	return errs
+}
+
+// validateDryRunTools checks that every tool declares a name in OpenAI or Claude form.
+func validateDryRunTools(tools gjson.Result) []string {
+	if !tools.Exists() {
+		return nil
+	}
+	if !tools.IsArray() {
+		return []string{"tools must be an array"}
+	}
+	var errs []string
+	for i, tool := range tools.Array() {
+		name := tool.Get("function.name").String()
+		if name == "" {
+			name = tool.Get("name").String()
+		}
+		if strings.TrimSpace(name) == "" {
+			errs = append(errs, fmt.Sprintf("tools[%d] is missing a function name", i))
+		}
+	}
+	return errs
+}
+
+// dryRunPromptChars counts the characters that contribute to the prompt: system text,
+// message text, prior tool calls, and tool definitions.
+func dryRunPromptChars(root gjson.Result) int64 {
+	var chars int64
+	addText := func(content gjson.Result) {
+		if content.IsArray() {
+			for _, part := range content.Array() {
+				chars += int64(len(part.Get("text").String()))
+			}
+			return
+		}
+		chars += int64(len(content.String()))
+	}
+	addText(root.Get("system"))
+	for _, msg := range root.Get("messages").Array() {
+		addText(msg.Get("content"))
+		if calls := msg.Get("tool_calls"); calls.Exists() {
+			chars += int64(len(calls.Raw))
+		}
+	}
+	if tools := root.Get("tools"); tools.Exists() {
+		chars += int64(len(tools.Raw))
+	}
+	return chars
+}
+
+// dryRunContextWarnings flags prompts that do not fit the model's context window.
+func dryRunContextWarnings(report DryRunReport) []string {
+	if report.ContextWindow <= 0 {
+		return nil
+	}
+	if report.EstimatedPromptTokens > report.ContextWindow {
+		return []string{fmt.Sprintf("estimated prompt tokens (%d) exceed the context window (%d)", report.EstimatedPromptTokens, report.ContextWindow)}
+	}
+	if report.EstimatedPromptTokens+report.MaxOutputTokens > report.ContextWindow {
+		return []string{fmt.Sprintf("estimated prompt tokens (%d) plus max output tokens (%d) exceed the context window (%d)", report.EstimatedPromptTokens, report.MaxOutputTokens, report.ContextWindow)}
+	}
+	return nil
+}
+
+// dryRunCost prices the estimate using the configured per-model prices, or returns nil when none apply.
+func (h *BaseAPIHandler) dryRunCost(model string, report DryRunReport) *DryRunCost {
+	if h.Cfg == nil {
+		return nil
+	}
+	price, ok := h.Cfg.Pricing.Models[model]
+	if !ok && report.Model != model {
+		price, ok = h.Cfg.Pricing.Models[report.Model]
+	}
+	if !ok {
+		return nil
+	}
+	cost := &DryRunCost{
+		Currency:      "USD",
+		Prompt:        float64(report.EstimatedPromptTokens) * price.InputPerMillion / 1e6,
+		MaxCompletion: float64(report.MaxOutputTokens) * price.OutputPerMillion / 1e6,
+	}
+	cost.Total = cost.Prompt + cost.MaxCompletion
+	return cost
+}
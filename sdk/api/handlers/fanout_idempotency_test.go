@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// gatedStreamExecutor counts upstream stream starts and holds each stream until released.
+type gatedStreamExecutor struct {
+	starts  atomic.Int32
+	release chan struct{}
+	chunks  []string
+}
+
+func (e *gatedStreamExecutor) Identifier() string { return "codex" }
+
+func (e *gatedStreamExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "Execute not implemented"}
+}
+
+func (e *gatedStreamExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	e.starts.Add(1)
+	ch := make(chan coreexecutor.StreamChunk)
+	go func() {
+		defer close(ch)
+		<-e.release
+		for _, chunk := range e.chunks {
+			ch <- coreexecutor.StreamChunk{Payload: []byte(chunk)}
+		}
+	}()
+	return ch, nil
+}
+
+func (e *gatedStreamExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *gatedStreamExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (e *gatedStreamExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+// newIdempotencyFanoutHandler registers exec for a test model and returns a handler plus a
+// starter that opens a stream for body as principal, carrying the test's Idempotency-Key.
+func newIdempotencyFanoutHandler(t *testing.T, exec *gatedStreamExecutor, model string) func(principal, body string) <-chan []byte {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(exec)
+	auth := &coreauth.Auth{ID: model + "-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: model}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager)
+
+	return func(principal, body string) <-chan []byte {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		c.Request.Header.Set("Idempotency-Key", "dedup-"+t.Name())
+		c.Set("apiKey", principal)
+		ctx := context.WithValue(context.Background(), "gin", c)
+		data, _ := handler.ExecuteStreamWithFanout(ctx, "openai", model, []byte(body), "")
+		return data
+	}
+}
+
+func collectFanout(data <-chan []byte, out *[]string, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for chunk := range data {
+		*out = append(*out, string(chunk))
+	}
+}
+
+func TestExecuteStreamWithFanout_SharesUpstreamByIdempotencyKey(t *testing.T) {
+	exec := &gatedStreamExecutor{release: make(chan struct{}), chunks: []string{`{"n":1}`, `{"n":2}`, `{"n":3}`}}
+	start := newIdempotencyFanoutHandler(t, exec, "fanout-model")
+
+	// Both attach while the upstream is still held open.
+	body := `{"model":"fanout-model","stream":true}`
+	var first, second []string
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go collectFanout(start("key-a", body), &first, &wg)
+	go collectFanout(start("key-a", body), &second, &wg)
+	close(exec.release)
+	wg.Wait()
+
+	if got := exec.starts.Load(); got != 1 {
+		t.Fatalf("upstream stream started %d times, want 1", got)
+	}
+	want := strings.Join(exec.chunks, "|")
+	if got := strings.Join(first, "|"); got != want {
+		t.Fatalf("first client received %q, want %q", got, want)
+	}
+	if got := strings.Join(second, "|"); got != want {
+		t.Fatalf("second client received %q, want %q", got, want)
+	}
+}
+
+func TestExecuteStreamWithFanout_IdempotencyKeyScopedToPrincipalAndPayload(t *testing.T) {
+	cases := []struct {
+		name              string
+		principalA, bodyA string
+		principalB, bodyB string
+	}{
+		{
+			name:       "different principals",
+			principalA: "key-a", bodyA: `{"model":"fanout-scope-model","stream":true}`,
+			principalB: "key-b", bodyB: `{"model":"fanout-scope-model","stream":true}`,
+		},
+		{
+			name:       "different payloads",
+			principalA: "key-a", bodyA: `{"model":"fanout-scope-model","stream":true,"messages":[{"role":"user","content":"a"}]}`,
+			principalB: "key-a", bodyB: `{"model":"fanout-scope-model","stream":true,"messages":[{"role":"user","content":"b"}]}`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			exec := &gatedStreamExecutor{release: make(chan struct{}), chunks: []string{`{"n":1}`}}
+			start := newIdempotencyFanoutHandler(t, exec, "fanout-scope-model")
+
+			var first, second []string
+			var wg sync.WaitGroup
+			wg.Add(2)
+			go collectFanout(start(tc.principalA, tc.bodyA), &first, &wg)
+			go collectFanout(start(tc.principalB, tc.bodyB), &second, &wg)
+			close(exec.release)
+			wg.Wait()
+
+			if got := exec.starts.Load(); got != 2 {
+				t.Fatalf("upstream stream started %d times, want 2 (one per request)", got)
+			}
+		})
+	}
+}
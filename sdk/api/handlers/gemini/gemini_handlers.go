@@ -286,6 +286,7 @@ func (h *GeminiAPIHandler) handleStreamGenerateContent(c *gin.Context, modelName
 
 			// Write first chunk
 			if alt == "" {
+				handlers.WriteSSEEventFields(c, chunk)
 				_, _ = c.Writer.Write([]byte("data: "))
 				_, _ = c.Writer.Write(chunk)
 				_, _ = c.Writer.Write([]byte("\n\n"))
@@ -355,10 +356,20 @@ func (h *GeminiAPIHandler) forwardGeminiStream(c *gin.Context, flusher http.Flus
 		keepAliveInterval = &disabled
 	}
 
+	// Synthetic finish events are only meaningful for SSE framing.
+	var writeSyntheticFinish func()
+	if alt == "" {
+		writeSyntheticFinish = func() {
+			_, _ = fmt.Fprint(c.Writer, "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"\"}]},\"finishReason\":\"STOP\",\"index\":0}]}\n\n")
+		}
+	}
+
 	h.ForwardStream(c, flusher, cancel, data, errs, handlers.StreamForwardOptions{
-		KeepAliveInterval: keepAliveInterval,
+		KeepAliveInterval:    keepAliveInterval,
+		WriteSyntheticFinish: writeSyntheticFinish,
 		WriteChunk: func(chunk []byte) {
 			if alt == "" {
+				handlers.WriteSSEEventFields(c, chunk)
 				_, _ = c.Writer.Write([]byte("data: "))
 				_, _ = c.Writer.Write(chunk)
 				_, _ = c.Writer.Write([]byte("\n\n"))
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"golang.org/x/net/context"
+)
+
+// GuardrailRequest is the inbound request a guardrail inspects before dispatch.
+type GuardrailRequest struct {
+	// HandlerType is the inbound API format (openai, claude, gemini, ...).
+	HandlerType string
+	// Model is the model the client requested.
+	Model string
+	// Payload is the raw request body. Guardrails must not modify it.
+	Payload []byte
+}
+
+// Text returns the prompt text of the request: system instructions and message content
+// in any of the supported inbound formats, joined by newlines.
+func (r GuardrailRequest) Text() string {
+	return promptText(r.Payload)
+}
+
+// GuardrailDecision is a guardrail's verdict on a request.
+type GuardrailDecision struct {
+	// Block rejects the request with a 400 before any provider is contacted.
+	Block bool
+	// Reason is shown to the client when the request is blocked.
+	Reason string
+	// Annotations are recorded in the request's audit metadata with a "guardrail_" prefix.
+	Annotations map[string]string
+}
+
+// GuardrailFunc screens a request before dispatch. Returning an error rejects the request,
+// so guardrails backed by an external service fail closed.
+type GuardrailFunc func(ctx context.Context, req GuardrailRequest) (GuardrailDecision, error)
+
+// AddGuardrail registers fn to run before every request this handler dispatches.
+func (h *BaseAPIHandler) AddGuardrail(fn GuardrailFunc) {
+	if fn != nil {
+		h.Guardrails = append(h.Guardrails, fn)
+	}
+}
+
+// NewRegexGuardrail builds a guardrail from regex rules. The first matching "block" rule
+// rejects the request; matching "flag" rules only annotate it.
+func NewRegexGuardrail(rules []config.GuardrailRule) (GuardrailFunc, error) {
+	type compiledRule struct {
+		config.GuardrailRule
+		re *regexp.Regexp
+	}
+	compiled := make([]compiledRule, 0, len(rules))
+	for i, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("guardrail rule %d (%s): %w", i, rule.Name, err)
+		}
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i)
+		}
+		compiled = append(compiled, compiledRule{GuardrailRule: rule, re: re})
+	}
+
+	return func(_ context.Context, req GuardrailRequest) (GuardrailDecision, error) {
+		text := req.Text()
+		var decision GuardrailDecision
+		var flagged []string
+		for _, rule := range compiled {
+			if !rule.re.MatchString(text) {
+				continue
+			}
+			if strings.EqualFold(rule.Action, "flag") {
+				flagged = append(flagged, rule.Name)
+				continue
+			}
+			decision.Block = true
+			decision.Reason = rule.Message
+			if decision.Reason == "" {
+				decision.Reason = fmt.Sprintf("prompt matched guardrail rule %s", rule.Name)
+			}
+			flagged = append(flagged, rule.Name)
+			break
+		}
+		if len(flagged) > 0 {
+			decision.Annotations = map[string]string{"rules": strings.Join(flagged, ",")}
+		}
+		return decision, nil
+	}, nil
+}
+
+// newConfigGuardrail compiles the configured regex guardrail. Invalid rules are logged and disable it.
+func newConfigGuardrail(cfg *config.SDKConfig) GuardrailFunc {
+	if cfg == nil || !cfg.Guardrails.Enabled || len(cfg.Guardrails.Rules) == 0 {
+		return nil
+	}
+	fn, err := NewRegexGuardrail(cfg.Guardrails.Rules)
+	if err != nil {
+		log.Errorf("failed to compile guardrail rules: %v", err)
+		return nil
+	}
+	return fn
+}
+
+// runGuardrails evaluates the configured and registered guardrails in order and
+// returns an error message when one of them rejects the request.
+func (h *BaseAPIHandler) runGuardrails(ctx context.Context, handlerType, modelName string, rawJSON []byte) *interfaces.ErrorMessage {
+	if h == nil || (h.configGuardrail == nil && len(h.Guardrails) == 0) {
+		return nil
+	}
+	guardrails := make([]GuardrailFunc, 0, len(h.Guardrails)+1)
+	if h.configGuardrail != nil {
+		guardrails = append(guardrails, h.configGuardrail)
+	}
+	guardrails = append(guardrails, h.Guardrails...)
+
+	var ginCtx *gin.Context
+	if ctx != nil {
+		ginCtx, _ = ctx.Value("gin").(*gin.Context)
+	}
+	req := GuardrailRequest{HandlerType: handlerType, Model: modelName, Payload: rawJSON}
+	for _, guardrail := range guardrails {
+		decision, err := guardrail(ctx, req)
+		if err != nil {
+			log.Warnf("guardrail failed for model %s: %v", modelName, err)
+			return guardrailError(http.StatusInternalServerError, "server_error", "guardrail_blocked", "request could not be screened by guardrail")
+		}
+		for key, value := range decision.Annotations {
+			SetAuditMetadata(ginCtx, "guardrail_"+key, value)
+		}
+		if decision.Block {
+			SetAuditMetadata(ginCtx, "guardrail_blocked", "true")
+			reason := decision.Reason
+			if reason == "" {
+				reason = "request blocked by guardrail"
+			}
+			return guardrailError(http.StatusBadRequest, "invalid_request_error", "guardrail_blocked", reason)
+		}
+	}
+	return nil
+}
+
+// guardrailError builds an OpenAI-style error body for a guardrail rejection.
+func guardrailError(status int, errType, code, message string) *interfaces.ErrorMessage {
+	payload, err := json.Marshal(map[string]any{"error": map[string]any{
+		"message": message,
+		"type":    errType,
+		"code":    code,
+	}})
+	if err != nil {
+		return &interfaces.ErrorMessage{StatusCode: status, Error: errors.New(message)}
+	}
+	return &interfaces.ErrorMessage{StatusCode: status, Error: errors.New(string(payload))}
+}
+
+// promptText collects the user-supplied text of an OpenAI, Claude, Gemini, or Responses request.
+func promptText(payload []byte) string {
+	if !gjson.ValidBytes(payload) {
+		return string(payload)
+	}
+	root := gjson.ParseBytes(payload)
+	var parts []string
+	add := func(value gjson.Result) {
+		switch {
+		case value.IsArray():
+			for _, item := range value.Array() {
+				if text := item.Get("text"); text.Exists() {
+					parts = append(parts, text.String())
+				} else if content := item.Get("content"); content.Exists() {
+					if content.IsArray() {
+						for _, part := range content.Array() {
+							parts = append(parts, part.Get("text").String())
+						}
+					} else {
+						parts = append(parts, content.String())
+					}
+				}
+			}
+		case value.Type == gjson.String:
+			parts = append(parts, value.String())
+		}
+	}
+	add(root.Get("system"))
+	add(root.Get("instructions"))
+	add(root.Get("prompt"))
+	add(root.Get("input"))
+	add(root.Get("messages"))
+	for _, prefix := range []string{"", "request."} {
+		add(root.Get(prefix + "systemInstruction.parts"))
+		for _, content := range root.Get(prefix + "contents").Array() {
+			add(content.Get("parts"))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
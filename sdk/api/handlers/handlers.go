@@ -6,24 +6,30 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
 	contextmgr "github.com/router-for-me/CLIProxyAPI/v6/internal/context"
 	providererrors "github.com/router-for-me/CLIProxyAPI/v6/internal/errors"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/routing"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/scheduler"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
 )
 
@@ -117,6 +123,15 @@ func StreamingKeepAliveInterval(cfg *config.SDKConfig) time.Duration {
 	return time.Duration(seconds) * time.Second
 }
 
+// StreamingMaxDuration returns the longest a single stream may run.
+// Returning 0 disables the cap (default when unset).
+func StreamingMaxDuration(cfg *config.SDKConfig) time.Duration {
+	if cfg == nil || cfg.Streaming.MaxDurationSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(cfg.Streaming.MaxDurationSeconds) * time.Second
+}
+
 // NonStreamingKeepAliveInterval returns the keep-alive interval for non-streaming responses.
 // Returning 0 disables keep-alives (default when unset).
 func NonStreamingKeepAliveInterval(cfg *config.SDKConfig) time.Duration {
@@ -142,6 +157,30 @@ func StreamingBootstrapRetries(cfg *config.SDKConfig) int {
 	return retries
 }
 
+// timeoutHeader lets clients bound upstream execution time in milliseconds.
+const timeoutHeader = "X-Timeout-Ms"
+
+// requestDeadline returns the earliest of the client request context deadline and
+// the deadline implied by the X-Timeout-Ms header.
+func requestDeadline(c *gin.Context, requestCtx context.Context) (time.Time, bool) {
+	var deadline time.Time
+	var ok bool
+	if requestCtx != nil {
+		deadline, ok = requestCtx.Deadline()
+	}
+	if c != nil && c.Request != nil {
+		if raw := strings.TrimSpace(c.GetHeader(timeoutHeader)); raw != "" {
+			if ms, err := strconv.ParseInt(raw, 10, 64); err == nil && ms > 0 {
+				headerDeadline := time.Now().Add(time.Duration(ms) * time.Millisecond)
+				if !ok || headerDeadline.Before(deadline) {
+					deadline, ok = headerDeadline, true
+				}
+			}
+		}
+	}
+	return deadline, ok
+}
+
 func requestExecutionMetadata(ctx context.Context) map[string]any {
 	// Idempotency-Key is an optional client-supplied header used to correlate retries.
 	// It is forwarded as execution metadata; when absent we generate a UUID.
@@ -157,6 +196,42 @@ func requestExecutionMetadata(ctx context.Context) map[string]any {
 	return map[string]any{idempotencyKeyMetadataKey: key}
 }
 
+// requestSessionID returns the client session identifier used for sticky experiment assignment.
+func requestSessionID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil && ginCtx.Request != nil {
+		return strings.TrimSpace(ginCtx.GetHeader("X-Session-ID"))
+	}
+	return ""
+}
+
+// recordModelVariant reports the experiment variant that served a request to metrics and audit.
+func recordModelVariant(ctx context.Context, model, variant string) {
+	observability.GetMetricsRecorder().RecordModelVariant(model, variant)
+	if ctx == nil {
+		return
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		SetAuditMetadata(ginCtx, "experiment_model", model)
+		SetAuditMetadata(ginCtx, "experiment_variant", variant)
+	}
+}
+
+// recordDefaultProvider notes that model was routed to the default provider as a last resort.
+func recordDefaultProvider(ctx context.Context, model, provider string) {
+	log.Debugf("model %s matched no routing rule or registered provider; using default provider %s", model, provider)
+	observability.GetMetricsRecorder().RecordDefaultProviderRoute(provider)
+	if ctx == nil {
+		return
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Header("X-Default-Provider", provider)
+		SetAuditMetadata(ginCtx, "default_provider", provider)
+	}
+}
+
 func mergeMetadata(base, overlay map[string]any) map[string]any {
 	if len(base) == 0 && len(overlay) == 0 {
 		return nil
@@ -186,6 +261,21 @@ type BaseAPIHandler struct {
 
 	// ErrorHandler handles provider error classification and retry logic.
 	ErrorHandler *providererrors.ErrorHandler
+
+	// ModelRouter resolves model names to providers using configured routing rules.
+	ModelRouter *routing.Resolver
+
+	// Guardrails screen inbound requests before dispatch, after the configured regex guardrail.
+	Guardrails []GuardrailFunc
+
+	// OutputGuardrails screen generated responses, after the configured regex output guardrail.
+	OutputGuardrails []OutputGuardrailFunc
+
+	// configGuardrail is compiled from the guardrails configuration section.
+	configGuardrail GuardrailFunc
+
+	// configOutputGuardrail is compiled from the guardrails.output configuration section.
+	configOutputGuardrail OutputGuardrailFunc
 }
 
 // NewBaseAPIHandlers creates a new API handlers instance.
@@ -212,11 +302,28 @@ func NewBaseAPIHandlers(cfg *config.SDKConfig, authManager *coreauth.Manager) *B
 	errHandler := providererrors.NewErrorHandler(providererrors.DefaultRetryConfig())
 
 	return &BaseAPIHandler{
-		Cfg:            cfg,
-		AuthManager:    authManager,
-		ContextManager: ctxMgr,
-		ErrorHandler:   errHandler,
+		Cfg:                   cfg,
+		AuthManager:           authManager,
+		ContextManager:        ctxMgr,
+		ErrorHandler:          errHandler,
+		ModelRouter:           newModelRouter(cfg),
+		configGuardrail:       newConfigGuardrail(cfg),
+		configOutputGuardrail: newConfigOutputGuardrail(cfg),
+	}
+}
+
+// newModelRouter compiles the model routing rules from configuration.
+// Invalid rules are logged and routing falls back to registry lookups.
+func newModelRouter(cfg *config.SDKConfig) *routing.Resolver {
+	if cfg == nil {
+		return nil
+	}
+	resolver, err := routing.NewResolver(cfg.ModelRouting)
+	if err != nil {
+		log.Errorf("failed to compile model routing rules: %v", err)
+		return nil
 	}
+	return resolver
 }
 
 // UpdateClients updates the handlers' client list and configuration.
@@ -225,7 +332,12 @@ func NewBaseAPIHandlers(cfg *config.SDKConfig, authManager *coreauth.Manager) *B
 // Parameters:
 //   - clients: The new slice of AI service clients
 //   - cfg: The new application configuration
-func (h *BaseAPIHandler) UpdateClients(cfg *config.SDKConfig) { h.Cfg = cfg }
+func (h *BaseAPIHandler) UpdateClients(cfg *config.SDKConfig) {
+	h.Cfg = cfg
+	h.ModelRouter = newModelRouter(cfg)
+	h.configGuardrail = newConfigGuardrail(cfg)
+	h.configOutputGuardrail = newConfigOutputGuardrail(cfg)
+}
 
 // GetAlt extracts the 'alt' parameter from the request query string.
 // It checks both 'alt' and '$alt' parameters and returns the appropriate value.
@@ -279,12 +391,22 @@ func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *
 		}
 	}
 	newCtx, cancel := context.WithCancel(parentCtx)
+	if deadline, ok := requestDeadline(c, requestCtx); ok {
+		deadlineCtx, cancelDeadline := context.WithDeadline(newCtx, deadline)
+		cancelParent := cancel
+		newCtx = deadlineCtx
+		cancel = func() {
+			cancelDeadline()
+			cancelParent()
+		}
+	}
 	if requestCtx != nil && requestCtx != parentCtx {
+		upstreamDone := newCtx.Done()
 		go func() {
 			select {
 			case <-requestCtx.Done():
 				cancel()
-			case <-newCtx.Done():
+			case <-upstreamDone:
 			}
 		}()
 	}
@@ -331,51 +453,13 @@ func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *
 	}
 }
 
-// StartNonStreamingKeepAlive emits blank lines every 5 seconds while waiting for a non-streaming response.
+// StartNonStreamingKeepAlive emits blank lines at the configured interval while waiting for a non-streaming response.
 // It returns a stop function that must be called before writing the final response.
 func (h *BaseAPIHandler) StartNonStreamingKeepAlive(c *gin.Context, ctx context.Context) func() {
 	if h == nil || c == nil {
 		return func() {}
 	}
-	interval := NonStreamingKeepAliveInterval(h.Cfg)
-	if interval <= 0 {
-		return func() {}
-	}
-	flusher, ok := c.Writer.(http.Flusher)
-	if !ok {
-		return func() {}
-	}
-	if ctx == nil {
-		ctx = context.Background()
-	}
-
-	stopChan := make(chan struct{})
-	var stopOnce sync.Once
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-stopChan:
-				return
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				_, _ = c.Writer.Write([]byte("\n"))
-				flusher.Flush()
-			}
-		}
-	}()
-
-	return func() {
-		stopOnce.Do(func() {
-			close(stopChan)
-		})
-		wg.Wait()
-	}
+	return h.NonStreamingKeepAliveWriter(c).Start(ctx)
 }
 
 // appendAPIResponse preserves any previously captured API response and appends new data.
@@ -401,20 +485,87 @@ func appendAPIResponse(c *gin.Context, data []byte) {
 }
 
 // ExecuteWithAuthManager executes a non-streaming request via the core auth manager.
-// This path is the only supported execution route.
+// This path is the only supported execution route. While response caching is enabled,
+// identical requests are answered from responses stored by cache priming, reported through
+// the X-Cache header as HIT or MISS.
 func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
-	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(modelName)
+	if errMsg := h.validateRequest(ctx, handlerType, modelName, rawJSON); errMsg != nil {
+		return nil, errMsg
+	}
+	cacheControl := cacheControlFromContext(ctx)
+	negativeKey := h.negativeCacheKey(handlerType, modelName, rawJSON)
+	if !cacheControl.SkipLookup {
+		if errMsg := replayNegative(negativeKey); errMsg != nil {
+			return nil, errMsg
+		}
+	}
+	responseKey := h.ResponseCacheKey(handlerType, modelName, rawJSON, alt)
+	if responseKey != "" && !cacheControl.SkipLookup {
+		if resp, ok := lookupResponse(modelName, responseKey); ok {
+			setCacheHeader(ctx, "HIT")
+			return h.filterResponse(ctx, handlerType, modelName, resp)
+		}
+	}
+	// Concurrent identical cache misses share one upstream call.
+	coalesceKey := h.coalesceKey(ctx, handlerType, modelName, rawJSON, alt)
+	resp, errMsg := executeCoalesced(ctx, coalesceKey, func() ([]byte, *interfaces.ErrorMessage) {
+		return h.executeWithFallback(ctx, modelName, func(model string) ([]byte, *interfaces.ErrorMessage) {
+			return h.executeWithAuthManager(ctx, handlerType, model, rawJSON, alt)
+		})
+	})
+	if responseKey != "" {
+		setCacheHeader(ctx, "MISS")
+	}
+	if errMsg == nil && !cacheControl.SkipStore && isCachePriming(ctx) {
+		storeResponse(modelName, responseKey, resp)
+	}
 	if errMsg != nil {
+		if !cacheControl.SkipStore {
+			storeNegative(negativeKey, handlerType, errMsg)
+		}
 		return nil, errMsg
 	}
+	return h.filterResponse(ctx, handlerType, modelName, resp)
+}
+
+func (h *BaseAPIHandler) executeWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
+	return h.executeActionWithAuthManager(ctx, handlerType, modelName, rawJSON, alt, "")
+}
+
+// executeActionWithAuthManager runs a non-streaming request. A non-empty action is passed to
+// executors as the "action" request metadata and marks a non-generation call, so the
+// max-tokens cap and reasoning stripping are skipped.
+func (h *BaseAPIHandler) executeActionWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt, action string) ([]byte, *interfaces.ErrorMessage) {
+	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(ctx, modelName)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	if action == coreexecutor.EmbeddingsAction {
+		if providers = h.embeddingsProviders(providers); len(providers) == 0 {
+			return nil, &interfaces.ErrorMessage{
+				StatusCode: http.StatusNotImplemented,
+				Error:      fmt.Errorf("model %s is not served by a provider that supports embeddings", modelName),
+			}
+		}
+	}
 	reqMeta := requestExecutionMetadata(ctx)
+	payload := cloneBytes(rawJSON)
+	if action == "" {
+		payload = h.applyMaxTokensCap(ctx, handlerType, payload, normalizedModel, modelName)
+	}
 	req := coreexecutor.Request{
 		Model:   normalizedModel,
-		Payload: cloneBytes(rawJSON),
+		Payload: payload,
 	}
 	if cloned := cloneMetadata(metadata); cloned != nil {
 		req.Metadata = cloned
 	}
+	if action != "" {
+		if req.Metadata == nil {
+			req.Metadata = make(map[string]any, 1)
+		}
+		req.Metadata["action"] = action
+	}
 	opts := coreexecutor.Options{
 		Stream:          false,
 		Alt:             alt,
@@ -422,7 +573,13 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = mergeMetadata(cloneMetadata(metadata), reqMeta)
+	release, errMsg := admitUpstreamCall(ctx)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	ctx = withServedRoute(ctx, normalizedModel)
 	resp, err := h.AuthManager.Execute(ctx, providers, req, opts)
+	release()
 	if err != nil {
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
@@ -438,13 +595,43 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 		}
 		return nil, &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
 	}
+	if action == "" && handlerType == constant.OpenAI && h.hideReasoning() {
+		return StripReasoningFields(cloneBytes(resp.Payload)), nil
+	}
 	return cloneBytes(resp.Payload), nil
 }
 
+// embeddingsProviders returns the providers whose executors serve embeddings, so a model
+// also offered by a chat-only provider is never sent there as a chat request.
+func (h *BaseAPIHandler) embeddingsProviders(providers []string) []string {
+	capable := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		if h.AuthManager.SupportsEmbeddings(provider) {
+			capable = append(capable, provider)
+		}
+	}
+	return capable
+}
+
+// ExecuteEmbeddingsWithAuthManager executes an OpenAI embeddings request via the core auth
+// manager. The payload is forwarded untranslated, so it is only sent to providers whose
+// executors implement coreauth.EmbeddingsExecutor; other models are rejected with 501.
+func (h *BaseAPIHandler) ExecuteEmbeddingsWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	return h.executeWithFallback(ctx, modelName, func(model string) ([]byte, *interfaces.ErrorMessage) {
+		return h.executeActionWithAuthManager(ctx, handlerType, model, rawJSON, "", coreexecutor.EmbeddingsAction)
+	})
+}
+
 // ExecuteCountWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
-	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(modelName)
+	return h.executeWithFallback(ctx, modelName, func(model string) ([]byte, *interfaces.ErrorMessage) {
+		return h.executeCountWithAuthManager(ctx, handlerType, model, rawJSON, alt)
+	})
+}
+
+func (h *BaseAPIHandler) executeCountWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
+	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(ctx, modelName)
 	if errMsg != nil {
 		return nil, errMsg
 	}
@@ -463,7 +650,12 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = mergeMetadata(cloneMetadata(metadata), reqMeta)
+	release, errMsg := admitUpstreamCall(ctx)
+	if errMsg != nil {
+		return nil, errMsg
+	}
 	resp, err := h.AuthManager.ExecuteCount(ctx, providers, req, opts)
+	release()
 	if err != nil {
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
@@ -484,8 +676,64 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 
 // ExecuteStreamWithAuthManager executes a streaming request via the core auth manager.
 // This path is the only supported execution route.
+// Fallback chains apply only while no payload has been forwarded to the client.
 func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
-	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(modelName)
+	if errMsg := h.validateRequest(ctx, handlerType, modelName, rawJSON); errMsg != nil {
+		errChan := make(chan *interfaces.ErrorMessage, 1)
+		errChan <- errMsg
+		close(errChan)
+		return nil, errChan
+	}
+	dataChan, errChan := h.executeStreamWithFallback(ctx, handlerType, modelName, rawJSON, alt)
+	return h.filterStream(ctx, handlerType, modelName, dataChan, errChan)
+}
+
+func (h *BaseAPIHandler) executeStreamWithFallback(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	chain := h.ModelRouter.FallbackChain(modelName)
+	if len(chain) == 0 {
+		return h.executeStreamWithAuthManager(ctx, handlerType, modelName, rawJSON, alt)
+	}
+
+	dataChan := make(chan []byte)
+	errChan := make(chan *interfaces.ErrorMessage, 1)
+	go func() {
+		defer close(dataChan)
+		defer close(errChan)
+		var done <-chan struct{}
+		if ctx != nil {
+			done = ctx.Done()
+		}
+		models := append([]string{modelName}, chain...)
+		for i, model := range models {
+			chunks, errs := h.executeStreamWithAuthManager(ctx, handlerType, model, rawJSON, alt)
+			sentPayload := false
+			for chunk := range chunks {
+				if !sentPayload && i > 0 {
+					recordModelFallback(ctx, modelName, model)
+				}
+				sentPayload = true
+				select {
+				case dataChan <- chunk:
+				case <-done:
+					return
+				}
+			}
+			errMsg := <-errs
+			if errMsg == nil {
+				return
+			}
+			if sentPayload || i == len(models)-1 || !h.fallbackEligible(ctx, errMsg) || !scheduler.GetRetryBudget().Allow() {
+				errChan <- errMsg
+				return
+			}
+			log.Warnf("model %s failed with status %d, falling back to %s", model, errMsg.StatusCode, models[i+1])
+		}
+	}()
+	return dataChan, errChan
+}
+
+func (h *BaseAPIHandler) executeStreamWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(ctx, modelName)
 	if errMsg != nil {
 		errChan := make(chan *interfaces.ErrorMessage, 1)
 		errChan <- errMsg
@@ -495,7 +743,7 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 	reqMeta := requestExecutionMetadata(ctx)
 	req := coreexecutor.Request{
 		Model:   normalizedModel,
-		Payload: cloneBytes(rawJSON),
+		Payload: h.applyMaxTokensCap(ctx, handlerType, cloneBytes(rawJSON), normalizedModel, modelName),
 	}
 	if cloned := cloneMetadata(metadata); cloned != nil {
 		req.Metadata = cloned
@@ -507,8 +755,17 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = mergeMetadata(cloneMetadata(metadata), reqMeta)
+	release, errMsg := admitUpstreamCall(ctx)
+	if errMsg != nil {
+		errChan := make(chan *interfaces.ErrorMessage, 1)
+		errChan <- errMsg
+		close(errChan)
+		return nil, errChan
+	}
+	ctx = withServedRoute(ctx, normalizedModel)
 	chunks, err := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
 	if err != nil {
+		release()
 		errChan := make(chan *interfaces.ErrorMessage, 1)
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
@@ -531,6 +788,11 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 	go func() {
 		defer close(dataChan)
 		defer close(errChan)
+		defer release()
+		accountant := &ReasoningAccountant{}
+		defer recordReasoningUsage(normalizedModel, accountant)
+		annotateUsage := handlerType == constant.OpenAI
+		stripReasoning := handlerType == constant.OpenAI && h.hideReasoning()
 		sentPayload := false
 		bootstrapRetries := 0
 		maxBootstrapRetries := StreamingBootstrapRetries(h.Cfg)
@@ -599,7 +861,15 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 				}
 				if len(chunk.Payload) > 0 {
 					sentPayload = true
-					dataChan <- cloneBytes(chunk.Payload)
+					payload := cloneBytes(chunk.Payload)
+					accountant.Observe(payload)
+					if annotateUsage {
+						payload = accountant.AnnotateUsage(payload)
+					}
+					if stripReasoning {
+						payload = StripReasoningFields(payload)
+					}
+					dataChan <- payload
 				}
 			}
 		}
@@ -607,6 +877,130 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 	return dataChan, errChan
 }
 
+// admitUpstreamCall takes a slot from the global admission controller for one upstream
+// call. The returned release must be called once the call, or its stream, has finished.
+func admitUpstreamCall(ctx context.Context) (release func(), errMsg *interfaces.ErrorMessage) {
+	release, err := scheduler.GetAdmissionController().Acquire(ctx)
+	if err != nil {
+		addon := http.Header{}
+		addon.Set("Retry-After", "1")
+		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusServiceUnavailable, Error: err, Addon: addon}
+	}
+	return release, nil
+}
+
+// recordReasoningUsage feeds the stream's reasoning/completion split into the token counters.
+func recordReasoningUsage(model string, accountant *ReasoningAccountant) {
+	usage := accountant.Usage()
+	if usage.ReasoningTokens == 0 && usage.CompletionTokens == 0 {
+		return
+	}
+	observability.GetMetricsRecorder().RecordReasoningTokens(model, usage.ReasoningTokens, usage.CompletionTokens)
+}
+
+// executeWithFallback runs attempt for the requested model and, when it fails with a
+// failover-eligible error, for each model in its configured fallback chain. Each fallback
+// spends a retry from the global retry budget; once it is spent, the last error is returned.
+func (h *BaseAPIHandler) executeWithFallback(ctx context.Context, modelName string, attempt func(model string) ([]byte, *interfaces.ErrorMessage)) ([]byte, *interfaces.ErrorMessage) {
+	resp, errMsg := attempt(modelName)
+	if errMsg == nil {
+		return resp, nil
+	}
+	failed := modelName
+	for _, fallback := range h.ModelRouter.FallbackChain(modelName) {
+		if !h.fallbackEligible(ctx, errMsg) || !scheduler.GetRetryBudget().Allow() {
+			break
+		}
+		log.Warnf("model %s failed with status %d, falling back to %s", failed, errMsg.StatusCode, fallback)
+		var next *interfaces.ErrorMessage
+		resp, next = attempt(fallback)
+		if next == nil {
+			recordModelFallback(ctx, modelName, fallback)
+			return resp, nil
+		}
+		errMsg, failed = next, fallback
+	}
+	return nil, errMsg
+}
+
+// fallbackEligible reports whether a failure should move on to the next model in a fallback
+// chain: errors the provider error classification marks as retryable or as calling for a
+// failover, and transport failures that never got an upstream status. Nothing is eligible
+// once ctx is done or the failure is a cancellation, since the client has gone or its
+// deadline has passed and another model would not change that.
+func (h *BaseAPIHandler) fallbackEligible(ctx context.Context, msg *interfaces.ErrorMessage) bool {
+	if msg == nil || (ctx != nil && ctx.Err() != nil) {
+		return false
+	}
+	if errors.Is(msg.Error, context.Canceled) || errors.Is(msg.Error, context.DeadlineExceeded) {
+		return false
+	}
+	if msg.StatusCode == 0 {
+		return true
+	}
+	handler := h.ErrorHandler
+	if handler == nil {
+		handler = providererrors.NewErrorHandler(providererrors.DefaultRetryConfig())
+	}
+	var body []byte
+	if msg.Error != nil {
+		body = []byte(msg.Error.Error())
+	}
+	classified := handler.ParseError("", msg.StatusCode, body)
+	return classified.Retryable || classified.ShouldFailover || providererrors.IsRetryable(msg.StatusCode, handler.Config())
+}
+
+// withServedRoute returns ctx under which the provider that answers a request for model is
+// published, with model, for the audit log and the X-Proxy-Provider and X-Proxy-Model
+// response headers. Every upstream call is also recorded for the access log, along with the
+// retry rounds used and the latency of the call that served the request.
+func withServedRoute(ctx context.Context, model string) context.Context {
+	if ctx == nil {
+		return ctx
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return ctx
+	}
+	ctx = coreauth.WithAttemptObserver(ctx, func(attempt coreauth.Attempt) {
+		outcome := "ok"
+		if attempt.Err != nil {
+			outcome = "error"
+			if attempt.Status > 0 {
+				outcome = strconv.Itoa(attempt.Status)
+			}
+		}
+		trace := ginCtx.GetStringSlice("audit_upstream_attempts")
+		trace = append(trace, fmt.Sprintf("%s/%s %s %dms", attempt.Provider, attempt.AuthID, outcome, attempt.Duration.Milliseconds()))
+		ginCtx.Set("audit_upstream_attempts", trace)
+		if attempt.Retry > ginCtx.GetInt("audit_retries") {
+			ginCtx.Set("audit_retries", attempt.Retry)
+		}
+		if attempt.Err == nil {
+			ginCtx.Set("audit_upstream_latency_ms", attempt.Duration.Milliseconds())
+		}
+	})
+	return coreauth.WithServedProvider(ctx, func(provider string) {
+		ginCtx.Set("audit_provider", provider)
+		ginCtx.Set("audit_model", model)
+	})
+}
+
+// recordModelFallback records a fallback substitution in the response headers and audit metadata,
+// preserving the model the client originally requested.
+func recordModelFallback(ctx context.Context, requested, served string) {
+	log.Infof("served model %s via fallback for %s", served, requested)
+	if ctx == nil {
+		return
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Header("X-Original-Model", requested)
+		ginCtx.Header("X-Fallback-Model", served)
+		SetAuditMetadata(ginCtx, "original_model", requested)
+		SetAuditMetadata(ginCtx, "fallback_model", served)
+	}
+}
+
 func statusFromError(err error) int {
 	if err == nil {
 		return 0
@@ -619,12 +1013,42 @@ func statusFromError(err error) int {
 	return 0
 }
 
-func (h *BaseAPIHandler) getRequestDetails(modelName string) (providers []string, normalizedModel string, metadata map[string]any, err *interfaces.ErrorMessage) {
+func (h *BaseAPIHandler) getRequestDetails(ctx context.Context, modelName string) (providers []string, normalizedModel string, metadata map[string]any, err *interfaces.ErrorMessage) {
+	return h.resolveRequestDetails(ctx, modelName, true)
+}
+
+// resolveRequestDetails resolves modelName to the providers and model that serve it. With
+// record unset it has no side effects: A/B variant and default-provider routing are neither
+// counted in metrics nor noted on the response and audit log, as a dry run requires.
+func (h *BaseAPIHandler) resolveRequestDetails(ctx context.Context, modelName string, record bool) (providers []string, normalizedModel string, metadata map[string]any, err *interfaces.ErrorMessage) {
 	// Resolve "auto" model to an actual available model first
 	resolvedModelName := util.ResolveAutoModel(modelName)
 
+	// Operator-blocked models are rejected before any provider is contacted.
+	for _, candidate := range []string{modelName, resolvedModelName} {
+		if block, ok := h.ModelRouter.Blocked(candidate); ok {
+			return nil, "", nil, blockedModelError(candidate, block)
+		}
+	}
+
+	// A/B experiments swap the logical model for a weighted variant before routing.
+	if variant, ok := h.ModelRouter.AssignVariant(resolvedModelName, requestSessionID(ctx)); ok {
+		if record {
+			recordModelVariant(ctx, resolvedModelName, variant)
+		}
+		resolvedModelName = variant
+	}
+
 	// Normalize the model name to handle dynamic thinking suffixes before determining the provider.
 	normalizedModel, metadata = normalizeModelMetadata(resolvedModelName)
+	if block, ok := h.ModelRouter.Blocked(normalizedModel); ok {
+		return nil, "", nil, blockedModelError(normalizedModel, block)
+	}
+
+	// Explicit routing rules take precedence over registry lookups.
+	if resolution, ok := h.ModelRouter.Resolve(normalizedModel); ok {
+		return []string{resolution.Provider}, resolution.Model(normalizedModel), metadata, nil
+	}
 
 	// Use the normalizedModel to get the provider name.
 	providers = util.GetProviderName(normalizedModel)
@@ -643,7 +1067,20 @@ func (h *BaseAPIHandler) getRequestDetails(modelName string) (providers []string
 	}
 
 	if len(providers) == 0 {
-		return nil, "", nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("unknown provider for model %s", modelName)}
+		if resolution, ok := h.ModelRouter.Default(); ok {
+			if record {
+				recordDefaultProvider(ctx, normalizedModel, resolution.Provider)
+			}
+			if metadata == nil {
+				metadata = make(map[string]any, 1)
+			}
+			metadata[coreexecutor.DefaultProviderMetadataKey] = true
+			return []string{resolution.Provider}, normalizedModel, metadata, nil
+		}
+		return nil, "", nil, &interfaces.ErrorMessage{
+			StatusCode: http.StatusBadRequest,
+			Error:      fmt.Errorf("unknown provider for model %s: no routing rule or registered provider serves it and no model-routing.default-provider is configured", modelName),
+		}
 	}
 
 	// If it's a dynamic model, the normalizedModel was already set to extractedModelName.
@@ -653,6 +1090,31 @@ func (h *BaseAPIHandler) getRequestDetails(modelName string) (providers []string
 	return providers, normalizedModel, metadata, nil
 }
 
+// blockedModelError builds a 403 whose body carries the operator's explanation and suggested replacement.
+func blockedModelError(model string, block routing.Block) *interfaces.ErrorMessage {
+	message := fmt.Sprintf("model %s is not available on this server", model)
+	if block.Message != "" {
+		message += ": " + block.Message
+	}
+	if block.Replacement != "" {
+		message += fmt.Sprintf(" (use %s instead)", block.Replacement)
+	}
+	body := map[string]any{
+		"message": message,
+		"type":    "invalid_request_error",
+		"code":    "model_blocked",
+		"param":   "model",
+	}
+	if block.Replacement != "" {
+		body["replacement"] = block.Replacement
+	}
+	payload, err := json.Marshal(map[string]any{"error": body})
+	if err != nil {
+		return &interfaces.ErrorMessage{StatusCode: http.StatusForbidden, Error: errors.New(message)}
+	}
+	return &interfaces.ErrorMessage{StatusCode: http.StatusForbidden, Error: errors.New(string(payload))}
+}
+
 func cloneBytes(src []byte) []byte {
 	if len(src) == 0 {
 		return nil
@@ -749,63 +1211,91 @@ type APIHandlerCancelFunc func(params ...interface{})
 
 // ExecuteStreamWithFanout executes a streaming request with optional fanout support.
 // If fanout is enabled and a matching stream exists, it subscribes to the existing stream
-// instead of creating a new upstream connection.
+// instead of creating a new upstream connection. Requests carrying an Idempotency-Key
+// header match on that key; other requests match on their model and payload. A request
+// carrying Last-Event-ID resumes the matching stream after that event; see
+// WriteSSEEventFields for emitting the ids. While streaming caching is enabled, completed
+// streams are recorded and identical requests are replayed from the cache, reported
+// through the X-Cache header as HIT or MISS.
 func (h *BaseAPIHandler) ExecuteStreamWithFanout(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	if errMsg := h.validateRequest(ctx, handlerType, modelName, rawJSON); errMsg != nil {
+		errChan := make(chan *interfaces.ErrorMessage, 1)
+		errChan <- errMsg
+		close(errChan)
+		return nil, errChan
+	}
+
+	cacheControl := cacheControlFromContext(ctx)
+	streamKey := h.StreamCacheKey(handlerType, modelName, rawJSON, alt)
+	if streamKey != "" && !cacheControl.SkipLookup && lastEventID(ctx) == "" {
+		if dataChan, errChan, ok := replayCachedStream(ctx, streamKey, newStreamEventIDs(ctx, h.Cfg)); ok {
+			setCacheHeader(ctx, "HIT")
+			return h.filterStream(ctx, handlerType, modelName, dataChan, errChan)
+		}
+	}
+	if streamKey != "" {
+		setCacheHeader(ctx, "MISS")
+	}
+	if cacheControl.SkipStore {
+		streamKey = ""
+	}
+
 	// Check if fanout is enabled and applicable
 	fanout := executor.GetStreamFanout()
 	if fanout.IsEnabled() {
-		result := executor.CheckStreamFanout(modelName, rawJSON)
+		key := fanoutStreamKey(ctx, handlerType, modelName, rawJSON)
+		ids := newStreamEventIDs(ctx, h.Cfg)
+		if lastID := lastEventID(ctx); lastID != "" {
+			// A reconnecting client picks up where it left off instead of starting over.
+			if result, ok := executor.ResumeStreamFanoutKey(key, lastID); ok {
+				return h.subscribeFanout(ctx, handlerType, modelName, result, ids)
+			}
+		}
+		result := executor.CheckStreamFanoutKey(key)
 		if !result.IsNew && result.Subscriber != nil {
 			// Subscribe to existing stream - reuse the upstream connection
-			dataChan := make(chan []byte)
-			errChan := make(chan *interfaces.ErrorMessage, 1)
-
-			go func() {
-				defer close(dataChan)
-				defer close(errChan)
-
-				for event := range result.Subscriber {
-					select {
-					case dataChan <- event.Data:
-					case <-ctx.Done():
-						return
-					}
-				}
-			}()
-
-			return dataChan, errChan
+			return h.subscribeFanout(ctx, handlerType, modelName, result, ids)
 		}
 
 		// Create new stream and publish to fanout
 		if result.Stream != nil {
-			dataChan, errChan := h.ExecuteStreamWithAuthManager(ctx, handlerType, modelName, rawJSON, alt)
-
-			// Wrap the data channel to publish to fanout
+			// The upstream outlives this request so that subscribers and reconnecting
+			// clients still receive the whole response; see publishFanout.
+			upstreamCtx := newFanoutUpstreamContext(ctx)
+			dataChan, errChan := h.executeStreamWithFallback(upstreamCtx, handlerType, modelName, rawJSON, alt)
 			fanoutDataChan := make(chan []byte)
-			go func() {
-				defer close(fanoutDataChan)
-				defer result.Stream.Complete()
-
-				for chunk := range dataChan {
-					// Publish to fanout subscribers
-					if len(chunk) > 0 {
-						result.Stream.PublishBytes(chunk)
-					}
-					// Forward to caller
-					select {
-					case fanoutDataChan <- chunk:
-					case <-ctx.Done():
-						return
-					}
-				}
-			}()
+			go publishFanout(ctx, upstreamCtx, result.Stream, fanout.ResumeWindow(), ids, dataChan, fanoutDataChan)
 
-			return fanoutDataChan, errChan
+			// Recorded after publishing, so the cached events keep their fan-out ids.
+			recordedChan, recordedErrChan := recordStream(ctx, modelName, streamKey, ids, fanoutDataChan, errChan)
+			return h.filterStream(ctx, handlerType, modelName, recordedChan, recordedErrChan)
 		}
 	}
 
 	// Fallback to normal execution without fanout
-	return h.ExecuteStreamWithAuthManager(ctx, handlerType, modelName, rawJSON, alt)
+	ids := newStreamEventIDs(ctx, h.Cfg)
+	dataChan, errChan := h.executeStreamWithFallback(ctx, handlerType, modelName, rawJSON, alt)
+	dataChan, errChan = recordStream(ctx, modelName, streamKey, ids, dataChan, errChan)
+	return h.filterStream(ctx, handlerType, modelName, dataChan, errChan)
+}
+
+// fanoutStreamKey returns the key under which a streaming request shares its upstream.
+// A client-supplied Idempotency-Key takes precedence over the payload hash; it is scoped
+// to the authenticated API key and the payload, so it only ever joins the caller's own
+// identical request.
+func fanoutStreamKey(ctx context.Context, handlerType, modelName string, rawJSON []byte) string {
+	if ctx != nil {
+		if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil && ginCtx.Request != nil {
+			if key := strings.TrimSpace(ginCtx.GetHeader("Idempotency-Key")); key != "" {
+				principal := ""
+				if v, exists := ginCtx.Get("apiKey"); exists {
+					principal = fmt.Sprintf("%v", v)
+				}
+				return executor.IdempotentStreamKey(handlerType, modelName, principal, key, rawJSON)
+			}
+		}
+	}
+	return executor.RequestHash(modelName, rawJSON, nil)
 }
 
 // SetAuditContext sets audit-related values in the Gin context for the audit middleware.
@@ -828,6 +1318,20 @@ func SetAuditContext(c *gin.Context, provider, model string, inputTokens, output
 	c.Set("audit_cached", cached)
 }
 
+// SetAuditMetadata adds a key/value pair to the metadata recorded by the audit middleware.
+func SetAuditMetadata(c *gin.Context, key, value string) {
+	if c == nil || key == "" {
+		return
+	}
+	meta, _ := c.Get("audit_metadata")
+	m, ok := meta.(map[string]string)
+	if !ok {
+		m = make(map[string]string)
+		c.Set("audit_metadata", m)
+	}
+	m[key] = value
+}
+
 // SetAuditAuth sets auth-related values in the Gin context for the audit middleware.
 func SetAuditAuth(c *gin.Context, authID, authLabel string) {
 	if c == nil {
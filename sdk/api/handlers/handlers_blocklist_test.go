@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/routing"
+	"github.com/tidwall/gjson"
+)
+
+func newBlocklistTestHandler(t *testing.T) (*BaseAPIHandler, *modelFailureExecutor) {
+	t.Helper()
+	handler, executor := newFallbackTestHandler(t)
+	handler.Cfg.ModelRouting.BlockedModels = []config.BlockedModel{
+		{Pattern: "primary-model", Message: "disabled for compliance review", Replacement: "backup-model"},
+		{Pattern: "last-*", Message: "too expensive"},
+	}
+	router, err := routing.NewResolver(handler.Cfg.ModelRouting)
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+	handler.ModelRouter = router
+	return handler, executor
+}
+
+func TestExecuteWithAuthManager_BlockedExactModel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, executor := newBlocklistTestHandler(t)
+
+	_, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "Primary-Model", []byte(`{"model":"Primary-Model"}`), "")
+	if errMsg == nil {
+		t.Fatalf("expected blocked model to be rejected")
+	}
+	if errMsg.StatusCode != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", errMsg.StatusCode, http.StatusForbidden)
+	}
+	if len(executor.calls) != 0 {
+		t.Fatalf("blocked model reached the executor: %v", executor.calls)
+	}
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	handler.WriteErrorResponse(c, errMsg)
+	body := rec.Body.Bytes()
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("written status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if code := gjson.GetBytes(body, "error.code").String(); code != "model_blocked" {
+		t.Fatalf("error.code = %q, want model_blocked (body %s)", code, body)
+	}
+	if replacement := gjson.GetBytes(body, "error.replacement").String(); replacement != "backup-model" {
+		t.Fatalf("error.replacement = %q, want backup-model", replacement)
+	}
+	want := "model Primary-Model is not available on this server: disabled for compliance review (use backup-model instead)"
+	if msg := gjson.GetBytes(body, "error.message").String(); msg != want {
+		t.Fatalf("error.message = %q, want %q", msg, want)
+	}
+}
+
+func TestExecuteStreamWithAuthManager_BlockedWildcardFamily(t *testing.T) {
+	handler, executor := newBlocklistTestHandler(t)
+
+	dataChan, errChan := handler.ExecuteStreamWithAuthManager(context.Background(), "openai", "last-resort-model", []byte(`{"model":"last-resort-model"}`), "")
+	if dataChan != nil {
+		for range dataChan {
+			t.Fatalf("blocked model streamed a payload")
+		}
+	}
+	errMsg := <-errChan
+	if errMsg == nil || errMsg.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for wildcard-blocked model, got %+v", errMsg)
+	}
+	if len(executor.calls) != 0 {
+		t.Fatalf("blocked model reached the executor: %v", executor.calls)
+	}
+	body := []byte(errMsg.Error.Error())
+	if msg := gjson.GetBytes(body, "error.message").String(); msg != "model last-resort-model is not available on this server: too expensive" {
+		t.Fatalf("unexpected message %q", msg)
+	}
+	if gjson.GetBytes(body, "error.replacement").Exists() {
+		t.Fatalf("replacement should be omitted when not configured: %s", body)
+	}
+
+	// Models outside the blocked family still dispatch normally.
+	resp, okMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "backup-model", []byte(`{"model":"backup-model"}`), "")
+	if okMsg != nil || string(resp) != "served by backup-model" {
+		t.Fatalf("unblocked model failed: resp=%q err=%+v", resp, okMsg)
+	}
+}
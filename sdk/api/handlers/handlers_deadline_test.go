@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// blockingExecutor blocks until the upstream context ends and reports why.
+type blockingExecutor struct {
+	started chan struct{}
+	ended   chan error
+}
+
+func (e *blockingExecutor) Identifier() string { return "codex" }
+
+func (e *blockingExecutor) Execute(ctx context.Context, _ *coreauth.Auth, _ coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	close(e.started)
+	<-ctx.Done()
+	e.ended <- ctx.Err()
+	return coreexecutor.Response{}, ctx.Err()
+}
+
+func (e *blockingExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (e *blockingExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *blockingExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (e *blockingExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func newBlockingTestHandler(t *testing.T) (*BaseAPIHandler, *blockingExecutor) {
+	t.Helper()
+	executor := &blockingExecutor{started: make(chan struct{}), ended: make(chan error, 1)}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "deadline-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "deadline-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+	return NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager), executor
+}
+
+func TestGetContextWithCancel_ClientDisconnectCancelsUpstream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, executor := newBlockingTestHandler(t)
+
+	clientCtx, disconnect := context.WithCancel(context.Background())
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil).WithContext(clientCtx)
+
+	ctx, cancel := handler.GetContextWithCancel(nil, c, context.Background())
+	defer cancel()
+	go func() {
+		_, _ = handler.ExecuteWithAuthManager(ctx, "openai", "deadline-model", []byte(`{"model":"deadline-model"}`), "")
+	}()
+
+	<-executor.started
+	disconnect()
+	select {
+	case err := <-executor.ended:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("upstream context error = %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("upstream call outlived client disconnect")
+	}
+}
+
+func TestGetContextWithCancel_TimeoutHeaderSetsDeadline(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, executor := newBlockingTestHandler(t)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	c.Request.Header.Set("X-Timeout-Ms", "50")
+
+	ctx, cancel := handler.GetContextWithCancel(nil, c, context.Background())
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		t.Fatalf("expected deadline from X-Timeout-Ms header")
+	}
+	if remaining := time.Until(deadline); remaining > 50*time.Millisecond {
+		t.Fatalf("deadline too far in the future: %v", remaining)
+	}
+
+	start := time.Now()
+	_, errMsg := handler.ExecuteWithAuthManager(ctx, "openai", "deadline-model", []byte(`{"model":"deadline-model"}`), "")
+	if errMsg == nil {
+		t.Fatalf("expected error once the deadline elapsed")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("upstream ran for %v despite 50ms timeout", elapsed)
+	}
+	if err := <-executor.ended; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("upstream context error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestRequestDeadline_PrefersEarliest(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+	c.Request.Header.Set("X-Timeout-Ms", "60000")
+
+	requestCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	want, _ := requestCtx.Deadline()
+
+	got, ok := requestDeadline(c, requestCtx)
+	if !ok || !got.Equal(want) {
+		t.Fatalf("requestDeadline = %v, %v; want request context deadline %v", got, ok, want)
+	}
+
+	c.Request.Header.Set("X-Timeout-Ms", "not-a-number")
+	if _, ok := requestDeadline(c, context.Background()); ok {
+		t.Fatalf("invalid header should not produce a deadline")
+	}
+}
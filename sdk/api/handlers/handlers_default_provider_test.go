@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/routing"
+)
+
+func TestExecuteWithAuthManager_UnknownModelUsesDefaultProvider(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, executor := newFallbackTestHandler(t)
+	handler.Cfg.ModelRouting.DefaultProvider = "codex"
+	router, err := routing.NewResolver(handler.Cfg.ModelRouting)
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+	handler.ModelRouter = router
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	ctx := context.WithValue(context.Background(), "gin", c)
+	resp, errMsg := handler.ExecuteWithAuthManager(ctx, "openai", "mystery-model", []byte(`{"model":"mystery-model"}`), "")
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	if string(resp) != "served by mystery-model" {
+		t.Fatalf("response = %q", resp)
+	}
+	if len(executor.calls) != 1 || executor.calls[0] != "mystery-model" {
+		t.Fatalf("executor calls = %v, want [mystery-model]", executor.calls)
+	}
+	if got := rec.Header().Get("X-Default-Provider"); got != "codex" {
+		t.Fatalf("X-Default-Provider = %q, want codex", got)
+	}
+	meta, _ := c.Get("audit_metadata")
+	if got := meta.(map[string]string)["default_provider"]; got != "codex" {
+		t.Fatalf("audit default_provider = %q, want codex", got)
+	}
+}
+
+func TestExecuteWithAuthManager_UnknownModelWithoutDefaultProvider(t *testing.T) {
+	handler, executor := newFallbackTestHandler(t)
+
+	_, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "mystery-model", []byte(`{"model":"mystery-model"}`), "")
+	if errMsg == nil {
+		t.Fatal("expected an unknown model to be rejected")
+	}
+	if errMsg.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", errMsg.StatusCode, http.StatusBadRequest)
+	}
+	if msg := errMsg.Error.Error(); !strings.Contains(msg, "unknown provider for model mystery-model") || !strings.Contains(msg, "default-provider") {
+		t.Fatalf("error = %q, want an explanation naming the model and default-provider setting", msg)
+	}
+	if len(executor.calls) != 0 {
+		t.Fatalf("unknown model reached the executor: %v", executor.calls)
+	}
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/scheduler"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// modelFailureExecutor fails every request for the configured models with status, or 503.
+type modelFailureExecutor struct {
+	mu     sync.Mutex
+	failed map[string]bool
+	status int
+	calls  []string
+}
+
+func (e *modelFailureExecutor) Identifier() string { return "codex" }
+
+func (e *modelFailureExecutor) record(model string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls = append(e.calls, model)
+	if e.failed[model] {
+		status := e.status
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		return &coreauth.Error{Code: "unavailable", Message: model + " unavailable", HTTPStatus: status}
+	}
+	return nil
+}
+
+func (e *modelFailureExecutor) Execute(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	if err := e.record(req.Model); err != nil {
+		return coreexecutor.Response{}, err
+	}
+	return coreexecutor.Response{Payload: []byte("served by " + req.Model)}, nil
+}
+
+func (e *modelFailureExecutor) ExecuteStream(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	ch := make(chan coreexecutor.StreamChunk, 1)
+	if err := e.record(req.Model); err != nil {
+		ch <- coreexecutor.StreamChunk{Err: err}
+	} else {
+		ch <- coreexecutor.StreamChunk{Payload: []byte("served by " + req.Model)}
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (e *modelFailureExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *modelFailureExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (e *modelFailureExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func newFallbackTestHandler(t *testing.T, failed ...string) (*BaseAPIHandler, *modelFailureExecutor) {
+	t.Helper()
+	executor := &modelFailureExecutor{failed: map[string]bool{}}
+	for _, model := range failed {
+		executor.failed[model] = true
+	}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+
+	auth := &coreauth.Auth{ID: "fallback-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{
+		{ID: "primary-model"}, {ID: "backup-model"}, {ID: "last-resort-model"},
+	})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.ModelRouting.FallbackChains = map[string][]string{
+		"primary-model": {"backup-model", "last-resort-model"},
+	}
+	return NewBaseAPIHandlers(cfg, manager), executor
+}
+
+func TestExecuteWithAuthManager_FallsBackWhenPrimaryFails(t *testing.T) {
+	handler, executor := newFallbackTestHandler(t, "primary-model")
+
+	resp, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "primary-model", []byte(`{"model":"primary-model"}`), "")
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	if string(resp) != "served by backup-model" {
+		t.Fatalf("response = %q, want served by backup-model", resp)
+	}
+	if n := len(executor.calls); n == 0 || executor.calls[n-1] != "backup-model" {
+		t.Fatalf("unexpected call sequence: %v", executor.calls)
+	}
+}
+
+func TestExecuteWithAuthManager_WholeChainFails(t *testing.T) {
+	handler, executor := newFallbackTestHandler(t, "primary-model", "backup-model", "last-resort-model")
+
+	_, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "primary-model", []byte(`{"model":"primary-model"}`), "")
+	if errMsg == nil {
+		t.Fatalf("expected error when every model in the chain fails")
+	}
+	if errMsg.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", errMsg.StatusCode, http.StatusServiceUnavailable)
+	}
+	tried := map[string]bool{}
+	for _, model := range executor.calls {
+		tried[model] = true
+	}
+	for _, model := range []string{"primary-model", "backup-model", "last-resort-model"} {
+		if !tried[model] {
+			t.Errorf("model %s was never attempted (calls %v)", model, executor.calls)
+		}
+	}
+}
+
+func TestExecuteWithAuthManager_ClientErrorDoesNotFallBack(t *testing.T) {
+	handler, executor := newFallbackTestHandler(t, "primary-model")
+	executor.status = http.StatusBadRequest
+
+	_, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "primary-model", []byte(`{"model":"primary-model"}`), "")
+	if errMsg == nil || errMsg.StatusCode != http.StatusBadRequest {
+		t.Fatalf("errMsg = %+v, want the primary's 400", errMsg)
+	}
+	for _, model := range executor.calls {
+		if model != "primary-model" {
+			t.Fatalf("fell back to %s on a client error (calls %v)", model, executor.calls)
+		}
+	}
+}
+
+func TestExecuteWithFallback_StopsOnceContextIsDone(t *testing.T) {
+	handler, _ := newFallbackTestHandler(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var tried []string
+	_, errMsg := handler.executeWithFallback(ctx, "primary-model", func(model string) ([]byte, *interfaces.ErrorMessage) {
+		tried = append(tried, model)
+		cancel()
+		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusServiceUnavailable, Error: context.Canceled}
+	})
+	if errMsg == nil {
+		t.Fatal("expected the primary's error")
+	}
+	if len(tried) != 1 {
+		t.Fatalf("tried %v after the client went away, want only the primary", tried)
+	}
+}
+
+func TestExecuteStreamWithAuthManager_FallsBackBeforeFirstByte(t *testing.T) {
+	handler, _ := newFallbackTestHandler(t, "primary-model")
+
+	dataChan, errChan := handler.ExecuteStreamWithAuthManager(context.Background(), "openai", "primary-model", []byte(`{"model":"primary-model"}`), "")
+	var got []byte
+	for chunk := range dataChan {
+		got = append(got, chunk...)
+	}
+	for msg := range errChan {
+		if msg != nil {
+			t.Fatalf("unexpected error: %v", msg.Error)
+		}
+	}
+	if string(got) != "served by backup-model" {
+		t.Fatalf("payload = %q, want served by backup-model", got)
+	}
+}
+
+func TestExecuteWithAuthManager_SpentRetryBudgetStopsRotationAndFallback(t *testing.T) {
+	handler, executor := newFallbackTestHandler(t, "primary-model")
+	second := &coreauth.Auth{ID: "fallback-auth-2", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := handler.AuthManager.Register(context.Background(), second); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(second.ID, second.Provider, []*registry.ModelInfo{{ID: "primary-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(second.ID) })
+	budget := scheduler.InitRetryBudget(scheduler.RetryBudgetConfig{MaxRetries: 1, Window: time.Hour})
+	t.Cleanup(func() { scheduler.InitRetryBudget(scheduler.RetryBudgetConfig{}) })
+	budget.Allow()
+
+	_, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "primary-model", []byte(`{"model":"primary-model"}`), "")
+	if errMsg == nil || errMsg.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("errMsg = %+v, want the primary's 503", errMsg)
+	}
+	if len(executor.calls) != 1 {
+		t.Fatalf("calls = %v, want a single attempt once the retry budget is spent", executor.calls)
+	}
+
+	executor.calls = nil
+	dataChan, errChan := handler.ExecuteStreamWithAuthManager(context.Background(), "openai", "primary-model", []byte(`{"model":"primary-model"}`), "")
+	for range dataChan {
+	}
+	for range errChan {
+	}
+	for _, model := range executor.calls {
+		if model != "primary-model" {
+			t.Fatalf("streamed request fell back to %s with the retry budget spent (calls %v)", model, executor.calls)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// SSEKeepAliveComment is the heartbeat written to SSE streams; clients ignore comment lines.
+	SSEKeepAliveComment = ": keep-alive\n\n"
+	// NonStreamKeepAlive is the heartbeat written while a non-streaming response is pending;
+	// leading whitespace is ignored by JSON decoders.
+	NonStreamKeepAlive = "\n"
+)
+
+// KeepAliveWriter wraps a response writer and emits a heartbeat at a fixed interval.
+//
+// It supports two modes. Loop-driven handlers select on Ticks and call Heartbeat from
+// the goroutine that writes the response. Handlers that block between writes call Start,
+// which emits heartbeats from a background goroutine; in that mode every response write
+// must go through the KeepAliveWriter so heartbeats never land inside a frame.
+type KeepAliveWriter struct {
+	gin.ResponseWriter
+
+	mu        sync.Mutex
+	heartbeat []byte
+	interval  time.Duration
+	ticker    *time.Ticker
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewKeepAliveWriter wraps w so that heartbeat is written every interval.
+// An interval <= 0 disables heartbeats; the writer still serializes writes.
+func NewKeepAliveWriter(w gin.ResponseWriter, interval time.Duration, heartbeat []byte) *KeepAliveWriter {
+	return &KeepAliveWriter{
+		ResponseWriter: w,
+		heartbeat:      heartbeat,
+		interval:       interval,
+		stopCh:         make(chan struct{}),
+	}
+}
+
+// SSEKeepAliveWriter returns a keep-alive writer for an SSE response using streaming.keepalive-seconds.
+func (h *BaseAPIHandler) SSEKeepAliveWriter(c *gin.Context) *KeepAliveWriter {
+	return NewKeepAliveWriter(c.Writer, StreamingKeepAliveInterval(h.Cfg), []byte(SSEKeepAliveComment))
+}
+
+// NonStreamingKeepAliveWriter returns a keep-alive writer for a JSON response using nonstream-keepalive-interval.
+func (h *BaseAPIHandler) NonStreamingKeepAliveWriter(c *gin.Context) *KeepAliveWriter {
+	return NewKeepAliveWriter(c.Writer, NonStreamingKeepAliveInterval(h.Cfg), []byte(NonStreamKeepAlive))
+}
+
+// Enabled reports whether heartbeats are configured.
+func (w *KeepAliveWriter) Enabled() bool {
+	return w != nil && w.interval > 0 && len(w.heartbeat) > 0
+}
+
+// Ticks returns a channel that fires whenever a heartbeat is due, or nil when disabled.
+// It is intended for loop-driven handlers; call Stop when done.
+func (w *KeepAliveWriter) Ticks() <-chan time.Time {
+	if !w.Enabled() {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.ticker == nil {
+		w.ticker = time.NewTicker(w.interval)
+	}
+	return w.ticker.C
+}
+
+// Heartbeat writes one heartbeat and flushes it to the client.
+func (w *KeepAliveWriter) Heartbeat() {
+	if w == nil || len(w.heartbeat) == 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, _ = w.ResponseWriter.Write(w.heartbeat)
+	w.ResponseWriter.Flush()
+}
+
+// Write writes p as a single unit so background heartbeats cannot split it.
+func (w *KeepAliveWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.ResponseWriter.Write(p)
+}
+
+// WriteString writes s as a single unit so background heartbeats cannot split it.
+func (w *KeepAliveWriter) WriteString(s string) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Flush flushes buffered data to the client.
+func (w *KeepAliveWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.ResponseWriter.Flush()
+}
+
+// Start emits heartbeats from a background goroutine until ctx is done or the returned
+// stop function is called. Stop waits for the goroutine, so no heartbeat is written after it returns.
+func (w *KeepAliveWriter) Start(ctx context.Context) func() {
+	ticks := w.Ticks()
+	if ticks == nil {
+		return func() {}
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		for {
+			select {
+			case <-w.stopCh:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticks:
+				w.Heartbeat()
+			}
+		}
+	}()
+	return w.Stop
+}
+
+// Stop halts heartbeats and waits for any background goroutine to exit. It is safe to call more than once.
+func (w *KeepAliveWriter) Stop() {
+	if w == nil {
+		return
+	}
+	w.stopOnce.Do(func() {
+		close(w.stopCh)
+		w.mu.Lock()
+		if w.ticker != nil {
+			w.ticker.Stop()
+		}
+		w.mu.Unlock()
+	})
+	w.wg.Wait()
+}
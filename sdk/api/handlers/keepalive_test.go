@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func newKeepAliveTestContext() (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	return c, rec
+}
+
+func TestKeepAliveWriter_ForwardStreamEmitsSSEComments(t *testing.T) {
+	c, rec := newKeepAliveTestContext()
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, nil)
+
+	data := make(chan []byte)
+	errs := make(chan *interfaces.ErrorMessage)
+	go func() {
+		time.Sleep(60 * time.Millisecond)
+		data <- []byte("data: {}")
+		close(data)
+	}()
+
+	interval := 10 * time.Millisecond
+	h.ForwardStream(c, c.Writer, func(error) {}, data, errs, StreamForwardOptions{
+		KeepAliveInterval: &interval,
+		WriteChunk: func(chunk []byte) {
+			_, _ = c.Writer.Write(append(chunk, '\n', '\n'))
+		},
+	})
+
+	body := rec.Body.String()
+	if !strings.HasPrefix(body, SSEKeepAliveComment) {
+		t.Fatalf("stream should open with heartbeats while idle: %q", body)
+	}
+	if !strings.HasSuffix(body, "data: {}\n\n") {
+		t.Fatalf("data chunk missing after heartbeats: %q", body)
+	}
+}
+
+func TestKeepAliveWriter_NonStreamingEmitsWhitespace(t *testing.T) {
+	c, rec := newKeepAliveTestContext()
+
+	kw := NewKeepAliveWriter(c.Writer, 10*time.Millisecond, []byte(NonStreamKeepAlive))
+	stop := kw.Start(context.Background())
+	time.Sleep(45 * time.Millisecond)
+	stop()
+	_, _ = c.Writer.Write([]byte(`{"ok":true}`))
+
+	body := rec.Body.String()
+	prefix := strings.TrimSuffix(body, `{"ok":true}`)
+	if prefix == body || prefix == "" || strings.Trim(prefix, "\n") != "" {
+		t.Fatalf("expected newline heartbeats before the JSON body, got %q", body)
+	}
+}
+
+func TestKeepAliveWriter_BackgroundHeartbeatsDoNotSplitWrites(t *testing.T) {
+	c, rec := newKeepAliveTestContext()
+
+	kw := NewKeepAliveWriter(c.Writer, time.Millisecond, []byte(SSEKeepAliveComment))
+	stop := kw.Start(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				_, _ = kw.Write([]byte("data: {\"chunk\":true}\n\n"))
+				time.Sleep(100 * time.Microsecond)
+			}
+		}()
+	}
+	wg.Wait()
+	stop()
+
+	for _, frame := range strings.Split(strings.TrimSuffix(rec.Body.String(), "\n\n"), "\n\n") {
+		if frame != `data: {"chunk":true}` && frame != ": keep-alive" {
+			t.Fatalf("corrupted frame %q", frame)
+		}
+	}
+}
+
+func TestKeepAliveWriter_DisabledByDefault(t *testing.T) {
+	c, rec := newKeepAliveTestContext()
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, nil)
+
+	stop := h.StartNonStreamingKeepAlive(c, context.Background())
+	time.Sleep(20 * time.Millisecond)
+	stop()
+	if h.SSEKeepAliveWriter(c).Enabled() {
+		t.Fatal("SSE keep-alive should be disabled without streaming.keepalive-seconds")
+	}
+	if rec.Body.Len() != 0 {
+		t.Fatalf("unexpected heartbeat output: %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/net/context"
+)
+
+// MaxTokensCap describes what ClampMaxTokens did to a request.
+type MaxTokensCap struct {
+	// Field is the payload path that carries the output-token limit.
+	Field string
+	// Requested is the client's value, or 0 when the client set none.
+	Requested int64
+	// Limit is the configured cap.
+	Limit int64
+	// Clamped is true when Requested exceeded Limit and was lowered.
+	Clamped bool
+	// Injected is true when the client set no limit and Limit was added.
+	Injected bool
+}
+
+// maxTokensFields lists the output-token limit paths for each inbound request format.
+// The first path is the one injected when the client sets none.
+var maxTokensFields = map[string][]string{
+	constant.OpenAI:         {"max_tokens", "max_completion_tokens"},
+	constant.OpenaiResponse: {"max_output_tokens"},
+	constant.Claude:         {"max_tokens"},
+	constant.Gemini:         {"generationConfig.maxOutputTokens"},
+	constant.GeminiCLI:      {"request.generationConfig.maxOutputTokens"},
+}
+
+// ClampMaxTokens lowers any output-token limit in payload above limit and injects limit
+// when the payload sets none. Payloads in unknown formats are returned unchanged.
+func ClampMaxTokens(handlerType string, payload []byte, limit int64) ([]byte, MaxTokensCap) {
+	result := MaxTokensCap{Limit: limit}
+	fields := maxTokensFields[handlerType]
+	if limit <= 0 || len(fields) == 0 || !gjson.ValidBytes(payload) {
+		return payload, result
+	}
+
+	out := payload
+	found := false
+	for _, field := range fields {
+		value := gjson.GetBytes(payload, field)
+		if !value.Exists() {
+			continue
+		}
+		found = true
+		if value.Int() <= limit {
+			continue
+		}
+		if updated, err := sjson.SetBytes(out, field, limit); err == nil {
+			out = updated
+			if !result.Clamped {
+				result.Field, result.Requested, result.Clamped = field, value.Int(), true
+			}
+		}
+	}
+	if found {
+		return out, result
+	}
+
+	if updated, err := sjson.SetBytes(out, fields[0], limit); err == nil {
+		out = updated
+		result.Field, result.Injected = fields[0], true
+	}
+	return out, result
+}
+
+// maxTokensLimit returns the configured output-token cap for the first model that has one.
+func (h *BaseAPIHandler) maxTokensLimit(models ...string) int64 {
+	if h == nil || h.Cfg == nil || len(h.Cfg.ModelMaxTokens) == 0 {
+		return 0
+	}
+	for _, model := range models {
+		if limit, ok := h.Cfg.ModelMaxTokens[model]; ok && limit > 0 {
+			return limit
+		}
+		for name, limit := range h.Cfg.ModelMaxTokens {
+			if limit > 0 && strings.EqualFold(name, model) {
+				return limit
+			}
+		}
+	}
+	return 0
+}
+
+// applyMaxTokensCap enforces model-max-tokens on an inbound payload and records any change in the audit log.
+func (h *BaseAPIHandler) applyMaxTokensCap(ctx context.Context, handlerType string, payload []byte, models ...string) []byte {
+	limit := h.maxTokensLimit(models...)
+	if limit <= 0 {
+		return payload
+	}
+	out, result := ClampMaxTokens(handlerType, payload, limit)
+	if !result.Clamped && !result.Injected {
+		return out
+	}
+	var ginCtx *gin.Context
+	if ctx != nil {
+		ginCtx, _ = ctx.Value("gin").(*gin.Context)
+	}
+	if result.Clamped {
+		log.Debugf("clamped %s from %d to %d for model %s", result.Field, result.Requested, limit, models[0])
+		SetAuditMetadata(ginCtx, "max_tokens_clamped", strconv.FormatInt(result.Requested, 10)+"->"+strconv.FormatInt(limit, 10))
+	} else {
+		SetAuditMetadata(ginCtx, "max_tokens_injected", strconv.FormatInt(limit, 10))
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+// payloadCaptureExecutor records the payload it receives from the handler.
+type payloadCaptureExecutor struct {
+	mu      sync.Mutex
+	payload []byte
+}
+
+func (e *payloadCaptureExecutor) Identifier() string { return "codex" }
+
+func (e *payloadCaptureExecutor) Execute(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	e.mu.Lock()
+	e.payload = append([]byte(nil), req.Payload...)
+	e.mu.Unlock()
+	return coreexecutor.Response{Payload: []byte(`{"ok":true}`)}, nil
+}
+
+func (e *payloadCaptureExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (e *payloadCaptureExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *payloadCaptureExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (e *payloadCaptureExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func (e *payloadCaptureExecutor) Payload() []byte {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.payload
+}
+
+func TestClampMaxTokens(t *testing.T) {
+	cases := []struct {
+		name        string
+		handlerType string
+		payload     string
+		path        string
+		want        int64
+		clamped     bool
+		injected    bool
+	}{
+		{name: "above cap", handlerType: "openai", payload: `{"max_tokens":9000}`, path: "max_tokens", want: 1000, clamped: true},
+		{name: "absent", handlerType: "openai", payload: `{"messages":[]}`, path: "max_tokens", want: 1000, injected: true},
+		{name: "below cap", handlerType: "openai", payload: `{"max_tokens":200}`, path: "max_tokens", want: 200},
+		{name: "completion tokens", handlerType: "openai", payload: `{"max_completion_tokens":5000}`, path: "max_completion_tokens", want: 1000, clamped: true},
+		{name: "claude", handlerType: "claude", payload: `{"max_tokens":4096}`, path: "max_tokens", want: 1000, clamped: true},
+		{name: "gemini", handlerType: "gemini", payload: `{"contents":[]}`, path: "generationConfig.maxOutputTokens", want: 1000, injected: true},
+		{name: "responses", handlerType: "openai-response", payload: `{"max_output_tokens":1000}`, path: "max_output_tokens", want: 1000},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, result := ClampMaxTokens(tc.handlerType, []byte(tc.payload), 1000)
+			if got := gjson.GetBytes(out, tc.path).Int(); got != tc.want {
+				t.Fatalf("%s = %d, want %d (payload %s)", tc.path, got, tc.want, out)
+			}
+			if result.Clamped != tc.clamped || result.Injected != tc.injected {
+				t.Fatalf("result = %+v, want clamped=%v injected=%v", result, tc.clamped, tc.injected)
+			}
+		})
+	}
+}
+
+func TestExecuteWithAuthManager_AppliesModelMaxTokens(t *testing.T) {
+	executor := &payloadCaptureExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "max-tokens-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "capped-model"}, {ID: "free-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	cfg := &sdkconfig.SDKConfig{ModelMaxTokens: map[string]int64{"capped-model": 512}}
+	handler := NewBaseAPIHandlers(cfg, manager)
+
+	if _, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "capped-model", []byte(`{"model":"capped-model","max_tokens":100000}`), ""); errMsg != nil {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	if got := gjson.GetBytes(executor.Payload(), "max_tokens").Int(); got != 512 {
+		t.Fatalf("upstream max_tokens = %d, want 512", got)
+	}
+
+	if _, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "free-model", []byte(`{"model":"free-model"}`), ""); errMsg != nil {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	if gjson.GetBytes(executor.Payload(), "max_tokens").Exists() {
+		t.Fatalf("uncapped model should be untouched: %s", executor.Payload())
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/tidwall/gjson"
+)
+
+// messageFields lists the paths holding the conversation in each inbound request format:
+// OpenAI and Claude messages, Responses input items and Gemini contents.
+var messageFields = []string{"messages", "input", "contents", "request.contents"}
+
+// CountMessages returns the number of messages in payload, or 0 when it carries no
+// message array.
+func CountMessages(payload []byte) int {
+	for _, field := range messageFields {
+		if value := gjson.GetBytes(payload, field); value.IsArray() {
+			return int(gjson.GetBytes(payload, field+".#").Int())
+		}
+	}
+	return 0
+}
+
+// maxMessagesLimit returns the message limit for model: its model-max-messages entry when
+// it has a positive one, and max-messages otherwise.
+func (h *BaseAPIHandler) maxMessagesLimit(model string) int {
+	if h == nil || h.Cfg == nil {
+		return 0
+	}
+	if limit, ok := h.Cfg.ModelMaxMessages[model]; ok && limit > 0 {
+		return limit
+	}
+	for name, limit := range h.Cfg.ModelMaxMessages {
+		if limit > 0 && strings.EqualFold(name, model) {
+			return limit
+		}
+	}
+	return h.Cfg.MaxMessages
+}
+
+// validateRequest runs the cheap structural checks ahead of the guardrails, so a request
+// rejected by them costs no moderation call either.
+func (h *BaseAPIHandler) validateRequest(ctx context.Context, handlerType, modelName string, rawJSON []byte) *interfaces.ErrorMessage {
+	if errMsg := h.CheckMessageLimit(modelName, rawJSON); errMsg != nil {
+		return errMsg
+	}
+	return h.runGuardrails(ctx, handlerType, modelName, rawJSON)
+}
+
+// CheckMessageLimit rejects a request carrying more messages than allowed for modelName.
+// It runs before the request is translated or dispatched, so oversized conversations
+// never reach the translators or the context manager. Handlers that rewrite the body
+// before dispatching it call it first themselves.
+func (h *BaseAPIHandler) CheckMessageLimit(modelName string, rawJSON []byte) *interfaces.ErrorMessage {
+	if violation := h.messageLimitViolation(modelName, rawJSON); violation != "" {
+		return guardrailError(http.StatusBadRequest, "invalid_request_error", "too_many_messages", violation)
+	}
+	return nil
+}
+
+// messageLimitViolation describes how rawJSON exceeds the message limit for modelName, or
+// returns "" when it does not.
+func (h *BaseAPIHandler) messageLimitViolation(modelName string, rawJSON []byte) string {
+	limit := h.maxMessagesLimit(modelName)
+	if limit <= 0 {
+		return ""
+	}
+	if count := CountMessages(rawJSON); count > limit {
+		return fmt.Sprintf("request has %d messages, more than the %d allowed for model %s", count, limit, modelName)
+	}
+	return ""
+}
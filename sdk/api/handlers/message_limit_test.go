@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func messagesPayload(model string, n int) []byte {
+	messages := make([]string, n)
+	for i := range messages {
+		messages[i] = `{"role":"user","content":"hi"}`
+	}
+	return []byte(`{"model":"` + model + `","messages":[` + strings.Join(messages, ",") + `]}`)
+}
+
+func TestCountMessages(t *testing.T) {
+	cases := map[string]int{
+		`{"messages":[{},{},{}]}`:          3,
+		`{"input":[{},{}]}`:                2,
+		`{"input":"plain text"}`:           0,
+		`{"contents":[{}]}`:                1,
+		`{"request":{"contents":[{},{}]}}`: 2,
+		`{"model":"gpt-4o"}`:               0,
+	}
+	for payload, want := range cases {
+		if got := CountMessages([]byte(payload)); got != want {
+			t.Errorf("CountMessages(%s) = %d, want %d", payload, got, want)
+		}
+	}
+}
+
+func TestExecuteWithAuthManager_EnforcesMaxMessages(t *testing.T) {
+	executor := &payloadCaptureExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "max-messages-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "short-model"}, {ID: "long-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	cfg := &sdkconfig.SDKConfig{MaxMessages: 3, ModelMaxMessages: map[string]int{"long-model": 5}}
+	handler := NewBaseAPIHandlers(cfg, manager)
+
+	if _, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "short-model", messagesPayload("short-model", 3), ""); errMsg != nil {
+		t.Fatalf("request at the limit rejected: %v", errMsg.Error)
+	}
+	if executor.Payload() == nil {
+		t.Fatal("request at the limit did not reach the executor")
+	}
+
+	executor.mu.Lock()
+	executor.payload = nil
+	executor.mu.Unlock()
+	_, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "short-model", messagesPayload("short-model", 4), "")
+	if errMsg == nil || errMsg.StatusCode != http.StatusBadRequest {
+		t.Fatalf("request over the limit = %+v, want a 400", errMsg)
+	}
+	if !strings.Contains(errMsg.Error.Error(), "too_many_messages") {
+		t.Fatalf("error = %v, want too_many_messages", errMsg.Error)
+	}
+	if executor.Payload() != nil {
+		t.Fatal("request over the limit reached the executor")
+	}
+
+	if _, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "long-model", messagesPayload("long-model", 5), ""); errMsg != nil {
+		t.Fatalf("per-model override not applied: %v", errMsg.Error)
+	}
+	if _, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "long-model", messagesPayload("long-model", 6), ""); errMsg == nil || errMsg.StatusCode != http.StatusBadRequest {
+		t.Fatalf("request over the per-model limit = %+v, want a 400", errMsg)
+	}
+}
+
+func TestExecuteStreamWithAuthManager_EnforcesMaxMessages(t *testing.T) {
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{MaxMessages: 1}, coreauth.NewManager(nil, nil, nil))
+	dataChan, errChan := handler.ExecuteStreamWithAuthManager(context.Background(), "openai", "any-model", messagesPayload("any-model", 2), "")
+	if dataChan != nil {
+		t.Fatal("stream opened for a request over the limit")
+	}
+	if errMsg := <-errChan; errMsg == nil || errMsg.StatusCode != http.StatusBadRequest {
+		t.Fatalf("stream error = %+v, want a 400", errMsg)
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	providererrors "github.com/router-for-me/CLIProxyAPI/v6/internal/errors"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// CacheHeader reports how the response cache served a request.
+const CacheHeader = "X-Cache"
+
+// negativeCache returns the negative cache, or nil when negative caching is disabled.
+// Replaced in tests.
+var negativeCache = func() *cache.NegativeCache {
+	return cache.GetCacheSystem().Negative
+}
+
+// requestCacheKeyConfig converts the configured cache-key settings, falling back to the
+// defaults when the section is left empty.
+func requestCacheKeyConfig(cfg *config.SDKConfig) cache.CacheKeyConfig {
+	if cfg == nil {
+		return cache.DefaultCacheKeyConfig()
+	}
+	kc := cfg.Cache.CacheKey
+	keyCfg := cache.DefaultCacheKeyConfig()
+	if kc.IncludeModel || kc.IncludeSystemPrompt || kc.IncludeTemperature ||
+		kc.IncludeMaxTokens || kc.IncludeTools || len(kc.ExcludeFields) > 0 {
+		keyCfg = cache.CacheKeyConfig{
+			IncludeModel:        kc.IncludeModel,
+			IncludeSystemPrompt: kc.IncludeSystemPrompt,
+			IncludeTemperature:  kc.IncludeTemperature,
+			IncludeMaxTokens:    kc.IncludeMaxTokens,
+			IncludeTools:        kc.IncludeTools,
+			IncludeReasoning:    true,
+			ExcludeFields:       kc.ExcludeFields,
+		}
+	}
+	if kc.IncludeReasoning != nil {
+		keyCfg.IncludeReasoning = *kc.IncludeReasoning
+	}
+	return keyCfg
+}
+
+// negativeCacheKey returns the key a request's provider error is cached under, or "" when
+// negative caching is disabled. The handler type is part of the key because the replayed
+// body is in that handler's error format.
+func (h *BaseAPIHandler) negativeCacheKey(handlerType, modelName string, rawJSON []byte) string {
+	if negativeCache() == nil {
+		return ""
+	}
+	return handlerType + ":" + cache.RequestCacheKey(requestCacheKeyConfig(h.Cfg), modelName, rawJSON)
+}
+
+// replayNegative returns the cached provider error for key, marked with X-Cache: NEGATIVE.
+func replayNegative(key string) *interfaces.ErrorMessage {
+	nc := negativeCache()
+	if key == "" || nc == nil {
+		return nil
+	}
+	entry, ok := nc.Get(key)
+	if !ok {
+		return nil
+	}
+	addon := http.Header{}
+	addon.Set(CacheHeader, "NEGATIVE")
+	return &interfaces.ErrorMessage{StatusCode: entry.StatusCode, Error: errors.New(entry.Body), Addon: addon}
+}
+
+// storeNegative caches errMsg under key when it classifies as a deterministic client error.
+func storeNegative(key, handlerType string, errMsg *interfaces.ErrorMessage) {
+	nc := negativeCache()
+	if key == "" || nc == nil || errMsg == nil || errMsg.Error == nil {
+		return
+	}
+	body := errMsg.Error.Error()
+	nc.Set(key, providererrors.ParseProviderError(handlerType, errMsg.StatusCode, []byte(body)), body)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// statusErrorExecutor fails every request with the configured status and counts calls.
+type statusErrorExecutor struct {
+	status int
+	body   string
+	calls  int
+}
+
+func (e *statusErrorExecutor) Identifier() string { return "codex" }
+
+func (e *statusErrorExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	e.calls++
+	return coreexecutor.Response{}, &coreauth.Error{Message: e.body, HTTPStatus: e.status}
+}
+
+func (e *statusErrorExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	e.calls++
+	return nil, &coreauth.Error{Message: e.body, HTTPStatus: e.status}
+}
+
+func (e *statusErrorExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *statusErrorExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (e *statusErrorExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func newNegativeCacheTestHandler(t *testing.T, status int, body string) (*BaseAPIHandler, *statusErrorExecutor) {
+	t.Helper()
+	nc := cache.NewNegativeCache(10, time.Minute)
+	previous := negativeCache
+	negativeCache = func() *cache.NegativeCache { return nc }
+	t.Cleanup(func() { negativeCache = previous })
+
+	executor := &statusErrorExecutor{status: status, body: body}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "negative-cache-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "negative-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+	return NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager), executor
+}
+
+func TestExecuteWithAuthManager_ReplaysCachedClientError(t *testing.T) {
+	body := `{"error":{"type":"invalid_request_error","code":"context_length_exceeded","message":"too long"}}`
+	handler, executor := newNegativeCacheTestHandler(t, http.StatusBadRequest, body)
+	payload := []byte(`{"model":"negative-model","messages":[{"role":"user","content":"hi"}]}`)
+
+	_, first := handler.ExecuteWithAuthManager(context.Background(), "openai", "negative-model", payload, "")
+	if first == nil || first.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected upstream 400, got %+v", first)
+	}
+	if first.Addon.Get(CacheHeader) != "" {
+		t.Fatalf("first error should come from upstream, got X-Cache %q", first.Addon.Get(CacheHeader))
+	}
+	calls := executor.calls
+
+	_, second := handler.ExecuteWithAuthManager(context.Background(), "openai", "negative-model", payload, "")
+	if second == nil || second.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected replayed 400, got %+v", second)
+	}
+	if got := second.Addon.Get(CacheHeader); got != "NEGATIVE" {
+		t.Fatalf("X-Cache = %q, want NEGATIVE", got)
+	}
+	if second.Error.Error() != first.Error.Error() {
+		t.Fatalf("replayed body = %q, want %q", second.Error.Error(), first.Error.Error())
+	}
+	if executor.calls != calls {
+		t.Fatalf("negative hit should not call upstream: calls %d -> %d", calls, executor.calls)
+	}
+
+	other := []byte(`{"model":"negative-model","messages":[{"role":"user","content":"shorter"}]}`)
+	if _, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "negative-model", other, ""); errMsg != nil && errMsg.Addon.Get(CacheHeader) != "" {
+		t.Fatal("a different request must not hit the negative cache")
+	}
+}
+
+func TestExecuteWithAuthManager_DoesNotCacheTransientErrors(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusInternalServerError} {
+		handler, executor := newNegativeCacheTestHandler(t, status, `{"error":{"message":"try later"}}`)
+		payload := []byte(`{"model":"negative-model","messages":[{"role":"user","content":"hi"}]}`)
+
+		handler.ExecuteWithAuthManager(context.Background(), "openai", "negative-model", payload, "")
+		if executor.calls == 0 {
+			t.Fatalf("status %d: expected an upstream call", status)
+		}
+		_, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "negative-model", payload, "")
+		if errMsg == nil {
+			t.Fatalf("status %d: expected an error", status)
+		}
+		if errMsg.Addon.Get(CacheHeader) != "" || negativeCache().Stats().Size != 0 {
+			t.Fatalf("status %d must not be stored in the negative cache", status)
+		}
+	}
+}
@@ -1,15 +1,21 @@
 package openai
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/tools"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/agent"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -20,6 +26,49 @@ type agenticConfig struct {
 	ParallelToolCalls bool
 	MaxConcurrency    int
 	ToolTimeout       time.Duration
+	// MaxStepsBehavior is maxStepsBehaviorError or maxStepsBehaviorPartial; empty means unset.
+	MaxStepsBehavior string
+	// IncludeSummary appends the loop summary to the response.
+	IncludeSummary bool
+	// MaxToolResultBytes caps each tool result's content fed back to the model; 0 disables it.
+	MaxToolResultBytes int
+	// KeepToolResultTail keeps the end of a truncated tool result as well as its start.
+	KeepToolResultTail bool
+	// MaxToolArgsBytes caps the arguments of each streamed tool call; 0 uses the default.
+	MaxToolArgsBytes int
+	// MaxTotalTokens stops the loop once its iterations have used this many tokens; 0 disables it.
+	MaxTotalTokens int64
+	// CompactThresholdBytes summarizes older messages once the request grows past it; 0 disables it.
+	CompactThresholdBytes int
+	// CompactKeepRecent is how many recent messages compaction keeps verbatim.
+	CompactKeepRecent int
+
+	includeSummarySet bool
+}
+
+// applyServerDefaults fills options the request left unset from server configuration. The
+// server's token budget is also a ceiling: a request may ask for a smaller one, never a larger.
+func (c *agenticConfig) applyServerDefaults(cfg *config.SDKConfig) {
+	if cfg == nil {
+		return
+	}
+	if c.MaxStepsBehavior == "" {
+		c.MaxStepsBehavior = normalizeMaxStepsBehavior(cfg.Agent.MaxStepsBehavior)
+	}
+	if !c.includeSummarySet {
+		c.IncludeSummary = cfg.Agent.IncludeSummary
+	}
+	if limit := cfg.Agent.MaxTotalTokens; limit > 0 && (c.MaxTotalTokens <= 0 || c.MaxTotalTokens > limit) {
+		c.MaxTotalTokens = limit
+	}
+	c.CompactThresholdBytes = cfg.Agent.CompactThresholdBytes
+	c.CompactKeepRecent = cfg.Agent.CompactKeepRecent
+	if c.CompactKeepRecent <= 0 {
+		c.CompactKeepRecent = defaultAgenticCompactKeepRecent
+	}
+	c.MaxToolResultBytes = cfg.Agent.MaxToolResultBytes
+	c.KeepToolResultTail = cfg.Agent.ToolResultKeepTail
+	c.MaxToolArgsBytes = cfg.Agent.MaxToolArgsBytes
 }
 
 const (
@@ -28,6 +77,24 @@ const (
 	defaultAgenticToolTimeout    = 30 * time.Second
 )
 
+const (
+	// maxStepsBehaviorError responds with 400 when the iteration cap is reached.
+	maxStepsBehaviorError = "error"
+	// maxStepsBehaviorPartial responds with 200 and the last assistant message.
+	maxStepsBehaviorPartial = "partial"
+)
+
+func normalizeMaxStepsBehavior(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case maxStepsBehaviorPartial:
+		return maxStepsBehaviorPartial
+	case maxStepsBehaviorError:
+		return maxStepsBehaviorError
+	default:
+		return ""
+	}
+}
+
 func parseAgenticConfig(rawJSON []byte) (agenticConfig, []byte) {
 	cfg := agenticConfig{
 		MaxSteps:          defaultAgenticMaxSteps,
@@ -61,6 +128,9 @@ func parseAgenticConfig(rawJSON []byte) (agenticConfig, []byte) {
 		if v := agentic.Get("max_steps"); v.Exists() {
 			cfg.MaxSteps = int(v.Int())
 		}
+		if v := agentic.Get("max_total_tokens"); v.Exists() {
+			cfg.MaxTotalTokens = v.Int()
+		}
 		if v := agentic.Get("parallel_tool_calls"); v.Exists() {
 			cfg.ParallelToolCalls = v.Bool()
 		}
@@ -70,6 +140,13 @@ func parseAgenticConfig(rawJSON []byte) (agenticConfig, []byte) {
 		if v := agentic.Get("tool_timeout_ms"); v.Exists() {
 			cfg.ToolTimeout = time.Duration(v.Int()) * time.Millisecond
 		}
+		if v := agentic.Get("on_max_steps"); v.Exists() {
+			cfg.MaxStepsBehavior = normalizeMaxStepsBehavior(v.String())
+		}
+		if v := agentic.Get("include_summary"); v.Exists() {
+			cfg.IncludeSummary = v.Bool()
+			cfg.includeSummarySet = true
+		}
 	}
 
 	if cfg.MaxSteps <= 0 {
@@ -95,17 +172,26 @@ func (h *OpenAIAPIHandler) handleAgenticNonStreamingResponse(c *gin.Context, raw
 	// Initialize agent loop with config
 	loopCfg := agent.LoopConfig{
 		MaxIterations:     cfg.MaxSteps,
+		MaxTotalTokens:    cfg.MaxTotalTokens,
 		ParallelToolCalls: cfg.ParallelToolCalls,
 		MaxConcurrency:    cfg.MaxConcurrency,
 		ToolTimeout:       cfg.ToolTimeout,
 	}
 	loop := agent.NewLoop(loopCfg, agent.DefaultRegistry())
+	reqCtx, span := startAgenticRequestSpan(c, modelName)
+	defer span.End()
+	defer loop.Finish()
+	// transcript holds the messages the loop added, which compaction may drop from the request.
+	var transcript []string
+	var lastResp []byte
 
 	for loop.ShouldContinue() {
-		loop.StartIteration()
+		loop.StartIteration(reqCtx)
 
 		cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+		stopKeepAlive := h.StartNonStreamingKeepAlive(c, cliCtx)
 		resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, requestJSON, alt)
+		stopKeepAlive()
 		if errMsg != nil {
 			h.WriteErrorResponse(c, errMsg)
 			cliCancel(errMsg.Error)
@@ -113,6 +199,7 @@ func (h *OpenAIAPIHandler) handleAgenticNonStreamingResponse(c *gin.Context, raw
 			return
 		}
 		cliCancel(nil)
+		lastResp = resp
 
 		assistantMsg, toolCalls, err := extractToolCallsFromChatResponse(resp)
 		if err != nil {
@@ -127,18 +214,24 @@ func (h *OpenAIAPIHandler) handleAgenticNonStreamingResponse(c *gin.Context, raw
 		}
 
 		// Record model response with tool calls
-		loop.RecordModelResponse(resp, toolCalls, "", agent.TokenUsage{})
+		loop.RecordModelResponse(resp, toolCalls, "", usageFromChatResponse(resp))
 
 		if len(toolCalls) == 0 {
-			_, _ = c.Writer.Write(resp)
 			loop.MarkComplete()
+			if cfg.IncludeSummary {
+				resp = attachAgenticSummary(resp, loop)
+			}
+			_, _ = c.Writer.Write(resp)
 			return
 		}
 
 		// Execute tools through the loop
-		results := loop.ExecuteTools(c.Request.Context())
+		results := loop.ExecuteTools(reqCtx)
 
-		requestJSON, err = appendAgenticMessages(requestJSON, assistantMsg, results)
+		turn, err := agenticTurnMessages(assistantMsg, results, cfg)
+		if err == nil {
+			requestJSON, err = appendRawMessages(requestJSON, turn)
+		}
 		if err != nil {
 			c.JSON(httpStatusBadRequest, handlers.ErrorResponse{
 				Error: handlers.ErrorDetail{
@@ -149,18 +242,97 @@ func (h *OpenAIAPIHandler) handleAgenticNonStreamingResponse(c *gin.Context, raw
 			loop.RecordError(err)
 			return
 		}
+		transcript = append(transcript, turn...)
+		requestJSON = h.compactAgenticRequest(c, loop, requestJSON, cfg, alt)
 	}
 
-	// Loop ended due to max iterations
+	// Loop ended due to max iterations or the token budget
+	budgetExceeded := loop.State() == agent.StateTokenBudgetExceeded
+	if cfg.MaxStepsBehavior == maxStepsBehaviorPartial && len(lastResp) > 0 {
+		flag := "max_steps_reached"
+		if budgetExceeded {
+			flag = "token_budget_exceeded"
+		}
+		out := buildMaxStepsPartialResponse(lastResp, transcript, len(loop.Iterations()), flag)
+		if cfg.IncludeSummary {
+			out = attachAgenticSummary(out, loop)
+		}
+		_, _ = c.Writer.Write(out)
+		return
+	}
+	message := fmt.Sprintf("agentic max_steps (%d) reached after %d iterations", cfg.MaxSteps, len(loop.Iterations()))
+	if budgetExceeded {
+		message = fmt.Sprintf("agentic max_total_tokens (%d) exceeded after %d iterations using %d tokens",
+			cfg.MaxTotalTokens, len(loop.Iterations()), loop.TotalTokensUsed().TotalTokens)
+	}
 	c.JSON(httpStatusBadRequest, handlers.ErrorResponse{
 		Error: handlers.ErrorDetail{
-			Message: fmt.Sprintf("agentic max_steps (%d) reached after %d iterations",
-				cfg.MaxSteps, len(loop.Iterations())),
-			Type: "invalid_request_error",
+			Message: message,
+			Type:    "invalid_request_error",
 		},
 	})
 }
 
+// buildMaxStepsPartialResponse returns the last model response marked as truncated by the
+// iteration cap or token budget, with the messages exchanged during the loop attached under
+// `agentic`. flag names the `agentic` field set to true to say which limit stopped the loop.
+func buildMaxStepsPartialResponse(lastResp []byte, messages []string, iterations int, flag string) []byte {
+	out, err := sjson.SetBytes(lastResp, "choices.0.finish_reason", "length")
+	if err != nil {
+		out = lastResp
+	}
+
+	transcript := "[]"
+	for _, msg := range messages {
+		transcript, _ = sjson.SetRaw(transcript, "-1", msg)
+	}
+
+	agentic, _ := sjson.Set(`{}`, flag, true)
+	agentic, _ = sjson.Set(agentic, "iterations", iterations)
+	agentic, _ = sjson.SetRaw(agentic, "transcript", transcript)
+	if updated, errSet := sjson.SetRawBytes(out, "agentic", []byte(agentic)); errSet == nil {
+		out = updated
+	}
+	return out
+}
+
+// agenticSummaryJSON encodes the loop summary without per-iteration detail.
+func agenticSummaryJSON(loop *agent.Loop) []byte {
+	summary := loop.Summary()
+	summary.Iterations = nil
+	encoded, err := json.Marshal(summary)
+	if err != nil {
+		return []byte("{}")
+	}
+	return encoded
+}
+
+// attachAgenticSummary merges the loop summary into the response's top-level `agentic` object.
+func attachAgenticSummary(resp []byte, loop *agent.Loop) []byte {
+	out := resp
+	gjson.ParseBytes(agenticSummaryJSON(loop)).ForEach(func(key, value gjson.Result) bool {
+		if updated, err := sjson.SetRawBytes(out, "agentic."+key.String(), []byte(value.Raw)); err == nil {
+			out = updated
+		}
+		return true
+	})
+	return out
+}
+
+// usageFromChatResponse reads OpenAI-style usage counters from a chat completion payload.
+func usageFromChatResponse(resp []byte) agent.TokenUsage {
+	usage := gjson.GetBytes(resp, "usage")
+	if !usage.Exists() {
+		return agent.TokenUsage{}
+	}
+	return agent.TokenUsage{
+		PromptTokens:     usage.Get("prompt_tokens").Int(),
+		CompletionTokens: usage.Get("completion_tokens").Int(),
+		ThinkingTokens:   usage.Get("completion_tokens_details.reasoning_tokens").Int(),
+		TotalTokens:      usage.Get("total_tokens").Int(),
+	}
+}
+
 func extractToolCallsFromChatResponse(resp []byte) ([]byte, []agent.ToolCall, error) {
 	root := gjson.ParseBytes(resp)
 	choice := root.Get("choices.0")
@@ -256,31 +428,47 @@ func normalizeArguments(raw string) json.RawMessage {
 	return encoded
 }
 
-func appendAgenticMessages(rawJSON []byte, assistantMsg []byte, results []agent.ToolResult) ([]byte, error) {
-	messages := gjson.GetBytes(rawJSON, "messages")
-	if !messages.Exists() || !messages.IsArray() {
-		return nil, fmt.Errorf("messages array missing")
-	}
-
-	messagesJSON := messages.Raw
-	if messagesJSON == "" {
-		messagesJSON = "[]"
+// appendAgenticMessages appends the assistant message and its tool results to the request's
+// messages.
+func appendAgenticMessages(rawJSON []byte, assistantMsg []byte, results []agent.ToolResult, cfg agenticConfig) ([]byte, error) {
+	turn, err := agenticTurnMessages(assistantMsg, results, cfg)
+	if err != nil {
+		return nil, err
 	}
+	return appendRawMessages(rawJSON, turn)
+}
 
+// agenticTurnMessages builds the messages one iteration adds to the conversation: the
+// assistant message, if any, followed by a tool message per result.
+func agenticTurnMessages(assistantMsg []byte, results []agent.ToolResult, cfg agenticConfig) ([]string, error) {
+	turn := make([]string, 0, len(results)+1)
 	if len(assistantMsg) > 0 {
-		updated, err := sjson.SetRaw(messagesJSON, "-1", string(assistantMsg))
-		if err != nil {
-			return nil, err
-		}
-		messagesJSON = updated
+		turn = append(turn, string(assistantMsg))
 	}
-
 	for _, result := range results {
+		result.Content = truncateToolResult(result.Content, cfg.MaxToolResultBytes, cfg.KeepToolResultTail)
 		msgJSON, err := buildToolMessage(result)
 		if err != nil {
 			return nil, err
 		}
-		updated, err := sjson.SetRaw(messagesJSON, "-1", msgJSON)
+		turn = append(turn, msgJSON)
+	}
+	return turn, nil
+}
+
+// appendRawMessages appends the encoded messages to the request's messages array.
+func appendRawMessages(rawJSON []byte, messages []string) ([]byte, error) {
+	existing := gjson.GetBytes(rawJSON, "messages")
+	if !existing.Exists() || !existing.IsArray() {
+		return nil, fmt.Errorf("messages array missing")
+	}
+
+	messagesJSON := existing.Raw
+	if messagesJSON == "" {
+		messagesJSON = "[]"
+	}
+	for _, msg := range messages {
+		updated, err := sjson.SetRaw(messagesJSON, "-1", msg)
 		if err != nil {
 			return nil, err
 		}
@@ -294,11 +482,56 @@ func appendAgenticMessages(rawJSON []byte, assistantMsg []byte, results []agent.
 	return updatedRaw, nil
 }
 
+// truncateToolResult cuts content longer than maxBytes down to maxBytes, marking the cut
+// with how many bytes were dropped. keepTail splits the kept bytes between the start and
+// the end of content. Cuts never split a UTF-8 sequence; the marker is not counted.
+func truncateToolResult(content string, maxBytes int, keepTail bool) string {
+	if maxBytes <= 0 || len(content) <= maxBytes {
+		return content
+	}
+	headLen := maxBytes
+	tailLen := 0
+	if keepTail {
+		headLen = maxBytes - maxBytes/2
+		tailLen = maxBytes / 2
+	}
+	for headLen > 0 && !utf8.RuneStart(content[headLen]) {
+		headLen--
+	}
+	tailStart := len(content) - tailLen
+	for tailStart < len(content) && !utf8.RuneStart(content[tailStart]) {
+		tailStart++
+	}
+	dropped := tailStart - headLen
+	return content[:headLen] + fmt.Sprintf(toolResultTruncationMarker, dropped) + content[tailStart:]
+}
+
+// toolResultTruncationMarker replaces the bytes truncateToolResult drops.
+const toolResultTruncationMarker = "\n...[truncated %d bytes]...\n"
+
+// buildToolMessage renders a tool result as an OpenAI tool message. Calls that did not
+// succeed carry a JSON status object so the model can tell a timeout, which may be worth
+// retrying, from a failure or a user denial.
 func buildToolMessage(result agent.ToolResult) (string, error) {
+	content := result.Content
+	if result.Failed() {
+		status := map[string]any{
+			"status": string(result.Status),
+			"error":  result.Error,
+		}
+		if result.Content != "" {
+			status["output"] = result.Content
+		}
+		encoded, err := json.Marshal(status)
+		if err != nil {
+			return "", err
+		}
+		content = string(encoded)
+	}
 	msg := map[string]any{
 		"role":         "tool",
 		"tool_call_id": result.ID,
-		"content":      result.Content,
+		"content":      content,
 	}
 	encoded, err := json.Marshal(msg)
 	if err != nil {
@@ -309,6 +542,13 @@ func buildToolMessage(result agent.ToolResult) (string, error) {
 
 const httpStatusBadRequest = 400
 
+// startAgenticRequestSpan opens the server span that an agentic request's iteration and
+// tool spans hang off, continuing the caller's trace when the request carries one.
+func startAgenticRequestSpan(c *gin.Context, model string) (context.Context, observability.Span) {
+	tm := observability.NewTracingMiddleware(observability.GetTracer(), observability.DefaultTracerConfig())
+	return tm.StartIncomingRequestSpan(c.Request, model)
+}
+
 // handleAgenticStreamingResponse handles agentic loops with streaming responses.
 // It streams each model response as SSE events, then executes tools, and continues the loop.
 // Between iterations, it sends custom SSE events to notify the client of tool execution.
@@ -318,6 +558,14 @@ func (h *OpenAIAPIHandler) handleAgenticStreamingResponse(c *gin.Context, rawJSO
 	c.Header("Connection", "keep-alive")
 	c.Header("X-Accel-Buffering", "no")
 
+	// Tool execution can leave the stream idle, so heartbeats run in the background.
+	// Route every write through the keep-alive writer so they never split an event.
+	keepAlive := h.SSEKeepAliveWriter(c)
+	original := c.Writer
+	c.Writer = keepAlive
+	defer func() { c.Writer = original }()
+	defer keepAlive.Start(c.Request.Context())()
+
 	flusher, ok := c.Writer.(interface{ Flush() })
 	if !ok {
 		c.JSON(httpStatusBadRequest, handlers.ErrorResponse{
@@ -331,8 +579,28 @@ func (h *OpenAIAPIHandler) handleAgenticStreamingResponse(c *gin.Context, rawJSO
 
 	alt := h.GetAlt(c)
 	requestJSON := rawJSON
+	// Every iteration is framed as part of one chat.completion.chunk stream.
+	framer := newAgenticChunkFramer(gjson.GetBytes(rawJSON, "model").String())
+	loop := agent.NewLoop(agent.LoopConfig{
+		MaxIterations:     cfg.MaxSteps,
+		MaxTotalTokens:    cfg.MaxTotalTokens,
+		ParallelToolCalls: cfg.ParallelToolCalls,
+		MaxConcurrency:    cfg.MaxConcurrency,
+		ToolTimeout:       cfg.ToolTimeout,
+	}, agent.DefaultRegistry())
+	reqCtx, span := startAgenticRequestSpan(c, gjson.GetBytes(rawJSON, "model").String())
+	defer span.End()
+	defer loop.Finish()
+	writeSummary := func() {
+		if !cfg.IncludeSummary {
+			return
+		}
+		summaryEvent, _ := sjson.SetBytes(agenticSummaryJSON(loop), "type", "agentic.summary")
+		_, _ = c.Writer.Write([]byte("data: " + string(summaryEvent) + "\n\n"))
+	}
 
-	for step := 0; step < cfg.MaxSteps; step++ {
+	for step := 0; loop.ShouldContinue(); step++ {
+		loop.StartIteration(reqCtx)
 		modelName := gjson.GetBytes(requestJSON, "model").String()
 
 		// Set stream=true for the actual request
@@ -341,10 +609,11 @@ func (h *OpenAIAPIHandler) handleAgenticStreamingResponse(c *gin.Context, rawJSO
 		cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
 
 		// Execute streaming request and accumulate tool calls
-		resp, toolCalls, err := h.executeAgenticStreamingRequest(c, cliCtx, modelName, streamReq, alt, flusher)
+		resp, toolCalls, err := h.executeAgenticStreamingRequest(c, cliCtx, modelName, streamReq, alt, flusher, framer, cfg.MaxToolArgsBytes)
 		cliCancel(nil)
 
 		if err != nil {
+			loop.RecordError(err)
 			// Send error as SSE event
 			errJSON, _ := json.Marshal(map[string]any{
 				"error": map[string]any{
@@ -357,8 +626,11 @@ func (h *OpenAIAPIHandler) handleAgenticStreamingResponse(c *gin.Context, rawJSO
 			return
 		}
 
+		loop.RecordModelResponse(resp, toolCalls, "", usageFromChatResponse(resp))
+
 		// If no tool calls, we're done
 		if len(toolCalls) == 0 {
+			writeSummary()
 			_, _ = c.Writer.Write([]byte("data: [DONE]\n\n"))
 			flusher.Flush()
 			return
@@ -375,11 +647,7 @@ func (h *OpenAIAPIHandler) handleAgenticStreamingResponse(c *gin.Context, rawJSO
 		flusher.Flush()
 
 		// Execute tools
-		results := agent.ExecuteToolCalls(c.Request.Context(), toolCalls, agent.ExecuteOptions{
-			Parallel:       cfg.ParallelToolCalls,
-			MaxConcurrency: cfg.MaxConcurrency,
-			Timeout:        cfg.ToolTimeout,
-		}, agent.DefaultRegistry())
+		results := loop.ExecuteTools(reqCtx)
 
 		// Send tool results notification
 		toolResultEvent := map[string]any{
@@ -392,7 +660,7 @@ func (h *OpenAIAPIHandler) handleAgenticStreamingResponse(c *gin.Context, rawJSO
 		flusher.Flush()
 
 		// Append assistant message and tool results to messages
-		requestJSON, err = appendAgenticMessages(requestJSON, resp, results)
+		requestJSON, err = appendAgenticMessages(requestJSON, resp, results, cfg)
 		if err != nil {
 			errJSON, _ := json.Marshal(map[string]any{
 				"error": map[string]any{
@@ -402,17 +670,27 @@ func (h *OpenAIAPIHandler) handleAgenticStreamingResponse(c *gin.Context, rawJSO
 			})
 			_, _ = c.Writer.Write([]byte("data: " + string(errJSON) + "\n\n"))
 			flusher.Flush()
+			loop.RecordError(err)
 			return
 		}
+		requestJSON = h.compactAgenticRequest(c, loop, requestJSON, cfg, alt)
 	}
 
-	// Max steps reached
+	// Max steps reached or token budget exceeded
 	maxStepsEvent := map[string]any{
 		"type":    "agentic.max_steps_reached",
 		"message": "agentic max_steps reached",
 	}
+	if loop.State() == agent.StateTokenBudgetExceeded {
+		maxStepsEvent = map[string]any{
+			"type":        "agentic.token_budget_exceeded",
+			"message":     "agentic max_total_tokens exceeded",
+			"tokens_used": loop.TotalTokensUsed().TotalTokens,
+		}
+	}
 	maxStepsJSON, _ := json.Marshal(maxStepsEvent)
 	_, _ = c.Writer.Write([]byte("data: " + string(maxStepsJSON) + "\n\n"))
+	writeSummary()
 	_, _ = c.Writer.Write([]byte("data: [DONE]\n\n"))
 	flusher.Flush()
 }
@@ -425,12 +703,31 @@ func (h *OpenAIAPIHandler) executeAgenticStreamingRequest(
 	requestJSON []byte,
 	alt string,
 	flusher interface{ Flush() },
+	framer *agenticChunkFramer,
+	maxToolArgsBytes int,
 ) ([]byte, []agent.ToolCall, error) {
 	// Execute the streaming request
 	respChan, errChan := h.ExecuteStreamingWithAuthManager(ctx, h.HandlerType(), modelName, requestJSON, alt)
+	return consumeAgenticStream(c, ctx, respChan, errChan, flusher, framer, maxToolArgsBytes)
+}
 
+// consumeAgenticStream forwards upstream SSE frames to the client while accumulating the
+// assistant message. Data frames are rewritten by framer so every iteration continues the
+// same completion, and the upstream [DONE] is held back for the handler to send once the
+// loop ends. Tool calls are finalized whenever the stream ends, whether or not a
+// finish_reason of "tool_calls" was seen, so calls from streams cut short are not lost.
+// Each call's arguments are capped at maxToolArgsBytes; see streamedToolCalls.
+func consumeAgenticStream(
+	c *gin.Context,
+	ctx context.Context,
+	respChan <-chan []byte,
+	errChan <-chan error,
+	flusher interface{ Flush() },
+	framer *agenticChunkFramer,
+	maxToolArgsBytes int,
+) ([]byte, []agent.ToolCall, error) {
 	var assistantMsgBuilder strings.Builder
-	var toolCalls []agent.ToolCall
+	toolCalls := newStreamedToolCalls(maxToolArgsBytes)
 	var lastChunk []byte
 
 	assistantMsgBuilder.WriteString(`{"role":"assistant","content":"","tool_calls":[]}`)
@@ -440,86 +737,55 @@ func (h *OpenAIAPIHandler) executeAgenticStreamingRequest(
 		case chunk, ok := <-respChan:
 			if !ok {
 				// Channel closed, check for tool calls
-				if len(toolCalls) > 0 {
-					// Build assistant message with tool calls
-					assistantMsg := assistantMsgBuilder.String()
-					for i, tc := range toolCalls {
-						toolCallJSON := fmt.Sprintf(`{"id":"%s","type":"function","function":{"name":"%s","arguments":%s}}`,
-							tc.ID, tc.Name, tc.RawPayload)
-						assistantMsg, _ = sjson.SetRaw(assistantMsg, fmt.Sprintf("tool_calls.%d", i), toolCallJSON)
-					}
-					return []byte(assistantMsg), toolCalls, nil
+				if calls := toolCalls.finalize(); len(calls) > 0 {
+					return []byte(toolCalls.assistantMessage(assistantMsgBuilder.String(), calls)), calls, nil
 				}
 				return lastChunk, nil, nil
 			}
 
-			// Forward chunk to client
-			_, _ = c.Writer.Write(chunk)
-			_, _ = c.Writer.Write([]byte("\n"))
-			flusher.Flush()
+			if len(chunk) <= 6 || string(chunk[:6]) != "data: " {
+				// Forward non-data lines untouched
+				frame := make([]byte, 0, len(chunk)+1)
+				frame = append(append(frame, chunk...), '\n')
+				_, _ = c.Writer.Write(frame)
+				flusher.Flush()
+				continue
+			}
 
 			// Parse the SSE data
-			if len(chunk) > 6 && string(chunk[:6]) == "data: " {
-				data := chunk[6:]
-				if string(data) == "[DONE]" {
-					continue
-				}
+			data := bytes.TrimSpace(chunk[6:])
+			if string(data) == "[DONE]" {
+				continue
+			}
+			// Extract content delta
+			contentDelta := gjson.GetBytes(data, "choices.0.delta.content")
+			if contentDelta.Exists() {
+				// Append to content
+				currentContent := gjson.Get(assistantMsgBuilder.String(), "content").String()
+				newContent := currentContent + contentDelta.String()
+				newMsg, _ := sjson.Set(assistantMsgBuilder.String(), "content", newContent)
+				assistantMsgBuilder.Reset()
+				assistantMsgBuilder.WriteString(newMsg)
+			}
 
-				lastChunk = data
-
-				// Extract content delta
-				contentDelta := gjson.GetBytes(data, "choices.0.delta.content")
-				if contentDelta.Exists() {
-					// Append to content
-					currentContent := gjson.Get(assistantMsgBuilder.String(), "content").String()
-					newContent := currentContent + contentDelta.String()
-					newMsg, _ := sjson.Set(assistantMsgBuilder.String(), "content", newContent)
-					assistantMsgBuilder.Reset()
-					assistantMsgBuilder.WriteString(newMsg)
-				}
+			// Extract tool calls
+			tcDelta := gjson.GetBytes(data, "choices.0.delta.tool_calls")
+			if tcDelta.Exists() && tcDelta.IsArray() {
+				toolCalls.apply(tcDelta)
+			}
 
-				// Extract tool calls
-				tcDelta := gjson.GetBytes(data, "choices.0.delta.tool_calls")
-				if tcDelta.Exists() && tcDelta.IsArray() {
-					for _, tc := range tcDelta.Array() {
-						idx := int(tc.Get("index").Int())
-
-						// Ensure we have enough slots
-						for len(toolCalls) <= idx {
-							toolCalls = append(toolCalls, agent.ToolCall{})
-						}
-
-						// Update ID if present
-						if id := tc.Get("id"); id.Exists() && id.String() != "" {
-							toolCalls[idx].ID = id.String()
-						}
-
-						// Update function name if present
-						if name := tc.Get("function.name"); name.Exists() && name.String() != "" {
-							toolCalls[idx].Name = name.String()
-						}
-
-						// Append to arguments
-						if args := tc.Get("function.arguments"); args.Exists() {
-							toolCalls[idx].RawPayload += args.String()
-						}
-					}
-				}
+			// Tool calls are read above, before the framer strips them for the client.
+			data = framer.frame(data)
+			_, _ = c.Writer.Write([]byte("data: " + string(data) + "\n\n"))
+			flusher.Flush()
+			lastChunk = data
 
-				// Check finish reason
-				finishReason := gjson.GetBytes(data, "choices.0.finish_reason")
-				if finishReason.Exists() && finishReason.String() == "tool_calls" {
-					// Finalize tool calls
-					for i := range toolCalls {
-						if toolCalls[i].ID == "" {
-							toolCalls[i].ID = fmt.Sprintf("call_%d", i+1)
-						}
-						toolCalls[i].Arguments = normalizeArguments(toolCalls[i].RawPayload)
-					}
-				}
+		case err, ok := <-errChan:
+			if !ok || err == nil {
+				// A closed error channel only means no error; keep draining respChan.
+				errChan = nil
+				continue
 			}
-
-		case err := <-errChan:
 			return nil, nil, err
 
 		case <-ctx.Done():
@@ -528,6 +794,175 @@ func (h *OpenAIAPIHandler) executeAgenticStreamingRequest(
 	}
 }
 
+// agenticChunkFramer rewrites the chunks of every agentic iteration so clients see a single
+// chat.completion.chunk stream: id, created and model are taken from the first upstream
+// chunk (or generated) and reused for the rest of the loop, and the assistant role is
+// announced once, on the first delta. Tool calls are executed by the proxy, so tool_call
+// deltas and a finish_reason of "tool_calls" are stripped: the client only sees the stream
+// end once the final iteration answers.
+type agenticChunkFramer struct {
+	id       string
+	created  int64
+	model    string
+	roleSent bool
+}
+
+func newAgenticChunkFramer(model string) *agenticChunkFramer {
+	return &agenticChunkFramer{model: model}
+}
+
+// frame returns data with the stream's identity applied. Payloads without choices, such as
+// error objects, are returned unchanged.
+func (f *agenticChunkFramer) frame(data []byte) []byte {
+	choices := gjson.GetBytes(data, "choices")
+	if !choices.IsArray() {
+		return data
+	}
+	if f.id == "" {
+		f.id = gjson.GetBytes(data, "id").String()
+		if f.id == "" {
+			f.id = fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano())
+		}
+		f.created = gjson.GetBytes(data, "created").Int()
+		if f.created == 0 {
+			f.created = time.Now().Unix()
+		}
+		if upstreamModel := gjson.GetBytes(data, "model").String(); upstreamModel != "" {
+			f.model = upstreamModel
+		}
+	}
+
+	out, _ := sjson.SetBytes(data, "id", f.id)
+	out, _ = sjson.SetBytes(out, "object", "chat.completion.chunk")
+	out, _ = sjson.SetBytes(out, "created", f.created)
+	if f.model != "" {
+		out, _ = sjson.SetBytes(out, "model", f.model)
+	}
+	for i, choice := range choices.Array() {
+		out, _ = sjson.DeleteBytes(out, fmt.Sprintf("choices.%d.delta.tool_calls", i))
+		if choice.Get("finish_reason").String() == "tool_calls" {
+			out, _ = sjson.SetRawBytes(out, fmt.Sprintf("choices.%d.finish_reason", i), []byte("null"))
+		}
+		rolePath := fmt.Sprintf("choices.%d.delta.role", i)
+		if f.roleSent {
+			out, _ = sjson.DeleteBytes(out, rolePath)
+			continue
+		}
+		if i == 0 {
+			out, _ = sjson.SetBytes(out, rolePath, "assistant")
+		}
+	}
+	if len(choices.Array()) > 0 {
+		f.roleSent = true
+	}
+	return out
+}
+
+// completionAsChunk converts a non-streaming chat.completion into the equivalent single
+// chat.completion.chunk, moving each message into its delta and indexing its tool calls.
+func completionAsChunk(resp []byte) []byte {
+	out, _ := sjson.SetBytes(resp, "object", "chat.completion.chunk")
+	gjson.GetBytes(resp, "choices").ForEach(func(key, choice gjson.Result) bool {
+		prefix := "choices." + key.String()
+		message := choice.Get("message")
+		if !message.Exists() {
+			return true
+		}
+		out, _ = sjson.DeleteBytes(out, prefix+".message")
+		out, _ = sjson.SetRawBytes(out, prefix+".delta", []byte(message.Raw))
+		message.Get("tool_calls").ForEach(func(idx, _ gjson.Result) bool {
+			out, _ = sjson.SetBytes(out, fmt.Sprintf("%s.delta.tool_calls.%d.index", prefix, idx.Int()), idx.Int())
+			return true
+		})
+		return true
+	})
+	return out
+}
+
+// streamedToolCalls accumulates OpenAI tool_call deltas keyed by their upstream index.
+// Upstreams may stream indices out of order or sparsely, so output positions are assigned
+// densely in index order and indices that never received an id, name or arguments are
+// dropped instead of serializing as empty calls. A call whose arguments grow past
+// maxArgsBytes is marked malformed and its arguments discarded, so a runaway upstream
+// cannot grow the accumulator without bound.
+type streamedToolCalls struct {
+	byIndex      map[int]*agent.ToolCall
+	maxArgsBytes int
+}
+
+// newStreamedToolCalls returns an accumulator capping each call's arguments at
+// maxArgsBytes; 0 uses tools.DefaultMaxToolArgsBytes.
+func newStreamedToolCalls(maxArgsBytes int) *streamedToolCalls {
+	if maxArgsBytes <= 0 {
+		maxArgsBytes = tools.DefaultMaxToolArgsBytes
+	}
+	return &streamedToolCalls{byIndex: make(map[int]*agent.ToolCall), maxArgsBytes: maxArgsBytes}
+}
+
+// apply merges one delta.tool_calls array into the accumulated calls.
+func (s *streamedToolCalls) apply(deltas gjson.Result) {
+	deltas.ForEach(func(_, tc gjson.Result) bool {
+		idx := int(tc.Get("index").Int())
+		call, ok := s.byIndex[idx]
+		if !ok {
+			call = &agent.ToolCall{}
+			s.byIndex[idx] = call
+		}
+		if id := tc.Get("id").String(); id != "" {
+			call.ID = id
+		}
+		if name := tc.Get("function.name").String(); name != "" {
+			call.Name = name
+		}
+		if args := tc.Get("function.arguments"); args.Exists() && !call.Malformed {
+			if len(call.RawPayload)+len(args.String()) > s.maxArgsBytes {
+				call.Malformed = true
+				call.RawPayload = ""
+			} else {
+				call.RawPayload += args.String()
+			}
+		}
+		return true
+	})
+}
+
+// finalize returns the populated calls in upstream index order with missing ids filled
+// from their dense output position and arguments normalized.
+func (s *streamedToolCalls) finalize() []agent.ToolCall {
+	indices := make([]int, 0, len(s.byIndex))
+	for idx, call := range s.byIndex {
+		if call.ID == "" && call.Name == "" && call.RawPayload == "" && !call.Malformed {
+			continue
+		}
+		indices = append(indices, idx)
+	}
+	sort.Ints(indices)
+
+	calls := make([]agent.ToolCall, 0, len(indices))
+	for _, idx := range indices {
+		call := *s.byIndex[idx]
+		if call.ID == "" {
+			call.ID = fmt.Sprintf("call_%d", len(calls)+1)
+		}
+		call.Arguments = normalizeArguments(call.RawPayload)
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+// assistantMessage sets calls on the assistant message at their dense output positions.
+func (s *streamedToolCalls) assistantMessage(base string, calls []agent.ToolCall) string {
+	msg := base
+	for i, tc := range calls {
+		toolCall := `{"id":"","type":"function","function":{"name":"","arguments":""}}`
+		toolCall, _ = sjson.Set(toolCall, "id", tc.ID)
+		toolCall, _ = sjson.Set(toolCall, "function.name", tc.Name)
+		toolCall, _ = sjson.Set(toolCall, "function.arguments", tc.RawPayload)
+		msg, _ = sjson.SetRaw(msg, fmt.Sprintf("tool_calls.%d", i), toolCall)
+	}
+	return msg
+}
+
 // ExecuteStreamingWithAuthManager is a placeholder interface method.
 // The actual implementation should be in the handler's base.
 func (h *OpenAIAPIHandler) ExecuteStreamingWithAuthManager(ctx context.Context, handlerType, modelName string, requestJSON []byte, alt string) (<-chan []byte, <-chan error) {
@@ -547,7 +982,7 @@ func (h *OpenAIAPIHandler) ExecuteStreamingWithAuthManager(ctx context.Context,
 		}
 
 		// Convert non-streaming response to SSE format
-		sseData := "data: " + string(resp) + "\n\n"
+		sseData := "data: " + string(completionAsChunk(resp)) + "\n\n"
 		respChan <- []byte(sseData)
 		respChan <- []byte("data: [DONE]\n\n")
 	}()
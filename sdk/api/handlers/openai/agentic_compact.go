@@ -0,0 +1,73 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	contextmgr "github.com/router-for-me/CLIProxyAPI/v6/internal/context"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/agent"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// defaultAgenticCompactKeepRecent is how many recent messages survive compaction unless
+// configured otherwise.
+const defaultAgenticCompactKeepRecent = 4
+
+// agenticSummaryPrompt instructs the model that summarizes older agentic history.
+const agenticSummaryPrompt = "You compact the history of an agent that calls tools. The user message is a JSON array of chat messages. " +
+	"Summarize it so the agent can continue its task: keep facts learned from tool results, decisions made and open questions, " +
+	"and drop anything redundant. Reply with the summary only."
+
+// agenticSummarizer returns the Summarizer used to compact agentic history. Replaced in tests.
+var agenticSummarizer = func(h *OpenAIAPIHandler, loop *agent.Loop, alt string) contextmgr.Summarizer {
+	return contextmgr.SummarizerFunc(func(ctx context.Context, model string, messages []byte) (string, error) {
+		return h.summarizeWithModel(ctx, loop, model, alt, messages)
+	})
+}
+
+// summarizeWithModel asks model itself to summarize messages, charging the tokens it used to
+// loop's budget.
+func (h *OpenAIAPIHandler) summarizeWithModel(ctx context.Context, loop *agent.Loop, model, alt string, messages []byte) (string, error) {
+	req := []byte(`{"messages":[]}`)
+	req, _ = sjson.SetBytes(req, "model", model)
+	req, _ = sjson.SetBytes(req, "messages.-1", map[string]string{"role": "system", "content": agenticSummaryPrompt})
+	req, _ = sjson.SetBytes(req, "messages.-1", map[string]string{"role": "user", "content": string(messages)})
+
+	resp, errMsg := h.ExecuteWithAuthManager(ctx, h.HandlerType(), model, req, alt)
+	if errMsg != nil {
+		return "", fmt.Errorf("summarize agentic history: %w", errMsg.Error)
+	}
+	loop.RecordUsage(usageFromChatResponse(resp))
+	return gjson.GetBytes(resp, "choices.0.message.content").String(), nil
+}
+
+// compactAgenticRequest summarizes older messages once requestJSON grows past the configured
+// threshold, keeping the task and the most recent messages verbatim. On failure the request
+// is returned unchanged so the loop can continue.
+func (h *OpenAIAPIHandler) compactAgenticRequest(c *gin.Context, loop *agent.Loop, requestJSON []byte, cfg agenticConfig, alt string) []byte {
+	if cfg.CompactThresholdBytes <= 0 || len(requestJSON) <= cfg.CompactThresholdBytes {
+		return requestJSON
+	}
+	messages := gjson.GetBytes(requestJSON, "messages")
+	if !messages.IsArray() {
+		return requestJSON
+	}
+
+	model := gjson.GetBytes(requestJSON, "model").String()
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	compacted, err := contextmgr.SummarizeMessages(cliCtx, []byte(messages.Raw), model, cfg.CompactKeepRecent, agenticSummarizer(h, loop, alt))
+	cliCancel(err)
+	if err != nil {
+		log.Warnf("agentic: history compaction skipped: %v", err)
+		return requestJSON
+	}
+	out, err := sjson.SetRawBytes(requestJSON, "messages", compacted)
+	if err != nil {
+		return requestJSON
+	}
+	log.Debugf("agentic: compacted request from %d to %d bytes", len(requestJSON), len(out))
+	return out
+}
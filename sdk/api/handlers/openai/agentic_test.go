@@ -0,0 +1,489 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	contextmgr "github.com/router-for-me/CLIProxyAPI/v6/internal/context"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/agent"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+// toolLoopExecutor always answers with a tool call so the agentic loop never completes on its own.
+type toolLoopExecutor struct{}
+
+func (toolLoopExecutor) Identifier() string { return "codex" }
+
+func (toolLoopExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{Payload: []byte(`{"id":"chatcmpl-1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"checking","tool_calls":[{"id":"call_1","type":"function","function":{"name":"lookup","arguments":"{}"}}]},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`)}, nil
+}
+
+func (toolLoopExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (toolLoopExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (toolLoopExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (toolLoopExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func runAgenticAtCap(t *testing.T, cfg *sdkconfig.SDKConfig, agentic string) *httptest.ResponseRecorder {
+	t.Helper()
+	return runAgentic(t, cfg, `{"model":"agentic-model","messages":[{"role":"user","content":"hi"}],"agentic":`+agentic+`}`)
+}
+
+func runAgentic(t *testing.T, cfg *sdkconfig.SDKConfig, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	return runAgenticWith(t, cfg, body, toolLoopExecutor{})
+}
+
+func runAgenticWith(t *testing.T, cfg *sdkconfig.SDKConfig, body string, executor coreauth.ProviderExecutor) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "agentic-cap-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "agentic-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	h := NewOpenAIAPIHandler(handlers.NewBaseAPIHandlers(cfg, manager))
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	h.ChatCompletions(c)
+	return rec
+}
+
+func TestAgenticMaxSteps_DefaultReturnsError(t *testing.T) {
+	rec := runAgenticAtCap(t, &sdkconfig.SDKConfig{}, `{"max_steps":2}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d; body %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+	if !strings.Contains(gjson.Get(rec.Body.String(), "error.message").String(), "max_steps (2) reached") {
+		t.Fatalf("unexpected error body: %s", rec.Body.String())
+	}
+}
+
+func TestAgenticMaxSteps_PartialReturnsLastMessage(t *testing.T) {
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Agent.MaxStepsBehavior = "partial"
+	rec := runAgenticAtCap(t, cfg, `{"max_steps":2}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	body := gjson.Parse(rec.Body.String())
+	if got := body.Get("choices.0.finish_reason").String(); got != "length" {
+		t.Errorf("finish_reason = %q, want length", got)
+	}
+	if got := body.Get("choices.0.message.content").String(); got != "checking" {
+		t.Errorf("content = %q, want last assistant message", got)
+	}
+	if !body.Get("agentic.max_steps_reached").Bool() {
+		t.Errorf("agentic.max_steps_reached missing: %s", rec.Body.String())
+	}
+	if got := body.Get("agentic.iterations").Int(); got != 2 {
+		t.Errorf("agentic.iterations = %d, want 2", got)
+	}
+	// Each iteration appends an assistant message and one tool result.
+	transcript := body.Get("agentic.transcript").Array()
+	if len(transcript) != 4 {
+		t.Fatalf("transcript length = %d, want 4: %s", len(transcript), body.Get("agentic.transcript").Raw)
+	}
+	if transcript[0].Get("role").String() != "assistant" || transcript[1].Get("role").String() != "tool" {
+		t.Errorf("unexpected transcript roles: %s", body.Get("agentic.transcript").Raw)
+	}
+}
+
+func TestAgenticMaxSteps_RequestOverridesConfig(t *testing.T) {
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Agent.MaxStepsBehavior = "partial"
+	rec := runAgenticAtCap(t, cfg, `{"max_steps":1,"on_max_steps":"error"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAgenticTokenBudget_StopsBeforeMaxSteps(t *testing.T) {
+	// Each iteration reports 15 tokens, so a budget of 40 is spent after three.
+	rec := runAgenticAtCap(t, &sdkconfig.SDKConfig{}, `{"max_steps":8,"max_total_tokens":40}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d; body %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+	want := "max_total_tokens (40) exceeded after 3 iterations using 45 tokens"
+	if got := gjson.Get(rec.Body.String(), "error.message").String(); !strings.Contains(got, want) {
+		t.Fatalf("error message = %q, want it to contain %q", got, want)
+	}
+}
+
+func TestAgenticTokenBudget_PartialFlagsBudget(t *testing.T) {
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Agent.MaxStepsBehavior = "partial"
+	cfg.Agent.MaxTotalTokens = 20
+	rec := runAgenticAtCap(t, cfg, `{"max_steps":8}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	body := gjson.Parse(rec.Body.String())
+	if !body.Get("agentic.token_budget_exceeded").Bool() || body.Get("agentic.max_steps_reached").Exists() {
+		t.Errorf("agentic stop reason not token budget: %s", body.Get("agentic").Raw)
+	}
+	if got := body.Get("agentic.iterations").Int(); got != 2 {
+		t.Errorf("agentic.iterations = %d, want 2", got)
+	}
+}
+
+func TestAgenticTokenBudget_ServerCapsRequestBudget(t *testing.T) {
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Agent.MaxTotalTokens = 40
+	rec := runAgenticAtCap(t, cfg, `{"max_steps":8,"max_total_tokens":1000000}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d; body %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+	want := "max_total_tokens (40) exceeded after 3 iterations"
+	if got := gjson.Get(rec.Body.String(), "error.message").String(); !strings.Contains(got, want) {
+		t.Fatalf("error message = %q, want it to contain %q", got, want)
+	}
+}
+
+func TestAgenticSummary_NonStreamingMatchesLoop(t *testing.T) {
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Agent.MaxStepsBehavior = "partial"
+	rec := runAgenticAtCap(t, cfg, `{"max_steps":3,"include_summary":true}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	agentic := gjson.Get(rec.Body.String(), "agentic")
+	if got := agentic.Get("state").String(); got != "max_iterations" {
+		t.Errorf("state = %q, want max_iterations", got)
+	}
+	if got := agentic.Get("total_iterations").Int(); got != 3 {
+		t.Errorf("total_iterations = %d, want 3", got)
+	}
+	if got := agentic.Get("total_tool_calls").Int(); got != 3 {
+		t.Errorf("total_tool_calls = %d, want 3", got)
+	}
+	if got := agentic.Get("tokens_used.total_tokens").Int(); got != 45 {
+		t.Errorf("tokens_used.total_tokens = %d, want 45", got)
+	}
+	if agentic.Get("total_duration").String() == "" {
+		t.Errorf("total_duration missing: %s", agentic.Raw)
+	}
+	if agentic.Get("iterations").IsArray() {
+		t.Errorf("summary should omit per-iteration detail: %s", agentic.Raw)
+	}
+	if !agentic.Get("max_steps_reached").Bool() {
+		t.Errorf("summary must not drop partial-response fields: %s", agentic.Raw)
+	}
+}
+
+func TestAgenticSummary_OmittedByDefault(t *testing.T) {
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Agent.MaxStepsBehavior = "partial"
+	rec := runAgenticAtCap(t, cfg, `{"max_steps":1}`)
+	if gjson.Get(rec.Body.String(), "agentic.total_iterations").Exists() {
+		t.Fatalf("summary emitted without being requested: %s", rec.Body.String())
+	}
+}
+
+func TestAgenticSummary_StreamingEmitsSummaryEvent(t *testing.T) {
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Agent.IncludeSummary = true
+	rec := runAgentic(t, cfg, `{"model":"agentic-model","stream":true,"messages":[{"role":"user","content":"hi"}],"agentic":{"max_steps":2}}`)
+
+	var summary gjson.Result
+	var doneAfterSummary bool
+	var toolSteps, toolCalls int64
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		payload, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		if payload == "[DONE]" {
+			doneAfterSummary = summary.Exists()
+			continue
+		}
+		event := gjson.Parse(payload)
+		switch event.Get("type").String() {
+		case "agentic.summary":
+			summary = event
+		case "agentic.tool_execution_start":
+			toolSteps++
+			toolCalls += event.Get("tools").Int()
+		}
+	}
+	if !summary.Exists() {
+		t.Fatalf("agentic.summary event missing: %s", rec.Body.String())
+	}
+	if !doneAfterSummary {
+		t.Errorf("[DONE] must follow the summary event: %s", rec.Body.String())
+	}
+	// Every step that executed tools is an iteration; a final step either answers or hits the cap.
+	if got := summary.Get("total_iterations").Int(); got < toolSteps || got > toolSteps+1 || got > 2 {
+		t.Errorf("total_iterations = %d, inconsistent with %d tool steps", got, toolSteps)
+	}
+	if got := summary.Get("total_tool_calls").Int(); got != toolCalls {
+		t.Errorf("total_tool_calls = %d, want %d", got, toolCalls)
+	}
+	if summary.Get("total_duration").String() == "" {
+		t.Errorf("total_duration missing: %s", summary.Raw)
+	}
+}
+
+func TestAgenticStreaming_SingleCoherentCompletion(t *testing.T) {
+	rec := runAgentic(t, &sdkconfig.SDKConfig{}, `{"model":"agentic-model","stream":true,"messages":[{"role":"user","content":"hi"}],"agentic":{"max_steps":2,"on_max_steps":"partial"}}`)
+
+	var chunks []gjson.Result
+	var done int
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		payload, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		if payload == "[DONE]" {
+			done++
+			continue
+		}
+		if done > 0 {
+			t.Fatalf("data after [DONE]: %s", payload)
+		}
+		if event := gjson.Parse(payload); event.Get("choices").Exists() {
+			chunks = append(chunks, event)
+		}
+	}
+	if done != 1 {
+		t.Fatalf("got %d [DONE] markers, want exactly 1: %s", done, rec.Body.String())
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("expected a chunk per iteration, got %d: %s", len(chunks), rec.Body.String())
+	}
+
+	first := chunks[0]
+	if first.Get("id").String() == "" || first.Get("choices.0.delta.role").String() != "assistant" {
+		t.Fatalf("first chunk must carry an id and the assistant role: %s", first.Raw)
+	}
+	for i, chunk := range chunks {
+		if chunk.Get("object").String() != "chat.completion.chunk" {
+			t.Errorf("chunk %d object = %q", i, chunk.Get("object").String())
+		}
+		if chunk.Get("choices.0.message").Exists() || !chunk.Get("choices.0.delta").Exists() {
+			t.Errorf("chunk %d must carry a delta, not a message: %s", i, chunk.Raw)
+		}
+		for _, field := range []string{"id", "created", "model"} {
+			if chunk.Get(field).Raw != first.Get(field).Raw {
+				t.Errorf("chunk %d %s = %s, want %s", i, field, chunk.Get(field).Raw, first.Get(field).Raw)
+			}
+		}
+		if i > 0 && chunk.Get("choices.0.delta.role").Exists() {
+			t.Errorf("chunk %d repeats the role: %s", i, chunk.Raw)
+		}
+		// The proxy runs the tools itself; the client must not be asked to.
+		if chunk.Get("choices.0.delta.tool_calls").Exists() || chunk.Get("choices.0.finish_reason").String() == "tool_calls" {
+			t.Errorf("chunk %d exposes an internal tool call: %s", i, chunk.Raw)
+		}
+	}
+}
+
+// growingHistoryExecutor calls a tool on every turn until finishAfter turns, recording the
+// size of each request it receives.
+type growingHistoryExecutor struct {
+	toolLoopExecutor
+	finishAfter int
+	sizes       *[]int
+}
+
+func (e growingHistoryExecutor) Execute(ctx context.Context, auth *coreauth.Auth, req coreexecutor.Request, opts coreexecutor.Options) (coreexecutor.Response, error) {
+	*e.sizes = append(*e.sizes, len(req.Payload))
+	if len(*e.sizes) >= e.finishAfter {
+		return coreexecutor.Response{Payload: []byte(`{"id":"chatcmpl-1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"done"},"finish_reason":"stop"}]}`)}, nil
+	}
+	return e.toolLoopExecutor.Execute(ctx, auth, req, opts)
+}
+
+func TestAgenticCompaction_BoundsRequestSize(t *testing.T) {
+	summaries := 0
+	previous := agenticSummarizer
+	agenticSummarizer = func(*OpenAIAPIHandler, *agent.Loop, string) contextmgr.Summarizer {
+		return contextmgr.SummarizerFunc(func(_ context.Context, _ string, messages []byte) (string, error) {
+			summaries++
+			if !gjson.ParseBytes(messages).IsArray() {
+				t.Errorf("summarizer got %s, want a messages array", messages)
+			}
+			return "looked things up", nil
+		})
+	}
+	t.Cleanup(func() { agenticSummarizer = previous })
+
+	const threshold = 1500
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Agent.CompactThresholdBytes = threshold
+	var sizes []int
+	rec := runAgenticWith(t, cfg,
+		`{"model":"agentic-model","messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}],"agentic":{"max_steps":40}}`,
+		growingHistoryExecutor{finishAfter: 30, sizes: &sizes})
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if got := gjson.Get(rec.Body.String(), "choices.0.message.content").String(); got != "done" {
+		t.Fatalf("content = %q, want the final answer", got)
+	}
+	if len(sizes) != 30 {
+		t.Fatalf("model calls = %d, want 30", len(sizes))
+	}
+	if summaries == 0 {
+		t.Fatal("expected the history to be summarized")
+	}
+	// A compacted request may grow by one iteration's messages before it is compacted again.
+	for i, size := range sizes {
+		if size > 2*threshold {
+			t.Fatalf("request %d is %d bytes, want at most %d", i+1, size, 2*threshold)
+		}
+	}
+}
+
+func TestAgenticCompaction_PartialTranscriptKeepsLoopMessages(t *testing.T) {
+	previous := agenticSummarizer
+	agenticSummarizer = func(*OpenAIAPIHandler, *agent.Loop, string) contextmgr.Summarizer {
+		return contextmgr.SummarizerFunc(func(context.Context, string, []byte) (string, error) {
+			return "looked things up", nil
+		})
+	}
+	t.Cleanup(func() { agenticSummarizer = previous })
+
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Agent.CompactThresholdBytes = 1
+	cfg.Agent.MaxStepsBehavior = "partial"
+	// The client sends more messages than survive compaction.
+	rec := runAgentic(t, cfg, `{"model":"agentic-model","messages":[`+
+		`{"role":"user","content":"one"},{"role":"assistant","content":"two"},{"role":"user","content":"three"},`+
+		`{"role":"assistant","content":"four"},{"role":"user","content":"five"},{"role":"assistant","content":"six"},`+
+		`{"role":"user","content":"seven"}],"agentic":{"max_steps":4}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	transcript := gjson.Get(rec.Body.String(), "agentic.transcript").Array()
+	if len(transcript) != 8 {
+		t.Fatalf("transcript length = %d, want 8: %s", len(transcript), gjson.Get(rec.Body.String(), "agentic.transcript").Raw)
+	}
+	for i, msg := range transcript {
+		want := "assistant"
+		if i%2 == 1 {
+			want = "tool"
+		}
+		if got := msg.Get("role").String(); got != want {
+			t.Errorf("transcript[%d] role = %q, want %q: %s", i, got, want, msg.Raw)
+		}
+	}
+}
+
+// summarizingExecutor answers summary requests with a fixed summary costing 100 tokens and
+// every other request with a tool call, recording whether summary calls carried the gin
+// context.
+type summarizingExecutor struct {
+	toolLoopExecutor
+	summaries *int
+	withGin   *bool
+}
+
+func (e summarizingExecutor) Execute(ctx context.Context, auth *coreauth.Auth, req coreexecutor.Request, opts coreexecutor.Options) (coreexecutor.Response, error) {
+	if gjson.GetBytes(req.Payload, "messages.0.content").String() != agenticSummaryPrompt {
+		return e.toolLoopExecutor.Execute(ctx, auth, req, opts)
+	}
+	*e.summaries++
+	_, ok := ctx.Value("gin").(*gin.Context)
+	*e.withGin = ok
+	return coreexecutor.Response{Payload: []byte(`{"id":"chatcmpl-s","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"looked things up"},"finish_reason":"stop"}],"usage":{"prompt_tokens":90,"completion_tokens":10,"total_tokens":100}}`)}, nil
+}
+
+func TestAgenticCompaction_SummaryTokensCountAgainstBudget(t *testing.T) {
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Agent.CompactThresholdBytes = 1
+	var summaries int
+	var withGin bool
+	// Iterations report 15 tokens each; the first summary runs in the third iteration, so the
+	// budget is only spent after three iterations when the summary's 100 tokens are charged.
+	rec := runAgenticWith(t, cfg,
+		`{"model":"agentic-model","messages":[{"role":"user","content":"hi"}],"agentic":{"max_steps":8,"max_total_tokens":100}}`,
+		summarizingExecutor{summaries: &summaries, withGin: &withGin})
+
+	if summaries != 1 {
+		t.Fatalf("summaries = %d, want 1", summaries)
+	}
+	want := "max_total_tokens (100) exceeded after 3 iterations using 145 tokens"
+	if got := gjson.Get(rec.Body.String(), "error.message").String(); !strings.Contains(got, want) {
+		t.Fatalf("error message = %q, want it to contain %q", got, want)
+	}
+	if !withGin {
+		t.Error("summary call ran without the request's gin context")
+	}
+}
+
+func TestAgenticStreaming_TracesIterationsUnderRequestSpan(t *testing.T) {
+	tracer := observability.NewInMemoryTracer(100)
+	previous := observability.GetTracer()
+	observability.SetTracer(tracer)
+	t.Cleanup(func() { observability.SetTracer(previous) })
+
+	runAgentic(t, &sdkconfig.SDKConfig{}, `{"model":"agentic-model","stream":true,"messages":[{"role":"user","content":"hi"}],"agentic":{"max_steps":2}}`)
+
+	var request *observability.InMemorySpan
+	var iterations, tools []*observability.InMemorySpan
+	for _, span := range tracer.Spans() {
+		if !span.Ended() {
+			t.Errorf("%s span left open", span.Name())
+		}
+		switch span.Name() {
+		case "http.request":
+			request = span
+		case "agent.iteration":
+			iterations = append(iterations, span)
+		case "agent.tool":
+			tools = append(tools, span)
+		}
+	}
+	if request == nil {
+		t.Fatal("no request span recorded")
+	}
+	if len(iterations) != 2 || len(tools) == 0 {
+		t.Fatalf("got %d iteration and %d tool spans, want 2 iterations with tool spans", len(iterations), len(tools))
+	}
+	iterationIDs := map[string]bool{}
+	for i, span := range iterations {
+		if span.ParentSpanID() != request.SpanContext().SpanID {
+			t.Errorf("iteration %d parent = %q, want the request span", i+1, span.ParentSpanID())
+		}
+		iterationIDs[span.SpanContext().SpanID] = true
+	}
+	for _, span := range tools {
+		if !iterationIDs[span.ParentSpanID()] {
+			t.Errorf("tool span parent = %q, want an iteration span", span.ParentSpanID())
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/agent"
+	"github.com/tidwall/gjson"
+)
+
+func TestStreamedToolCalls_SparseOutOfOrderIndices(t *testing.T) {
+	calls := newStreamedToolCalls(0)
+	deltas := []string{
+		`[{"index":3,"id":"call_c","function":{"name":"third","arguments":""}}]`,
+		`[{"index":1,"id":"call_a","function":{"name":"first","arguments":"{\"q\":"}}]`,
+		`[{"index":5}]`,
+		`[{"index":3,"function":{"arguments":"{}"}},{"index":1,"function":{"arguments":"\"x\"}"}}]`,
+		`[{"index":5,"function":{"arguments":""}}]`,
+	}
+	for _, d := range deltas {
+		calls.apply(gjson.Parse(d))
+	}
+
+	got := calls.finalize()
+	if len(got) != 2 {
+		t.Fatalf("got %d tool calls, want 2: %+v", len(got), got)
+	}
+	if got[0].ID != "call_a" || got[0].Name != "first" || string(got[0].Arguments) != `{"q":"x"}` {
+		t.Errorf("call 0 = %+v", got[0])
+	}
+	if got[1].ID != "call_c" || got[1].Name != "third" || string(got[1].Arguments) != `{}` {
+		t.Errorf("call 1 = %+v", got[1])
+	}
+
+	msg := gjson.Parse(calls.assistantMessage(`{"role":"assistant","content":"","tool_calls":[]}`, got))
+	toolCalls := msg.Get("tool_calls").Array()
+	if len(toolCalls) != 2 {
+		t.Fatalf("assistant message has %d tool calls, want 2: %s", len(toolCalls), msg.Raw)
+	}
+	for i, tc := range toolCalls {
+		if tc.Get("id").String() == "" || tc.Get("function.name").String() == "" {
+			t.Errorf("tool_calls.%d is an empty placeholder: %s", i, tc.Raw)
+		}
+	}
+	if args := toolCalls[0].Get("function.arguments").String(); args != `{"q":"x"}` {
+		t.Errorf("tool_calls.0 arguments = %q", args)
+	}
+}
+
+func TestStreamedToolCalls_AssignsDenseIDs(t *testing.T) {
+	calls := newStreamedToolCalls(0)
+	calls.apply(gjson.Parse(`[{"index":4,"function":{"name":"late","arguments":"{}"}},{"index":2,"function":{"name":"early","arguments":"{}"}}]`))
+
+	got := calls.finalize()
+	if len(got) != 2 || got[0].Name != "early" || got[0].ID != "call_1" || got[1].Name != "late" || got[1].ID != "call_2" {
+		t.Fatalf("unexpected calls: %+v", got)
+	}
+}
+
+func TestStreamedToolCalls_OversizedArgumentsAreNotExecuted(t *testing.T) {
+	calls := newStreamedToolCalls(16)
+	calls.apply(gjson.Parse(`[{"index":0,"id":"call_big","function":{"name":"echo","arguments":"{\"text\":\""}}]`))
+	calls.apply(gjson.Parse(`[{"index":0,"function":{"arguments":"aaaaaaaaaaaaaaaa\"}"}},{"index":1,"id":"call_ok","function":{"name":"echo","arguments":"{}"}}]`))
+
+	got := calls.finalize()
+	if len(got) != 2 {
+		t.Fatalf("got %d tool calls, want 2: %+v", len(got), got)
+	}
+	if !got[0].Malformed || got[0].RawPayload != "" {
+		t.Fatalf("oversized call = %+v, want it malformed with its arguments dropped", got[0])
+	}
+	if got[1].Malformed {
+		t.Fatalf("small call marked malformed: %+v", got[1])
+	}
+
+	registry := agent.NewRegistry()
+	ran := 0
+	registry.Register("echo", func(context.Context, agent.ToolCall) (agent.ToolResult, error) {
+		ran++
+		return agent.ToolResult{Content: "ok"}, nil
+	})
+	results := agent.ExecuteToolCalls(context.Background(), got, agent.ExecuteOptions{}, registry)
+	if ran != 1 {
+		t.Fatalf("tool ran %d times, want only the well-formed call", ran)
+	}
+	if results[0].Status != agent.ToolStatusError || !strings.Contains(results[0].Error, "malformed_arguments") {
+		t.Fatalf("malformed call result = %+v", results[0])
+	}
+}
+
+func TestConsumeAgenticStream_FinalizesWithoutFinishReason(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	frames := []string{
+		`data: {"choices":[{"index":0,"delta":{"role":"assistant","content":"Looking it up."}}]}` + "\n\n",
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_w","function":{"name":"weather","arguments":"{\"city\":"}}]}}]}` + "\n\n",
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Oslo\"}"}}]}}]}` + "\n\n",
+	}
+	respChan := make(chan []byte, len(frames))
+	for _, frame := range frames {
+		respChan <- []byte(frame)
+	}
+	// The upstream closes without ever sending finish_reason "tool_calls".
+	close(respChan)
+	errChan := make(chan error)
+
+	resp, calls, err := consumeAgenticStream(c, context.Background(), respChan, errChan, c.Writer, newAgenticChunkFramer("m"), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(calls) != 1 || calls[0].ID != "call_w" || calls[0].Name != "weather" || string(calls[0].Arguments) != `{"city":"Oslo"}` {
+		t.Fatalf("tool call not finalized: %+v", calls)
+	}
+	msg := gjson.ParseBytes(resp)
+	if msg.Get("content").String() != "Looking it up." || msg.Get("tool_calls.0.function.arguments").String() != `{"city":"Oslo"}` {
+		t.Fatalf("assistant message = %s", resp)
+	}
+	if got := strings.Count(rec.Body.String(), "data: "); got != len(frames) {
+		t.Errorf("forwarded %d frames to the client, want %d", got, len(frames))
+	}
+	if strings.Contains(rec.Body.String(), "tool_calls") {
+		t.Errorf("tool call deltas forwarded to the client: %s", rec.Body.String())
+	}
+}
+
+func TestBuildToolMessage_ConveysStatus(t *testing.T) {
+	cases := []struct {
+		result  agent.ToolResult
+		content string
+	}{
+		{agent.ToolResult{ID: "c1", Content: "42", Status: agent.ToolStatusOK}, `42`},
+		{agent.ToolResult{ID: "c2", Content: "legacy"}, `legacy`},
+		{agent.ToolResult{ID: "c3", Status: agent.ToolStatusTimeout, Error: "tool timed out after 30s"}, `{"error":"tool timed out after 30s","status":"timeout"}`},
+		{agent.ToolResult{ID: "c4", Status: agent.ToolStatusError, Error: "disk full"}, `{"error":"disk full","status":"error"}`},
+		{agent.ToolResult{ID: "c5", Status: agent.ToolStatusDenied, Error: "tool execution was denied by the user"}, `{"error":"tool execution was denied by the user","status":"denied"}`},
+	}
+	for _, tc := range cases {
+		msg, err := buildToolMessage(tc.result)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.result.ID, err)
+		}
+		parsed := gjson.Parse(msg)
+		if parsed.Get("role").String() != "tool" || parsed.Get("tool_call_id").String() != tc.result.ID {
+			t.Errorf("%s: unexpected envelope %s", tc.result.ID, msg)
+		}
+		if got := parsed.Get("content").String(); got != tc.content {
+			t.Errorf("%s: content = %s, want %s", tc.result.ID, got, tc.content)
+		}
+	}
+}
+
+func TestTruncateToolResult_Boundary(t *testing.T) {
+	content := strings.Repeat("a", 10)
+	if got := truncateToolResult(content, 10, false); got != content {
+		t.Fatalf("content at the limit was truncated: %q", got)
+	}
+	if got := truncateToolResult(content, 0, false); got != content {
+		t.Fatalf("a zero limit must disable truncation, got %q", got)
+	}
+
+	got := truncateToolResult(content+"b", 10, false)
+	if want := content + "\n...[truncated 1 bytes]...\n"; got != want {
+		t.Fatalf("truncated = %q, want %q", got, want)
+	}
+}
+
+func TestTruncateToolResult_KeepsHeadAndTail(t *testing.T) {
+	got := truncateToolResult("HEAD-"+strings.Repeat("x", 100)+"-TAIL", 10, true)
+	if want := "HEAD-\n...[truncated 100 bytes]...\n-TAIL"; got != want {
+		t.Fatalf("truncated = %q, want %q", got, want)
+	}
+}
+
+func TestTruncateToolResult_DoesNotSplitRunes(t *testing.T) {
+	got := truncateToolResult(strings.Repeat("é", 6), 5, false)
+	if want := "éé\n...[truncated 8 bytes]...\n"; got != want {
+		t.Fatalf("truncated = %q, want %q", got, want)
+	}
+}
+
+func TestAppendAgenticMessages_TruncatesToolResults(t *testing.T) {
+	raw := []byte(`{"messages":[{"role":"user","content":"read it"}]}`)
+	results := []agent.ToolResult{{ID: "c1", Content: strings.Repeat("z", 64), Status: agent.ToolStatusOK}}
+
+	out, err := appendAgenticMessages(raw, nil, results, agenticConfig{MaxToolResultBytes: 16})
+	if err != nil {
+		t.Fatalf("appendAgenticMessages: %v", err)
+	}
+	content := gjson.GetBytes(out, "messages.1.content").String()
+	if !strings.HasPrefix(content, strings.Repeat("z", 16)+"\n...[truncated 48 bytes]...") {
+		t.Fatalf("tool message content = %q", content)
+	}
+	if results[0].Content != strings.Repeat("z", 64) {
+		t.Fatal("the recorded tool result must keep its full content")
+	}
+}
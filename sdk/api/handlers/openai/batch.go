@@ -0,0 +1,268 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/scheduler"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// maxBatchItems bounds the number of chat requests accepted by /v1/batch.
+	maxBatchItems = 100
+	// defaultBatchConcurrency limits in-flight items when the fair scheduler is disabled.
+	defaultBatchConcurrency = 8
+)
+
+// BatchResponse is the body returned by /v1/batch.
+type BatchResponse struct {
+	Object string            `json:"object"`
+	Data   []BatchItemResult `json:"data"`
+}
+
+// BatchItemResult holds the outcome of one batch item, in request order.
+type BatchItemResult struct {
+	Index    int             `json:"index"`
+	Status   int             `json:"status"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    json.RawMessage `json:"error,omitempty"`
+}
+
+// BatchSummary is the terminal event of a streamed batch.
+type BatchSummary struct {
+	Type      string `json:"type"`
+	Total     int    `json:"total"`
+	Succeeded int    `json:"succeeded"`
+	Failed    int    `json:"failed"`
+}
+
+// batchStreamEvent is a streamed batch item result.
+type batchStreamEvent struct {
+	Type string `json:"type"`
+	BatchItemResult
+}
+
+// Batch handles the /v1/batch endpoint. The body is either a JSON array of chat
+// completion requests or an object with a "requests" array. Items run concurrently
+// (through the fair scheduler when enabled) and results are returned in request order
+// with a per-item HTTP status. Streaming and agentic items are rejected individually.
+//
+// With `?stream=true` (or `"stream": true` in the object form) results are sent as
+// SSE `batch.item` events as each item finishes, followed by a `batch.completed` summary.
+func (h *OpenAIAPIHandler) Batch(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	root := gjson.ParseBytes(rawJSON)
+	items := root
+	stream := c.Query("stream") == "true"
+	if root.IsObject() {
+		items = root.Get("requests")
+		stream = stream || root.Get("stream").Bool()
+	}
+	if !items.IsArray() || len(items.Array()) == 0 {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "Invalid request: expected a non-empty array of chat completion requests",
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	requests := items.Array()
+	if len(requests) > maxBatchItems {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: batch exceeds %d items", maxBatchItems),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	if stream {
+		h.streamBatch(c, requests)
+		return
+	}
+
+	results := make([]BatchItemResult, len(requests))
+	for result := range h.runBatch(c, requests) {
+		results[result.Index] = result
+	}
+	c.JSON(http.StatusOK, BatchResponse{Object: "batch", Data: results})
+}
+
+// streamBatch writes each item result as an SSE event in completion order.
+func (h *OpenAIAPIHandler) streamBatch(c *gin.Context, requests []gjson.Result) {
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "Streaming not supported",
+				Type:    "server_error",
+			},
+		})
+		return
+	}
+	h.setSSEHeaders(c)
+
+	summary := BatchSummary{Type: "batch.completed", Total: len(requests)}
+	for result := range h.runBatch(c, requests) {
+		if result.Status == http.StatusOK {
+			summary.Succeeded++
+		} else {
+			summary.Failed++
+		}
+		event, _ := json.Marshal(batchStreamEvent{Type: "batch.item", BatchItemResult: result})
+		_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", event)
+		flusher.Flush()
+	}
+	event, _ := json.Marshal(summary)
+	_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", event)
+	_, _ = fmt.Fprint(c.Writer, "data: [DONE]\n\n")
+	flusher.Flush()
+}
+
+// runBatch executes every item concurrently and delivers results as they finish.
+// The channel is closed once all items have completed.
+func (h *OpenAIAPIHandler) runBatch(c *gin.Context, requests []gjson.Result) <-chan BatchItemResult {
+	out := make(chan BatchItemResult, len(requests))
+	run := h.batchRunner(c)
+	var wg sync.WaitGroup
+	for i, item := range requests {
+		// Detach before spawning: copying c must not overlap with writes to its response.
+		itemCtx := handlers.DetachedContext(c)
+		wg.Add(1)
+		go func(i int, raw []byte) {
+			defer wg.Done()
+			out <- h.executeBatchItem(itemCtx, run, i, raw)
+		}(i, []byte(item.Raw))
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// batchRunner returns a function that runs fn under the batch concurrency limit:
+// the fair scheduler when it is enabled and its workers are running, otherwise a
+// per-batch semaphore. fn returns the tokens
+// the item used so the scheduler can charge its key for them. Batch items are scheduled
+// at batch priority, so the key's interactive requests overtake them.
+func (h *OpenAIAPIHandler) batchRunner(c *gin.Context) func(ctx context.Context, body []byte, fn func() int64) error {
+	if sched := scheduler.GetScheduler(); h.Cfg != nil && h.Cfg.Scheduler.Enabled && sched.Running() {
+		apiKey := ""
+		if v, ok := c.Get("apiKey"); ok {
+			apiKey = fmt.Sprintf("%v", v)
+		}
+		return func(ctx context.Context, body []byte, fn func() int64) error {
+			opts := scheduler.ScheduleOptions{
+				EstimatedTokens: sched.EstimateTokens(body),
+				Priority:        scheduler.PriorityBatch,
+			}
+			return sched.ScheduleWithOptions(ctx, apiKey, opts, func() (int64, error) {
+				return fn(), nil
+			})
+		}
+	}
+
+	limit := defaultBatchConcurrency
+	if h.Cfg != nil && h.Cfg.Scheduler.MaxConcurrent > 0 {
+		limit = h.Cfg.Scheduler.MaxConcurrent
+	}
+	sem := make(chan struct{}, limit)
+	return func(ctx context.Context, _ []byte, fn func() int64) error {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		defer func() { <-sem }()
+		fn()
+		return nil
+	}
+}
+
+// executeBatchItem runs one item through the regular non-streaming execution path.
+// itemCtx must be a detached copy of the batch request's context.
+func (h *OpenAIAPIHandler) executeBatchItem(itemCtx *gin.Context, run func(context.Context, []byte, func() int64) error, index int, rawJSON []byte) BatchItemResult {
+	result := BatchItemResult{Index: index}
+	fail := func(status int, msg string) BatchItemResult {
+		result.Status = status
+		body := handlers.BuildErrorResponseBody(status, msg)
+		if detail := gjson.GetBytes(body, "error"); detail.IsObject() {
+			body = []byte(detail.Raw)
+		}
+		result.Error = json.RawMessage(body)
+		return result
+	}
+
+	if !gjson.ValidBytes(rawJSON) || !gjson.ParseBytes(rawJSON).IsObject() {
+		return fail(http.StatusBadRequest, "Invalid request: batch item must be a JSON object")
+	}
+	agentCfg, cleaned := parseAgenticConfig(rawJSON)
+	if agentCfg.Enabled {
+		return fail(http.StatusBadRequest, "Invalid request: agentic requests are not supported in a batch")
+	}
+	rawJSON = cleaned
+	if gjson.GetBytes(rawJSON, "stream").Bool() {
+		return fail(http.StatusBadRequest, "Invalid request: streaming is not supported in a batch")
+	}
+	modelName := strings.TrimSpace(gjson.GetBytes(rawJSON, "model").String())
+	if modelName == "" {
+		return fail(http.StatusBadRequest, "Invalid request: model is required")
+	}
+
+	cliCtx, cliCancel := h.GetContextWithCancel(h, itemCtx, context.Background())
+	var resp []byte
+	var errMsg *interfaces.ErrorMessage
+	if err := run(cliCtx, rawJSON, func() int64 {
+		resp, errMsg = h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, h.GetAlt(itemCtx))
+		return gjson.GetBytes(resp, "usage.total_tokens").Int()
+	}); err != nil {
+		cliCancel(err)
+		status := scheduler.HTTPStatus(err)
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		return fail(status, fmt.Sprintf("batch item was not scheduled: %v", err))
+	}
+	if errMsg != nil {
+		cliCancel(errMsg.Error)
+		status := http.StatusInternalServerError
+		if errMsg.StatusCode > 0 {
+			status = errMsg.StatusCode
+		}
+		errText := http.StatusText(status)
+		if errMsg.Error != nil && errMsg.Error.Error() != "" {
+			errText = errMsg.Error.Error()
+		}
+		return fail(status, errText)
+	}
+	cliCancel(resp)
+
+	result.Status = http.StatusOK
+	if json.Valid(resp) {
+		result.Response = json.RawMessage(resp)
+	} else {
+		result.Response, _ = json.Marshal(string(resp))
+	}
+	return result
+}
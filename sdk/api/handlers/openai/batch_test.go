@@ -0,0 +1,209 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/scheduler"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+// echoExecutor answers with the last user message and fails every request for broken-model.
+type echoExecutor struct{}
+
+func (echoExecutor) Identifier() string { return "codex" }
+
+func (echoExecutor) Execute(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	if req.Model == "broken-model" {
+		return coreexecutor.Response{}, &coreauth.Error{Code: "unavailable", Message: "broken-model unavailable", HTTPStatus: http.StatusServiceUnavailable}
+	}
+	// Stagger responses so completion order differs from request order.
+	content := gjson.GetBytes(req.Payload, "messages.0.content").String()
+	if content == "first" {
+		time.Sleep(50 * time.Millisecond)
+	}
+	return coreexecutor.Response{Payload: []byte(`{"object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"echo ` + content + `"},"finish_reason":"stop"}]}`)}, nil
+}
+
+func (echoExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (echoExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (echoExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (echoExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+const mixedBatchBody = `[
+	{"model":"echo-model","messages":[{"role":"user","content":"first"}]},
+	{"model":"broken-model","messages":[{"role":"user","content":"second"}]},
+	{"model":"echo-model","stream":true,"messages":[{"role":"user","content":"third"}]},
+	{"model":"echo-model","messages":[{"role":"user","content":"fourth"}]}
+]`
+
+func runBatch(t *testing.T, cfg *sdkconfig.SDKConfig, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(echoExecutor{})
+	auth := &coreauth.Auth{ID: "batch-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "echo-model"}, {ID: "broken-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	h := NewOpenAIAPIHandler(handlers.NewBaseAPIHandlers(cfg, manager))
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/batch", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	h.Batch(c)
+	return rec
+}
+
+func assertMixedBatch(t *testing.T, rec *httptest.ResponseRecorder) {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	data := gjson.Get(rec.Body.String(), "data").Array()
+	if len(data) != 4 {
+		t.Fatalf("got %d results, want 4: %s", len(data), rec.Body.String())
+	}
+	for i, item := range data {
+		if got := item.Get("index").Int(); got != int64(i) {
+			t.Errorf("result %d has index %d", i, got)
+		}
+	}
+
+	if data[0].Get("status").Int() != http.StatusOK || data[0].Get("response.choices.0.message.content").String() != "echo first" {
+		t.Errorf("item 0 = %s", data[0].Raw)
+	}
+	if data[1].Get("status").Int() != http.StatusServiceUnavailable || !strings.Contains(data[1].Get("error.message").String(), "broken-model unavailable") {
+		t.Errorf("item 1 = %s", data[1].Raw)
+	}
+	if data[1].Get("response").Exists() {
+		t.Errorf("failed item carries a response: %s", data[1].Raw)
+	}
+	if data[2].Get("status").Int() != http.StatusBadRequest || !strings.Contains(data[2].Get("error.message").String(), "streaming is not supported") {
+		t.Errorf("item 2 = %s", data[2].Raw)
+	}
+	if data[3].Get("status").Int() != http.StatusOK || data[3].Get("response.choices.0.message.content").String() != "echo fourth" {
+		t.Errorf("item 3 = %s", data[3].Raw)
+	}
+}
+
+func TestBatch_MixedResultsPreserveOrder(t *testing.T) {
+	assertMixedBatch(t, runBatch(t, &sdkconfig.SDKConfig{}, mixedBatchBody))
+}
+
+func TestBatch_SchedulerWithoutWorkersFallsBackToSemaphore(t *testing.T) {
+	sched := scheduler.GetScheduler()
+	if sched.Running() {
+		t.Skip("global scheduler already has workers")
+	}
+	before := sched.Stats().Metrics.TotalEnqueued
+
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Scheduler.Enabled = true
+	assertMixedBatch(t, runBatch(t, cfg, `{"requests":`+mixedBatchBody+`}`))
+
+	if got := sched.Stats().Metrics.TotalEnqueued - before; got != 0 {
+		t.Fatalf("scheduled %d items on a scheduler with no workers, want 0", got)
+	}
+}
+
+func TestBatch_RunsThroughFairScheduler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	sched := scheduler.GetScheduler()
+	sched.Start(ctx, 2)
+	before := sched.Stats().Metrics.TotalEnqueued
+
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Scheduler.Enabled = true
+	assertMixedBatch(t, runBatch(t, cfg, `{"requests":`+mixedBatchBody+`}`))
+
+	// Only the three items that reach execution are scheduled.
+	if got := sched.Stats().Metrics.TotalEnqueued - before; got != 3 {
+		t.Fatalf("scheduled %d items, want 3", got)
+	}
+}
+
+func TestBatch_RejectsInvalidBody(t *testing.T) {
+	for _, body := range []string{`{}`, `[]`, `{"requests":"nope"}`} {
+		rec := runBatch(t, &sdkconfig.SDKConfig{}, body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", body, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestBatch_StreamEmitsItemsAsTheyFinish(t *testing.T) {
+	rec := runBatch(t, &sdkconfig.SDKConfig{}, `{"stream":true,"requests":`+mixedBatchBody+`}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	var events []gjson.Result
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if payload, ok := strings.CutPrefix(line, "data: "); ok && payload != "[DONE]" {
+			events = append(events, gjson.Parse(payload))
+		}
+	}
+	if !strings.HasSuffix(rec.Body.String(), "data: [DONE]\n\n") {
+		t.Fatalf("stream not terminated with [DONE]: %s", rec.Body.String())
+	}
+	if len(events) != 5 {
+		t.Fatalf("got %d events, want 4 items and a summary: %s", len(events), rec.Body.String())
+	}
+
+	seen := map[int64]gjson.Result{}
+	var order []int64
+	for _, event := range events[:4] {
+		if event.Get("type").String() != "batch.item" {
+			t.Fatalf("unexpected event: %s", event.Raw)
+		}
+		order = append(order, event.Get("index").Int())
+		seen[event.Get("index").Int()] = event
+	}
+	if len(seen) != 4 {
+		t.Fatalf("duplicate or missing indexes: %v", order)
+	}
+	// The slow first item must not hold back items that finished earlier.
+	if order[len(order)-1] != 0 {
+		t.Fatalf("item order = %v, want the slow item 0 last", order)
+	}
+	if seen[0].Get("response.choices.0.message.content").String() != "echo first" || seen[1].Get("status").Int() != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected item payloads: %s / %s", seen[0].Raw, seen[1].Raw)
+	}
+
+	summary := events[4]
+	if summary.Get("type").String() != "batch.completed" || summary.Get("total").Int() != 4 ||
+		summary.Get("succeeded").Int() != 2 || summary.Get("failed").Int() != 2 {
+		t.Fatalf("unexpected summary: %s", summary.Raw)
+	}
+}
@@ -0,0 +1,112 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/scheduler"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+)
+
+// CachePrimeResponse is the body returned by /v1/cache/prime.
+type CachePrimeResponse struct {
+	Object   string          `json:"object"`
+	Model    string          `json:"model"`
+	CacheKey string          `json:"cache_key"`
+	Cache    string          `json:"cache"`
+	Usage    json.RawMessage `json:"usage,omitempty"`
+}
+
+// CachePrime handles the /v1/cache/prime endpoint. The body is a regular non-streaming chat
+// completion request; it is executed like /v1/chat/completions and its response stored in
+// the response cache, so identical requests that follow are served from the cache. Only
+// the cache key, whether the response was already cached and the token usage are returned,
+// never the response itself. Priming runs through the fair scheduler at batch priority when
+// it is enabled, so it counts against the key's rate limits.
+func (h *OpenAIAPIHandler) CachePrime(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		h.writeCachePrimeError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	if !gjson.ValidBytes(rawJSON) || !gjson.ParseBytes(rawJSON).IsObject() {
+		h.writeCachePrimeError(c, http.StatusBadRequest, "Invalid request: body must be a chat completion request")
+		return
+	}
+	if h.Cfg == nil || !h.Cfg.Cache.Enabled {
+		h.writeCachePrimeError(c, http.StatusBadRequest, "Invalid request: response caching is disabled")
+		return
+	}
+	if handlers.RequestCacheControl(c).SkipStore {
+		h.writeCachePrimeError(c, http.StatusBadRequest, "Invalid request: a primed response must be stored")
+		return
+	}
+	if gjson.GetBytes(rawJSON, "stream").Bool() {
+		h.writeCachePrimeError(c, http.StatusBadRequest, "Invalid request: streaming responses are not cached")
+		return
+	}
+	if agentCfg, _ := parseAgenticConfig(rawJSON); agentCfg.Enabled {
+		h.writeCachePrimeError(c, http.StatusBadRequest, "Invalid request: agentic requests are not cached")
+		return
+	}
+	modelName := strings.TrimSpace(gjson.GetBytes(rawJSON, "model").String())
+	if modelName == "" {
+		h.writeCachePrimeError(c, http.StatusBadRequest, "Invalid request: model is required")
+		return
+	}
+
+	alt := h.GetAlt(c)
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, handlers.WithCachePriming(context.Background()))
+	var resp []byte
+	var errMsg *interfaces.ErrorMessage
+	run := h.batchRunner(c)
+	if err = run(cliCtx, rawJSON, func() int64 {
+		resp, errMsg = h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, alt)
+		return gjson.GetBytes(resp, "usage.total_tokens").Int()
+	}); err != nil {
+		cliCancel(err)
+		status := scheduler.HTTPStatus(err)
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		h.writeCachePrimeError(c, status, fmt.Sprintf("cache prime was not scheduled: %v", err))
+		return
+	}
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	cliCancel()
+
+	out := CachePrimeResponse{
+		Object:   "cache.prime",
+		Model:    modelName,
+		CacheKey: h.ResponseCacheKey(h.HandlerType(), modelName, rawJSON, alt),
+		Cache:    c.Writer.Header().Get(handlers.CacheHeader),
+	}
+	if usage := gjson.GetBytes(resp, "usage"); usage.IsObject() {
+		out.Usage = json.RawMessage(usage.Raw)
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// writeCachePrimeError writes an OpenAI-style error for a rejected cache prime.
+func (h *OpenAIAPIHandler) writeCachePrimeError(c *gin.Context, status int, msg string) {
+	errType := "invalid_request_error"
+	if status >= http.StatusInternalServerError {
+		errType = "server_error"
+	}
+	c.JSON(status, handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{
+			Message: msg,
+			Type:    errType,
+		},
+	})
+}
@@ -0,0 +1,189 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+// countingChatExecutor answers chat requests with a fixed completion and counts them.
+type countingChatExecutor struct {
+	calls *atomic.Int32
+}
+
+func (countingChatExecutor) Identifier() string { return "codex" }
+
+func (e countingChatExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	e.calls.Add(1)
+	return coreexecutor.Response{Payload: []byte(`{"object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"primed answer"},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`)}, nil
+}
+
+func (countingChatExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (countingChatExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (countingChatExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (countingChatExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func newCachePrimeTestHandler(t *testing.T, model string) (*OpenAIAPIHandler, *atomic.Int32) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	calls := &atomic.Int32{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(countingChatExecutor{calls: calls})
+	auth := &coreauth.Auth{ID: model + "-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: model}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Cache.Enabled = true
+	return NewOpenAIAPIHandler(handlers.NewBaseAPIHandlers(cfg, manager)), calls
+}
+
+func postJSON(path, body string, handle func(*gin.Context)) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	handle(c)
+	return rec
+}
+
+func TestCachePrime_LaterRequestHitsCache(t *testing.T) {
+	const model = "cache-prime-model"
+	h, calls := newCachePrimeTestHandler(t, model)
+	// The cache is process-wide, so every run primes a prompt of its own.
+	body := fmt.Sprintf(`{"model":%q,"messages":[{"role":"user","content":"hot prompt %d"}]}`, model, time.Now().UnixNano())
+
+	primed := postJSON("/v1/cache/prime", body, h.CachePrime)
+	if primed.Code != http.StatusOK {
+		t.Fatalf("prime status = %d, body %s", primed.Code, primed.Body.String())
+	}
+	out := gjson.Parse(primed.Body.String())
+	if out.Get("cache_key").String() == "" || out.Get("cache").String() != "MISS" {
+		t.Fatalf("prime response = %s, want a cache key and cache MISS", primed.Body.String())
+	}
+	if got := out.Get("usage.total_tokens").Int(); got != 15 {
+		t.Fatalf("prime usage.total_tokens = %d, want 15", got)
+	}
+	if out.Get("choices").Exists() || strings.Contains(primed.Body.String(), "primed answer") {
+		t.Fatalf("prime response leaks the completion: %s", primed.Body.String())
+	}
+
+	chat := postJSON("/v1/chat/completions", body, h.ChatCompletions)
+	if chat.Code != http.StatusOK {
+		t.Fatalf("chat status = %d, body %s", chat.Code, chat.Body.String())
+	}
+	if got := chat.Header().Get(handlers.CacheHeader); got != "HIT" {
+		t.Fatalf("chat X-Cache = %q, want HIT", got)
+	}
+	if got := gjson.Get(chat.Body.String(), "choices.0.message.content").String(); got != "primed answer" {
+		t.Fatalf("chat content = %q, want the primed answer", got)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("upstream calls = %d, want 1", n)
+	}
+}
+
+func TestCachePrime_RejectsRequestsThatCannotBeCached(t *testing.T) {
+	const model = "cache-prime-reject-model"
+	h, calls := newCachePrimeTestHandler(t, model)
+
+	for name, body := range map[string]string{
+		"streaming": `{"model":"` + model + `","stream":true,"messages":[{"role":"user","content":"hi"}]}`,
+		"no model":  `{"messages":[{"role":"user","content":"hi"}]}`,
+		"not json":  `[1,2]`,
+	} {
+		if rec := postJSON("/v1/cache/prime", body, h.CachePrime); rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d, want 400", name, rec.Code)
+		}
+	}
+
+	h.Cfg.Cache.Enabled = false
+	body := `{"model":"` + model + `","messages":[{"role":"user","content":"hi"}]}`
+	if rec := postJSON("/v1/cache/prime", body, h.CachePrime); rec.Code != http.StatusBadRequest {
+		t.Fatalf("caching disabled: status = %d, want 400", rec.Code)
+	}
+	if n := calls.Load(); n != 0 {
+		t.Fatalf("upstream calls = %d, want 0", n)
+	}
+}
+
+func TestCachePrime_KeyIncludesMaxTokensAndOnlyPrimesStore(t *testing.T) {
+	const model = "cache-prime-scope-model"
+	h, calls := newCachePrimeTestHandler(t, model)
+	prompt := fmt.Sprintf("scoped prompt %d", time.Now().UnixNano())
+	short := fmt.Sprintf(`{"model":%q,"max_tokens":5,"messages":[{"role":"user","content":%q}]}`, model, prompt)
+	long := fmt.Sprintf(`{"model":%q,"max_tokens":4000,"messages":[{"role":"user","content":%q}]}`, model, prompt)
+
+	if rec := postJSON("/v1/cache/prime", short, h.CachePrime); rec.Code != http.StatusOK {
+		t.Fatalf("prime status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	// A different max_tokens must not be answered by the primed response, and an ordinary
+	// request is not stored, so asking again goes upstream again.
+	for i := 0; i < 2; i++ {
+		chat := postJSON("/v1/chat/completions", long, h.ChatCompletions)
+		if got := chat.Header().Get(handlers.CacheHeader); got != "MISS" {
+			t.Fatalf("request %d with larger max_tokens: X-Cache = %q, want MISS", i, got)
+		}
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("upstream calls = %d, want 3", n)
+	}
+}
+
+func TestCacheWarmup_PreloadedEntryServesChatRequest(t *testing.T) {
+	const model = "cache-warmup-model"
+	h, calls := newCachePrimeTestHandler(t, model)
+	prompt := fmt.Sprintf("preloaded prompt %d", time.Now().UnixNano())
+
+	report := cache.GetCacheSystem().Preload([]cache.WarmupEntry{{
+		Model:    model,
+		Prompt:   prompt,
+		Response: `{"object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"preloaded answer"},"finish_reason":"stop"}]}`,
+	}}, func(model string, request []byte) string {
+		return handlers.ResponseCacheKeyFor(h.Cfg, h.HandlerType(), model, request, "")
+	})
+	if report.Loaded != 1 {
+		t.Fatalf("preload report = %+v, want 1 loaded", report)
+	}
+
+	body := fmt.Sprintf(`{"model":%q,"messages":[{"role":"user","content":%q}]}`, model, prompt)
+	chat := postJSON("/v1/chat/completions", body, h.ChatCompletions)
+	if got := chat.Header().Get(handlers.CacheHeader); got != "HIT" {
+		t.Fatalf("chat X-Cache = %q, want HIT", got)
+	}
+	if got := gjson.Get(chat.Body.String(), "choices.0.message.content").String(); got != "preloaded answer" {
+		t.Fatalf("chat content = %q, want the preloaded answer", got)
+	}
+	if n := calls.Load(); n != 0 {
+		t.Fatalf("upstream calls = %d, want 0", n)
+	}
+}
@@ -0,0 +1,181 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+// countingExecutor records how many times the provider is contacted.
+type countingExecutor struct {
+	calls *atomic.Int32
+}
+
+func (countingExecutor) Identifier() string { return "codex" }
+
+func (e countingExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	e.calls.Add(1)
+	return coreexecutor.Response{Payload: []byte(`{"id":"chatcmpl-1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`)}, nil
+}
+
+func (e countingExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	e.calls.Add(1)
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (countingExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (countingExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (countingExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func runDryRun(t *testing.T, cfg *sdkconfig.SDKConfig, target string, header http.Header, body string) (*httptest.ResponseRecorder, int32) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	calls := &atomic.Int32{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(countingExecutor{calls: calls})
+	auth := &coreauth.Auth{ID: "dry-run-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "dry-run-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	h := NewOpenAIAPIHandler(handlers.NewBaseAPIHandlers(cfg, manager))
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		c.Request.Header[k] = v
+	}
+	h.ChatCompletions(c)
+	return rec, calls.Load()
+}
+
+func TestDryRun_ReportsEstimateWithoutUpstreamCall(t *testing.T) {
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Pricing.Models = map[string]sdkconfig.ModelPrice{
+		"dry-run-model": {InputPerMillion: 1, OutputPerMillion: 2},
+	}
+	body := `{"model":"dry-run-model","max_tokens":100,"messages":[{"role":"user","content":"` + strings.Repeat("a", 400) + `"}]}`
+
+	rec, calls := runDryRun(t, cfg, "/v1/chat/completions?dry_run=true", nil, body)
+	if calls != 0 {
+		t.Fatalf("provider called %d times during dry run", calls)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	report := gjson.Parse(rec.Body.String())
+	if report.Get("object").String() != "dry_run" || !report.Get("valid").Bool() {
+		t.Fatalf("unexpected report: %s", rec.Body.String())
+	}
+	if got := report.Get("estimated_prompt_tokens").Int(); got != 100 {
+		t.Errorf("estimated_prompt_tokens = %d, want 100", got)
+	}
+	if got := report.Get("providers.0").String(); got != "codex" {
+		t.Errorf("providers = %s, want [codex]", report.Get("providers").Raw)
+	}
+	// 100 prompt tokens at $1/M plus 100 output tokens at $2/M.
+	if got := report.Get("estimated_cost.total").Float(); got < 0.000299 || got > 0.000301 {
+		t.Errorf("estimated_cost.total = %v, want 0.0003", got)
+	}
+}
+
+func TestDryRun_HeaderReportsValidationWarnings(t *testing.T) {
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Context.ModelLimits = map[string]int64{"dry-run-model": 50}
+	body := `{"model":"dry-run-model","messages":[{"role":"user","content":"` + strings.Repeat("a", 400) + `"}],"tools":[{"type":"function","function":{}}]}`
+
+	rec, calls := runDryRun(t, cfg, "/v1/chat/completions", http.Header{handlers.DryRunHeader: {"true"}}, body)
+	if calls != 0 {
+		t.Fatalf("provider called %d times during dry run", calls)
+	}
+
+	report := gjson.Parse(rec.Body.String())
+	if report.Get("valid").Bool() {
+		t.Fatalf("report should be invalid: %s", rec.Body.String())
+	}
+	if !strings.Contains(report.Get("errors").Raw, "tools[0] is missing a function name") {
+		t.Errorf("missing tool validation error: %s", report.Get("errors").Raw)
+	}
+	warnings := report.Get("warnings").Raw
+	if !strings.Contains(warnings, "exceed the context window (50)") {
+		t.Errorf("missing context-fit warning: %s", warnings)
+	}
+	if !strings.Contains(warnings, "no pricing configured") {
+		t.Errorf("missing pricing warning: %s", warnings)
+	}
+}
+
+func TestDryRun_UnknownModelIsInvalid(t *testing.T) {
+	rec, _ := runDryRun(t, &sdkconfig.SDKConfig{}, "/v1/chat/completions?dry_run=1", nil, `{"model":"no-such-model","messages":[{"role":"user","content":"hi"}]}`)
+	report := gjson.Parse(rec.Body.String())
+	if report.Get("valid").Bool() || !strings.Contains(report.Get("errors").Raw, "unknown provider") {
+		t.Fatalf("unexpected report: %s", rec.Body.String())
+	}
+}
+
+func TestDryRun_DefaultProviderRouteIsNotCounted(t *testing.T) {
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.ModelRouting.DefaultProvider = "dry-run-default"
+	rec, calls := runDryRun(t, cfg, "/v1/chat/completions?dry_run=true", nil, `{"model":"unlisted-model","messages":[{"role":"user","content":"hi"}]}`)
+	if calls != 0 {
+		t.Fatalf("provider called %d times during dry run", calls)
+	}
+	report := gjson.Parse(rec.Body.String())
+	if got := report.Get("providers.0").String(); got != "dry-run-default" {
+		t.Fatalf("providers = %s, want the default provider", report.Get("providers").Raw)
+	}
+	if strings.Contains(observability.GetMetrics().Export(), `provider="dry-run-default"`) {
+		t.Fatal("dry run counted a default-provider route")
+	}
+}
+
+func TestDryRun_ReportsMessageLimit(t *testing.T) {
+	cfg := &sdkconfig.SDKConfig{MaxMessages: 1}
+	body := `{"model":"dry-run-model","messages":[{"role":"user","content":"hi"},{"role":"user","content":"again"}]}`
+	rec, calls := runDryRun(t, cfg, "/v1/chat/completions?dry_run=true", nil, body)
+	if rec.Code != http.StatusOK || calls != 0 {
+		t.Fatalf("status = %d, upstream calls = %d; body %s", rec.Code, calls, rec.Body.String())
+	}
+	report := gjson.Parse(rec.Body.String())
+	if report.Get("valid").Bool() || !strings.Contains(report.Get("errors").Raw, "more than the 1 allowed") {
+		t.Fatalf("report does not flag the message limit: %s", rec.Body.String())
+	}
+}
+
+func TestChatCompletions_ResponsesFormatOverMessageLimitIsRejected(t *testing.T) {
+	cfg := &sdkconfig.SDKConfig{MaxMessages: 1}
+	body := `{"model":"dry-run-model","input":[{"role":"user","content":"hi"},{"role":"user","content":"again"}]}`
+	rec, calls := runDryRun(t, cfg, "/v1/chat/completions", nil, body)
+	if rec.Code != http.StatusBadRequest || calls != 0 {
+		t.Fatalf("status = %d, upstream calls = %d, want a 400 without an upstream call; body %s", rec.Code, calls, rec.Body.String())
+	}
+	if got := gjson.Get(rec.Body.String(), "error.code").String(); got != "too_many_messages" {
+		t.Fatalf("error code = %q, want too_many_messages: %s", got, rec.Body.String())
+	}
+}
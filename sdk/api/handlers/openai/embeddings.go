@@ -0,0 +1,235 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// embeddingsStore is the cache embeddings are served from.
+type embeddingsStore interface {
+	Get(model, key string) ([]byte, bool)
+	Set(model, key string, value []byte)
+	GetStale(model, key string) (value []byte, stale bool, ok bool)
+}
+
+// embeddingsCache returns the cache used for embeddings. Replaced in tests.
+var embeddingsCache = func() embeddingsStore {
+	return cache.GetCacheSystem()
+}
+
+// Embeddings handles the /v1/embeddings endpoint.
+// The request is routed like a chat completion and forwarded untranslated to the
+// provider's embeddings endpoint. Embeddings are deterministic, so when caching is enabled
+// every input is cached and only inputs missing from the cache are sent upstream. The
+// "X-Cache" header reports HIT, PARTIAL or MISS. With serve-stale-on-error, an upstream
+// failure is answered from expired entries still within their grace window, reported as
+// STALE. The X-Cache-Control request header can force a fresh response; see
+// handlers.CacheControlHeader.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) Embeddings(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	modelName := gjson.GetBytes(rawJSON, "model").String()
+	if modelName == "" || !gjson.GetBytes(rawJSON, "input").Exists() {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "Invalid request: model and input are required",
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	c.Header("Content-Type", "application/json")
+
+	start := time.Now()
+	if h.Cfg == nil || !h.Cfg.Cache.Enabled {
+		resp, errMsg := h.fetchEmbeddings(c, modelName, rawJSON)
+		if errMsg != nil {
+			h.WriteErrorResponse(c, errMsg)
+			return
+		}
+		_, _ = c.Writer.Write(resp)
+		return
+	}
+
+	// Each input is cached on its own so a batch sharing inputs with earlier requests only
+	// sends the inputs not seen before.
+	cacheControl := handlers.RequestCacheControl(c)
+	inputs, batched := embeddingsInputs(rawJSON)
+	keys := make([]string, len(inputs))
+	vectors := make([]string, len(inputs))
+	var misses []int
+	for i, input := range inputs {
+		keys[i] = embeddingsCacheKey(rawJSON, input)
+		if cacheControl.SkipLookup {
+			misses = append(misses, i)
+			continue
+		}
+		cached, hit := embeddingsCache().Get(modelName, keys[i])
+		if hit {
+			vectors[i] = string(cached)
+		} else {
+			misses = append(misses, i)
+		}
+	}
+
+	responseModel := modelName
+	usage := `{"prompt_tokens":0,"total_tokens":0}`
+	if len(misses) > 0 {
+		upstreamJSON := rawJSON
+		if batched && len(misses) < len(inputs) {
+			missed := make([]string, len(misses))
+			for i, idx := range misses {
+				missed[i] = inputs[idx]
+			}
+			upstreamJSON, _ = sjson.SetRawBytes(rawJSON, "input", []byte("["+strings.Join(missed, ",")+"]"))
+		}
+		resp, errMsg := h.fetchEmbeddings(c, modelName, upstreamJSON)
+		if errMsg != nil {
+			if h.ServeStaleOnError(c, errMsg) && fillStaleEmbeddings(modelName, keys, vectors, misses) {
+				log.Warnf("embeddings: serving stale cached vectors for %s after upstream error (status %d)", modelName, errMsg.StatusCode)
+				recordCachedEmbeddings(modelName, start)
+				handlers.MarkStale(c)
+				_, _ = c.Writer.Write(buildEmbeddingsResponse(modelName, vectors, usage))
+				return
+			}
+			h.WriteErrorResponse(c, errMsg)
+			return
+		}
+		data := gjson.GetBytes(resp, "data").Array()
+		if len(data) != len(misses) {
+			// The provider did not answer one vector per input; pass its response through.
+			_, _ = c.Writer.Write(resp)
+			return
+		}
+		for i, item := range data {
+			pos := i
+			if index := item.Get("index"); index.Exists() && int(index.Int()) >= 0 && int(index.Int()) < len(misses) {
+				pos = int(index.Int())
+			}
+			idx := misses[pos]
+			vectors[idx] = item.Get("embedding").Raw
+			if !cacheControl.SkipStore {
+				embeddingsCache().Set(modelName, keys[idx], []byte(vectors[idx]))
+			}
+		}
+		if m := gjson.GetBytes(resp, "model").String(); m != "" {
+			responseModel = m
+		}
+		if u := gjson.GetBytes(resp, "usage"); u.Exists() {
+			usage = u.Raw
+		}
+	}
+
+	switch len(misses) {
+	case 0:
+		c.Header(handlers.CacheHeader, "HIT")
+		recordCachedEmbeddings(modelName, start)
+	case len(inputs):
+		c.Header(handlers.CacheHeader, "MISS")
+	default:
+		c.Header(handlers.CacheHeader, "PARTIAL")
+	}
+	_, _ = c.Writer.Write(buildEmbeddingsResponse(responseModel, vectors, usage))
+}
+
+// fetchEmbeddings sends an embeddings request upstream.
+func (h *OpenAIAPIHandler) fetchEmbeddings(c *gin.Context, modelName string, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, errMsg := h.ExecuteEmbeddingsWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON)
+	if errMsg != nil {
+		cliCancel(errMsg.Error)
+		return nil, errMsg
+	}
+	cliCancel()
+	return resp, nil
+}
+
+// fillStaleEmbeddings fills vectors at the missed positions from expired cache entries.
+// It reports false unless every miss was found.
+func fillStaleEmbeddings(modelName string, keys, vectors []string, misses []int) bool {
+	for _, idx := range misses {
+		cached, _, ok := embeddingsCache().GetStale(modelName, keys[idx])
+		if !ok {
+			return false
+		}
+		vectors[idx] = string(cached)
+	}
+	return true
+}
+
+// embeddingsInputs splits the request input into the items embedded separately, as
+// compact JSON. batched reports whether input is an array of strings or token arrays; a
+// single string or a single token array is one item.
+func embeddingsInputs(rawJSON []byte) (inputs []string, batched bool) {
+	input := gjson.GetBytes(rawJSON, "input")
+	items := input.Array()
+	if !input.IsArray() || len(items) == 0 || (items[0].Type != gjson.String && !items[0].IsArray()) {
+		return []string{compactJSON(input.Raw)}, false
+	}
+	inputs = make([]string, len(items))
+	for i, item := range items {
+		inputs[i] = compactJSON(item.Raw)
+	}
+	return inputs, true
+}
+
+// embeddingsCacheKey keys one input by its content and the request options that change
+// the vector returned. The model is added by the cache itself.
+func embeddingsCacheKey(rawJSON []byte, input string) string {
+	return "embedding|" + input +
+		"|format:" + gjson.GetBytes(rawJSON, "encoding_format").String() +
+		"|dimensions:" + gjson.GetBytes(rawJSON, "dimensions").String()
+}
+
+// buildEmbeddingsResponse assembles an OpenAI embeddings list with one entry per input,
+// in input order.
+func buildEmbeddingsResponse(model string, vectors []string, usage string) []byte {
+	out := []byte(`{"object":"list","data":[]}`)
+	for i, vector := range vectors {
+		entry := `{"object":"embedding","index":` + strconv.Itoa(i) + `,"embedding":` + vector + `}`
+		out, _ = sjson.SetRawBytes(out, "data.-1", []byte(entry))
+	}
+	out, _ = sjson.SetBytes(out, "model", model)
+	out, _ = sjson.SetRawBytes(out, "usage", []byte(usage))
+	return out
+}
+
+func compactJSON(raw string) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(raw)); err != nil {
+		return raw
+	}
+	return buf.String()
+}
+
+// recordCachedEmbeddings records a request answered without an upstream call. Requests that
+// reach upstream are recorded from their usage record instead, so they are not counted twice.
+func recordCachedEmbeddings(modelName string, start time.Time) {
+	observability.GetMetricsRecorder().RecordRequest(modelName, "cache", "success", time.Since(start), 0)
+}
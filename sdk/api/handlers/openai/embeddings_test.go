@@ -0,0 +1,358 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// embeddingsExecutor serves embeddings requests and rejects anything else. Each input
+// string is embedded as a one-element vector holding its length, and every input sent
+// upstream is recorded. While failing is set it answers with a 503.
+type embeddingsExecutor struct {
+	calls   *atomic.Int32
+	mu      *sync.Mutex
+	sent    *[]string
+	failing *atomic.Bool
+}
+
+func (embeddingsExecutor) Identifier() string { return "codex" }
+
+func (embeddingsExecutor) SupportsEmbeddings() bool { return true }
+
+func (e embeddingsExecutor) Execute(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	if action, _ := req.Metadata["action"].(string); action != coreexecutor.EmbeddingsAction {
+		return coreexecutor.Response{}, &coreauth.Error{Message: "not an embeddings request", HTTPStatus: http.StatusBadRequest}
+	}
+	e.calls.Add(1)
+	if e.failing.Load() {
+		return coreexecutor.Response{}, &coreauth.Error{Message: "upstream unavailable", HTTPStatus: http.StatusServiceUnavailable}
+	}
+	input := gjson.GetBytes(req.Payload, "input")
+	items := []gjson.Result{input}
+	if input.IsArray() {
+		items = input.Array()
+	}
+	out := []byte(`{"object":"list","data":[],"model":"` + req.Model + `"}`)
+	e.mu.Lock()
+	for i, item := range items {
+		*e.sent = append(*e.sent, item.String())
+		out, _ = sjson.SetRawBytes(out, "data.-1", []byte(fmt.Sprintf(`{"object":"embedding","index":%d,"embedding":[%d]}`, i, len(item.String()))))
+	}
+	e.mu.Unlock()
+	out, _ = sjson.SetRawBytes(out, "usage", []byte(fmt.Sprintf(`{"prompt_tokens":%d,"total_tokens":%d}`, 7*len(items), 7*len(items))))
+	return coreexecutor.Response{Payload: out}, nil
+}
+
+func (embeddingsExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (embeddingsExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (embeddingsExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (embeddingsExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func newEmbeddingsTestHandler(t *testing.T, model string, cacheEnabled bool) (*OpenAIAPIHandler, embeddingsExecutor) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	executor := embeddingsExecutor{calls: &atomic.Int32{}, mu: &sync.Mutex{}, sent: &[]string{}, failing: &atomic.Bool{}}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: model + "-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: model}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Cache.Enabled = cacheEnabled
+	return NewOpenAIAPIHandler(handlers.NewBaseAPIHandlers(cfg, manager)), executor
+}
+
+func postEmbeddings(h *OpenAIAPIHandler, body string) *httptest.ResponseRecorder {
+	return postEmbeddingsWithCacheControl(h, body, "")
+}
+
+func postEmbeddingsWithCacheControl(h *OpenAIAPIHandler, body, cacheControl string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	if cacheControl != "" {
+		c.Request.Header.Set(handlers.CacheControlHeader, cacheControl)
+	}
+	h.Embeddings(c)
+	return rec
+}
+
+func TestEmbeddings_IdenticalRequestsHitCache(t *testing.T) {
+	const model = "embed-cache-test-model"
+	h, executor := newEmbeddingsTestHandler(t, model, true)
+
+	first := postEmbeddings(h, `{"model":"`+model+`","input":["alpha","beta"]}`)
+	if first.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", first.Code, first.Body.String())
+	}
+	if got := first.Header().Get(handlers.CacheHeader); got != "MISS" {
+		t.Fatalf("first X-Cache = %q, want MISS", got)
+	}
+
+	second := postEmbeddings(h, `{"model":"`+model+`", "input": ["alpha", "beta"]}`)
+	if got := second.Header().Get(handlers.CacheHeader); got != "HIT" {
+		t.Fatalf("second X-Cache = %q, want HIT", got)
+	}
+	if got, want := gjson.Get(second.Body.String(), "data").Raw, gjson.Get(first.Body.String(), "data").Raw; got != want {
+		t.Fatalf("cached data = %s, want %s", got, want)
+	}
+	if n := executor.calls.Load(); n != 1 {
+		t.Fatalf("upstream calls = %d, want 1", n)
+	}
+
+	postEmbeddings(h, `{"model":"`+model+`","input":["alpha","gamma"]}`)
+	postEmbeddings(h, `{"model":"`+model+`","input":["alpha","beta"],"dimensions":256}`)
+	if n := executor.calls.Load(); n != 3 {
+		t.Fatalf("different inputs or dimensions must miss the cache: upstream calls = %d, want 3", n)
+	}
+}
+
+func TestEmbeddings_MetricsAttributeToEmbeddingsModel(t *testing.T) {
+	const model = "embed-metrics-test-model"
+	h, executor := newEmbeddingsTestHandler(t, model, true)
+
+	for i := 0; i < 2; i++ {
+		rec := postEmbeddings(h, `{"model":"`+model+`","input":"hello"}`)
+		if rec.Code != http.StatusOK || gjson.Get(rec.Body.String(), "data.0.embedding").Raw == "" {
+			t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	if executor.calls.Load() != 1 {
+		t.Fatalf("upstream calls = %d, want 1", executor.calls.Load())
+	}
+
+	// The upstream call is recorded from its usage record; only the cache hit is recorded here.
+	exported := observability.GetMetrics().Export()
+	if !strings.Contains(exported, `requests_total{model="`+model+`",status="success"} 1`) {
+		t.Errorf("cached request not attributed to %s:\n%s", model, exported)
+	}
+}
+
+// chatOnlyExecutor hides SupportsEmbeddings, like the executors of chat-only providers.
+type chatOnlyExecutor struct{ coreauth.ProviderExecutor }
+
+func (chatOnlyExecutor) Identifier() string { return "chat-only" }
+
+func TestEmbeddings_ModelWithoutEmbeddingsProviderIsRejected(t *testing.T) {
+	const model = "embed-chat-only-test-model"
+	gin.SetMode(gin.TestMode)
+	executor := embeddingsExecutor{calls: &atomic.Int32{}, mu: &sync.Mutex{}, sent: &[]string{}, failing: &atomic.Bool{}}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(chatOnlyExecutor{executor})
+	auth := &coreauth.Auth{ID: model + "-auth", Provider: "chat-only", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: model}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+	h := NewOpenAIAPIHandler(handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager))
+
+	rec := postEmbeddings(h, `{"model":"`+model+`","input":"hello"}`)
+	if rec.Code != http.StatusNotImplemented {
+		t.Fatalf("status = %d, want 501: %s", rec.Code, rec.Body.String())
+	}
+	if executor.calls.Load() != 0 {
+		t.Fatal("embeddings request reached a chat-only provider")
+	}
+}
+
+func TestEmbeddings_RequiresModelAndInput(t *testing.T) {
+	h, executor := newEmbeddingsTestHandler(t, "embed-validation-test-model", false)
+	if rec := postEmbeddings(h, `{"model":"embed-validation-test-model"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	if executor.calls.Load() != 0 {
+		t.Fatal("invalid request reached upstream")
+	}
+}
+
+func TestEmbeddings_PartialHitFetchesOnlyMisses(t *testing.T) {
+	const model = "embed-partial-test-model"
+	h, executor := newEmbeddingsTestHandler(t, model, true)
+
+	postEmbeddings(h, `{"model":"`+model+`","input":["bb","dddd"]}`)
+	*executor.sent = nil
+
+	rec := postEmbeddings(h, `{"model":"`+model+`","input":["a","bb","ccc","dddd","eeeee"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get(handlers.CacheHeader); got != "PARTIAL" {
+		t.Fatalf("X-Cache = %q, want PARTIAL", got)
+	}
+	if got := strings.Join(*executor.sent, ","); got != "a,ccc,eeeee" {
+		t.Fatalf("upstream inputs = %s, want only the misses a,ccc,eeeee", got)
+	}
+
+	data := gjson.Get(rec.Body.String(), "data").Array()
+	if len(data) != 5 {
+		t.Fatalf("got %d embeddings, want 5: %s", len(data), rec.Body.String())
+	}
+	for i, item := range data {
+		if item.Get("index").Int() != int64(i) || item.Get("embedding.0").Int() != int64(i+1) {
+			t.Errorf("data[%d] = %s, want index %d with the vector of input %d", i, item.Raw, i, i)
+		}
+	}
+	if got := gjson.Get(rec.Body.String(), "usage.total_tokens").Int(); got != 21 {
+		t.Errorf("usage.total_tokens = %d, want 21 for the three fetched inputs", got)
+	}
+}
+
+func TestEmbeddings_CacheControlBypassesLookup(t *testing.T) {
+	const model = "embed-cache-control-test-model"
+	h, executor := newEmbeddingsTestHandler(t, model, true)
+
+	postEmbeddings(h, `{"model":"`+model+`","input":"alpha"}`)
+	rec := postEmbeddingsWithCacheControl(h, `{"model":"`+model+`","input":"alpha"}`, "no-cache")
+	if got := rec.Header().Get(handlers.CacheHeader); got != "MISS" {
+		t.Fatalf("no-cache X-Cache = %q, want MISS", got)
+	}
+	if n := executor.calls.Load(); n != 2 {
+		t.Fatalf("no-cache must call upstream: upstream calls = %d, want 2", n)
+	}
+
+	// no-cache still stores what it fetched; no-store keeps it out of the cache.
+	postEmbeddingsWithCacheControl(h, `{"model":"`+model+`","input":"beta"}`, "no-cache")
+	if got := postEmbeddings(h, `{"model":"`+model+`","input":"beta"}`).Header().Get(handlers.CacheHeader); got != "HIT" {
+		t.Fatalf("X-Cache after no-cache = %q, want HIT", got)
+	}
+	postEmbeddingsWithCacheControl(h, `{"model":"`+model+`","input":"gamma"}`, "no-store")
+	if got := postEmbeddings(h, `{"model":"`+model+`","input":"gamma"}`).Header().Get(handlers.CacheHeader); got != "MISS" {
+		t.Fatalf("X-Cache after no-store = %q, want MISS", got)
+	}
+	if n := executor.calls.Load(); n != 5 {
+		t.Fatalf("upstream calls = %d, want 5", n)
+	}
+}
+
+// lruEmbeddingsStore serves embeddings from a private LRU cache so tests control its TTL.
+type lruEmbeddingsStore struct {
+	lru *cache.LRUCache
+}
+
+func (s lruEmbeddingsStore) Get(model, key string) ([]byte, bool) {
+	value := s.lru.Get(cache.HashKey(model, key))
+	return value, value != nil
+}
+
+func (s lruEmbeddingsStore) Set(model, key string, value []byte) {
+	s.lru.Set(cache.HashKey(model, key), value)
+}
+
+func (s lruEmbeddingsStore) GetStale(model, key string) ([]byte, bool, bool) {
+	return s.lru.GetStale(cache.HashKey(model, key))
+}
+
+// newStaleEmbeddingsTestHandler serves embeddings with serve-stale-on-error from a cache
+// whose entries expire after 20ms and stay servable as stale for 200ms more.
+func newStaleEmbeddingsTestHandler(t *testing.T, model string) (*OpenAIAPIHandler, embeddingsExecutor) {
+	t.Helper()
+	lru := cache.NewLRUCache(100, 20*time.Millisecond)
+	lru.SetStaleGrace(200 * time.Millisecond)
+	previous := embeddingsCache
+	embeddingsCache = func() embeddingsStore { return lruEmbeddingsStore{lru: lru} }
+	t.Cleanup(func() {
+		embeddingsCache = previous
+		lru.Close()
+	})
+
+	h, executor := newEmbeddingsTestHandler(t, model, true)
+	h.Cfg.Cache.ServeStaleOnError = true
+	return h, executor
+}
+
+func TestEmbeddings_ServeStaleOnError_FreshHit(t *testing.T) {
+	const model = "embed-stale-fresh-test-model"
+	h, executor := newStaleEmbeddingsTestHandler(t, model)
+
+	postEmbeddings(h, `{"model":"`+model+`","input":"alpha"}`)
+	executor.failing.Store(true)
+	rec := postEmbeddings(h, `{"model":"`+model+`","input":"alpha"}`)
+	if rec.Code != http.StatusOK || rec.Header().Get(handlers.CacheHeader) != "HIT" {
+		t.Fatalf("status = %d, X-Cache = %q, want a fresh HIT", rec.Code, rec.Header().Get(handlers.CacheHeader))
+	}
+	if got := rec.Header().Get("Warning"); got != "" {
+		t.Fatalf("fresh hit carries Warning %q", got)
+	}
+	if n := executor.calls.Load(); n != 1 {
+		t.Fatalf("upstream calls = %d, want 1", n)
+	}
+}
+
+func TestEmbeddings_ServeStaleOnError_WithinGrace(t *testing.T) {
+	const model = "embed-stale-grace-test-model"
+	h, executor := newStaleEmbeddingsTestHandler(t, model)
+
+	first := postEmbeddings(h, `{"model":"`+model+`","input":["alpha","beta"]}`)
+	time.Sleep(60 * time.Millisecond)
+	executor.failing.Store(true)
+
+	rec := postEmbeddings(h, `{"model":"`+model+`","input":["alpha","beta"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get(handlers.CacheHeader); got != "STALE" {
+		t.Fatalf("X-Cache = %q, want STALE", got)
+	}
+	if got := rec.Header().Get("Warning"); got != handlers.StaleWarning {
+		t.Fatalf("Warning = %q, want %q", got, handlers.StaleWarning)
+	}
+	if got, want := gjson.Get(rec.Body.String(), "data").Raw, gjson.Get(first.Body.String(), "data").Raw; got != want {
+		t.Fatalf("stale data = %s, want %s", got, want)
+	}
+	if n := executor.calls.Load(); n < 2 {
+		t.Fatalf("upstream calls = %d, want the expired entry refetched first", n)
+	}
+}
+
+func TestEmbeddings_ServeStaleOnError_BeyondGrace(t *testing.T) {
+	const model = "embed-stale-expired-test-model"
+	h, executor := newStaleEmbeddingsTestHandler(t, model)
+
+	postEmbeddings(h, `{"model":"`+model+`","input":"alpha"}`)
+	time.Sleep(300 * time.Millisecond)
+	executor.failing.Store(true)
+
+	rec := postEmbeddings(h, `{"model":"`+model+`","input":"alpha"}`)
+	if rec.Code == http.StatusOK {
+		t.Fatalf("status = 200, want the upstream error once the grace window has passed; body %s", rec.Body.String())
+	}
+	if got := rec.Header().Get(handlers.CacheHeader); got == "STALE" {
+		t.Fatal("served a stale response past its grace window")
+	}
+}
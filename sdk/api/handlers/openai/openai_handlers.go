@@ -62,7 +62,7 @@ func (h *OpenAIAPIHandler) OpenAIModels(c *gin.Context) {
 	// Get all available models
 	allModels := h.Models()
 
-	// Filter to only include the 4 required fields: id, object, created, owned_by
+	// Filter to the required fields (id, object, created, owned_by) plus capability flags
 	filteredModels := make([]map[string]any, len(allModels))
 	for i, model := range allModels {
 		filteredModel := map[string]any{
@@ -80,6 +80,17 @@ func (h *OpenAIAPIHandler) OpenAIModels(c *gin.Context) {
 			filteredModel["owned_by"] = ownedBy
 		}
 
+		// Add capability flags so clients can feature-detect
+		if id, ok := model["id"].(string); ok {
+			caps := h.modelCapabilities(id)
+			filteredModel["supports_tools"] = caps.SupportsTools
+			filteredModel["supports_streaming"] = caps.SupportsStreaming
+			filteredModel["supports_reasoning"] = caps.SupportsReasoning
+			if caps.ContextWindow > 0 {
+				filteredModel["context_window"] = caps.ContextWindow
+			}
+		}
+
 		filteredModels[i] = filteredModel
 	}
 
@@ -89,6 +100,18 @@ func (h *OpenAIAPIHandler) OpenAIModels(c *gin.Context) {
 	})
 }
 
+// modelCapabilities resolves capability flags for a model, preferring the
+// configured context.model-limits entry for the context window.
+func (h *OpenAIAPIHandler) modelCapabilities(modelID string) registry.ModelCapabilities {
+	caps := registry.LookupModelCapabilities(modelID)
+	if h.Cfg != nil {
+		if limit, ok := h.Cfg.Context.ModelLimits[modelID]; ok && limit > 0 {
+			caps.ContextWindow = limit
+		}
+	}
+	return caps
+}
+
 // ChatCompletions handles the /v1/chat/completions endpoint.
 // It determines whether the request is for a streaming or non-streaming response
 // and calls the appropriate handler based on the model provider.
@@ -110,11 +133,21 @@ func (h *OpenAIAPIHandler) ChatCompletions(c *gin.Context) {
 
 	agentCfg, cleaned := parseAgenticConfig(rawJSON)
 	rawJSON = cleaned
+	agentCfg.applyServerDefaults(h.Cfg)
 
 	// Check if the client requested a streaming response.
 	streamResult := gjson.GetBytes(rawJSON, "stream")
 	stream := streamResult.Type == gjson.True
 
+	// Oversized conversations are rejected before the Responses conversion below. Dry runs
+	// report the violation instead.
+	if !handlers.IsDryRun(c) {
+		if errMsg := h.CheckMessageLimit(gjson.GetBytes(rawJSON, "model").String(), rawJSON); errMsg != nil {
+			h.WriteErrorResponse(c, errMsg)
+			return
+		}
+	}
+
 	// Some clients send OpenAI Responses-format payloads to /v1/chat/completions.
 	// Convert them to Chat Completions so downstream translators preserve tool metadata.
 	if shouldTreatAsResponsesFormat(rawJSON) {
@@ -123,6 +156,11 @@ func (h *OpenAIAPIHandler) ChatCompletions(c *gin.Context) {
 		stream = gjson.GetBytes(rawJSON, "stream").Bool()
 	}
 
+	if handlers.IsDryRun(c) {
+		c.JSON(http.StatusOK, h.DryRun(c.Request.Context(), rawJSON))
+		return
+	}
+
 	if agentCfg.Enabled {
 		if stream {
 			h.handleAgenticStreamingResponse(c, rawJSON, agentCfg)
@@ -451,7 +489,9 @@ func (h *OpenAIAPIHandler) handleNonStreamingResponse(c *gin.Context, rawJSON []
 
 	modelName := gjson.GetBytes(rawJSON, "model").String()
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	stopKeepAlive := h.StartNonStreamingKeepAlive(c, cliCtx)
 	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, h.GetAlt(c))
+	stopKeepAlive()
 	if errMsg != nil {
 		h.WriteErrorResponse(c, errMsg)
 		cliCancel(errMsg.Error)
@@ -542,6 +582,7 @@ func (h *OpenAIAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON []byt
 			// Success! Commit to streaming headers.
 			h.setSSEHeaders(c)
 
+			handlers.WriteSSEEventFields(c, chunk)
 			_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", string(chunk))
 			flusher.Flush()
 
@@ -640,6 +681,7 @@ func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, ra
 			// Write the first chunk
 			converted := convertChatCompletionsStreamChunkToCompletions(chunk)
 			if converted != nil {
+				handlers.WriteSSEEventFields(c, converted)
 				_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", string(converted))
 				flusher.Flush()
 			}
@@ -683,6 +725,7 @@ func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, ra
 func (h *OpenAIAPIHandler) handleStreamResult(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
 	h.ForwardStream(c, flusher, cancel, data, errs, handlers.StreamForwardOptions{
 		WriteChunk: func(chunk []byte) {
+			handlers.WriteSSEEventFields(c, chunk)
 			_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", string(chunk))
 		},
 		WriteTerminalError: func(errMsg *interfaces.ErrorMessage) {
@@ -703,5 +746,8 @@ func (h *OpenAIAPIHandler) handleStreamResult(c *gin.Context, flusher http.Flush
 		WriteDone: func() {
 			_, _ = fmt.Fprint(c.Writer, "data: [DONE]\n\n")
 		},
+		WriteSyntheticFinish: func() {
+			_, _ = fmt.Fprint(c.Writer, "data: {\"object\":\"chat.completion.chunk\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		},
 	})
 }
@@ -0,0 +1,65 @@
+package openai
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestOpenAIModels_ReportsCapabilityFlags(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const modelID = "claude-opus-4-5-capabilities-test"
+	registry.GetGlobalRegistry().RegisterClient("capabilities-test", "claude", []*registry.ModelInfo{{
+		ID:       modelID,
+		Object:   "model",
+		OwnedBy:  "anthropic",
+		Thinking: &registry.ThinkingSupport{Min: 1024, Max: 100000},
+	}})
+	t.Cleanup(func() {
+		registry.GetGlobalRegistry().UnregisterClient("capabilities-test")
+	})
+
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Context.ModelLimits = map[string]int64{modelID: 123456}
+	h := NewOpenAIAPIHandler(handlers.NewBaseAPIHandlers(cfg, nil))
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	h.OpenAIModels(c)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var entry gjson.Result
+	gjson.GetBytes(rec.Body.Bytes(), "data").ForEach(func(_, value gjson.Result) bool {
+		if value.Get("id").String() == modelID {
+			entry = value
+			return false
+		}
+		return true
+	})
+	if !entry.Exists() {
+		t.Fatalf("model %s missing from response: %s", modelID, rec.Body.String())
+	}
+	if !entry.Get("supports_reasoning").Bool() {
+		t.Errorf("supports_reasoning = false, want true")
+	}
+	if !entry.Get("supports_tools").Bool() {
+		t.Errorf("supports_tools = false, want true")
+	}
+	if !entry.Get("supports_streaming").Bool() {
+		t.Errorf("supports_streaming = false, want true")
+	}
+	if got := entry.Get("context_window").Int(); got != 123456 {
+		t.Errorf("context_window = %d, want 123456", got)
+	}
+}
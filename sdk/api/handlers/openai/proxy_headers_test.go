@@ -0,0 +1,109 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/middleware"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// streamingChatExecutor answers chat requests like countingChatExecutor and streams one chunk.
+type streamingChatExecutor struct {
+	countingChatExecutor
+}
+
+func (streamingChatExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	chunks := make(chan coreexecutor.StreamChunk, 1)
+	chunks <- coreexecutor.StreamChunk{Payload: []byte(`{"object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"hi"}}]}`)}
+	close(chunks)
+	return chunks, nil
+}
+
+func newProxyHeadersEngine(t *testing.T, model string, enabled bool) *gin.Engine {
+	t.Helper()
+	h, _ := newCachePrimeTestHandler(t, model)
+	h.AuthManager.RegisterExecutor(streamingChatExecutor{countingChatExecutor{calls: &atomic.Int32{}}})
+
+	engine := gin.New()
+	engine.Use(middleware.ProxyHeadersMiddleware(func() bool { return enabled }))
+	engine.POST("/v1/chat/completions", h.ChatCompletions)
+	engine.POST("/v1/cache/prime", h.CachePrime)
+	return engine
+}
+
+func serveChat(engine *gin.Engine, body string) *httptest.ResponseRecorder {
+	return servePath(engine, "/v1/chat/completions", body)
+}
+
+func servePath(engine *gin.Engine, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	engine.ServeHTTP(rec, req)
+	return rec
+}
+
+func assertProxyHeaders(t *testing.T, name string, rec *httptest.ResponseRecorder, provider, model, cache string) {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s: status = %d, body %s", name, rec.Code, rec.Body.String())
+	}
+	for header, want := range map[string]string{
+		middleware.ProxyProviderHeader: provider,
+		middleware.ProxyModelHeader:    model,
+		middleware.ProxyCacheHeader:    cache,
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Fatalf("%s: %s = %q, want %q", name, header, got, want)
+		}
+	}
+	if _, err := strconv.Atoi(rec.Header().Get(middleware.ProxyLatencyMsHeader)); err != nil {
+		t.Fatalf("%s: %s = %q, want milliseconds", name, middleware.ProxyLatencyMsHeader, rec.Header().Get(middleware.ProxyLatencyMsHeader))
+	}
+}
+
+func TestProxyHeaders_LiveAndCachedResponses(t *testing.T) {
+	const model = "proxy-headers-model"
+	engine := newProxyHeadersEngine(t, model, true)
+	// The cache is process-wide, so every run sends a prompt of its own.
+	body := fmt.Sprintf(`{"model":%q,"messages":[{"role":"user","content":"headers %d"}]}`, model, time.Now().UnixNano())
+
+	assertProxyHeaders(t, "live", serveChat(engine, body), "codex", model, "MISS")
+	if rec := servePath(engine, "/v1/cache/prime", body); rec.Code != http.StatusOK {
+		t.Fatalf("prime status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	assertProxyHeaders(t, "cached", serveChat(engine, body), "", "", "HIT")
+}
+
+func TestProxyHeaders_StreamingResponseCarriesHeaders(t *testing.T) {
+	const model = "proxy-headers-stream-model"
+	engine := newProxyHeadersEngine(t, model, true)
+
+	rec := serveChat(engine, `{"model":"`+model+`","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	if !strings.Contains(rec.Body.String(), `"content":"hi"`) {
+		t.Fatalf("stream body = %s, want the streamed chunk", rec.Body.String())
+	}
+	assertProxyHeaders(t, "streaming", rec, "codex", model, "BYPASS")
+}
+
+func TestProxyHeaders_Disabled(t *testing.T) {
+	const model = "proxy-headers-off-model"
+	engine := newProxyHeadersEngine(t, model, false)
+
+	rec := serveChat(engine, `{"model":"`+model+`","messages":[{"role":"user","content":"off"}]}`)
+	for _, header := range []string{middleware.ProxyProviderHeader, middleware.ProxyModelHeader, middleware.ProxyCacheHeader, middleware.ProxyLatencyMsHeader} {
+		if got := rec.Header().Get(header); got != "" {
+			t.Fatalf("%s = %q while proxy headers are disabled", header, got)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// recordedStreamExecutor streams a short completion ending in a finish_reason and counts
+// the streams it opens.
+type recordedStreamExecutor struct {
+	countingChatExecutor
+}
+
+func (e recordedStreamExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	e.calls.Add(1)
+	chunks := make(chan coreexecutor.StreamChunk, 3)
+	chunks <- coreexecutor.StreamChunk{Payload: []byte(`{"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello"}}]}`)}
+	chunks <- coreexecutor.StreamChunk{Payload: []byte(`{"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":", world"}}]}`)}
+	chunks <- coreexecutor.StreamChunk{Payload: []byte(`{"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`)}
+	close(chunks)
+	return chunks, nil
+}
+
+func TestStreamCache_ReplaysIdenticalStream(t *testing.T) {
+	const model = "stream-cache-model"
+	h, _ := newCachePrimeTestHandler(t, model)
+	h.Cfg.Cache.StreamingCache.Enabled = true
+	calls := &atomic.Int32{}
+	h.AuthManager.RegisterExecutor(recordedStreamExecutor{countingChatExecutor{calls: calls}})
+
+	engine := gin.New()
+	engine.POST("/v1/chat/completions", h.ChatCompletions)
+	// The cache is process-wide, so every run streams a prompt of its own.
+	body := fmt.Sprintf(`{"model":%q,"stream":true,"messages":[{"role":"user","content":"stream %d"}]}`, model, time.Now().UnixNano())
+
+	live := serveChat(engine, body)
+	if got := live.Header().Get(handlers.CacheHeader); got != "MISS" {
+		t.Fatalf("first stream X-Cache = %q, want MISS", got)
+	}
+	if !strings.Contains(live.Body.String(), `"finish_reason":"stop"`) {
+		t.Fatalf("first stream body = %s, want the generated events", live.Body.String())
+	}
+
+	replayed := serveChat(engine, body)
+	if got := replayed.Header().Get(handlers.CacheHeader); got != "HIT" {
+		t.Fatalf("second stream X-Cache = %q, want HIT", got)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("upstream streams = %d, want 1 (the second request replays the cache)", calls.Load())
+	}
+	if replayed.Body.String() != live.Body.String() {
+		t.Fatalf("replayed events differ from the generated stream:\nlive:     %q\nreplayed: %q", live.Body.String(), replayed.Body.String())
+	}
+
+	bypass := serveChat(engine, strings.Replace(body, `stream `, `other stream `, 1))
+	if got := bypass.Header().Get(handlers.CacheHeader); got != "MISS" || calls.Load() != 2 {
+		t.Fatalf("different request: X-Cache = %q after %d upstream streams, want MISS after 2", got, calls.Load())
+	}
+}
@@ -0,0 +1,515 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/net/context"
+)
+
+const (
+	// defaultOutputLookahead is the number of bytes of generated text held back from a stream
+	// so that a policy match spanning chunk boundaries is caught before it is emitted.
+	defaultOutputLookahead = 64
+	// defaultOutputReplacement replaces redacted spans of generated text.
+	defaultOutputReplacement = "[REDACTED]"
+)
+
+// outputTextKeys are the JSON keys whose string values carry generated text in the
+// OpenAI, Responses, Claude, and Gemini response formats.
+var outputTextKeys = map[string]bool{"content": true, "text": true, "delta": true}
+
+// TextRange is a half-open byte range [Start, End) within generated text.
+type TextRange struct {
+	Start int
+	End   int
+}
+
+// OutputGuardrailRequest is the generated text an output guardrail inspects.
+type OutputGuardrailRequest struct {
+	// HandlerType is the response format (openai, claude, gemini, ...).
+	HandlerType string
+	// Model is the model the client requested.
+	Model string
+	// Text is the buffered text of a stream, or the full text of a non-streaming response.
+	Text string
+	// Final reports whether no more text will follow.
+	Final bool
+}
+
+// OutputGuardrailDecision is an output guardrail's verdict on generated text.
+type OutputGuardrailDecision struct {
+	// Block stops the response; nothing that has not been emitted yet reaches the client.
+	Block bool
+	// Reason is shown to the client when the response is blocked.
+	Reason string
+	// Redactions are replaced with the configured replacement text before emission.
+	Redactions []TextRange
+	// Annotations are recorded in the request's audit metadata with a "guardrail_" prefix.
+	Annotations map[string]string
+}
+
+// OutputGuardrailFunc screens generated text before it is returned to the client.
+// Returning an error stops the response.
+type OutputGuardrailFunc func(ctx context.Context, req OutputGuardrailRequest) (OutputGuardrailDecision, error)
+
+// AddOutputGuardrail registers fn to screen every response this handler returns.
+func (h *BaseAPIHandler) AddOutputGuardrail(fn OutputGuardrailFunc) {
+	if fn != nil {
+		h.OutputGuardrails = append(h.OutputGuardrails, fn)
+	}
+}
+
+// NewRegexOutputGuardrail builds an output guardrail from regex rules. A matching "block"
+// rule stops the response, "redact" rules replace the matched text, and "flag" rules only annotate.
+func NewRegexOutputGuardrail(rules []config.GuardrailRule) (OutputGuardrailFunc, error) {
+	type compiledRule struct {
+		config.GuardrailRule
+		re *regexp.Regexp
+	}
+	compiled := make([]compiledRule, 0, len(rules))
+	for i, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("output guardrail rule %d (%s): %w", i, rule.Name, err)
+		}
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i)
+		}
+		compiled = append(compiled, compiledRule{GuardrailRule: rule, re: re})
+	}
+
+	return func(_ context.Context, req OutputGuardrailRequest) (OutputGuardrailDecision, error) {
+		var decision OutputGuardrailDecision
+		var flagged []string
+		for _, rule := range compiled {
+			matches := rule.re.FindAllStringIndex(req.Text, -1)
+			if len(matches) == 0 {
+				continue
+			}
+			flagged = append(flagged, rule.Name)
+			switch strings.ToLower(rule.Action) {
+			case "flag":
+			case "redact":
+				for _, m := range matches {
+					decision.Redactions = append(decision.Redactions, TextRange{Start: m[0], End: m[1]})
+				}
+			default:
+				decision.Block = true
+				decision.Reason = rule.Message
+				if decision.Reason == "" {
+					decision.Reason = fmt.Sprintf("response matched guardrail rule %s", rule.Name)
+				}
+			}
+			if decision.Block {
+				break
+			}
+		}
+		if len(flagged) > 0 {
+			decision.Annotations = map[string]string{"output_rules": strings.Join(flagged, ",")}
+		}
+		return decision, nil
+	}, nil
+}
+
+// newConfigOutputGuardrail compiles the configured regex output guardrail. Invalid rules are logged and disable it.
+func newConfigOutputGuardrail(cfg *config.SDKConfig) OutputGuardrailFunc {
+	if cfg == nil || !cfg.Guardrails.Output.Enabled || len(cfg.Guardrails.Output.Rules) == 0 {
+		return nil
+	}
+	fn, err := NewRegexOutputGuardrail(cfg.Guardrails.Output.Rules)
+	if err != nil {
+		log.Errorf("failed to compile output guardrail rules: %v", err)
+		return nil
+	}
+	return fn
+}
+
+// newOutputFilter returns a filter for one response, or nil when no output guardrail is active.
+func (h *BaseAPIHandler) newOutputFilter(ctx context.Context, handlerType, modelName string) *outputFilter {
+	if h == nil || (h.configOutputGuardrail == nil && len(h.OutputGuardrails) == 0) {
+		return nil
+	}
+	f := &outputFilter{
+		ctx:         ctx,
+		handlerType: handlerType,
+		model:       modelName,
+		lookahead:   defaultOutputLookahead,
+		replacement: defaultOutputReplacement,
+	}
+	if h.configOutputGuardrail != nil {
+		f.guardrails = append(f.guardrails, h.configOutputGuardrail)
+	}
+	f.guardrails = append(f.guardrails, h.OutputGuardrails...)
+	if h.Cfg != nil {
+		if n := h.Cfg.Guardrails.Output.LookaheadBytes; n > 0 {
+			f.lookahead = n
+		}
+		if r := h.Cfg.Guardrails.Output.Replacement; r != "" {
+			f.replacement = r
+		}
+	}
+	if ctx != nil {
+		f.ginCtx, _ = ctx.Value("gin").(*gin.Context)
+	}
+	return f
+}
+
+// filterResponse applies the output guardrails to a complete non-streaming response.
+func (h *BaseAPIHandler) filterResponse(ctx context.Context, handlerType, modelName string, body []byte) ([]byte, *interfaces.ErrorMessage) {
+	f := h.newOutputFilter(ctx, handlerType, modelName)
+	if f == nil || len(body) == 0 {
+		return body, nil
+	}
+	f.pending = append(f.pending, parseOutputChunk(body))
+	out, errMsg := f.flush()
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	return bytes.Join(out, nil), nil
+}
+
+// filterStream applies the output guardrails to a stream, holding back chunks until
+// enough text follows them to rule out a match that starts inside them.
+func (h *BaseAPIHandler) filterStream(ctx context.Context, handlerType, modelName string, data <-chan []byte, errs <-chan *interfaces.ErrorMessage) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	f := h.newOutputFilter(ctx, handlerType, modelName)
+	if f == nil || data == nil {
+		return data, errs
+	}
+
+	dataChan := make(chan []byte)
+	errChan := make(chan *interfaces.ErrorMessage, 1)
+	go func() {
+		defer close(dataChan)
+		defer close(errChan)
+		var done <-chan struct{}
+		if ctx != nil {
+			done = ctx.Done()
+		}
+		send := func(chunks [][]byte) bool {
+			for _, chunk := range chunks {
+				select {
+				case dataChan <- chunk:
+				case <-done:
+					return false
+				}
+			}
+			return true
+		}
+
+		for chunk := range data {
+			out, errMsg := f.push(chunk)
+			if !send(out) {
+				return
+			}
+			if errMsg != nil {
+				errChan <- errMsg
+				// Release the upstream producer; the rest of the response is discarded.
+				go func() {
+					for range data {
+					}
+				}()
+				return
+			}
+		}
+		out, errMsg := f.flush()
+		if !send(out) {
+			return
+		}
+		if errMsg != nil {
+			errChan <- errMsg
+			return
+		}
+		if errs != nil {
+			for errMsg := range errs {
+				if errMsg != nil {
+					errChan <- errMsg
+					return
+				}
+			}
+		}
+	}()
+	return dataChan, errChan
+}
+
+// outputFilter buffers the chunks of one response and applies output guardrails to their text.
+type outputFilter struct {
+	ctx         context.Context
+	ginCtx      *gin.Context
+	handlerType string
+	model       string
+	guardrails  []OutputGuardrailFunc
+	lookahead   int
+	replacement string
+	pending     []*outputChunk
+}
+
+// push buffers chunk and returns the chunks that can now be emitted safely.
+func (f *outputFilter) push(chunk []byte) ([][]byte, *interfaces.ErrorMessage) {
+	f.pending = append(f.pending, parseOutputChunk(chunk))
+
+	// Emit every chunk whose text is followed by at least lookahead bytes of buffered text.
+	total := 0
+	for _, c := range f.pending {
+		total += c.textLen()
+	}
+	emit, boundary, seen := 0, 0, 0
+	for i, c := range f.pending {
+		seen += c.textLen()
+		if total-seen < f.lookahead {
+			break
+		}
+		emit, boundary = i+1, seen
+	}
+	if errMsg := f.screen(false, boundary); errMsg != nil {
+		return nil, errMsg
+	}
+	return f.take(emit), nil
+}
+
+// flush screens and returns everything still buffered.
+func (f *outputFilter) flush() ([][]byte, *interfaces.ErrorMessage) {
+	if errMsg := f.screen(true, -1); errMsg != nil {
+		return nil, errMsg
+	}
+	return f.take(len(f.pending)), nil
+}
+
+func (f *outputFilter) take(n int) [][]byte {
+	out := make([][]byte, 0, n)
+	for _, c := range f.pending[:n] {
+		out = append(out, c.encode())
+	}
+	f.pending = f.pending[n:]
+	return out
+}
+
+// screen runs the guardrails over the buffered text. Redactions starting before boundary
+// (or anywhere when boundary < 0) are applied; later ones are left until more text arrives.
+func (f *outputFilter) screen(final bool, boundary int) *interfaces.ErrorMessage {
+	var segments []*string
+	var sb strings.Builder
+	for _, c := range f.pending {
+		for i := range c.fields {
+			segments = append(segments, &c.fields[i].text)
+			sb.WriteString(c.fields[i].text)
+		}
+	}
+	text := sb.String()
+	if text == "" && !final {
+		return nil
+	}
+
+	var redactions []TextRange
+	for _, guardrail := range f.guardrails {
+		decision, err := guardrail(f.ctx, OutputGuardrailRequest{HandlerType: f.handlerType, Model: f.model, Text: text, Final: final})
+		if err != nil {
+			log.Warnf("output guardrail failed for model %s: %v", f.model, err)
+			return guardrailError(http.StatusInternalServerError, "server_error", "output_blocked", "response could not be screened by guardrail")
+		}
+		for key, value := range decision.Annotations {
+			SetAuditMetadata(f.ginCtx, "guardrail_"+key, value)
+		}
+		if decision.Block {
+			SetAuditMetadata(f.ginCtx, "guardrail_output_blocked", "true")
+			reason := decision.Reason
+			if reason == "" {
+				reason = "response blocked by guardrail"
+			}
+			return guardrailError(http.StatusBadRequest, "invalid_request_error", "output_blocked", reason)
+		}
+		for _, r := range decision.Redactions {
+			if r.Start < 0 || r.End > len(text) || r.Start >= r.End {
+				continue
+			}
+			if boundary < 0 || r.Start < boundary {
+				redactions = append(redactions, r)
+			}
+		}
+	}
+	if len(redactions) == 0 {
+		return nil
+	}
+	SetAuditMetadata(f.ginCtx, "guardrail_output_redacted", "true")
+	redactSegments(segments, mergeRanges(redactions), f.replacement)
+	for _, c := range f.pending {
+		c.dirty = true
+	}
+	return nil
+}
+
+// mergeRanges sorts ranges and coalesces overlapping ones.
+func mergeRanges(ranges []TextRange) []TextRange {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.Start <= last.End {
+			if r.End > last.End {
+				last.End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// redactSegments rewrites text split across segments, replacing each range with
+// replacement in the segment where the range starts and dropping the rest of it.
+func redactSegments(segments []*string, ranges []TextRange, replacement string) {
+	start := 0
+	for _, seg := range segments {
+		text := *seg
+		end := start + len(text)
+		var sb strings.Builder
+		cursor := start
+		for _, r := range ranges {
+			if r.End <= start || r.Start >= end {
+				continue
+			}
+			if r.Start > cursor {
+				sb.WriteString(text[cursor-start : r.Start-start])
+			}
+			if r.Start >= start {
+				sb.WriteString(replacement)
+			}
+			cursor = min(r.End, end)
+		}
+		sb.WriteString(text[cursor-start:])
+		*seg = sb.String()
+		start = end
+	}
+}
+
+// outputChunk is one response chunk split into literal bytes and JSON payloads, with
+// the generated-text fields of those payloads extracted for screening.
+type outputChunk struct {
+	raw    []byte
+	parts  []outputPart
+	fields []outputField
+	dirty  bool
+}
+
+type outputPart struct {
+	data   []byte
+	isJSON bool
+}
+
+type outputField struct {
+	part int
+	path string
+	text string
+}
+
+// parseOutputChunk extracts the text fields of a raw JSON chunk or of the data lines of an SSE chunk.
+func parseOutputChunk(chunk []byte) *outputChunk {
+	c := &outputChunk{raw: chunk}
+	trimmed := bytes.TrimSpace(chunk)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		c.addJSON(chunk)
+		return c
+	}
+	for _, line := range bytes.SplitAfter(chunk, []byte("\n")) {
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			c.parts = append(c.parts, outputPart{data: line})
+			continue
+		}
+		payload := bytes.TrimLeft(line[len("data:"):], " ")
+		body := bytes.TrimRight(payload, "\r\n")
+		if !gjson.ValidBytes(body) {
+			c.parts = append(c.parts, outputPart{data: line})
+			continue
+		}
+		c.parts = append(c.parts, outputPart{data: line[:len(line)-len(payload)]})
+		c.addJSON(body)
+		c.parts = append(c.parts, outputPart{data: payload[len(body):]})
+	}
+	return c
+}
+
+func (c *outputChunk) addJSON(data []byte) {
+	if !gjson.ValidBytes(data) {
+		c.parts = append(c.parts, outputPart{data: data})
+		return
+	}
+	part := len(c.parts)
+	c.parts = append(c.parts, outputPart{data: data, isJSON: true})
+	collectOutputText(gjson.ParseBytes(data), "", func(path, text string) {
+		c.fields = append(c.fields, outputField{part: part, path: path, text: text})
+	})
+}
+
+func (c *outputChunk) textLen() int {
+	n := 0
+	for _, field := range c.fields {
+		n += len(field.text)
+	}
+	return n
+}
+
+// encode returns the chunk with any redacted text written back into its JSON payloads.
+func (c *outputChunk) encode() []byte {
+	if !c.dirty {
+		return c.raw
+	}
+	for _, field := range c.fields {
+		if updated, err := sjson.SetBytes(c.parts[field.part].data, field.path, field.text); err == nil {
+			c.parts[field.part].data = updated
+		}
+	}
+	var buf bytes.Buffer
+	for _, part := range c.parts {
+		buf.Write(part.data)
+	}
+	return buf.Bytes()
+}
+
+// collectOutputText walks value in document order and reports every string stored under a generated-text key.
+func collectOutputText(value gjson.Result, prefix string, fn func(path, text string)) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	switch {
+	case value.IsObject():
+		value.ForEach(func(key, item gjson.Result) bool {
+			path := join(escapeOutputPath(key.String()))
+			if item.Type == gjson.String && outputTextKeys[key.String()] {
+				fn(path, item.String())
+			} else {
+				collectOutputText(item, path, fn)
+			}
+			return true
+		})
+	case value.IsArray():
+		for i, item := range value.Array() {
+			collectOutputText(item, join(strconv.Itoa(i)), fn)
+		}
+	}
+}
+
+func escapeOutputPath(key string) string {
+	var sb strings.Builder
+	for _, r := range key {
+		switch r {
+		case '.', '*', '?', '|', '#', '@', '\\', ':':
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+// scriptedStreamExecutor replays fixed chunks for streaming requests and a fixed body otherwise.
+type scriptedStreamExecutor struct {
+	body   []byte
+	chunks [][]byte
+}
+
+func (e *scriptedStreamExecutor) Identifier() string { return "codex" }
+
+func (e *scriptedStreamExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{Payload: e.body}, nil
+}
+
+func (e *scriptedStreamExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	ch := make(chan coreexecutor.StreamChunk, len(e.chunks))
+	for _, chunk := range e.chunks {
+		ch <- coreexecutor.StreamChunk{Payload: chunk}
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (e *scriptedStreamExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *scriptedStreamExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (e *scriptedStreamExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func newOutputGuardrailTestHandler(t *testing.T, executor *scriptedStreamExecutor, rules ...sdkconfig.GuardrailRule) *BaseAPIHandler {
+	t.Helper()
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "output-guardrail-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "moderated-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Guardrails.Output.Enabled = true
+	cfg.Guardrails.Output.Rules = rules
+	return NewBaseAPIHandlers(cfg, manager)
+}
+
+func drainFilteredStream(data <-chan []byte, errs <-chan *interfaces.ErrorMessage) ([][]byte, *interfaces.ErrorMessage) {
+	var chunks [][]byte
+	for chunk := range data {
+		chunks = append(chunks, chunk)
+	}
+	var errMsg *interfaces.ErrorMessage
+	for e := range errs {
+		if e != nil && errMsg == nil {
+			errMsg = e
+		}
+	}
+	return chunks, errMsg
+}
+
+func openAIDelta(text string) []byte {
+	return []byte(`{"choices":[{"index":0,"delta":{"content":"` + text + `"}}]}`)
+}
+
+func TestOutputGuardrail_CleanStreamPassesThroughUnchanged(t *testing.T) {
+	chunks := [][]byte{openAIDelta("Paris is "), openAIDelta("the capital "), openAIDelta("of France.")}
+	executor := &scriptedStreamExecutor{chunks: chunks}
+	handler := newOutputGuardrailTestHandler(t, executor, sdkconfig.GuardrailRule{Name: "secret", Pattern: `top-secret`})
+
+	got, errMsg := drainFilteredStream(handler.ExecuteStreamWithAuthManager(context.Background(), "openai", "moderated-model", []byte(`{"model":"moderated-model"}`), ""))
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	if len(got) != len(chunks) {
+		t.Fatalf("got %d chunks, want %d", len(got), len(chunks))
+	}
+	for i := range chunks {
+		if string(got[i]) != string(chunks[i]) {
+			t.Fatalf("chunk %d = %s, want %s", i, got[i], chunks[i])
+		}
+	}
+}
+
+func TestOutputGuardrail_BlocksPhraseSplitAcrossChunks(t *testing.T) {
+	executor := &scriptedStreamExecutor{chunks: [][]byte{
+		openAIDelta("Sure. The code is top"),
+		openAIDelta("-sec"),
+		openAIDelta("ret and more"),
+	}}
+	handler := newOutputGuardrailTestHandler(t, executor, sdkconfig.GuardrailRule{Name: "secret", Pattern: `top-secret`, Message: "response withheld"})
+
+	got, errMsg := drainFilteredStream(handler.ExecuteStreamWithAuthManager(context.Background(), "openai", "moderated-model", []byte(`{"model":"moderated-model"}`), ""))
+	if errMsg == nil || errMsg.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected the stream to be blocked, got %+v", errMsg)
+	}
+	if !strings.Contains(errMsg.Error.Error(), "output_blocked") || !strings.Contains(errMsg.Error.Error(), "response withheld") {
+		t.Fatalf("unexpected error body: %v", errMsg.Error)
+	}
+	for _, chunk := range got {
+		if strings.Contains(string(chunk), "top") {
+			t.Fatalf("part of the blocked phrase was emitted: %s", chunk)
+		}
+	}
+}
+
+func TestOutputGuardrail_RedactsPhraseSplitAcrossSSEChunks(t *testing.T) {
+	executor := &scriptedStreamExecutor{}
+	handler := newOutputGuardrailTestHandler(t, executor, sdkconfig.GuardrailRule{Name: "key", Pattern: `sk-[a-z0-9]+`, Action: "redact"})
+	handler.Cfg.Guardrails.Output.LookaheadBytes = 16
+
+	claudeDelta := func(text string) []byte {
+		return []byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"" + text + "\"}}\n\n")
+	}
+	data := make(chan []byte, 4)
+	for _, text := range []string{"Your key is sk-ab", "c123", " keep it safe, it is important."} {
+		data <- claudeDelta(text)
+	}
+	close(data)
+
+	got, errMsg := drainFilteredStream(handler.filterStream(context.Background(), "claude", "moderated-model", data, nil))
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	var text strings.Builder
+	for _, chunk := range got {
+		if !strings.HasPrefix(string(chunk), "event: content_block_delta\ndata: {") || !strings.HasSuffix(string(chunk), "}\n\n") {
+			t.Fatalf("SSE framing not preserved: %q", chunk)
+		}
+		payload := strings.TrimSuffix(strings.TrimPrefix(string(chunk), "event: content_block_delta\ndata: "), "\n\n")
+		text.WriteString(gjson.Get(payload, "delta.text").String())
+	}
+	if want := "Your key is [REDACTED] keep it safe, it is important."; text.String() != want {
+		t.Fatalf("text = %q, want %q", text.String(), want)
+	}
+}
+
+func TestOutputGuardrail_NonStreamingResponse(t *testing.T) {
+	body := []byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":"Contact me at sk-abc123 today."}}]}`)
+	executor := &scriptedStreamExecutor{body: body}
+	handler := newOutputGuardrailTestHandler(t, executor, sdkconfig.GuardrailRule{Name: "key", Pattern: `sk-[a-z0-9]+`, Action: "redact"})
+
+	resp, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "moderated-model", []byte(`{"model":"moderated-model"}`), "")
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	if got := gjson.GetBytes(resp, "choices.0.message.content").String(); got != "Contact me at [REDACTED] today." {
+		t.Fatalf("content = %q", got)
+	}
+
+	handler.AddOutputGuardrail(func(_ context.Context, req OutputGuardrailRequest) (OutputGuardrailDecision, error) {
+		return OutputGuardrailDecision{Block: strings.Contains(req.Text, "today"), Reason: "no dates"}, nil
+	})
+	if _, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "moderated-model", []byte(`{"model":"moderated-model"}`), ""); errMsg == nil || errMsg.StatusCode != http.StatusBadRequest {
+		t.Fatalf("custom output guardrail should block the response, got %+v", errMsg)
+	}
+}
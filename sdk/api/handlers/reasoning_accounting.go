@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"bytes"
+	"strings"
+
+	contextmgr "github.com/router-for-me/CLIProxyAPI/v6/internal/context"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+// ReasoningUsage separates thinking tokens from visible completion tokens for one stream.
+type ReasoningUsage struct {
+	// ReasoningTokens counts thinking/reasoning output.
+	ReasoningTokens int64
+	// CompletionTokens counts visible answer output, excluding reasoning.
+	CompletionTokens int64
+	// Reported is true when the upstream usage block supplied a reasoning breakdown;
+	// otherwise both values are estimated from the streamed text.
+	Reported bool
+}
+
+// ReasoningAccountant tallies streamed reasoning and answer text. It understands
+// OpenAI `reasoning_content` deltas, DeepSeek `<think>` tags inside content,
+// Gemini `thought` parts, Claude `thinking_delta` blocks, and OpenAI Responses
+// reasoning events, and prefers upstream-reported counts when present.
+type ReasoningAccountant struct {
+	reasoningChars  int64
+	completionChars int64
+
+	// inThink tracks an open <think> tag; pending holds a possibly split tag at a chunk boundary.
+	inThink bool
+	pending string
+
+	reported          bool
+	reportedReasoning int64
+	reportedOutput    int64
+	outputIncludes    bool // reportedOutput already includes reasoning tokens
+}
+
+// Observe inspects a chunk as forwarded by the executor. Chunks may be bare JSON
+// payloads or SSE frames containing `event:` and `data:` lines.
+func (a *ReasoningAccountant) Observe(chunk []byte) {
+	if a == nil || len(chunk) == 0 {
+		return
+	}
+	for _, line := range bytes.Split(chunk, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		switch {
+		case len(line) == 0, bytes.HasPrefix(line, []byte("event:")):
+			continue
+		case bytes.HasPrefix(line, []byte("data:")):
+			a.observePayload(bytes.TrimSpace(line[len("data:"):]))
+		default:
+			a.observePayload(line)
+		}
+	}
+}
+
+func (a *ReasoningAccountant) observePayload(payload []byte) {
+	if len(payload) == 0 || bytes.Equal(payload, []byte("[DONE]")) || !gjson.ValidBytes(payload) {
+		return
+	}
+	root := gjson.ParseBytes(payload)
+	if root.IsArray() {
+		root.ForEach(func(_, value gjson.Result) bool {
+			a.observePayload([]byte(value.Raw))
+			return true
+		})
+		return
+	}
+	if inner := root.Get("response"); inner.IsObject() && inner.Get("candidates").Exists() {
+		root = inner
+	}
+
+	// OpenAI chat completions.
+	root.Get("choices").ForEach(func(_, choice gjson.Result) bool {
+		delta := choice.Get("delta")
+		a.reasoningChars += int64(len(delta.Get("reasoning_content").String()))
+		a.reasoningChars += int64(len(delta.Get("reasoning").String()))
+		a.observeContent(delta.Get("content").String())
+		return true
+	})
+
+	// Gemini candidates.
+	root.Get("candidates").ForEach(func(_, candidate gjson.Result) bool {
+		candidate.Get("content.parts").ForEach(func(_, part gjson.Result) bool {
+			text := int64(len(part.Get("text").String()))
+			if part.Get("thought").Bool() {
+				a.reasoningChars += text
+			} else {
+				a.completionChars += text
+			}
+			return true
+		})
+		return true
+	})
+
+	// Claude messages and OpenAI Responses events.
+	switch root.Get("type").String() {
+	case "content_block_delta":
+		delta := root.Get("delta")
+		switch delta.Get("type").String() {
+		case "thinking_delta":
+			a.reasoningChars += int64(len(delta.Get("thinking").String()))
+		case "text_delta":
+			a.completionChars += int64(len(delta.Get("text").String()))
+		}
+	case "response.reasoning_text.delta", "response.reasoning_summary_text.delta":
+		a.reasoningChars += int64(len(root.Get("delta").String()))
+	case "response.output_text.delta":
+		a.completionChars += int64(len(root.Get("delta").String()))
+	case "response.completed":
+		a.observeReported(root.Get("response.usage.output_tokens_details.reasoning_tokens"), root.Get("response.usage.output_tokens"), true)
+	}
+
+	a.observeReported(root.Get("usage.completion_tokens_details.reasoning_tokens"), root.Get("usage.completion_tokens"), true)
+	a.observeReported(root.Get("usageMetadata.thoughtsTokenCount"), root.Get("usageMetadata.candidatesTokenCount"), false)
+}
+
+func (a *ReasoningAccountant) observeReported(reasoning, output gjson.Result, outputIncludes bool) {
+	if !reasoning.Exists() {
+		return
+	}
+	a.reported = true
+	a.reportedReasoning = reasoning.Int()
+	a.reportedOutput = output.Int()
+	a.outputIncludes = outputIncludes
+}
+
+// observeContent splits content on <think> tags, which may straddle chunk boundaries.
+func (a *ReasoningAccountant) observeContent(content string) {
+	if content == "" {
+		return
+	}
+	text := a.pending + content
+	a.pending = ""
+	for text != "" {
+		tag := thinkOpenTag
+		if a.inThink {
+			tag = thinkCloseTag
+		}
+		if idx := strings.Index(text, tag); idx >= 0 {
+			a.addText(text[:idx])
+			a.inThink = !a.inThink
+			text = text[idx+len(tag):]
+			continue
+		}
+		// Hold back a trailing prefix of the tag so a split tag is not counted as text.
+		keep := 0
+		for n := len(tag) - 1; n > 0; n-- {
+			if strings.HasSuffix(text, tag[:n]) {
+				keep = n
+				break
+			}
+		}
+		a.addText(text[:len(text)-keep])
+		a.pending = text[len(text)-keep:]
+		return
+	}
+}
+
+func (a *ReasoningAccountant) addText(text string) {
+	if a.inThink {
+		a.reasoningChars += int64(len(text))
+	} else {
+		a.completionChars += int64(len(text))
+	}
+}
+
+// Usage returns the reasoning/completion split observed so far.
+func (a *ReasoningAccountant) Usage() ReasoningUsage {
+	if a == nil {
+		return ReasoningUsage{}
+	}
+	if a.reported {
+		completion := a.reportedOutput
+		if a.outputIncludes {
+			completion -= a.reportedReasoning
+		}
+		if completion < 0 {
+			completion = 0
+		}
+		return ReasoningUsage{ReasoningTokens: a.reportedReasoning, CompletionTokens: completion, Reported: true}
+	}
+	reasoning, completion := a.reasoningChars, a.completionChars
+	if a.inThink {
+		reasoning += int64(len(a.pending))
+	} else {
+		completion += int64(len(a.pending))
+	}
+	return ReasoningUsage{
+		ReasoningTokens:  contextmgr.EstimateTokensFromLength(reasoning),
+		CompletionTokens: contextmgr.EstimateTokensFromLength(completion),
+	}
+}
+
+// AnnotateUsage adds completion_tokens_details.reasoning_tokens to an OpenAI chat
+// usage chunk when the upstream omitted it but reasoning was streamed.
+func (a *ReasoningAccountant) AnnotateUsage(chunk []byte) []byte {
+	if a == nil || a.reported || !bytes.Contains(chunk, []byte(`"usage"`)) || !gjson.ValidBytes(chunk) {
+		return chunk
+	}
+	usage := gjson.GetBytes(chunk, "usage")
+	if !usage.IsObject() || usage.Get("completion_tokens_details.reasoning_tokens").Exists() {
+		return chunk
+	}
+	reasoning := a.Usage().ReasoningTokens
+	if reasoning == 0 {
+		return chunk
+	}
+	if completion := usage.Get("completion_tokens").Int(); completion > 0 && reasoning > completion {
+		reasoning = completion
+	}
+	updated, err := sjson.SetBytes(chunk, "usage.completion_tokens_details.reasoning_tokens", reasoning)
+	if err != nil {
+		return chunk
+	}
+	return updated
+}
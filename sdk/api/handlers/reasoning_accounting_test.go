@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestReasoningAccountant_DeepSeekThinkTagsAcrossChunks(t *testing.T) {
+	a := &ReasoningAccountant{}
+	chunks := []string{
+		`{"choices":[{"index":0,"delta":{"content":"<thi"}}]}`,
+		`{"choices":[{"index":0,"delta":{"content":"nk>abcdefghijkl"}}]}`,
+		`{"choices":[{"index":0,"delta":{"content":"mnop</th"}}]}`,
+		`{"choices":[{"index":0,"delta":{"content":"ink>12345678"}}]}`,
+		`[DONE]`,
+	}
+	for _, chunk := range chunks {
+		a.Observe([]byte(chunk))
+	}
+	got := a.Usage()
+	// 16 reasoning characters and 8 answer characters at four characters per token.
+	if got.Reported || got.ReasoningTokens != 4 || got.CompletionTokens != 2 {
+		t.Fatalf("usage = %+v, want 4 reasoning / 2 completion estimated", got)
+	}
+}
+
+func TestReasoningAccountant_PrefersReportedUsage(t *testing.T) {
+	a := &ReasoningAccountant{}
+	a.Observe([]byte(`data: {"choices":[{"index":0,"delta":{"reasoning_content":"thinking hard"}}]}`))
+	a.Observe([]byte(`data: {"choices":[{"index":0,"delta":{"content":"answer"}}]}`))
+	a.Observe([]byte(`data: {"choices":[],"usage":{"prompt_tokens":5,"completion_tokens":50,"completion_tokens_details":{"reasoning_tokens":30}}}`))
+
+	got := a.Usage()
+	if !got.Reported || got.ReasoningTokens != 30 || got.CompletionTokens != 20 {
+		t.Fatalf("usage = %+v, want reported 30 reasoning / 20 completion", got)
+	}
+}
+
+func TestReasoningAccountant_GeminiThoughtsAndClaudeThinking(t *testing.T) {
+	gemini := &ReasoningAccountant{}
+	gemini.Observe([]byte(`{"candidates":[{"content":{"parts":[{"text":"pondering","thought":true},{"text":"done"}]}}],"usageMetadata":{"candidatesTokenCount":7,"thoughtsTokenCount":12}}`))
+	if got := gemini.Usage(); !got.Reported || got.ReasoningTokens != 12 || got.CompletionTokens != 7 {
+		t.Fatalf("gemini usage = %+v, want 12 reasoning / 7 completion", got)
+	}
+
+	claude := &ReasoningAccountant{}
+	claude.Observe([]byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"thinking_delta\",\"thinking\":\"abcdefgh\"}}\n\n"))
+	claude.Observe([]byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"abcd\"}}\n\n"))
+	if got := claude.Usage(); got.Reported || got.ReasoningTokens != 2 || got.CompletionTokens != 1 {
+		t.Fatalf("claude usage = %+v, want 2 reasoning / 1 completion", got)
+	}
+}
+
+// reasoningStreamExecutor streams a fixed sequence of OpenAI chunks.
+type reasoningStreamExecutor struct {
+	chunks []string
+}
+
+func (e *reasoningStreamExecutor) Identifier() string { return "codex" }
+
+func (e *reasoningStreamExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "Execute not implemented"}
+}
+
+func (e *reasoningStreamExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	ch := make(chan coreexecutor.StreamChunk, len(e.chunks))
+	for _, chunk := range e.chunks {
+		ch <- coreexecutor.StreamChunk{Payload: []byte(chunk)}
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (e *reasoningStreamExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *reasoningStreamExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (e *reasoningStreamExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func tokenCounter(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, pair := range metric.GetLabel() {
+				if want, ok := labels[pair.GetName()]; ok && want != pair.GetValue() {
+					continue metrics
+				}
+			}
+			return metric.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestExecuteStreamWithAuthManager_SeparatesReasoningTokens(t *testing.T) {
+	const model = "reasoning-stream-model"
+	executor := &reasoningStreamExecutor{chunks: []string{
+		`{"choices":[{"index":0,"delta":{"content":"<think>abcdefghijklmnop"}}]}`,
+		`{"choices":[{"index":0,"delta":{"content":"</think>12345678"}}]}`,
+		`{"choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":6,"total_tokens":9}}`,
+	}}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "reasoning-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: model}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager)
+	observability.InitMetricsRecorder(observability.MetricsConfig{UseOfficialClient: true})
+	t.Cleanup(func() { observability.InitMetricsRecorder(observability.MetricsConfig{}) })
+
+	reasoningBefore := tokenCounter(t, "shinapi_proxy_tokens_total", map[string]string{"model": model, "type": "reasoning"})
+	completionBefore := tokenCounter(t, "shinapi_proxy_tokens_total", map[string]string{"model": model, "type": "completion"})
+	thinkingBefore := tokenCounter(t, "shinapi_agent_thinking_tokens_total", map[string]string{"model": model})
+
+	dataChan, errChan := handler.ExecuteStreamWithAuthManager(context.Background(), "openai", model, []byte(`{"model":"`+model+`"}`), "")
+	var last []byte
+	for chunk := range dataChan {
+		last = chunk
+	}
+	for msg := range errChan {
+		if msg != nil {
+			t.Fatalf("unexpected error: %v", msg.Error)
+		}
+	}
+
+	if got := gjson.GetBytes(last, "usage.completion_tokens_details.reasoning_tokens").Int(); got != 4 {
+		t.Fatalf("returned reasoning_tokens = %d, want 4 (chunk %s)", got, last)
+	}
+	if got := gjson.GetBytes(last, "usage.completion_tokens").Int(); got != 6 {
+		t.Fatalf("completion_tokens changed to %d", got)
+	}
+
+	if got := tokenCounter(t, "shinapi_proxy_tokens_total", map[string]string{"model": model, "type": "reasoning"}) - reasoningBefore; got != 4 {
+		t.Errorf("reasoning tokens recorded = %v, want 4", got)
+	}
+	if got := tokenCounter(t, "shinapi_proxy_tokens_total", map[string]string{"model": model, "type": "completion"}) - completionBefore; got != 2 {
+		t.Errorf("completion tokens recorded = %v, want 2", got)
+	}
+	if got := tokenCounter(t, "shinapi_agent_thinking_tokens_total", map[string]string{"model": model}) - thinkingBefore; got != 4 {
+		t.Errorf("thinking tokens recorded = %v, want 4", got)
+	}
+}
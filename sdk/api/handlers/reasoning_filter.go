@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// hideReasoning reports whether reasoning fields should be stripped from OpenAI chat responses.
+// Filtering applies only when the reasoning block is enabled and show-thinking-to-client is off,
+// so deployments without a reasoning config keep the translators' output unchanged.
+func (h *BaseAPIHandler) hideReasoning() bool {
+	return h != nil && h.Cfg != nil && h.Cfg.Reasoning.Enabled && !h.Cfg.Reasoning.ShowThinkingToClient
+}
+
+// StripReasoningFields removes the non-standard `reasoning_content` and `native_finish_reason`
+// fields that translators add to OpenAI chat completion responses and stream chunks.
+func StripReasoningFields(payload []byte) []byte {
+	if !bytes.Contains(payload, []byte(`"reasoning_content"`)) && !bytes.Contains(payload, []byte(`"native_finish_reason"`)) {
+		return payload
+	}
+	if !gjson.ValidBytes(payload) {
+		return payload
+	}
+	out := payload
+	for i := range gjson.GetBytes(payload, "choices").Array() {
+		for _, path := range []string{
+			fmt.Sprintf("choices.%d.message.reasoning_content", i),
+			fmt.Sprintf("choices.%d.delta.reasoning_content", i),
+			fmt.Sprintf("choices.%d.native_finish_reason", i),
+		} {
+			if !gjson.GetBytes(out, path).Exists() {
+				continue
+			}
+			if updated, err := sjson.DeleteBytes(out, path); err == nil {
+				out = updated
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+const reasoningFilterResponse = `{"id":"r1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"42","reasoning_content":"let me think"},"finish_reason":"stop","native_finish_reason":"stop"}]}`
+
+var reasoningFilterChunks = []string{
+	`{"id":"r1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"role":"assistant","content":null,"reasoning_content":"let me think"},"finish_reason":null,"native_finish_reason":null}]}`,
+	`{"id":"r1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"42"},"finish_reason":"stop","native_finish_reason":"stop"}]}`,
+}
+
+// reasoningPayloadExecutor returns fixed OpenAI chat payloads carrying reasoning fields.
+type reasoningPayloadExecutor struct{}
+
+func (reasoningPayloadExecutor) Identifier() string { return "codex" }
+
+func (reasoningPayloadExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{Payload: []byte(reasoningFilterResponse)}, nil
+}
+
+func (reasoningPayloadExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	ch := make(chan coreexecutor.StreamChunk, len(reasoningFilterChunks))
+	for _, chunk := range reasoningFilterChunks {
+		ch <- coreexecutor.StreamChunk{Payload: []byte(chunk)}
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (reasoningPayloadExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (reasoningPayloadExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (reasoningPayloadExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func newReasoningFilterHandler(t *testing.T, cfg sdkconfig.SDKConfig) *BaseAPIHandler {
+	t.Helper()
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(reasoningPayloadExecutor{})
+	auth := &coreauth.Auth{ID: "reasoning-filter-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "reasoning-filter-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+	return NewBaseAPIHandlers(&cfg, manager)
+}
+
+func collectStream(t *testing.T, handler *BaseAPIHandler) [][]byte {
+	t.Helper()
+	dataChan, errChan := handler.ExecuteStreamWithAuthManager(context.Background(), "openai", "reasoning-filter-model", []byte(`{"model":"reasoning-filter-model"}`), "")
+	var chunks [][]byte
+	for chunk := range dataChan {
+		chunks = append(chunks, chunk)
+	}
+	for msg := range errChan {
+		if msg != nil {
+			t.Fatalf("unexpected stream error: %v", msg.Error)
+		}
+	}
+	return chunks
+}
+
+func TestReasoningFields_PresentWhenShownToClient(t *testing.T) {
+	cfg := sdkconfig.SDKConfig{}
+	cfg.Reasoning.Enabled = true
+	cfg.Reasoning.ShowThinkingToClient = true
+	handler := newReasoningFilterHandler(t, cfg)
+
+	resp, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "reasoning-filter-model", []byte(`{"model":"reasoning-filter-model"}`), "")
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	if got := gjson.GetBytes(resp, "choices.0.message.reasoning_content").String(); got != "let me think" {
+		t.Fatalf("reasoning_content = %q, want passthrough (body %s)", got, resp)
+	}
+	if !gjson.GetBytes(resp, "choices.0.native_finish_reason").Exists() {
+		t.Fatalf("native_finish_reason missing: %s", resp)
+	}
+
+	chunks := collectStream(t, handler)
+	if len(chunks) != 2 || gjson.GetBytes(chunks[0], "choices.0.delta.reasoning_content").String() != "let me think" {
+		t.Fatalf("stream reasoning_content not passed through: %q", chunks)
+	}
+	if !gjson.GetBytes(chunks[1], "choices.0.native_finish_reason").Exists() {
+		t.Fatalf("stream native_finish_reason missing: %s", chunks[1])
+	}
+}
+
+func TestReasoningFields_StrippedWhenHidden(t *testing.T) {
+	cfg := sdkconfig.SDKConfig{}
+	cfg.Reasoning.Enabled = true
+	handler := newReasoningFilterHandler(t, cfg)
+
+	resp, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "reasoning-filter-model", []byte(`{"model":"reasoning-filter-model"}`), "")
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	if gjson.GetBytes(resp, "choices.0.message.reasoning_content").Exists() || gjson.GetBytes(resp, "choices.0.native_finish_reason").Exists() {
+		t.Fatalf("reasoning fields not stripped: %s", resp)
+	}
+	if got := gjson.GetBytes(resp, "choices.0.message.content").String(); got != "42" {
+		t.Fatalf("content = %q, want 42", got)
+	}
+
+	chunks := collectStream(t, handler)
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if gjson.GetBytes(chunk, "choices.0.delta.reasoning_content").Exists() || gjson.GetBytes(chunk, "choices.0.native_finish_reason").Exists() {
+			t.Fatalf("stream chunk kept reasoning fields: %s", chunk)
+		}
+	}
+	if got := gjson.GetBytes(chunks[1], "choices.0.finish_reason").String(); got != "stop" {
+		t.Fatalf("finish_reason = %q, want stop", got)
+	}
+}
+
+func TestReasoningFields_UntouchedWithoutReasoningConfig(t *testing.T) {
+	handler := newReasoningFilterHandler(t, sdkconfig.SDKConfig{})
+
+	resp, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "reasoning-filter-model", []byte(`{"model":"reasoning-filter-model"}`), "")
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	if !gjson.GetBytes(resp, "choices.0.message.reasoning_content").Exists() {
+		t.Fatalf("reasoning_content stripped without a reasoning config: %s", resp)
+	}
+}
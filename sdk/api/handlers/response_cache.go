@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// responseStore is the cache non-streaming responses are served from.
+type responseStore interface {
+	Get(model, key string) ([]byte, bool)
+	Set(model, key string, value []byte)
+}
+
+// responseCache returns the cache non-streaming responses are stored in, or nil to disable
+// it. Replaced in tests.
+var responseCache = func() responseStore {
+	return cache.GetCacheSystem()
+}
+
+// cachePrimingKey marks a context whose response is stored in the response cache.
+type cachePrimingKey struct{}
+
+// WithCachePriming marks ctx so that a successful non-streaming response executed under it
+// is stored in the response cache. Only primed responses are ever stored there.
+func WithCachePriming(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cachePrimingKey{}, true)
+}
+
+func isCachePriming(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	primed, _ := ctx.Value(cachePrimingKey{}).(bool)
+	return primed
+}
+
+// ResponseCacheKey returns the key a non-streaming response to rawJSON is cached under, or
+// "" while response caching is disabled. The handler type and alt are part of the key
+// because the cached body is in that handler's response format. Temperature and max
+// tokens are always part of it, whatever cache-key says, since they change the response
+// itself: a completion cut short by a small max_tokens must not answer a larger one.
+func (h *BaseAPIHandler) ResponseCacheKey(handlerType, modelName string, rawJSON []byte, alt string) string {
+	return ResponseCacheKeyFor(h.Cfg, handlerType, modelName, rawJSON, alt)
+}
+
+// ResponseCacheKeyFor is ResponseCacheKey for a handler configured with cfg.
+func ResponseCacheKeyFor(cfg *config.SDKConfig, handlerType, modelName string, rawJSON []byte, alt string) string {
+	if cfg == nil || !cfg.Cache.Enabled {
+		return ""
+	}
+	keyCfg := requestCacheKeyConfig(cfg)
+	keyCfg.IncludeTemperature = true
+	keyCfg.IncludeMaxTokens = true
+	return handlerType + ":" + alt + ":" + cache.RequestCacheKey(keyCfg, modelName, rawJSON)
+}
+
+// lookupResponse returns the cached response stored under key for modelName.
+func lookupResponse(modelName, key string) ([]byte, bool) {
+	store := responseCache()
+	if key == "" || store == nil {
+		return nil, false
+	}
+	resp, ok := store.Get(modelName, key)
+	if !ok {
+		return nil, false
+	}
+	return cloneBytes(resp), true
+}
+
+// storeResponse caches resp under key; the model selects the entry's TTL.
+func storeResponse(modelName, key string, resp []byte) {
+	store := responseCache()
+	if key == "" || store == nil || len(resp) == 0 {
+		return
+	}
+	store.Set(modelName, key, cloneBytes(resp))
+}
+
+// setCacheHeader reports how the response cache served the request behind ctx.
+func setCacheHeader(ctx context.Context, value string) {
+	if ctx == nil {
+		return
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Header(CacheHeader, value)
+	}
+}
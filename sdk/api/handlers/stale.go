@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+)
+
+// StaleWarning is the Warning header sent with stale cached embeddings served in place of
+// a failed upstream call (warn-code 111, "Revalidation Failed").
+const StaleWarning = `111 - "Revalidation Failed"`
+
+// ServeStaleOnError reports whether expired cached embeddings may be served instead of
+// errMsg: serve-stale-on-error is enabled, the client did not ask for a fresh response,
+// and the failure is transient, the same failures that move a request to its fallback
+// model. An open circuit breaker surfaces as one of these.
+func (h *BaseAPIHandler) ServeStaleOnError(c *gin.Context, errMsg *interfaces.ErrorMessage) bool {
+	if h.Cfg == nil || !h.Cfg.Cache.Enabled || !h.Cfg.Cache.ServeStaleOnError || RequestCacheControl(c).SkipLookup {
+		return false
+	}
+	var ctx context.Context
+	if c != nil && c.Request != nil {
+		ctx = c.Request.Context()
+	}
+	return h.fallbackEligible(ctx, errMsg)
+}
+
+// MarkStale labels the response on c as a stale cache entry.
+func MarkStale(c *gin.Context) {
+	c.Header(CacheHeader, "STALE")
+	c.Header("Warning", StaleWarning)
+}
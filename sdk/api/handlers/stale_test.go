@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestServeStaleOnError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name         string
+		enabled      bool
+		status       int
+		cacheControl string
+		want         bool
+	}{
+		{name: "server error", enabled: true, status: http.StatusServiceUnavailable, want: true},
+		{name: "rate limited", enabled: true, status: http.StatusTooManyRequests, want: true},
+		{name: "no upstream status", enabled: true, status: 0, want: true},
+		{name: "client error", enabled: true, status: http.StatusBadRequest, want: false},
+		{name: "disabled", enabled: false, status: http.StatusBadGateway, want: false},
+		{name: "client asked for a fresh response", enabled: true, status: http.StatusBadGateway, cacheControl: "no-cache", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &sdkconfig.SDKConfig{}
+			cfg.Cache.Enabled = true
+			cfg.Cache.ServeStaleOnError = tt.enabled
+			h := NewBaseAPIHandlers(cfg, nil)
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/embeddings", nil)
+			if tt.cacheControl != "" {
+				c.Request.Header.Set(CacheControlHeader, tt.cacheControl)
+			}
+			errMsg := &interfaces.ErrorMessage{StatusCode: tt.status, Error: errors.New("upstream failed")}
+			if got := h.ServeStaleOnError(c, errMsg); got != tt.want {
+				t.Fatalf("ServeStaleOnError = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+)
+
+// streamStore is the cache streamed responses are recorded in and replayed from.
+type streamStore interface {
+	GetStreamingResponse(key string) ([]cache.StreamEvent, bool)
+	ReplayStreamingResponse(ctx context.Context, events []cache.StreamEvent, callback func(event cache.StreamEvent) error) error
+	NewStreamRecorder(model, key string) *cache.StreamRecorder
+}
+
+// streamCache returns the cache streamed responses are stored in, or nil to disable it.
+// Replaced in tests.
+var streamCache = func() streamStore {
+	cs := cache.GetCacheSystem()
+	if cs == nil || cs.Streaming == nil {
+		return nil
+	}
+	return cs
+}
+
+// StreamCacheKey returns the key a streamed response to rawJSON is cached under, or "" while
+// response or streaming caching is disabled. It differs from ResponseCacheKey because the
+// cached chunks are in the handler's streaming format.
+func (h *BaseAPIHandler) StreamCacheKey(handlerType, modelName string, rawJSON []byte, alt string) string {
+	if h.Cfg == nil || !h.Cfg.Cache.StreamingCache.Enabled {
+		return ""
+	}
+	key := h.ResponseCacheKey(handlerType, modelName, rawJSON, alt)
+	if key == "" {
+		return ""
+	}
+	return "stream:" + key
+}
+
+// replayCachedStream serves the stream cached under key, chunk for chunk and with the event
+// ids it was first generated with. ok is false when nothing is cached under key.
+func replayCachedStream(ctx context.Context, key string, ids *streamEventIDs) (<-chan []byte, <-chan *interfaces.ErrorMessage, bool) {
+	store := streamCache()
+	if key == "" || store == nil {
+		return nil, nil, false
+	}
+	events, found := store.GetStreamingResponse(key)
+	if !found {
+		return nil, nil, false
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	dataChan := make(chan []byte)
+	errChan := make(chan *interfaces.ErrorMessage)
+	go func() {
+		defer close(dataChan)
+		defer close(errChan)
+		_ = store.ReplayStreamingResponse(ctx, events, func(event cache.StreamEvent) error {
+			chunk := cloneBytes(event.Data)
+			ids.record(chunk, event.ID)
+			select {
+			case dataChan <- chunk:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	return dataChan, errChan, true
+}
+
+// recordStream forwards a stream unchanged while recording it, along with any event ids
+// assigned in ids, under key. The recording is cached only when the stream ends with a
+// terminal event and no error, so truncated or failed streams are never replayed.
+func recordStream(ctx context.Context, modelName, key string, ids *streamEventIDs, data <-chan []byte, errs <-chan *interfaces.ErrorMessage) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	store := streamCache()
+	if key == "" || store == nil || data == nil {
+		return data, errs
+	}
+	recorder := store.NewStreamRecorder(modelName, key)
+	if recorder == nil {
+		return data, errs
+	}
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
+
+	dataChan := make(chan []byte)
+	errChan := make(chan *interfaces.ErrorMessage, 1)
+	go func() {
+		defer close(dataChan)
+		defer close(errChan)
+		validator := &StreamValidator{}
+		abandoned := false
+		for chunk := range data {
+			validator.Observe(chunk)
+			recorder.RecordEvent(chunk, "", ids.lookup(chunk))
+			if abandoned {
+				// Keep draining so the upstream goroutine can finish.
+				continue
+			}
+			select {
+			case dataChan <- chunk:
+			case <-done:
+				abandoned = true
+			}
+		}
+		if errMsg, ok := <-errs; ok && errMsg != nil {
+			errChan <- errMsg
+			return
+		}
+		if !abandoned && validator.Terminated() {
+			recorder.Commit()
+		}
+	}()
+	return dataChan, errChan
+}
@@ -1,18 +1,28 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
+	log "github.com/sirupsen/logrus"
 )
 
+// ErrStreamMaxDuration ends a stream that ran longer than the configured maximum duration.
+var ErrStreamMaxDuration = errors.New("stream exceeded the maximum streaming duration")
+
 type StreamForwardOptions struct {
 	// KeepAliveInterval overrides the configured streaming keep-alive interval.
 	// If nil, the configured default is used. If set to <= 0, keep-alives are disabled.
 	KeepAliveInterval *time.Duration
 
+	// MaxDuration overrides the configured maximum streaming duration.
+	// If nil, the configured default is used. If set to <= 0, streams are not capped.
+	MaxDuration *time.Duration
+
 	// WriteChunk writes a single data chunk to the response body. It should not flush.
 	WriteChunk func(chunk []byte)
 
@@ -25,8 +35,13 @@ type StreamForwardOptions struct {
 	WriteDone func()
 
 	// WriteKeepAlive optionally writes a keep-alive heartbeat. It should not flush.
-	// When nil, a standard SSE comment heartbeat is used.
+	// When nil, the shared KeepAliveWriter emits a standard SSE comment heartbeat.
 	WriteKeepAlive func()
+
+	// WriteSyntheticFinish optionally writes a format-specific finish event when the upstream
+	// closes without an error but also without a terminal marker. When set, forwarded chunks
+	// are validated and truncated streams are counted. It should not flush.
+	WriteSyntheticFinish func()
 }
 
 func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage, opts StreamForwardOptions) {
@@ -42,23 +57,33 @@ func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, can
 		writeChunk = func([]byte) {}
 	}
 
+	keepAliveInterval := StreamingKeepAliveInterval(h.Cfg)
+	if opts.KeepAliveInterval != nil {
+		keepAliveInterval = *opts.KeepAliveInterval
+	}
+	keepAlive := NewKeepAliveWriter(c.Writer, keepAliveInterval, []byte(SSEKeepAliveComment))
+	defer keepAlive.Stop()
+	keepAliveC := keepAlive.Ticks()
+
 	writeKeepAlive := opts.WriteKeepAlive
 	if writeKeepAlive == nil {
-		writeKeepAlive = func() {
-			_, _ = c.Writer.Write([]byte(": keep-alive\n\n"))
-		}
+		writeKeepAlive = keepAlive.Heartbeat
 	}
 
-	keepAliveInterval := StreamingKeepAliveInterval(h.Cfg)
-	if opts.KeepAliveInterval != nil {
-		keepAliveInterval = *opts.KeepAliveInterval
+	var validator *StreamValidator
+	if opts.WriteSyntheticFinish != nil {
+		validator = &StreamValidator{}
+	}
+
+	maxDuration := StreamingMaxDuration(h.Cfg)
+	if opts.MaxDuration != nil {
+		maxDuration = *opts.MaxDuration
 	}
-	var keepAlive *time.Ticker
-	var keepAliveC <-chan time.Time
-	if keepAliveInterval > 0 {
-		keepAlive = time.NewTicker(keepAliveInterval)
-		defer keepAlive.Stop()
-		keepAliveC = keepAlive.C
+	var maxDurationC <-chan time.Time
+	if maxDuration > 0 {
+		timer := time.NewTimer(maxDuration)
+		defer timer.Stop()
+		maxDurationC = timer.C
 	}
 
 	var terminalErr *interfaces.ErrorMessage
@@ -87,6 +112,12 @@ func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, can
 					cancel(terminalErr.Error)
 					return
 				}
+				if validator != nil && !validator.Terminated() {
+					log.Warnf("upstream stream for %s closed without a terminal event; emitting synthetic finish", c.Request.URL.Path)
+					observability.GetMetricsRecorder().RecordTruncatedStream()
+					SetAuditMetadata(c, "stream_truncated", "true")
+					opts.WriteSyntheticFinish()
+				}
 				if opts.WriteDone != nil {
 					opts.WriteDone()
 				}
@@ -94,6 +125,7 @@ func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, can
 				cancel(nil)
 				return
 			}
+			validator.Observe(chunk)
 			writeChunk(chunk)
 			flusher.Flush()
 		case errMsg, ok := <-errs:
@@ -116,6 +148,15 @@ func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, can
 		case <-keepAliveC:
 			writeKeepAlive()
 			flusher.Flush()
+		case <-maxDurationC:
+			log.Warnf("stream for %s exceeded the maximum duration of %s; closing it", c.Request.URL.Path, maxDuration)
+			SetAuditMetadata(c, "stream_max_duration_exceeded", "true")
+			if opts.WriteTerminalError != nil {
+				opts.WriteTerminalError(&interfaces.ErrorMessage{StatusCode: http.StatusGatewayTimeout, Error: ErrStreamMaxDuration})
+			}
+			flusher.Flush()
+			cancel(ErrStreamMaxDuration)
+			return
 		}
 	}
 }
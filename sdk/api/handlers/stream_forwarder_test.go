@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestForwardStream_CutsSlowDripStreamAtMaxDuration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	// The upstream never goes idle: it sends a chunk every 10ms until it is canceled.
+	data := make(chan []byte)
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				select {
+				case data <- []byte(`{"choices":[{"index":0,"delta":{"content":"."}}]}`):
+				case <-stop:
+					return
+				}
+			}
+		}
+	}()
+	var cancelErr error
+	cancel := func(err error) {
+		cancelErr = err
+		close(stop)
+	}
+
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Streaming.MaxDurationSeconds = 1
+	h := NewBaseAPIHandlers(cfg, nil)
+	maxDuration := 150 * time.Millisecond
+
+	start := time.Now()
+	h.ForwardStream(c, rec, cancel, data, make(chan *interfaces.ErrorMessage), StreamForwardOptions{
+		MaxDuration: &maxDuration,
+		WriteChunk: func(chunk []byte) {
+			_, _ = c.Writer.Write([]byte("data: " + string(chunk) + "\n\n"))
+		},
+		WriteTerminalError: func(errMsg *interfaces.ErrorMessage) {
+			_, _ = c.Writer.Write([]byte("event: error\ndata: " + errMsg.Error.Error() + "\n\n"))
+		},
+	})
+	elapsed := time.Since(start)
+
+	if elapsed < maxDuration || elapsed > maxDuration+500*time.Millisecond {
+		t.Fatalf("stream ran %v, want it cut at about %v", elapsed, maxDuration)
+	}
+	if !errors.Is(cancelErr, ErrStreamMaxDuration) {
+		t.Fatalf("cancel error = %v, want ErrStreamMaxDuration", cancelErr)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `"content":"."`) {
+		t.Fatalf("expected chunks before the cap, got %q", body)
+	}
+	if !strings.HasSuffix(body, "event: error\ndata: "+ErrStreamMaxDuration.Error()+"\n\n") {
+		t.Fatalf("expected a terminal error event, got %q", body)
+	}
+}
+
+func TestStreamingMaxDuration(t *testing.T) {
+	if got := StreamingMaxDuration(nil); got != 0 {
+		t.Fatalf("nil config = %v, want 0", got)
+	}
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Streaming.MaxDurationSeconds = 90
+	if got := StreamingMaxDuration(cfg); got != 90*time.Second {
+		t.Fatalf("max duration = %v, want 90s", got)
+	}
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// LastEventIDHeader is sent by SSE clients reconnecting to a stream.
+const LastEventIDHeader = "Last-Event-ID"
+
+// streamEventIDsKey stores a request's streamEventIDs in its gin context.
+const streamEventIDsKey = "sse_event_ids"
+
+// streamEventIDs tracks the SSE "id:" and "retry:" fields of one streamed response.
+// Chunks from a fan-out stream carry the ids the shared stream assigned them, matched by
+// identity so a chunk rewritten on its way to the client, e.g. by an output guardrail, is
+// never written with a wrong id. With streaming.event-ids on, every other event is
+// numbered after the last id written, keeping ids monotonic.
+type streamEventIDs struct {
+	mu          sync.Mutex
+	ids         map[*byte]string
+	numberAll   bool
+	retryMillis int
+	last        uint64
+	started     bool
+}
+
+// newStreamEventIDs attaches id tracking to the request behind ctx, or returns nil when
+// ctx carries no gin context.
+func newStreamEventIDs(ctx context.Context, cfg *config.SDKConfig) *streamEventIDs {
+	if ctx == nil {
+		return nil
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return nil
+	}
+	ids := &streamEventIDs{ids: make(map[*byte]string)}
+	if cfg != nil {
+		ids.numberAll = cfg.Streaming.EventIDs
+		ids.retryMillis = cfg.Streaming.RetryMillis
+	}
+	ginCtx.Set(streamEventIDsKey, ids)
+	return ids
+}
+
+func (t *streamEventIDs) record(chunk []byte, id string) {
+	if t == nil || len(chunk) == 0 || id == "" {
+		return
+	}
+	t.mu.Lock()
+	t.ids[&chunk[0]] = id
+	t.mu.Unlock()
+}
+
+// lookup returns the id recorded for chunk without consuming it.
+func (t *streamEventIDs) lookup(chunk []byte) string {
+	if t == nil || len(chunk) == 0 {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ids[&chunk[0]]
+}
+
+// next returns the id to write ahead of chunk, if any, and on the first call the
+// reconnect delay to advertise.
+func (t *streamEventIDs) next(chunk []byte) (id string, retryMillis int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.started {
+		t.started = true
+		retryMillis = t.retryMillis
+	}
+	if len(chunk) > 0 {
+		if assigned, ok := t.ids[&chunk[0]]; ok {
+			delete(t.ids, &chunk[0])
+			if n, err := strconv.ParseUint(assigned, 10, 64); err == nil {
+				t.last = n
+			}
+			return assigned, retryMillis
+		}
+	}
+	if t.numberAll {
+		t.last++
+		id = strconv.FormatUint(t.last, 10)
+	}
+	return id, retryMillis
+}
+
+// WriteSSEEventFields writes the SSE fields that precede chunk: "retry:" with the
+// configured streaming.retry-ms ahead of the first event, and "id:" so a client that
+// loses the connection can resume by sending the id back in the Last-Event-ID header.
+// Ids come from the fan-out stream the chunk was shared through, or, with
+// streaming.event-ids on, from a per-response counter. Events later in the same chunk
+// inherit the id, so the chunk must be written whole right after this call.
+func WriteSSEEventFields(c *gin.Context, chunk []byte) {
+	if c == nil {
+		return
+	}
+	value, ok := c.Get(streamEventIDsKey)
+	if !ok {
+		return
+	}
+	ids, _ := value.(*streamEventIDs)
+	if ids == nil {
+		return
+	}
+	id, retryMillis := ids.next(chunk)
+	if retryMillis > 0 {
+		_, _ = fmt.Fprintf(c.Writer, "retry: %d\n", retryMillis)
+	}
+	if id != "" {
+		_, _ = fmt.Fprintf(c.Writer, "id: %s\n", id)
+	}
+}
+
+// lastEventID returns the Last-Event-ID header of the request behind ctx.
+func lastEventID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil && ginCtx.Request != nil {
+		return strings.TrimSpace(ginCtx.GetHeader(LastEventIDHeader))
+	}
+	return ""
+}
+
+// subscribeFanout forwards the events of a shared stream the request joined, recording
+// their ids for WriteSSEEventFields.
+func (h *BaseAPIHandler) subscribeFanout(ctx context.Context, handlerType, modelName string, result executor.StreamFanoutResult, ids *streamEventIDs) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	dataChan := make(chan []byte)
+	errChan := make(chan *interfaces.ErrorMessage, 1)
+
+	go func() {
+		defer close(dataChan)
+		defer close(errChan)
+
+		for event := range result.Subscriber {
+			ids.record(event.Data, event.ID)
+			select {
+			case dataChan <- event.Data:
+			case <-ctx.Done():
+				result.Stream.Unsubscribe(result.Subscriber)
+				return
+			}
+		}
+	}()
+
+	return h.filterStream(ctx, handlerType, modelName, dataChan, errChan)
+}
+
+// fanoutUpstreamContext carries the upstream of a shared stream. It keeps the values of
+// the request that started the stream but not its cancellation, so the upstream survives
+// that request's client disconnecting. Once the request is released its gin context is
+// recycled by gin and no longer handed to the executors still streaming.
+type fanoutUpstreamContext struct {
+	context.Context
+	cancel   context.CancelFunc
+	released atomic.Bool
+}
+
+func newFanoutUpstreamContext(ctx context.Context) *fanoutUpstreamContext {
+	c := &fanoutUpstreamContext{}
+	c.Context, c.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	return c
+}
+
+func (c *fanoutUpstreamContext) Value(key any) any {
+	if key == "gin" && c.released.Load() {
+		return nil
+	}
+	return c.Context.Value(key)
+}
+
+// publishFanout publishes the upstream chunks of a shared stream and forwards them to the
+// request that started it on out. When that request's ctx ends, out is closed but
+// publishing goes on: the stream runs to completion while other clients are subscribed,
+// and otherwise for up to window, so the client can reconnect with Last-Event-ID. After
+// window without a subscriber the upstream is cancelled and the stream aborted.
+func publishFanout(ctx context.Context, upstream *fanoutUpstreamContext, stream *executor.SharedStream, window time.Duration, ids *streamEventIDs, data <-chan []byte, out chan<- []byte) {
+	defer upstream.cancel()
+	originatorDone := ctx.Done()
+	var idle *time.Ticker
+	var idleCheck <-chan time.Time
+	var lastSubscribed time.Time
+	defer func() {
+		if idle != nil {
+			idle.Stop()
+		}
+	}()
+	detach := func() {
+		close(out)
+		out = nil
+		originatorDone = nil
+		upstream.released.Store(true)
+		idle = time.NewTicker(window / 4)
+		idleCheck = idle.C
+		lastSubscribed = time.Now()
+	}
+
+	for {
+		select {
+		case chunk, ok := <-data:
+			if !ok {
+				if out != nil {
+					close(out)
+				}
+				stream.Complete()
+				return
+			}
+			if len(chunk) > 0 {
+				id := stream.PublishBytes(chunk)
+				if out != nil {
+					ids.record(chunk, id)
+				}
+			}
+			if out == nil {
+				continue
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				detach()
+			}
+		case <-originatorDone:
+			detach()
+		case now := <-idleCheck:
+			// The originator's own subscription is never read but still counted.
+			if stream.SubscriberCount() > 1 {
+				lastSubscribed = now
+				continue
+			}
+			if now.Sub(lastSubscribed) >= window {
+				stream.Abort()
+				upstream.cancel()
+				// Drained so the upstream goroutine can finish.
+				go func() {
+					for range data {
+					}
+				}()
+				return
+			}
+		}
+	}
+}
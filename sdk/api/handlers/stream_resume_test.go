@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestExecuteStreamWithFanout_ResumesFromLastEventID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	exec := &gatedStreamExecutor{release: make(chan struct{}), chunks: []string{`{"n":1}`, `{"n":2}`, `{"n":3}`, `{"n":4}`}}
+	close(exec.release)
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(exec)
+	auth := &coreauth.Auth{ID: "fanout-resume-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "fanout-resume-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager)
+
+	// stream runs one request and renders it the way the SSE handlers do.
+	stream := func(lastEventID string) string {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		body := `{"model":"fanout-resume-model","stream":true}`
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		c.Request.Header.Set("Idempotency-Key", "resume-"+t.Name())
+		if lastEventID != "" {
+			c.Request.Header.Set(LastEventIDHeader, lastEventID)
+		}
+		ctx := context.WithValue(context.Background(), "gin", c)
+		data, _ := handler.ExecuteStreamWithFanout(ctx, "openai", "fanout-resume-model", []byte(body), "")
+		for chunk := range data {
+			WriteSSEEventFields(c, chunk)
+			_, _ = c.Writer.Write([]byte("data: " + string(chunk) + "\n\n"))
+		}
+		return recorder.Body.String()
+	}
+
+	first := stream("")
+	want := "id: 1\ndata: {\"n\":1}\n\nid: 2\ndata: {\"n\":2}\n\nid: 3\ndata: {\"n\":3}\n\nid: 4\ndata: {\"n\":4}\n\n"
+	if first != want {
+		t.Fatalf("first stream = %q, want %q", first, want)
+	}
+
+	// The client lost the connection after event 2 and reconnects.
+	resumed := stream("2")
+	want = "id: 3\ndata: {\"n\":3}\n\nid: 4\ndata: {\"n\":4}\n\n"
+	if resumed != want {
+		t.Fatalf("resumed stream = %q, want %q", resumed, want)
+	}
+	if got := exec.starts.Load(); got != 1 {
+		t.Fatalf("upstream stream started %d times, want 1", got)
+	}
+}
+
+func TestWriteSSEEventFields_RetryHintAndIncrementingIDs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Streaming.EventIDs = true
+	cfg.Streaming.RetryMillis = 3000
+	ids := newStreamEventIDs(context.WithValue(context.Background(), "gin", c), cfg)
+
+	// Chunks 2 and 3 came through a fan-out stream that numbered them 7 and 8; the others
+	// are numbered after the last id written.
+	chunks := [][]byte{[]byte(`{"n":1}`), []byte(`{"n":2}`), []byte(`{"n":3}`), []byte(`{"n":4}`)}
+	ids.record(chunks[1], "7")
+	ids.record(chunks[2], "8")
+	for _, chunk := range chunks {
+		WriteSSEEventFields(c, chunk)
+		_, _ = c.Writer.Write([]byte("data: " + string(chunk) + "\n\n"))
+	}
+
+	want := "retry: 3000\nid: 1\ndata: {\"n\":1}\n\nid: 7\ndata: {\"n\":2}\n\nid: 8\ndata: {\"n\":3}\n\nid: 9\ndata: {\"n\":4}\n\n"
+	if got := recorder.Body.String(); got != want {
+		t.Fatalf("stream = %q, want %q", got, want)
+	}
+}
+
+func TestWriteSSEEventFields_OffByDefault(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	newStreamEventIDs(context.WithValue(context.Background(), "gin", c), &sdkconfig.SDKConfig{})
+
+	WriteSSEEventFields(c, []byte(`{"n":1}`))
+	if got := recorder.Body.String(); got != "" {
+		t.Fatalf("expected no SSE fields by default, got %q", got)
+	}
+}
+
+// steppedStreamExecutor sends one chunk per value received on step, and records whether
+// its context was cancelled before the stream finished.
+type steppedStreamExecutor struct {
+	gatedStreamExecutor
+	step      chan struct{}
+	cancelled atomic.Bool
+}
+
+func (e *steppedStreamExecutor) ExecuteStream(ctx context.Context, _ *coreauth.Auth, _ coreexecutor.Request, _ coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	e.starts.Add(1)
+	ch := make(chan coreexecutor.StreamChunk)
+	go func() {
+		defer close(ch)
+		for _, chunk := range e.chunks {
+			select {
+			case <-e.step:
+			case <-ctx.Done():
+				e.cancelled.Store(true)
+				return
+			}
+			ch <- coreexecutor.StreamChunk{Payload: []byte(chunk)}
+		}
+	}()
+	return ch, nil
+}
+
+func TestExecuteStreamWithFanout_OriginatorDisconnectKeepsStreamResumable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	exec := &steppedStreamExecutor{step: make(chan struct{})}
+	exec.chunks = []string{`{"n":1}`, `{"n":2}`, `{"n":3}`}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(exec)
+	auth := &coreauth.Auth{ID: "fanout-detach-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "fanout-detach-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager)
+
+	body := `{"model":"fanout-detach-model","stream":true}`
+	request := func(ctx context.Context, lastEventID string) <-chan []byte {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		c.Request.Header.Set("Idempotency-Key", "detach-"+t.Name())
+		if lastEventID != "" {
+			c.Request.Header.Set(LastEventIDHeader, lastEventID)
+		}
+		data, _ := handler.ExecuteStreamWithFanout(context.WithValue(ctx, "gin", c), "openai", "fanout-detach-model", []byte(body), "")
+		return data
+	}
+
+	ctx, disconnect := context.WithCancel(context.Background())
+	first := request(ctx, "")
+	exec.step <- struct{}{}
+	if chunk := <-first; string(chunk) != `{"n":1}` {
+		t.Fatalf("first chunk = %s", chunk)
+	}
+	disconnect()
+	for range first {
+	}
+
+	// The upstream keeps going after the client is gone.
+	exec.step <- struct{}{}
+	exec.step <- struct{}{}
+
+	var resumed []string
+	for chunk := range request(context.Background(), "1") {
+		resumed = append(resumed, string(chunk))
+	}
+	if got := strings.Join(resumed, "|"); got != `{"n":2}|{"n":3}` {
+		t.Fatalf("resumed stream = %q, want the events after id 1", got)
+	}
+	if exec.cancelled.Load() {
+		t.Fatal("upstream was cancelled when the originating client disconnected")
+	}
+	if got := exec.starts.Load(); got != 1 {
+		t.Fatalf("upstream stream started %d times, want 1", got)
+	}
+}
+
+func TestPublishFanout_CancelsUpstreamAfterResumeWindow(t *testing.T) {
+	stream, _, _ := executor.NewStreamFanout(executor.DefaultStreamFanoutConfig()).GetOrCreateStream("publish-window")
+	ctx, disconnect := context.WithCancel(context.Background())
+	upstream := newFanoutUpstreamContext(ctx)
+	data := make(chan []byte)
+	go func() {
+		defer close(data)
+		<-upstream.Done()
+	}()
+	out := make(chan []byte)
+	go publishFanout(ctx, upstream, stream, 40*time.Millisecond, nil, data, out)
+
+	disconnect()
+	for range out {
+	}
+	select {
+	case <-upstream.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream still running after the resume window without subscribers")
+	}
+	<-stream.Done()
+	if _, ok := stream.SubscribeAfter("0"); ok {
+		t.Fatal("an abandoned stream must not be resumable")
+	}
+}
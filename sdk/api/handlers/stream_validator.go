@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"bytes"
+
+	"github.com/tidwall/gjson"
+)
+
+// StreamValidator tracks whether a proxied stream carried a valid terminal marker.
+// It recognizes OpenAI `finish_reason`, Claude `message_stop`, Gemini `finishReason`,
+// OpenAI Responses `response.completed`, and the `[DONE]` sentinel.
+type StreamValidator struct {
+	terminated bool
+}
+
+// Observe inspects a chunk as forwarded by the executor. Chunks may be bare JSON
+// payloads or SSE frames containing `event:` and `data:` lines.
+func (v *StreamValidator) Observe(chunk []byte) {
+	if v == nil || v.terminated || len(chunk) == 0 {
+		return
+	}
+	for _, line := range bytes.Split(chunk, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		switch {
+		case len(line) == 0:
+			continue
+		case bytes.HasPrefix(line, []byte("event:")):
+			if event := bytes.TrimSpace(line[len("event:"):]); bytes.Equal(event, []byte("message_stop")) || bytes.Equal(event, []byte("response.completed")) {
+				v.terminated = true
+			}
+		case bytes.HasPrefix(line, []byte("data:")):
+			v.observePayload(bytes.TrimSpace(line[len("data:"):]))
+		default:
+			v.observePayload(line)
+		}
+		if v.terminated {
+			return
+		}
+	}
+}
+
+func (v *StreamValidator) observePayload(payload []byte) {
+	if bytes.Equal(payload, []byte("[DONE]")) {
+		v.terminated = true
+		return
+	}
+	if !gjson.ValidBytes(payload) {
+		return
+	}
+	root := gjson.ParseBytes(payload)
+	if root.IsArray() {
+		root.ForEach(func(_, value gjson.Result) bool {
+			v.observePayload([]byte(value.Raw))
+			return !v.terminated
+		})
+		return
+	}
+	switch root.Get("type").String() {
+	case "message_stop", "response.completed":
+		v.terminated = true
+		return
+	}
+	if hasNonEmpty(root.Get("choices.#.finish_reason")) ||
+		hasNonEmpty(root.Get("candidates.#.finishReason")) ||
+		hasNonEmpty(root.Get("response.candidates.#.finishReason")) {
+		v.terminated = true
+	}
+}
+
+// Terminated reports whether a terminal marker has been observed.
+func (v *StreamValidator) Terminated() bool {
+	return v != nil && v.terminated
+}
+
+func hasNonEmpty(values gjson.Result) bool {
+	for _, value := range values.Array() {
+		if value.Type == gjson.String && value.String() != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestStreamValidator_TerminalMarkers(t *testing.T) {
+	cases := map[string][]string{
+		"openai": {
+			`{"choices":[{"index":0,"delta":{"content":"hi"},"finish_reason":null}]}`,
+			`{"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		},
+		"claude": {
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"hi\"}}\n\n",
+			"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+		},
+		"gemini": {
+			`{"candidates":[{"content":{"parts":[{"text":"hi"}]}}]}`,
+			`{"candidates":[{"content":{"parts":[{"text":""}]},"finishReason":"STOP"}]}`,
+		},
+	}
+	for name, chunks := range cases {
+		v := &StreamValidator{}
+		v.Observe([]byte(chunks[0]))
+		if v.Terminated() {
+			t.Fatalf("%s: terminated before final chunk", name)
+		}
+		v.Observe([]byte(chunks[1]))
+		if !v.Terminated() {
+			t.Fatalf("%s: expected terminal marker to be detected", name)
+		}
+	}
+}
+
+func forwardTestStream(t *testing.T, chunks ...string) string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	data := make(chan []byte, len(chunks))
+	for _, chunk := range chunks {
+		data <- []byte(chunk)
+	}
+	close(data)
+	errs := make(chan *interfaces.ErrorMessage)
+
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, nil)
+	h.ForwardStream(c, rec, func(error) {}, data, errs, StreamForwardOptions{
+		WriteChunk: func(chunk []byte) {
+			_, _ = c.Writer.Write([]byte("data: " + string(chunk) + "\n\n"))
+		},
+		WriteDone: func() {
+			_, _ = c.Writer.Write([]byte("data: [DONE]\n\n"))
+		},
+		WriteSyntheticFinish: func() {
+			_, _ = c.Writer.Write([]byte("data: synthetic-finish\n\n"))
+		},
+	})
+	return rec.Body.String()
+}
+
+func TestForwardStream_CleanStreamHasNoSyntheticFinish(t *testing.T) {
+	body := forwardTestStream(t,
+		`{"choices":[{"index":0,"delta":{"content":"hi"},"finish_reason":null}]}`,
+		`{"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+	)
+	if strings.Contains(body, "synthetic-finish") {
+		t.Fatalf("unexpected synthetic finish in clean stream: %q", body)
+	}
+	if !strings.HasSuffix(body, "data: [DONE]\n\n") {
+		t.Fatalf("expected [DONE] terminator, got %q", body)
+	}
+}
+
+func TestForwardStream_PrematureCloseEmitsSyntheticFinish(t *testing.T) {
+	body := forwardTestStream(t,
+		`{"choices":[{"index":0,"delta":{"content":"hi"},"finish_reason":null}]}`,
+	)
+	idx := strings.Index(body, "synthetic-finish")
+	if idx < 0 {
+		t.Fatalf("expected synthetic finish for truncated stream, got %q", body)
+	}
+	if done := strings.Index(body, "[DONE]"); done < idx {
+		t.Fatalf("synthetic finish must precede [DONE]: %q", body)
+	}
+}
@@ -16,7 +16,9 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/observability"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/scheduler"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/circuitbreaker"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
@@ -362,7 +364,7 @@ func (m *Manager) Execute(ctx context.Context, providers []string, req cliproxye
 		default:
 		}
 
-		resp, errExec := m.executeProvidersOnce(ctx, rotated, func(execCtx context.Context, provider string) (cliproxyexecutor.Response, error) {
+		resp, errExec := m.executeProvidersOnce(withRetryRound(ctx, attempt), rotated, func(execCtx context.Context, provider string) (cliproxyexecutor.Response, error) {
 			return m.executeWithProvider(execCtx, provider, req, opts)
 		})
 		if errExec == nil {
@@ -466,7 +468,7 @@ func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cli
 		default:
 		}
 
-		chunks, errStream := m.executeStreamProvidersOnce(ctx, rotated, func(execCtx context.Context, provider string) (<-chan cliproxyexecutor.StreamChunk, error) {
+		chunks, errStream := m.executeStreamProvidersOnce(withRetryRound(ctx, attempt), rotated, func(execCtx context.Context, provider string) (<-chan cliproxyexecutor.StreamChunk, error) {
 			return m.executeStreamWithProvider(execCtx, provider, req, opts)
 		})
 		if errStream == nil {
@@ -519,6 +521,9 @@ func (m *Manager) executeWithProvider(ctx context.Context, provider string, req
 			log.Debugf("circuit breaker open for %s, skipping", cbKey)
 			continue
 		}
+		if !retryBudgetAllows(lastErr) {
+			return cliproxyexecutor.Response{}, lastErr
+		}
 
 		execCtx := ctx
 		if rt := m.roundTripperFor(auth); rt != nil {
@@ -528,14 +533,18 @@ func (m *Manager) executeWithProvider(ctx context.Context, provider string, req
 		execReq := req
 		execReq.Model, execReq.Metadata = rewriteModelForAuth(routeModel, req.Metadata, auth)
 		execReq.Model, execReq.Metadata = m.applyOAuthModelMapping(auth, execReq.Model, execReq.Metadata)
+		started := time.Now()
 		resp, errExec := exec.Execute(execCtx, auth, execReq, opts)
 		result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil}
+		attempt := Attempt{Provider: provider, AuthID: auth.ID, Model: routeModel, Duration: time.Since(started)}
 		if errExec != nil {
 			result.Error = &Error{Message: errExec.Error()}
 			var se cliproxyexecutor.StatusError
 			if errors.As(errExec, &se) && se != nil {
 				result.Error.HTTPStatus = se.StatusCode()
 			}
+			attempt.Status, attempt.Err = result.Error.HTTPStatus, errExec
+			notifyAttempt(ctx, attempt)
 			if ra := retryAfterFromError(errExec); ra != nil {
 				result.RetryAfter = ra
 			}
@@ -549,6 +558,8 @@ func (m *Manager) executeWithProvider(ctx context.Context, provider string, req
 		}
 		cb.RecordSuccess()
 		m.MarkResult(execCtx, result)
+		notifyAttempt(ctx, attempt)
+		notifyServedProvider(ctx, provider)
 		return resp, nil
 	}
 }
@@ -573,6 +584,9 @@ func (m *Manager) executeCountWithProvider(ctx context.Context, provider string,
 		debugLogAuthSelection(entry, auth, provider, req.Model)
 
 		tried[auth.ID] = struct{}{}
+		if !retryBudgetAllows(lastErr) {
+			return cliproxyexecutor.Response{}, lastErr
+		}
 		execCtx := ctx
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
@@ -629,6 +643,9 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 			log.Debugf("circuit breaker open for %s, skipping", cbKey)
 			continue
 		}
+		if !retryBudgetAllows(lastErr) {
+			return nil, lastErr
+		}
 
 		execCtx := ctx
 		if rt := m.roundTripperFor(auth); rt != nil {
@@ -638,6 +655,7 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 		execReq := req
 		execReq.Model, execReq.Metadata = rewriteModelForAuth(routeModel, req.Metadata, auth)
 		execReq.Model, execReq.Metadata = m.applyOAuthModelMapping(auth, execReq.Model, execReq.Metadata)
+		started := time.Now()
 		chunks, errStream := exec.ExecuteStream(execCtx, auth, execReq, opts)
 		if errStream != nil {
 			rerr := &Error{Message: errStream.Error()}
@@ -652,13 +670,17 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 				cb.RecordFailure()
 			}
 			m.MarkResult(execCtx, result)
+			notifyAttempt(ctx, Attempt{Provider: provider, AuthID: auth.ID, Model: routeModel, Status: rerr.HTTPStatus, Err: errStream, Duration: time.Since(started)})
 			lastErr = errStream
 			continue
 		}
+		notifyAttempt(ctx, Attempt{Provider: provider, AuthID: auth.ID, Model: routeModel, Duration: time.Since(started)})
+		notifyServedProvider(ctx, provider)
 		out := make(chan cliproxyexecutor.StreamChunk)
 		go func(streamCtx context.Context, streamAuth *Auth, streamProvider string, streamChunks <-chan cliproxyexecutor.StreamChunk, streamCB *circuitbreaker.CircuitBreaker) {
 			defer close(out)
 			var failed bool
+			var ttfb time.Duration
 			for {
 				select {
 				case <-streamCtx.Done():
@@ -667,12 +689,18 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 				case chunk, ok := <-streamChunks:
 					if !ok {
 						// Upstream closed - record final result
+						if ttfb > 0 {
+							recordStreamLatency(streamProvider, ttfb, time.Since(started))
+						}
 						if !failed {
 							streamCB.RecordSuccess()
 							m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: routeModel, Success: true})
 						}
 						return
 					}
+					if ttfb == 0 && len(chunk.Payload) > 0 {
+						ttfb = time.Since(started)
+					}
 					if chunk.Err != nil && !failed {
 						failed = true
 						rerr := &Error{Message: chunk.Err.Error()}
@@ -699,6 +727,12 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 	}
 }
 
+// recordStreamLatency publishes how long a provider took to start and to finish a stream, so
+// slow-to-start providers can be told apart from slow-to-finish ones.
+func recordStreamLatency(provider string, ttfb, total time.Duration) {
+	observability.GetMetricsRecorder().RecordProviderLatency(provider, ttfb, total)
+}
+
 func rewriteModelForAuth(model string, metadata map[string]any, auth *Auth) (string, map[string]any) {
 	if auth == nil || model == "" {
 		return model, metadata
@@ -861,9 +895,19 @@ func (m *Manager) shouldRetryAfterError(err error, attempt, maxAttempts int, pro
 	if !found || wait > maxWait {
 		return 0, false
 	}
+	if !scheduler.GetRetryBudget().Allow() {
+		return 0, false
+	}
 	return wait, true
 }
 
+// retryBudgetAllows reports whether another credential may be tried after lastErr. The
+// first attempt is free; every rotation after a failure spends a retry from the global
+// retry budget.
+func retryBudgetAllows(lastErr error) bool {
+	return lastErr == nil || scheduler.GetRetryBudget().Allow()
+}
+
 func waitForCooldown(ctx context.Context, wait time.Duration) error {
 	if wait <= 0 {
 		return nil
@@ -1326,6 +1370,9 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 	}
 	candidates := make([]*Auth, 0, len(m.auths))
 	modelKey := strings.TrimSpace(model)
+	if routedToDefault, _ := opts.Metadata[cliproxyexecutor.DefaultProviderMetadataKey].(bool); routedToDefault {
+		modelKey = ""
+	}
 	registryRef := registry.GetGlobalRegistry()
 	for _, candidate := range m.auths {
 		if candidate.Provider != provider || candidate.Disabled {
@@ -1747,6 +1794,22 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 	_, _ = m.Update(ctx, updated)
 }
 
+// EmbeddingsExecutor is an optional interface that provider executors implement when they
+// serve requests carrying the cliproxyexecutor.EmbeddingsAction metadata.
+type EmbeddingsExecutor interface {
+	SupportsEmbeddings() bool
+}
+
+// SupportsEmbeddings reports whether the executor registered for provider serves
+// embeddings requests.
+func (m *Manager) SupportsEmbeddings(provider string) bool {
+	if m == nil {
+		return false
+	}
+	exec, ok := m.executorFor(strings.ToLower(strings.TrimSpace(provider))).(EmbeddingsExecutor)
+	return ok && exec.SupportsEmbeddings()
+}
+
 func (m *Manager) executorFor(provider string) ProviderExecutor {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -1756,6 +1819,63 @@ func (m *Manager) executorFor(provider string) ProviderExecutor {
 // roundTripperContextKey is an unexported context key type to avoid collisions.
 type roundTripperContextKey struct{}
 
+// servedProviderContextKey carries the callback registered with WithServedProvider.
+type servedProviderContextKey struct{}
+
+// WithServedProvider returns a context under which fn is called with the provider that
+// answered a request, once its executor succeeds or its stream starts. When a request is
+// retried on another provider, fn is called again for the provider that finally served it.
+func WithServedProvider(ctx context.Context, fn func(provider string)) context.Context {
+	return context.WithValue(ctx, servedProviderContextKey{}, fn)
+}
+
+func notifyServedProvider(ctx context.Context, provider string) {
+	if fn, ok := ctx.Value(servedProviderContextKey{}).(func(string)); ok && fn != nil {
+		fn(provider)
+	}
+}
+
+// Attempt describes one upstream call made while serving a request.
+type Attempt struct {
+	Provider string
+	AuthID   string
+	Model    string
+	// Retry is the retry round the call was made in, 0 for the first.
+	Retry int
+	// Status is the HTTP status the call failed with; 0 when it succeeded or the status is unknown.
+	Status int
+	// Err is the error the call failed with, nil when it succeeded.
+	Err error
+	// Duration is how long the call took to answer or, for streams, to start.
+	Duration time.Duration
+}
+
+// attemptObserverContextKey carries the callback registered with WithAttemptObserver.
+type attemptObserverContextKey struct{}
+
+// retryRoundContextKey carries the retry round of the calls made under a context.
+type retryRoundContextKey struct{}
+
+// WithAttemptObserver returns a context under which fn is called after every upstream call,
+// in order, including calls that failed over to another auth or were retried.
+func WithAttemptObserver(ctx context.Context, fn func(Attempt)) context.Context {
+	return context.WithValue(ctx, attemptObserverContextKey{}, fn)
+}
+
+func notifyAttempt(ctx context.Context, attempt Attempt) {
+	if fn, ok := ctx.Value(attemptObserverContextKey{}).(func(Attempt)); ok && fn != nil {
+		attempt.Retry, _ = ctx.Value(retryRoundContextKey{}).(int)
+		fn(attempt)
+	}
+}
+
+func withRetryRound(ctx context.Context, round int) context.Context {
+	if round == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, retryRoundContextKey{}, round)
+}
+
 // roundTripperFor retrieves an HTTP RoundTripper for the given auth if a provider is registered.
 func (m *Manager) roundTripperFor(auth *Auth) http.RoundTripper {
 	m.mu.RLock()
@@ -19,6 +19,16 @@ type Request struct {
 	Metadata map[string]any
 }
 
+// DefaultProviderMetadataKey marks a request routed to the configured default provider
+// because no routing rule or registered provider serves its model. Auth selection then
+// accepts credentials of that provider even though they do not list the model.
+const DefaultProviderMetadataKey = "default_provider"
+
+// EmbeddingsAction is the Request.Metadata "action" value of an /v1/embeddings call. The
+// payload is an OpenAI embeddings request that executors supporting it send untranslated
+// to the provider's embeddings endpoint.
+const EmbeddingsAction = "embeddings"
+
 // Options controls execution behavior for both streaming and non-streaming calls.
 type Options struct {
 	// Stream toggles streaming mode.
@@ -30,6 +30,12 @@ type OpenAICompatibility = internalconfig.OpenAICompatibility
 type OpenAICompatibilityAPIKey = internalconfig.OpenAICompatibilityAPIKey
 type OpenAICompatibilityModel = internalconfig.OpenAICompatibilityModel
 
+type PricingConfig = internalconfig.PricingConfig
+type ModelPrice = internalconfig.ModelPrice
+type GuardrailConfig = internalconfig.GuardrailConfig
+type GuardrailRule = internalconfig.GuardrailRule
+type OutputGuardrailConfig = internalconfig.OutputGuardrailConfig
+
 type TLS = internalconfig.TLSConfig
 
 const (
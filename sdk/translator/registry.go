@@ -10,6 +10,7 @@ type Registry struct {
 	mu        sync.RWMutex
 	requests  map[Format]map[Format]RequestTransform
 	responses map[Format]map[Format]ResponseTransform
+	onInvalid InvalidResponseFunc
 }
 
 // NewRegistry constructs an empty translator registry.
@@ -65,6 +66,25 @@ func (r *Registry) HasResponseTransformer(from, to Format) bool {
 	return false
 }
 
+// SetInvalidResponseHandler turns on validation of translated responses, reporting each one
+// that fails ValidateResponse to fn. A nil fn turns validation off.
+func (r *Registry) SetInvalidResponseHandler(fn InvalidResponseFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onInvalid = fn
+}
+
+// validate reports out to the invalid response handler when it fails ValidateResponse.
+// Callers hold r.mu.
+func (r *Registry) validate(ctx context.Context, from, to Format, out string, stream bool) {
+	if r.onInvalid == nil {
+		return
+	}
+	if err := ValidateResponse(to, out, stream); err != nil {
+		r.onInvalid(ctx, from, to, err)
+	}
+}
+
 // TranslateStream applies the registered streaming response translator.
 func (r *Registry) TranslateStream(ctx context.Context, from, to Format, model string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
 	r.mu.RLock()
@@ -72,7 +92,11 @@ func (r *Registry) TranslateStream(ctx context.Context, from, to Format, model s
 
 	if byTarget, ok := r.responses[from]; ok {
 		if fn, isOk := byTarget[to]; isOk && fn.Stream != nil {
-			return fn.Stream(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+			chunks := fn.Stream(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+			for _, chunk := range chunks {
+				r.validate(ctx, from, to, chunk, true)
+			}
+			return chunks
 		}
 	}
 	return []string{string(rawJSON)}
@@ -85,7 +109,9 @@ func (r *Registry) TranslateNonStream(ctx context.Context, from, to Format, mode
 
 	if byTarget, ok := r.responses[from]; ok {
 		if fn, isOk := byTarget[to]; isOk && fn.NonStream != nil {
-			return fn.NonStream(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+			out := fn.NonStream(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+			r.validate(ctx, from, to, out, false)
+			return out
 		}
 	}
 	return string(rawJSON)
@@ -126,6 +152,11 @@ func HasResponseTransformer(from, to Format) bool {
 	return defaultRegistry.HasResponseTransformer(from, to)
 }
 
+// SetInvalidResponseHandler turns on validation of translated responses in the default registry.
+func SetInvalidResponseHandler(fn InvalidResponseFunc) {
+	defaultRegistry.SetInvalidResponseHandler(fn)
+}
+
 // TranslateStream is a helper on the default registry.
 func TranslateStream(ctx context.Context, from, to Format, model string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
 	return defaultRegistry.TranslateStream(ctx, from, to, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
@@ -0,0 +1,135 @@
+package translator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// InvalidResponseFunc is told about a translated response that does not have the shape its
+// target format requires. The response itself is still returned to the caller unchanged.
+type InvalidResponseFunc func(ctx context.Context, from, to Format, err error)
+
+// openAIFinishReasons are the finish_reason values an OpenAI chat completion may carry.
+var openAIFinishReasons = map[string]bool{
+	"stop":           true,
+	"length":         true,
+	"tool_calls":     true,
+	"content_filter": true,
+	"function_call":  true,
+}
+
+// claudeStopReasons are the stop_reason values a Claude message may carry.
+var claudeStopReasons = map[string]bool{
+	"end_turn":      true,
+	"max_tokens":    true,
+	"stop_sequence": true,
+	"tool_use":      true,
+	"pause_turn":    true,
+	"refusal":       true,
+}
+
+// ValidateResponse reports whether payload, a translated response or stream chunk, has the
+// shape the format expects. Formats without a known shape, empty chunks and error payloads
+// always pass.
+func ValidateResponse(format Format, payload string, stream bool) error {
+	switch format {
+	case FormatOpenAI:
+		for _, data := range streamData(payload, stream) {
+			if err := validateOpenAIChat(data, stream); err != nil {
+				return err
+			}
+		}
+	case FormatClaude:
+		for _, data := range streamData(payload, stream) {
+			if err := validateClaudeMessage(data, stream); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// streamData returns the JSON documents in payload: the data of each server-sent event for
+// a stream chunk, the payload itself otherwise. The [DONE] sentinel is skipped.
+func streamData(payload string, stream bool) []string {
+	if !stream || !strings.Contains(payload, "data:") {
+		if strings.TrimSpace(payload) == "" || strings.TrimSpace(payload) == "[DONE]" {
+			return nil
+		}
+		return []string{payload}
+	}
+	var out []string
+	for _, line := range strings.Split(payload, "\n") {
+		data, ok := strings.CutPrefix(strings.TrimSpace(line), "data:")
+		if !ok {
+			continue
+		}
+		if data = strings.TrimSpace(data); data != "" && data != "[DONE]" {
+			out = append(out, data)
+		}
+	}
+	return out
+}
+
+func validateOpenAIChat(data string, stream bool) error {
+	if !gjson.Valid(data) {
+		return fmt.Errorf("openai response is not valid JSON")
+	}
+	root := gjson.Parse(data)
+	if root.Get("error").Exists() {
+		return nil
+	}
+	choices := root.Get("choices")
+	if !choices.IsArray() {
+		return fmt.Errorf("openai response has no choices array")
+	}
+	if !stream && len(choices.Array()) == 0 {
+		return fmt.Errorf("openai response has no choices")
+	}
+	field := "message"
+	if stream {
+		field = "delta"
+	}
+	for i, choice := range choices.Array() {
+		if !choice.Get(field).IsObject() {
+			return fmt.Errorf("openai choice %d has no %s object", i, field)
+		}
+		if reason := choice.Get("finish_reason"); reason.Type != gjson.Null && !openAIFinishReasons[reason.String()] {
+			return fmt.Errorf("openai choice %d has invalid finish_reason %q", i, reason.String())
+		}
+	}
+	return nil
+}
+
+func validateClaudeMessage(data string, stream bool) error {
+	if !gjson.Valid(data) {
+		return fmt.Errorf("claude response is not valid JSON")
+	}
+	root := gjson.Parse(data)
+	typ := root.Get("type").String()
+	if typ == "error" || root.Get("error").Exists() {
+		return nil
+	}
+	if stream {
+		if typ == "" {
+			return fmt.Errorf("claude stream event has no type")
+		}
+		if reason := root.Get("delta.stop_reason"); typ == "message_delta" && reason.Exists() && reason.Type != gjson.Null && !claudeStopReasons[reason.String()] {
+			return fmt.Errorf("claude message_delta has invalid stop_reason %q", reason.String())
+		}
+		return nil
+	}
+	if typ != "message" {
+		return fmt.Errorf("claude response type is %q, want message", typ)
+	}
+	if !root.Get("content").IsArray() {
+		return fmt.Errorf("claude response has no content array")
+	}
+	if reason := root.Get("stop_reason"); reason.Exists() && reason.Type != gjson.Null && !claudeStopReasons[reason.String()] {
+		return fmt.Errorf("claude response has invalid stop_reason %q", reason.String())
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package translator
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestValidateResponse(t *testing.T) {
+	cases := []struct {
+		name    string
+		format  Format
+		payload string
+		stream  bool
+		wantErr string
+	}{
+		{name: "openai message", format: FormatOpenAI, payload: `{"choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}]}`},
+		{name: "openai null finish", format: FormatOpenAI, payload: `{"choices":[{"delta":{"content":"hi"},"finish_reason":null}]}`, stream: true},
+		{name: "openai usage chunk", format: FormatOpenAI, payload: `{"choices":[],"usage":{"total_tokens":3}}`, stream: true},
+		{name: "openai sse chunk", format: FormatOpenAI, payload: "data: {\"choices\":[{\"delta\":{}}]}\n\ndata: [DONE]\n\n", stream: true},
+		{name: "openai error", format: FormatOpenAI, payload: `{"error":{"message":"boom","type":"server_error"}}`, stream: true},
+		{name: "openai missing choices", format: FormatOpenAI, payload: `{"id":"x"}`, wantErr: "no choices array"},
+		{name: "openai empty choices", format: FormatOpenAI, payload: `{"choices":[]}`, wantErr: "no choices"},
+		{name: "openai delta in non-stream", format: FormatOpenAI, payload: `{"choices":[{"delta":{"content":"hi"}}]}`, wantErr: "no message object"},
+		{name: "openai bad finish", format: FormatOpenAI, payload: `{"choices":[{"delta":{},"finish_reason":"MAX_TOKENS"}]}`, stream: true, wantErr: `invalid finish_reason "MAX_TOKENS"`},
+		{name: "openai not json", format: FormatOpenAI, payload: `{"choices":[`, wantErr: "not valid JSON"},
+		{name: "claude message", format: FormatClaude, payload: `{"type":"message","content":[{"type":"text","text":"hi"}],"stop_reason":"end_turn"}`},
+		{name: "claude events", format: FormatClaude, payload: "event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"}}\n\n", stream: true},
+		{name: "claude bad stop", format: FormatClaude, payload: `{"type":"message","content":[],"stop_reason":"STOP"}`, wantErr: `invalid stop_reason "STOP"`},
+		{name: "claude untyped event", format: FormatClaude, payload: "event: ping\ndata: {}\n\n", stream: true, wantErr: "no type"},
+		{name: "unchecked format", format: FormatGemini, payload: `not json`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateResponse(tc.format, tc.payload, tc.stream)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegistry_FlagsMalformedTranslation(t *testing.T) {
+	const malformed = `{"choices":[{"message":{"role":"assistant","content":"hi"},"finish_reason":"end_turn"}]}`
+	r := NewRegistry()
+	r.Register(FormatClaude, FormatOpenAI, nil, ResponseTransform{
+		NonStream: func(context.Context, string, []byte, []byte, []byte, *any) string { return malformed },
+		Stream: func(context.Context, string, []byte, []byte, []byte, *any) []string {
+			return []string{`{"choices":[{"delta":{"content":"hi"}}]}`, `{"object":"chat.completion.chunk"}`}
+		},
+	})
+
+	// Without a handler nothing is validated.
+	if out := r.TranslateNonStream(context.Background(), FormatClaude, FormatOpenAI, "m", nil, nil, nil, nil); out != malformed {
+		t.Fatalf("response altered: %s", out)
+	}
+
+	var flagged []error
+	r.SetInvalidResponseHandler(func(_ context.Context, from, to Format, err error) {
+		if from != FormatClaude || to != FormatOpenAI {
+			t.Errorf("flagged %s -> %s, want claude -> openai", from, to)
+		}
+		flagged = append(flagged, err)
+	})
+
+	if out := r.TranslateNonStream(context.Background(), FormatClaude, FormatOpenAI, "m", nil, nil, nil, nil); out != malformed {
+		t.Fatalf("validation altered the response: %s", out)
+	}
+	if len(flagged) != 1 || !strings.Contains(flagged[0].Error(), `invalid finish_reason "end_turn"`) {
+		t.Fatalf("flagged = %v, want the invalid finish_reason", flagged)
+	}
+
+	flagged = nil
+	chunks := r.TranslateStream(context.Background(), FormatClaude, FormatOpenAI, "m", nil, nil, nil, nil)
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2", len(chunks))
+	}
+	if len(flagged) != 1 || !strings.Contains(flagged[0].Error(), "no choices array") {
+		t.Fatalf("flagged = %v, want only the chunk without choices", flagged)
+	}
+}